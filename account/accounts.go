@@ -2,6 +2,7 @@
 package account
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"reflect"
 	"sort"
@@ -20,7 +21,6 @@ import (
 	"kuskcore/crypto/sha3pool"
 	dbm "kuskcore/database/leveldb"
 	"kuskcore/errors"
-	"kuskcore/protocol"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/vm/vmutil"
 )
@@ -54,6 +54,8 @@ var (
 	ErrContractIndex   = errors.New("Exceeded maximum addresses per account")
 	ErrAccountIndex    = errors.New("Exceeded maximum accounts per xpub")
 	ErrFindTransaction = errors.New("No transaction")
+	ErrHTLCPreimage    = errors.New("Preimage mismatches the htlc secret hash")
+	ErrHTLCLocked      = errors.New("HTLC can't be refunded before the lock height")
 )
 
 // ContractKey account control promgram store prefix
@@ -98,10 +100,16 @@ type CtrlProgram struct {
 	Change         bool // Mark whether this control program is for UTXO change
 }
 
+// Chain provides the best height to the account manager, it's the core chain
+// of the full node or the header chain of the light node
+type Chain interface {
+	BestBlockHeight() uint64
+}
+
 // Manager stores accounts and their associated control programs.
 type Manager struct {
 	db         dbm.DB
-	chain      *protocol.Chain
+	chain      Chain
 	utxoKeeper *utxoKeeper
 
 	addressMu sync.Mutex
@@ -109,11 +117,11 @@ type Manager struct {
 }
 
 // NewManager creates a new account manager
-func NewManager(walletDB dbm.DB, chain *protocol.Chain) *Manager {
+func NewManager(walletDB dbm.DB, chain Chain) *Manager {
 	return &Manager{
 		db:         walletDB,
 		chain:      chain,
-		utxoKeeper: newUtxoKeeper(chain.BestBlockHeight, walletDB),
+		utxoKeeper: newUtxoKeeper(func() uint64 { return chain.BestBlockHeight() }, walletDB),
 	}
 }
 
@@ -486,6 +494,42 @@ func (m *Manager) GetLocalCtrlProgramByAddress(address string) (*CtrlProgram, er
 		return nil, err
 	}
 
+	return m.getLocalCtrlProgram(program)
+}
+
+// GetLocalCtrlProgramByPubkey return the local P2WPKH control program of the
+// pubkey, only the keys of the single signature accounts are found since the
+// keys of the multi signature accounts are used by P2WSH control programs
+func (m *Manager) GetLocalCtrlProgramByPubkey(pubkey ed25519.PublicKey) (*CtrlProgram, error) {
+	program, err := vmutil.P2WPKHProgram(crypto.Ripemd160(pubkey))
+	if err != nil {
+		return nil, err
+	}
+
+	return m.getLocalCtrlProgram(program)
+}
+
+// GetLocalKeyPath return the account and the derivation path of the local
+// key, the key should be the one of a P2WPKH control program
+func (m *Manager) GetLocalKeyPath(pubkey ed25519.PublicKey) (*Account, [][]byte, error) {
+	cp, err := m.GetLocalCtrlProgramByPubkey(pubkey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account, err := m.FindByID(cp.AccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path, err := signers.Path(account.Signer, signers.AccountKeySpace, cp.Change, cp.KeyIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, path, nil
+}
+
+func (m *Manager) getLocalCtrlProgram(program []byte) (*CtrlProgram, error) {
 	var hash [32]byte
 	sha3pool.Sum256(hash[:], program)
 	rawProgram := m.db.Get(ContractKey(hash))
@@ -1,7 +1,9 @@
 package account
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	stdjson "encoding/json"
 
 	"kuskcore/blockchain/signers"
@@ -13,6 +15,7 @@ import (
 	"kuskcore/errors"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm"
 	"kuskcore/protocol/vm/vmutil"
 )
 
@@ -297,6 +300,85 @@ func (a *spendUTXOAction) Build(ctx context.Context, b *txbuilder.TemplateBuilde
 	return b.AddInput(txInput, sigInst)
 }
 
+// DecodeClaimHTLCAction unmarshal JSON-encoded data of claim htlc action
+func (m *Manager) DecodeClaimHTLCAction(data []byte) (txbuilder.Action, error) {
+	a := &htlcAction{accounts: m, claim: true}
+	return a, stdjson.Unmarshal(data, a)
+}
+
+// DecodeRefundHTLCAction unmarshal JSON-encoded data of refund htlc action
+func (m *Manager) DecodeRefundHTLCAction(data []byte) (txbuilder.Action, error) {
+	a := &htlcAction{accounts: m}
+	return a, stdjson.Unmarshal(data, a)
+}
+
+// htlcAction spends a hash time-locked contract output, the recipient claims
+// it with the preimage and the sender refunds it after the lock height
+type htlcAction struct {
+	accounts       *Manager
+	claim          bool
+	OutputID       *bc.Hash      `json:"output_id"`
+	Preimage       json.HexBytes `json:"preimage"`
+	UseUnconfirmed bool          `json:"use_unconfirmed"`
+}
+
+func (a *htlcAction) ActionType() string {
+	if a.claim {
+		return "claim_htlc"
+	}
+	return "refund_htlc"
+}
+
+func (a *htlcAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
+	var missing []string
+	if a.OutputID == nil {
+		missing = append(missing, "output_id")
+	}
+	if a.claim && len(a.Preimage) == 0 {
+		missing = append(missing, "preimage")
+	}
+	if len(missing) > 0 {
+		return txbuilder.MissingFieldsError(missing...)
+	}
+
+	res, err := a.accounts.utxoKeeper.ReserveParticular(*a.OutputID, a.UseUnconfirmed, b.MaxTime())
+	if err != nil {
+		return err
+	}
+
+	b.OnRollback(func() { a.accounts.utxoKeeper.Cancel(res.id) })
+	u := res.utxos[0]
+	contract, err := vmutil.ParseHTLCProgram(u.ControlProgram)
+	if err != nil {
+		return err
+	}
+
+	pubkey, selector := contract.Sender, vm.BoolBytes(false)
+	if a.claim {
+		if hash := sha256.Sum256(a.Preimage); len(a.Preimage) != vmutil.HTLCPreimageSize || !bytes.Equal(hash[:], contract.SecretHash) {
+			return ErrHTLCPreimage
+		}
+		pubkey, selector = contract.Recipient, vm.BoolBytes(true)
+	} else if height := a.accounts.chain.BestBlockHeight() + 1; height < contract.LockHeight {
+		return errors.WithDetailf(ErrHTLCLocked, "lock height %d, next block height %d", contract.LockHeight, height)
+	}
+
+	account, path, err := a.accounts.GetLocalKeyPath(pubkey)
+	if err != nil {
+		return err
+	}
+
+	sigInst := &txbuilder.SigningInstruction{}
+	sigInst.AddRawWitnessKeys(account.XPubs, path, account.Quorum)
+	if a.claim {
+		sigInst.WitnessComponents = append(sigInst.WitnessComponents, txbuilder.DataWitness(a.Preimage))
+	}
+	sigInst.WitnessComponents = append(sigInst.WitnessComponents, txbuilder.DataWitness(selector))
+
+	txInput := types.NewSpendInput(nil, u.SourceID, u.AssetID, u.Amount, u.SourcePos, u.ControlProgram, u.StateData)
+	return b.AddInput(txInput, sigInst)
+}
+
 // UtxoToInputs convert an utxo to the txinput
 func UtxoToInputs(signer *signers.Signer, u *UTXO) (*types.TxInput, *txbuilder.SigningInstruction, error) {
 	txInput := types.NewSpendInput(nil, u.SourceID, u.AssetID, u.Amount, u.SourcePos, u.ControlProgram, u.StateData)
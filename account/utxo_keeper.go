@@ -14,6 +14,7 @@ import (
 	dbm "kuskcore/database/leveldb"
 	"kuskcore/errors"
 	"kuskcore/protocol/bc"
+	"kuskcore/protocol/vm/vmutil"
 )
 
 const desireUtxoCount = 5
@@ -210,6 +211,11 @@ func (uk *utxoKeeper) findUtxos(accountID string, assetID *bc.AssetID, useUnconf
 	currentHeight := uk.currentHeight()
 	utxos := []*UTXO{}
 	appendUtxo := func(u *UTXO) {
+		// the htlc outputs can only be spent by the claim or refund actions
+		if vmutil.IsHTLCProgram(u.ControlProgram) {
+			return
+		}
+
 		if u.AccountID != accountID || u.AssetID != *assetID || !bytes.Equal(u.Vote, vote) {
 			return
 		}
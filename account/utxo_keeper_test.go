@@ -8,6 +8,7 @@ import (
 
 	dbm "kuskcore/database/leveldb"
 	"kuskcore/protocol/bc"
+	"kuskcore/protocol/vm/vmutil"
 	"kuskcore/testutil"
 )
 
@@ -686,6 +687,11 @@ func TestFindUtxos(t *testing.T) {
 	testDB := dbm.NewDB("testdb", "leveldb", "temp")
 	defer os.RemoveAll("temp")
 
+	htlcProgram, err := vmutil.HTLCProgram(make([]byte, 32), make([]byte, 32), make([]byte, 32), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	cases := []struct {
 		uk             utxoKeeper
 		dbUtxos        []*UTXO
@@ -858,6 +864,36 @@ func TestFindUtxos(t *testing.T) {
 			},
 			immatureAmount: 0,
 		},
+		{
+			uk: utxoKeeper{
+				db:            testDB,
+				currentHeight: currentHeight,
+				unconfirmed: map[bc.Hash]*UTXO{
+					bc.NewHash([32]byte{0x01}): &UTXO{
+						OutputID:       bc.NewHash([32]byte{0x01}),
+						AccountID:      "testAccount",
+						Amount:         1,
+						ControlProgram: htlcProgram,
+					},
+				},
+			},
+			dbUtxos: []*UTXO{
+				&UTXO{
+					OutputID:  bc.NewHash([32]byte{0x02}),
+					AccountID: "testAccount",
+					Amount:    2,
+				},
+			},
+			useUnconfirmed: true,
+			wantUtxos: []*UTXO{
+				&UTXO{
+					OutputID:  bc.NewHash([32]byte{0x02}),
+					AccountID: "testAccount",
+					Amount:    2,
+				},
+			},
+			immatureAmount: 0,
+		},
 	}
 
 	for i, c := range cases {
@@ -24,9 +24,13 @@ import (
 	"kuskcore/net/http/static"
 	"kuskcore/net/websocket"
 	"kuskcore/netsync/peers"
+	"kuskcore/order"
 	"kuskcore/p2p"
+	"kuskcore/p2p/addrbook"
+	"kuskcore/p2p/security"
 	"kuskcore/proposal/blockproposer"
 	"kuskcore/protocol"
+	"kuskcore/protocol/bc/types"
 	"kuskcore/wallet"
 )
 
@@ -111,6 +115,7 @@ type API struct {
 	accessTokens    *accesstoken.CredentialStore
 	chain           *protocol.Chain
 	contractTracer  *contract.TraceService
+	orderBook       *order.OrderBook
 	server          *http.Server
 	handler         http.Handler
 	blockProposer   *blockproposer.BlockProposer
@@ -176,16 +181,25 @@ type NetSync interface {
 	BestPeer() *peers.PeerInfo
 	DialPeerWithAddress(addr *p2p.NetAddress) error
 	GetPeerInfos() []*peers.PeerInfo
+	ListKnownAddresses() []*addrbook.KnownAddress
+	BanPeer(target string, duration time.Duration, reason string) error
+	UnbanPeer(target string) error
+	ListBannedPeers() []*security.BanEntry
+	AddWhitelistPeer(target string) error
+	RemoveWhitelistPeer(target string) error
+	ListWhitelistPeers() []string
 	StopPeer(peerID string) error
+	RelayTx(tx *types.Tx) error
 }
 
 // NewAPI create and initialize the API
-func NewAPI(sync NetSync, wallet *wallet.Wallet, blockProposer *blockproposer.BlockProposer, chain *protocol.Chain, traceService *contract.TraceService, config *cfg.Config, token *accesstoken.CredentialStore, dispatcher *event.Dispatcher, notificationMgr *websocket.WSNotificationManager) *API {
+func NewAPI(sync NetSync, wallet *wallet.Wallet, blockProposer *blockproposer.BlockProposer, chain *protocol.Chain, traceService *contract.TraceService, orderBook *order.OrderBook, config *cfg.Config, token *accesstoken.CredentialStore, dispatcher *event.Dispatcher, notificationMgr *websocket.WSNotificationManager) *API {
 	api := &API{
 		sync:            sync,
 		wallet:          wallet,
 		chain:           chain,
 		contractTracer:  traceService,
+		orderBook:       orderBook,
 		accessTokens:    token,
 		blockProposer:   blockProposer,
 		eventDispatcher: dispatcher,
@@ -244,6 +258,13 @@ func (a *API) buildHandler() {
 		m.Handle("/list-balances", jsonHandler(a.listBalances))
 		m.Handle("/list-unspent-outputs", jsonHandler(a.listUnspentOutputs))
 		m.Handle("/list-account-votes", jsonHandler(a.listAccountVotes))
+		m.Handle("/create-htlc-secret", jsonHandler(a.createHTLCSecret))
+		m.Handle("/list-htlcs", jsonHandler(a.listHTLCs))
+		m.Handle("/list-payment-channels", jsonHandler(a.listPaymentChannels))
+		m.Handle("/get-payment-channel", jsonHandler(a.getPaymentChannel))
+		m.Handle("/sign-channel-payment", jsonHandler(a.signChannelPayment))
+		m.Handle("/sign-channel-close", jsonHandler(a.signChannelClose))
+		m.Handle("/accept-channel-update", jsonHandler(a.acceptChannelUpdate))
 
 		m.Handle("/decode-program", jsonHandler(a.decodeProgram))
 
@@ -268,43 +289,67 @@ func (a *API) buildHandler() {
 	m.Handle("/update-contract-alias", jsonHandler(a.updateContractAlias))
 	m.Handle("/get-contract", jsonHandler(a.getContract))
 	m.Handle("/list-contracts", jsonHandler(a.listContracts))
+	m.Handle("/compile", jsonHandler(a.compile))
 
-	m.Handle("/submit-transaction", jsonHandler(a.submit))
-	m.Handle("/submit-transactions", jsonHandler(a.submitTxs))
-	m.Handle("/estimate-transaction-gas", jsonHandler(a.estimateTxGas))
-	m.Handle("/estimate-chain-transaction-gas", jsonHandler(a.estimateChainTxGas))
-
-	m.Handle("/get-unconfirmed-transaction", jsonHandler(a.getUnconfirmedTx))
-	m.Handle("/list-unconfirmed-transactions", jsonHandler(a.listUnconfirmedTxs))
 	m.Handle("/decode-raw-transaction", jsonHandler(a.decodeRawTransaction))
-
-	m.Handle("/get-block", jsonHandler(a.getBlock))
-	m.Handle("/get-raw-block", jsonHandler(a.getRawBlock))
-	m.Handle("/get-block-hash", jsonHandler(a.getBestBlockHash))
-	m.Handle("/get-block-header", jsonHandler(a.getBlockHeader))
-	m.Handle("/get-block-count", jsonHandler(a.getBlockCount))
-
-	m.Handle("/is-mining", jsonHandler(a.isMining))
-	m.Handle("/set-mining", jsonHandler(a.setMining))
-
 	m.Handle("/verify-message", jsonHandler(a.verifyMessage))
-
 	m.Handle("/gas-rate", jsonHandler(a.gasRate))
-	m.Handle("/net-info", jsonHandler(a.getNetInfo))
-	m.Handle("/chain-status", jsonHandler(a.getChainStatus))
 
 	m.Handle("/list-peers", jsonHandler(a.listPeers))
 	m.Handle("/disconnect-peer", jsonHandler(a.disconnectPeer))
 	m.Handle("/connect-peer", jsonHandler(a.connectPeer))
-
-	m.Handle("/get-merkle-proof", jsonHandler(a.getMerkleProof))
-	m.Handle("/get-vote-result", jsonHandler(a.getVoteResult))
-
-	m.Handle("/get-contract-instance", jsonHandler(a.getContractInstance))
-	m.Handle("/create-contract-instance", jsonHandler(a.createContractInstance))
-	m.Handle("/remove-contract-instance", jsonHandler(a.removeContractInstance))
-
-	m.HandleFunc("/websocket-subscribe", a.websocketHandler)
+	m.Handle("/list-known-addresses", jsonHandler(a.listKnownAddresses))
+	m.Handle("/ban-peer", jsonHandler(a.banPeer))
+	m.Handle("/unban-peer", jsonHandler(a.unbanPeer))
+	m.Handle("/list-banned-peers", jsonHandler(a.listBannedPeers))
+	m.Handle("/add-whitelist-peer", jsonHandler(a.addWhitelistPeer))
+	m.Handle("/remove-whitelist-peer", jsonHandler(a.removeWhitelistPeer))
+	m.Handle("/list-whitelist-peers", jsonHandler(a.listWhitelistPeers))
+
+	// the light node has no core chain, the routes querying the chain are
+	// disabled and the submitted transactions are relayed to the full nodes
+	if a.chain == nil {
+		m.Handle("/submit-transaction", jsonHandler(a.relay))
+		m.Handle("/submit-transactions", jsonHandler(a.relayTxs))
+	} else {
+		m.Handle("/get-order", jsonHandler(a.getOrder))
+		m.Handle("/list-orders", jsonHandler(a.listOrders))
+		m.Handle("/match-orders", jsonHandler(a.matchOrders))
+
+		m.Handle("/submit-transaction", jsonHandler(a.submit))
+		m.Handle("/submit-transactions", jsonHandler(a.submitTxs))
+		m.Handle("/estimate-transaction-gas", jsonHandler(a.estimateTxGas))
+		m.Handle("/estimate-chain-transaction-gas", jsonHandler(a.estimateChainTxGas))
+		m.Handle("/debug-transaction", jsonHandler(a.debugTransaction))
+		m.Handle("/simulate-transaction", jsonHandler(a.simulateTransaction))
+
+		m.Handle("/get-unconfirmed-transaction", jsonHandler(a.getUnconfirmedTx))
+		m.Handle("/list-unconfirmed-transactions", jsonHandler(a.listUnconfirmedTxs))
+
+		m.Handle("/get-block", jsonHandler(a.getBlock))
+		m.Handle("/get-raw-block", jsonHandler(a.getRawBlock))
+		m.Handle("/get-block-hash", jsonHandler(a.getBestBlockHash))
+		m.Handle("/get-block-header", jsonHandler(a.getBlockHeader))
+		m.Handle("/get-block-count", jsonHandler(a.getBlockCount))
+
+		m.Handle("/is-mining", jsonHandler(a.isMining))
+		m.Handle("/set-mining", jsonHandler(a.setMining))
+
+		m.Handle("/net-info", jsonHandler(a.getNetInfo))
+		m.Handle("/chain-status", jsonHandler(a.getChainStatus))
+
+		m.Handle("/get-merkle-proof", jsonHandler(a.getMerkleProof))
+		m.Handle("/get-block-filters", jsonHandler(a.getBlockFilters))
+		m.Handle("/get-block-filter-headers", jsonHandler(a.getBlockFilterHeaders))
+		m.Handle("/get-vote-result", jsonHandler(a.getVoteResult))
+
+		m.Handle("/get-contract-instance", jsonHandler(a.getContractInstance))
+		m.Handle("/list-contract-instances", jsonHandler(a.listContractInstances))
+		m.Handle("/create-contract-instance", jsonHandler(a.createContractInstance))
+		m.Handle("/remove-contract-instance", jsonHandler(a.removeContractInstance))
+
+		m.HandleFunc("/websocket-subscribe", a.websocketHandler)
+	}
 
 	handler := walletHandler(m, walletEnable)
 	handler = webAssetsHandler(handler)
@@ -10,6 +10,7 @@ import (
 	"kuskcore/blockchain/rpc"
 	dbm "kuskcore/database/leveldb"
 	"kuskcore/testutil"
+	"kuskcore/wallet"
 )
 
 func TestAPIHandler(t *testing.T) {
@@ -95,3 +96,17 @@ func TestAPIHandler(t *testing.T) {
 		}
 	}
 }
+
+func TestActionDecoderWithoutOrderBook(t *testing.T) {
+	// the light node has no order book
+	a := &API{wallet: &wallet.Wallet{}}
+	for _, action := range []string{"fill_order", "cancel_order"} {
+		if _, ok := a.actionDecoder(action); ok {
+			t.Errorf("got the decoder of %s without the order book", action)
+		}
+	}
+
+	if _, ok := a.actionDecoder("place_order"); !ok {
+		t.Error("the decoder of place_order is not found")
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+)
+
+const (
+	maxNumOfBlockFilters  = uint64(1000)
+	maxNumOfFilterHeaders = uint64(2000)
+)
+
+var errInvalidFilterRange = errors.New("invalid range of the block filters")
+
+// BlockFiltersReq is used to handle the block filters req by height range
+type BlockFiltersReq struct {
+	StartHeight uint64 `json:"start_height"`
+	Count       uint64 `json:"count"`
+}
+
+// BlockFilterResp is the compact filter of the block
+type BlockFilterResp struct {
+	BlockHeight  uint64             `json:"block_height"`
+	BlockHash    *bc.Hash           `json:"block_hash"`
+	Filter       chainjson.HexBytes `json:"filter"`
+	FilterHeader *bc.Hash           `json:"filter_header"`
+}
+
+// GetFilterHeadersResp is resp struct for getBlockFilterHeaders API
+type GetFilterHeadersResp struct {
+	StartHeight      uint64     `json:"start_height"`
+	PrevFilterHeader *bc.Hash   `json:"prev_filter_header"`
+	FilterHeaders    []*bc.Hash `json:"filter_headers"`
+}
+
+// filterRange returns the end height of the main chain blocks in the range
+func (a *API) filterRange(ins BlockFiltersReq, maxNum uint64) (uint64, error) {
+	bestHeight := a.chain.BestBlockHeight()
+	if ins.Count == 0 || ins.StartHeight > bestHeight {
+		return 0, errors.WithDetailf(errInvalidFilterRange, "start height %d, count %d, best height %d", ins.StartHeight, ins.Count, bestHeight)
+	}
+
+	count := ins.Count
+	if count > maxNum {
+		count = maxNum
+	}
+
+	if endHeight := ins.StartHeight + count - 1; endHeight < bestHeight {
+		return endHeight, nil
+	}
+	return bestHeight, nil
+}
+
+func (a *API) getBlockFilters(ins BlockFiltersReq) Response {
+	endHeight, err := a.filterRange(ins, maxNumOfBlockFilters)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	resp := []*BlockFilterResp{}
+	for height := ins.StartHeight; height <= endHeight; height++ {
+		header, err := a.chain.GetHeaderByHeight(height)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+
+		blockHash := header.Hash()
+		filter, err := a.chain.GetBlockFilter(&blockHash)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+
+		filterHeader, err := a.chain.GetBlockFilterHeader(&blockHash)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+
+		resp = append(resp, &BlockFilterResp{
+			BlockHeight:  height,
+			BlockHash:    &blockHash,
+			Filter:       filter,
+			FilterHeader: filterHeader,
+		})
+	}
+	return NewSuccessResponse(resp)
+}
+
+func (a *API) getBlockFilterHeaders(ins BlockFiltersReq) Response {
+	endHeight, err := a.filterRange(ins, maxNumOfFilterHeaders)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	resp := &GetFilterHeadersResp{StartHeight: ins.StartHeight, PrevFilterHeader: &bc.Hash{}}
+	if ins.StartHeight > 0 {
+		header, err := a.chain.GetHeaderByHeight(ins.StartHeight - 1)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+
+		blockHash := header.Hash()
+		if resp.PrevFilterHeader, err = a.chain.GetBlockFilterHeader(&blockHash); err != nil {
+			return NewErrorResponse(err)
+		}
+	}
+
+	for height := ins.StartHeight; height <= endHeight; height++ {
+		header, err := a.chain.GetHeaderByHeight(height)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+
+		blockHash := header.Hash()
+		filterHeader, err := a.chain.GetBlockFilterHeader(&blockHash)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+
+		resp.FilterHeaders = append(resp.FilterHeaders, filterHeader)
+	}
+	return NewSuccessResponse(resp)
+}
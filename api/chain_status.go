@@ -1,13 +1,34 @@
 package api
 
+import "kuskcore/consensus"
+
 // ChainStatus indicate chain status
 type ChainStatus struct {
-	CurrentHeight   uint64 `json:"current_height"`
-	CurrentHash     string `json:"current_hash"`
-	FinalizedHeight uint64 `json:"finalized_height"`
-	FinalizedHash   string `json:"finalized_hash"`
-	JustifiedHeight uint64 `json:"justified_height"`
-	JustifiedHash   string `json:"justified_hash"`
+	CurrentHeight   uint64        `json:"current_height"`
+	CurrentHash     string        `json:"current_hash"`
+	FinalizedHeight uint64        `json:"finalized_height"`
+	FinalizedHash   string        `json:"finalized_hash"`
+	JustifiedHeight uint64        `json:"justified_height"`
+	JustifiedHash   string        `json:"justified_hash"`
+	UpcomingForks   []*ForkStatus `json:"upcoming_forks"`
+	Deployments     []*Deployment `json:"deployments"`
+}
+
+// ForkStatus indicate a scheduled fork not activated yet
+type ForkStatus struct {
+	Name             string   `json:"name"`
+	ActivationHeight uint64   `json:"activation_height"`
+	Features         []string `json:"features"`
+}
+
+// Deployment indicate the state of a deployment signalled by validators
+type Deployment struct {
+	Name         string `json:"name"`
+	Bit          uint8  `json:"bit"`
+	StartEpoch   uint64 `json:"start_epoch"`
+	TimeoutEpoch uint64 `json:"timeout_epoch"`
+	Threshold    uint64 `json:"threshold"`
+	State        string `json:"state"`
 }
 
 // getChainStatus return chain  status
@@ -32,14 +53,44 @@ func (a *API) GetChainStatus() (*ChainStatus, error) {
 		return nil, err
 	}
 
+	bestHeight := a.chain.BestBlockHeight()
+	upcomingForks := []*ForkStatus{}
+	for _, fork := range consensus.ActiveNetParams.UpcomingForks(bestHeight) {
+		upcomingForks = append(upcomingForks, &ForkStatus{
+			Name:             fork.Name,
+			ActivationHeight: fork.ActivationHeight(consensus.ActiveNetParams.BlocksOfEpoch),
+			Features:         fork.Features,
+		})
+	}
+
+	bestHash := a.chain.BestBlockHash()
+	deploymentStates, err := a.chain.DeploymentStates(bestHash)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments := []*Deployment{}
+	for _, deployment := range consensus.ActiveNetParams.Deployments {
+		deployments = append(deployments, &Deployment{
+			Name:         deployment.Name,
+			Bit:          deployment.Bit,
+			StartEpoch:   deployment.StartEpoch,
+			TimeoutEpoch: deployment.TimeoutEpoch,
+			Threshold:    deployment.Threshold,
+			State:        deploymentStates[deployment.Name].String(),
+		})
+	}
+
 	finalizedHash := finalizedBlockHeader.Hash()
 	justifiedHash := justifiedBlockHeader.Hash()
 	return &ChainStatus{
-		CurrentHeight:   a.chain.BestBlockHeight(),
-		CurrentHash:     a.chain.BestBlockHash().String(),
+		CurrentHeight:   bestHeight,
+		CurrentHash:     bestHash.String(),
 		FinalizedHeight: finalizedBlockHeader.Height,
 		FinalizedHash:   finalizedHash.String(),
 		JustifiedHeight: justifiedBlockHeader.Height,
 		JustifiedHash:   justifiedHash.String(),
+		UpcomingForks:   upcomingForks,
+		Deployments:     deployments,
 	}, nil
 }
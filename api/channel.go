@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+
+	"kuskcore/protocol/bc"
+	"kuskcore/wallet"
+)
+
+// POST /list-payment-channels
+func (a *API) listPaymentChannels(ctx context.Context, filter struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) Response {
+	accountID := filter.AccountID
+	if filter.AccountAlias != "" {
+		acc, err := a.wallet.AccountMgr.FindByAlias(filter.AccountAlias)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+		accountID = acc.ID
+	}
+
+	channels, err := a.wallet.ListPaymentChannels(accountID)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return NewSuccessResponse(channels)
+}
+
+// POST /get-payment-channel
+func (a *API) getPaymentChannel(ctx context.Context, ins struct {
+	ChannelID bc.Hash `json:"channel_id"`
+}) Response {
+	channel, err := a.wallet.GetPaymentChannel(&ins.ChannelID)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	channel.Refundable = a.wallet.BestBlockHeight()+1 >= channel.Timeout
+	return NewSuccessResponse(channel)
+}
+
+// POST /sign-channel-payment
+// signs the new balance of the receiver by the sender, the returned update is
+// sent to the receiver off chain
+func (a *API) signChannelPayment(ctx context.Context, ins struct {
+	ChannelID bc.Hash `json:"channel_id"`
+	Paid      uint64  `json:"paid"`
+	Fee       uint64  `json:"fee"`
+	Password  string  `json:"password"`
+}) Response {
+	update, err := a.wallet.SignChannelPayment(&ins.ChannelID, ins.Paid, ins.Fee, ins.Password)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return NewSuccessResponse(update)
+}
+
+// POST /sign-channel-close
+// signs the latest balance by the receiver, the returned update is sent to
+// the sender to close the channel cooperatively
+func (a *API) signChannelClose(ctx context.Context, ins struct {
+	ChannelID bc.Hash `json:"channel_id"`
+	Password  string  `json:"password"`
+}) Response {
+	update, err := a.wallet.SignChannelClose(&ins.ChannelID, ins.Password)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return NewSuccessResponse(update)
+}
+
+// POST /accept-channel-update
+// verifies and saves the update signed by the other party of the channel
+func (a *API) acceptChannelUpdate(ctx context.Context, update wallet.ChannelUpdate) Response {
+	channel, err := a.wallet.AcceptChannelUpdate(&update)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return NewSuccessResponse(channel)
+}
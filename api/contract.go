@@ -23,6 +23,7 @@ var (
 func (a *API) createContract(_ context.Context, ins struct {
 	Alias    string             `json:"alias"`
 	Contract chainjson.HexBytes `json:"contract"`
+	ABI      *contract.ABI      `json:"abi"`
 }) Response {
 	ins.Alias = strings.TrimSpace(ins.Alias)
 	if ins.Alias == "" {
@@ -33,6 +34,12 @@ func (a *API) createContract(_ context.Context, ins struct {
 		return NewErrorResponse(ErrNullContract)
 	}
 
+	if ins.ABI != nil {
+		if err := ins.ABI.Validate(); err != nil {
+			return NewErrorResponse(err)
+		}
+	}
+
 	var hash [32]byte
 	sha3pool.Sum256(hash[:], ins.Contract)
 
@@ -52,6 +59,7 @@ func (a *API) createContract(_ context.Context, ins struct {
 		Contract:        ins.Contract,
 		CallProgram:     callProgram,
 		RegisterProgram: registerProgram,
+		ABI:             ins.ABI,
 	}
 	if err := a.wallet.ContractReg.SaveContract(c); err != nil {
 		return NewErrorResponse(err)
@@ -108,12 +116,25 @@ func (a *API) listContracts(_ context.Context) Response {
 }
 
 type ContractInstance struct {
+	TraceID     string               `json:"trace_id"`
 	UTXOs       []*contract.UTXO     `json:"utxos"`
 	TxHash      *bc.Hash             `json:"tx_hash"`
 	Status      contract.Status      `json:"status"`
+	EndedHeight uint64               `json:"ended_height"`
 	Unconfirmed []*contract.TreeNode `json:"unconfirmed"`
 }
 
+func newContractInstance(instance *contract.Instance) *ContractInstance {
+	return &ContractInstance{
+		TraceID:     instance.TraceID,
+		UTXOs:       instance.UTXOs,
+		TxHash:      instance.TxHash,
+		Status:      instance.Status,
+		EndedHeight: instance.EndedHeight,
+		Unconfirmed: instance.Unconfirmed,
+	}
+}
+
 func (a *API) getContractInstance(_ context.Context, ins struct {
 	TraceID string `json:"trace_id"`
 }) Response {
@@ -122,12 +143,23 @@ func (a *API) getContractInstance(_ context.Context, ins struct {
 		return NewErrorResponse(err)
 	}
 
-	return NewSuccessResponse(&ContractInstance{
-		UTXOs:       instance.UTXOs,
-		TxHash:      instance.TxHash,
-		Status:      instance.Status,
-		Unconfirmed: instance.Unconfirmed,
-	})
+	return NewSuccessResponse(newContractInstance(instance))
+}
+
+// POST /list-contract-instances
+// lists the traced instances filtered by the status, the contract hash and
+// the range of the ended height
+func (a *API) listContractInstances(_ context.Context, filter contract.InstanceFilter) Response {
+	instances, err := a.contractTracer.ListInstances(&filter)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	result := []*ContractInstance{}
+	for _, instance := range instances {
+		result = append(result, newContractInstance(instance))
+	}
+	return NewSuccessResponse(result)
 }
 
 func (a *API) createContractInstance(_ context.Context, ins struct {
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+
+	"kuskcore/blockchain/txbuilder"
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm"
+)
+
+// ErrBadInputIndex means the input index is out of the range of the transaction inputs
+var ErrBadInputIndex = errors.New("input index out of range")
+
+// TraceStep is an instruction executed by the VM
+type TraceStep struct {
+	Depth     int                  `json:"depth"`
+	PC        uint32               `json:"pc"`
+	Op        string               `json:"op"`
+	Data      chainjson.HexBytes   `json:"data,omitempty"`
+	DataStack []chainjson.HexBytes `json:"data_stack"`
+	AltStack  []chainjson.HexBytes `json:"alt_stack"`
+	RunLimit  int64                `json:"run_limit"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// DebugTxResp is the response of debug transaction
+type DebugTxResp struct {
+	TxID       bc.Hash      `json:"tx_id"`
+	InputIndex uint64       `json:"input_index"`
+	Valid      bool         `json:"valid"`
+	Error      string       `json:"error,omitempty"`
+	Steps      []*TraceStep `json:"steps"`
+}
+
+func newTraceStep(step *vm.TraceStep) *TraceStep {
+	toHexBytes := func(stack [][]byte) []chainjson.HexBytes {
+		result := []chainjson.HexBytes{}
+		for _, item := range stack {
+			result = append(result, item)
+		}
+		return result
+	}
+
+	result := &TraceStep{
+		Depth:     step.Depth,
+		PC:        step.PC,
+		Op:        step.Op.String(),
+		Data:      step.Data,
+		DataStack: toHexBytes(step.DataStack),
+		AltStack:  toHexBytes(step.AltStack),
+		RunLimit:  step.RunLimit,
+	}
+	if step.Err != nil {
+		result.Error = step.Err.Error()
+	}
+	return result
+}
+
+// POST /debug-transaction
+// replays the transaction against the best block, returns the instructions
+// executed for the program of the specified input
+func (a *API) debugTransaction(ctx context.Context, ins struct {
+	Tx         *types.Tx           `json:"raw_transaction"`
+	TxTemplate *txbuilder.Template `json:"transaction_template"`
+	InputIndex uint64              `json:"input_index"`
+}) Response {
+	tx := ins.Tx
+	if tx == nil && ins.TxTemplate != nil {
+		tx = ins.TxTemplate.Transaction
+	}
+
+	if tx == nil {
+		return NewErrorResponse(errors.WithDetail(txbuilder.ErrMissingFields, "raw_transaction or transaction_template is required"))
+	}
+
+	if ins.InputIndex >= uint64(len(tx.Inputs)) {
+		return NewErrorResponse(errors.WithDetailf(ErrBadInputIndex, "transaction has %d inputs", len(tx.Inputs)))
+	}
+
+	resp := &DebugTxResp{TxID: tx.ID, InputIndex: ins.InputIndex, Steps: []*TraceStep{}}
+	inputID := tx.Tx.InputIDs[ins.InputIndex]
+	_, err := a.chain.TraceTx(tx, func(entryID bc.Hash, step *vm.TraceStep) {
+		if entryID == inputID {
+			resp.Steps = append(resp.Steps, newTraceStep(step))
+		}
+	})
+
+	resp.Valid = err == nil
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return NewSuccessResponse(resp)
+}
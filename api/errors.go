@@ -10,11 +10,16 @@ import (
 	"kuskcore/blockchain/signers"
 	"kuskcore/blockchain/txbuilder"
 	"kuskcore/contract"
+	"kuskcore/equity/compiler"
 	"kuskcore/errors"
 	"kuskcore/net/http/httperror"
 	"kuskcore/net/http/httpjson"
+	"kuskcore/order"
+	"kuskcore/protocol"
 	"kuskcore/protocol/validation"
 	"kuskcore/protocol/vm"
+	"kuskcore/protocol/vm/vmutil"
+	"kuskcore/wallet"
 )
 
 var (
@@ -54,6 +59,13 @@ var respErrFormatter = map[error]httperror.Info{
 	// Contract error namespace (3xx)
 	contract.ErrContractDuplicated: {400, "KUSK302", "Contract is duplicated"},
 	contract.ErrContractNotFound:   {400, "KUSK303", "Contract not found"},
+	compiler.ErrSyntax:             {400, "KUSK304", "Contract syntax error"},
+	compiler.ErrType:               {400, "KUSK305", "Contract type error"},
+	compiler.ErrArgs:               {400, "KUSK306", "Invalid contract arguments"},
+	contract.ErrBadABI:             {400, "KUSK307", "Invalid contract abi"},
+	contract.ErrMissingABI:         {400, "KUSK308", "Contract has no abi"},
+	contract.ErrClauseNotFound:     {400, "KUSK309", "Contract clause not found"},
+	contract.ErrBadABIArgs:         {400, "KUSK310", "Invalid contract call arguments"},
 
 	// Transaction error namespace (7xx)
 	// Build transaction error namespace (70x ~ 72x)
@@ -72,6 +84,20 @@ var respErrFormatter = map[error]httperror.Info{
 	txbuilder.ErrOrphanTx:           {400, "KUSK712", "Transaction input UTXO not found"},
 	txbuilder.ErrExtTxFee:           {400, "KUSK713", "Transaction fee exceeded max limit"},
 	txbuilder.ErrNoGasInput:         {400, "KUSK714", "Transaction has no gas input"},
+	ErrBadInputIndex:                {400, "KUSK715", "Input index out of range"},
+	account.ErrHTLCPreimage:         {400, "KUSK716", "Preimage mismatches the htlc secret hash"},
+	account.ErrHTLCLocked:           {400, "KUSK717", "HTLC can't be refunded before the lock height"},
+	vmutil.ErrHTLCFormat:            {400, "KUSK718", "Output is not a hash time-locked contract"},
+	vmutil.ErrOrderFormat:           {400, "KUSK719", "Invalid limit order parameters"},
+	order.ErrOrderNotFound:          {400, "KUSK720", "Limit order not found"},
+	order.ErrBadFillAmount:          {400, "KUSK721", "Invalid limit order fill amount"},
+	order.ErrOrdersNotCross:         {400, "KUSK722", "Limit orders don't cross"},
+	vmutil.ErrChannelFormat:         {400, "KUSK723", "Output is not a payment channel"},
+	wallet.ErrChannelNotFound:       {400, "KUSK724", "Payment channel not found"},
+	wallet.ErrChannelRole:           {400, "KUSK725", "Payment channel operation isn't allowed for the local role"},
+	wallet.ErrChannelStatus:         {400, "KUSK726", "Payment channel isn't open"},
+	wallet.ErrChannelUpdate:         {400, "KUSK727", "Invalid payment channel update"},
+	wallet.ErrChannelTimeout:        {400, "KUSK728", "Payment channel can't be refunded before the timeout"},
 
 	// Submit transaction error namespace (73x ~ 79x)
 	// Validation error (73x ~ 75x)
@@ -94,6 +120,8 @@ var respErrFormatter = map[error]httperror.Info{
 	validation.ErrUnbalanced:                {400, "KUSK746", "Unbalanced asset amount between input and output"},
 	validation.ErrOverGasCredit:             {400, "KUSK747", "Gas credit has been spent"},
 	validation.ErrGasCalculate:              {400, "KUSK748", "Gas usage calculate got a math error"},
+	protocol.ErrBadTx:                       {400, "KUSK749", "Invalid transaction"},
+	protocol.ErrMissingUtxo:                 {400, "KUSK750", "Spent output is not found or has been spent"},
 
 	// VM error (76x ~ 78x)
 	vm.ErrAltStackUnderflow:  {400, "KUSK760", "Alt stack underflow"},
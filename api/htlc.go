@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+
+	chainjson "kuskcore/encoding/json"
+)
+
+// POST /create-htlc-secret
+// generates a random preimage saved by the wallet, the secret hash is used to
+// lock the htlc and the preimage is listed with the pending htlcs
+func (a *API) createHTLCSecret(ctx context.Context) Response {
+	preimage, secretHash, err := a.wallet.NewHTLCSecret()
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return NewSuccessResponse(struct {
+		Preimage   chainjson.HexBytes `json:"preimage"`
+		SecretHash chainjson.HexBytes `json:"secret_hash"`
+	}{Preimage: preimage, SecretHash: secretHash})
+}
+
+// POST /list-htlcs
+// lists the pending hash time-locked contract outputs of the local keys
+func (a *API) listHTLCs(ctx context.Context, filter struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) Response {
+	accountID := filter.AccountID
+	if filter.AccountAlias != "" {
+		acc, err := a.wallet.AccountMgr.FindByAlias(filter.AccountAlias)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+		accountID = acc.ID
+	}
+
+	return NewSuccessResponse(a.wallet.ListHTLCs(accountID))
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 
 	"kuskcore/config"
+	"kuskcore/encoding/json"
 	"kuskcore/errors"
 	"kuskcore/netsync/peers"
 	"kuskcore/p2p"
@@ -104,6 +105,64 @@ func (a *API) listPeers() Response {
 	return NewSuccessResponse(a.sync.GetPeerInfos())
 }
 
+// list the addresses of the address book
+func (a *API) listKnownAddresses() Response {
+	return NewSuccessResponse(a.sync.ListKnownAddresses())
+}
+
+// ban the peer ip or node pubkey for the duration, the default duration is
+// one hour
+func (a *API) banPeer(ctx context.Context, ins struct {
+	Target   string        `json:"target"`
+	Duration json.Duration `json:"duration"`
+	Reason   string        `json:"reason"`
+}) Response {
+	if err := a.sync.BanPeer(ins.Target, ins.Duration.Duration, ins.Reason); err != nil {
+		return NewErrorResponse(err)
+	}
+	return NewSuccessResponse(nil)
+}
+
+// lift the ban of the peer ip or node pubkey
+func (a *API) unbanPeer(ctx context.Context, ins struct {
+	Target string `json:"target"`
+}) Response {
+	if err := a.sync.UnbanPeer(ins.Target); err != nil {
+		return NewErrorResponse(err)
+	}
+	return NewSuccessResponse(nil)
+}
+
+// list the current bans with the reasons
+func (a *API) listBannedPeers() Response {
+	return NewSuccessResponse(a.sync.ListBannedPeers())
+}
+
+// exempt the peer ip or node pubkey from the ban scoring and the connection limits
+func (a *API) addWhitelistPeer(ctx context.Context, ins struct {
+	Target string `json:"target"`
+}) Response {
+	if err := a.sync.AddWhitelistPeer(ins.Target); err != nil {
+		return NewErrorResponse(err)
+	}
+	return NewSuccessResponse(nil)
+}
+
+// remove the peer ip or node pubkey from the whitelist
+func (a *API) removeWhitelistPeer(ctx context.Context, ins struct {
+	Target string `json:"target"`
+}) Response {
+	if err := a.sync.RemoveWhitelistPeer(ins.Target); err != nil {
+		return NewErrorResponse(err)
+	}
+	return NewSuccessResponse(nil)
+}
+
+// list the whitelisted peer ips and node pubkeys
+func (a *API) listWhitelistPeers() Response {
+	return NewSuccessResponse(a.sync.ListWhitelistPeers())
+}
+
 // disconnect peer
 func (a *API) disconnectPeer(ctx context.Context, ins struct {
 	PeerID string `json:"peer_id"`
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+
+	"kuskcore/blockchain/txbuilder"
+	"kuskcore/consensus"
+	"kuskcore/encoding/json"
+	"kuskcore/order"
+	"kuskcore/protocol/bc"
+)
+
+// POST /list-orders
+// lists the unspent limit orders selling the asset for the requested asset,
+// the cheapest order is the first
+func (a *API) listOrders(ctx context.Context, filter struct {
+	AssetID          bc.AssetID `json:"asset_id"`
+	RequestedAssetID bc.AssetID `json:"requested_asset_id"`
+}) Response {
+	orders, err := a.orderBook.ListOrders(&filter.AssetID, &filter.RequestedAssetID)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return NewSuccessResponse(orders)
+}
+
+// POST /get-order
+func (a *API) getOrder(ctx context.Context, ins struct {
+	OutputID bc.Hash `json:"output_id"`
+}) Response {
+	order, err := a.orderBook.GetOrder(&ins.OutputID)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return NewSuccessResponse(order)
+}
+
+// POST /match-orders
+// matches the best orders of both sides of the trading pair and composes the
+// fill transaction, the spreads are received by the account which pays the fee
+func (a *API) matchOrders(ctx context.Context, ins struct {
+	AssetID          bc.AssetID    `json:"asset_id"`
+	RequestedAssetID bc.AssetID    `json:"requested_asset_id"`
+	AccountID        string        `json:"account_id"`
+	AccountAlias     string        `json:"account_alias"`
+	Fee              uint64        `json:"fee"`
+	TTL              json.Duration `json:"ttl"`
+}) Response {
+	match, err := a.orderBook.BestMatch(&ins.AssetID, &ins.RequestedAssetID)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	accountID := ins.AccountID
+	if ins.AccountAlias != "" {
+		acc, err := a.wallet.AccountMgr.FindByAlias(ins.AccountAlias)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+		accountID = acc.ID
+	}
+
+	cp, err := a.wallet.AccountMgr.CreateAddress(accountID, true)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	req := &BuildRequest{TTL: ins.TTL}
+	for i, o := range match.Orders {
+		req.Actions = append(req.Actions, map[string]interface{}{
+			"type":      "fill_order",
+			"output_id": o.OutputID.String(),
+			"amount":    match.FillAmounts[i],
+		})
+	}
+
+	for i, spread := range match.Spreads {
+		if spread == 0 {
+			continue
+		}
+
+		req.Actions = append(req.Actions, map[string]interface{}{
+			"type":            "control_program",
+			"asset_id":        match.Orders[i].AssetID.String(),
+			"amount":          spread,
+			"control_program": json.HexBytes(cp.ControlProgram),
+		})
+	}
+
+	if ins.Fee > 0 {
+		req.Actions = append(req.Actions, map[string]interface{}{
+			"type":       "spend_account",
+			"account_id": accountID,
+			"asset_id":   consensus.KUSKAssetID.String(),
+			"amount":     ins.Fee,
+		})
+	}
+
+	tmpl, err := a.buildSingle(ctx, req)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	return NewSuccessResponse(struct {
+		Match    *order.Match        `json:"match"`
+		Template *txbuilder.Template `json:"template"`
+	}{Match: match, Template: tmpl})
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"kuskcore/consensus/segwit"
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/equity/compiler"
 	"kuskcore/protocol/vm"
 )
 
@@ -44,3 +46,34 @@ func (a *API) decodeProgram(ctx context.Context, ins struct {
 	}
 	return NewSuccessResponse(DecodeProgResp{Instructions: result})
 }
+
+// CompileResp is response for compile contract
+type CompileResp struct {
+	*compiler.Contract
+	StateData []chainjson.HexBytes `json:"state_data,omitempty"`
+}
+
+// compile compiles the contract source, the state data of the contract output
+// is returned too when the contract arguments are given
+func (a *API) compile(ctx context.Context, ins struct {
+	Contract string                 `json:"contract"`
+	Args     []compiler.ContractArg `json:"args"`
+}) Response {
+	contract, err := compiler.Compile(ins.Contract)
+	if err != nil {
+		return NewErrorResponse(err)
+	}
+
+	resp := &CompileResp{Contract: contract}
+	if ins.Args != nil {
+		stateData, err := contract.StateData(ins.Args)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+
+		for _, data := range stateData {
+			resp.StateData = append(resp.StateData, data)
+		}
+	}
+	return NewSuccessResponse(resp)
+}
@@ -315,10 +315,23 @@ func (a *API) listUnspentOutputs(ctx context.Context, filter struct {
 
 // return gasRate
 func (a *API) gasRate() Response {
-	gasrate := map[string]int64{"gas_rate": consensus.VMGasRate}
+	forkParams := consensus.ActiveNetParams.ForkParams(a.nextBlockHeight())
+	gasrate := map[string]int64{"gas_rate": forkParams.VMGasRate}
 	return NewSuccessResponse(gasrate)
 }
 
+// nextBlockHeight return the height of the next block, the light node has no
+// core chain and follows the wallet
+func (a *API) nextBlockHeight() uint64 {
+	switch {
+	case a.chain != nil:
+		return a.chain.BestBlockHeight() + 1
+	case a.wallet != nil:
+		return a.wallet.BestBlockHeight() + 1
+	}
+	return 0
+}
+
 // PubKeyInfo is structure of pubkey info
 type PubKeyInfo struct {
 	Pubkey string               `json:"pubkey"`
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+
+	"kuskcore/blockchain/txbuilder"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+// SimulateInput is the execution result of an input
+type SimulateInput struct {
+	InputIndex uint64  `json:"input_index"`
+	InputID    bc.Hash `json:"input_id"`
+	GasUsed    int64   `json:"gas_used"`
+}
+
+// SimulateTxResp is the response of simulate transaction
+type SimulateTxResp struct {
+	TxID             bc.Hash          `json:"tx_id"`
+	Valid            bool             `json:"valid"`
+	Error            string           `json:"error,omitempty"`
+	ErrorDetail      string           `json:"error_detail,omitempty"`
+	FailedInputIndex *uint64          `json:"failed_input_index,omitempty"`
+	Fee              uint64           `json:"fee"`
+	GasUsed          int64            `json:"gas_used"`
+	StorageGas       int64            `json:"storage_gas"`
+	Inputs           []*SimulateInput `json:"inputs"`
+	OutputIDs        []bc.Hash        `json:"output_ids"`
+}
+
+// POST /simulate-transaction
+// validates the transaction against the best block and the utxo set without
+// submitting it, the unconfirmed parents are validated before the transaction
+func (a *API) simulateTransaction(ctx context.Context, ins struct {
+	Tx         *types.Tx           `json:"raw_transaction"`
+	TxTemplate *txbuilder.Template `json:"transaction_template"`
+	Parents    []*types.Tx         `json:"parents"`
+}) Response {
+	tx := ins.Tx
+	if tx == nil && ins.TxTemplate != nil {
+		tx = ins.TxTemplate.Transaction
+	}
+
+	if tx == nil {
+		return NewErrorResponse(errors.WithDetail(txbuilder.ErrMissingFields, "raw_transaction or transaction_template is required"))
+	}
+
+	gasState, simulation, err := a.chain.SimulateTx(tx, ins.Parents)
+	if simulation == nil {
+		return NewErrorResponse(err)
+	}
+
+	resp := &SimulateTxResp{TxID: tx.ID, Valid: err == nil, Inputs: []*SimulateInput{}, OutputIDs: []bc.Hash{}}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.ErrorDetail = errors.Detail(err)
+	}
+
+	if gasState != nil {
+		resp.Fee = gasState.KUSKValue
+		resp.GasUsed = gasState.GasUsed
+		resp.StorageGas = gasState.StorageGas
+	}
+
+	for i, inputID := range tx.Tx.InputIDs {
+		index := uint64(i)
+		resp.Inputs = append(resp.Inputs, &SimulateInput{InputIndex: index, InputID: inputID, GasUsed: simulation.GasUsed[inputID]})
+		if simulation.FailedEntry != nil && *simulation.FailedEntry == inputID {
+			resp.FailedInputIndex = &index
+		}
+	}
+
+	for i := range tx.Outputs {
+		resp.OutputIDs = append(resp.OutputIDs, *tx.OutputID(i))
+	}
+	return NewSuccessResponse(resp)
+}
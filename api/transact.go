@@ -10,8 +10,10 @@ import (
 
 	"kuskcore/account"
 	"kuskcore/blockchain/txbuilder"
+	"kuskcore/consensus"
 	"kuskcore/errors"
 	"kuskcore/net/http/reqid"
+	"kuskcore/protocol"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 )
@@ -32,6 +34,19 @@ func (a *API) actionDecoder(action string) (func([]byte) (txbuilder.Action, erro
 		"spend_account":                a.wallet.AccountMgr.DecodeSpendAction,
 		"spend_account_unspent_output": a.wallet.AccountMgr.DecodeSpendUTXOAction,
 		"veto":                         a.wallet.AccountMgr.DecodeVetoAction,
+		"call_contract":                a.wallet.ContractReg.DecodeCallContractAction(a.wallet.AccountMgr.DecodeSpendUTXOAction),
+		"lock_htlc":                    txbuilder.DecodeLockHTLCAction,
+		"claim_htlc":                   a.wallet.AccountMgr.DecodeClaimHTLCAction,
+		"refund_htlc":                  a.wallet.AccountMgr.DecodeRefundHTLCAction,
+		"place_order":                  txbuilder.DecodePlaceOrderAction,
+		"fund_channel":                 txbuilder.DecodeFundChannelAction,
+		"close_channel":                a.wallet.DecodeCloseChannelAction,
+		"refund_channel":               a.wallet.DecodeRefundChannelAction,
+	}
+	// the order book is kept by the full node only
+	if a.orderBook != nil {
+		decoders["fill_order"] = a.orderBook.DecodeFillOrderAction
+		decoders["cancel_order"] = a.orderBook.DecodeCancelOrderAction(a.wallet.AccountMgr)
 	}
 	decoder, ok := decoders[action]
 	return decoder, ok
@@ -45,7 +60,7 @@ func onlyHaveInputActions(req *BuildRequest) (bool, error) {
 			return false, errors.WithDetailf(ErrBadActionType, "no action type provided on action %d", i)
 		}
 
-		if strings.HasPrefix(actionType, "spend") || actionType == "issue" || actionType == "veto" {
+		if strings.HasPrefix(actionType, "spend") || actionType == "issue" || actionType == "veto" || actionType == "call_contract" || actionType == "claim_htlc" || actionType == "refund_htlc" || actionType == "fill_order" || actionType == "cancel_order" {
 			count++
 		}
 	}
@@ -221,11 +236,52 @@ func (a *API) submitTxs(ctx context.Context, ins struct {
 	return NewSuccessResponse(&submitTxsResp{TxID: txHashs})
 }
 
+// POST /submit-transaction of the light node
+func (a *API) relay(ctx context.Context, ins struct {
+	Tx types.Tx `json:"raw_transaction"`
+}) Response {
+	if err := a.relayTx(ctx, &ins.Tx); err != nil {
+		return NewErrorResponse(err)
+	}
+
+	log.WithField("tx_id", ins.Tx.ID.String()).Info("relay single tx")
+	return NewSuccessResponse(&submitTxResp{TxID: &ins.Tx.ID})
+}
+
+// POST /submit-transactions of the light node
+func (a *API) relayTxs(ctx context.Context, ins struct {
+	Tx []types.Tx `json:"raw_transactions"`
+}) Response {
+	txHashs := []*bc.Hash{}
+	for i := range ins.Tx {
+		if err := a.relayTx(ctx, &ins.Tx[i]); err != nil {
+			return NewErrorResponse(err)
+		}
+		log.WithField("tx_id", ins.Tx[i].ID.String()).Info("relay single tx")
+		txHashs = append(txHashs, &ins.Tx[i].ID)
+	}
+	return NewSuccessResponse(&submitTxsResp{TxID: txHashs})
+}
+
+// relayTx relays the transaction to the full nodes, the wallet is notified
+// like the transaction is added to the mempool
+func (a *API) relayTx(ctx context.Context, tx *types.Tx) error {
+	if err := txbuilder.RelayTx(ctx, a.sync.RelayTx, tx); err != nil {
+		return err
+	}
+
+	txD := &protocol.TxDesc{Tx: tx, Added: time.Now(), Height: a.wallet.BestBlockHeight()}
+	if err := a.eventDispatcher.Post(protocol.TxMsgEvent{TxMsg: &protocol.TxPoolMsg{TxDesc: txD, MsgType: protocol.MsgNewTx}}); err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Error("fail on post the relayed tx")
+	}
+	return nil
+}
+
 // POST /estimate-transaction-gas
 func (a *API) estimateTxGas(ctx context.Context, in struct {
 	TxTemplate txbuilder.Template `json:"transaction_template"`
 }) Response {
-	txGasResp, err := txbuilder.EstimateTxGas(in.TxTemplate)
+	txGasResp, err := txbuilder.EstimateTxGas(in.TxTemplate, consensus.ActiveNetParams.ForkParams(a.nextBlockHeight()), a.chain)
 	if err != nil {
 		return NewErrorResponse(err)
 	}
@@ -237,7 +293,7 @@ func (a *API) estimateTxGas(ctx context.Context, in struct {
 func (a *API) estimateChainTxGas(ctx context.Context, in struct {
 	TxTemplates []txbuilder.Template `json:"transaction_templates"`
 }) Response {
-	txGasResp, err := txbuilder.EstimateChainTxGas(in.TxTemplates)
+	txGasResp, err := txbuilder.EstimateChainTxGas(in.TxTemplates, consensus.ActiveNetParams.ForkParams(a.nextBlockHeight()), a.chain)
 	if err != nil {
 		return NewErrorResponse(err)
 	}
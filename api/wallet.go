@@ -89,7 +89,7 @@ type WalletInfo struct {
 }
 
 func (a *API) getWalletInfo() Response {
-	bestBlockHeight := a.chain.BestBlockHeight()
+	bestBlockHeight := a.wallet.BestBlockHeight()
 	walletStatus := a.wallet.GetWalletStatusInfo()
 
 	return NewSuccessResponse(&WalletInfo{
@@ -0,0 +1,47 @@
+package gcs
+
+import (
+	"kuskcore/crypto/sha3pool"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+// BlockFilterKey returns the key of the block filter, it's the prefix of the
+// block hash
+func BlockFilterKey(hash *bc.Hash) [KeySize]byte {
+	var key [KeySize]byte
+	copy(key[:], hash.Bytes())
+	return key
+}
+
+// BuildBlockFilter builds the filter of the output control programs and the
+// spent output programs of the block
+func BuildBlockFilter(block *types.Block) (*Filter, error) {
+	items := [][]byte{}
+	for _, tx := range block.Transactions {
+		for _, input := range tx.Inputs {
+			switch inp := input.TypedInput.(type) {
+			case *types.SpendInput:
+				items = append(items, inp.ControlProgram)
+			case *types.VetoInput:
+				items = append(items, inp.ControlProgram)
+			}
+		}
+
+		for _, output := range tx.Outputs {
+			items = append(items, output.ControlProgram)
+		}
+	}
+
+	blockHash := block.Hash()
+	return NewFilter(BlockFilterKey(&blockHash), items)
+}
+
+// FilterHeader chains the filter to the header of the previous block filter,
+// the previous header of the genesis block is the zero hash
+func FilterHeader(filter *Filter, prevHeader *bc.Hash) bc.Hash {
+	filterHash := filter.Hash()
+	var header [32]byte
+	sha3pool.Sum256(header[:], append(filterHash[:], prevHeader.Bytes()...))
+	return bc.NewHash(header)
+}
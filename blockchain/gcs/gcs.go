@@ -0,0 +1,221 @@
+// Package gcs implements the Golomb-coded sets used as the compact block
+// filters, the items are mapped to the range [0, N*M) by a keyed hash, sorted
+// and the deltas are encoded with the Golomb-Rice coding.
+package gcs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"sort"
+
+	"kuskcore/crypto/sha3pool"
+	"kuskcore/errors"
+)
+
+const (
+	// P is the bit length of the remainder of the Golomb-Rice coding
+	P = 19
+	// M is the inverse of the false positive rate of the filter
+	M = 784931
+
+	// KeySize is the size of the key of the item hash
+	KeySize = 16
+)
+
+var (
+	errTooManyItems  = errors.New("too many items of the filter")
+	errInvalidFilter = errors.New("invalid filter data")
+)
+
+// Filter is the Golomb-coded set of the items
+type Filter struct {
+	n    uint32
+	data []byte
+}
+
+// NewFilter builds the filter of the items with the key, the duplicated and
+// empty items are ignored.
+func NewFilter(key [KeySize]byte, items [][]byte) (*Filter, error) {
+	uniq := make(map[string]bool, len(items))
+	for _, item := range items {
+		if len(item) != 0 {
+			uniq[string(item)] = true
+		}
+	}
+
+	if uint64(len(uniq)) > uint64(^uint32(0)) {
+		return nil, errTooManyItems
+	}
+
+	n := uint32(len(uniq))
+	values := make([]uint64, 0, n)
+	for item := range uniq {
+		values = append(values, hashToRange(key, []byte(item), n))
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	w := &bitWriter{}
+	last := uint64(0)
+	for _, value := range values {
+		w.writeGolombRice(value - last)
+		last = value
+	}
+	return &Filter{n: n, data: w.bytes()}, nil
+}
+
+// FromBytes decodes the filter serialized by Bytes
+func FromBytes(b []byte) (*Filter, error) {
+	n, size := binary.Uvarint(b)
+	if size <= 0 || n > uint64(^uint32(0)) {
+		return nil, errInvalidFilter
+	}
+
+	data := append([]byte{}, b[size:]...)
+	if n == 0 && len(data) != 0 {
+		return nil, errInvalidFilter
+	}
+	return &Filter{n: uint32(n), data: data}, nil
+}
+
+// N returns the number of the items of the filter
+func (f *Filter) N() uint32 {
+	return f.n
+}
+
+// Bytes returns the serialized filter prefixed with the number of the items
+func (f *Filter) Bytes() []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	size := binary.PutUvarint(buf, uint64(f.n))
+	return append(buf[:size], f.data...)
+}
+
+// Hash returns the SHA3-256 hash of the serialized filter
+func (f *Filter) Hash() [32]byte {
+	var hash [32]byte
+	sha3pool.Sum256(hash[:], f.Bytes())
+	return hash
+}
+
+// Match checks whether the item is probably in the filter
+func (f *Filter) Match(key [KeySize]byte, item []byte) (bool, error) {
+	return f.MatchAny(key, [][]byte{item})
+}
+
+// MatchAny checks whether any of the items is probably in the filter
+func (f *Filter) MatchAny(key [KeySize]byte, items [][]byte) (bool, error) {
+	if f.n == 0 {
+		return false, nil
+	}
+
+	values := make([]uint64, 0, len(items))
+	for _, item := range items {
+		if len(item) != 0 {
+			values = append(values, hashToRange(key, item, f.n))
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	r := &bitReader{data: f.data}
+	value := uint64(0)
+	for i, j := uint32(0), 0; i < f.n && j < len(values); i++ {
+		delta, err := r.readGolombRice()
+		if err != nil {
+			return false, err
+		}
+
+		value += delta
+		for j < len(values) && values[j] < value {
+			j++
+		}
+
+		if j < len(values) && values[j] == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hashToRange maps the item to [0, n*M) by the high bits of the product of
+// the item hash and the range
+func hashToRange(key [KeySize]byte, item []byte, n uint32) uint64 {
+	var hash [32]byte
+	sha3pool.Sum256(hash[:], bytes.Join([][]byte{key[:], item}, nil))
+	value, _ := bits.Mul64(binary.BigEndian.Uint64(hash[:8]), uint64(n)*M)
+	return value
+}
+
+type bitWriter struct {
+	data  []byte
+	nbits uint8
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.nbits == 0 {
+		w.data = append(w.data, 0)
+		w.nbits = 8
+	}
+
+	w.nbits--
+	if bit {
+		w.data[len(w.data)-1] |= 1 << w.nbits
+	}
+}
+
+func (w *bitWriter) writeGolombRice(value uint64) {
+	for q := value >> P; q > 0; q-- {
+		w.writeBit(true)
+	}
+	w.writeBit(false)
+
+	for i := P - 1; i >= 0; i-- {
+		w.writeBit(value&(1<<uint(i)) != 0)
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.data
+}
+
+type bitReader struct {
+	data []byte
+	pos  uint64
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.pos >= uint64(len(r.data))*8 {
+		return false, errInvalidFilter
+	}
+
+	bit := r.data[r.pos/8]&(0x80>>(r.pos%8)) != 0
+	r.pos++
+	return bit, nil
+}
+
+func (r *bitReader) readGolombRice() (uint64, error) {
+	q := uint64(0)
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+
+		if !bit {
+			break
+		}
+		q++
+	}
+
+	value := q << P
+	for i := P - 1; i >= 0; i-- {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+
+		if bit {
+			value |= 1 << uint(i)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,138 @@
+package gcs
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+func TestFilterMatch(t *testing.T) {
+	key := [KeySize]byte{1, 2, 3}
+	items := [][]byte{}
+	for i := 0; i < 200; i++ {
+		items = append(items, []byte(fmt.Sprintf("program-%d", i)))
+	}
+
+	filter, err := NewFilter(key, append(items, items[0], nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filter.N() != uint32(len(items)) {
+		t.Fatalf("got %d items of the filter, want %d", filter.N(), len(items))
+	}
+
+	decoded, err := FromBytes(filter.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded.Bytes(), filter.Bytes()) || decoded.Hash() != filter.Hash() {
+		t.Fatal("the decoded filter mismatch the encoded one")
+	}
+
+	for i, item := range items {
+		if match, err := decoded.Match(key, item); err != nil || !match {
+			t.Fatalf("item %d: got match %v, error %v", i, match, err)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		match, err := decoded.Match(key, []byte(fmt.Sprintf("unknown-%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if match {
+			falsePositives++
+		}
+	}
+
+	if falsePositives > 1 {
+		t.Fatalf("got %d false positives of 1000 items", falsePositives)
+	}
+
+	if match, err := decoded.MatchAny(key, [][]byte{[]byte("unknown"), items[100]}); err != nil || !match {
+		t.Fatalf("got match any %v, error %v", match, err)
+	}
+
+	// the other key maps the items to other values
+	if match, err := decoded.MatchAny([KeySize]byte{3, 2, 1}, items[:10]); err != nil || match {
+		t.Fatalf("got match %v with the other key, error %v", match, err)
+	}
+}
+
+func TestEmptyFilter(t *testing.T) {
+	filter, err := NewFilter([KeySize]byte{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(filter.Bytes(), []byte{0}) {
+		t.Fatalf("got empty filter %x", filter.Bytes())
+	}
+
+	if match, err := filter.Match([KeySize]byte{}, []byte{1}); err != nil || match {
+		t.Fatalf("got match %v, error %v", match, err)
+	}
+
+	for _, data := range [][]byte{nil, {0, 1}, {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}} {
+		if _, err := FromBytes(data); err != errInvalidFilter {
+			t.Errorf("data %x: got error %v, want %v", data, err, errInvalidFilter)
+		}
+	}
+
+	truncated, err := FromBytes([]byte{10, 0xff})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := truncated.Match([KeySize]byte{}, []byte{1}); err != errInvalidFilter {
+		t.Fatalf("got error %v, want %v", err, errInvalidFilter)
+	}
+}
+
+func TestBuildBlockFilter(t *testing.T) {
+	spent, output := []byte{0x00, 0x14, 1}, []byte{0x00, 0x14, 2}
+	block := &types.Block{
+		BlockHeader: types.BlockHeader{Version: 1, Height: 1},
+		Transactions: []*types.Tx{
+			types.NewTx(types.TxData{
+				Version: 1,
+				Inputs:  []*types.TxInput{types.NewSpendInput(nil, bc.Hash{V0: 1}, bc.AssetID{V0: 1}, 100, 0, spent, nil)},
+				Outputs: []*types.TxOutput{types.NewOriginalTxOutput(bc.AssetID{V0: 1}, 100, output, nil)},
+			}),
+		},
+	}
+
+	filter, err := BuildBlockFilter(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockHash := block.Hash()
+	key := BlockFilterKey(&blockHash)
+	for _, program := range [][]byte{spent, output} {
+		if match, err := filter.Match(key, program); err != nil || !match {
+			t.Fatalf("program %x: got match %v, error %v", program, match, err)
+		}
+	}
+
+	rebuilt, err := BuildBlockFilter(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rebuilt.Bytes(), filter.Bytes()) {
+		t.Fatal("the block filter is not deterministic")
+	}
+
+	prevHeader := &bc.Hash{V0: 1}
+	if FilterHeader(filter, prevHeader) == FilterHeader(filter, &bc.Hash{}) {
+		t.Fatal("the filter header is not chained to the previous header")
+	}
+}
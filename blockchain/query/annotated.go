@@ -42,6 +42,18 @@ type AnnotatedInput struct {
 	// Vote assign value only input is vote type
 	Vote      string   `json:"vote,omitempty"`
 	StateData []string `json:"state_data,omitempty"`
+
+	// Contract assign value only input spends a registered contract with abi
+	Contract *AnnotatedContractCall `json:"contract,omitempty"`
+}
+
+// AnnotatedContractCall means the decoded call of a registered contract.
+type AnnotatedContractCall struct {
+	ID        chainjson.HexBytes     `json:"id"`
+	Alias     string                 `json:"alias"`
+	Clause    string                 `json:"clause"`
+	StateData map[string]interface{} `json:"state_data"`
+	Arguments map[string]interface{} `json:"arguments"`
 }
 
 // AnnotatedOutput means an annotated transaction output.
@@ -2,6 +2,7 @@ package txbuilder
 
 import (
 	"context"
+	"crypto/ed25519"
 	stdjson "encoding/json"
 	"errors"
 
@@ -195,6 +196,11 @@ type voteOutputAction struct {
 	bc.AssetAmount
 	Address string        `json:"address"`
 	Vote    json.HexBytes `json:"vote"`
+
+	// RotateTo and RotationSignature turn the vote into a key rotation of the
+	// validator Vote, the signature is signed by Vote over types.KeyRotationMsg
+	RotateTo          json.HexBytes `json:"rotate_to"`
+	RotationSignature json.HexBytes `json:"rotation_signature"`
 }
 
 func (a *voteOutputAction) Build(ctx context.Context, b *TemplateBuilder) error {
@@ -211,6 +217,9 @@ func (a *voteOutputAction) Build(ctx context.Context, b *TemplateBuilder) error
 	if len(a.Vote) == 0 {
 		missing = append(missing, "vote")
 	}
+	if len(a.RotateTo) != 0 && len(a.RotationSignature) == 0 {
+		missing = append(missing, "rotation_signature")
+	}
 	if len(missing) > 0 {
 		return MissingFieldsError(missing...)
 	}
@@ -234,10 +243,201 @@ func (a *voteOutputAction) Build(ctx context.Context, b *TemplateBuilder) error
 		return err
 	}
 
-	out := types.NewVoteOutput(*a.AssetId, a.Amount, program, a.Vote, nil)
+	var stateData [][]byte
+	if len(a.RotateTo) != 0 {
+		stateData = types.NewKeyRotationStateData(a.RotateTo, a.RotationSignature)
+		if _, err := types.ParseKeyRotation(a.Vote, stateData); err != nil {
+			return err
+		}
+	}
+
+	out := types.NewVoteOutput(*a.AssetId, a.Amount, program, a.Vote, stateData)
 	return b.AddOutput(out)
 }
 
 func (a *voteOutputAction) ActionType() string {
 	return "vote_output"
 }
+
+// DecodeLockHTLCAction convert input data to action struct
+func DecodeLockHTLCAction(data []byte) (Action, error) {
+	a := new(lockHTLCAction)
+	return a, stdjson.Unmarshal(data, a)
+}
+
+type lockHTLCAction struct {
+	bc.AssetAmount
+	SecretHash json.HexBytes `json:"secret_hash"`
+	Recipient  json.HexBytes `json:"recipient_pubkey"`
+	Sender     json.HexBytes `json:"sender_pubkey"`
+	LockHeight uint64        `json:"lock_height"`
+}
+
+func (a *lockHTLCAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	var missing []string
+	if len(a.SecretHash) == 0 {
+		missing = append(missing, "secret_hash")
+	}
+	if len(a.Recipient) == 0 {
+		missing = append(missing, "recipient_pubkey")
+	}
+	if len(a.Sender) == 0 {
+		missing = append(missing, "sender_pubkey")
+	}
+	if a.LockHeight == 0 {
+		missing = append(missing, "lock_height")
+	}
+	if a.AssetId.IsZero() {
+		missing = append(missing, "asset_id")
+	}
+	if a.Amount == 0 {
+		missing = append(missing, "amount")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+
+	program, err := vmutil.HTLCProgram(a.SecretHash, ed25519.PublicKey(a.Recipient), ed25519.PublicKey(a.Sender), a.LockHeight)
+	if err != nil {
+		return err
+	}
+
+	out := types.NewOriginalTxOutput(*a.AssetId, a.Amount, program, nil)
+	return b.AddOutput(out)
+}
+
+func (a *lockHTLCAction) ActionType() string {
+	return "lock_htlc"
+}
+
+// DecodeFundChannelAction convert input data to action struct
+func DecodeFundChannelAction(data []byte) (Action, error) {
+	a := new(fundChannelAction)
+	return a, stdjson.Unmarshal(data, a)
+}
+
+// fundChannelAction locks the capacity of a payment channel, the programs
+// receiving the balances of the close are kept in the state data
+type fundChannelAction struct {
+	bc.AssetAmount
+	Sender          json.HexBytes `json:"sender_pubkey"`
+	Receiver        json.HexBytes `json:"receiver_pubkey"`
+	SenderProgram   json.HexBytes `json:"sender_program"`
+	ReceiverProgram json.HexBytes `json:"receiver_program"`
+	Timeout         uint64        `json:"timeout"`
+}
+
+func (a *fundChannelAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	var missing []string
+	if len(a.Sender) == 0 {
+		missing = append(missing, "sender_pubkey")
+	}
+	if len(a.Receiver) == 0 {
+		missing = append(missing, "receiver_pubkey")
+	}
+	if len(a.SenderProgram) == 0 {
+		missing = append(missing, "sender_program")
+	}
+	if len(a.ReceiverProgram) == 0 {
+		missing = append(missing, "receiver_program")
+	}
+	if a.Timeout == 0 {
+		missing = append(missing, "timeout")
+	}
+	if a.AssetId.IsZero() {
+		missing = append(missing, "asset_id")
+	}
+	if a.Amount == 0 {
+		missing = append(missing, "amount")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+
+	// the fee of the close is paid by the balance of the sender
+	if *a.AssetId != *consensus.KUSKAssetID {
+		return errors.New("payment channel should be funded by KUSK")
+	}
+
+	program, err := vmutil.PaymentChannelProgram(ed25519.PublicKey(a.Sender), ed25519.PublicKey(a.Receiver), a.Timeout)
+	if err != nil {
+		return err
+	}
+
+	out := types.NewOriginalTxOutput(*a.AssetId, a.Amount, program, [][]byte{a.SenderProgram, a.ReceiverProgram})
+	return b.AddOutput(out)
+}
+
+func (a *fundChannelAction) ActionType() string {
+	return "fund_channel"
+}
+
+// DecodePlaceOrderAction convert input data to action struct
+func DecodePlaceOrderAction(data []byte) (Action, error) {
+	a := new(placeOrderAction)
+	return a, stdjson.Unmarshal(data, a)
+}
+
+type placeOrderAction struct {
+	bc.AssetAmount
+	RequestedAssetID *bc.AssetID   `json:"requested_asset_id"`
+	RatioNumerator   uint64        `json:"ratio_numerator"`
+	RatioDenominator uint64        `json:"ratio_denominator"`
+	MakerProgram     json.HexBytes `json:"maker_program"`
+	MakerPubkey      json.HexBytes `json:"maker_pubkey"`
+}
+
+func (a *placeOrderAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	var missing []string
+	if a.RequestedAssetID == nil || a.RequestedAssetID.IsZero() {
+		missing = append(missing, "requested_asset_id")
+	}
+	if a.RatioNumerator == 0 {
+		missing = append(missing, "ratio_numerator")
+	}
+	if a.RatioDenominator == 0 {
+		missing = append(missing, "ratio_denominator")
+	}
+	if len(a.MakerProgram) == 0 {
+		missing = append(missing, "maker_program")
+	}
+	if len(a.MakerPubkey) == 0 {
+		missing = append(missing, "maker_pubkey")
+	}
+	if a.AssetId.IsZero() {
+		missing = append(missing, "asset_id")
+	}
+	if a.Amount == 0 {
+		missing = append(missing, "amount")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+
+	if *a.RequestedAssetID == *a.AssetId {
+		return errors.New("order can't request the asset it sells")
+	}
+
+	order := &vmutil.LimitOrder{
+		RequestedAsset:   a.RequestedAssetID.Bytes(),
+		RatioNumerator:   a.RatioNumerator,
+		RatioDenominator: a.RatioDenominator,
+		MakerProgram:     a.MakerProgram,
+		MakerKey:         ed25519.PublicKey(a.MakerPubkey),
+	}
+	if err := order.Validate(); err != nil {
+		return err
+	}
+
+	program, err := vmutil.LimitOrderProgram()
+	if err != nil {
+		return err
+	}
+
+	out := types.NewOriginalTxOutput(*a.AssetId, a.Amount, program, order.StateData())
+	return b.AddOutput(out)
+}
+
+func (a *placeOrderAction) ActionType() string {
+	return "place_order"
+}
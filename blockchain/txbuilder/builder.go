@@ -50,6 +50,14 @@ func (b *TemplateBuilder) Outputs() []*types.TxOutput {
 	return b.outputs
 }
 
+// NextOutputPosition return the position of the next output in the transaction
+func (b *TemplateBuilder) NextOutputPosition() uint64 {
+	if b.base == nil {
+		return uint64(len(b.outputs))
+	}
+	return uint64(len(b.base.Outputs) + len(b.outputs))
+}
+
 // InputCount return number of input in the template builder
 func (b *TemplateBuilder) InputCount() int {
 	return len(b.inputs)
@@ -74,6 +82,17 @@ func (b *TemplateBuilder) MaxTime() time.Time {
 	return b.maxTime
 }
 
+// Base return the base transaction the actions are added on, nil if the
+// transaction is built from scratch
+func (b *TemplateBuilder) Base() *types.TxData {
+	return b.base
+}
+
+// TimeRange return the time range requested for the transaction
+func (b *TemplateBuilder) TimeRange() uint64 {
+	return b.timeRange
+}
+
 // OnRollback registers a function that can be
 // used to attempt to undo any side effects of building
 // actions. For example, it might cancel any reservations
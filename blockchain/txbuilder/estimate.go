@@ -1,8 +1,14 @@
 package txbuilder
 
 import (
+	"crypto/ed25519"
+	"io/ioutil"
+
 	"kuskcore/consensus"
+	"kuskcore/consensus/bcrp"
 	"kuskcore/consensus/segwit"
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/errors"
 	"kuskcore/protocol/bc/types"
 	"kuskcore/protocol/vm/vmutil"
 )
@@ -18,8 +24,17 @@ var (
 	ChainTxUtxoNum = 5
 	//ChainTxMergeGas chain tx gas
 	ChainTxMergeGas = uint64(6000000)
+
+	// ErrNoDryRunner means the program of the input can't be executed without the chain
+	ErrNoDryRunner = errors.New("no chain to dry run the program")
 )
 
+// DryRunner executes the program of the transaction input, it returns the
+// gas consumed by the program
+type DryRunner interface {
+	DryRunInput(tx *types.Tx, index int) (int64, error)
+}
+
 // EstimateTxGasInfo estimate transaction consumed gas
 type EstimateTxGasInfo struct {
 	TotalNeu    int64 `json:"total_neu"`
@@ -27,10 +42,34 @@ type EstimateTxGasInfo struct {
 	StorageNeu  int64 `json:"storage_neu"`
 	VMNeu       int64 `json:"vm_neu"`
 	ChainTxNeu  int64 `json:"chain_tx_neu"`
+
+	// the transaction header and the flexible gas aren't itemized
+	Inputs  []*EstimateGasItem `json:"inputs"`
+	Outputs []*EstimateGasItem `json:"outputs"`
+}
+
+// EstimateGasItem is the gas consumed by an input or an output, the witness
+// size is the size of the arguments not signed yet
+type EstimateGasItem struct {
+	Position      int    `json:"position"`
+	Type          string `json:"type"`
+	Size          int64  `json:"size"`
+	WitnessSize   int64  `json:"witness_size"`
+	StateDataSize int64  `json:"state_data_size"`
+	VMGas         int64  `json:"vm_gas"`
+	StorageGas    int64  `json:"storage_gas"`
+	FeeNeu        int64  `json:"fee_neu"`
+	DryRun        bool   `json:"dry_run"`
+	DryRunError   string `json:"dry_run_error,omitempty"`
+}
+
+func (item *EstimateGasItem) setFee(forkParams consensus.ForkParams) {
+	item.StorageGas = (item.Size + item.WitnessSize) * forkParams.StorageGasRate
+	item.FeeNeu = (item.VMGas + item.StorageGas) * forkParams.VMGasRate
 }
 
-func EstimateChainTxGas(templates []Template) (*EstimateTxGasInfo, error) {
-	estimated, err := EstimateTxGas(templates[len(templates)-1])
+func EstimateChainTxGas(templates []Template, forkParams consensus.ForkParams, dryRunner DryRunner) (*EstimateTxGasInfo, error) {
+	estimated, err := EstimateTxGas(templates[len(templates)-1], forkParams, dryRunner)
 	if err != nil {
 		return nil, err
 	}
@@ -41,20 +80,50 @@ func EstimateChainTxGas(templates []Template) (*EstimateTxGasInfo, error) {
 	return estimated, nil
 }
 
-// EstimateTxGas estimate consumed neu for transaction
-func EstimateTxGas(template Template) (*EstimateTxGasInfo, error) {
-	var baseP2WSHSize, totalWitnessSize, baseP2WSHGas, totalP2WPKHGas, totalP2WSHGas, totalIssueGas int64
+// EstimateTxGas estimate consumed neu for transaction at the gas rates of the
+// fork params, the programs of the inputs signed by the unknown instructions
+// are dry run by the dryRunner
+func EstimateTxGas(template Template, forkParams consensus.ForkParams, dryRunner DryRunner) (*EstimateTxGasInfo, error) {
+	var baseP2WSHSize, totalWitnessSize, baseP2WSHGas, totalP2WPKHGas, totalP2WSHGas, totalIssueGas, totalContractGas int64
+	var dryRunTx *types.Tx
+	inputs := []*EstimateGasItem{}
 	for pos, input := range template.Transaction.TxData.Inputs {
+		size, err := serializedSize([]*types.TxInput{input}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		item := &EstimateGasItem{Position: pos, Size: size}
 		switch input.InputType() {
 		case types.SpendInputType:
 			controlProgram := input.ControlProgram()
+			item.StateDataSize = stateDataSize(input.TypedInput.(*types.SpendInput).StateData)
 			if segwit.IsP2WPKHScript(controlProgram) {
+				item.Type, item.WitnessSize, item.VMGas = "p2wpkh", baseP2WPKHSize, baseP2WPKHGas
 				totalWitnessSize += baseP2WPKHSize
 				totalP2WPKHGas += baseP2WPKHGas
 			} else if segwit.IsP2WSHScript(controlProgram) {
 				baseP2WSHSize, baseP2WSHGas = estimateP2WSHGas(template.SigningInstructions[pos])
+				item.Type, item.WitnessSize, item.VMGas = "p2wsh", baseP2WSHSize, baseP2WSHGas
 				totalWitnessSize += baseP2WSHSize
 				totalP2WSHGas += baseP2WSHGas
+			} else {
+				if dryRunTx == nil {
+					if dryRunTx, err = newDryRunTx(&template); err != nil {
+						return nil, err
+					}
+				}
+
+				if err := estimateContractGas(dryRunTx, pos, dryRunner, item); err != nil {
+					return nil, err
+				}
+
+				item.Type = "contract"
+				if bcrp.IsCallContractScript(controlProgram) {
+					item.Type = "call_contract"
+				}
+				totalWitnessSize += item.WitnessSize
+				totalContractGas += item.VMGas
 			}
 
 		case types.IssuanceInputType:
@@ -62,36 +131,184 @@ func EstimateTxGas(template Template) (*EstimateTxGasInfo, error) {
 			if height := vmutil.GetIssuanceProgramRestrictHeight(issuanceProgram); height > 0 {
 				// the gas for issue program with checking block height
 				totalIssueGas += 5
+				item.VMGas += 5
 			}
 			baseIssueSize, baseIssueGas := estimateIssueGas(template.SigningInstructions[pos])
+			item.Type, item.WitnessSize, item.VMGas = "issue", baseIssueSize, item.VMGas+baseIssueGas
 			totalWitnessSize += baseIssueSize
 			totalIssueGas += baseIssueGas
+
+		case types.VetoInputType:
+			item.Type = "veto"
 		}
+		item.setFee(forkParams)
+		inputs = append(inputs, item)
+	}
+
+	outputs := []*EstimateGasItem{}
+	for pos, output := range template.Transaction.TxData.Outputs {
+		size, err := serializedSize(nil, []*types.TxOutput{output})
+		if err != nil {
+			return nil, err
+		}
+
+		item := &EstimateGasItem{Position: pos, Type: "original", Size: size, StateDataSize: stateDataSize(output.StateData)}
+		if output.OutputType() == types.VoteOutputType {
+			item.Type = "vote"
+		}
+		item.setFee(forkParams)
+		outputs = append(outputs, item)
 	}
 
 	flexibleGas := int64(0)
 	if totalP2WPKHGas > 0 {
-		flexibleGas += baseP2WPKHGas + (baseSize+baseP2WPKHSize)*consensus.StorageGasRate
+		flexibleGas += baseP2WPKHGas + (baseSize+baseP2WPKHSize)*forkParams.StorageGasRate
 	} else if totalP2WSHGas > 0 {
-		flexibleGas += baseP2WSHGas + (baseSize+baseP2WSHSize)*consensus.StorageGasRate
+		flexibleGas += baseP2WSHGas + (baseSize+baseP2WSHSize)*forkParams.StorageGasRate
 	} else if totalIssueGas > 0 {
 		totalIssueGas += baseP2WPKHGas
 		totalWitnessSize += baseSize + baseP2WPKHSize
+	} else if totalContractGas > 0 {
+		flexibleGas += baseP2WPKHGas + (baseSize+baseP2WPKHSize)*forkParams.StorageGasRate
 	}
 
 	// the total transaction storage gas
-	totalTxSizeGas := (int64(template.Transaction.TxData.SerializedSize) + totalWitnessSize) * consensus.StorageGasRate
+	totalTxSizeGas := (int64(template.Transaction.TxData.SerializedSize) + totalWitnessSize) * forkParams.StorageGasRate
 
 	// the total transaction gas is composed of storage and virtual machines
-	totalGas := totalTxSizeGas + totalP2WPKHGas + totalP2WSHGas + totalIssueGas + flexibleGas
+	vmGas := totalP2WPKHGas + totalP2WSHGas + totalIssueGas + totalContractGas
+	totalGas := totalTxSizeGas + vmGas + flexibleGas
 	return &EstimateTxGasInfo{
-		TotalNeu:    totalGas * consensus.VMGasRate,
-		FlexibleNeu: flexibleGas * consensus.VMGasRate,
-		StorageNeu:  totalTxSizeGas * consensus.VMGasRate,
-		VMNeu:       (totalP2WPKHGas + totalP2WSHGas + totalIssueGas) * consensus.VMGasRate,
+		TotalNeu:    totalGas * forkParams.VMGasRate,
+		FlexibleNeu: flexibleGas * forkParams.VMGasRate,
+		StorageNeu:  totalTxSizeGas * forkParams.VMGasRate,
+		VMNeu:       vmGas * forkParams.VMGasRate,
+		Inputs:      inputs,
+		Outputs:     outputs,
 	}, nil
 }
 
+// newDryRunTx return a copy of the transaction with the witness of every
+// signing instruction, the missing signatures are filled by placeholders
+func newDryRunTx(template *Template) (*types.Tx, error) {
+	data, err := template.Transaction.TxData.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &types.Tx{}
+	if err := tx.UnmarshalText(data); err != nil {
+		return nil, err
+	}
+
+	for _, sigInst := range template.SigningInstructions {
+		if int(sigInst.Position) >= len(tx.Inputs) {
+			return nil, errors.WithDetailf(ErrBadTxInputIdx, "signing instruction references missing tx input %d", sigInst.Position)
+		}
+
+		args, err := dryRunArguments(template, sigInst)
+		if err != nil {
+			return nil, err
+		}
+		tx.SetInputArguments(sigInst.Position, args)
+	}
+	return tx, nil
+}
+
+// dryRunArguments materializes the witness of the signing instruction, the
+// placeholders have the same size as the signatures
+func dryRunArguments(template *Template, sigInst *SigningInstruction) ([][]byte, error) {
+	var args [][]byte
+	for _, wc := range sigInst.WitnessComponents {
+		switch sw := wc.(type) {
+		case *SignatureWitness:
+			filled := *sw
+			filled.Sigs = placeholderSigs(sw.Sigs, sw.Quorum)
+			if len(filled.Program) == 0 {
+				program, err := buildSigProgram(template, sigInst.Position)
+				if err != nil {
+					return nil, err
+				}
+				filled.Program = program
+			}
+			wc = &filled
+
+		case *RawTxSigWitness:
+			filled := *sw
+			filled.Sigs = placeholderSigs(sw.Sigs, sw.Quorum)
+			wc = &filled
+		}
+
+		if err := wc.materialize(&args); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+func placeholderSigs(sigs []chainjson.HexBytes, quorum int) []chainjson.HexBytes {
+	filled := []chainjson.HexBytes{}
+	for _, sig := range sigs {
+		if len(sig) > 0 {
+			filled = append(filled, sig)
+		}
+	}
+
+	for len(filled) < quorum {
+		filled = append(filled, make([]byte, ed25519.SignatureSize))
+	}
+	return filled
+}
+
+// estimateContractGas measures the witness of the input in the dry run
+// transaction and executes the program. A failed execution, e.g. caused by
+// the placeholder signatures, only reports the gas consumed before the failure.
+func estimateContractGas(dryRunTx *types.Tx, pos int, dryRunner DryRunner, item *EstimateGasItem) error {
+	size, err := serializedSize([]*types.TxInput{dryRunTx.Inputs[pos]}, nil)
+	if err != nil {
+		return err
+	}
+
+	if size > item.Size {
+		item.WitnessSize = size - item.Size
+	}
+
+	item.DryRun = true
+	if dryRunner == nil {
+		item.DryRunError = ErrNoDryRunner.Error()
+		return nil
+	}
+
+	gas, err := dryRunner.DryRunInput(dryRunTx, pos)
+	if err != nil {
+		item.DryRunError = err.Error()
+	}
+	item.VMGas = gas
+	return nil
+}
+
+// serializedSize return the size of the inputs and the outputs in the transaction
+func serializedSize(inputs []*types.TxInput, outputs []*types.TxOutput) (int64, error) {
+	emptySize, err := (&types.TxData{Version: 1}).WriteTo(ioutil.Discard)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := (&types.TxData{Version: 1, Inputs: inputs, Outputs: outputs}).WriteTo(ioutil.Discard)
+	if err != nil {
+		return 0, err
+	}
+	return size - emptySize, nil
+}
+
+func stateDataSize(stateData [][]byte) int64 {
+	var size int64
+	for _, data := range stateData {
+		size += int64(len(data))
+	}
+	return size
+}
+
 // estimateP2WSH return the witness size and the gas consumed to execute the virtual machine for P2WSH program
 func estimateP2WSHGas(sigInst *SigningInstruction) (int64, int64) {
 	var witnessSize, gas int64
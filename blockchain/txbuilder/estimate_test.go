@@ -1,8 +1,14 @@
 package txbuilder
 
 import (
+	"bytes"
 	"encoding/json"
 	"testing"
+
+	"kuskcore/consensus"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
 )
 
 /* ------------------------------------------------------------------
@@ -139,7 +145,7 @@ func TestEstimateTxGas(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		estimateTxGasResp, err := EstimateTxGas(template)
+		estimateTxGasResp, err := EstimateTxGas(template, consensus.ActiveNetParams.ForkParams(1), nil)
 		if estimateTxGasResp.TotalNeu != c.wantTotalNeu {
 			t.Errorf(`got TotalNeu =%#v; want=%#v`, estimateTxGasResp.TotalNeu, c.wantTotalNeu)
 		}
@@ -149,3 +155,126 @@ func TestEstimateTxGas(t *testing.T) {
 		}
 	}
 }
+
+type mockDryRunner struct {
+	args [][]byte
+	gas  int64
+	err  error
+}
+
+func (m *mockDryRunner) DryRunInput(tx *types.Tx, index int) (int64, error) {
+	m.args = tx.Inputs[index].Arguments()
+	return m.gas, m.err
+}
+
+func TestEstimateContractTxGas(t *testing.T) {
+	contractProgram := []byte{0x51}
+	tx := types.NewTx(types.TxData{
+		Version: 1,
+		Inputs: []*types.TxInput{
+			types.NewSpendInput(nil, bc.NewHash([32]byte{1}), *consensus.KUSKAssetID, 100, 0, contractProgram, [][]byte{{1, 2, 3}}),
+		},
+		Outputs: []*types.TxOutput{
+			types.NewOriginalTxOutput(*consensus.KUSKAssetID, 100, []byte{0x51}, [][]byte{{4, 5}}),
+		},
+	})
+	sigInst := &SigningInstruction{Position: 0}
+	sigInst.AddRawWitnessKeys(nil, nil, 1)
+	sigInst.WitnessComponents = append(sigInst.WitnessComponents, DataWitness([]byte{7, 8, 9}))
+	template := Template{Transaction: tx, SigningInstructions: []*SigningInstruction{sigInst}}
+
+	cases := []struct {
+		dryRunner   *mockDryRunner
+		vmGas       int64
+		dryRunError string
+	}{
+		{dryRunner: &mockDryRunner{gas: 100}, vmGas: 100},
+		{dryRunner: &mockDryRunner{gas: 60, err: errors.New("false VM result")}, vmGas: 60, dryRunError: "false VM result"},
+		{vmGas: 0, dryRunError: ErrNoDryRunner.Error()},
+	}
+
+	for i, c := range cases {
+		var dryRunner DryRunner
+		if c.dryRunner != nil {
+			dryRunner = c.dryRunner
+		}
+
+		estimated, err := EstimateTxGas(template, consensus.ActiveNetParams.ForkParams(1), dryRunner)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(estimated.Inputs) != 1 || len(estimated.Outputs) != 1 {
+			t.Fatalf("case %d: got %d input items and %d output items", i, len(estimated.Inputs), len(estimated.Outputs))
+		}
+
+		// the placeholder signature and the data are pushed with the length prefixes
+		input := estimated.Inputs[0]
+		if input.Type != "contract" || !input.DryRun || input.WitnessSize != 65+4 || input.StateDataSize != 3 || input.VMGas != c.vmGas || input.DryRunError != c.dryRunError {
+			t.Errorf("case %d: got input item %+v", i, input)
+		}
+
+		if wantFee := (input.VMGas + (input.Size+input.WitnessSize)*consensus.StorageGasRate) * consensus.VMGasRate; input.FeeNeu != wantFee {
+			t.Errorf("case %d: got input fee %d, want %d", i, input.FeeNeu, wantFee)
+		}
+
+		if output := estimated.Outputs[0]; output.Type != "original" || output.StateDataSize != 2 || output.VMGas != 0 || output.FeeNeu != output.Size*consensus.StorageGasRate*consensus.VMGasRate {
+			t.Errorf("case %d: got output item %+v", i, output)
+		}
+
+		if wantVMNeu := c.vmGas * consensus.VMGasRate; estimated.VMNeu != wantVMNeu {
+			t.Errorf("case %d: got VMNeu %d, want %d", i, estimated.VMNeu, wantVMNeu)
+		}
+
+		if c.dryRunner != nil && (len(c.dryRunner.args) != 2 || !bytes.Equal(c.dryRunner.args[0], make([]byte, 64)) || !bytes.Equal(c.dryRunner.args[1], []byte{7, 8, 9})) {
+			t.Errorf("case %d: got dry run arguments %x", i, c.dryRunner.args)
+		}
+	}
+
+	if len(tx.Inputs[0].Arguments()) != 0 {
+		t.Errorf("the witness of the template is changed by the dry run")
+	}
+}
+
+func TestEstimateTxGasForkParams(t *testing.T) {
+	tx := types.NewTx(types.TxData{
+		Version: 1,
+		Inputs: []*types.TxInput{
+			types.NewSpendInput(nil, bc.NewHash([32]byte{1}), *consensus.KUSKAssetID, 100, 0, []byte{0x51}, nil),
+		},
+		Outputs: []*types.TxOutput{
+			types.NewOriginalTxOutput(*consensus.KUSKAssetID, 100, []byte{0x51}, nil),
+		},
+	})
+	template := Template{Transaction: tx, SigningInstructions: []*SigningInstruction{{Position: 0}}}
+	dryRunner := &mockDryRunner{gas: 100}
+
+	forkParams := consensus.ActiveNetParams.ForkParams(1)
+	estimated, err := EstimateTxGas(template, forkParams, dryRunner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fork doubles the storage gas rate and triples the vm gas rate
+	forkParams.StorageGasRate *= 2
+	forkParams.VMGasRate *= 3
+	forked, err := EstimateTxGas(template, forkParams, dryRunner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if forked.VMNeu != estimated.VMNeu*3 || forked.StorageNeu != estimated.StorageNeu*6 {
+		t.Errorf("got vm neu %d and storage neu %d after the fork, want %d and %d", forked.VMNeu, forked.StorageNeu, estimated.VMNeu*3, estimated.StorageNeu*6)
+	}
+
+	if forked.TotalNeu != forked.VMNeu+forked.StorageNeu+forked.FlexibleNeu {
+		t.Errorf("got total neu %d, want the sum of %d, %d and %d", forked.TotalNeu, forked.VMNeu, forked.StorageNeu, forked.FlexibleNeu)
+	}
+
+	for _, item := range append(forked.Inputs, forked.Outputs...) {
+		wantStorageGas := (item.Size + item.WitnessSize) * forkParams.StorageGasRate
+		if item.StorageGas != wantStorageGas || item.FeeNeu != (item.VMGas+wantStorageGas)*forkParams.VMGasRate {
+			t.Errorf("got %s item storage gas %d and fee %d at the fork rates", item.Type, item.StorageGas, item.FeeNeu)
+		}
+	}
+}
@@ -30,6 +30,35 @@ var (
 // assembles a fully signed tx, and stores the effects of
 // its changes on the UTXO set.
 func FinalizeTx(ctx context.Context, c *protocol.Chain, tx *types.Tx) error {
+	if err := checkTx(tx); err != nil {
+		return err
+	}
+
+	isOrphan, err := c.ValidateTx(tx)
+	if errors.Root(err) == protocol.ErrBadTx {
+		return errors.Sub(ErrRejected, err)
+	}
+	if err != nil {
+		return errors.WithDetail(err, "tx rejected: "+err.Error())
+	}
+	if isOrphan {
+		return ErrOrphanTx
+	}
+	return nil
+}
+
+// RelayTx checks the transaction like FinalizeTx, but the transaction is
+// relayed to the peers instead of being validated by the local chain, it's
+// used by the light node which has no mempool.
+func RelayTx(ctx context.Context, relay func(*types.Tx) error, tx *types.Tx) error {
+	if err := checkTx(tx); err != nil {
+		return err
+	}
+
+	return relay(tx)
+}
+
+func checkTx(tx *types.Tx) error {
 	if tx.Fee() > cfg.CommonConfig.Wallet.MaxTxFee {
 		return ErrExtTxFee
 	}
@@ -45,17 +74,6 @@ func FinalizeTx(ctx context.Context, c *protocol.Chain, tx *types.Tx) error {
 	}
 	tx.TxData.SerializedSize = uint64(len(data) / 2)
 	tx.Tx.SerializedSize = uint64(len(data) / 2)
-
-	isOrphan, err := c.ValidateTx(tx)
-	if errors.Root(err) == protocol.ErrBadTx {
-		return errors.Sub(ErrRejected, err)
-	}
-	if err != nil {
-		return errors.WithDetail(err, "tx rejected: "+err.Error())
-	}
-	if isOrphan {
-		return ErrOrphanTx
-	}
 	return nil
 }
 
@@ -158,6 +158,7 @@ func AddCommands() {
 	KuskcliCmd.AddCommand(signMsgCmd)
 	KuskcliCmd.AddCommand(verifyMsgCmd)
 	KuskcliCmd.AddCommand(decodeProgCmd)
+	KuskcliCmd.AddCommand(compileCmd)
 
 	KuskcliCmd.AddCommand(createTransactionFeedCmd)
 	KuskcliCmd.AddCommand(listTransactionFeedsCmd)
@@ -1,13 +1,23 @@
 package commands
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"os"
 
 	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
 
+	"kuskcore/equity/compiler"
 	"kuskcore/util"
 )
 
+func init() {
+	compileCmd.PersistentFlags().StringVar(&contractArgs, "args", "", "the contract arguments in json, e.g. '[{\"integer\": 100}, {\"string\": \"51\"}]'")
+}
+
+var contractArgs = ""
+
 var decodeProgCmd = &cobra.Command{
 	Use:   "decode-program <program>",
 	Short: "decode program to instruction and data",
@@ -24,3 +34,34 @@ var decodeProgCmd = &cobra.Command{
 		printJSON(data)
 	},
 }
+
+var compileCmd = &cobra.Command{
+	Use:   "compile <contract file>",
+	Short: "compile the contract source to BVM program and ABI",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		source, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			jww.ERROR.Println(err)
+			os.Exit(util.ErrLocalExe)
+		}
+
+		var req = struct {
+			Contract string                 `json:"contract"`
+			Args     []compiler.ContractArg `json:"args,omitempty"`
+		}{Contract: string(source)}
+
+		if contractArgs != "" {
+			if err := json.Unmarshal([]byte(contractArgs), &req.Args); err != nil {
+				jww.ERROR.Println(err)
+				os.Exit(util.ErrLocalExe)
+			}
+		}
+
+		data, exitCode := util.ClientCall("/compile", &req)
+		if exitCode != util.Success {
+			os.Exit(exitCode)
+		}
+		printJSON(data)
+	},
+}
@@ -13,6 +13,8 @@ import (
 	"kuskcore/crypto/ed25519/chainkd"
 )
 
+var genesisFile string
+
 var initFilesCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize blockchain",
@@ -21,6 +23,7 @@ var initFilesCmd = &cobra.Command{
 
 func init() {
 	initFilesCmd.Flags().String("chain_id", config.ChainID, "Select [mainnet] or [testnet] or [solonet]")
+	initFilesCmd.Flags().StringVar(&genesisFile, "genesis", "", "Define a custom network by the genesis file, chain_id is ignored")
 
 	RootCmd.AddCommand(initFilesCmd)
 }
@@ -32,8 +35,10 @@ func initFiles(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	switch config.ChainID {
-	case "mainnet", "testnet":
+	switch {
+	case genesisFile != "":
+		initCustomNetwork()
+	case config.ChainID == "mainnet", config.ChainID == "testnet":
 		cfg.EnsureRoot(config.RootDir, config.ChainID)
 	default:
 		cfg.EnsureRoot(config.RootDir, "solonet")
@@ -56,3 +61,27 @@ func initFiles(cmd *cobra.Command, args []string) {
 
 	log.WithFields(log.Fields{"module": logModule, "config": configFilePath}).Info("Initialized kusk")
 }
+
+func initCustomNetwork() {
+	genesisData, err := ioutil.ReadFile(genesisFile)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Fatal("fail on read genesis file")
+	}
+
+	doc, err := cfg.LoadGenesisDoc(genesisFile)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Fatal("fail on load genesis file")
+	}
+
+	genesisHash, err := cfg.RegisterGenesisDoc(doc)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Fatal("fail on build genesis block")
+	}
+
+	cfg.EnsureCustomRoot(config.RootDir, doc, genesisData)
+	if err := cfg.PinGenesisHash(config.GenesisHashFile(), genesisHash); err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Fatal("fail on pin genesis hash")
+	}
+
+	log.WithFields(log.Fields{"module": logModule, "chain_id": doc.ChainID, "genesis_hash": genesisHash.String()}).Info("Initialized custom network")
+}
@@ -27,6 +27,7 @@ func init() {
 	runNodeCmd.Flags().Bool("wallet.rescan", config.Wallet.Rescan, "Rescan wallet")
 	runNodeCmd.Flags().Bool("wallet.txindex", config.Wallet.TxIndex, "Save global tx index")
 	runNodeCmd.Flags().Bool("vault_mode", config.VaultMode, "Run in the offline enviroment")
+	runNodeCmd.Flags().Bool("light_mode", config.LightMode, "Run as the light node which syncs block headers and wallet transactions only")
 	runNodeCmd.Flags().Bool("web.closed", config.Web.Closed, "Lanch web browser or not")
 	runNodeCmd.Flags().String("chain_id", config.ChainID, "Select network type")
 
@@ -45,6 +46,12 @@ func init() {
 	runNodeCmd.Flags().String("p2p.proxy_username", config.P2P.ProxyUsername, "Username for proxy server")
 	runNodeCmd.Flags().String("p2p.proxy_password", config.P2P.ProxyPassword, "Password for proxy server")
 	runNodeCmd.Flags().String("p2p.keep_dial", config.P2P.KeepDial, "Peers addresses try keeping connecting to, separated by ',' (for example \"1.1.1.1:46657;2.2.2.2:46658\")")
+	runNodeCmd.Flags().Int64("p2p.send_rate", config.P2P.SendRate, "Max bytes per second sent to each peer")
+	runNodeCmd.Flags().Int64("p2p.recv_rate", config.P2P.RecvRate, "Max bytes per second received from each peer")
+	runNodeCmd.Flags().Int64("p2p.max_send_rate", config.P2P.MaxSendRate, "Max bytes per second sent to all peers (0 means unlimited)")
+	runNodeCmd.Flags().Int64("p2p.max_recv_rate", config.P2P.MaxRecvRate, "Max bytes per second received from all peers (0 means unlimited)")
+	runNodeCmd.Flags().Uint32("p2p.min_protocol_version", config.P2P.MinProtocolVersion, "Lowest peer protocol version to connect (0 means the default)")
+	runNodeCmd.Flags().String("p2p.asn_table", config.P2P.ASNTable, "File of the \"<cidr> <asn>\" lines to diversify the outbound peers by the autonomous systems")
 
 	// log flags
 	runNodeCmd.Flags().String("log_file", config.LogFile, "Log output file")
@@ -8,8 +8,8 @@ import (
 	"github.com/tendermint/tmlibs/cli"
 
 	"kuskcore/consensus"
-	"kuskcore/toolbar/common"
 	cfg "kuskcore/toolbar/vote_reward/config"
+	"kuskcore/toolbar/vote_reward/database"
 	"kuskcore/toolbar/vote_reward/settlementvotereward"
 	"kuskcore/toolbar/vote_reward/synchron"
 )
@@ -49,14 +49,13 @@ func runReward(cmd *cobra.Command, args []string) error {
 		log.Fatal("Please check the height range, which must be multiple of the number of block rounds.")
 	}
 
-	db, err := common.NewMySQLDB(config.MySQLConfig)
+	store, err := database.NewStore(config)
 	if err != nil {
-		log.WithFields(log.Fields{"module": logModule, "error": err}).Fatal("Failded to initialize mysql db.")
+		log.WithFields(log.Fields{"module": logModule, "db_backend": config.DBBackend, "error": err}).Fatal("Failded to initialize db.")
 	}
+	defer store.Close()
 
-	db.LogMode(true)
-
-	keeper, err := synchron.NewChainKeeper(db, config, rewardEndHeight)
+	keeper, err := synchron.NewChainKeeper(store, config, rewardEndHeight)
 	if err != nil {
 		log.WithFields(log.Fields{"module": logModule, "error": err}).Fatal("Failded to initialize NewChainKeeper.")
 	}
@@ -65,7 +64,7 @@ func runReward(cmd *cobra.Command, args []string) error {
 		log.WithFields(log.Fields{"module": logModule, "error": err}).Fatal("Failded to sync block.")
 	}
 
-	s := settlementvotereward.NewSettlementReward(db, config, rewardStartHeight, rewardEndHeight)
+	s := settlementvotereward.NewSettlementReward(store, config, rewardStartHeight, rewardEndHeight)
 
 	if err := s.Settlement(); err != nil {
 		log.WithFields(log.Fields{"module": logModule, "error": err}).Fatal("Settlement vote rewards failure.")
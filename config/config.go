@@ -115,9 +115,15 @@ type BaseConfig struct {
 
 	VaultMode bool `mapstructure:"vault_mode"`
 
+	// Sync the headers and the filtered blocks of the wallet only
+	LightMode bool `mapstructure:"light_mode"`
+
 	// log file name
 	LogFile string `mapstructure:"log_file"`
 
+	// Genesis file of a custom network, not used by the built-in networks
+	GenesisPath string `mapstructure:"genesis_file"`
+
 	PrivateKeyFile string `mapstructure:"private_key_file"`
 	XPrv           *chainkd.XPrv
 	XPub           *chainkd.XPub
@@ -135,6 +141,7 @@ func DefaultBaseConfig() BaseConfig {
 		NodeAlias:         "",
 		LogFile:           "log",
 		PrivateKeyFile:    "node_key.txt",
+		GenesisPath:       "genesis.json",
 	}
 }
 
@@ -150,6 +157,15 @@ func (b BaseConfig) KeysDir() string {
 	return rootify(b.KeysPath, b.RootDir)
 }
 
+func (b BaseConfig) GenesisFile() string {
+	return rootify(b.GenesisPath, b.RootDir)
+}
+
+// GenesisHashFile is where the genesis hash of a custom network is pinned
+func (b BaseConfig) GenesisHashFile() string {
+	return filepath.Join(b.DBDir(), "genesis_hash")
+}
+
 // P2PConfig
 type P2PConfig struct {
 	ListenAddress    string `mapstructure:"laddr"`
@@ -163,6 +179,19 @@ type P2PConfig struct {
 	ProxyUsername    string `mapstructure:"proxy_username"`
 	ProxyPassword    string `mapstructure:"proxy_password"`
 	KeepDial         string `mapstructure:"keep_dial"`
+	ASNTable         string `mapstructure:"asn_table"`
+	SendRate         int64  `mapstructure:"send_rate"`     // per peer bytes per second
+	RecvRate         int64  `mapstructure:"recv_rate"`     // per peer bytes per second
+	MaxSendRate      int64  `mapstructure:"max_send_rate"` // all peers bytes per second, 0 means unlimited
+	MaxRecvRate      int64  `mapstructure:"max_recv_rate"` // all peers bytes per second, 0 means unlimited
+
+	MinProtocolVersion uint32 `mapstructure:"min_protocol_version"` // lowest peer protocol version to connect, 0 means the default
+}
+
+// ASNTableFile returns the path of the table to bucket the addresses by the
+// autonomous systems
+func (cfg *Config) ASNTableFile() string {
+	return rootify(cfg.P2P.ASNTable, cfg.BaseConfig.RootDir)
 }
 
 // Default configurable p2p parameters.
@@ -177,6 +206,8 @@ func DefaultP2PConfig() *P2PConfig {
 		ProxyAddress:     "",
 		ProxyUsername:    "",
 		ProxyPassword:    "",
+		SendRate:         512000,
+		RecvRate:         512000,
 	}
 }
 
@@ -81,6 +81,10 @@ func soloNetGenesisBlock() *types.Block {
 
 // GenesisBlock will return genesis block
 func GenesisBlock() *types.Block {
+	if block, ok := genesisBlocks[consensus.ActiveNetParams.Name]; ok {
+		return block
+	}
+
 	return map[string]func() *types.Block{
 		"main": mainNetGenesisBlock,
 		"test": testNetGenesisBlock,
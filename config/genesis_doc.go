@@ -0,0 +1,332 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kuskcore/common"
+	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm/vmutil"
+)
+
+var (
+	ErrGenesisDoc      = errors.New("invalid genesis doc")
+	ErrGenesisMismatch = errors.New("genesis block mismatch the pinned genesis hash")
+
+	genesisBlocks = map[string]*types.Block{}
+)
+
+// GenesisDoc defines a custom network, it is copied into the root dir by
+// `kuskd init --genesis` and loaded every time the node starts
+type GenesisDoc struct {
+	ChainID         string              `json:"chain_id"`
+	Bech32HRPSegwit string              `json:"bech32_hrp"`
+	DefaultPort     string              `json:"default_port"`
+	DNSSeeds        []string            `json:"dns_seeds"`
+	Timestamp       uint64              `json:"genesis_timestamp"`
+	Message         string              `json:"genesis_message"`
+	Casper          GenesisCasperConfig `json:"casper"`
+	Allocations     []GenesisAllocation `json:"allocations"`
+	Forks           []GenesisFork       `json:"forks"`
+	Deployments     []GenesisDeployment `json:"deployments"`
+}
+
+// GenesisCasperConfig is the json form of consensus.CasperConfig
+type GenesisCasperConfig struct {
+	BlockTimeInterval    uint64                       `json:"block_time_interval"`
+	MaxTimeOffsetMs      uint64                       `json:"max_time_offset_ms"`
+	BlocksOfEpoch        uint64                       `json:"blocks_of_epoch"`
+	MinValidatorVoteNum  uint64                       `json:"min_validator_vote_num"`
+	VotePendingBlockNums []GenesisVotePendingBlockNum `json:"vote_pending_block_nums"`
+	FederationXpubs      []chainkd.XPub               `json:"federation_xpubs"`
+}
+
+// GenesisVotePendingBlockNum is the json form of consensus.VotePendingBlockNum,
+// an end_block of 0 means no end
+type GenesisVotePendingBlockNum struct {
+	BeginBlock uint64 `json:"begin_block"`
+	EndBlock   uint64 `json:"end_block"`
+	Num        uint64 `json:"num"`
+}
+
+// GenesisFork is the json form of consensus.Fork
+type GenesisFork struct {
+	Name     string   `json:"name"`
+	Height   uint64   `json:"height"`
+	Epoch    uint64   `json:"epoch"`
+	Features []string `json:"features"`
+	Params   struct {
+		MaxBlockGas    uint64 `json:"max_block_gas"`
+		VMGasRate      int64  `json:"vm_gas_rate"`
+		StorageGasRate int64  `json:"storage_gas_rate"`
+		MaxGasAmount   int64  `json:"max_gas_amount"`
+	} `json:"params"`
+}
+
+// GenesisDeployment is the json form of consensus.Deployment
+type GenesisDeployment struct {
+	Name         string `json:"name"`
+	Bit          uint8  `json:"bit"`
+	StartEpoch   uint64 `json:"start_epoch"`
+	TimeoutEpoch uint64 `json:"timeout_epoch"`
+	Threshold    uint64 `json:"threshold"`
+}
+
+// GenesisAllocation is a KUSK output of the genesis block, either address or
+// control_program should be set
+type GenesisAllocation struct {
+	Address        string             `json:"address"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	Amount         uint64             `json:"amount"`
+}
+
+// LoadGenesisDoc reads and validates the genesis file
+func LoadGenesisDoc(filePath string) (*GenesisDoc, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &GenesisDoc{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, errors.Wrap(err, "unmarshal genesis doc")
+	}
+
+	if err := doc.validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (g *GenesisDoc) validate() error {
+	if g.ChainID == "" || g.Bech32HRPSegwit == "" {
+		return errors.WithDetail(ErrGenesisDoc, "chain_id and bech32_hrp are required")
+	}
+
+	if _, ok := consensus.NetParams[g.ChainID]; ok {
+		return errors.WithDetailf(ErrGenesisDoc, "chain_id %s is a built-in network", g.ChainID)
+	}
+
+	if g.Casper.BlockTimeInterval == 0 || g.Casper.BlocksOfEpoch == 0 {
+		return errors.WithDetail(ErrGenesisDoc, "casper block_time_interval and blocks_of_epoch must be positive")
+	}
+
+	if len(g.Casper.FederationXpubs) == 0 {
+		return errors.WithDetail(ErrGenesisDoc, "at least one federation xpub is required")
+	}
+
+	if g.Timestamp == 0 {
+		return errors.WithDetail(ErrGenesisDoc, "genesis_timestamp is required")
+	}
+
+	if len(g.Allocations) == 0 {
+		return errors.WithDetail(ErrGenesisDoc, "at least one allocation is required")
+	}
+
+	// the pledge rate of the validator reward assumes the genesis supply is InitKUSKSupply
+	total := uint64(0)
+	for _, allocation := range g.Allocations {
+		if allocation.Amount == 0 || allocation.Amount > math.MaxUint64-total {
+			return errors.WithDetail(ErrGenesisDoc, "invalid allocation amount")
+		}
+		total += allocation.Amount
+	}
+
+	if total != consensus.InitKUSKSupply {
+		return errors.WithDetailf(ErrGenesisDoc, "allocations sum up to %d, should be %d", total, uint64(consensus.InitKUSKSupply))
+	}
+
+	for _, fork := range g.Forks {
+		if fork.Name == "" || (fork.Height == 0 && fork.Epoch == 0) {
+			return errors.WithDetail(ErrGenesisDoc, "fork needs a name and an activation height or epoch")
+		}
+
+		if fork.Params.VMGasRate < 0 || fork.Params.StorageGasRate < 0 || fork.Params.MaxGasAmount < 0 {
+			return errors.WithDetailf(ErrGenesisDoc, "fork %s has negative params", fork.Name)
+		}
+	}
+
+	if len(g.Deployments) > 0 && !g.hasFeature(consensus.FeatureVersionBits) {
+		return errors.WithDetailf(ErrGenesisDoc, "deployments need a fork enabling the %s feature", consensus.FeatureVersionBits)
+	}
+
+	bits := map[uint8]bool{}
+	for _, deployment := range g.Deployments {
+		if deployment.Name == "" || deployment.Bit >= consensus.VersionBitsNum || bits[deployment.Bit] {
+			return errors.WithDetail(ErrGenesisDoc, "deployment needs a name and an unused bit less than 29")
+		}
+
+		if deployment.Threshold == 0 || deployment.Threshold > 100 {
+			return errors.WithDetailf(ErrGenesisDoc, "deployment %s threshold should be a percentage", deployment.Name)
+		}
+		bits[deployment.Bit] = true
+	}
+	return nil
+}
+
+func (g *GenesisDoc) hasFeature(feature string) bool {
+	for _, fork := range g.Forks {
+		for _, f := range fork.Features {
+			if f == feature {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Params converts the genesis doc to the consensus params of the network
+func (g *GenesisDoc) Params() consensus.Params {
+	params := consensus.Params{
+		Name:            g.ChainID,
+		Bech32HRPSegwit: g.Bech32HRPSegwit,
+		DefaultPort:     g.DefaultPort,
+		DNSSeeds:        g.DNSSeeds,
+		CasperConfig: consensus.CasperConfig{
+			BlockTimeInterval:   g.Casper.BlockTimeInterval,
+			MaxTimeOffsetMs:     g.Casper.MaxTimeOffsetMs,
+			BlocksOfEpoch:       g.Casper.BlocksOfEpoch,
+			MinValidatorVoteNum: g.Casper.MinValidatorVoteNum,
+			FederationXpubs:     g.Casper.FederationXpubs,
+		},
+	}
+
+	for _, pendingNum := range g.Casper.VotePendingBlockNums {
+		endBlock := pendingNum.EndBlock
+		if endBlock == 0 {
+			endBlock = math.MaxUint64
+		}
+
+		params.VotePendingBlockNums = append(params.VotePendingBlockNums, consensus.VotePendingBlockNum{
+			BeginBlock: pendingNum.BeginBlock,
+			EndBlock:   endBlock,
+			Num:        pendingNum.Num,
+		})
+	}
+
+	for _, fork := range g.Forks {
+		params.Forks = append(params.Forks, consensus.Fork{
+			Name:     fork.Name,
+			Height:   fork.Height,
+			Epoch:    fork.Epoch,
+			Features: fork.Features,
+			Params: consensus.ForkParams{
+				MaxBlockGas:    fork.Params.MaxBlockGas,
+				VMGasRate:      fork.Params.VMGasRate,
+				StorageGasRate: fork.Params.StorageGasRate,
+				MaxGasAmount:   fork.Params.MaxGasAmount,
+			},
+		})
+	}
+
+	for _, deployment := range g.Deployments {
+		params.Deployments = append(params.Deployments, consensus.Deployment{
+			Name:         deployment.Name,
+			Bit:          deployment.Bit,
+			StartEpoch:   deployment.StartEpoch,
+			TimeoutEpoch: deployment.TimeoutEpoch,
+			Threshold:    deployment.Threshold,
+		})
+	}
+	return params
+}
+
+// GenesisBlock builds the genesis block of the network
+func (g *GenesisDoc) GenesisBlock() (*types.Block, error) {
+	params := g.Params()
+	var outputs []*types.TxOutput
+	for _, allocation := range g.Allocations {
+		program, err := allocation.controlProgram(&params)
+		if err != nil {
+			return nil, err
+		}
+
+		outputs = append(outputs, types.NewOriginalTxOutput(*consensus.KUSKAssetID, allocation.Amount, program, nil))
+	}
+
+	txs := []*types.Tx{types.NewTx(types.TxData{
+		Version: 1,
+		Inputs:  []*types.TxInput{types.NewCoinbaseInput([]byte(g.Message))},
+		Outputs: outputs,
+	})}
+
+	merkleRoot, err := types.TxMerkleRoot(toBCTxs(txs))
+	if err != nil {
+		return nil, errors.Wrap(err, "calc genesis tx merkle root")
+	}
+
+	return &types.Block{
+		BlockHeader: types.BlockHeader{
+			Version:   1,
+			Height:    0,
+			Timestamp: g.Timestamp,
+			BlockCommitment: types.BlockCommitment{
+				TransactionsMerkleRoot: merkleRoot,
+			},
+		},
+		Transactions: txs,
+	}, nil
+}
+
+func (a *GenesisAllocation) controlProgram(params *consensus.Params) ([]byte, error) {
+	if len(a.ControlProgram) != 0 {
+		return a.ControlProgram, nil
+	}
+
+	address, err := common.DecodeAddress(a.Address, params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode allocation address %s", a.Address)
+	}
+
+	switch address.(type) {
+	case *common.AddressWitnessPubKeyHash:
+		return vmutil.P2WPKHProgram(address.ScriptAddress())
+	case *common.AddressWitnessScriptHash:
+		return vmutil.P2WSHProgram(address.ScriptAddress())
+	default:
+		return nil, errors.WithDetailf(ErrGenesisDoc, "unsupported allocation address %s", a.Address)
+	}
+}
+
+// RegisterGenesisDoc adds the network of the genesis doc to consensus.NetParams
+// and returns the hash of its genesis block
+func RegisterGenesisDoc(doc *GenesisDoc) (*bc.Hash, error) {
+	block, err := doc.GenesisBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	consensus.NetParams[doc.ChainID] = doc.Params()
+	genesisBlocks[doc.ChainID] = block
+	hash := block.Hash()
+	return &hash, nil
+}
+
+// PinGenesisHash saves the genesis hash of a custom network into the file, the
+// node refuses to start when a different genesis hash has been pinned
+func PinGenesisHash(filePath string, hash *bc.Hash) error {
+	data, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(filePath, []byte(hash.String()), 0600)
+	} else if err != nil {
+		return err
+	}
+
+	if pinned := strings.TrimSpace(string(data)); pinned != hash.String() {
+		return errors.WithDetailf(ErrGenesisMismatch, "pinned %s, got %s", pinned, hash.String())
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kuskcore/consensus"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+)
+
+const testGenesisDoc = `{
+	"chain_id": "consortium",
+	"bech32_hrp": "ck",
+	"default_port": "46700",
+	"genesis_timestamp": 1732222222000,
+	"genesis_message": "consortium genesis",
+	"casper": {
+		"block_time_interval": 3000,
+		"max_time_offset_ms": 3000,
+		"blocks_of_epoch": 50,
+		"min_validator_vote_num": 100000000,
+		"vote_pending_block_nums": [{"begin_block": 0, "end_block": 0, "num": 20}],
+		"federation_xpubs": ["51f6a534970d739c4cad0204ff6f331f1ba3e3783fe424e1165bd7d2559ed69372e3d64ca8d34ef644e5a0ba97c97c4408049965923970adfe1f81e46198b920"]
+	},
+	"allocations": [
+		{"control_program": "0014dc90d8b67f95939950fd4f77db5e466faa92fe14", "amount": 6000000000000000},
+		{"control_program": "00143d05e891b165b165afefa2e861e83a9745f80d8c", "amount": 4000000000000000}
+	]
+}`
+
+func writeTestGenesisDoc(t *testing.T, dir, content string) string {
+	filePath := filepath.Join(dir, "genesis.json")
+	if err := ioutil.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestGenesisDoc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	doc, err := LoadGenesisDoc(writeTestGenesisDoc(t, dir, testGenesisDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := doc.Params()
+	if params.Name != "consortium" || params.Bech32HRPSegwit != "ck" || params.BlocksOfEpoch != 50 || len(params.FederationXpubs) != 1 {
+		t.Errorf("got unexpected params %v", params)
+	}
+
+	if pendingNum := params.VotePendingBlockNums[0]; pendingNum.EndBlock != ^uint64(0) || pendingNum.Num != 20 {
+		t.Errorf("got unexpected vote pending block num %v", pendingNum)
+	}
+
+	block, err := doc.GenesisBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Timestamp != doc.Timestamp || len(block.Transactions) != 1 || len(block.Transactions[0].Outputs) != 2 {
+		t.Errorf("got unexpected genesis block %v", block)
+	}
+
+	// the genesis block must be reproducible by every node of the network
+	again, err := doc.GenesisBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Hash() != again.Hash() {
+		t.Errorf("genesis block is not deterministic")
+	}
+
+	genesisHash, err := RegisterGenesisDoc(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer delete(consensus.NetParams, doc.ChainID)
+	defer delete(genesisBlocks, doc.ChainID)
+
+	if *genesisHash != block.Hash() {
+		t.Errorf("got genesis hash %v, want %v", genesisHash, block.Hash())
+	}
+
+	if _, err := LoadGenesisDoc(writeTestGenesisDoc(t, dir, testGenesisDoc)); errors.Root(err) != ErrGenesisDoc {
+		t.Errorf("load a registered network got err %v, want %v", err, ErrGenesisDoc)
+	}
+
+	hashFile := filepath.Join(dir, "data", "genesis_hash")
+	if err := PinGenesisHash(hashFile, genesisHash); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PinGenesisHash(hashFile, genesisHash); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := PinGenesisHash(hashFile, &bc.Hash{V0: 1}); errors.Root(err) != ErrGenesisMismatch {
+		t.Errorf("pin another genesis hash got err %v, want %v", err, ErrGenesisMismatch)
+	}
+}
+
+func TestGenesisDocValidate(t *testing.T) {
+	cases := []struct {
+		desc   string
+		modify func(doc *GenesisDoc)
+	}{
+		{desc: "built-in chain id", modify: func(doc *GenesisDoc) { doc.ChainID = "mainnet" }},
+		{desc: "missing bech32 hrp", modify: func(doc *GenesisDoc) { doc.Bech32HRPSegwit = "" }},
+		{desc: "zero blocks of epoch", modify: func(doc *GenesisDoc) { doc.Casper.BlocksOfEpoch = 0 }},
+		{desc: "no federation xpubs", modify: func(doc *GenesisDoc) { doc.Casper.FederationXpubs = nil }},
+		{desc: "wrong total supply", modify: func(doc *GenesisDoc) { doc.Allocations[0].Amount++ }},
+		{desc: "zero allocation", modify: func(doc *GenesisDoc) { doc.Allocations[1].Amount = 0 }},
+		{desc: "deployment without version bits", modify: func(doc *GenesisDoc) {
+			doc.Deployments = []GenesisDeployment{{Name: "test", Bit: 1, Threshold: 66}}
+		}},
+	}
+
+	dir, err := ioutil.TempDir("", "genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range cases {
+		doc, err := LoadGenesisDoc(writeTestGenesisDoc(t, dir, testGenesisDoc))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.modify(doc)
+		if err := doc.validate(); errors.Root(err) != ErrGenesisDoc {
+			t.Errorf("case %s: got err %v, want %v", c.desc, err, ErrGenesisDoc)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"path"
 
 	cmn "github.com/tendermint/tmlibs/common"
@@ -39,12 +40,40 @@ laddr = "tcp://0.0.0.0:46656"
 seeds = ""
 `
 
+var customNetConfigTmpl = `chain_id = "%s"
+genesis_file = "genesis.json"
+[p2p]
+laddr = "tcp://0.0.0.0:%s"
+seeds = ""
+`
+
 var soloNetConfigTmpl = `chain_id = "solonet"
 [p2p]
 laddr = "tcp://0.0.0.0:46658"
 seeds = ""
 `
 
+// EnsureCustomRoot creates the root dir of the custom network defined by the genesis doc
+func EnsureCustomRoot(rootDir string, doc *GenesisDoc, genesisData []byte) {
+	cmn.EnsureDir(rootDir, 0700)
+	cmn.EnsureDir(rootDir+"/data", 0700)
+
+	port := doc.DefaultPort
+	if port == "" {
+		port = "46658"
+	}
+
+	configFilePath := path.Join(rootDir, "config.toml")
+	if !cmn.FileExists(configFilePath) {
+		cmn.MustWriteFile(configFilePath, []byte(defaultConfigTmpl+fmt.Sprintf(customNetConfigTmpl, doc.ChainID, port)), 0644)
+	}
+
+	genesisFilePath := path.Join(rootDir, "genesis.json")
+	if !cmn.FileExists(genesisFilePath) {
+		cmn.MustWriteFile(genesisFilePath, genesisData, 0644)
+	}
+}
+
 // Select network seeds to merge a new string.
 func selectNetwork(network string) string {
 	switch network {
@@ -0,0 +1,34 @@
+package consensus
+
+const (
+	// VersionBitsTopBits is the top bits of a block version which signals deployments,
+	// the legacy block version 1 signals nothing
+	VersionBitsTopBits uint64 = 0x20000000
+	// VersionBitsTopMask is the mask to check the top bits of a block version
+	VersionBitsTopMask uint64 = 0xE0000000
+	// VersionBitsNum is the number of bits can be used by deployments
+	VersionBitsNum = 29
+)
+
+// Deployment is a soft fork signalled by the validators through the bits of
+// block version. It starts at StartEpoch, and is locked in at the end of an
+// epoch when no less than Threshold percent of the effective validators have
+// signalled it in the epoch, the next epoch it becomes active. It fails if not
+// locked in before the end of TimeoutEpoch, 0 means never timeout.
+type Deployment struct {
+	Name         string
+	Bit          uint8
+	StartEpoch   uint64
+	TimeoutEpoch uint64
+	Threshold    uint64
+}
+
+// Mask return the bit of the block version for the deployment
+func (d *Deployment) Mask() uint64 {
+	return uint64(1) << d.Bit
+}
+
+// IsVersionBits return whether the block version is used to signal deployments
+func IsVersionBits(version uint64) bool {
+	return version&VersionBitsTopMask == VersionBitsTopBits
+}
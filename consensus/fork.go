@@ -0,0 +1,111 @@
+package consensus
+
+import "sort"
+
+// the features can be enabled by a scheduled fork
+const (
+	// FeatureTimeOpcodes enables the BLOCKTIME and OUTPUTHEIGHT opcodes
+	FeatureTimeOpcodes = "timeOpcodes"
+
+	// FeatureKeyRotation enables the vote outputs rotating the validator keys
+	FeatureKeyRotation = "keyRotation"
+
+	// FeatureVersionBits enables the block versions signalling the deployments
+	FeatureVersionBits = "versionBits"
+)
+
+// ForkParams are the consensus parameters which can be changed by a scheduled fork
+type ForkParams struct {
+	MaxBlockGas    uint64
+	VMGasRate      int64
+	StorageGasRate int64
+	MaxGasAmount   int64
+}
+
+var defaultForkParams = ForkParams{
+	MaxBlockGas:    MaxBlockGas,
+	VMGasRate:      VMGasRate,
+	StorageGasRate: StorageGasRate,
+	MaxGasAmount:   MaxGasAmount,
+}
+
+// Fork is a scheduled protocol upgrade. It activates at Height, or at the first
+// block of Epoch when Epoch is set. Once activated its Features are enabled and
+// the non-zero fields of Params replace the previous values.
+type Fork struct {
+	Name     string
+	Height   uint64
+	Epoch    uint64
+	Features []string
+	Params   ForkParams
+}
+
+// ActivationHeight return the first block height the fork takes effect
+func (f *Fork) ActivationHeight(blocksOfEpoch uint64) uint64 {
+	if f.Epoch != 0 {
+		return f.Epoch*blocksOfEpoch + 1
+	}
+	return f.Height
+}
+
+// activeForks return the forks activated at the height, ordered by activation height
+func (p *Params) activeForks(height uint64) []Fork {
+	var forks []Fork
+	for _, fork := range p.Forks {
+		if fork.ActivationHeight(p.BlocksOfEpoch) <= height {
+			forks = append(forks, fork)
+		}
+	}
+
+	sort.SliceStable(forks, func(i, j int) bool {
+		return forks[i].ActivationHeight(p.BlocksOfEpoch) < forks[j].ActivationHeight(p.BlocksOfEpoch)
+	})
+	return forks
+}
+
+// ForkParams return the consensus parameters take effect at the height
+func (p *Params) ForkParams(height uint64) ForkParams {
+	params := defaultForkParams
+	for _, fork := range p.activeForks(height) {
+		if fork.Params.MaxBlockGas != 0 {
+			params.MaxBlockGas = fork.Params.MaxBlockGas
+		}
+		if fork.Params.VMGasRate != 0 {
+			params.VMGasRate = fork.Params.VMGasRate
+		}
+		if fork.Params.StorageGasRate != 0 {
+			params.StorageGasRate = fork.Params.StorageGasRate
+		}
+		if fork.Params.MaxGasAmount != 0 {
+			params.MaxGasAmount = fork.Params.MaxGasAmount
+		}
+	}
+	return params
+}
+
+// IsFeatureActive return whether the feature has been enabled by a fork at the height
+func (p *Params) IsFeatureActive(feature string, height uint64) bool {
+	for _, fork := range p.activeForks(height) {
+		for _, f := range fork.Features {
+			if f == feature {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UpcomingForks return the forks not activated at the height yet, ordered by activation height
+func (p *Params) UpcomingForks(height uint64) []Fork {
+	var forks []Fork
+	for _, fork := range p.Forks {
+		if fork.ActivationHeight(p.BlocksOfEpoch) > height {
+			forks = append(forks, fork)
+		}
+	}
+
+	sort.SliceStable(forks, func(i, j int) bool {
+		return forks[i].ActivationHeight(p.BlocksOfEpoch) < forks[j].ActivationHeight(p.BlocksOfEpoch)
+	})
+	return forks
+}
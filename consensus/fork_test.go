@@ -0,0 +1,81 @@
+package consensus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForkSchedule(t *testing.T) {
+	params := &Params{
+		CasperConfig: CasperConfig{BlocksOfEpoch: 100},
+		Forks: []Fork{
+			{
+				Name:     "second",
+				Epoch:    2,
+				Features: []string{"featureB"},
+				Params:   ForkParams{VMGasRate: 100},
+			},
+			{
+				Name:     "first",
+				Height:   50,
+				Features: []string{"featureA"},
+				Params:   ForkParams{MaxBlockGas: 20000000, VMGasRate: 300},
+			},
+		},
+	}
+
+	cases := []struct {
+		height   uint64
+		params   ForkParams
+		featureA bool
+		featureB bool
+		upcoming []string
+	}{
+		{
+			height:   49,
+			params:   defaultForkParams,
+			upcoming: []string{"first", "second"},
+		},
+		{
+			height:   50,
+			params:   ForkParams{MaxBlockGas: 20000000, VMGasRate: 300, StorageGasRate: StorageGasRate, MaxGasAmount: MaxGasAmount},
+			featureA: true,
+			upcoming: []string{"second"},
+		},
+		{
+			height:   200,
+			params:   ForkParams{MaxBlockGas: 20000000, VMGasRate: 300, StorageGasRate: StorageGasRate, MaxGasAmount: MaxGasAmount},
+			featureA: true,
+			upcoming: []string{"second"},
+		},
+		{
+			height:   201,
+			params:   ForkParams{MaxBlockGas: 20000000, VMGasRate: 100, StorageGasRate: StorageGasRate, MaxGasAmount: MaxGasAmount},
+			featureA: true,
+			featureB: true,
+		},
+	}
+
+	for i, c := range cases {
+		if got := params.ForkParams(c.height); got != c.params {
+			t.Errorf("case %d: fork params got %v, want %v", i, got, c.params)
+		}
+
+		if got := params.IsFeatureActive("featureA", c.height); got != c.featureA {
+			t.Errorf("case %d: featureA active got %v, want %v", i, got, c.featureA)
+		}
+
+		if got := params.IsFeatureActive("featureB", c.height); got != c.featureB {
+			t.Errorf("case %d: featureB active got %v, want %v", i, got, c.featureB)
+		}
+
+		var upcoming []string
+		for _, fork := range params.UpcomingForks(c.height) {
+			upcoming = append(upcoming, fork.Name)
+		}
+
+		if !reflect.DeepEqual(upcoming, c.upcoming) {
+			t.Errorf("case %d: upcoming forks got %v, want %v", i, upcoming, c.upcoming)
+		}
+	}
+}
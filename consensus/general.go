@@ -104,6 +104,12 @@ type Params struct {
 
 	// CasperConfig defines the casper consensus parameters
 	CasperConfig
+
+	// Forks defines the scheduled protocol upgrades
+	Forks []Fork
+
+	// Deployments defines the soft forks activated by the signals of validators
+	Deployments []Deployment
 }
 
 // ActiveNetParams is ...
@@ -11,11 +11,55 @@ const (
 	SFFastSync
 	// SFSPV indicate peer support spv mode
 	SFSPV
+	// SFCompactFilters indicate peer serves the compact block filters
+	SFCompactFilters
+	// SFPruned indicate peer validates and relays the new blocks but only keeps
+	// the recent blocks
+	SFPruned
+	// SFSnapshot indicate peer serves the state snapshots
+	SFSnapshot
 	// DefaultServices is the server that this node support
-	DefaultServices = SFFullNode | SFFastSync | SFSPV
+	DefaultServices = SFFullNode | SFFastSync | SFSPV | SFCompactFilters
 )
 
+// LightServices is the server that the light node support, it serves nothing
+// to the peers
+const LightServices = ServiceFlag(0)
+
+// RelayServices is the services of the nodes validating and relaying the new
+// blocks, a peer providing any of them takes part in the consensus
+const RelayServices = SFFullNode | SFPruned
+
+var serviceNames = []struct {
+	flag ServiceFlag
+	name string
+}{
+	{flag: SFFullNode, name: "full_node"},
+	{flag: SFFastSync, name: "fast_sync"},
+	{flag: SFSPV, name: "spv"},
+	{flag: SFCompactFilters, name: "compact_filters"},
+	{flag: SFPruned, name: "pruned"},
+	{flag: SFSnapshot, name: "snapshot"},
+}
+
 // IsEnable check does the flag support the input flag function
 func (f ServiceFlag) IsEnable(checkFlag ServiceFlag) bool {
 	return f&checkFlag == checkFlag
 }
+
+// IsAnyEnable check does the flag support any of the input flag functions
+func (f ServiceFlag) IsAnyEnable(checkFlag ServiceFlag) bool {
+	return f&checkFlag != 0
+}
+
+// Names return the names of the enabled services, the unknown services of the
+// newer nodes are ignored
+func (f ServiceFlag) Names() []string {
+	names := []string{}
+	for _, service := range serviceNames {
+		if f.IsEnable(service.flag) {
+			names = append(names, service.name)
+		}
+	}
+	return names
+}
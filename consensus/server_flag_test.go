@@ -36,3 +36,29 @@ func TestIsEnable(t *testing.T) {
 		}
 	}
 }
+
+func TestIsAnyEnable(t *testing.T) {
+	cases := []struct {
+		baseFlag   ServiceFlag
+		checkFlage ServiceFlag
+		result     bool
+	}{
+		{baseFlag: SFFullNode, checkFlage: RelayServices, result: true},
+		{baseFlag: SFPruned | SFFastSync, checkFlage: RelayServices, result: true},
+		{baseFlag: LightServices, checkFlage: RelayServices, result: false},
+		{baseFlag: SFSPV | SFCompactFilters, checkFlage: RelayServices, result: false},
+	}
+
+	for i, c := range cases {
+		if c.baseFlag.IsAnyEnable(c.checkFlage) != c.result {
+			t.Errorf("test case #%d got %t, want %t", i, c.baseFlag.IsAnyEnable(c.checkFlage), c.result)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	got := (SFFullNode | SFCompactFilters | 1<<40).Names()
+	if len(got) != 2 || got[0] != "full_node" || got[1] != "compact_filters" {
+		t.Errorf("got service names %v", got)
+	}
+}
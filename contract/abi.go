@@ -0,0 +1,258 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+
+	"kuskcore/blockchain/txbuilder"
+	"kuskcore/errors"
+	"kuskcore/protocol/vm"
+)
+
+// the types of the params, they are the same as the contract language
+const (
+	AmountType    = "Amount"
+	AssetType     = "Asset"
+	BooleanType   = "Boolean"
+	HashType      = "Hash"
+	IntegerType   = "Integer"
+	ProgramType   = "Program"
+	PublicKeyType = "PublicKey"
+	SignatureType = "Signature"
+	StringType    = "String"
+)
+
+var abiTypes = map[string]bool{
+	AmountType:    true,
+	AssetType:     true,
+	BooleanType:   true,
+	HashType:      true,
+	IntegerType:   true,
+	ProgramType:   true,
+	PublicKeyType: true,
+	SignatureType: true,
+	StringType:    true,
+}
+
+// pre-define errors for supporting kusk errorFormatter
+var (
+	ErrBadABI         = errors.New("invalid contract abi")
+	ErrMissingABI     = errors.New("contract has no abi")
+	ErrClauseNotFound = errors.New("contract clause not found")
+	ErrBadABIArgs     = errors.New("invalid contract call arguments")
+)
+
+// ABI describes the layout of the state data and the clauses of a contract,
+// it's compatible with the output of the contract compiler.
+//
+// The params are supplied as the state data of the contract output in order.
+// The clause params are supplied as the witness arguments of the spending
+// input in order, followed by the selector when there are more than one clause.
+type ABI struct {
+	Params  []*ABIParam  `json:"params"`
+	Clauses []*ABIClause `json:"clauses"`
+}
+
+// ABIParam is a typed param of the contract or a clause
+type ABIParam struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ABIClause is a way to spend the contract
+type ABIClause struct {
+	Name     string      `json:"name"`
+	Selector uint64      `json:"selector"`
+	Params   []*ABIParam `json:"params"`
+}
+
+// Validate checks the types and the names of the abi
+func (abi *ABI) Validate() error {
+	if err := validateParams(abi.Params); err != nil {
+		return errors.Wrap(err, "contract params")
+	}
+
+	if len(abi.Clauses) == 0 {
+		return errors.WithDetail(ErrBadABI, "contract has no clause")
+	}
+
+	names, selectors := make(map[string]bool), make(map[uint64]bool)
+	for _, clause := range abi.Clauses {
+		if clause.Name == "" || names[clause.Name] {
+			return errors.WithDetailf(ErrBadABI, "empty or duplicated clause name %q", clause.Name)
+		}
+
+		if selectors[clause.Selector] {
+			return errors.WithDetailf(ErrBadABI, "duplicated selector %d of clause %s", clause.Selector, clause.Name)
+		}
+
+		if err := validateParams(clause.Params); err != nil {
+			return errors.Wrapf(err, "clause %s", clause.Name)
+		}
+
+		names[clause.Name], selectors[clause.Selector] = true, true
+	}
+	return nil
+}
+
+func validateParams(params []*ABIParam) error {
+	names := make(map[string]bool)
+	for _, param := range params {
+		if param.Name == "" || names[param.Name] {
+			return errors.WithDetailf(ErrBadABI, "empty or duplicated param name %q", param.Name)
+		}
+
+		if !abiTypes[param.Type] {
+			return errors.WithDetailf(ErrBadABI, "unknown type %q of param %s", param.Type, param.Name)
+		}
+		names[param.Name] = true
+	}
+	return nil
+}
+
+// Clause returns the clause by the name
+func (abi *ABI) Clause(name string) (*ABIClause, error) {
+	for _, clause := range abi.Clauses {
+		if clause.Name == name {
+			return clause, nil
+		}
+	}
+	return nil, errors.WithDetailf(ErrClauseNotFound, "clause %q", name)
+}
+
+// Arguments converts the named values into the contract arguments of the
+// clause. The values of Boolean and numeric params are JSON booleans and
+// numbers, the others are hex strings. The value of a Signature param can
+// also be an object of xpub and derivation_path, the signature is made
+// when signing the transaction.
+func (abi *ABI) Arguments(clauseName string, values map[string]json.RawMessage) ([]txbuilder.ContractArgument, error) {
+	clause, err := abi.Clause(clauseName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) != len(clause.Params) {
+		return nil, errors.WithDetailf(ErrBadABIArgs, "clause %s needs %d arguments, got %d", clause.Name, len(clause.Params), len(values))
+	}
+
+	var args []txbuilder.ContractArgument
+	for _, param := range clause.Params {
+		value, ok := values[param.Name]
+		if !ok {
+			return nil, errors.WithDetailf(ErrBadABIArgs, "missing argument %s of clause %s", param.Name, clause.Name)
+		}
+
+		arg, err := contractArgument(param, value)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	if len(abi.Clauses) > 1 {
+		selector, err := json.Marshal(txbuilder.IntegerArgument{Value: clause.Selector})
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, txbuilder.ContractArgument{Type: "integer", RawData: selector})
+	}
+	return args, nil
+}
+
+func contractArgument(param *ABIParam, value json.RawMessage) (txbuilder.ContractArgument, error) {
+	var (
+		arg    txbuilder.ContractArgument
+		target interface{}
+	)
+	switch {
+	case param.Type == BooleanType:
+		arg.Type, target = "boolean", &txbuilder.BoolArgument{}
+	case param.Type == AmountType || param.Type == IntegerType:
+		arg.Type, target = "integer", &txbuilder.IntegerArgument{}
+	case param.Type == SignatureType && bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")):
+		arg.Type, target = "raw_tx_signature", &txbuilder.RawTxSigArgument{}
+	default:
+		arg.Type, target = "data", &txbuilder.DataArgument{}
+	}
+
+	// all the arguments except raw_tx_signature are in the form of {"value": ...}
+	if arg.Type != "raw_tx_signature" {
+		value = json.RawMessage(`{"value":` + string(value) + `}`)
+	}
+
+	if err := json.Unmarshal(value, target); err != nil {
+		return arg, errors.WithDetailf(ErrBadABIArgs, "argument %s should be %s", param.Name, param.Type)
+	}
+
+	var err error
+	arg.RawData, err = json.Marshal(target)
+	return arg, err
+}
+
+// DecodeStateData decodes the state data of the contract output into named fields
+func (abi *ABI) DecodeStateData(stateData [][]byte) (map[string]interface{}, error) {
+	if len(stateData) != len(abi.Params) {
+		return nil, errors.WithDetailf(ErrBadABIArgs, "contract needs %d state data, got %d", len(abi.Params), len(stateData))
+	}
+
+	return decodeFields(abi.Params, stateData)
+}
+
+// DecodeArguments decodes the witness arguments of the spending input into
+// the clause name and the named fields
+func (abi *ABI) DecodeArguments(arguments [][]byte) (string, map[string]interface{}, error) {
+	clause := abi.Clauses[0]
+	if len(abi.Clauses) > 1 {
+		if len(arguments) == 0 {
+			return "", nil, errors.WithDetail(ErrBadABIArgs, "missing clause selector")
+		}
+
+		selector, err := vm.AsBigInt(arguments[len(arguments)-1])
+		if err != nil || !selector.IsUint64() {
+			return "", nil, errors.WithDetail(ErrBadABIArgs, "invalid clause selector")
+		}
+
+		if clause = abi.clauseBySelector(selector.Uint64()); clause == nil {
+			return "", nil, errors.WithDetailf(ErrClauseNotFound, "selector %d", selector.Uint64())
+		}
+		arguments = arguments[:len(arguments)-1]
+	}
+
+	if len(arguments) != len(clause.Params) {
+		return "", nil, errors.WithDetailf(ErrBadABIArgs, "clause %s needs %d arguments, got %d", clause.Name, len(clause.Params), len(arguments))
+	}
+
+	fields, err := decodeFields(clause.Params, arguments)
+	return clause.Name, fields, err
+}
+
+func (abi *ABI) clauseBySelector(selector uint64) *ABIClause {
+	for _, clause := range abi.Clauses {
+		if clause.Selector == selector {
+			return clause
+		}
+	}
+	return nil
+}
+
+func decodeFields(params []*ABIParam, data [][]byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for i, param := range params {
+		switch param.Type {
+		case BooleanType:
+			fields[param.Name] = vm.AsBool(data[i])
+
+		case AmountType, IntegerType:
+			n, err := vm.AsBigInt(data[i])
+			if err != nil || !n.IsUint64() {
+				return nil, errors.WithDetailf(ErrBadABIArgs, "field %s is not a valid %s", param.Name, param.Type)
+			}
+			fields[param.Name] = n.Uint64()
+
+		default:
+			fields[param.Name] = hex.EncodeToString(data[i])
+		}
+	}
+	return fields, nil
+}
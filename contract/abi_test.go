@@ -0,0 +1,102 @@
+package contract
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"kuskcore/blockchain/txbuilder"
+	"kuskcore/errors"
+	"kuskcore/protocol/vm"
+)
+
+var escrowABI = &ABI{
+	Params: []*ABIParam{{Name: "agent", Type: PublicKeyType}, {Name: "amount", Type: AmountType}, {Name: "open", Type: BooleanType}},
+	Clauses: []*ABIClause{
+		{Name: "approve", Selector: 0, Params: []*ABIParam{{Name: "sig", Type: SignatureType}}},
+		{Name: "reject", Selector: 1, Params: []*ABIParam{{Name: "sig", Type: SignatureType}, {Name: "fee", Type: IntegerType}}},
+	},
+}
+
+func TestABIValidate(t *testing.T) {
+	cases := []struct {
+		abi     *ABI
+		wantErr error
+	}{
+		{abi: escrowABI},
+		{abi: &ABI{}, wantErr: ErrBadABI},
+		{abi: &ABI{Params: []*ABIParam{{Name: "a", Type: "Unknown"}}, Clauses: escrowABI.Clauses}, wantErr: ErrBadABI},
+		{abi: &ABI{Clauses: []*ABIClause{{Name: "a"}, {Name: "a", Selector: 1}}}, wantErr: ErrBadABI},
+		{abi: &ABI{Clauses: []*ABIClause{{Name: "a"}, {Name: "b"}}}, wantErr: ErrBadABI},
+	}
+
+	for i, c := range cases {
+		if err := c.abi.Validate(); errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+	}
+}
+
+func TestABIArguments(t *testing.T) {
+	values := map[string]json.RawMessage{
+		"sig": json.RawMessage(`{"xpub": "3e5d7d52d334964eef173021ef6a04dc0807ac8c41700fe718f5a80c2109f79c7a5e8b4a6a2d7bad9ddd3d6b89ba2a6d5a2e0c8dd3b1e0d4f5d8a6a4c1a7e9b0", "derivation_path": ["010100000000000000"]}`),
+		"fee": json.RawMessage(`100`),
+	}
+
+	args, err := escrowABI.Arguments("reject", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	for _, arg := range args {
+		types = append(types, arg.Type)
+	}
+
+	if want := []string{"raw_tx_signature", "integer", "integer"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("got argument types %v, want %v", types, want)
+	}
+
+	selector := &txbuilder.IntegerArgument{}
+	if err := json.Unmarshal(args[2].RawData, selector); err != nil || selector.Value != 1 {
+		t.Errorf("got selector %v, want 1", selector.Value)
+	}
+
+	values["sig"] = json.RawMessage(`"0102"`)
+	if args, err = escrowABI.Arguments("reject", values); err != nil || args[0].Type != "data" {
+		t.Errorf("got signature argument %v with error %v, want data", args, err)
+	}
+
+	values["fee"] = json.RawMessage(`"0102"`)
+	if _, err := escrowABI.Arguments("reject", values); errors.Root(err) != ErrBadABIArgs {
+		t.Errorf("got error %v with bad integer, want %v", err, ErrBadABIArgs)
+	}
+
+	if _, err := escrowABI.Arguments("refund", values); errors.Root(err) != ErrClauseNotFound {
+		t.Errorf("got error %v with unknown clause, want %v", err, ErrClauseNotFound)
+	}
+}
+
+func TestABIDecode(t *testing.T) {
+	stateData, err := escrowABI.DecodeStateData([][]byte{{0xaa, 0xbb}, vm.Uint64Bytes(300), vm.BoolBytes(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]interface{}{"agent": "aabb", "amount": uint64(300), "open": true}; !reflect.DeepEqual(stateData, want) {
+		t.Errorf("got state data %v, want %v", stateData, want)
+	}
+
+	clause, arguments, err := escrowABI.DecodeArguments([][]byte{{0x01}, vm.Uint64Bytes(7), vm.Uint64Bytes(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]interface{}{"sig": "01", "fee": uint64(7)}; clause != "reject" || !reflect.DeepEqual(arguments, want) {
+		t.Errorf("got clause %s arguments %v, want reject %v", clause, arguments, want)
+	}
+
+	if _, _, err := escrowABI.DecodeArguments([][]byte{{0x01}, vm.Uint64Bytes(2)}); errors.Root(err) != ErrClauseNotFound {
+		t.Errorf("got error %v with unknown selector, want %v", err, ErrClauseNotFound)
+	}
+}
@@ -0,0 +1,87 @@
+package contract
+
+import (
+	"context"
+	"encoding/json"
+
+	"kuskcore/blockchain/txbuilder"
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+)
+
+// DecodeCallContractAction returns the decoder of call contract action, the
+// contract UTXO is spent by the spend_account_unspent_output action decoded
+// by decodeSpendUTXO with the witness arguments built from the abi
+func (reg *Registry) DecodeCallContractAction(decodeSpendUTXO func([]byte) (txbuilder.Action, error)) func([]byte) (txbuilder.Action, error) {
+	return func(data []byte) (txbuilder.Action, error) {
+		a := &callContractAction{registry: reg, decodeSpendUTXO: decodeSpendUTXO}
+		return a, json.Unmarshal(data, a)
+	}
+}
+
+type callContractAction struct {
+	registry        *Registry
+	decodeSpendUTXO func([]byte) (txbuilder.Action, error)
+	ContractID      chainjson.HexBytes         `json:"contract_id"`
+	OutputID        *bc.Hash                   `json:"output_id"`
+	UseUnconfirmed  bool                       `json:"use_unconfirmed"`
+	Clause          string                     `json:"clause"`
+	Arguments       map[string]json.RawMessage `json:"arguments"`
+}
+
+func (a *callContractAction) ActionType() string {
+	return "call_contract"
+}
+
+func (a *callContractAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
+	var missing []string
+	if len(a.ContractID) == 0 {
+		missing = append(missing, "contract_id")
+	}
+	if a.OutputID == nil {
+		missing = append(missing, "output_id")
+	}
+	if a.Clause == "" {
+		missing = append(missing, "clause")
+	}
+	if len(missing) > 0 {
+		return txbuilder.MissingFieldsError(missing...)
+	}
+
+	contract, err := a.registry.GetContract(a.ContractID)
+	if err != nil {
+		return err
+	}
+
+	if contract.ABI == nil {
+		return errors.WithDetailf(ErrMissingABI, "contract %x", []byte(a.ContractID))
+	}
+
+	// the arguments must not be nil, or the UTXO will be signed by the account
+	arguments := []txbuilder.ContractArgument{}
+	args, err := contract.ABI.Arguments(a.Clause, a.Arguments)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(struct {
+		OutputID       *bc.Hash                     `json:"output_id"`
+		UseUnconfirmed bool                         `json:"use_unconfirmed"`
+		Arguments      []txbuilder.ContractArgument `json:"arguments"`
+	}{
+		OutputID:       a.OutputID,
+		UseUnconfirmed: a.UseUnconfirmed,
+		Arguments:      append(arguments, args...),
+	})
+	if err != nil {
+		return err
+	}
+
+	spendAction, err := a.decodeSpendUTXO(data)
+	if err != nil {
+		return err
+	}
+
+	return spendAction.Build(ctx, b)
+}
@@ -44,6 +44,7 @@ type Contract struct {
 	Contract        chainjson.HexBytes `json:"contract"`
 	CallProgram     chainjson.HexBytes `json:"call_program"`
 	RegisterProgram chainjson.HexBytes `json:"register_program"`
+	ABI             *ABI               `json:"abi,omitempty"`
 }
 
 // SaveContract save user contract
@@ -0,0 +1,45 @@
+package contract
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"kuskcore/protocol/bc"
+)
+
+// InstanceEventType is the lifecycle state transition of a traced instance
+type InstanceEventType string
+
+// the lifecycle events of the traced instances
+const (
+	InstanceCreated     InstanceEventType = "instance_created"
+	InstanceTransferred InstanceEventType = "instance_transferred"
+	InstanceEnded       InstanceEventType = "instance_ended"
+	InstanceRolledBack  InstanceEventType = "instance_rolled_back"
+)
+
+// InstanceEvent is posted on the event dispatcher when the trace service
+// changes the state of an instance, the block is the one applied or detached
+type InstanceEvent struct {
+	Type        InstanceEventType `json:"type"`
+	Instance    *Instance         `json:"instance"`
+	BlockHeight uint64            `json:"block_height"`
+	BlockHash   bc.Hash           `json:"block_hash"`
+}
+
+func (t *TraceService) postInstanceEvents(typ InstanceEventType, instances []*Instance, blockHeight uint64, blockHash bc.Hash) {
+	if t.infra.Dispatcher == nil {
+		return
+	}
+
+	for _, inst := range instances {
+		eventType := typ
+		if typ == InstanceTransferred && inst.Status == Ended {
+			eventType = InstanceEnded
+		}
+
+		event := InstanceEvent{Type: eventType, Instance: inst, BlockHeight: blockHeight, BlockHash: blockHash}
+		if err := t.infra.Dispatcher.Post(event); err != nil {
+			log.WithFields(log.Fields{"module": logModule, "err": err, "trace_id": inst.TraceID}).Error("post contract instance event")
+		}
+	}
+}
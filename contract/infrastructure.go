@@ -1,6 +1,7 @@
 package contract
 
 import (
+	"kuskcore/event"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 )
@@ -8,10 +9,11 @@ import (
 type Infrastructure struct {
 	Chain      ChainService
 	Repository Repository
+	Dispatcher *event.Dispatcher
 }
 
-func NewInfrastructure(chain ChainService, repository Repository) *Infrastructure {
-	return &Infrastructure{Chain: chain, Repository: repository}
+func NewInfrastructure(chain ChainService, repository Repository, dispatcher *event.Dispatcher) *Infrastructure {
+	return &Infrastructure{Chain: chain, Repository: repository, Dispatcher: dispatcher}
 }
 
 type ChainService interface {
@@ -1,7 +1,12 @@
 package contract
 
 import (
+	"bytes"
+
 	"github.com/google/uuid"
+
+	"kuskcore/consensus/bcrp"
+	"kuskcore/crypto/sha3pool"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 )
@@ -75,6 +80,26 @@ func (i *Instance) rollbackTo(t *transfer) *Instance {
 	}
 }
 
+// hasContract reports whether the utxos are locked by the contract, both the
+// contract program and the call program of the registered contract match
+func (i *Instance) hasContract(hash []byte) bool {
+	for _, utxo := range i.UTXOs {
+		if bcrp.IsCallContractScript(utxo.Program) {
+			if contractHash, err := bcrp.ParseContractHash(utxo.Program); err == nil && bytes.Equal(contractHash[:], hash) {
+				return true
+			}
+			continue
+		}
+
+		var programHash [32]byte
+		sha3pool.Sum256(programHash[:], utxo.Program)
+		if bytes.Equal(programHash[:], hash) {
+			return true
+		}
+	}
+	return false
+}
+
 func (i *Instance) confirmTx(txHash bc.Hash) {
 	for _, node := range i.Unconfirmed {
 		if node.TxHash == txHash {
@@ -6,6 +6,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	chainjson "kuskcore/encoding/json"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 )
@@ -102,7 +103,12 @@ func (t *TraceService) ApplyBlock(block *types.Block) error {
 	t.processEndedInstances(newInstances)
 	t.bestHeight++
 	t.bestHash = block.Hash()
-	return t.infra.Repository.SaveInstancesWithStatus(newInstances, t.bestHeight, t.bestHash)
+	if err := t.infra.Repository.SaveInstancesWithStatus(newInstances, t.bestHeight, t.bestHash); err != nil {
+		return err
+	}
+
+	t.postInstanceEvents(InstanceTransferred, newInstances, block.Height, t.bestHash)
+	return nil
 }
 
 func (t *TraceService) DetachBlock(block *types.Block) error {
@@ -113,7 +119,12 @@ func (t *TraceService) DetachBlock(block *types.Block) error {
 	t.processEndedInstances(nil)
 	t.bestHeight--
 	t.bestHash = block.PreviousBlockHash
-	return t.infra.Repository.SaveInstancesWithStatus(newInstances, t.bestHeight, t.bestHash)
+	if err := t.infra.Repository.SaveInstancesWithStatus(newInstances, t.bestHeight, t.bestHash); err != nil {
+		return err
+	}
+
+	t.postInstanceEvents(InstanceRolledBack, newInstances, block.Height, block.Hash())
+	return nil
 }
 
 func (t *TraceService) AddUnconfirmedTx(tx *types.Tx) {
@@ -165,6 +176,8 @@ func (t *TraceService) CreateInstance(txHash, blockHash bc.Hash) ([]string, erro
 		if err := t.addNewTraceJob(inst); err != nil {
 			return nil, err
 		}
+
+		t.postInstanceEvents(InstanceCreated, []*Instance{inst}, block.Height, blockHash)
 	}
 	return traceIDs, nil
 }
@@ -182,6 +195,47 @@ func (t *TraceService) GetInstance(traceID string) (*Instance, error) {
 	return t.infra.Repository.GetInstance(traceID)
 }
 
+// InstanceFilter selects the listed instances, the zero fields match all
+type InstanceFilter struct {
+	Status         Status             `json:"status"`
+	ContractHash   chainjson.HexBytes `json:"contract_hash"`
+	MinEndedHeight uint64             `json:"min_ended_height"`
+	MaxEndedHeight uint64             `json:"max_ended_height"`
+}
+
+func (f *InstanceFilter) match(inst *Instance) bool {
+	if f.Status != 0 && inst.Status != f.Status {
+		return false
+	}
+
+	if f.MinEndedHeight != 0 || f.MaxEndedHeight != 0 {
+		if inst.Status != Ended || inst.EndedHeight < f.MinEndedHeight {
+			return false
+		}
+
+		if f.MaxEndedHeight != 0 && inst.EndedHeight > f.MaxEndedHeight {
+			return false
+		}
+	}
+	return len(f.ContractHash) == 0 || inst.hasContract(f.ContractHash)
+}
+
+// ListInstances return the stored instances selected by the filter
+func (t *TraceService) ListInstances(filter *InstanceFilter) ([]*Instance, error) {
+	instances, err := t.infra.Repository.LoadInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Instance{}
+	for _, inst := range instances {
+		if filter.match(inst) {
+			result = append(result, inst)
+		}
+	}
+	return result, nil
+}
+
 func (t *TraceService) takeOverInstances(instances []*Instance, blockHash bc.Hash) bool {
 	t.Lock()
 	defer t.Unlock()
@@ -0,0 +1,80 @@
+package contract
+
+import (
+	"testing"
+	"time"
+
+	"kuskcore/crypto/sha3pool"
+	"kuskcore/event"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/vm/vmutil"
+)
+
+func TestInstanceFilter(t *testing.T) {
+	code := []byte{0x51}
+	var hash [32]byte
+	sha3pool.Sum256(hash[:], code)
+	callProgram, err := vmutil.CallContractProgram(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inSync := &Instance{TraceID: "1", Status: InSync, UTXOs: []*UTXO{{Program: callProgram}}}
+	ended := &Instance{TraceID: "2", Status: Ended, EndedHeight: 100, UTXOs: []*UTXO{{Program: code}}}
+	lagging := &Instance{TraceID: "3", Status: Lagging, UTXOs: []*UTXO{{Program: []byte{0x52}}}}
+	instances := []*Instance{inSync, ended, lagging}
+
+	cases := []struct {
+		filter *InstanceFilter
+		want   []*Instance
+	}{
+		{filter: &InstanceFilter{}, want: instances},
+		{filter: &InstanceFilter{Status: InSync}, want: []*Instance{inSync}},
+		{filter: &InstanceFilter{ContractHash: hash[:]}, want: []*Instance{inSync, ended}},
+		{filter: &InstanceFilter{MinEndedHeight: 50}, want: []*Instance{ended}},
+		{filter: &InstanceFilter{MinEndedHeight: 50, MaxEndedHeight: 99}, want: nil},
+		{filter: &InstanceFilter{Status: Lagging, ContractHash: hash[:]}, want: nil},
+	}
+
+	for i, c := range cases {
+		var got []*Instance
+		for _, inst := range instances {
+			if c.filter.match(inst) {
+				got = append(got, inst)
+			}
+		}
+
+		if len(got) != len(c.want) {
+			t.Fatalf("case %d: got %d instances, want %d", i, len(got), len(c.want))
+		}
+
+		for j := range got {
+			if got[j] != c.want[j] {
+				t.Errorf("case %d: got instance %s, want %s", i, got[j].TraceID, c.want[j].TraceID)
+			}
+		}
+	}
+}
+
+func TestPostInstanceEvents(t *testing.T) {
+	dispatcher := event.NewDispatcher()
+	sub, err := dispatcher.Subscribe(InstanceEvent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &TraceService{infra: &Infrastructure{Dispatcher: dispatcher}}
+	instances := []*Instance{{TraceID: "1", Status: InSync}, {TraceID: "2", Status: Ended}}
+	service.postInstanceEvents(InstanceTransferred, instances, 10, bc.Hash{})
+
+	for _, want := range []InstanceEventType{InstanceTransferred, InstanceEnded} {
+		select {
+		case obj := <-sub.Chan():
+			if ev := obj.Data.(InstanceEvent); ev.Type != want || ev.BlockHeight != 10 {
+				t.Errorf("got event %s at %d, want %s at 10", ev.Type, ev.BlockHeight, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %s is not posted", want)
+		}
+	}
+}
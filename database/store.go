@@ -144,6 +144,9 @@ func (s *Store) SaveBlock(block *types.Block) error {
 	batch.Set(CalcBlockHashesKey(block.Height), binaryBlockHashes)
 	batch.Set(CalcBlockHeaderKey(&blockHash), binaryBlockHeader)
 	batch.Set(CalcBlockTransactionsKey(&blockHash), binaryBlockTxs)
+	if err := s.saveBlockFilter(batch, block); err != nil {
+		return err
+	}
 	batch.Write()
 
 	s.cache.removeBlockHashes(block.Height)
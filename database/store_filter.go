@@ -0,0 +1,153 @@
+package database
+
+import (
+	"encoding/binary"
+
+	"kuskcore/blockchain/gcs"
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+// maxBackfillBlocks is the max number of the blocks visited by backfilling
+// the filters each time a block is saved
+const maxBackfillBlocks = 100
+
+var (
+	blockFilterCursorKey = []byte("blockFilterCursor")
+
+	errNotFoundBlockFilter = errors.New("can't find the block filter in db")
+)
+
+func calcBlockFilterKey(hash *bc.Hash) []byte {
+	return append(blockFilterKeyPrefix, hash.Bytes()...)
+}
+
+func calcBlockFilterHeaderKey(hash *bc.Hash) []byte {
+	return append(blockFilterHeaderPrefix, hash.Bytes()...)
+}
+
+func getBlockFilterHeader(db dbm.DB, hash *bc.Hash) (*bc.Hash, error) {
+	data := db.Get(calcBlockFilterHeaderKey(hash))
+	if data == nil {
+		return nil, errors.WithDetailf(errNotFoundBlockFilter, "block hash %s", hash.String())
+	}
+
+	var header [32]byte
+	copy(header[:], data)
+	filterHeader := bc.NewHash(header)
+	return &filterHeader, nil
+}
+
+// GetBlockFilter return the serialized compact filter of the block
+func (s *Store) GetBlockFilter(hash *bc.Hash) ([]byte, error) {
+	data := s.db.Get(calcBlockFilterKey(hash))
+	if data == nil {
+		return nil, errors.WithDetailf(errNotFoundBlockFilter, "block hash %s", hash.String())
+	}
+	return data, nil
+}
+
+// GetBlockFilterHeader return the filter header chained to the block
+func (s *Store) GetBlockFilterHeader(hash *bc.Hash) (*bc.Hash, error) {
+	return getBlockFilterHeader(s.db, hash)
+}
+
+// saveBlockFilter builds the filter of the block and chains it to the filter
+// header of the parent block, the filters are keyed by the block hash so each
+// fork keeps its own filter header chain. The filter of the block is left to
+// the backfill if the filter of the parent is not built yet.
+func (s *Store) saveBlockFilter(batch dbm.Batch, block *types.Block) error {
+	prevHeader := &bc.Hash{}
+	if block.Height > 0 {
+		header, err := getBlockFilterHeader(s.db, &block.PreviousBlockHash)
+		if err != nil {
+			if err := s.backfillBlockFilters(); err != nil {
+				return err
+			}
+
+			if header, err = getBlockFilterHeader(s.db, &block.PreviousBlockHash); err != nil {
+				return nil
+			}
+		}
+		prevHeader = header
+	}
+
+	_, err := saveBlockFilter(batch, block, prevHeader)
+	return err
+}
+
+// backfillBlockFilters builds the filters of the blocks saved before the
+// filters are supported. The blocks are backfilled by height from the
+// persisted cursor, at most maxBackfillBlocks blocks are visited each time so
+// saving a block isn't blocked by backfilling the whole chain.
+func (s *Store) backfillBlockFilters() error {
+	height := uint64(0)
+	if data := s.db.Get(blockFilterCursorKey); data != nil {
+		height = binary.BigEndian.Uint64(data)
+	}
+
+	batch := s.db.NewBatch()
+	// the filter headers of the blocks at the previous height
+	prevHeaders := map[bc.Hash]*bc.Hash{}
+	for num := 0; num < maxBackfillBlocks; height++ {
+		hashes, err := GetBlockHashesByHeight(s.db, height)
+		if err != nil {
+			return err
+		}
+
+		if len(hashes) == 0 {
+			break
+		}
+
+		headers := make(map[bc.Hash]*bc.Hash, len(hashes))
+		for _, hash := range hashes {
+			if header, err := getBlockFilterHeader(s.db, hash); err == nil {
+				headers[*hash] = header
+				continue
+			}
+
+			block, err := s.GetBlock(hash)
+			if err != nil {
+				return err
+			}
+
+			prevHeader := &bc.Hash{}
+			if height > 0 {
+				var ok bool
+				if prevHeader, ok = prevHeaders[block.PreviousBlockHash]; !ok {
+					if prevHeader, err = getBlockFilterHeader(s.db, &block.PreviousBlockHash); err != nil {
+						return err
+					}
+				}
+			}
+
+			if headers[*hash], err = saveBlockFilter(batch, block, prevHeader); err != nil {
+				return err
+			}
+		}
+
+		prevHeaders = headers
+		num += len(hashes)
+	}
+
+	buf := [8]byte{}
+	binary.BigEndian.PutUint64(buf[:], height)
+	batch.Set(blockFilterCursorKey, buf[:])
+	batch.Write()
+	return nil
+}
+
+func saveBlockFilter(batch dbm.Batch, block *types.Block, prevHeader *bc.Hash) (*bc.Hash, error) {
+	filter, err := gcs.BuildBlockFilter(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "build block filter")
+	}
+
+	blockHash := block.Hash()
+	header := gcs.FilterHeader(filter, prevHeader)
+	batch.Set(calcBlockFilterKey(&blockHash), filter.Bytes())
+	batch.Set(calcBlockFilterHeaderKey(&blockHash), header.Bytes())
+	return &header, nil
+}
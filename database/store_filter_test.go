@@ -0,0 +1,152 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+
+	"kuskcore/blockchain/gcs"
+	"kuskcore/config"
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+func mockChildBlock(parent *types.Block, program []byte) *types.Block {
+	return &types.Block{
+		BlockHeader: types.BlockHeader{
+			Version:           1,
+			Height:            parent.Height + 1,
+			PreviousBlockHash: parent.Hash(),
+			Timestamp:         parent.Timestamp + 1 + uint64(program[0]),
+		},
+		Transactions: []*types.Tx{
+			types.NewTx(types.TxData{
+				Version: 1,
+				Inputs:  []*types.TxInput{types.NewCoinbaseInput(program)},
+				Outputs: []*types.TxOutput{types.NewOriginalTxOutput(bc.AssetID{V0: 1}, 100, program, nil)},
+			}),
+		},
+	}
+}
+
+func TestSaveBlockFilter(t *testing.T) {
+	store := NewStore(dbm.NewMemDB())
+	genesis := config.GenesisBlock()
+	mainBlock := mockChildBlock(genesis, []byte{1})
+	forkBlock := mockChildBlock(genesis, []byte{2})
+	for _, block := range []*types.Block{genesis, mainBlock, forkBlock} {
+		if err := store.SaveBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	genesisHash := genesis.Hash()
+	genesisHeader, err := store.GetBlockFilterHeader(&genesisHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		block   *types.Block
+		program []byte
+	}{
+		{block: mainBlock, program: []byte{1}},
+		{block: forkBlock, program: []byte{2}},
+	} {
+		blockHash := c.block.Hash()
+		data, err := store.GetBlockFilter(&blockHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filter, err := gcs.FromBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if match, err := filter.Match(gcs.BlockFilterKey(&blockHash), c.program); err != nil || !match {
+			t.Fatalf("block %d: got match %v, error %v", c.block.Height, match, err)
+		}
+
+		header, err := store.GetBlockFilterHeader(&blockHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := gcs.FilterHeader(filter, genesisHeader); *header != want {
+			t.Fatalf("got filter header %s, want %s", header.String(), want.String())
+		}
+	}
+
+	unknownHash := bc.Hash{V0: 1}
+	if _, err := store.GetBlockFilter(&unknownHash); errors.Root(err) != errNotFoundBlockFilter {
+		t.Fatalf("got error %v, want %v", err, errNotFoundBlockFilter)
+	}
+}
+
+func TestBackfillBlockFilters(t *testing.T) {
+	store := NewStore(dbm.NewMemDB())
+	blocks := []*types.Block{config.GenesisBlock()}
+	for i := 0; i < maxBackfillBlocks+50; i++ {
+		blocks = append(blocks, mockChildBlock(blocks[i], []byte{byte(i)}))
+	}
+
+	// the fork block is backfilled with the main chain
+	blocks = append(blocks, mockChildBlock(blocks[maxBackfillBlocks/2], []byte{0xff}))
+	numSaved := len(blocks)
+	for _, block := range blocks {
+		if err := store.SaveBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wantHeaders := [][]byte{}
+	for _, block := range blocks {
+		blockHash := block.Hash()
+		wantHeaders = append(wantHeaders, store.db.Get(calcBlockFilterHeaderKey(&blockHash)))
+	}
+
+	// the blocks are saved before the filters are supported
+	for _, block := range blocks {
+		blockHash := block.Hash()
+		store.db.Delete(calcBlockFilterKey(&blockHash))
+		store.db.Delete(calcBlockFilterHeaderKey(&blockHash))
+	}
+
+	hasFilter := func(block *types.Block) bool {
+		blockHash := block.Hash()
+		_, err := store.GetBlockFilter(&blockHash)
+		return err == nil
+	}
+
+	// the backfill is bounded, the filter of the saved block is left to the
+	// backfill since the filter of the parent is missing
+	blocks = append(blocks, mockChildBlock(blocks[numSaved-2], []byte{1}))
+	if err := store.SaveBlock(blocks[numSaved]); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasFilter(blocks[maxBackfillBlocks-2]) || hasFilter(blocks[maxBackfillBlocks+10]) || hasFilter(blocks[numSaved]) {
+		t.Fatal("the backfill of the filters isn't bounded")
+	}
+
+	// the backfill continues from the cursor
+	blocks = append(blocks, mockChildBlock(blocks[numSaved], []byte{2}))
+	if err := store.SaveBlock(blocks[numSaved+1]); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, block := range blocks[:numSaved] {
+		blockHash := block.Hash()
+		if got := store.db.Get(calcBlockFilterHeaderKey(&blockHash)); !bytes.Equal(got, wantHeaders[i]) {
+			t.Fatalf("block %d: got backfilled filter header %x, want %x", i, got, wantHeaders[i])
+		}
+	}
+
+	for _, block := range blocks[numSaved:] {
+		if !hasFilter(block) {
+			t.Fatalf("block %d: the filter is not built", block.Height)
+		}
+	}
+}
@@ -20,6 +20,8 @@ const (
 	checkpoint
 	utxo
 	contract
+	blockFilter
+	blockFilterHeader
 )
 
 var (
@@ -31,6 +33,8 @@ var (
 	checkpointKeyPrefix     = []byte{checkpoint, colon}
 	UtxoKeyPrefix           = []byte{utxo, colon}
 	ContractPrefix          = []byte{contract, colon}
+	blockFilterKeyPrefix    = []byte{blockFilter, colon}
+	blockFilterHeaderPrefix = []byte{blockFilterHeader, colon}
 )
 
 func calcMainChainIndexPrefix(height uint64) []byte {
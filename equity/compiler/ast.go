@@ -0,0 +1,217 @@
+package compiler
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the types can be used by contract params, clause params and defines
+const (
+	amountType    = "Amount"
+	assetType     = "Asset"
+	booleanType   = "Boolean"
+	hashType      = "Hash"
+	integerType   = "Integer"
+	programType   = "Program"
+	publicKeyType = "PublicKey"
+	signatureType = "Signature"
+	stringType    = "String"
+
+	// bytesType is the type of hex literals, it can be assigned to any bytes type
+	bytesType = "Bytes"
+)
+
+var types = map[string]bool{
+	amountType:    true,
+	assetType:     true,
+	booleanType:   true,
+	hashType:      true,
+	integerType:   true,
+	programType:   true,
+	publicKeyType: true,
+	signatureType: true,
+	stringType:    true,
+}
+
+func isNumericType(typ string) bool {
+	return typ == amountType || typ == integerType
+}
+
+func isBytesType(typ string) bool {
+	switch typ {
+	case assetType, hashType, programType, publicKeyType, signatureType, stringType, bytesType:
+		return true
+	}
+	return false
+}
+
+// assignable return whether the value of type got can be used as type want
+func assignable(want, got string) bool {
+	if want == got {
+		return true
+	}
+
+	if isNumericType(want) && isNumericType(got) {
+		return true
+	}
+	return got == bytesType && isBytesType(want)
+}
+
+type contract struct {
+	name    string
+	params  []*param
+	value   valueInfo
+	clauses []*clause
+}
+
+type param struct {
+	name string
+	typ  string
+}
+
+type valueInfo struct {
+	amount string
+	asset  string
+}
+
+type clause struct {
+	name       string
+	params     []*param
+	statements []statement
+}
+
+type statement interface {
+	pos() position
+}
+
+type verifyStatement struct {
+	position
+	expr expression
+}
+
+type lockStatement struct {
+	position
+	amount  expression
+	asset   expression
+	program expression
+}
+
+type unlockStatement struct {
+	position
+	amount expression
+	asset  expression
+}
+
+type defineStatement struct {
+	position
+	param *param
+	expr  expression
+}
+
+type expression interface {
+	pos() position
+	String() string
+}
+
+type binaryExpr struct {
+	position
+	op          string
+	left, right expression
+}
+
+func (e *binaryExpr) String() string {
+	return fmt.Sprintf("%s %s %s", e.left, e.op, e.right)
+}
+
+type unaryExpr struct {
+	position
+	op   string
+	expr expression
+}
+
+func (e *unaryExpr) String() string {
+	return e.op + e.expr.String()
+}
+
+type parenExpr struct {
+	position
+	expr expression
+}
+
+func (e *parenExpr) String() string {
+	return "(" + e.expr.String() + ")"
+}
+
+type callExpr struct {
+	position
+	fn   string
+	args []expression
+}
+
+func (e *callExpr) String() string {
+	var args []string
+	for _, arg := range e.args {
+		args = append(args, arg.String())
+	}
+	return fmt.Sprintf("%s(%s)", e.fn, strings.Join(args, ", "))
+}
+
+type varRef struct {
+	position
+	name string
+}
+
+func (e *varRef) String() string {
+	return e.name
+}
+
+type integerLiteral struct {
+	position
+	value uint64
+}
+
+func (e *integerLiteral) String() string {
+	return strconv.FormatUint(e.value, 10)
+}
+
+type booleanLiteral struct {
+	position
+	value bool
+}
+
+func (e *booleanLiteral) String() string {
+	return strconv.FormatBool(e.value)
+}
+
+type bytesLiteral struct {
+	position
+	value []byte
+}
+
+func (e *bytesLiteral) String() string {
+	return "0x" + hex.EncodeToString(e.value)
+}
+
+type stringLiteral struct {
+	position
+	value string
+}
+
+func (e *stringLiteral) String() string {
+	return strconv.Quote(e.value)
+}
+
+// position is the line and column of a token in the source
+type position struct {
+	line, col int
+}
+
+func (p position) pos() position {
+	return p
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%d:%d", p.line, p.col)
+}
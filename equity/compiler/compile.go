@@ -0,0 +1,600 @@
+// Package compiler compiles the Equity style contract language into BVM
+// programs which can be registered and called by BCRP.
+//
+// The contract params are supplied as the state data of the contract output,
+// in the order of declaration. The clause params are supplied as the arguments
+// of the spending input in the order of declaration, followed by the selector
+// of the clause when the contract has more than one clause. The n-th lock
+// statement executed by a clause checks the n-th output of the transaction.
+package compiler
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"kuskcore/crypto/sha3pool"
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/errors"
+	"kuskcore/protocol/vm"
+	"kuskcore/protocol/vm/vmutil"
+)
+
+// pre-define errors
+var (
+	ErrSyntax = errors.New("contract syntax error")
+	ErrType   = errors.New("contract type error")
+	ErrArgs   = errors.New("invalid contract arguments")
+)
+
+// Contract is the compiled contract with its ABI
+type Contract struct {
+	Name            string             `json:"name"`
+	Params          []*Param           `json:"params"`
+	Value           Value              `json:"value"`
+	Clauses         []*Clause          `json:"clauses"`
+	Opcodes         string             `json:"opcodes"`
+	Program         chainjson.HexBytes `json:"program"`
+	Hash            chainjson.HexBytes `json:"hash"`
+	RegisterProgram chainjson.HexBytes `json:"register_program"`
+	CallProgram     chainjson.HexBytes `json:"call_program"`
+}
+
+// Param is a typed param of the contract or a clause
+type Param struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Value is the names of the amount and asset locked by the contract
+type Value struct {
+	Amount string `json:"amount"`
+	Asset  string `json:"asset"`
+}
+
+// Clause is a way to spend the contract
+type Clause struct {
+	Name     string   `json:"name"`
+	Selector uint64   `json:"selector"`
+	Params   []*Param `json:"params"`
+	Locks    []*Lock  `json:"locks,omitempty"`
+	Unlocks  []*Lock  `json:"unlocks,omitempty"`
+}
+
+// Lock describes an output the clause requires, or the value the clause unlocks
+type Lock struct {
+	Index   uint64 `json:"index"`
+	Amount  string `json:"amount"`
+	Asset   string `json:"asset"`
+	Program string `json:"program,omitempty"`
+}
+
+// Compile compiles the contract source into BVM program and ABI
+func Compile(src string) (*Contract, error) {
+	c, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Contract{
+		Name:  c.name,
+		Value: Value{Amount: c.value.amount, Asset: c.value.asset},
+	}
+	for _, p := range c.params {
+		result.Params = append(result.Params, &Param{Name: p.name, Type: p.typ})
+	}
+
+	g := &generator{contract: c}
+	if err := g.generate(result); err != nil {
+		return nil, err
+	}
+
+	if result.Program, err = vm.Assemble(result.Opcodes); err != nil {
+		return nil, errors.Wrap(err, "assemble contract")
+	}
+
+	var hash [32]byte
+	sha3pool.Sum256(hash[:], result.Program)
+	result.Hash = hash[:]
+	if result.RegisterProgram, err = vmutil.RegisterProgram(result.Program); err != nil {
+		return nil, err
+	}
+
+	if result.CallProgram, err = vmutil.CallContractProgram(hash[:]); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkOutputPredicate is the predicate program running CHECKOUTPUT on the 5 items
+// moved from the data stack
+var checkOutputPredicate = "0x" + hex.EncodeToString([]byte{byte(vm.OP_CHECKOUTPUT)})
+
+// builtin is a function can be called by the contract
+type builtin struct {
+	args   []string
+	result string
+}
+
+var builtins = map[string]builtin{
+	"checkTxSig": {[]string{publicKeyType, signatureType}, booleanType},
+	"sha3":       {[]string{bytesType}, hashType},
+	"sha256":     {[]string{bytesType}, hashType},
+	"size":       {[]string{bytesType}, integerType},
+	"min":        {[]string{integerType, integerType}, integerType},
+	"max":        {[]string{integerType, integerType}, integerType},
+	"above":      {[]string{integerType}, booleanType},
+	"below":      {[]string{integerType}, booleanType},
+}
+
+// generator emits the assembly of the contract, it tracks the names of the
+// items on the data stack to find the variables
+type generator struct {
+	contract *contract
+	opcodes  []string
+	stack    []string
+	vars     map[string]string // name -> type
+	locks    uint64
+}
+
+func (g *generator) emit(ops ...string) {
+	g.opcodes = append(g.opcodes, ops...)
+}
+
+func (g *generator) push(name string) {
+	g.stack = append(g.stack, name)
+}
+
+func (g *generator) pop(n int) {
+	g.stack = g.stack[:len(g.stack)-n]
+}
+
+func (g *generator) generate(result *Contract) error {
+	c := g.contract
+	if len(c.clauses) == 0 {
+		return errors.WithDetailf(ErrSyntax, "contract %s has no clause", c.name)
+	}
+
+	names := map[string]bool{c.value.amount: true, c.value.asset: true}
+	if c.value.amount == c.value.asset {
+		return errors.WithDetailf(ErrType, "duplicated name %s", c.value.amount)
+	}
+
+	for _, p := range c.params {
+		if names[p.name] || builtins[p.name].result != "" {
+			return errors.WithDetailf(ErrType, "duplicated name %s", p.name)
+		}
+		names[p.name] = true
+	}
+
+	// move the contract params from the alt stack, params[0] ends up on the top
+	for range c.params {
+		g.emit("FROMALTSTACK")
+	}
+
+	clauseNames := map[string]bool{}
+	for i, cl := range c.clauses {
+		if clauseNames[cl.name] {
+			return errors.WithDetailf(ErrType, "duplicated clause %s", cl.name)
+		}
+		clauseNames[cl.name] = true
+
+		if i == 0 && len(c.clauses) > 1 {
+			g.emitDispatch()
+		} else if i > 0 {
+			g.emit("$clause_" + cl.name)
+		}
+
+		abi, err := g.generateClause(cl, uint64(i), names)
+		if err != nil {
+			return err
+		}
+
+		if i != len(c.clauses)-1 {
+			g.emit("JUMP:$end")
+		}
+		result.Clauses = append(result.Clauses, abi)
+	}
+
+	if len(c.clauses) > 1 {
+		g.emit("$end")
+	}
+	result.Opcodes = strings.Join(g.opcodes, " ")
+	return nil
+}
+
+// emitDispatch jumps to the clause by the selector under the contract params,
+// falls through to the first clause when the selector is 0
+func (g *generator) emitDispatch() {
+	c := g.contract
+	if n := len(c.params); n != 0 {
+		g.emit(strconv.Itoa(n), "ROLL")
+	}
+
+	for i := len(c.clauses) - 1; i > 0; i-- {
+		g.emit("DUP", strconv.Itoa(i), "NUMEQUAL", "JUMPIF:$clause_"+c.clauses[i].name)
+	}
+	g.emit("0", "NUMEQUALVERIFY")
+}
+
+func (g *generator) generateClause(cl *clause, selector uint64, contractNames map[string]bool) (*Clause, error) {
+	c := g.contract
+	abi := &Clause{Name: cl.name, Selector: selector}
+	if selector != 0 {
+		g.emit("DROP")
+	}
+
+	g.stack, g.locks = nil, 0
+	g.vars = map[string]string{c.value.amount: amountType, c.value.asset: assetType}
+	for _, p := range cl.params {
+		if contractNames[p.name] || g.vars[p.name] != "" || builtins[p.name].result != "" {
+			return nil, errors.WithDetailf(ErrType, "clause %s: duplicated name %s", cl.name, p.name)
+		}
+
+		g.vars[p.name] = p.typ
+		g.push(p.name)
+		abi.Params = append(abi.Params, &Param{Name: p.name, Type: p.typ})
+	}
+
+	for i := len(c.params) - 1; i >= 0; i-- {
+		g.vars[c.params[i].name] = c.params[i].typ
+		g.push(c.params[i].name)
+	}
+
+	for _, stmt := range cl.statements {
+		if err := g.generateStatement(stmt, abi); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(abi.Locks) == 0 && len(abi.Unlocks) == 0 {
+		return nil, errors.WithDetailf(ErrType, "clause %s neither locks nor unlocks the value", cl.name)
+	}
+
+	g.emit("TRUE")
+	return abi, nil
+}
+
+func (g *generator) generateStatement(stmt statement, abi *Clause) error {
+	switch s := stmt.(type) {
+	case *verifyStatement:
+		if err := g.expectExpr(s.expr, booleanType); err != nil {
+			return err
+		}
+
+		g.emit("VERIFY")
+		g.pop(1)
+
+	case *defineStatement:
+		if g.vars[s.param.name] != "" || builtins[s.param.name].result != "" {
+			return errors.WithDetailf(ErrType, "%s: duplicated name %s", s.position, s.param.name)
+		}
+
+		if err := g.expectExpr(s.expr, s.param.typ); err != nil {
+			return err
+		}
+
+		g.vars[s.param.name] = s.param.typ
+		g.stack[len(g.stack)-1] = s.param.name
+
+	case *lockStatement:
+		g.emit(strconv.FormatUint(g.locks, 10))
+		g.push("")
+		if err := g.expectExpr(s.amount, amountType); err != nil {
+			return err
+		}
+
+		if err := g.expectExpr(s.asset, assetType); err != nil {
+			return err
+		}
+
+		g.emit("1")
+		g.push("")
+		if err := g.expectExpr(s.program, programType); err != nil {
+			return err
+		}
+
+		// CHECKOUTPUT compares the state data of the output with the alt stack,
+		// which is emptied but not nil after the contract params are moved off.
+		// It runs in a child vm starting with a nil alt stack, so the output
+		// without state data can be matched.
+		g.emit("5", checkOutputPredicate, "0", "CHECKPREDICATE", "VERIFY")
+		g.pop(5)
+		abi.Locks = append(abi.Locks, &Lock{Index: g.locks, Amount: s.amount.String(), Asset: s.asset.String(), Program: s.program.String()})
+		g.locks++
+
+	case *unlockStatement:
+		amount, ok := s.amount.(*varRef)
+		if !ok || amount.name != g.contract.value.amount {
+			return errors.WithDetailf(ErrType, "%s: unlock amount must be %s", s.position, g.contract.value.amount)
+		}
+
+		asset, ok := s.asset.(*varRef)
+		if !ok || asset.name != g.contract.value.asset {
+			return errors.WithDetailf(ErrType, "%s: unlock asset must be %s", s.position, g.contract.value.asset)
+		}
+		abi.Unlocks = append(abi.Unlocks, &Lock{Amount: amount.name, Asset: asset.name})
+	}
+	return nil
+}
+
+func (g *generator) expectExpr(expr expression, want string) error {
+	typ, err := g.generateExpr(expr)
+	if err != nil {
+		return err
+	}
+
+	if !assignable(want, typ) {
+		return errors.WithDetailf(ErrType, "%s: %s is %s, want %s", expr.pos(), expr, typ, want)
+	}
+	return nil
+}
+
+// generateExpr emits the code pushes the value of the expression, and returns the type of it
+func (g *generator) generateExpr(expr expression) (string, error) {
+	switch e := expr.(type) {
+	case *integerLiteral:
+		g.emit(strconv.FormatUint(e.value, 10))
+		g.push("")
+		return integerType, nil
+
+	case *booleanLiteral:
+		if e.value {
+			g.emit("1")
+		} else {
+			g.emit("0")
+		}
+		g.push("")
+		return booleanType, nil
+
+	case *bytesLiteral:
+		g.emit("0x" + hex.EncodeToString(e.value))
+		g.push("")
+		return bytesType, nil
+
+	case *stringLiteral:
+		g.emit("0x" + hex.EncodeToString([]byte(e.value)))
+		g.push("")
+		return stringType, nil
+
+	case *parenExpr:
+		return g.generateExpr(e.expr)
+
+	case *varRef:
+		return g.generateVarRef(e)
+
+	case *unaryExpr:
+		if err := g.expectExpr(e.expr, booleanType); err != nil {
+			return "", err
+		}
+
+		g.emit("NOT")
+		return booleanType, nil
+
+	case *binaryExpr:
+		return g.generateBinary(e)
+
+	case *callExpr:
+		return g.generateCall(e)
+	}
+	return "", errors.WithDetailf(ErrSyntax, "%s: unsupported expression", expr.pos())
+}
+
+func (g *generator) generateVarRef(e *varRef) (string, error) {
+	typ, ok := g.vars[e.name]
+	if !ok {
+		return "", errors.WithDetailf(ErrType, "%s: undefined name %s", e.position, e.name)
+	}
+
+	switch e.name {
+	case g.contract.value.amount:
+		g.emit("AMOUNT")
+		g.push("")
+		return typ, nil
+
+	case g.contract.value.asset:
+		g.emit("ASSET")
+		g.push("")
+		return typ, nil
+	}
+
+	depth := -1
+	for i := len(g.stack) - 1; i >= 0; i-- {
+		if g.stack[i] == e.name {
+			depth = len(g.stack) - 1 - i
+			break
+		}
+	}
+
+	switch depth {
+	case -1:
+		return "", errors.WithDetailf(ErrType, "%s: name %s is not on the stack", e.position, e.name)
+	case 0:
+		g.emit("DUP")
+	case 1:
+		g.emit("OVER")
+	default:
+		g.emit(strconv.Itoa(depth), "PICK")
+	}
+	g.push("")
+	return typ, nil
+}
+
+var (
+	numericOps = map[string]string{
+		"+":  "ADD",
+		"-":  "SUB",
+		"*":  "MUL",
+		"/":  "DIV",
+		"%":  "MOD",
+		"<":  "LESSTHAN",
+		"<=": "LESSTHANOREQUAL",
+		">":  "GREATERTHAN",
+		">=": "GREATERTHANOREQUAL",
+		"==": "NUMEQUAL",
+		"!=": "NUMNOTEQUAL",
+	}
+
+	booleanOps = map[string]string{
+		"&&": "BOOLAND",
+		"||": "BOOLOR",
+	}
+)
+
+func (g *generator) generateBinary(e *binaryExpr) (string, error) {
+	left, err := g.generateExpr(e.left)
+	if err != nil {
+		return "", err
+	}
+
+	right, err := g.generateExpr(e.right)
+	if err != nil {
+		return "", err
+	}
+
+	g.pop(2)
+	g.push("")
+	mismatch := errors.WithDetailf(ErrType, "%s: operator %s can't be applied to %s and %s", e.position, e.op, left, right)
+	switch {
+	case booleanOps[e.op] != "":
+		if left != booleanType || right != booleanType {
+			return "", mismatch
+		}
+
+		g.emit(booleanOps[e.op])
+		return booleanType, nil
+
+	case isNumericType(left) && isNumericType(right):
+		g.emit(numericOps[e.op])
+		switch e.op {
+		case "+", "-", "*", "/", "%":
+			if left == amountType && right == amountType {
+				return amountType, nil
+			}
+			return integerType, nil
+		}
+		return booleanType, nil
+
+	case e.op == "==" || e.op == "!=":
+		if !assignable(left, right) && !assignable(right, left) {
+			return "", mismatch
+		}
+
+		g.emit("EQUAL")
+		if e.op == "!=" {
+			g.emit("NOT")
+		}
+		return booleanType, nil
+	}
+	return "", mismatch
+}
+
+func (g *generator) generateCall(e *callExpr) (string, error) {
+	fn, ok := builtins[e.fn]
+	if !ok {
+		return "", errors.WithDetailf(ErrType, "%s: unknown function %s", e.position, e.fn)
+	}
+
+	if len(e.args) != len(fn.args) {
+		return "", errors.WithDetailf(ErrType, "%s: %s needs %d arguments, got %d", e.position, e.fn, len(fn.args), len(e.args))
+	}
+
+	checkArg := func(i int) error {
+		typ, err := g.generateExpr(e.args[i])
+		if err != nil {
+			return err
+		}
+
+		if fn.args[i] == bytesType && isBytesType(typ) {
+			return nil
+		}
+
+		if !assignable(fn.args[i], typ) {
+			return errors.WithDetailf(ErrType, "%s: argument %d of %s is %s, want %s", e.args[i].pos(), i+1, e.fn, typ, fn.args[i])
+		}
+		return nil
+	}
+
+	switch e.fn {
+	case "checkTxSig":
+		// CHECKSIG takes the stack [sig msg pubKey]
+		if err := checkArg(1); err != nil {
+			return "", err
+		}
+
+		g.emit("TXSIGHASH")
+		g.push("")
+		if err := checkArg(0); err != nil {
+			return "", err
+		}
+
+		g.emit("CHECKSIG")
+		g.pop(3)
+
+	case "above", "below":
+		if err := checkArg(0); err != nil {
+			return "", err
+		}
+
+		if e.fn == "above" {
+			g.emit("BLOCKHEIGHT", "LESSTHAN")
+		} else {
+			g.emit("BLOCKHEIGHT", "GREATERTHAN")
+		}
+		g.pop(1)
+
+	default:
+		for i := range e.args {
+			if err := checkArg(i); err != nil {
+				return "", err
+			}
+		}
+
+		switch e.fn {
+		case "sha3":
+			g.emit("SHA3")
+		case "sha256":
+			g.emit("SHA256")
+		case "size":
+			g.emit("SIZE", "NIP")
+		case "min":
+			g.emit("MIN")
+		case "max":
+			g.emit("MAX")
+		}
+		g.pop(len(e.args))
+	}
+
+	g.push("")
+	return fn.result, nil
+}
+
+// ContractArg is the value of a contract param, one of the fields should be set
+type ContractArg struct {
+	Boolean *bool               `json:"boolean,omitempty"`
+	Integer *uint64             `json:"integer,omitempty"`
+	String  *chainjson.HexBytes `json:"string,omitempty"`
+}
+
+// StateData encodes the contract args as the state data of the contract output
+func (c *Contract) StateData(args []ContractArg) ([][]byte, error) {
+	if len(args) != len(c.Params) {
+		return nil, errors.WithDetailf(ErrArgs, "contract %s needs %d arguments, got %d", c.Name, len(c.Params), len(args))
+	}
+
+	var stateData [][]byte
+	for i, p := range c.Params {
+		arg := args[i]
+		switch {
+		case p.Type == booleanType && arg.Boolean != nil:
+			stateData = append(stateData, vm.BoolBytes(*arg.Boolean))
+		case isNumericType(p.Type) && arg.Integer != nil:
+			stateData = append(stateData, vm.Uint64Bytes(*arg.Integer))
+		case isBytesType(p.Type) && arg.String != nil:
+			stateData = append(stateData, *arg.String)
+		default:
+			return nil, errors.WithDetailf(ErrArgs, "argument %d of contract %s should be %s", i+1, c.Name, p.Type)
+		}
+	}
+	return stateData, nil
+}
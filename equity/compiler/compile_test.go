@@ -0,0 +1,247 @@
+package compiler
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/errors"
+	"kuskcore/protocol/vm"
+)
+
+const lockWithPublicKey = `
+contract LockWithPublicKey(publicKey: PublicKey) locks valueAmount of valueAsset {
+	clause spend(sig: Signature) {
+		verify checkTxSig(publicKey, sig)
+		unlock valueAmount of valueAsset
+	}
+}
+`
+
+const escrow = `
+// the agent decides who gets the value
+contract Escrow(agent: PublicKey, sender: Program, recipient: Program) locks value of asset {
+	clause approve(sig: Signature) {
+		verify checkTxSig(agent, sig)
+		lock value of asset with recipient
+	}
+	clause reject(sig: Signature) {
+		verify checkTxSig(agent, sig)
+		lock value of asset with sender
+	}
+}
+`
+
+const timeLock = `
+contract TimeLock(owner: Program, height: Integer, fee: Amount) locks value of asset {
+	clause withdraw(secret: String) {
+		verify above(height) && !below(height)
+		verify sha3(secret) == 0xf5a5207a8729b1f709cb710311751eb2fc8acad5a1fb8ac991b736e69b6529a3
+		define rest: Amount = value - fee
+		verify rest > min(fee, 10) && size(secret) != 0
+		lock rest of asset with owner
+	}
+}
+`
+
+func mockContext(program []byte, stateData, args [][]byte, blockHeight uint64, checkOutput func([]byte) bool) *vm.Context {
+	txVersion, amount, assetID := uint64(1), uint64(100), bytes.Repeat([]byte{1}, 32)
+	return &vm.Context{
+		VMVersion:   1,
+		Code:        program,
+		StateData:   stateData,
+		Arguments:   args,
+		TxVersion:   &txVersion,
+		BlockHeight: &blockHeight,
+		AssetID:     &assetID,
+		Amount:      &amount,
+		TxSigHash:   func() []byte { return bytes.Repeat([]byte{2}, 32) },
+		CheckOutput: func(index uint64, amount uint64, asset []byte, vmVersion uint64, code []byte, state [][]byte, expansion bool) (bool, error) {
+			return index == 0 && bytes.Equal(asset, assetID) && vmVersion == 1 && stateEqual(state, nil) && checkOutput(append(vm.Uint64Bytes(amount), code...)), nil
+		},
+	}
+}
+
+// stateEqual compares the state data like the validation of CHECKOUTPUT does,
+// the nil state data only equals nil
+func stateEqual(a, b [][]byte) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+
+	for i, v := range a {
+		if !bytes.Equal(v, b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCompileLockWithPublicKey(t *testing.T) {
+	contract, err := Compile(lockWithPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contract.Name != "LockWithPublicKey" || len(contract.Clauses) != 1 || len(contract.Clauses[0].Params) != 1 || contract.Clauses[0].Params[0].Type != "Signature" {
+		t.Fatalf("got unexpected abi %v", contract)
+	}
+
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateData, err := contract.StateData([]ContractArg{{String: (*chainjson.HexBytes)(&pubKey)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := ed25519.Sign(privKey, bytes.Repeat([]byte{2}, 32))
+	if _, err := vm.Verify(mockContext(contract.Program, stateData, [][]byte{sig}, 1, nil), 10000); err != nil {
+		t.Fatalf("verify with valid signature: %v", err)
+	}
+
+	sig[0] ^= 1
+	if _, err := vm.Verify(mockContext(contract.Program, stateData, [][]byte{sig}, 1, nil), 10000); errors.Root(err) != vm.ErrVerifyFailed {
+		t.Fatalf("verify with invalid signature got %v, want %v", err, vm.ErrVerifyFailed)
+	}
+}
+
+func TestCompileEscrow(t *testing.T) {
+	contract, err := Compile(escrow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender, recipient := chainjson.HexBytes{0x51, 0x01}, chainjson.HexBytes{0x51, 0x02}
+	stateData, err := contract.StateData([]ContractArg{{String: (*chainjson.HexBytes)(&pubKey)}, {String: &sender}, {String: &recipient}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := ed25519.Sign(privKey, bytes.Repeat([]byte{2}, 32))
+	cases := []struct {
+		selector uint64
+		output   []byte
+		wantErr  error
+	}{
+		{selector: 0, output: recipient},
+		{selector: 1, output: sender},
+		{selector: 0, output: sender, wantErr: vm.ErrVerifyFailed},
+		{selector: 1, output: recipient, wantErr: vm.ErrVerifyFailed},
+		{selector: 2, output: recipient, wantErr: vm.ErrVerifyFailed},
+	}
+
+	for i, c := range cases {
+		checkOutput := func(output []byte) bool {
+			return bytes.Equal(output, append(vm.Uint64Bytes(100), c.output...))
+		}
+
+		args := [][]byte{sig, vm.Uint64Bytes(c.selector)}
+		if _, err := vm.Verify(mockContext(contract.Program, stateData, args, 1, checkOutput), 10000); errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+	}
+
+	if locks := contract.Clauses[1].Locks; len(locks) != 1 || locks[0].Program != "sender" || contract.Clauses[1].Selector != 1 {
+		t.Errorf("got unexpected abi of clause reject %v", contract.Clauses[1])
+	}
+}
+
+func TestCompileTimeLock(t *testing.T) {
+	contract, err := Compile(timeLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	height, fee, owner := uint64(10), uint64(30), chainjson.HexBytes{0x51}
+	stateData, err := contract.StateData([]ContractArg{{String: &owner}, {Integer: &height}, {Integer: &fee}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkOutput := func(output []byte) bool {
+		return bytes.Equal(output, append(vm.Uint64Bytes(70), owner...))
+	}
+
+	cases := []struct {
+		blockHeight uint64
+		secret      string
+		wantErr     error
+	}{
+		{blockHeight: 11, secret: "secret"},
+		{blockHeight: 10, secret: "secret", wantErr: vm.ErrVerifyFailed},
+		{blockHeight: 11, secret: "wrong", wantErr: vm.ErrVerifyFailed},
+	}
+
+	for i, c := range cases {
+		if _, err := vm.Verify(mockContext(contract.Program, stateData, [][]byte{[]byte(c.secret)}, c.blockHeight, checkOutput), 10000); errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	cases := []struct {
+		src     string
+		wantErr error
+	}{
+		{
+			src:     `contract A() locks v of a { clause c() { unlock v of a }`,
+			wantErr: ErrSyntax,
+		},
+		{
+			src:     `contract A(n: Integer) locks v of a { clause c() { verify n unlock v of a } }`,
+			wantErr: ErrType,
+		},
+		{
+			src:     `contract A() locks v of a { clause c() { verify x == 1 unlock v of a } }`,
+			wantErr: ErrType,
+		},
+		{
+			src:     `contract A(p: Program) locks v of a { clause c() { verify p == 1 unlock v of a } }`,
+			wantErr: ErrType,
+		},
+		{
+			src:     `contract A(p: PublicKey) locks v of a { clause c() { verify size(p) == 32 } }`,
+			wantErr: ErrType,
+		},
+		{
+			src:     `contract A(p: Unknown) locks v of a { clause c() { unlock v of a } }`,
+			wantErr: ErrSyntax,
+		},
+		{
+			src:     `contract A(p: Program) locks v of a { clause c(p: Program) { unlock v of a } }`,
+			wantErr: ErrType,
+		},
+	}
+
+	for i, c := range cases {
+		if _, err := Compile(c.src); errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+	}
+}
+
+func TestStateData(t *testing.T) {
+	contract, err := Compile(timeLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flag := true
+	if _, err := contract.StateData([]ContractArg{{Boolean: &flag}, {Boolean: &flag}, {Boolean: &flag}}); errors.Root(err) != ErrArgs {
+		t.Errorf("got error %v with mismatched types, want %v", err, ErrArgs)
+	}
+
+	if _, err := contract.StateData(nil); errors.Root(err) != ErrArgs {
+		t.Errorf("got error %v with missing arguments, want %v", err, ErrArgs)
+	}
+}
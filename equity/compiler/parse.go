@@ -0,0 +1,469 @@
+package compiler
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"kuskcore/errors"
+)
+
+const (
+	tokEOF = iota
+	tokIdent
+	tokInteger
+	tokBytes
+	tokString
+	tokPunct
+)
+
+type token struct {
+	position
+	kind int
+	text string
+}
+
+// binaryOps maps the binary operators to their precedence, the larger binds tighter
+var binaryOps = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5,
+}
+
+func tokenize(src string) ([]*token, error) {
+	var tokens []*token
+	line, col := 1, 1
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := position{line: line, col: col}
+		advance := func(n int) {
+			i += n
+			col += n
+		}
+
+		switch {
+		case r == '\n':
+			i++
+			line, col = line+1, 1
+
+		case unicode.IsSpace(r):
+			advance(1)
+
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+
+		case r == '0' && i+1 < len(runes) && (runes[i+1] == 'x' || runes[i+1] == 'X'):
+			j := i + 2
+			for j < len(runes) && strings.ContainsRune("0123456789abcdefABCDEF", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, &token{position: pos, kind: tokBytes, text: string(runes[i+2 : j])})
+			advance(j - i)
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, &token{position: pos, kind: tokInteger, text: string(runes[i:j])})
+			advance(j - i)
+
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, &token{position: pos, kind: tokIdent, text: string(runes[i:j])})
+			advance(j - i)
+
+		case r == '"':
+			var value []rune
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\n' {
+					break
+				}
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				value = append(value, runes[j])
+			}
+			if j >= len(runes) || runes[j] != '"' {
+				return nil, errors.WithDetailf(ErrSyntax, "%s: unterminated string", pos)
+			}
+			tokens = append(tokens, &token{position: pos, kind: tokString, text: string(value)})
+			advance(j + 1 - i)
+
+		default:
+			if i+1 < len(runes) {
+				if two := string(runes[i : i+2]); two == "==" || two == "!=" || two == "<=" || two == ">=" || two == "&&" || two == "||" {
+					tokens = append(tokens, &token{position: pos, kind: tokPunct, text: two})
+					advance(2)
+					continue
+				}
+			}
+
+			if !strings.ContainsRune("(){},:=<>+-*/%!", r) {
+				return nil, errors.WithDetailf(ErrSyntax, "%s: unexpected character %q", pos, r)
+			}
+			tokens = append(tokens, &token{position: pos, kind: tokPunct, text: string(r)})
+			advance(1)
+		}
+	}
+	return append(tokens, &token{position: position{line: line, col: col}, kind: tokEOF}), nil
+}
+
+type parser struct {
+	tokens []*token
+	pos    int
+}
+
+func parse(src string) (*contract, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	c, err := p.parseContract()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, errors.WithDetailf(ErrSyntax, "%s: unexpected %q after contract", tok.position, tok.text)
+	}
+	return c, nil
+}
+
+func (p *parser) peek() *token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() *token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) accept(text string) bool {
+	if tok := p.peek(); (tok.kind == tokPunct || tok.kind == tokIdent) && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if tok := p.peek(); !p.accept(text) {
+		return errors.WithDetailf(ErrSyntax, "%s: expected %q, got %q", tok.position, text, tok.text)
+	}
+	return nil
+}
+
+func (p *parser) expectIdent() (*token, error) {
+	tok := p.next()
+	if tok.kind != tokIdent || keywords[tok.text] {
+		return nil, errors.WithDetailf(ErrSyntax, "%s: expected identifier, got %q", tok.position, tok.text)
+	}
+	return tok, nil
+}
+
+var keywords = map[string]bool{
+	"contract": true,
+	"clause":   true,
+	"locks":    true,
+	"of":       true,
+	"with":     true,
+	"verify":   true,
+	"lock":     true,
+	"unlock":   true,
+	"define":   true,
+	"true":     true,
+	"false":    true,
+}
+
+// contract Name(param: Type, ...) locks amountName of assetName { clause ... }
+func (p *parser) parseContract() (*contract, error) {
+	if err := p.expect("contract"); err != nil {
+		return nil, err
+	}
+
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := p.parseParams()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("locks"); err != nil {
+		return nil, err
+	}
+
+	amount, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("of"); err != nil {
+		return nil, err
+	}
+
+	asset, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	c := &contract{name: name.text, params: params, value: valueInfo{amount: amount.text, asset: asset.text}}
+	for !p.accept("}") {
+		clause, err := p.parseClause()
+		if err != nil {
+			return nil, err
+		}
+		c.clauses = append(c.clauses, clause)
+	}
+	return c, nil
+}
+
+func (p *parser) parseParams() ([]*param, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var params []*param
+	for !p.accept(")") {
+		if len(params) != 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		param, err := p.parseParam()
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+func (p *parser) parseParam() (*param, error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+
+	typ := p.next()
+	if !types[typ.text] {
+		return nil, errors.WithDetailf(ErrSyntax, "%s: unknown type %q", typ.position, typ.text)
+	}
+	return &param{name: name.text, typ: typ.text}, nil
+}
+
+func (p *parser) parseClause() (*clause, error) {
+	if err := p.expect("clause"); err != nil {
+		return nil, err
+	}
+
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := p.parseParams()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	c := &clause{name: name.text, params: params}
+	for !p.accept("}") {
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		c.statements = append(c.statements, stmt)
+	}
+	return c, nil
+}
+
+func (p *parser) parseStatement() (statement, error) {
+	tok := p.next()
+	switch tok.text {
+	case "verify":
+		expr, err := p.parseExpr(1)
+		if err != nil {
+			return nil, err
+		}
+		return &verifyStatement{position: tok.position, expr: expr}, nil
+
+	case "lock", "unlock":
+		amount, err := p.parseExpr(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect("of"); err != nil {
+			return nil, err
+		}
+
+		asset, err := p.parseExpr(1)
+		if err != nil {
+			return nil, err
+		}
+
+		if tok.text == "unlock" {
+			return &unlockStatement{position: tok.position, amount: amount, asset: asset}, nil
+		}
+
+		if err := p.expect("with"); err != nil {
+			return nil, err
+		}
+
+		program, err := p.parseExpr(1)
+		if err != nil {
+			return nil, err
+		}
+		return &lockStatement{position: tok.position, amount: amount, asset: asset, program: program}, nil
+
+	case "define":
+		param, err := p.parseParam()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+
+		expr, err := p.parseExpr(1)
+		if err != nil {
+			return nil, err
+		}
+		return &defineStatement{position: tok.position, param: param, expr: expr}, nil
+	}
+	return nil, errors.WithDetailf(ErrSyntax, "%s: expected statement, got %q", tok.position, tok.text)
+}
+
+// parseExpr parses the binary expression whose operators bind no looser than minPrec
+func (p *parser) parseExpr(minPrec int) (expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		prec, ok := binaryOps[tok.text]
+		if tok.kind != tokPunct || !ok || prec < minPrec {
+			return left, nil
+		}
+
+		p.next()
+		right, err := p.parseExpr(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{position: tok.position, op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (expression, error) {
+	if tok := p.peek(); tok.kind == tokPunct && tok.text == "!" {
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{position: tok.position, op: "!", expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expression, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokInteger:
+		value, err := strconv.ParseUint(tok.text, 10, 64)
+		if err != nil {
+			return nil, errors.WithDetailf(ErrSyntax, "%s: integer %s out of range", tok.position, tok.text)
+		}
+		return &integerLiteral{position: tok.position, value: value}, nil
+
+	case tokBytes:
+		value, err := hex.DecodeString(tok.text)
+		if err != nil {
+			return nil, errors.WithDetailf(ErrSyntax, "%s: invalid hex literal 0x%s", tok.position, tok.text)
+		}
+		return &bytesLiteral{position: tok.position, value: value}, nil
+
+	case tokString:
+		return &stringLiteral{position: tok.position, value: tok.text}, nil
+
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &booleanLiteral{position: tok.position, value: tok.text == "true"}, nil
+		}
+
+		if keywords[tok.text] {
+			return nil, errors.WithDetailf(ErrSyntax, "%s: unexpected keyword %q", tok.position, tok.text)
+		}
+
+		if !p.accept("(") {
+			return &varRef{position: tok.position, name: tok.text}, nil
+		}
+
+		call := &callExpr{position: tok.position, fn: tok.text}
+		for !p.accept(")") {
+			if len(call.args) != 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+
+			arg, err := p.parseExpr(1)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		return call, nil
+
+	case tokPunct:
+		if tok.text == "(" {
+			expr, err := p.parseExpr(1)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return &parenExpr{position: tok.position, expr: expr}, nil
+		}
+	}
+	return nil, errors.WithDetailf(ErrSyntax, "%s: expected expression, got %q", tok.position, tok.text)
+}
@@ -53,10 +53,12 @@ type wsTopicHandler func(*WSClient)
 // functions.  This is set by init because help references wsHandlers and thus
 // causes a dependency loop.
 var wsHandlers = map[string]wsTopicHandler{
-	"notify_raw_blocks":            handleNotifyBlocks,
-	"notify_new_transactions":      handleNotifyNewTransactions,
-	"stop_notify_raw_blocks":       handleStopNotifyBlocks,
-	"stop_notify_new_transactions": handleStopNotifyNewTransactions,
+	"notify_raw_blocks":              handleNotifyBlocks,
+	"notify_new_transactions":        handleNotifyNewTransactions,
+	"notify_contract_instances":      handleNotifyContractInstances,
+	"stop_notify_raw_blocks":         handleStopNotifyBlocks,
+	"stop_notify_new_transactions":   handleStopNotifyNewTransactions,
+	"stop_notify_contract_instances": handleStopNotifyContractInstances,
 }
 
 // responseMessage houses a message to send to a connected websocket client as
@@ -370,3 +372,13 @@ func handleNotifyNewTransactions(wsc *WSClient) {
 func handleStopNotifyNewTransactions(wsc *WSClient) {
 	wsc.notificationMgr.UnregisterNewMempoolTxsUpdates(wsc)
 }
+
+// handleNotifyContractInstances implements the notifycontractinstances topic extension for websocket connections.
+func handleNotifyContractInstances(wsc *WSClient) {
+	wsc.notificationMgr.RegisterContractInstanceUpdates(wsc)
+}
+
+// handleStopNotifyContractInstances implements the stopnotifycontractinstances topic extension for websocket connections.
+func handleStopNotifyContractInstances(wsc *WSClient) {
+	wsc.notificationMgr.UnregisterContractInstanceUpdates(wsc)
+}
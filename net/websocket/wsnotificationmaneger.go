@@ -7,6 +7,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"kuskcore/contract"
 	"kuskcore/event"
 	"kuskcore/protocol"
 	"kuskcore/protocol/bc"
@@ -17,6 +18,7 @@ import (
 type notificationBlockConnected types.Block
 type notificationBlockDisconnected types.Block
 type notificationTxDescAcceptedByMempool protocol.TxDesc
+type notificationContractInstance contract.InstanceEvent
 
 // Notification control requests
 type notificationRegisterClient WSClient
@@ -25,6 +27,8 @@ type notificationRegisterBlocks WSClient
 type notificationUnregisterBlocks WSClient
 type notificationRegisterNewMempoolTxs WSClient
 type notificationUnregisterNewMempoolTxs WSClient
+type notificationRegisterContractInstances WSClient
+type notificationUnregisterContractInstances WSClient
 
 // NotificationType represents the type of a notification message.
 type NotificationType int
@@ -37,6 +41,8 @@ const (
 	NTRawBlockDisconnected
 	NTNewTransaction
 	NTRequestStatus
+	// NTContractInstance indicates the state of a traced contract instance is changed.
+	NTContractInstance
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
@@ -46,6 +52,7 @@ var notificationTypeStrings = map[NotificationType]string{
 	NTRawBlockDisconnected: "raw_blocks_disconnected",
 	NTNewTransaction:       "new_transaction",
 	NTRequestStatus:        "request_status",
+	NTContractInstance:     "contract_instance",
 }
 
 // String returns the NotificationType in human-readable form.
@@ -90,6 +97,7 @@ type WSNotificationManager struct {
 	chain                *protocol.Chain
 	eventDispatcher      *event.Dispatcher
 	txMsgSub             *event.Subscription
+	instanceSub          *event.Subscription
 }
 
 // NewWsNotificationManager returns a new notification manager ready for use. See WSNotificationManager for more details.
@@ -250,12 +258,43 @@ out:
 	m.wg.Done()
 }
 
+// contractInstanceLoop constantly pass the lifecycle events of the traced
+// contract instances to the notification manager.
+func (m *WSNotificationManager) contractInstanceLoop() {
+out:
+	for {
+		select {
+		case obj, ok := <-m.instanceSub.Chan():
+			if !ok {
+				log.WithFields(log.Fields{"module": logModule}).Warning("contract instance subscription channel closed")
+				break out
+			}
+
+			ev, ok := obj.Data.(contract.InstanceEvent)
+			if !ok {
+				log.WithFields(log.Fields{"module": logModule}).Error("event type error")
+				continue
+			}
+
+			select {
+			case m.queueNotification <- (*notificationContractInstance)(&ev):
+			default:
+			}
+		case <-m.quit:
+			break out
+		}
+	}
+
+	m.wg.Done()
+}
+
 // notificationHandler reads notifications and control messages from the queue handler and processes one at a time.
 func (m *WSNotificationManager) notificationHandler() {
 	// clients is a map of all currently connected websocket clients.
 	clients := make(map[chan struct{}]*WSClient)
 	blockNotifications := make(map[chan struct{}]*WSClient)
 	txNotifications := make(map[chan struct{}]*WSClient)
+	instanceNotifications := make(map[chan struct{}]*WSClient)
 
 out:
 	for {
@@ -283,6 +322,12 @@ out:
 					m.notifyForNewTx(txNotifications, txDesc)
 				}
 
+			case *notificationContractInstance:
+				ev := (*contract.InstanceEvent)(n)
+				if len(instanceNotifications) != 0 {
+					m.notifyContractInstance(instanceNotifications, ev)
+				}
+
 			case *notificationRegisterBlocks:
 				wsc := (*WSClient)(n)
 				blockNotifications[wsc.quit] = wsc
@@ -299,6 +344,14 @@ out:
 				wsc := (*WSClient)(n)
 				delete(txNotifications, wsc.quit)
 
+			case *notificationRegisterContractInstances:
+				wsc := (*WSClient)(n)
+				instanceNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterContractInstances:
+				wsc := (*WSClient)(n)
+				delete(instanceNotifications, wsc.quit)
+
 			case *notificationRegisterClient:
 				wsc := (*WSClient)(n)
 				clients[wsc.quit] = wsc
@@ -307,6 +360,7 @@ out:
 				wsc := (*WSClient)(n)
 				delete(blockNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(instanceNotifications, wsc.quit)
 				delete(clients, wsc.quit)
 
 			default:
@@ -406,6 +460,33 @@ func (m *WSNotificationManager) notifyForNewTx(clients map[chan struct{}]*WSClie
 	}
 }
 
+// RegisterContractInstanceUpdates requests notifications to the passed websocket
+// client when the state of a traced contract instance is changed.
+func (m *WSNotificationManager) RegisterContractInstanceUpdates(wsc *WSClient) {
+	m.queueNotification <- (*notificationRegisterContractInstances)(wsc)
+}
+
+// UnregisterContractInstanceUpdates removes the contract instance notifications
+// for the passed websocket client.
+func (m *WSNotificationManager) UnregisterContractInstanceUpdates(wsc *WSClient) {
+	m.queueNotification <- (*notificationUnregisterContractInstances)(wsc)
+}
+
+// notifyContractInstance notifies websocket clients that have registered for
+// the contract instance updates.
+func (m *WSNotificationManager) notifyContractInstance(clients map[chan struct{}]*WSClient, ev *contract.InstanceEvent) {
+	resp := NewWSResponse(NTContractInstance.String(), ev, nil)
+	marshalledJSON, err := json.Marshal(resp)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "error": err}).Error("Failed to marshal contract instance notification")
+		return
+	}
+
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // AddClient adds the passed websocket client to the notification manager.
 func (m *WSNotificationManager) AddClient(wsc *WSClient) {
 	m.queueNotification <- (*notificationRegisterClient)(wsc)
@@ -474,11 +555,17 @@ func (m *WSNotificationManager) Start() error {
 		return err
 	}
 
-	m.wg.Add(4)
+	m.instanceSub, err = m.eventDispatcher.Subscribe(contract.InstanceEvent{})
+	if err != nil {
+		return err
+	}
+
+	m.wg.Add(5)
 	go m.blockNotify()
 	go m.queueHandler()
 	go m.notificationHandler()
 	go m.memPoolTxQueryLoop()
+	go m.contractInstanceLoop()
 	return nil
 }
 
@@ -7,6 +7,7 @@ import (
 
 	"kuskcore/consensus"
 	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
 	"kuskcore/netsync/peers"
 	"kuskcore/p2p/security"
 	"kuskcore/protocol/bc"
@@ -22,9 +23,13 @@ const (
 )
 
 var (
-	maxNumOfBlocksPerMsg      = uint64(64)
-	maxNumOfHeadersPerMsg     = uint64(1000)
-	maxNumOfBlocksRegularSync = uint64(128)
+	maxNumOfBlocksPerMsg        = uint64(64)
+	maxNumOfHeadersPerMsg       = uint64(1000)
+	maxNumOfBlocksRegularSync   = uint64(128)
+	maxNumOfFiltersPerMsg       = uint64(1000)
+	maxNumOfFilterHeadersPerMsg = uint64(2000)
+
+	errNotCheckpoint = errors.New("the block doesn't end an epoch")
 )
 
 // Fetcher is the interface for fetch struct
@@ -141,6 +146,95 @@ func (bk *blockKeeper) locateHeaders(locator []*bc.Hash, stopHash *bc.Hash, skip
 	return headers, nil
 }
 
+// locateBlockFilters returns the compact filters of the main chain blocks from
+// the start height, it stops at the best height of the chain
+func (bk *blockKeeper) locateBlockFilters(startHeight, count uint64) ([]*bc.Hash, [][]byte, error) {
+	if count > maxNumOfFiltersPerMsg {
+		count = maxNumOfFiltersPerMsg
+	}
+
+	blockHashes, filters := []*bc.Hash{}, [][]byte{}
+	for height := startHeight; height < startHeight+count && height <= bk.chain.BestBlockHeight(); height++ {
+		header, err := bk.chain.GetHeaderByHeight(height)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		blockHash := header.Hash()
+		filter, err := bk.chain.GetBlockFilter(&blockHash)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		blockHashes = append(blockHashes, &blockHash)
+		filters = append(filters, filter)
+	}
+	return blockHashes, filters, nil
+}
+
+// locateEpochBlocks returns the blocks of the epoch ending at the checkpoint in
+// height order, the votes in the blocks change the validators of the next epoch
+func (bk *blockKeeper) locateEpochBlocks(hash *bc.Hash) ([]*types.Block, error) {
+	header, err := bk.chain.GetHeaderByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Height == 0 || header.Height%consensus.ActiveNetParams.BlocksOfEpoch != 0 {
+		return nil, errNotCheckpoint
+	}
+
+	blocks := make([]*types.Block, consensus.ActiveNetParams.BlocksOfEpoch)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block, err := bk.chain.GetBlockByHash(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		blocks[i] = block
+		hash = &block.PreviousBlockHash
+	}
+	return blocks, nil
+}
+
+// locateFilterHeaders returns the filter header before the start height and
+// the filter headers of the main chain blocks from the start height
+func (bk *blockKeeper) locateFilterHeaders(startHeight, count uint64) (*bc.Hash, []*bc.Hash, error) {
+	if count > maxNumOfFilterHeadersPerMsg {
+		count = maxNumOfFilterHeadersPerMsg
+	}
+
+	prevFilterHeader := &bc.Hash{}
+	if startHeight > 0 {
+		header, err := bk.chain.GetHeaderByHeight(startHeight - 1)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		blockHash := header.Hash()
+		if prevFilterHeader, err = bk.chain.GetBlockFilterHeader(&blockHash); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	filterHeaders := []*bc.Hash{}
+	for height := startHeight; height < startHeight+count && height <= bk.chain.BestBlockHeight(); height++ {
+		header, err := bk.chain.GetHeaderByHeight(height)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		blockHash := header.Hash()
+		filterHeader, err := bk.chain.GetBlockFilterHeader(&blockHash)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		filterHeaders = append(filterHeaders, filterHeader)
+	}
+	return prevFilterHeader, filterHeaders, nil
+}
+
 func (bk *blockKeeper) processBlock(peerID string, block *types.Block) {
 	bk.msgFetcher.processBlock(peerID, block)
 }
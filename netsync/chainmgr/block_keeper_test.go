@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"kuskcore/blockchain/gcs"
 	"kuskcore/consensus"
 	dbm "kuskcore/database/leveldb"
 	"kuskcore/errors"
@@ -560,3 +561,96 @@ func TestLocateHeaders(t *testing.T) {
 		}
 	}
 }
+
+func TestLocateBlockFilters(t *testing.T) {
+	blocks := mockBlocks(nil, 10)
+	mockChain := mock.NewChain()
+	bk := &blockKeeper{chain: mockChain}
+	for _, block := range blocks {
+		mockChain.SetBlockByHeight(block.Height, block)
+	}
+	mockChain.SetBestBlockHeader(&blocks[10].BlockHeader)
+
+	cases := []struct {
+		startHeight uint64
+		count       uint64
+		wantHeight  []uint64
+	}{
+		{startHeight: 0, count: 3, wantHeight: []uint64{0, 1, 2}},
+		{startHeight: 8, count: 5, wantHeight: []uint64{8, 9, 10}},
+		{startHeight: 11, count: 5, wantHeight: []uint64{}},
+	}
+
+	for i, c := range cases {
+		blockHashes, filters, err := bk.locateBlockFilters(c.startHeight, c.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		prevFilterHeader, filterHeaders, err := bk.locateFilterHeaders(c.startHeight, c.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(blockHashes) != len(c.wantHeight) || len(filters) != len(c.wantHeight) {
+			t.Fatalf("case %d: got %d filters, want %d", i, len(filters), len(c.wantHeight))
+		}
+
+		if len(filterHeaders) != len(c.wantHeight) {
+			t.Fatalf("case %d: got %d filter headers, want %d", i, len(filterHeaders), len(c.wantHeight))
+		}
+
+		for j, height := range c.wantHeight {
+			if *blockHashes[j] != blocks[height].Hash() {
+				t.Errorf("case %d: got block hash %s at height %d", i, blockHashes[j].String(), height)
+			}
+
+			filter, err := gcs.FromBytes(filters[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := gcs.FilterHeader(filter, prevFilterHeader); *filterHeaders[j] != want {
+				t.Errorf("case %d: got filter header %s, want %s", i, filterHeaders[j].String(), want.String())
+			}
+			prevFilterHeader = filterHeaders[j]
+		}
+	}
+}
+
+func TestLocateEpochBlocks(t *testing.T) {
+	epoch := consensus.ActiveNetParams.BlocksOfEpoch
+	blocks := mockBlocks(nil, 2*epoch)
+	mockChain := mock.NewChain()
+	bk := &blockKeeper{chain: mockChain}
+	for _, block := range blocks {
+		mockChain.SetBlockByHeight(block.Height, block)
+	}
+
+	cases := []struct {
+		height uint64
+		err    error
+	}{
+		{height: epoch},
+		{height: 2 * epoch},
+		{height: epoch + 1, err: errNotCheckpoint},
+		{height: 0, err: errNotCheckpoint},
+	}
+
+	for i, c := range cases {
+		hash := blocks[c.height].Hash()
+		got, err := bk.locateEpochBlocks(&hash)
+		if err != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if !testutil.DeepEqual(got, blocks[c.height-epoch+1:c.height+1]) {
+			t.Errorf("case %d: got %d blocks of the epoch", i, len(got))
+		}
+	}
+}
@@ -2,12 +2,19 @@ package chainmgr
 
 import (
 	"sync"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 
 	"kuskcore/errors"
 	"kuskcore/netsync/peers"
 	"kuskcore/p2p/security"
+	"kuskcore/protocol/bc/types"
+)
+
+const (
+	numOfPrevalidateWorkers  = 4
+	maxNumOfPrevalidateAhead = 128
 )
 
 var errOrphanBlock = errors.New("fast sync inserting orphan block")
@@ -39,14 +46,49 @@ func (bp *blockProcessor) insert(blockStorage *blockStorage) error {
 	return err
 }
 
+// prevalidateWorker validates the transactions of the downloaded blocks ahead of
+// the sequential processing, the blocks already processed are skipped
+func (bp *blockProcessor) prevalidateWorker(workCh chan *types.Block, syncHeight *uint64, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for block := range workCh {
+		if block.Height < atomic.LoadUint64(syncHeight) {
+			continue
+		}
+
+		bp.chain.PrevalidateBlock(block)
+	}
+}
+
 func (bp *blockProcessor) process(downloadNotifyCh chan struct{}, ProcessStop chan struct{}, syncHeight uint64, wg *sync.WaitGroup) {
+	workCh := make(chan *types.Block, maxNumOfPrevalidateAhead)
+	var workWg sync.WaitGroup
+	for i := 0; i < numOfPrevalidateWorkers; i++ {
+		workWg.Add(1)
+		go bp.prevalidateWorker(workCh, &syncHeight, &workWg)
+	}
+
 	defer func() {
+		close(workCh)
+		workWg.Wait()
 		close(ProcessStop)
 		wg.Done()
 	}()
 
+	prevalidateHeight := syncHeight
 	for {
 		for {
+			for ; prevalidateHeight < atomic.LoadUint64(&syncHeight)+maxNumOfPrevalidateAhead; prevalidateHeight++ {
+				block, err := bp.storage.readBlock(prevalidateHeight)
+				if err != nil {
+					break
+				}
+
+				select {
+				case workCh <- block.block:
+				default:
+				}
+			}
+
 			block, err := bp.storage.readBlock(syncHeight)
 			if err != nil {
 				break
@@ -58,7 +100,7 @@ func (bp *blockProcessor) process(downloadNotifyCh chan struct{}, ProcessStop ch
 			}
 
 			bp.storage.deleteBlock(syncHeight)
-			syncHeight++
+			atomic.AddUint64(&syncHeight, 1)
 		}
 
 		if _, ok := <-downloadNotifyCh; !ok {
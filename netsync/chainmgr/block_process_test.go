@@ -9,6 +9,7 @@ import (
 
 	dbm "kuskcore/database/leveldb"
 	"kuskcore/netsync/peers"
+	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 	"kuskcore/test/mock"
 	"kuskcore/testcontrol"
@@ -77,3 +78,112 @@ func TestBlockProcess(t *testing.T) {
 		}
 	}
 }
+
+// prevalidateChain counts the processed blocks which were prevalidated, the
+// processing waits a while for the prevalidation running in parallel
+type prevalidateChain struct {
+	*mock.Chain
+	mtx          sync.Mutex
+	prevalidated map[bc.Hash]uint64
+	hits, misses int
+}
+
+func (c *prevalidateChain) PrevalidateBlock(block *types.Block) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.prevalidated[block.Hash()] = block.Height
+}
+
+func (c *prevalidateChain) ProcessBlock(block *types.Block) (bool, error) {
+	for i := 0; i < 100; i++ {
+		c.mtx.Lock()
+		_, ok := c.prevalidated[block.Hash()]
+		c.mtx.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.mtx.Lock()
+	if _, ok := c.prevalidated[block.Hash()]; ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	c.mtx.Unlock()
+	return c.Chain.ProcessBlock(block)
+}
+
+func TestPrevalidateBlocks(t *testing.T) {
+	tmp, err := ioutil.TempDir(".", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	testDB := dbm.NewDB("testdb", "leveldb", tmp)
+	defer testDB.Close()
+
+	blocks := mockBlocks(nil, 200)
+	chain := &prevalidateChain{Chain: mock.NewChain(), prevalidated: map[bc.Hash]uint64{}}
+	for i := 0; i <= 100; i++ {
+		chain.SetBlockByHeight(uint64(i), blocks[i])
+		chain.SetBestBlockHeader(&blocks[i].BlockHeader)
+	}
+
+	s := newStorage(testDB)
+	if err := s.writeBlocks("testPeer", blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	bp := newBlockProcessor(chain, s, peers.NewPeerSet(nil))
+	downloadNotifyCh := make(chan struct{})
+	close(downloadNotifyCh)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	bp.process(downloadNotifyCh, make(chan struct{}), 101, &wg)
+
+	if chain.BestBlockHeight() != 200 {
+		t.Fatalf("got best block height %d, want 200", chain.BestBlockHeight())
+	}
+
+	if chain.hits != 100 || chain.misses != 0 {
+		t.Errorf("got %d prevalidated blocks processed and %d not, want 100 and 0", chain.hits, chain.misses)
+	}
+
+	for _, height := range chain.prevalidated {
+		if height <= 100 {
+			t.Errorf("got the block at height %d prevalidated, want the blocks after 100 only", height)
+		}
+	}
+}
+
+func TestPrevalidateWorker(t *testing.T) {
+	blocks := mockBlocks(nil, 10)
+	chain := &prevalidateChain{Chain: mock.NewChain(), prevalidated: map[bc.Hash]uint64{}}
+	bp := newBlockProcessor(chain, nil, nil)
+
+	// the blocks below the sync height are processed already
+	syncHeight := uint64(6)
+	workCh := make(chan *types.Block, len(blocks))
+	for _, block := range blocks {
+		workCh <- block
+	}
+	close(workCh)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	bp.prevalidateWorker(workCh, &syncHeight, &wg)
+	wg.Wait()
+
+	if len(chain.prevalidated) != 5 {
+		t.Errorf("got %d blocks prevalidated, want 5", len(chain.prevalidated))
+	}
+
+	for _, height := range chain.prevalidated {
+		if height < syncHeight {
+			t.Errorf("got the block at height %d prevalidated, want the blocks from %d only", height, syncHeight)
+		}
+	}
+}
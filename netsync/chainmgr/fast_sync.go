@@ -5,6 +5,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"kuskcore/consensus"
 	"kuskcore/errors"
 	"kuskcore/netsync/peers"
 	"kuskcore/p2p/security"
@@ -20,10 +21,11 @@ var (
 	fastSyncPivotGap       = uint64(64)
 	minGapStartFastSync    = uint64(128)
 
-	errNoSyncPeer      = errors.New("can't find sync peer")
-	errSkeletonSize    = errors.New("fast sync skeleton size wrong")
-	errNoMainSkeleton  = errors.New("No main skeleton found")
-	errNoSkeletonFound = errors.New("No skeleton found")
+	errNoSyncPeer       = errors.New("can't find sync peer")
+	errSkeletonSize     = errors.New("fast sync skeleton size wrong")
+	errNoMainSkeleton   = errors.New("No main skeleton found")
+	errNoSkeletonFound  = errors.New("No skeleton found")
+	errNoVerifiedHeader = errors.New("No verified header found")
 )
 
 type fastSync struct {
@@ -73,10 +75,12 @@ func (fs *fastSync) blockLocator() []*bc.Hash {
 	return locator
 }
 
-// createFetchBlocksTasks get the skeleton and assign tasks according to the skeleton.
+// createFetchBlocksTasks get the skeleton, fetch and verify the header chain of
+// the skeleton, then assign tasks according to the skeleton. The tasks stop at
+// the last verified header.
 func (fs *fastSync) createFetchBlocksTasks(stopBlock *types.Block) ([]*fetchBlocksWork, error) {
-	// Find peers that meet the height requirements.
-	peers := fs.peers.GetPeersByHeight(stopBlock.Height + fastSyncPivotGap)
+	// Find peers that meet the height requirements and serve the fast sync.
+	peers := fs.peers.GetPeersByService(stopBlock.Height+fastSyncPivotGap, consensus.SFFullNode|consensus.SFFastSync)
 	if len(peers) == 0 {
 		return nil, errNoSyncPeer
 	}
@@ -102,29 +106,66 @@ func (fs *fastSync) createFetchBlocksTasks(stopBlock *types.Block) ([]*fetchBloc
 	fs.msgFetcher.addSyncPeer(fs.mainSyncPeer.ID())
 	delete(skeletonMap, fs.mainSyncPeer.ID())
 	for peerID, skeleton := range skeletonMap {
-		if len(skeleton) != len(mainSkeleton) {
-			log.WithFields(log.Fields{"module": logModule, "main skeleton": len(mainSkeleton), "got skeleton": len(skeleton)}).Warn("different skeleton length")
+		if !fs.matchSkeleton(skeleton, mainSkeleton) {
 			continue
 		}
-
-		for i, header := range skeleton {
-			if header.Hash() != mainSkeleton[i].Hash() {
-				log.WithFields(log.Fields{"module": logModule, "header index": i, "main skeleton": mainSkeleton[i].Hash(), "got skeleton": header.Hash()}).Warn("different skeleton hash")
-				continue
-			}
-		}
 		fs.msgFetcher.addSyncPeer(peerID)
 	}
 
+	if len(mainSkeleton) > maxSizeOfSyncSkeleton {
+		mainSkeleton = mainSkeleton[:maxSizeOfSyncSkeleton]
+	}
+
+	// verify the whole header chain before any block body is downloaded
+	headers, err := fs.msgFetcher.parallelFetchHeaderChain(mainSkeleton)
+	if err != nil {
+		return nil, err
+	}
+
+	verifiedNum, err := fs.chain.VerifyHeaders(headers)
+	if err != nil {
+		fs.peers.ProcessIllegal(fs.mainSyncPeer.ID(), security.LevelMsgIllegal, err.Error())
+		return nil, err
+	}
+
+	if verifiedNum == 0 {
+		return nil, errNoVerifiedHeader
+	}
+
+	headerChain := append([]*types.BlockHeader{mainSkeleton[0]}, headers[:verifiedNum]...)
 	blockFetchTasks := make([]*fetchBlocksWork, 0)
 	// create download task
-	for i := 0; i < len(mainSkeleton)-1 && i < maxSizeOfSyncSkeleton-1; i++ {
-		blockFetchTasks = append(blockFetchTasks, &fetchBlocksWork{startHeader: mainSkeleton[i], stopHeader: mainSkeleton[i+1]})
+	for i := 0; i < len(mainSkeleton)-1; i++ {
+		start := mainSkeleton[i].Height - mainSkeleton[0].Height
+		if start >= uint64(len(headerChain)-1) {
+			break
+		}
+
+		stop := mainSkeleton[i+1].Height - mainSkeleton[0].Height
+		if stop >= uint64(len(headerChain)) {
+			stop = uint64(len(headerChain) - 1)
+		}
+		blockFetchTasks = append(blockFetchTasks, &fetchBlocksWork{startHeader: headerChain[start], stopHeader: headerChain[stop], headers: headerChain[start : stop+1]})
 	}
 
 	return blockFetchTasks, nil
 }
 
+func (fs *fastSync) matchSkeleton(skeleton, mainSkeleton []*types.BlockHeader) bool {
+	if len(skeleton) != len(mainSkeleton) {
+		log.WithFields(log.Fields{"module": logModule, "main skeleton": len(mainSkeleton), "got skeleton": len(skeleton)}).Warn("different skeleton length")
+		return false
+	}
+
+	for i, header := range skeleton {
+		if header.Hash() != mainSkeleton[i].Hash() {
+			log.WithFields(log.Fields{"module": logModule, "header index": i, "main skeleton": mainSkeleton[i].Hash(), "got skeleton": header.Hash()}).Warn("different skeleton hash")
+			return false
+		}
+	}
+	return true
+}
+
 func (fs *fastSync) process() error {
 	stopBlock, err := fs.findSyncRange()
 	if err != nil {
@@ -256,6 +256,26 @@ func (mf *mockFetcher) parallelFetchHeaders(peers []*peers.Peer, locator []*bc.H
 	return result
 }
 
+func (mf *mockFetcher) parallelFetchHeaderChain(skeleton []*types.BlockHeader) ([]*types.BlockHeader, error) {
+	headers := []*types.BlockHeader{}
+	for _, block := range mf.peerStatus["peer1"][skeleton[0].Height+1 : skeleton[len(skeleton)-1].Height+1] {
+		headers = append(headers, &block.BlockHeader)
+	}
+	return headers, nil
+}
+
+func mockFetchBlocksWorks(blocks []*types.Block, heights ...uint64) []*fetchBlocksWork {
+	works := []*fetchBlocksWork{}
+	for i := 0; i < len(heights)-1; i++ {
+		headers := []*types.BlockHeader{}
+		for _, block := range blocks[heights[i] : heights[i+1]+1] {
+			headers = append(headers, &block.BlockHeader)
+		}
+		works = append(works, &fetchBlocksWork{startHeader: headers[0], stopHeader: headers[len(headers)-1], headers: headers})
+	}
+	return works
+}
+
 func TestCreateFetchBlocksTasks(t *testing.T) {
 	baseChain := mockBlocks(nil, 1000)
 	chainX := append(baseChain, mockBlocks(baseChain[1000], 2000)...)
@@ -284,13 +304,8 @@ func TestCreateFetchBlocksTasks(t *testing.T) {
 			},
 			mainSyncPeer: "peer1",
 			testType:     1,
-			wantTasks: []*fetchBlocksWork{
-				{&chainX[1000].BlockHeader, &chainX[1100].BlockHeader}, {&chainX[1100].BlockHeader, &chainX[1200].BlockHeader},
-				{&chainX[1200].BlockHeader, &chainX[1300].BlockHeader}, {&chainX[1300].BlockHeader, &chainX[1400].BlockHeader},
-				{&chainX[1400].BlockHeader, &chainX[1500].BlockHeader}, {&chainX[1500].BlockHeader, &chainX[1600].BlockHeader},
-				{&chainX[1600].BlockHeader, &chainX[1700].BlockHeader}, {&chainX[1700].BlockHeader, &chainX[1800].BlockHeader},
-			},
-			wantErr: nil,
+			wantTasks:    mockFetchBlocksWorks(chainX, 1000, 1100, 1200, 1300, 1400, 1500, 1600, 1700, 1800),
+			wantErr:      nil,
 		},
 		// test no sync peer
 		{
@@ -341,13 +356,8 @@ func TestCreateFetchBlocksTasks(t *testing.T) {
 			},
 			mainSyncPeer: "peer1",
 			testType:     5,
-			wantTasks: []*fetchBlocksWork{
-				{&chainX[1000].BlockHeader, &chainX[1100].BlockHeader}, {&chainX[1100].BlockHeader, &chainX[1200].BlockHeader},
-				{&chainX[1200].BlockHeader, &chainX[1300].BlockHeader}, {&chainX[1300].BlockHeader, &chainX[1400].BlockHeader},
-				{&chainX[1400].BlockHeader, &chainX[1500].BlockHeader}, {&chainX[1500].BlockHeader, &chainX[1600].BlockHeader},
-				{&chainX[1600].BlockHeader, &chainX[1700].BlockHeader}, {&chainX[1700].BlockHeader, &chainX[1800].BlockHeader},
-			},
-			wantErr: nil,
+			wantTasks:    mockFetchBlocksWorks(chainX, 1000, 1100, 1200, 1300, 1400, 1500, 1600, 1700, 1800),
+			wantErr:      nil,
 		},
 	}
 
@@ -33,9 +33,13 @@ type Chain interface {
 	GetBlockByHeight(uint64) (*types.Block, error)
 	GetHeaderByHash(*bc.Hash) (*types.BlockHeader, error)
 	GetHeaderByHeight(uint64) (*types.BlockHeader, error)
+	GetBlockFilter(*bc.Hash) ([]byte, error)
+	GetBlockFilterHeader(*bc.Hash) (*bc.Hash, error)
 	InMainChain(bc.Hash) bool
+	PrevalidateBlock(*types.Block)
 	ProcessBlock(*types.Block) (bool, error)
 	ValidateTx(*types.Tx) (bool, error)
+	VerifyHeaders([]*types.BlockHeader) (int, error)
 }
 
 // Switch is the interface for network layer
@@ -50,7 +54,9 @@ type Switch interface {
 
 // Mempool is the interface for Kusk mempool
 type Mempool interface {
+	GetTransaction(txHash *bc.Hash) (*core.TxDesc, error)
 	GetTransactions() []*core.TxDesc
+	HaveTransaction(txHash *bc.Hash) bool
 	IsDust(tx *types.Tx) bool
 }
 
@@ -236,6 +242,48 @@ func (m *Manager) handleGetMerkleBlockMsg(peer *peers.Peer, msg *msgs.GetMerkleB
 	}
 }
 
+func (m *Manager) handleGetValidatorsMsg(peer *peers.Peer, msg *msgs.GetValidatorsMessage) {
+	blocks, err := m.blockKeeper.locateEpochBlocks(msg.GetHash())
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Debug("fail on handleGetValidatorsMsg locateEpochBlocks")
+		return
+	}
+
+	ok, err := peer.SendValidators(msg.GetHash(), blocks)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Error("fail on handleGetValidatorsMsg sentValidators")
+		return
+	}
+
+	if !ok {
+		m.peers.RemovePeer(peer.ID())
+	}
+}
+
+func (m *Manager) handleGetBlockFiltersMsg(peer *peers.Peer, msg *msgs.GetBlockFiltersMessage) {
+	blockHashes, filters, err := m.blockKeeper.locateBlockFilters(msg.StartHeight, msg.Count)
+	if err != nil || len(filters) == 0 {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Debug("fail on handleGetBlockFiltersMsg locateBlockFilters")
+		return
+	}
+
+	if ok := peer.SendBlockFilters(msg.StartHeight, blockHashes, filters); !ok {
+		m.peers.RemovePeer(peer.ID())
+	}
+}
+
+func (m *Manager) handleGetFilterHeadersMsg(peer *peers.Peer, msg *msgs.GetFilterHeadersMessage) {
+	prevFilterHeader, filterHeaders, err := m.blockKeeper.locateFilterHeaders(msg.StartHeight, msg.Count)
+	if err != nil || len(filterHeaders) == 0 {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Debug("fail on handleGetFilterHeadersMsg locateFilterHeaders")
+		return
+	}
+
+	if ok := peer.SendFilterHeaders(msg.StartHeight, prevFilterHeader, filterHeaders); !ok {
+		m.peers.RemovePeer(peer.ID())
+	}
+}
+
 func (m *Manager) handleHeadersMsg(peer *peers.Peer, msg *msgs.HeadersMessage) {
 	headers, err := msg.GetHeaders()
 	if err != nil {
@@ -265,7 +313,7 @@ func (m *Manager) handleTransactionMsg(peer *peers.Peer, msg *msgs.TransactionMe
 		return
 	}
 
-	m.peers.MarkTx(peer.ID(), tx.ID)
+	m.peers.MarkTxReceived(peer.ID(), tx.ID)
 	if isOrphan, err := m.chain.ValidateTx(tx); err != nil && err != core.ErrDustTx && !isOrphan {
 		m.peers.ProcessIllegal(peer.ID(), security.LevelMsgIllegal, "fail on validate tx transaction")
 	}
@@ -289,7 +337,7 @@ func (m *Manager) handleTransactionsMsg(peer *peers.Peer, msg *msgs.Transactions
 			continue
 		}
 
-		m.peers.MarkTx(peer.ID(), tx.ID)
+		m.peers.MarkTxReceived(peer.ID(), tx.ID)
 		if isOrphan, err := m.chain.ValidateTx(tx); err != nil && !isOrphan {
 			m.peers.ProcessIllegal(peer.ID(), security.LevelMsgIllegal, "fail on validate tx transaction")
 			return
@@ -297,6 +345,64 @@ func (m *Manager) handleTransactionsMsg(peer *peers.Peer, msg *msgs.Transactions
 	}
 }
 
+func (m *Manager) handleTxInventoryMsg(peer *peers.Peer, msg *msgs.TxInventoryMessage) {
+	txIDs := msg.GetTxIDs()
+	if len(txIDs) > msgs.TxInvMsgMaxNum {
+		m.peers.ProcessIllegal(peer.ID(), security.LevelMsgIllegal, "exceeded the maximum tx inventory number limit")
+		return
+	}
+
+	if !peer.AllowTxInvs(len(txIDs), time.Now()) {
+		log.WithFields(log.Fields{"module": logModule, "peer": peer.Addr(), "tx_inv_num": len(txIDs)}).Debug("drop the tx inventory exceeded the rate limit")
+		return
+	}
+
+	unknownTxIDs := []*bc.Hash{}
+	for _, txID := range txIDs {
+		m.peers.MarkTx(peer.ID(), *txID)
+		if !m.mempool.HaveTransaction(txID) {
+			unknownTxIDs = append(unknownTxIDs, txID)
+		}
+	}
+
+	m.peers.RequestTxs(peer.ID(), unknownTxIDs)
+}
+
+func (m *Manager) handleGetTransactionsMsg(peer *peers.Peer, msg *msgs.GetTransactionsMessage) {
+	txIDs := msg.GetTxIDs()
+	if len(txIDs) > msgs.TxInvMsgMaxNum {
+		m.peers.ProcessIllegal(peer.ID(), security.LevelMsgIllegal, "exceeded the maximum tx request number limit")
+		return
+	}
+
+	txs := []*types.Tx{}
+	for _, txID := range txIDs {
+		// only the txs announced to the peer are served, the ones left the
+		// mempool since announced are skipped
+		if !peer.IsTxAnnounced(txID) {
+			continue
+		}
+
+		txDesc, err := m.mempool.GetTransaction(txID)
+		if err != nil {
+			continue
+		}
+
+		txs = append(txs, txDesc.Tx)
+		if len(txs) == msgs.TxsMsgMaxTxNum {
+			if ok := peer.SendRequestedTxs(txs); !ok {
+				m.peers.RemovePeer(peer.ID())
+				return
+			}
+			txs = []*types.Tx{}
+		}
+	}
+
+	if ok := peer.SendRequestedTxs(txs); !ok {
+		m.peers.RemovePeer(peer.ID())
+	}
+}
+
 func (m *Manager) processMsg(basePeer peers.BasePeer, msgType byte, msg msgs.BlockchainMessage) {
 	peer := m.peers.GetPeer(basePeer.ID())
 	if peer == nil {
@@ -309,6 +415,7 @@ func (m *Manager) processMsg(basePeer peers.BasePeer, msgType byte, msg msgs.Blo
 		"type":    reflect.TypeOf(msg),
 		"message": msg.String(),
 	}).Debug("receive message from peer")
+	peer.MarkMsgReceived(msg)
 
 	switch msg := msg.(type) {
 	case *msgs.GetBlockMessage:
@@ -326,6 +433,12 @@ func (m *Manager) processMsg(basePeer peers.BasePeer, msgType byte, msg msgs.Blo
 	case *msgs.TransactionsMessage:
 		m.handleTransactionsMsg(peer, msg)
 
+	case *msgs.TxInventoryMessage:
+		m.handleTxInventoryMsg(peer, msg)
+
+	case *msgs.GetTransactionsMessage:
+		m.handleGetTransactionsMsg(peer, msg)
+
 	case *msgs.GetHeadersMessage:
 		m.handleGetHeadersMsg(peer, msg)
 
@@ -350,6 +463,15 @@ func (m *Manager) processMsg(basePeer peers.BasePeer, msgType byte, msg msgs.Blo
 	case *msgs.GetMerkleBlockMessage:
 		m.handleGetMerkleBlockMsg(peer, msg)
 
+	case *msgs.GetValidatorsMessage:
+		m.handleGetValidatorsMsg(peer, msg)
+
+	case *msgs.GetBlockFiltersMessage:
+		m.handleGetBlockFiltersMsg(peer, msg)
+
+	case *msgs.GetFilterHeadersMessage:
+		m.handleGetFilterHeadersMsg(peer, msg)
+
 	default:
 		log.WithFields(log.Fields{
 			"module":       logModule,
@@ -393,6 +515,7 @@ func (m *Manager) Start() error {
 	m.blockKeeper.start()
 	go m.broadcastTxsLoop()
 	go m.syncMempoolLoop()
+	go m.txTrickleLoop()
 
 	return nil
 }
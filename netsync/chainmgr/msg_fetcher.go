@@ -11,14 +11,16 @@ import (
 	"kuskcore/p2p/security"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/validation"
 )
 
 const (
-	maxNumOfParallelFetchBlocks = 7
-	blockProcessChSize          = 1024
-	blocksProcessChSize         = 128
-	headersProcessChSize        = 1024
-	maxNumOfFastSyncPeers       = 128
+	maxNumOfParallelFetchBlocks  = 7
+	maxNumOfParallelFetchHeaders = 7
+	blockProcessChSize           = 1024
+	blocksProcessChSize          = 128
+	headersProcessChSize         = 1024
+	maxNumOfFastSyncPeers        = 128
 )
 
 var (
@@ -31,6 +33,7 @@ var (
 	errRequestTimeout       = errors.New("request timeout")
 	errPeerDropped          = errors.New("Peer dropped")
 	errSendMsg              = errors.New("send message error")
+	errNoHeaderChain        = errors.New("can't fetch the header chain")
 )
 
 // MsgFetcher is the interface for msg fetch struct
@@ -40,10 +43,12 @@ type MsgFetcher interface {
 	requireBlock(peerID string, height uint64) (*types.Block, error)
 	parallelFetchBlocks(work []*fetchBlocksWork, downloadNotifyCh chan struct{}, ProcessStopCh chan struct{}, wg *sync.WaitGroup)
 	parallelFetchHeaders(peers []*peers.Peer, locator []*bc.Hash, stopHash *bc.Hash, skip uint64) map[string][]*types.BlockHeader
+	parallelFetchHeaderChain(skeleton []*types.BlockHeader) ([]*types.BlockHeader, error)
 }
 
 type fetchBlocksWork struct {
 	startHeader, stopHeader *types.BlockHeader
+	headers                 []*types.BlockHeader // the verified headers from the start header to the stop header
 }
 
 type fetchBlocksResult struct {
@@ -52,25 +57,27 @@ type fetchBlocksResult struct {
 }
 
 type msgFetcher struct {
-	storage          *storage
-	syncPeers        *fastSyncPeers
-	peers            *peers.PeerSet
-	blockProcessCh   chan *blockMsg
-	blocksProcessCh  chan *blocksMsg
-	headersProcessCh chan *headersMsg
-	blocksMsgChanMap map[string]chan []*types.Block
-	mux              sync.RWMutex
+	storage           *storage
+	syncPeers         *fastSyncPeers
+	peers             *peers.PeerSet
+	blockProcessCh    chan *blockMsg
+	blocksProcessCh   chan *blocksMsg
+	headersProcessCh  chan *headersMsg
+	blocksMsgChanMap  map[string]chan []*types.Block
+	headersMsgChanMap map[string]chan []*types.BlockHeader
+	mux               sync.RWMutex
 }
 
 func newMsgFetcher(storage *storage, peers *peers.PeerSet) *msgFetcher {
 	return &msgFetcher{
-		storage:          storage,
-		syncPeers:        newFastSyncPeers(),
-		peers:            peers,
-		blockProcessCh:   make(chan *blockMsg, blockProcessChSize),
-		blocksProcessCh:  make(chan *blocksMsg, blocksProcessChSize),
-		headersProcessCh: make(chan *headersMsg, headersProcessChSize),
-		blocksMsgChanMap: make(map[string]chan []*types.Block),
+		storage:           storage,
+		syncPeers:         newFastSyncPeers(),
+		peers:             peers,
+		blockProcessCh:    make(chan *blockMsg, blockProcessChSize),
+		blocksProcessCh:   make(chan *blocksMsg, blocksProcessChSize),
+		headersProcessCh:  make(chan *headersMsg, headersProcessChSize),
+		blocksMsgChanMap:  make(map[string]chan []*types.Block),
+		headersMsgChanMap: make(map[string]chan []*types.BlockHeader),
 	}
 }
 
@@ -123,7 +130,7 @@ func (mf *msgFetcher) fetchBlocks(work *fetchBlocksWork, peerID string) ([]*type
 		return nil, err
 	}
 
-	if err := mf.verifyBlocksMsg(blocks, work.startHeader, work.stopHeader); err != nil {
+	if err := mf.verifyBlocksMsg(blocks, work); err != nil {
 		mf.syncPeers.delete(peerID)
 		mf.peers.ProcessIllegal(peerID, security.LevelConnException, err.Error())
 		return nil, err
@@ -241,6 +248,88 @@ func (mf *msgFetcher) parallelFetchHeaders(peers []*peers.Peer, locator []*bc.Ha
 	}
 }
 
+// parallelFetchHeaderChain fetches the headers between the skeleton headers from
+// the sync peers in parallel, each segment of the skeleton must be linked from
+// its start to its stop. It returns the header chain after the first skeleton
+// header, the chain stops at the first segment none of the peers returns.
+func (mf *msgFetcher) parallelFetchHeaderChain(skeleton []*types.BlockHeader) ([]*types.BlockHeader, error) {
+	if len(skeleton) < 2 {
+		return nil, errSkeletonSize
+	}
+
+	segments := make([][]*types.BlockHeader, len(skeleton)-1)
+	workCh := make(chan int, len(segments))
+	for i := range segments {
+		workCh <- i
+	}
+	close(workCh)
+
+	var wg sync.WaitGroup
+	for i := 0; i < maxNumOfParallelFetchHeaders && i < len(segments); i++ {
+		peerID, err := mf.syncPeers.selectIdlePeer()
+		if err != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mf.fetchHeadersWorker(peerID, skeleton, segments, workCh)
+		}()
+	}
+	wg.Wait()
+
+	headers := []*types.BlockHeader{}
+	for _, segment := range segments {
+		if segment == nil {
+			break
+		}
+		headers = append(headers, segment...)
+	}
+
+	if len(headers) == 0 {
+		return nil, errNoHeaderChain
+	}
+	return headers, nil
+}
+
+// fetchHeadersWorker fetches the segments of the skeleton with the peer, the
+// peer failing on a segment is dropped and another idle peer retries it
+func (mf *msgFetcher) fetchHeadersWorker(peerID string, skeleton []*types.BlockHeader, segments [][]*types.BlockHeader, workCh chan int) {
+	for i := range workCh {
+		for {
+			headers, err := mf.fetchHeaders(peerID, skeleton[i], skeleton[i+1])
+			if err == nil {
+				segments[i] = headers
+				break
+			}
+
+			log.WithFields(log.Fields{"module": logModule, "startHeight": skeleton[i].Height, "stopHeight": skeleton[i+1].Height, "error": err}).Info("failed on fetch headers")
+			if peerID, err = mf.syncPeers.selectIdlePeer(); err != nil {
+				return
+			}
+		}
+	}
+	mf.syncPeers.setIdle(peerID)
+}
+
+func (mf *msgFetcher) fetchHeaders(peerID string, startHeader, stopHeader *types.BlockHeader) ([]*types.BlockHeader, error) {
+	headers, err := mf.requireHeaders(peerID, startHeader, stopHeader)
+	if err != nil {
+		mf.syncPeers.delete(peerID)
+		mf.peers.ProcessIllegal(peerID, security.LevelConnException, err.Error())
+		return nil, err
+	}
+
+	if err := mf.verifyHeadersMsg(headers, startHeader, stopHeader); err != nil {
+		mf.syncPeers.delete(peerID)
+		mf.peers.ProcessIllegal(peerID, security.LevelMsgIllegal, err.Error())
+		return nil, err
+	}
+
+	return headers[1:], nil
+}
+
 func (mf *msgFetcher) processBlock(peerID string, block *types.Block) {
 	mf.blockProcessCh <- &blockMsg{block: block, peerID: peerID}
 }
@@ -259,6 +348,17 @@ func (mf *msgFetcher) processBlocks(peerID string, blocks []*types.Block) {
 }
 
 func (mf *msgFetcher) processHeaders(peerID string, headers []*types.BlockHeader) {
+	mf.mux.RLock()
+	headersMsgChan, ok := mf.headersMsgChanMap[peerID]
+	mf.mux.RUnlock()
+	if ok {
+		select {
+		case headersMsgChan <- headers:
+		default:
+		}
+		return
+	}
+
 	mf.headersProcessCh <- &headersMsg{headers: headers, peerID: peerID}
 }
 
@@ -317,8 +417,49 @@ func (mf *msgFetcher) requireBlocks(peerID string, locator []*bc.Hash, stopHash
 	}
 }
 
+// requireHeaders requires the headers from the start header to the stop header,
+// the late responses of the other requests are skipped
+func (mf *msgFetcher) requireHeaders(peerID string, startHeader, stopHeader *types.BlockHeader) ([]*types.BlockHeader, error) {
+	peer := mf.peers.GetPeer(peerID)
+	if peer == nil {
+		return nil, errPeerDropped
+	}
+
+	receiveCh := make(chan []*types.BlockHeader, 1)
+	mf.mux.Lock()
+	mf.headersMsgChanMap[peerID] = receiveCh
+	mf.mux.Unlock()
+	defer func() {
+		mf.mux.Lock()
+		delete(mf.headersMsgChanMap, peerID)
+		mf.mux.Unlock()
+	}()
+
+	startHash, stopHash := startHeader.Hash(), stopHeader.Hash()
+	if ok := peer.GetHeaders([]*bc.Hash{&startHash}, &stopHash, 0); !ok {
+		return nil, errSendMsg
+	}
+
+	timeout := time.NewTimer(requireHeadersTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case headers := <-receiveCh:
+			if len(headers) == 0 || headers[0].Hash() != startHash {
+				continue
+			}
+			return headers, nil
+		case <-timeout.C:
+			return nil, errors.Wrap(errRequestTimeout, "requireHeaders")
+		}
+	}
+}
+
 func (mf *msgFetcher) resetParameter() {
+	mf.mux.Lock()
 	mf.blocksMsgChanMap = make(map[string]chan []*types.Block)
+	mf.headersMsgChanMap = make(map[string]chan []*types.BlockHeader)
+	mf.mux.Unlock()
 	mf.syncPeers = newFastSyncPeers()
 	mf.storage.resetParameter()
 	//empty chan
@@ -332,7 +473,8 @@ func (mf *msgFetcher) resetParameter() {
 	}
 }
 
-func (mf *msgFetcher) verifyBlocksMsg(blocks []*types.Block, startHeader, stopHeader *types.BlockHeader) error {
+func (mf *msgFetcher) verifyBlocksMsg(blocks []*types.Block, work *fetchBlocksWork) error {
+	startHeader, stopHeader := work.startHeader, work.stopHeader
 	// null blocks
 	if len(blocks) == 0 {
 		return errors.New("null blocks msg")
@@ -355,5 +497,39 @@ func (mf *msgFetcher) verifyBlocksMsg(blocks []*types.Block, startHeader, stopHe
 		}
 	}
 
+	// verify blocks match the verified headers, the transactions must match the merkle root
+	for _, block := range blocks {
+		if len(work.headers) == 0 {
+			break
+		}
+
+		index := block.Height - work.headers[0].Height
+		if index >= uint64(len(work.headers)) || block.Hash() != work.headers[index].Hash() {
+			return errors.New("get blocks msg mismatch the header chain")
+		}
+
+		if err := validation.ValidateBlockBody(block); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mf *msgFetcher) verifyHeadersMsg(headers []*types.BlockHeader, startHeader, stopHeader *types.BlockHeader) error {
+	if uint64(len(headers)) != stopHeader.Height-startHeader.Height+1 {
+		return errors.New("get wrong length headers msg")
+	}
+
+	if headers[len(headers)-1].Hash() != stopHeader.Hash() {
+		return errors.New("get mismatch headers msg")
+	}
+
+	for i := 0; i < len(headers)-1; i++ {
+		if headers[i].Hash() != headers[i+1].PreviousBlockHash {
+			return errors.New("get discontinuous headers msg")
+		}
+	}
+
 	return nil
 }
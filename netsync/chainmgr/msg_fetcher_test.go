@@ -0,0 +1,117 @@
+package chainmgr
+
+import (
+	"testing"
+
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+func mockBodyBlocks(height uint64) []*types.Block {
+	blocks := mockBlocks(nil, height)
+	for i, block := range blocks {
+		block.TransactionsMerkleRoot = bc.EmptyStringHash
+		if i > 0 {
+			block.PreviousBlockHash = blocks[i-1].Hash()
+		}
+	}
+	return blocks
+}
+
+func TestVerifyBlocksMsg(t *testing.T) {
+	blocks := mockBodyBlocks(10)
+	headers := []*types.BlockHeader{}
+	for _, block := range blocks {
+		headers = append(headers, &block.BlockHeader)
+	}
+
+	tamperedBlock := *blocks[5]
+	tamperedBlock.Transactions = []*types.Tx{types.NewTx(types.TxData{
+		Outputs: []*types.TxOutput{
+			types.NewOriginalTxOutput(bc.NewAssetID([32]byte{1}), 8, []byte("outProgram"), [][]byte{}),
+		},
+	})}
+	tamperedBlocks := append([]*types.Block{}, blocks...)
+	tamperedBlocks[5] = &tamperedBlock
+
+	forkBlocks := append([]*types.Block{}, blocks[:6]...)
+	forkBlocks = append(forkBlocks, mockBlocks(blocks[5], 10)...)
+
+	cases := []struct {
+		blocks  []*types.Block
+		work    *fetchBlocksWork
+		wantErr bool
+	}{
+		{
+			blocks: blocks[2:8],
+			work:   &fetchBlocksWork{startHeader: headers[2], stopHeader: headers[8], headers: headers[2:9]},
+		},
+		{
+			blocks: blocks[2:8],
+			work:   &fetchBlocksWork{startHeader: headers[2], stopHeader: headers[8]},
+		},
+		{
+			blocks:  tamperedBlocks[2:8],
+			work:    &fetchBlocksWork{startHeader: headers[2], stopHeader: headers[8], headers: headers[2:9]},
+			wantErr: true,
+		},
+		{
+			blocks:  forkBlocks[2:8],
+			work:    &fetchBlocksWork{startHeader: headers[2], stopHeader: headers[8], headers: headers[2:9]},
+			wantErr: true,
+		},
+		{
+			blocks:  blocks[2:10],
+			work:    &fetchBlocksWork{startHeader: headers[2], stopHeader: headers[8], headers: headers[2:9]},
+			wantErr: true,
+		},
+	}
+
+	mf := &msgFetcher{}
+	for i, c := range cases {
+		if err := mf.verifyBlocksMsg(c.blocks, c.work); (err != nil) != c.wantErr {
+			t.Errorf("case %d: got err %v, want err %v", i, err, c.wantErr)
+		}
+	}
+}
+
+func TestVerifyHeadersMsg(t *testing.T) {
+	blocks := mockBlocks(nil, 10)
+	forkBlocks := append([]*types.Block{}, blocks[:6]...)
+	forkBlocks = append(forkBlocks, mockBlocks(blocks[5], 10)...)
+	headersOf := func(blocks []*types.Block) []*types.BlockHeader {
+		headers := []*types.BlockHeader{}
+		for _, block := range blocks {
+			headers = append(headers, &block.BlockHeader)
+		}
+		return headers
+	}
+
+	cases := []struct {
+		headers []*types.BlockHeader
+		wantErr bool
+	}{
+		{
+			headers: headersOf(blocks[2:9]),
+		},
+		{
+			headers: headersOf(blocks[2:8]),
+			wantErr: true,
+		},
+		{
+			headers: headersOf(forkBlocks[2:9]),
+			wantErr: true,
+		},
+		{
+			headers: append(headersOf(blocks[2:5]), headersOf(blocks[5:9])[1:]...),
+			wantErr: true,
+		},
+	}
+
+	mf := &msgFetcher{}
+	for i, c := range cases {
+		if err := mf.verifyHeadersMsg(c.headers, &blocks[2].BlockHeader, &blocks[8].BlockHeader); (err != nil) != c.wantErr {
+			t.Errorf("case %d: got err %v, want err %v", i, err, c.wantErr)
+		}
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"kuskcore/consensus"
 	"kuskcore/event"
 	"kuskcore/netsync/peers"
+	"kuskcore/p2p"
+	"kuskcore/p2p/connection"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 	"kuskcore/test/mock"
@@ -63,6 +65,10 @@ func (p *P2PPeer) ServiceFlag() consensus.ServiceFlag {
 	return p.flag
 }
 
+func (p *P2PPeer) ProtocolVersion() uint32 {
+	return p2p.ProtocolVersion
+}
+
 func (p *P2PPeer) SetConnection(srcPeer *P2PPeer, node *Manager) {
 	p.srcPeer = srcPeer
 	p.remoteNode = node
@@ -72,6 +78,10 @@ func (p *P2PPeer) TrafficStatus() (*flowrate.Status, *flowrate.Status) {
 	return nil, nil
 }
 
+func (p *P2PPeer) ConnectionStatus() *connection.ConnectionStatus {
+	return &connection.ConnectionStatus{}
+}
+
 func (p *P2PPeer) TrySend(b byte, msg interface{}) bool {
 	msgBytes := wire.BinaryBytes(msg)
 	if p.async {
@@ -101,6 +111,10 @@ func NewPeerSet() *PeerSet {
 	return &PeerSet{}
 }
 
+func (ps *PeerSet) BanScore(ip string) uint32 {
+	return 0
+}
+
 func (ps *PeerSet) IsBanned(ip string, level byte, reason string) bool {
 	return false
 }
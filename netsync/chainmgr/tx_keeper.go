@@ -14,6 +14,10 @@ const (
 	// This is the target size for the packs of transactions sent by txSyncLoop.
 	// A pack can get larger than this if a single transactions exceeds this size.
 	txSyncPackSize = 100 * 1024
+
+	// txTrickleTick is the interval to check the randomized trickle time of
+	// the tx announcements of the peers, and the timeout tx requests
+	txTrickleTick = 100 * time.Millisecond
 )
 
 type txSyncMsg struct {
@@ -31,6 +35,12 @@ func (m *Manager) syncMempool(peerID string) {
 	for i, batch := range pending {
 		txs[i] = batch.Tx
 	}
+
+	// the full nodes are announced the pending txs and fetch the unknown ones
+	peer := m.peers.GetPeer(peerID)
+	if peer == nil || peer.QueueTxInvs(txs) {
+		return
+	}
 	m.txSyncCh <- &txSyncMsg{peerID, txs}
 }
 
@@ -61,6 +71,24 @@ func (m *Manager) broadcastTxsLoop() {
 	}
 }
 
+// txTrickleLoop announces the queued txs to the peers on their randomized
+// trickle time, and requests the txs again from the other announcers once the
+// requests timeout
+func (m *Manager) txTrickleLoop() {
+	ticker := time.NewTicker(txTrickleTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.peers.TrickleTxInvs()
+			m.peers.RetryTxRequests(m.mempool.HaveTransaction)
+		case <-m.quit:
+			return
+		}
+	}
+}
+
 // syncMempoolLoop takes care of the initial transaction sync for each new
 // connection. When a new peer appears, we relay all currently pending
 // transactions. In order to minimise egress bandwidth usage, we send
@@ -11,6 +11,7 @@ import (
 
 	"kuskcore/consensus"
 	dbm "kuskcore/database/leveldb"
+	msgs "kuskcore/netsync/messages"
 	"kuskcore/protocol"
 	core "kuskcore/protocol"
 	"kuskcore/protocol/bc"
@@ -52,6 +53,20 @@ func (m *mempool) GetTransactions() []*core.TxDesc {
 	return txs
 }
 
+func (m *mempool) GetTransaction(txHash *bc.Hash) (*core.TxDesc, error) {
+	for _, txDesc := range m.GetTransactions() {
+		if txDesc.Tx.ID == *txHash {
+			return txDesc, nil
+		}
+	}
+	return nil, core.ErrTransactionNotExist
+}
+
+func (m *mempool) HaveTransaction(txHash *bc.Hash) bool {
+	_, err := m.GetTransaction(txHash)
+	return err == nil
+}
+
 func (m *mempool) IsDust(tx *types.Tx) bool {
 	return false
 }
@@ -195,3 +210,78 @@ out:
 		}
 	}
 }
+
+type relayChain struct {
+	*mock.Chain
+	mempool *mock.Mempool
+}
+
+func (c *relayChain) ValidateTx(tx *types.Tx) (bool, error) {
+	c.mempool.AddTx(tx)
+	return false, nil
+}
+
+func TestTxInventoryRelay(t *testing.T) {
+	blocks := mockBlocks(nil, 5)
+	aMempool, bMempool := &mock.Mempool{}, &mock.Mempool{}
+	a := mockSync(blocks, aMempool, dbm.NewMemDB())
+	b := mockSync(blocks, bMempool, dbm.NewMemDB())
+	b.chain = &relayChain{Chain: b.chain.(*mock.Chain), mempool: bMempool}
+
+	txs, _ := mockTxs(10)
+	for _, tx := range txs[:5] {
+		aMempool.AddTx(tx)
+	}
+	bMempool.AddTx(txs[0])
+
+	// the messages are delivered synchronously so the relay finishes on the trickle
+	B2A := NewP2PPeer("192.168.0.1", "test node A", consensus.SFFullNode)
+	A2B := NewP2PPeer("192.168.0.2", "test node B", consensus.SFFullNode)
+	A2B.SetConnection(B2A, b)
+	B2A.SetConnection(A2B, a)
+	a.AddPeer(A2B)
+	b.AddPeer(B2A)
+
+	a.syncMempool("test node B")
+	a.peers.TrickleTxInvs()
+	for _, tx := range txs[5:] {
+		aMempool.AddTx(tx)
+		if err := a.peers.BroadcastTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(bMempool.GetTransactions()); got != 5 {
+		t.Fatalf("got %d txs before the trickle, want 5", got)
+	}
+
+	timeout := time.Now().Add(30 * time.Second)
+	for len(bMempool.GetTransactions()) < len(txs) && time.Now().Before(timeout) {
+		time.Sleep(txTrickleTick)
+		a.peers.TrickleTxInvs()
+	}
+
+	gotTxs := map[bc.Hash]int{}
+	for _, txD := range bMempool.GetTransactions() {
+		gotTxs[txD.Tx.ID]++
+	}
+
+	if len(gotTxs) != len(txs) {
+		t.Fatalf("got %d txs relayed, want %d", len(gotTxs), len(txs))
+	}
+
+	for _, tx := range txs {
+		if gotTxs[tx.ID] != 1 {
+			t.Errorf("tx %s is relayed %d times, want once", tx.ID.String(), gotTxs[tx.ID])
+		}
+	}
+
+	// the txs never announced to the peer aren't served
+	moreTxs, _ := mockTxs(len(txs) + 1)
+	unannounced := moreTxs[len(txs)]
+	aMempool.AddTx(unannounced)
+	a.handleGetTransactionsMsg(a.peers.GetPeer("test node B"), msgs.NewGetTransactionsMessage([]*bc.Hash{&unannounced.ID}))
+	if bMempool.HaveTransaction(&unannounced.ID) {
+		t.Fatalf("the unannounced tx is served")
+	}
+}
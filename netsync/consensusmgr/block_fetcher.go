@@ -1,11 +1,15 @@
 package consensusmgr
 
 import (
+	"sync"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
 
 	"kuskcore/p2p/security"
 	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
 )
 
 const (
@@ -17,24 +21,30 @@ const (
 // blockFetcher is responsible for accumulating block announcements from various peers
 // and scheduling them for retrieval.
 type blockFetcher struct {
-	chain Chain
-	peers Peers
+	chain  Chain
+	txPool TxPool
+	peers  Peers
 
 	newBlockCh chan *blockMsg
 	queue      *prque.Prque          // block import priority queue
 	msgSet     map[bc.Hash]*blockMsg // already queued blocks
 	msgCounter map[string]int        // per peer msg counter to prevent DOS
+
+	mtx      sync.Mutex
+	partials map[bc.Hash]*partialBlock // compact blocks waiting for the missing transactions
 }
 
 // NewBlockFetcher creates a block fetcher to retrieve blocks of the new propose.
-func newBlockFetcher(chain Chain, peers Peers) *blockFetcher {
+func newBlockFetcher(chain Chain, txPool TxPool, peers Peers) *blockFetcher {
 	return &blockFetcher{
 		chain:      chain,
+		txPool:     txPool,
 		peers:      peers,
 		newBlockCh: make(chan *blockMsg, newBlockChSize),
 		queue:      prque.New(),
 		msgSet:     make(map[bc.Hash]*blockMsg),
 		msgCounter: make(map[string]int),
+		partials:   make(map[bc.Hash]*partialBlock),
 	}
 }
 
@@ -99,18 +109,115 @@ func (f *blockFetcher) insert(msg *blockMsg) {
 		return
 	}
 
-	proposeMsg, err := NewBlockProposeMsg(msg.block)
+	proposedMsgs, err := newProposedBlockMsgs(msg.block)
 	if err != nil {
-		log.WithFields(log.Fields{"module": logModule, "err": err}).Error("failed on create BlockProposeMsg")
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Error("failed on create proposed block messages")
 		return
 	}
 
-	if err := f.peers.BroadcastMsg(NewBroadcastMsg(proposeMsg, consensusChannel)); err != nil {
-		log.WithFields(log.Fields{"module": logModule, "err": err}).Error("failed on broadcast proposed block")
-		return
+	for _, proposedMsg := range proposedMsgs {
+		if err := f.peers.BroadcastMsg(NewBroadcastMsg(proposedMsg, consensusChannel)); err != nil {
+			log.WithFields(log.Fields{"module": logModule, "err": err}).Error("failed on broadcast proposed block")
+			return
+		}
 	}
 }
 
 func (f *blockFetcher) processNewBlock(msg *blockMsg) {
 	f.newBlockCh <- msg
 }
+
+// processCompactBlock rebuilds the block of the compact block from the
+// mempool, the missing transactions are requested from the peer
+func (f *blockFetcher) processCompactBlock(peerID string, header *types.BlockHeader, msg *CompactBlockMsg) {
+	blockHash := header.Hash()
+	if known, err := f.chain.GetHeaderByHash(&blockHash); err == nil && known != nil {
+		return
+	}
+
+	bestHeight := f.chain.BestBlockHeight()
+	if bestHeight > header.Height || header.Height-bestHeight > maxBlockDistance {
+		return
+	}
+
+	block, missing, err := rebuildBlock(msg, header, f.txPool.GetTransactions())
+	if err != nil {
+		f.peers.ProcessIllegal(peerID, security.LevelMsgIllegal, err.Error())
+		return
+	}
+
+	if len(missing) == 0 {
+		f.processRebuiltBlock(peerID, block)
+		return
+	}
+
+	f.mtx.Lock()
+	for hash, partial := range f.partials {
+		if time.Since(partial.time) > partialBlockTimeout {
+			delete(f.partials, hash)
+		}
+	}
+
+	_, requested := f.partials[blockHash]
+	full := len(f.partials) >= maxNumOfPartials
+	if !requested && !full {
+		f.partials[blockHash] = &partialBlock{peerID: peerID, block: block, missing: missing, time: time.Now()}
+	}
+	f.mtx.Unlock()
+
+	switch {
+	case requested:
+	case full:
+		f.sendMsg(peerID, NewGetProposeBlockMsg(blockHash))
+	default:
+		f.sendMsg(peerID, NewGetBlockTxsMsg(blockHash, missing))
+	}
+}
+
+// processBlockTxs fills the missing transactions of the compact block
+func (f *blockFetcher) processBlockTxs(peerID string, msg *BlockTxsMsg) {
+	f.mtx.Lock()
+	partial, ok := f.partials[msg.BlockHash]
+	if ok && partial.peerID == peerID {
+		delete(f.partials, msg.BlockHash)
+	}
+	f.mtx.Unlock()
+	if !ok || partial.peerID != peerID {
+		return
+	}
+
+	txs, err := msg.GetTransactions()
+	if err != nil {
+		f.peers.ProcessIllegal(peerID, security.LevelMsgIllegal, err.Error())
+		return
+	}
+
+	if err := partial.fillMissingTxs(txs); err != nil {
+		f.peers.ProcessIllegal(peerID, security.LevelMsgIllegal, err.Error())
+		return
+	}
+
+	f.processRebuiltBlock(peerID, partial.block)
+}
+
+// processRebuiltBlock falls back to the full block when the rebuilt
+// transactions mismatch the merkle root
+func (f *blockFetcher) processRebuiltBlock(peerID string, block *types.Block) {
+	if !validateTxsMerkleRoot(block) {
+		f.sendMsg(peerID, NewGetProposeBlockMsg(block.Hash()))
+		return
+	}
+
+	f.processNewBlock(&blockMsg{peerID: peerID, block: block})
+}
+
+func (f *blockFetcher) sendMsg(peerID string, msg ConsensusMessage) {
+	peer := f.peers.GetPeer(peerID)
+	if peer == nil {
+		return
+	}
+
+	if ok := peer.TrySend(consensusChannel, struct{ ConsensusMessage }{msg}); !ok {
+		log.WithFields(log.Fields{"module": logModule, "peer": peerID, "message": msg.String()}).Warning("failed on send message to peer")
+	}
+}
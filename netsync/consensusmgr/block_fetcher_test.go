@@ -13,6 +13,10 @@ import (
 type peerMgr struct {
 }
 
+func (pm *peerMgr) BanScore(ip string) uint32 {
+	return 0
+}
+
 func (pm *peerMgr) IsBanned(ip string, level byte, reason string) bool {
 	return false
 }
@@ -37,6 +41,10 @@ func (c *chain) BestBlockHeight() uint64 {
 	return c.blocks[len(c.blocks)-1]
 }
 
+func (c *chain) GetBlockByHash(*bc.Hash) (*types.Block, error) {
+	return nil, nil
+}
+
 func (c *chain) GetHeaderByHash(*bc.Hash) (*types.BlockHeader, error) {
 	return nil, nil
 }
@@ -127,7 +135,7 @@ func TestBlockFetcher(t *testing.T) {
 			height: 105,
 		},
 	}
-	fetcher := newBlockFetcher(newChain(), peers)
+	fetcher := newBlockFetcher(newChain(), &mockTxPool{}, peers)
 	go fetcher.blockProcessorLoop()
 	for i, c := range testCase {
 		fetcher.processNewBlock(c.blockMsg)
@@ -276,7 +284,7 @@ func TestAddBlockMsg(t *testing.T) {
 	}
 
 	for i, c := range testCase {
-		fetcher := newBlockFetcher(newChain(), peers)
+		fetcher := newBlockFetcher(newChain(), &mockTxPool{}, peers)
 		for _, msg := range c.blocksMsg {
 			fetcher.add(msg, c.limit)
 		}
@@ -0,0 +1,156 @@
+package consensusmgr
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"kuskcore/crypto/sha3pool"
+	"kuskcore/protocol"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+const (
+	shortIDMask         = uint64(1)<<48 - 1
+	partialBlockTimeout = 10 * time.Second
+	maxNumOfPartials    = 16
+)
+
+var (
+	errInvalidPrefilledTxs = errors.New("invalid prefilled transactions of the compact block")
+	errMismatchBlockTxs    = errors.New("mismatch number of the missing transactions")
+)
+
+// partialBlock is the compact block waiting for the missing transactions
+type partialBlock struct {
+	peerID  string
+	block   *types.Block
+	missing []uint64
+	time    time.Time
+}
+
+// shortID is the lower 6 bytes of the hash of the tx id keyed by the block
+// hash and the nonce of the compact block
+func shortID(blockHash *bc.Hash, nonce uint64, txID *bc.Hash) uint64 {
+	var nonceBytes [8]byte
+	binary.BigEndian.PutUint64(nonceBytes[:], nonce)
+
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+
+	h.Write(blockHash.Bytes())
+	h.Write(nonceBytes[:])
+	h.Write(txID.Bytes())
+
+	var hash [32]byte
+	h.Read(hash[:])
+	return binary.BigEndian.Uint64(hash[:8]) & shortIDMask
+}
+
+// calcShortIDs returns the short ids of the transactions, it's not ok when
+// the short ids collide
+func calcShortIDs(blockHash *bc.Hash, nonce uint64, txs []*types.Tx) ([]uint64, bool) {
+	shortIDs := make([]uint64, 0, len(txs))
+	seen := make(map[uint64]bool, len(txs))
+	for _, tx := range txs {
+		id := shortID(blockHash, nonce, &tx.ID)
+		if seen[id] {
+			return nil, false
+		}
+
+		seen[id] = true
+		shortIDs = append(shortIDs, id)
+	}
+	return shortIDs, true
+}
+
+// rebuildBlock fills the transactions of the compact block by the prefilled
+// ones and the mempool, it returns the indexes of the missing transactions
+func rebuildBlock(msg *CompactBlockMsg, header *types.BlockHeader, pool []*protocol.TxDesc) (*types.Block, []uint64, error) {
+	if len(msg.PrefilledIndexes) != len(msg.RawPrefilledTxs) {
+		return nil, nil, errInvalidPrefilledTxs
+	}
+
+	total := uint64(len(msg.ShortIDs) + len(msg.PrefilledIndexes))
+	block := &types.Block{BlockHeader: *header, Transactions: make([]*types.Tx, total)}
+	for i, index := range msg.PrefilledIndexes {
+		if index >= total || (i > 0 && index <= msg.PrefilledIndexes[i-1]) {
+			return nil, nil, errInvalidPrefilledTxs
+		}
+
+		tx := &types.Tx{}
+		if err := tx.UnmarshalText(msg.RawPrefilledTxs[i]); err != nil {
+			return nil, nil, err
+		}
+		block.Transactions[index] = tx
+	}
+
+	// the transactions of the same short id are ambiguous, they're requested
+	blockHash := header.Hash()
+	poolTxs := make(map[uint64]*types.Tx, len(pool))
+	for _, txDesc := range pool {
+		id := shortID(&blockHash, msg.Nonce, &txDesc.Tx.ID)
+		if _, ok := poolTxs[id]; ok {
+			poolTxs[id] = nil
+			continue
+		}
+		poolTxs[id] = txDesc.Tx
+	}
+
+	missing := []uint64{}
+	for index, i := uint64(0), 0; index < total; index++ {
+		if block.Transactions[index] != nil {
+			continue
+		}
+
+		if tx := poolTxs[msg.ShortIDs[i]]; tx != nil {
+			block.Transactions[index] = tx
+		} else {
+			missing = append(missing, index)
+		}
+		i++
+	}
+	return block, missing, nil
+}
+
+// fillMissingTxs fills the missing transactions responsed by the peer
+func (p *partialBlock) fillMissingTxs(txs []*types.Tx) error {
+	if len(txs) != len(p.missing) {
+		return errMismatchBlockTxs
+	}
+
+	for i, index := range p.missing {
+		p.block.Transactions[index] = txs[i]
+	}
+	return nil
+}
+
+// newProposedBlockMsgs returns the messages relaying the proposed block, the
+// compact block is broadcasted first to the peers supporting it, and the
+// full block to the rest peers still not knowing the block
+func newProposedBlockMsgs(block *types.Block) ([]ConsensusMessage, error) {
+	compactMsg, err := NewCompactBlockMsg(block)
+	if err != nil {
+		return nil, err
+	}
+
+	proposeMsg, err := NewBlockProposeMsg(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return []ConsensusMessage{compactMsg, proposeMsg}, nil
+}
+
+// validateTxsMerkleRoot checks the rebuilt transactions match the merkle root
+// of the block header, it fails on the short id collision of the mempool
+func validateTxsMerkleRoot(block *types.Block) bool {
+	bcTxs := make([]*bc.Tx, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		bcTxs = append(bcTxs, tx.Tx)
+	}
+
+	merkleRoot, err := types.TxMerkleRoot(bcTxs)
+	return err == nil && merkleRoot == block.TransactionsMerkleRoot
+}
@@ -0,0 +1,319 @@
+package consensusmgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tendermint/go-wire"
+
+	"kuskcore/netsync/peers"
+	"kuskcore/p2p"
+	"kuskcore/protocol"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+const (
+	// the link of the simulated peers to compare the latency of the relay modes
+	mockBandwidth = 1024 * 1024 // bytes per second
+	mockRTT       = 100 * time.Millisecond
+)
+
+type recordPeer struct {
+	p2peer
+	msgs []ConsensusMessage
+}
+
+func (p *recordPeer) TrySend(chID byte, msg interface{}) bool {
+	p.msgs = append(p.msgs, msg.(struct{ ConsensusMessage }).ConsensusMessage)
+	return true
+}
+
+type recordPeers struct {
+	*mockPeers
+	peer    *recordPeer
+	illegal int
+}
+
+func (ps *recordPeers) GetPeer(id string) *peers.Peer {
+	return &peers.Peer{BasePeer: ps.peer}
+}
+
+func (ps *recordPeers) ProcessIllegal(peerID string, level byte, reason string) {
+	ps.illegal++
+}
+
+func mockTxsBlock(numOfTxs int) *types.Block {
+	block := &types.Block{BlockHeader: types.BlockHeader{Version: 1, Height: 100}}
+	block.Transactions = append(block.Transactions, types.NewTx(types.TxData{
+		Version: 1,
+		Inputs:  []*types.TxInput{types.NewCoinbaseInput([]byte{0x01})},
+		Outputs: []*types.TxOutput{types.NewOriginalTxOutput(bc.AssetID{V0: 1}, 100, []byte{0x00, 0x14}, nil)},
+	}))
+
+	for i := 0; i < numOfTxs; i++ {
+		block.Transactions = append(block.Transactions, types.NewTx(types.TxData{
+			Version: 1,
+			Inputs:  []*types.TxInput{types.NewSpendInput([][]byte{make([]byte, 64), make([]byte, 32)}, bc.Hash{V0: uint64(i + 1)}, bc.AssetID{V0: 1}, 1000, 0, make([]byte, 22), nil)},
+			Outputs: []*types.TxOutput{types.NewOriginalTxOutput(bc.AssetID{V0: 1}, 900, make([]byte, 22), nil)},
+		}))
+	}
+
+	bcTxs := []*bc.Tx{}
+	for _, tx := range block.Transactions {
+		bcTxs = append(bcTxs, tx.Tx)
+	}
+
+	merkleRoot, err := types.TxMerkleRoot(bcTxs)
+	if err != nil {
+		panic(err)
+	}
+
+	block.TransactionsMerkleRoot = merkleRoot
+	return block
+}
+
+func mockPool(txs []*types.Tx) []*protocol.TxDesc {
+	pool := []*protocol.TxDesc{}
+	for _, tx := range txs {
+		pool = append(pool, &protocol.TxDesc{Tx: tx})
+	}
+	return pool
+}
+
+// relayLatency is the transfer time of the messages plus half of the RTT
+// per message on the simulated link
+func relayLatency(msgs ...ConsensusMessage) (int, time.Duration) {
+	size := 0
+	for _, msg := range msgs {
+		size += len(wire.BinaryBytes(struct{ ConsensusMessage }{msg}))
+	}
+	return size, time.Duration(size)*time.Second/mockBandwidth + time.Duration(len(msgs))*mockRTT/2
+}
+
+func TestCompactBlockRelay(t *testing.T) {
+	block := mockTxsBlock(1000)
+	proposeMsg, err := NewBlockProposeMsg(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fullSize, fullLatency := relayLatency(proposeMsg)
+	cases := []struct {
+		desc       string
+		pool       []*types.Tx
+		numMissing int
+	}{
+		{desc: "all transactions in mempool", pool: block.Transactions[1:]},
+		{desc: "part of transactions in mempool", pool: block.Transactions[101:], numMissing: 100},
+		{desc: "empty mempool", pool: nil, numMissing: 1000},
+	}
+
+	for _, c := range cases {
+		msg, err := NewCompactBlockMsg(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		compactMsg := msg.(*CompactBlockMsg)
+		header, err := compactMsg.GetBlockHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rebuilt, missing, err := rebuildBlock(compactMsg, header, mockPool(c.pool))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(missing) != c.numMissing {
+			t.Fatalf("%s: got %d missing transactions, want %d", c.desc, len(missing), c.numMissing)
+		}
+
+		relayMsgs := []ConsensusMessage{compactMsg}
+		if len(missing) > 0 {
+			missingTxs := []*types.Tx{}
+			for _, index := range missing {
+				missingTxs = append(missingTxs, block.Transactions[index])
+			}
+
+			blockTxsMsg, err := NewBlockTxsMsg(block.Hash(), missingTxs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			txs, err := blockTxsMsg.(*BlockTxsMsg).GetTransactions()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			partial := &partialBlock{block: rebuilt, missing: missing}
+			if err := partial.fillMissingTxs(txs); err != nil {
+				t.Fatal(err)
+			}
+			relayMsgs = append(relayMsgs, NewGetBlockTxsMsg(block.Hash(), missing), blockTxsMsg)
+		}
+
+		if !validateTxsMerkleRoot(rebuilt) || rebuilt.Hash() != block.Hash() {
+			t.Fatalf("%s: the rebuilt block mismatch the propose block", c.desc)
+		}
+
+		compactSize, compactLatency := relayLatency(relayMsgs...)
+		t.Logf("%s: compact relay %d bytes in %v, full relay %d bytes in %v", c.desc, compactSize, compactLatency, fullSize, fullLatency)
+		if c.numMissing <= 100 && (compactSize*5 > fullSize || compactLatency >= fullLatency) {
+			t.Errorf("%s: compact relay %d bytes in %v, full relay %d bytes in %v", c.desc, compactSize, compactLatency, fullSize, fullLatency)
+		}
+	}
+}
+
+func TestProcessCompactBlock(t *testing.T) {
+	block := mockTxsBlock(10)
+	forged := *block
+	forged.TransactionsMerkleRoot = bc.Hash{V0: 1}
+
+	cases := []struct {
+		desc      string
+		block     *types.Block
+		pool      []*types.Tx
+		wantSent  []byte
+		wantBlock bool
+	}{
+		{desc: "rebuilt from mempool", block: block, pool: block.Transactions[1:], wantBlock: true},
+		{desc: "request the missing transactions", block: block, pool: block.Transactions[3:], wantSent: []byte{getBlockTxsByte}, wantBlock: true},
+		{desc: "fall back to the full block", block: &forged, pool: block.Transactions[1:], wantSent: []byte{getProposeBlockByte}},
+	}
+
+	for _, c := range cases {
+		msgCount := 0
+		peer := &recordPeer{}
+		ps := &recordPeers{mockPeers: newMockPeers(&msgCount, nil, nil, nil), peer: peer}
+		fetcher := newBlockFetcher(newChain(), &mockTxPool{txs: mockPool(c.pool)}, ps)
+
+		msg, err := NewCompactBlockMsg(c.block)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		compactMsg := msg.(*CompactBlockMsg)
+		header, err := compactMsg.GetBlockHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fetcher.processCompactBlock("peer", header, compactMsg)
+		if len(peer.msgs) == 1 {
+			if getTxsMsg, ok := peer.msgs[0].(*GetBlockTxsMsg); ok {
+				txs := []*types.Tx{}
+				for _, index := range getTxsMsg.Indexes {
+					txs = append(txs, block.Transactions[index])
+				}
+
+				blockTxsMsg, err := NewBlockTxsMsg(getTxsMsg.BlockHash, txs)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// the response from other peers are ignored
+				fetcher.processBlockTxs("other", blockTxsMsg.(*BlockTxsMsg))
+				fetcher.processBlockTxs("peer", blockTxsMsg.(*BlockTxsMsg))
+			}
+		}
+
+		if len(peer.msgs) != len(c.wantSent) {
+			t.Fatalf("%s: got %d messages sent, want %d", c.desc, len(peer.msgs), len(c.wantSent))
+		}
+
+		for i, msg := range peer.msgs {
+			msgType, _, err := decodeMessage(wire.BinaryBytes(struct{ ConsensusMessage }{msg}))
+			if err != nil || msgType != c.wantSent[i] {
+				t.Fatalf("%s: got message type %x, error %v, want %x", c.desc, msgType, err, c.wantSent[i])
+			}
+		}
+
+		select {
+		case got := <-fetcher.newBlockCh:
+			if !c.wantBlock || got.block.Hash() != block.Hash() || !validateTxsMerkleRoot(got.block) {
+				t.Fatalf("%s: got unexpected rebuilt block", c.desc)
+			}
+		default:
+			if c.wantBlock {
+				t.Fatalf("%s: the rebuilt block is not processed", c.desc)
+			}
+		}
+
+		if ps.illegal != 0 || len(fetcher.partials) != 0 {
+			t.Fatalf("%s: got %d illegal messages, %d partial blocks", c.desc, ps.illegal, len(fetcher.partials))
+		}
+	}
+}
+
+func TestRebuildInvalidCompactBlock(t *testing.T) {
+	block := mockTxsBlock(3)
+	msg, err := NewCompactBlockMsg(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compactMsg := msg.(*CompactBlockMsg)
+	compactMsg.PrefilledIndexes = []uint64{4}
+	if _, _, err := rebuildBlock(compactMsg, &block.BlockHeader, nil); err != errInvalidPrefilledTxs {
+		t.Fatalf("got error %v, want %v", err, errInvalidPrefilledTxs)
+	}
+
+	partial := &partialBlock{block: block, missing: []uint64{1, 2}}
+	if err := partial.fillMissingTxs(block.Transactions[1:2]); err != errMismatchBlockTxs {
+		t.Fatalf("got error %v, want %v", err, errMismatchBlockTxs)
+	}
+}
+
+type versionPeer struct {
+	recordPeer
+	id      string
+	version uint32
+}
+
+func (p *versionPeer) ID() string {
+	return p.id
+}
+
+func (p *versionPeer) ProtocolVersion() uint32 {
+	return p.version
+}
+
+func TestBroadcastProposedBlock(t *testing.T) {
+	compactPeer := &versionPeer{id: "compact", version: p2p.CompactBlockVersion}
+	legacyPeer := &versionPeer{id: "legacy", version: p2p.CompactBlockVersion - 1}
+	ps := peers.NewPeerSet(&peerMgr{})
+	ps.AddPeer(compactPeer)
+	ps.AddPeer(legacyPeer)
+
+	block := mockTxsBlock(10)
+	msgs, err := newProposedBlockMsgs(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range msgs {
+		if err := ps.BroadcastMsg(NewBroadcastMsg(msg, consensusChannel)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		peer     *versionPeer
+		wantType byte
+	}{
+		{peer: compactPeer, wantType: compactBlockByte},
+		{peer: legacyPeer, wantType: blockProposeByte},
+	}
+	for _, c := range cases {
+		if len(c.peer.msgs) != 1 {
+			t.Fatalf("peer %s: got %d messages, want 1", c.peer.id, len(c.peer.msgs))
+		}
+
+		msgType, _, err := decodeMessage(wire.BinaryBytes(struct{ ConsensusMessage }{c.peer.msgs[0]}))
+		if err != nil || msgType != c.wantType {
+			t.Errorf("peer %s: got message type %x, error %v, want %x", c.peer.id, msgType, err, c.wantType)
+		}
+	}
+}
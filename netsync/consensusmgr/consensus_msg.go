@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/rand"
 
 	"github.com/tendermint/go-wire"
 
@@ -14,8 +15,12 @@ import (
 )
 
 const (
-	blockSignatureByte = byte(0x10)
-	blockProposeByte   = byte(0x11)
+	blockSignatureByte  = byte(0x10)
+	blockProposeByte    = byte(0x11)
+	compactBlockByte    = byte(0x12)
+	getBlockTxsByte     = byte(0x13)
+	blockTxsByte        = byte(0x14)
+	getProposeBlockByte = byte(0x15)
 )
 
 // ConsensusMessage is a generic message for consensus reactor.
@@ -29,6 +34,10 @@ var _ = wire.RegisterInterface(
 	struct{ ConsensusMessage }{},
 	wire.ConcreteType{O: &BlockVerificationMsg{}, Byte: blockSignatureByte},
 	wire.ConcreteType{O: &BlockProposeMsg{}, Byte: blockProposeByte},
+	wire.ConcreteType{O: &CompactBlockMsg{}, Byte: compactBlockByte},
+	wire.ConcreteType{O: &GetBlockTxsMsg{}, Byte: getBlockTxsByte},
+	wire.ConcreteType{O: &BlockTxsMsg{}, Byte: blockTxsByte},
+	wire.ConcreteType{O: &GetProposeBlockMsg{}, Byte: getProposeBlockByte},
 )
 
 // decodeMessage decode msg
@@ -134,3 +143,187 @@ func (bp *BlockProposeMsg) BroadcastFilterTargetPeers(ps *peers.PeerSet) []strin
 
 	return ps.PeersWithoutBlock(block.Hash())
 }
+
+// CompactBlockMsg announces the propose block by the header and the short ids
+// of the transactions, the receiver rebuilds the block from the mempool.
+type CompactBlockMsg struct {
+	RawBlockHeader   []byte
+	Nonce            uint64
+	ShortIDs         []uint64
+	PrefilledIndexes []uint64
+	RawPrefilledTxs  [][]byte
+}
+
+// NewCompactBlockMsg create new compact block msg, the coinbase transaction is
+// prefilled since it's never in the mempool.
+func NewCompactBlockMsg(block *types.Block) (ConsensusMessage, error) {
+	rawHeader, err := block.BlockHeader.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &CompactBlockMsg{RawBlockHeader: rawHeader, Nonce: rand.Uint64()}
+	if len(block.Transactions) == 0 {
+		return msg, nil
+	}
+
+	rawCoinbase, err := block.Transactions[0].MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	msg.PrefilledIndexes = []uint64{0}
+	msg.RawPrefilledTxs = [][]byte{rawCoinbase}
+
+	// the nonce is changed on the collision of the short ids
+	blockHash := block.Hash()
+	for {
+		shortIDs, ok := calcShortIDs(&blockHash, msg.Nonce, block.Transactions[1:])
+		if ok {
+			msg.ShortIDs = shortIDs
+			return msg, nil
+		}
+		msg.Nonce++
+	}
+}
+
+// GetBlockHeader get the header of the compact block from msg.
+func (cb *CompactBlockMsg) GetBlockHeader() (*types.BlockHeader, error) {
+	header := &types.BlockHeader{}
+	if err := header.UnmarshalText(cb.RawBlockHeader); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func (cb *CompactBlockMsg) String() string {
+	header, err := cb.GetBlockHeader()
+	if err != nil {
+		return "{err: wrong message}"
+	}
+	blockHash := header.Hash()
+	return fmt.Sprintf("{block_height: %d, block_hash: %s, short_ids: %d}", header.Height, blockHash.String(), len(cb.ShortIDs))
+}
+
+// BroadcastMarkSendRecord mark send message record to prevent messages from being sent repeatedly.
+func (cb *CompactBlockMsg) BroadcastMarkSendRecord(ps *peers.PeerSet, peers []string) {
+	header, err := cb.GetBlockHeader()
+	if err != nil {
+		return
+	}
+
+	hash := header.Hash()
+	for _, peer := range peers {
+		ps.MarkBlock(peer, &hash)
+		ps.MarkStatus(peer, header.Height)
+	}
+}
+
+// BroadcastFilterTargetPeers filter target peers to filter the nodes that need to send messages.
+func (cb *CompactBlockMsg) BroadcastFilterTargetPeers(ps *peers.PeerSet) []string {
+	header, err := cb.GetBlockHeader()
+	if err != nil {
+		return nil
+	}
+
+	var targets []string
+	for _, peerID := range ps.PeersWithoutBlock(header.Hash()) {
+		if peer := ps.GetPeer(peerID); peer != nil && peer.SupportCompactBlock() {
+			targets = append(targets, peerID)
+		}
+	}
+	return targets
+}
+
+// GetBlockTxsMsg requests the transactions of the compact block missing in
+// the mempool by the indexes in the block.
+type GetBlockTxsMsg struct {
+	BlockHash bc.Hash
+	Indexes   []uint64
+}
+
+// NewGetBlockTxsMsg create new get block transactions msg.
+func NewGetBlockTxsMsg(blockHash bc.Hash, indexes []uint64) ConsensusMessage {
+	return &GetBlockTxsMsg{BlockHash: blockHash, Indexes: indexes}
+}
+
+func (g *GetBlockTxsMsg) String() string {
+	return fmt.Sprintf("{block_hash: %s, indexes: %d}", g.BlockHash.String(), len(g.Indexes))
+}
+
+// BroadcastMarkSendRecord implements ConsensusMessage, the msg is only sent to the announcing peer.
+func (g *GetBlockTxsMsg) BroadcastMarkSendRecord(ps *peers.PeerSet, peers []string) {}
+
+// BroadcastFilterTargetPeers implements ConsensusMessage, the msg is only sent to the announcing peer.
+func (g *GetBlockTxsMsg) BroadcastFilterTargetPeers(ps *peers.PeerSet) []string {
+	return nil
+}
+
+// BlockTxsMsg responses the requested transactions of the compact block.
+type BlockTxsMsg struct {
+	BlockHash bc.Hash
+	RawTxs    [][]byte
+}
+
+// NewBlockTxsMsg create new block transactions msg.
+func NewBlockTxsMsg(blockHash bc.Hash, txs []*types.Tx) (ConsensusMessage, error) {
+	msg := &BlockTxsMsg{BlockHash: blockHash}
+	for _, tx := range txs {
+		rawTx, err := tx.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+
+		msg.RawTxs = append(msg.RawTxs, rawTx)
+	}
+	return msg, nil
+}
+
+// GetTransactions get the transactions from msg.
+func (bt *BlockTxsMsg) GetTransactions() ([]*types.Tx, error) {
+	txs := []*types.Tx{}
+	for _, rawTx := range bt.RawTxs {
+		tx := &types.Tx{}
+		if err := tx.UnmarshalText(rawTx); err != nil {
+			return nil, err
+		}
+
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+func (bt *BlockTxsMsg) String() string {
+	return fmt.Sprintf("{block_hash: %s, txs: %d}", bt.BlockHash.String(), len(bt.RawTxs))
+}
+
+// BroadcastMarkSendRecord implements ConsensusMessage, the msg is only sent to the requesting peer.
+func (bt *BlockTxsMsg) BroadcastMarkSendRecord(ps *peers.PeerSet, peers []string) {}
+
+// BroadcastFilterTargetPeers implements ConsensusMessage, the msg is only sent to the requesting peer.
+func (bt *BlockTxsMsg) BroadcastFilterTargetPeers(ps *peers.PeerSet) []string {
+	return nil
+}
+
+// GetProposeBlockMsg requests the full propose block when the compact block
+// can't be rebuilt, the block is responsed by BlockProposeMsg.
+type GetProposeBlockMsg struct {
+	BlockHash bc.Hash
+}
+
+// NewGetProposeBlockMsg create new get propose block msg.
+func NewGetProposeBlockMsg(blockHash bc.Hash) ConsensusMessage {
+	return &GetProposeBlockMsg{BlockHash: blockHash}
+}
+
+func (g *GetProposeBlockMsg) String() string {
+	return fmt.Sprintf("{block_hash: %s}", g.BlockHash.String())
+}
+
+// BroadcastMarkSendRecord implements ConsensusMessage, the msg is only sent to the announcing peer.
+func (g *GetProposeBlockMsg) BroadcastMarkSendRecord(ps *peers.PeerSet, peers []string) {}
+
+// BroadcastFilterTargetPeers implements ConsensusMessage, the msg is only sent to the announcing peer.
+func (g *GetProposeBlockMsg) BroadcastFilterTargetPeers(ps *peers.PeerSet) []string {
+	return nil
+}
@@ -15,6 +15,10 @@ var _ = wire.RegisterInterface(
 	struct{ ConsensusMessage }{},
 	wire.ConcreteType{O: &BlockVerificationMsg{}, Byte: blockSignatureByte},
 	wire.ConcreteType{O: &BlockProposeMsg{}, Byte: blockProposeByte},
+	wire.ConcreteType{O: &CompactBlockMsg{}, Byte: compactBlockByte},
+	wire.ConcreteType{O: &GetBlockTxsMsg{}, Byte: getBlockTxsByte},
+	wire.ConcreteType{O: &BlockTxsMsg{}, Byte: blockTxsByte},
+	wire.ConcreteType{O: &GetProposeBlockMsg{}, Byte: getProposeBlockByte},
 )
 
 func TestDecodeMessage(t *testing.T) {
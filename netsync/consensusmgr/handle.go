@@ -6,10 +6,12 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"kuskcore/consensus"
 	"kuskcore/event"
 	"kuskcore/netsync/peers"
 	"kuskcore/p2p"
 	"kuskcore/p2p/security"
+	"kuskcore/protocol"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 	"kuskcore/protocol/casper"
@@ -23,11 +25,17 @@ type Switch interface {
 // Chain is the interface for Kusk core.
 type Chain interface {
 	BestBlockHeight() uint64
+	GetBlockByHash(*bc.Hash) (*types.Block, error)
 	GetHeaderByHash(*bc.Hash) (*types.BlockHeader, error)
 	ProcessBlock(*types.Block) (bool, error)
 	ProcessBlockVerification(*casper.ValidCasperSignMsg) error
 }
 
+// TxPool is the interface of the mempool to rebuild the compact blocks.
+type TxPool interface {
+	GetTransactions() []*protocol.TxDesc
+}
+
 type Peers interface {
 	AddPeer(peer peers.BasePeer)
 	BroadcastMsg(bm peers.BroadcastMsg) error
@@ -56,12 +64,12 @@ type Manager struct {
 }
 
 // NewManager create new manager.
-func NewManager(sw Switch, chain Chain, peers Peers, dispatcher *event.Dispatcher) *Manager {
+func NewManager(sw Switch, chain Chain, txPool TxPool, peers Peers, dispatcher *event.Dispatcher) *Manager {
 	manager := &Manager{
 		sw:              sw,
 		chain:           chain,
 		peers:           peers,
-		blockFetcher:    newBlockFetcher(chain, peers),
+		blockFetcher:    newBlockFetcher(chain, txPool, peers),
 		eventDispatcher: dispatcher,
 		quit:            make(chan struct{}),
 	}
@@ -80,7 +88,14 @@ func (m *Manager) processMsg(peerID string, msgType byte, msg ConsensusMessage)
 		return
 	}
 
+	// the light nodes don't take part in the consensus
+	if !peer.ServiceFlag().IsAnyEnable(consensus.RelayServices) {
+		logrus.WithFields(logrus.Fields{"module": logModule, "peer": peer.Addr(), "type": reflect.TypeOf(msg)}).Debug("ignore consensus message from the light node")
+		return
+	}
+
 	logrus.WithFields(logrus.Fields{"module": logModule, "peer": peer.Addr(), "type": reflect.TypeOf(msg), "message": msg.String()}).Debug("receive message from peer")
+	peer.MarkMsgReceived(msg)
 
 	switch msg := msg.(type) {
 	case *BlockProposeMsg:
@@ -89,6 +104,18 @@ func (m *Manager) processMsg(peerID string, msgType byte, msg ConsensusMessage)
 	case *BlockVerificationMsg:
 		m.handleBlockVerificationMsg(peerID, msg)
 
+	case *CompactBlockMsg:
+		m.handleCompactBlockMsg(peerID, msg)
+
+	case *GetBlockTxsMsg:
+		m.handleGetBlockTxsMsg(peerID, msg)
+
+	case *BlockTxsMsg:
+		m.blockFetcher.processBlockTxs(peerID, msg)
+
+	case *GetProposeBlockMsg:
+		m.handleGetProposeBlockMsg(peerID, msg)
+
 	default:
 		logrus.WithFields(logrus.Fields{"module": logModule, "peer": peerID, "message_type": reflect.TypeOf(msg)}).Error("unhandled message type")
 	}
@@ -107,6 +134,61 @@ func (m *Manager) handleBlockProposeMsg(peerID string, msg *BlockProposeMsg) {
 	m.peers.SetStatus(peerID, block.Height, &hash)
 }
 
+func (m *Manager) handleCompactBlockMsg(peerID string, msg *CompactBlockMsg) {
+	header, err := msg.GetBlockHeader()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"module": logModule, "err": err}).Warning("failed on get compact block header")
+		return
+	}
+
+	hash := header.Hash()
+	m.peers.MarkBlock(peerID, &hash)
+	m.blockFetcher.processCompactBlock(peerID, header, msg)
+	m.peers.SetStatus(peerID, header.Height, &hash)
+}
+
+func (m *Manager) handleGetBlockTxsMsg(peerID string, msg *GetBlockTxsMsg) {
+	block, err := m.chain.GetBlockByHash(&msg.BlockHash)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"module": logModule, "err": err}).Warning("failed on get block of the missing transactions")
+		return
+	}
+
+	txs := make([]*types.Tx, 0, len(msg.Indexes))
+	for _, index := range msg.Indexes {
+		if index >= uint64(len(block.Transactions)) {
+			m.peers.ProcessIllegal(peerID, security.LevelMsgIllegal, "invalid index of the block transactions")
+			return
+		}
+
+		txs = append(txs, block.Transactions[index])
+	}
+
+	blockTxsMsg, err := NewBlockTxsMsg(msg.BlockHash, txs)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"module": logModule, "err": err}).Error("failed on create BlockTxsMsg")
+		return
+	}
+
+	m.blockFetcher.sendMsg(peerID, blockTxsMsg)
+}
+
+func (m *Manager) handleGetProposeBlockMsg(peerID string, msg *GetProposeBlockMsg) {
+	block, err := m.chain.GetBlockByHash(&msg.BlockHash)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"module": logModule, "err": err}).Warning("failed on get the requested propose block")
+		return
+	}
+
+	proposeMsg, err := NewBlockProposeMsg(block)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{"module": logModule, "err": err}).Error("failed on create BlockProposeMsg")
+		return
+	}
+
+	m.blockFetcher.sendMsg(peerID, proposeMsg)
+}
+
 func (m *Manager) handleBlockVerificationMsg(peerID string, msg *BlockVerificationMsg) {
 	m.peers.MarkBlockVerification(peerID, msg.Signature)
 	if err := m.chain.ProcessBlockVerification(&casper.ValidCasperSignMsg{
@@ -120,25 +202,25 @@ func (m *Manager) handleBlockVerificationMsg(peerID string, msg *BlockVerificati
 }
 
 func (m *Manager) blockProposeMsgBroadcastLoop() {
-	m.msgBroadcastLoop(event.NewProposedBlockEvent{}, func(data interface{}) (ConsensusMessage, error) {
+	m.msgBroadcastLoop(event.NewProposedBlockEvent{}, func(data interface{}) ([]ConsensusMessage, error) {
 		ev := data.(event.NewProposedBlockEvent)
-		return NewBlockProposeMsg(&ev.Block)
+		return newProposedBlockMsgs(&ev.Block)
 	})
 }
 
 func (m *Manager) blockVerificationMsgBroadcastLoop() {
-	m.msgBroadcastLoop(casper.ValidCasperSignMsg{}, func(data interface{}) (ConsensusMessage, error) {
+	m.msgBroadcastLoop(casper.ValidCasperSignMsg{}, func(data interface{}) ([]ConsensusMessage, error) {
 		v := data.(casper.ValidCasperSignMsg)
 		pubKey, err := hex.DecodeString(v.PubKey)
 		if err != nil {
 			return nil, err
 		}
 
-		return NewBlockVerificationMsg(v.SourceHash, v.TargetHash, pubKey, v.Signature), nil
+		return []ConsensusMessage{NewBlockVerificationMsg(v.SourceHash, v.TargetHash, pubKey, v.Signature)}, nil
 	})
 }
 
-func (m *Manager) msgBroadcastLoop(msgType interface{}, newMsg func(event interface{}) (ConsensusMessage, error)) {
+func (m *Manager) msgBroadcastLoop(msgType interface{}, newMsg func(event interface{}) ([]ConsensusMessage, error)) {
 	subscribeType := reflect.TypeOf(msgType)
 	msgSub, err := m.eventDispatcher.Subscribe(msgType)
 	if err != nil {
@@ -159,16 +241,18 @@ func (m *Manager) msgBroadcastLoop(msgType interface{}, newMsg func(event interf
 				continue
 			}
 
-			msg, err := newMsg(obj.Data)
+			msgs, err := newMsg(obj.Data)
 			if err != nil {
 				logrus.WithFields(logrus.Fields{"module": logModule, "err": err}).Errorf("failed on create %s message", subscribeType)
 				return
 			}
 
-			message := NewBroadcastMsg(msg, consensusChannel)
-			if err := m.peers.BroadcastMsg(message); err != nil {
-				logrus.WithFields(logrus.Fields{"module": logModule, "err": err}).Errorf("failed on broadcast %s message.", subscribeType)
-				continue
+			for _, msg := range msgs {
+				message := NewBroadcastMsg(msg, consensusChannel)
+				if err := m.peers.BroadcastMsg(message); err != nil {
+					logrus.WithFields(logrus.Fields{"module": logModule, "err": err}).Errorf("failed on broadcast %s message.", subscribeType)
+					break
+				}
 			}
 
 		case <-m.quit:
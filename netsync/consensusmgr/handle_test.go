@@ -13,6 +13,8 @@ import (
 	"kuskcore/event"
 	"kuskcore/netsync/peers"
 	"kuskcore/p2p"
+	"kuskcore/p2p/connection"
+	"kuskcore/protocol"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 	"kuskcore/protocol/casper"
@@ -37,11 +39,17 @@ func (p *p2peer) RemoteAddrHost() string {
 	return ""
 }
 func (p *p2peer) ServiceFlag() consensus.ServiceFlag {
-	return 0
+	return consensus.DefaultServices
+}
+func (p *p2peer) ProtocolVersion() uint32 {
+	return p2p.ProtocolVersion
 }
 func (p *p2peer) TrafficStatus() (*flowrate.Status, *flowrate.Status) {
 	return nil, nil
 }
+func (p *p2peer) ConnectionStatus() *connection.ConnectionStatus {
+	return &connection.ConnectionStatus{}
+}
 func (p *p2peer) TrySend(byte, interface{}) bool {
 	return true
 }
@@ -87,6 +95,10 @@ func (c *mockChain) BestBlockHeight() uint64 {
 	return 0
 }
 
+func (c *mockChain) GetBlockByHash(*bc.Hash) (*types.Block, error) {
+	return nil, nil
+}
+
 func (c *mockChain) GetHeaderByHash(*bc.Hash) (*types.BlockHeader, error) {
 	return nil, nil
 }
@@ -99,6 +111,14 @@ func (c *mockChain) ProcessBlockVerification(*casper.ValidCasperSignMsg) error {
 	return nil
 }
 
+type mockTxPool struct {
+	txs []*protocol.TxDesc
+}
+
+func (p *mockTxPool) GetTransactions() []*protocol.TxDesc {
+	return p.txs
+}
+
 type mockPeers struct {
 	msgCount       *int
 	knownBlock     *bc.Hash
@@ -148,7 +168,7 @@ func TestBlockProposeMsgBroadcastLoop(t *testing.T) {
 	dispatcher := event.NewDispatcher()
 	msgCount := 0
 	blockHeight := 100
-	mgr := NewManager(&mockSW{}, &mockChain{}, newMockPeers(&msgCount, nil, nil, nil), dispatcher)
+	mgr := NewManager(&mockSW{}, &mockChain{}, &mockTxPool{}, newMockPeers(&msgCount, nil, nil, nil), dispatcher)
 	blocks := mockBlocks(nil, uint64(blockHeight))
 
 	mgr.Start()
@@ -158,8 +178,9 @@ func TestBlockProposeMsgBroadcastLoop(t *testing.T) {
 		mgr.eventDispatcher.Post(event.NewProposedBlockEvent{Block: *block})
 	}
 	time.Sleep(10 * time.Millisecond)
-	if msgCount != blockHeight+1 {
-		t.Fatalf("broad propose block msg err. got:%d\n want:%d", msgCount, blockHeight+1)
+	// each block is broadcasted by the compact block and the full block
+	if msgCount != 2*(blockHeight+1) {
+		t.Fatalf("broad propose block msg err. got:%d\n want:%d", msgCount, 2*(blockHeight+1))
 	}
 }
 
@@ -167,7 +188,7 @@ func TestBlockVerificationMsgBroadcastLoop(t *testing.T) {
 	dispatcher := event.NewDispatcher()
 	msgCount := 0
 	blockHeight := 100
-	mgr := NewManager(&mockSW{}, &mockChain{}, newMockPeers(&msgCount, nil, nil, nil), dispatcher)
+	mgr := NewManager(&mockSW{}, &mockChain{}, &mockTxPool{}, newMockPeers(&msgCount, nil, nil, nil), dispatcher)
 	blocks := mockBlocks(nil, uint64(blockHeight))
 
 	mgr.Start()
@@ -188,7 +209,7 @@ func TestProcessBlockProposeMsg(t *testing.T) {
 	var knownBlock bc.Hash
 	blockHeight := uint64(0)
 	peerID := "Peer1"
-	mgr := NewManager(&mockSW{}, &mockChain{}, newMockPeers(&msgCount, &knownBlock, &blockHeight, nil), dispatcher)
+	mgr := NewManager(&mockSW{}, &mockChain{}, &mockTxPool{}, newMockPeers(&msgCount, &knownBlock, &blockHeight, nil), dispatcher)
 	block := &types.Block{
 		BlockHeader: types.BlockHeader{
 			Height:            100,
@@ -216,7 +237,7 @@ func TestProcessBlockVerificationMsg(t *testing.T) {
 	msgCount := 0
 	knownSignature := []byte{}
 	peerID := "Peer1"
-	mgr := NewManager(&mockSW{}, &mockChain{}, newMockPeers(&msgCount, nil, nil, &knownSignature), dispatcher)
+	mgr := NewManager(&mockSW{}, &mockChain{}, &mockTxPool{}, newMockPeers(&msgCount, nil, nil, &knownSignature), dispatcher)
 	block := &types.Block{
 		BlockHeader: types.BlockHeader{
 			Height:            100,
@@ -234,3 +255,36 @@ func TestProcessBlockVerificationMsg(t *testing.T) {
 		t.Fatalf("set peer status err. got:%d\n want:%d", knownSignature, signature)
 	}
 }
+
+type lightPeer struct {
+	p2peer
+}
+
+func (p *lightPeer) ServiceFlag() consensus.ServiceFlag {
+	return consensus.LightServices
+}
+
+type lightPeers struct {
+	*mockPeers
+}
+
+func (ps *lightPeers) GetPeer(id string) *peers.Peer {
+	return &peers.Peer{BasePeer: &lightPeer{}}
+}
+
+func TestProcessMsgFromLightNode(t *testing.T) {
+	var knownBlock bc.Hash
+	msgCount, blockHeight := 0, uint64(0)
+	ps := &lightPeers{newMockPeers(&msgCount, &knownBlock, &blockHeight, nil)}
+	mgr := NewManager(&mockSW{}, &mockChain{}, &mockTxPool{}, ps, event.NewDispatcher())
+	block := &types.Block{BlockHeader: types.BlockHeader{Height: 100, PreviousBlockHash: bc.NewHash([32]byte{0x1})}}
+	msg, err := NewBlockProposeMsg(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.processMsg("Peer1", 0, msg)
+	if knownBlock != (bc.Hash{}) || blockHeight != 0 {
+		t.Fatalf("the propose block of the light node is processed")
+	}
+}
@@ -35,6 +35,7 @@ func (cr *ConsensusReactor) GetChannels() []*connection.ChannelDescriptor {
 			ID:                consensusChannel,
 			Priority:          10,
 			SendQueueCapacity: 100,
+			StrictPriority:    true,
 		},
 	}
 }
@@ -15,24 +15,33 @@ import (
 const (
 	BlockchainChannel = byte(0x40)
 
-	BlockRequestByte    = byte(0x10)
-	BlockResponseByte   = byte(0x11)
-	HeadersRequestByte  = byte(0x12)
-	HeadersResponseByte = byte(0x13)
-	BlocksRequestByte   = byte(0x14)
-	BlocksResponseByte  = byte(0x15)
-	StatusByte          = byte(0x21)
-	NewTransactionByte  = byte(0x30)
-	NewTransactionsByte = byte(0x31)
-	NewMineBlockByte    = byte(0x40)
-	FilterLoadByte      = byte(0x50)
-	FilterAddByte       = byte(0x51)
-	FilterClearByte     = byte(0x52)
-	MerkleRequestByte   = byte(0x60)
-	MerkleResponseByte  = byte(0x61)
+	BlockRequestByte          = byte(0x10)
+	BlockResponseByte         = byte(0x11)
+	HeadersRequestByte        = byte(0x12)
+	HeadersResponseByte       = byte(0x13)
+	BlocksRequestByte         = byte(0x14)
+	BlocksResponseByte        = byte(0x15)
+	StatusByte                = byte(0x21)
+	NewTransactionByte        = byte(0x30)
+	NewTransactionsByte       = byte(0x31)
+	TxInventoryByte           = byte(0x32)
+	TransactionsRequestByte   = byte(0x33)
+	NewMineBlockByte          = byte(0x40)
+	FilterLoadByte            = byte(0x50)
+	FilterAddByte             = byte(0x51)
+	FilterClearByte           = byte(0x52)
+	MerkleRequestByte         = byte(0x60)
+	MerkleResponseByte        = byte(0x61)
+	FiltersRequestByte        = byte(0x70)
+	FiltersResponseByte       = byte(0x71)
+	FilterHeadersRequestByte  = byte(0x72)
+	FilterHeadersResponseByte = byte(0x73)
+	ValidatorsRequestByte     = byte(0x74)
+	ValidatorsResponseByte    = byte(0x75)
 
 	MaxBlockchainResponseSize = 22020096 + 2
 	TxsMsgMaxTxNum            = 1024
+	TxInvMsgMaxNum            = 4096
 )
 
 // BlockchainMessage is a generic message for this reactor.
@@ -51,12 +60,20 @@ var _ = wire.RegisterInterface(
 	wire.ConcreteType{&StatusMessage{}, StatusByte},
 	wire.ConcreteType{&TransactionMessage{}, NewTransactionByte},
 	wire.ConcreteType{&TransactionsMessage{}, NewTransactionsByte},
+	wire.ConcreteType{&TxInventoryMessage{}, TxInventoryByte},
+	wire.ConcreteType{&GetTransactionsMessage{}, TransactionsRequestByte},
 	wire.ConcreteType{&MineBlockMessage{}, NewMineBlockByte},
 	wire.ConcreteType{&FilterLoadMessage{}, FilterLoadByte},
 	wire.ConcreteType{&FilterAddMessage{}, FilterAddByte},
 	wire.ConcreteType{&FilterClearMessage{}, FilterClearByte},
 	wire.ConcreteType{&GetMerkleBlockMessage{}, MerkleRequestByte},
 	wire.ConcreteType{&MerkleBlockMessage{}, MerkleResponseByte},
+	wire.ConcreteType{&GetBlockFiltersMessage{}, FiltersRequestByte},
+	wire.ConcreteType{&BlockFiltersMessage{}, FiltersResponseByte},
+	wire.ConcreteType{&GetFilterHeadersMessage{}, FilterHeadersRequestByte},
+	wire.ConcreteType{&FilterHeadersMessage{}, FilterHeadersResponseByte},
+	wire.ConcreteType{&GetValidatorsMessage{}, ValidatorsRequestByte},
+	wire.ConcreteType{&ValidatorsMessage{}, ValidatorsResponseByte},
 )
 
 // GetBlockMessage request blocks from remote peers by height/hash
@@ -369,6 +386,62 @@ func (m *TransactionsMessage) String() string {
 	return fmt.Sprintf("{tx_num: %d}", len(m.RawTxs))
 }
 
+// TxInventoryMessage announce the ids of the new txs
+type TxInventoryMessage struct {
+	RawTxIDs [][32]byte
+}
+
+// NewTxInventoryMessage construct announce new txs msg
+func NewTxInventoryMessage(txIDs []*bc.Hash) *TxInventoryMessage {
+	msg := &TxInventoryMessage{}
+	for _, txID := range txIDs {
+		msg.RawTxIDs = append(msg.RawTxIDs, txID.Byte32())
+	}
+	return msg
+}
+
+// GetTxIDs return the ids of the announced txs
+func (m *TxInventoryMessage) GetTxIDs() []*bc.Hash {
+	txIDs := make([]*bc.Hash, 0, len(m.RawTxIDs))
+	for _, rawTxID := range m.RawTxIDs {
+		txID := bc.NewHash(rawTxID)
+		txIDs = append(txIDs, &txID)
+	}
+	return txIDs
+}
+
+func (m *TxInventoryMessage) String() string {
+	return fmt.Sprintf("{tx_inv_num: %d}", len(m.RawTxIDs))
+}
+
+// GetTransactionsMessage request the announced txs from remote peers by id
+type GetTransactionsMessage struct {
+	RawTxIDs [][32]byte
+}
+
+// NewGetTransactionsMessage construct request txs msg
+func NewGetTransactionsMessage(txIDs []*bc.Hash) *GetTransactionsMessage {
+	msg := &GetTransactionsMessage{}
+	for _, txID := range txIDs {
+		msg.RawTxIDs = append(msg.RawTxIDs, txID.Byte32())
+	}
+	return msg
+}
+
+// GetTxIDs return the ids of the requested txs
+func (m *GetTransactionsMessage) GetTxIDs() []*bc.Hash {
+	txIDs := make([]*bc.Hash, 0, len(m.RawTxIDs))
+	for _, rawTxID := range m.RawTxIDs {
+		txID := bc.NewHash(rawTxID)
+		txIDs = append(txIDs, &txID)
+	}
+	return txIDs
+}
+
+func (m *GetTransactionsMessage) String() string {
+	return fmt.Sprintf("{tx_request_num: %d}", len(m.RawTxIDs))
+}
+
 // MineBlockMessage new mined block msg
 type MineBlockMessage struct {
 	RawBlock []byte
@@ -487,3 +560,125 @@ func (m *MerkleBlockMessage) String() string {
 func NewMerkleBlockMessage() *MerkleBlockMessage {
 	return &MerkleBlockMessage{}
 }
+
+// GetBlockFiltersMessage request the compact filters of the main chain blocks
+// by height range
+type GetBlockFiltersMessage struct {
+	StartHeight uint64
+	Count       uint64
+}
+
+func (m *GetBlockFiltersMessage) String() string {
+	return fmt.Sprintf("{start_height: %d, count: %d}", m.StartHeight, m.Count)
+}
+
+// BlockFiltersMessage return the compact filters with the hashes of the blocks
+type BlockFiltersMessage struct {
+	StartHeight    uint64
+	RawBlockHashes [][32]byte
+	Filters        [][]byte
+}
+
+// NewBlockFiltersMessage construct the block filters response msg
+func NewBlockFiltersMessage(startHeight uint64, blockHashes []*bc.Hash, filters [][]byte) *BlockFiltersMessage {
+	msg := &BlockFiltersMessage{StartHeight: startHeight, Filters: filters}
+	for _, hash := range blockHashes {
+		msg.RawBlockHashes = append(msg.RawBlockHashes, hash.Byte32())
+	}
+	return msg
+}
+
+// GetBlockHashes return the hashes of the filtered blocks
+func (m *BlockFiltersMessage) GetBlockHashes() []*bc.Hash {
+	blockHashes := []*bc.Hash{}
+	for _, rawHash := range m.RawBlockHashes {
+		hash := bc.NewHash(rawHash)
+		blockHashes = append(blockHashes, &hash)
+	}
+	return blockHashes
+}
+
+func (m *BlockFiltersMessage) String() string {
+	return fmt.Sprintf("{start_height: %d, filters_length: %d}", m.StartHeight, len(m.Filters))
+}
+
+// GetFilterHeadersMessage request the filter headers of the main chain blocks
+// by height range
+type GetFilterHeadersMessage struct {
+	StartHeight uint64
+	Count       uint64
+}
+
+func (m *GetFilterHeadersMessage) String() string {
+	return fmt.Sprintf("{start_height: %d, count: %d}", m.StartHeight, m.Count)
+}
+
+// FilterHeadersMessage return the filter headers, the previous filter header
+// is the one chained by the header of the start height
+type FilterHeadersMessage struct {
+	StartHeight         uint64
+	RawPrevFilterHeader [32]byte
+	RawFilterHeaders    [][32]byte
+}
+
+// NewFilterHeadersMessage construct the filter headers response msg
+func NewFilterHeadersMessage(startHeight uint64, prevFilterHeader *bc.Hash, filterHeaders []*bc.Hash) *FilterHeadersMessage {
+	msg := &FilterHeadersMessage{StartHeight: startHeight, RawPrevFilterHeader: prevFilterHeader.Byte32()}
+	for _, header := range filterHeaders {
+		msg.RawFilterHeaders = append(msg.RawFilterHeaders, header.Byte32())
+	}
+	return msg
+}
+
+// GetPrevFilterHeader return the filter header before the start height
+func (m *FilterHeadersMessage) GetPrevFilterHeader() *bc.Hash {
+	hash := bc.NewHash(m.RawPrevFilterHeader)
+	return &hash
+}
+
+// GetFilterHeaders return the filter headers in the msg
+func (m *FilterHeadersMessage) GetFilterHeaders() []*bc.Hash {
+	filterHeaders := []*bc.Hash{}
+	for _, rawHeader := range m.RawFilterHeaders {
+		hash := bc.NewHash(rawHeader)
+		filterHeaders = append(filterHeaders, &hash)
+	}
+	return filterHeaders
+}
+
+func (m *FilterHeadersMessage) String() string {
+	return fmt.Sprintf("{start_height: %d, headers_length: %d}", m.StartHeight, len(m.RawFilterHeaders))
+}
+
+// GetValidatorsMessage request the votes changing the validators in the epoch
+// ending at the checkpoint
+type GetValidatorsMessage struct {
+	RawHash [32]byte
+}
+
+// GetHash return the hash of the checkpoint
+func (m *GetValidatorsMessage) GetHash() *bc.Hash {
+	hash := bc.NewHash(m.RawHash)
+	return &hash
+}
+
+func (m *GetValidatorsMessage) String() string {
+	return fmt.Sprintf("{hash: %s}", hex.EncodeToString(m.RawHash[:]))
+}
+
+// ValidatorsMessage return the blocks containing the votes in the epoch ending
+// at the checkpoint, the blocks are the merkle blocks of the vote transactions
+type ValidatorsMessage struct {
+	RawHash      [32]byte
+	MerkleBlocks []*MerkleBlockMessage
+}
+
+// GetHash return the hash of the checkpoint
+func (m *ValidatorsMessage) GetHash() *bc.Hash {
+	hash := bc.NewHash(m.RawHash)
+	return &hash
+}
+
+func (m *ValidatorsMessage) String() string {
+	return fmt.Sprintf("{hash: %s, blocks_length: %d}", hex.EncodeToString(m.RawHash[:]), len(m.MerkleBlocks))
+}
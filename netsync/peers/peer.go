@@ -13,6 +13,8 @@ import (
 	"kuskcore/consensus"
 	"kuskcore/errors"
 	msgs "kuskcore/netsync/messages"
+	"kuskcore/p2p"
+	"kuskcore/p2p/connection"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 )
@@ -40,7 +42,9 @@ type BasePeer interface {
 	ID() string
 	RemoteAddrHost() string
 	ServiceFlag() consensus.ServiceFlag
+	ProtocolVersion() uint32
 	TrafficStatus() (*flowrate.Status, *flowrate.Status)
+	ConnectionStatus() *connection.ConnectionStatus
 	TrySend(byte, interface{}) bool
 	IsLAN() bool
 }
@@ -49,6 +53,7 @@ type BasePeer interface {
 type BasePeerSet interface {
 	StopPeerGracefully(string)
 	IsBanned(ip string, level byte, reason string) bool
+	BanScore(ip string) uint32
 }
 
 type BroadcastMsg interface {
@@ -61,18 +66,25 @@ type BroadcastMsg interface {
 
 // PeerInfo indicate peer status snap
 type PeerInfo struct {
-	ID                  string `json:"peer_id"`
-	Moniker             string `json:"moniker"`
-	RemoteAddr          string `json:"remote_addr"`
-	Height              uint64 `json:"height"`
-	Ping                string `json:"ping"`
-	Duration            string `json:"duration"`
-	TotalSent           int64  `json:"total_sent"`
-	TotalReceived       int64  `json:"total_received"`
-	AverageSentRate     int64  `json:"average_sent_rate"`
-	AverageReceivedRate int64  `json:"average_received_rate"`
-	CurrentSentRate     int64  `json:"current_sent_rate"`
-	CurrentReceivedRate int64  `json:"current_received_rate"`
+	ID                  string   `json:"peer_id"`
+	Moniker             string   `json:"moniker"`
+	RemoteAddr          string   `json:"remote_addr"`
+	Services            []string `json:"services"`
+	Height              uint64   `json:"height"`
+	Ping                string   `json:"ping"`
+	Duration            string   `json:"duration"`
+	TotalSent           int64    `json:"total_sent"`
+	TotalReceived       int64    `json:"total_received"`
+	AverageSentRate     int64    `json:"average_sent_rate"`
+	AverageReceivedRate int64    `json:"average_received_rate"`
+	CurrentSentRate     int64    `json:"current_sent_rate"`
+	CurrentReceivedRate int64    `json:"current_received_rate"`
+
+	PingRTT          string                     `json:"ping_rtt"`
+	BanScore         uint32                     `json:"ban_score"`
+	Channels         []connection.ChannelStatus `json:"channels"`
+	SentMessages     map[string]uint64          `json:"sent_messages"`
+	ReceivedMessages map[string]uint64          `json:"received_messages"`
 }
 
 type Peer struct {
@@ -88,6 +100,9 @@ type Peer struct {
 	knownSignatures *set.Set // Set of block signatures known to be known by this peer
 	knownStatus     uint64   // Set of chain status known to be known by this peer
 	filterAdds      *set.Set // Set of addresses that the spv node cares about.
+	txRelay         *txRelay // Announce-then-fetch relay state of the transactions
+	statsMtx        sync.Mutex
+	msgStats        msgStats // Message counts of the peer by the message type
 }
 
 func newPeer(basePeer BasePeer) *Peer {
@@ -98,6 +113,7 @@ func newPeer(basePeer BasePeer) *Peer {
 		knownBlocks:     set.New(),
 		knownSignatures: set.New(),
 		filterAdds:      set.New(),
+		txRelay:         newTxRelay(),
 	}
 }
 
@@ -108,6 +124,13 @@ func (p *Peer) Height() uint64 {
 	return p.bestHeight
 }
 
+func (p *Peer) BestHash() *bc.Hash {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return p.bestHash
+}
+
 func (p *Peer) JustifiedHeight() uint64 {
 	p.mtx.RLock()
 	defer p.mtx.RUnlock()
@@ -159,6 +182,26 @@ func (p *Peer) GetHeaders(locator []*bc.Hash, stopHash *bc.Hash, skip uint64) bo
 	return p.TrySend(msgs.BlockchainChannel, msg)
 }
 
+// GetMerkleBlock requests the block filtered by the addresses loaded to the peer
+func (p *Peer) GetMerkleBlock(hash *bc.Hash) bool {
+	msg := struct{ msgs.BlockchainMessage }{&msgs.GetMerkleBlockMessage{RawHash: hash.Byte32()}}
+	return p.TrySend(msgs.BlockchainChannel, msg)
+}
+
+// GetValidators requests the votes changing the validators in the epoch ending
+// at the checkpoint
+func (p *Peer) GetValidators(hash *bc.Hash) bool {
+	msg := struct{ msgs.BlockchainMessage }{&msgs.GetValidatorsMessage{RawHash: hash.Byte32()}}
+	return p.TrySend(msgs.BlockchainChannel, msg)
+}
+
+// LoadFilter replaces the addresses filtering the blocks and the transactions
+// sent by the peer
+func (p *Peer) LoadFilter(addresses [][]byte) bool {
+	msg := struct{ msgs.BlockchainMessage }{&msgs.FilterLoadMessage{Addresses: addresses}}
+	return p.TrySend(msgs.BlockchainChannel, msg)
+}
+
 func (p *Peer) GetPeerInfo() *PeerInfo {
 	p.mtx.RLock()
 	defer p.mtx.RUnlock()
@@ -169,10 +212,13 @@ func (p *Peer) GetPeerInfo() *PeerInfo {
 		ping = -ping
 	}
 
+	connStatus := p.ConnectionStatus()
+	sentMsgs, receivedMsgs := p.msgCounts()
 	return &PeerInfo{
 		ID:                  p.ID(),
 		Moniker:             p.BasePeer.Moniker(),
 		RemoteAddr:          p.Addr().String(),
+		Services:            p.services.Names(),
 		Height:              p.bestHeight,
 		Ping:                ping.String(),
 		Duration:            sentStatus.Duration.String(),
@@ -182,6 +228,10 @@ func (p *Peer) GetPeerInfo() *PeerInfo {
 		AverageReceivedRate: receivedStatus.AvgRate,
 		CurrentSentRate:     sentStatus.CurRate,
 		CurrentReceivedRate: receivedStatus.CurRate,
+		PingRTT:             connStatus.PingRTT.String(),
+		Channels:            connStatus.Channels,
+		SentMessages:        sentMsgs,
+		ReceivedMessages:    receivedMsgs,
 	}
 }
 
@@ -195,6 +245,30 @@ func (p *Peer) getRelatedTxs(txs []*types.Tx) []*types.Tx {
 	return relatedTxs
 }
 
+func getVoteTxs(txs []*types.Tx) []*types.Tx {
+	var voteTxs []*types.Tx
+	for _, tx := range txs {
+		if isVoteTx(tx) {
+			voteTxs = append(voteTxs, tx)
+		}
+	}
+	return voteTxs
+}
+
+func isVoteTx(tx *types.Tx) bool {
+	for _, input := range tx.Inputs {
+		if input.InputType() == types.VetoInputType {
+			return true
+		}
+	}
+	for _, output := range tx.Outputs {
+		if output.OutputType() == types.VoteOutputType {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Peer) isRelatedTx(tx *types.Tx) bool {
 	for _, input := range tx.Inputs {
 		switch inp := input.TypedInput.(type) {
@@ -212,8 +286,14 @@ func (p *Peer) isRelatedTx(tx *types.Tx) bool {
 	return false
 }
 
+// SupportCompactBlock checks the peer relays the proposed blocks by the
+// compact blocks
+func (p *Peer) SupportCompactBlock() bool {
+	return p.ProtocolVersion() >= p2p.CompactBlockVersion
+}
+
 func (p *Peer) isSPVNode() bool {
-	return !p.services.IsEnable(consensus.SFFullNode)
+	return !p.services.IsAnyEnable(consensus.RelayServices)
 }
 
 func (p *Peer) MarkBlock(hash *bc.Hash) {
@@ -311,6 +391,41 @@ func (p *Peer) SendMerkleBlock(block *types.Block) (bool, error) {
 	return ok, nil
 }
 
+// SendValidators sends the blocks containing the votes in the epoch ending at
+// the checkpoint, the vote transactions are proved by the merkle blocks
+func (p *Peer) SendValidators(hash *bc.Hash, blocks []*types.Block) (bool, error) {
+	msg := &msgs.ValidatorsMessage{RawHash: hash.Byte32()}
+	for _, block := range blocks {
+		merkleBlock := msgs.NewMerkleBlockMessage()
+		if err := merkleBlock.SetRawBlockHeader(block.BlockHeader); err != nil {
+			return false, err
+		}
+
+		voteTxs := getVoteTxs(block.Transactions)
+		txHashes, txFlags := types.GetTxMerkleTreeProof(block.Transactions, voteTxs)
+		if err := merkleBlock.SetTxInfo(txHashes, txFlags, voteTxs); err != nil {
+			return false, err
+		}
+
+		msg.MerkleBlocks = append(msg.MerkleBlocks, merkleBlock)
+	}
+
+	ok := p.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{msg})
+	return ok, nil
+}
+
+// SendBlockFilters sends the compact filters of the blocks to the peer
+func (p *Peer) SendBlockFilters(startHeight uint64, blockHashes []*bc.Hash, filters [][]byte) bool {
+	msg := msgs.NewBlockFiltersMessage(startHeight, blockHashes, filters)
+	return p.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{msg})
+}
+
+// SendFilterHeaders sends the filter headers of the blocks to the peer
+func (p *Peer) SendFilterHeaders(startHeight uint64, prevFilterHeader *bc.Hash, filterHeaders []*bc.Hash) bool {
+	msg := msgs.NewFilterHeadersMessage(startHeight, prevFilterHeader, filterHeaders)
+	return p.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{msg})
+}
+
 func (p *Peer) SendTransactions(txs []*types.Tx) error {
 	validTxs := make([]*types.Tx, 0, len(txs))
 	for i, tx := range txs {
@@ -474,6 +589,9 @@ func (ps *PeerSet) BroadcastNewStatus(bestHeader, justifiedHeader *types.BlockHe
 	return nil
 }
 
+// BroadcastTx announces the tx to the full nodes on the next trickle, the spv
+// nodes are pushed the related tx since they can't request the unknown ones,
+// and the full nodes not supporting the announcements are pushed the tx
 func (ps *PeerSet) BroadcastTx(tx *types.Tx) error {
 	msg, err := msgs.NewTransactionMessage(tx)
 	if err != nil {
@@ -482,7 +600,7 @@ func (ps *PeerSet) BroadcastTx(tx *types.Tx) error {
 
 	peers := ps.peersWithoutTx(&tx.ID)
 	for _, peer := range peers {
-		if peer.isSPVNode() && !peer.isRelatedTx(tx) {
+		if peer.QueueTxInvs([]*types.Tx{tx}) || peer.isSPVNode() && !peer.isRelatedTx(tx) {
 			continue
 		}
 		if ok := peer.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{msg}); !ok {
@@ -520,13 +638,30 @@ func (ps *PeerSet) GetPeersByHeight(height uint64) []*Peer {
 	return peers
 }
 
+// GetPeersByService returns the peers not lower than the height and providing
+// the services
+func (ps *PeerSet) GetPeersByService(height uint64, flag consensus.ServiceFlag) []*Peer {
+	ps.mtx.RLock()
+	defer ps.mtx.RUnlock()
+
+	peers := []*Peer{}
+	for _, peer := range ps.peers {
+		if peer.Height() >= height && peer.services.IsEnable(flag) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
 func (ps *PeerSet) GetPeerInfos() []*PeerInfo {
 	ps.mtx.RLock()
 	defer ps.mtx.RUnlock()
 
 	result := []*PeerInfo{}
 	for _, peer := range ps.peers {
-		result = append(result, peer.GetPeerInfo())
+		peerInfo := peer.GetPeerInfo()
+		peerInfo.BanScore = ps.BanScore(peer.RemoteAddrHost())
+		result = append(result, peerInfo)
 	}
 	return result
 }
@@ -566,26 +701,30 @@ func (ps *PeerSet) MarkTx(peerID string, txHash bc.Hash) {
 	peer.markTransaction(&txHash)
 }
 
+// PeersWithoutBlock returns the peers not knowing the block, the light nodes
+// don't take part in the consensus
 func (ps *PeerSet) PeersWithoutBlock(hash bc.Hash) []string {
 	ps.mtx.RLock()
 	defer ps.mtx.RUnlock()
 
 	var peers []string
 	for _, peer := range ps.peers {
-		if !peer.knownBlocks.Has(hash.String()) {
+		if !peer.isSPVNode() && !peer.knownBlocks.Has(hash.String()) {
 			peers = append(peers, peer.ID())
 		}
 	}
 	return peers
 }
 
+// PeersWithoutSignature returns the peers not knowing the signature, the light
+// nodes don't take part in the consensus
 func (ps *PeerSet) PeersWithoutSignature(signature []byte) []string {
 	ps.mtx.RLock()
 	defer ps.mtx.RUnlock()
 
 	var peers []string
 	for _, peer := range ps.peers {
-		if !peer.knownSignatures.Has(hex.EncodeToString(signature)) {
+		if !peer.isSPVNode() && !peer.knownSignatures.Has(hex.EncodeToString(signature)) {
 			peers = append(peers, peer.ID())
 		}
 	}
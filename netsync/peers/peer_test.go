@@ -8,6 +8,8 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/tendermint/tmlibs/flowrate"
 	"kuskcore/consensus"
+	"kuskcore/p2p"
+	"kuskcore/p2p/connection"
 	"kuskcore/p2p/security"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
@@ -60,10 +62,18 @@ func (bp *basePeer) ServiceFlag() consensus.ServiceFlag {
 	return bp.serviceFlag
 }
 
+func (bp *basePeer) ProtocolVersion() uint32 {
+	return p2p.ProtocolVersion
+}
+
 func (bp *basePeer) TrafficStatus() (*flowrate.Status, *flowrate.Status) {
 	return nil, nil
 }
 
+func (bp *basePeer) ConnectionStatus() *connection.ConnectionStatus {
+	return &connection.ConnectionStatus{}
+}
+
 func (bp *basePeer) TrySend(byte, interface{}) bool {
 	return true
 }
@@ -187,6 +197,10 @@ func (bp *basePeerSet) StopPeerGracefully(string) {
 
 }
 
+func (bp *basePeerSet) BanScore(ip string) uint32 {
+	return 0
+}
+
 func (bp *basePeerSet) IsBanned(ip string, level byte, reason string) bool {
 	switch ip {
 	case peer1ID:
@@ -203,9 +217,10 @@ func (bp *basePeerSet) IsBanned(ip string, level byte, reason string) bool {
 
 func TestMarkBlock(t *testing.T) {
 	ps := NewPeerSet(&basePeerSet{})
-	ps.AddPeer(&basePeer{id: peer1ID})
-	ps.AddPeer(&basePeer{id: peer2ID})
-	ps.AddPeer(&basePeer{id: peer3ID})
+	ps.AddPeer(&basePeer{id: peer1ID, serviceFlag: consensus.SFFullNode})
+	ps.AddPeer(&basePeer{id: peer2ID, serviceFlag: consensus.SFFullNode})
+	ps.AddPeer(&basePeer{id: peer3ID, serviceFlag: consensus.SFPruned})
+	ps.AddPeer(&basePeer{id: peer4ID, serviceFlag: consensus.LightServices})
 
 	blockHash := bc.NewHash([32]byte{0x01, 0x02})
 	ps.MarkBlock(peer1ID, &blockHash)
@@ -261,9 +276,10 @@ func TestMarkStatus(t *testing.T) {
 
 func TestMarkBlockSignature(t *testing.T) {
 	ps := NewPeerSet(&basePeerSet{})
-	ps.AddPeer(&basePeer{id: peer1ID})
-	ps.AddPeer(&basePeer{id: peer2ID})
-	ps.AddPeer(&basePeer{id: peer3ID})
+	ps.AddPeer(&basePeer{id: peer1ID, serviceFlag: consensus.SFFullNode})
+	ps.AddPeer(&basePeer{id: peer2ID, serviceFlag: consensus.SFFullNode})
+	ps.AddPeer(&basePeer{id: peer3ID, serviceFlag: consensus.SFPruned})
+	ps.AddPeer(&basePeer{id: peer4ID, serviceFlag: consensus.LightServices})
 
 	signature := []byte{0x01, 0x02}
 	ps.MarkBlockVerification(peer1ID, signature)
@@ -381,6 +397,11 @@ func TestGetPeersByHeight(t *testing.T) {
 			t.Errorf("test get peers by height err. can't found target peer %s ", targetPeer)
 		}
 	}
+
+	peers = ps.GetPeersByService(2000, consensus.SFFullNode)
+	if len(peers) != 2 || peers[0].ID() == peer3ID || peers[1].ID() == peer3ID {
+		t.Fatalf("test get peers by service err. got %d peers", len(peers))
+	}
 }
 
 func TestRemovePeer(t *testing.T) {
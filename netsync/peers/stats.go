@@ -0,0 +1,71 @@
+package peers
+
+import (
+	"reflect"
+)
+
+// msgStats is the message counts of the peer by the message type
+type msgStats struct {
+	sent     map[string]uint64
+	received map[string]uint64
+}
+
+// msgTypeName returns the type name of the message, the messages are wrapped
+// by the anonymous struct of the message interface on sending
+func msgTypeName(msg interface{}) string {
+	v := reflect.ValueOf(msg)
+	if v.Kind() == reflect.Struct && v.Type().Name() == "" && v.NumField() == 1 {
+		v = v.Field(0)
+	}
+
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "nil"
+		}
+		v = v.Elem()
+	}
+	return v.Type().Name()
+}
+
+func countMsg(counts *map[string]uint64, msg interface{}) {
+	if *counts == nil {
+		*counts = make(map[string]uint64)
+	}
+	(*counts)[msgTypeName(msg)]++
+}
+
+func copyCounts(counts map[string]uint64) map[string]uint64 {
+	result := make(map[string]uint64, len(counts))
+	for msgType, count := range counts {
+		result[msgType] = count
+	}
+	return result
+}
+
+// TrySend sends the message by the connection level peer and counts the sent
+// message by the type
+func (p *Peer) TrySend(chID byte, msg interface{}) bool {
+	if ok := p.BasePeer.TrySend(chID, msg); !ok {
+		return false
+	}
+
+	p.statsMtx.Lock()
+	countMsg(&p.msgStats.sent, msg)
+	p.statsMtx.Unlock()
+	return true
+}
+
+// MarkMsgReceived counts the received message by the type
+func (p *Peer) MarkMsgReceived(msg interface{}) {
+	p.statsMtx.Lock()
+	countMsg(&p.msgStats.received, msg)
+	p.statsMtx.Unlock()
+}
+
+// msgCounts returns the copies of the sent and the received message counts
+func (p *Peer) msgCounts() (map[string]uint64, map[string]uint64) {
+	p.statsMtx.Lock()
+	defer p.statsMtx.Unlock()
+
+	return copyCounts(p.msgStats.sent), copyCounts(p.msgStats.received)
+}
@@ -0,0 +1,42 @@
+package peers
+
+import (
+	"reflect"
+	"testing"
+
+	msgs "kuskcore/netsync/messages"
+)
+
+func TestMsgTypeName(t *testing.T) {
+	cases := []struct {
+		msg  interface{}
+		want string
+	}{
+		{msg: struct{ msgs.BlockchainMessage }{&msgs.GetBlockMessage{}}, want: "GetBlockMessage"},
+		{msg: &msgs.StatusMessage{}, want: "StatusMessage"},
+		{msg: msgs.StatusMessage{}, want: "StatusMessage"},
+		{msg: struct{ msgs.BlockchainMessage }{}, want: "nil"},
+	}
+
+	for i, c := range cases {
+		if got := msgTypeName(c.msg); got != c.want {
+			t.Errorf("case %d: got message type %s, want %s", i, got, c.want)
+		}
+	}
+}
+
+func TestMsgCounts(t *testing.T) {
+	peer := newPeer(&basePeer{id: peer1ID})
+	peer.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{&msgs.GetBlockMessage{}})
+	peer.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{&msgs.GetBlockMessage{}})
+	peer.MarkMsgReceived(&msgs.StatusMessage{})
+
+	sent, received := peer.msgCounts()
+	if want := map[string]uint64{"GetBlockMessage": 2}; !reflect.DeepEqual(sent, want) {
+		t.Fatalf("got sent messages %v, want %v", sent, want)
+	}
+
+	if want := map[string]uint64{"StatusMessage": 1}; !reflect.DeepEqual(received, want) {
+		t.Fatalf("got received messages %v, want %v", received, want)
+	}
+}
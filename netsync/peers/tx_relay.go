@@ -0,0 +1,333 @@
+package peers
+
+import (
+	"math/rand"
+	"time"
+
+	"gopkg.in/fatih/set.v0"
+
+	msgs "kuskcore/netsync/messages"
+	"kuskcore/p2p"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+const (
+	avgTxTrickleInterval = time.Second // Average interval of the randomized tx announcements
+	maxQueuedTxInvs      = maxKnownTxs // Maximum transactions queued to announce to the peer
+	maxTxInvsPerTrickle  = 1024        // Maximum transactions announced to the peer per trickle
+	maxTxInvsPerSecond   = 4096        // Maximum transactions announced by the peer per second
+	maxRequestedTxs      = 4096        // Maximum transactions in flight requested from the peer
+	maxPendingTxs        = 4096        // Maximum transactions announced by the peer waiting for the retry
+	txRequestTimeout     = 30 * time.Second
+	txPendingTimeout     = 4 * txRequestTimeout
+)
+
+// txRelay is the announce-then-fetch bookkeeping of the peer
+type txRelay struct {
+	queuedInvs   []bc.Hash             // transactions waiting for the next announcement
+	nextTrickle  time.Time             // randomized time of the next announcement
+	requestedTxs map[bc.Hash]time.Time // transactions requested from the peer
+	pendingTxs   map[bc.Hash]time.Time // transactions announced by the peer but requested from the others
+	announcedTxs *set.Set              // transactions announced to the peer, only they are served
+	invWindow    time.Time             // start of the rate limit window of the received announcements
+	invCount     int                   // announcements received in the rate limit window
+}
+
+func newTxRelay() *txRelay {
+	return &txRelay{
+		requestedTxs: make(map[bc.Hash]time.Time),
+		pendingTxs:   make(map[bc.Hash]time.Time),
+		announcedTxs: set.New(),
+	}
+}
+
+// trickleInterval returns the exponentially distributed delay of the next
+// announcement, so the announcements don't leak the origin of the transactions
+func trickleInterval() time.Duration {
+	return time.Duration(rand.ExpFloat64() * float64(avgTxTrickleInterval))
+}
+
+// SupportTxInvs checks the peer relays the transactions by the announcements
+func (p *Peer) SupportTxInvs() bool {
+	return p.ProtocolVersion() >= p2p.TxInventoryVersion
+}
+
+// QueueTxInvs queues the transactions unknown by the peer to announce on the
+// next trickle, it returns false for the spv node since it filters the pushed
+// transactions instead, and for the peer not supporting the announcements.
+func (p *Peer) QueueTxInvs(txs []*types.Tx) bool {
+	if p.isSPVNode() || !p.SupportTxInvs() {
+		return false
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, tx := range txs {
+		if p.knownTxs.Has(tx.ID.String()) || len(p.txRelay.queuedInvs) >= maxQueuedTxInvs {
+			continue
+		}
+		p.txRelay.queuedInvs = append(p.txRelay.queuedInvs, tx.ID)
+	}
+	return true
+}
+
+// trickleTxInvs announces the queued transactions when the randomized trickle
+// time is reached, the order of the announcements is shuffled
+func (p *Peer) trickleTxInvs(now time.Time) bool {
+	p.mtx.Lock()
+	if now.Before(p.txRelay.nextTrickle) || len(p.txRelay.queuedInvs) == 0 {
+		p.mtx.Unlock()
+		return true
+	}
+
+	p.txRelay.nextTrickle = now.Add(trickleInterval())
+	invs := []*bc.Hash{}
+	num := len(p.txRelay.queuedInvs)
+	if num > maxTxInvsPerTrickle {
+		num = maxTxInvsPerTrickle
+	}
+
+	for _, hash := range p.txRelay.queuedInvs[:num] {
+		if !p.knownTxs.Has(hash.String()) {
+			invs = append(invs, &bc.Hash{V0: hash.V0, V1: hash.V1, V2: hash.V2, V3: hash.V3})
+		}
+	}
+	p.txRelay.queuedInvs = p.txRelay.queuedInvs[num:]
+	p.mtx.Unlock()
+
+	if len(invs) == 0 {
+		return true
+	}
+
+	// the announcements are marked before sent since the peer may request
+	// them right away
+	for _, hash := range invs {
+		p.markTransaction(hash)
+		p.markTxAnnounced(hash)
+	}
+
+	rand.Shuffle(len(invs), func(i, j int) { invs[i], invs[j] = invs[j], invs[i] })
+	msg := msgs.NewTxInventoryMessage(invs)
+	return p.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{msg})
+}
+
+func (p *Peer) markTxAnnounced(hash *bc.Hash) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for p.txRelay.announcedTxs.Size() >= maxKnownTxs {
+		p.txRelay.announcedTxs.Pop()
+	}
+	p.txRelay.announcedTxs.Add(hash.String())
+}
+
+// IsTxAnnounced checks whether the transaction is announced to the peer, the
+// peer is only allowed to request the announced transactions
+func (p *Peer) IsTxAnnounced(hash *bc.Hash) bool {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return p.txRelay.announcedTxs.Has(hash.String())
+}
+
+// AllowTxInvs limits the rate of the transactions announced by the peer
+func (p *Peer) AllowTxInvs(num int, now time.Time) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if now.Sub(p.txRelay.invWindow) >= time.Second {
+		p.txRelay.invWindow = now
+		p.txRelay.invCount = 0
+	}
+
+	p.txRelay.invCount += num
+	return p.txRelay.invCount <= maxTxInvsPerSecond
+}
+
+// isTxRequested checks whether the transaction is requested from the peer and
+// the request isn't timeout
+func (p *Peer) isTxRequested(hash *bc.Hash, now time.Time) bool {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	requested, ok := p.txRelay.requestedTxs[*hash]
+	return ok && now.Sub(requested) < txRequestTimeout
+}
+
+// requestTxs requests the announced transactions from the peer, the number of
+// the requests in flight is limited
+func (p *Peer) requestTxs(hashes []*bc.Hash, now time.Time) bool {
+	p.mtx.Lock()
+	for hash, requested := range p.txRelay.requestedTxs {
+		if now.Sub(requested) >= txRequestTimeout {
+			delete(p.txRelay.requestedTxs, hash)
+		}
+	}
+
+	requests := []*bc.Hash{}
+	for _, hash := range hashes {
+		if len(p.txRelay.requestedTxs) >= maxRequestedTxs {
+			break
+		}
+
+		p.txRelay.requestedTxs[*hash] = now
+		requests = append(requests, hash)
+	}
+	p.mtx.Unlock()
+
+	if len(requests) == 0 {
+		return true
+	}
+
+	msg := msgs.NewGetTransactionsMessage(requests)
+	return p.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{msg})
+}
+
+// addPendingTxs keeps the transactions announced by the peer while they're
+// requested from the other peers, they're requested again from the peer once
+// the other requests timeout
+func (p *Peer) addPendingTxs(hashes []*bc.Hash, now time.Time) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, hash := range hashes {
+		if _, ok := p.txRelay.pendingTxs[*hash]; !ok && len(p.txRelay.pendingTxs) >= maxPendingTxs {
+			continue
+		}
+		p.txRelay.pendingTxs[*hash] = now
+	}
+}
+
+// pendingTxHashes returns the pending transactions of the peer, the expired
+// ones are dropped
+func (p *Peer) pendingTxHashes(now time.Time) []*bc.Hash {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	hashes := []*bc.Hash{}
+	for hash, announced := range p.txRelay.pendingTxs {
+		if now.Sub(announced) >= txPendingTimeout {
+			delete(p.txRelay.pendingTxs, hash)
+			continue
+		}
+		hashes = append(hashes, &bc.Hash{V0: hash.V0, V1: hash.V1, V2: hash.V2, V3: hash.V3})
+	}
+	return hashes
+}
+
+func (p *Peer) removePendingTxs(hashes []*bc.Hash) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for _, hash := range hashes {
+		delete(p.txRelay.pendingTxs, *hash)
+	}
+}
+
+// markTxReceived clears the request of the transaction received from the peer
+func (p *Peer) markTxReceived(hash *bc.Hash) {
+	p.mtx.Lock()
+	delete(p.txRelay.requestedTxs, *hash)
+	p.mtx.Unlock()
+	p.markTransaction(hash)
+}
+
+// SendRequestedTxs responses the transactions requested by the peer, they're
+// sent regardless of the known transactions since they're announced by us
+func (p *Peer) SendRequestedTxs(txs []*types.Tx) bool {
+	if len(txs) == 0 {
+		return true
+	}
+
+	msg, err := msgs.NewTransactionsMessage(txs)
+	if err != nil {
+		return false
+	}
+	return p.TrySend(msgs.BlockchainChannel, struct{ msgs.BlockchainMessage }{msg})
+}
+
+// RequestTxs requests the transactions announced by the peer, the ones
+// requested from the other peers in flight are kept pending to retry from the
+// peer by RetryTxRequests
+func (ps *PeerSet) RequestTxs(peerID string, hashes []*bc.Hash) {
+	peer := ps.GetPeer(peerID)
+	if peer == nil {
+		return
+	}
+
+	now := time.Now()
+	requests, pendings := []*bc.Hash{}, []*bc.Hash{}
+	for _, hash := range hashes {
+		if ps.isTxRequested(hash, now) {
+			pendings = append(pendings, hash)
+			continue
+		}
+		requests = append(requests, hash)
+	}
+
+	peer.addPendingTxs(pendings, now)
+	if ok := peer.requestTxs(requests, now); !ok {
+		ps.RemovePeer(peerID)
+	}
+}
+
+// RetryTxRequests requests the pending transactions from the peers announced
+// them once the requests to the other peers timeout, the transactions already
+// had are dropped
+func (ps *PeerSet) RetryTxRequests(have func(*bc.Hash) bool) {
+	ps.retryTxRequests(have, time.Now())
+}
+
+func (ps *PeerSet) retryTxRequests(have func(*bc.Hash) bool, now time.Time) {
+	for _, peer := range ps.GetPeersByHeight(0) {
+		drops, requests := []*bc.Hash{}, []*bc.Hash{}
+		for _, hash := range peer.pendingTxHashes(now) {
+			if have(hash) {
+				drops = append(drops, hash)
+			} else if !ps.isTxRequested(hash, now) {
+				requests = append(requests, hash)
+			}
+		}
+
+		peer.removePendingTxs(append(drops, requests...))
+		if ok := peer.requestTxs(requests, now); !ok {
+			ps.RemovePeer(peer.ID())
+		}
+	}
+}
+
+// MarkTxReceived marks the transaction received from the peer, the
+// announcements of it pending on the other peers are cleared
+func (ps *PeerSet) MarkTxReceived(peerID string, txHash bc.Hash) {
+	for _, peer := range ps.GetPeersByHeight(0) {
+		if peer.ID() == peerID {
+			peer.markTxReceived(&txHash)
+		}
+		peer.removePendingTxs([]*bc.Hash{&txHash})
+	}
+}
+
+// TrickleTxInvs announces the queued transactions to the peers reaching the
+// randomized trickle time
+func (ps *PeerSet) TrickleTxInvs() {
+	now := time.Now()
+	for _, peer := range ps.GetPeersByHeight(0) {
+		if ok := peer.trickleTxInvs(now); !ok {
+			ps.RemovePeer(peer.ID())
+		}
+	}
+}
+
+func (ps *PeerSet) isTxRequested(hash *bc.Hash, now time.Time) bool {
+	ps.mtx.RLock()
+	defer ps.mtx.RUnlock()
+
+	for _, peer := range ps.peers {
+		if peer.isTxRequested(hash, now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package peers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"kuskcore/consensus"
+	msgs "kuskcore/netsync/messages"
+	"kuskcore/p2p"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+type recordPeer struct {
+	basePeer
+	msgs []msgs.BlockchainMessage
+}
+
+func (p *recordPeer) TrySend(chID byte, msg interface{}) bool {
+	p.msgs = append(p.msgs, msg.(struct{ msgs.BlockchainMessage }).BlockchainMessage)
+	return true
+}
+
+func mockRelayTxs(num int) []*types.Tx {
+	txs := []*types.Tx{}
+	for i := 0; i < num; i++ {
+		txs = append(txs, types.NewTx(types.TxData{
+			Version: 1,
+			Inputs:  []*types.TxInput{types.NewSpendInput(nil, bc.Hash{V0: uint64(i + 1)}, bc.AssetID{V0: 1}, 1000, 0, []byte{0x51}, nil)},
+			Outputs: []*types.TxOutput{types.NewOriginalTxOutput(bc.AssetID{V0: 1}, 900, []byte{0x51}, nil)},
+		}))
+	}
+	return txs
+}
+
+func TestTrickleTxInvs(t *testing.T) {
+	txs := mockRelayTxs(3)
+	fullPeer := &recordPeer{basePeer: basePeer{id: peer1ID, serviceFlag: consensus.SFFullNode}}
+	spvPeer := &recordPeer{basePeer: basePeer{id: peer2ID}}
+	ps := NewPeerSet(&basePeerSet{})
+	ps.AddPeer(fullPeer)
+	ps.AddPeer(spvPeer)
+
+	ps.MarkTx(peer1ID, txs[0].ID)
+	for _, tx := range txs {
+		if err := ps.BroadcastTx(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(fullPeer.msgs) != 0 || len(spvPeer.msgs) != 0 {
+		t.Fatalf("got %d, %d messages sent before the trickle, want none", len(fullPeer.msgs), len(spvPeer.msgs))
+	}
+
+	ps.TrickleTxInvs()
+	ps.TrickleTxInvs()
+	if len(fullPeer.msgs) != 1 {
+		t.Fatalf("got %d messages sent to the full node, want 1", len(fullPeer.msgs))
+	}
+
+	invMsg, ok := fullPeer.msgs[0].(*msgs.TxInventoryMessage)
+	if !ok {
+		t.Fatalf("got message %v, want tx inventory", fullPeer.msgs[0])
+	}
+
+	got := map[bc.Hash]bool{}
+	for _, txID := range invMsg.GetTxIDs() {
+		got[*txID] = true
+	}
+
+	if want := map[bc.Hash]bool{txs[1].ID: true, txs[2].ID: true}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got announced txs %v, want %v", got, want)
+	}
+
+	if peers := ps.peersWithoutTx(&txs[2].ID); len(peers) != 1 || peers[0].ID() != peer2ID {
+		t.Fatalf("the announced tx isn't marked known by the full node")
+	}
+
+	if ps.GetPeer(peer2ID).QueueTxInvs(txs) {
+		t.Fatalf("the spv node queued the tx announcements")
+	}
+
+	peer := ps.GetPeer(peer1ID)
+	if peer.IsTxAnnounced(&txs[0].ID) || !peer.IsTxAnnounced(&txs[1].ID) || !peer.IsTxAnnounced(&txs[2].ID) {
+		t.Fatalf("got unexpected announced txs")
+	}
+}
+
+type legacyPeer struct {
+	recordPeer
+}
+
+func (p *legacyPeer) ProtocolVersion() uint32 {
+	return p2p.TxInventoryVersion - 1
+}
+
+func TestBroadcastTxToLegacyPeer(t *testing.T) {
+	txs := mockRelayTxs(1)
+	peer := &legacyPeer{recordPeer{basePeer: basePeer{id: peer1ID, serviceFlag: consensus.SFFullNode}}}
+	ps := NewPeerSet(&basePeerSet{})
+	ps.AddPeer(peer)
+
+	if ps.GetPeer(peer1ID).QueueTxInvs(txs) {
+		t.Fatalf("the legacy peer queued the tx announcements")
+	}
+
+	if err := ps.BroadcastTx(txs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(peer.msgs) != 1 {
+		t.Fatalf("got %d messages sent to the legacy peer, want 1", len(peer.msgs))
+	}
+
+	if _, ok := peer.msgs[0].(*msgs.TransactionMessage); !ok {
+		t.Fatalf("got message %v, want transaction", peer.msgs[0])
+	}
+}
+
+func TestRequestTxs(t *testing.T) {
+	txs := mockRelayTxs(3)
+	peer1 := &recordPeer{basePeer: basePeer{id: peer1ID, serviceFlag: consensus.SFFullNode}}
+	peer2 := &recordPeer{basePeer: basePeer{id: peer2ID, serviceFlag: consensus.SFFullNode}}
+	ps := NewPeerSet(&basePeerSet{})
+	ps.AddPeer(peer1)
+	ps.AddPeer(peer2)
+
+	ps.RequestTxs(peer1ID, []*bc.Hash{&txs[0].ID, &txs[1].ID})
+	ps.RequestTxs(peer2ID, []*bc.Hash{&txs[0].ID, &txs[2].ID})
+	ps.MarkTxReceived(peer1ID, txs[1].ID)
+	ps.RequestTxs(peer2ID, []*bc.Hash{&txs[1].ID})
+
+	cases := []struct {
+		peer *recordPeer
+		want [][]bc.Hash
+	}{
+		{peer: peer1, want: [][]bc.Hash{{txs[0].ID, txs[1].ID}}},
+		{peer: peer2, want: [][]bc.Hash{{txs[2].ID}, {txs[1].ID}}},
+	}
+
+	for _, c := range cases {
+		got := [][]bc.Hash{}
+		for _, msg := range c.peer.msgs {
+			hashes := []bc.Hash{}
+			for _, txID := range msg.(*msgs.GetTransactionsMessage).GetTxIDs() {
+				hashes = append(hashes, *txID)
+			}
+			got = append(got, hashes)
+		}
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("peer %s: got requests %v, want %v", c.peer.ID(), got, c.want)
+		}
+	}
+
+	// the timeout requests are requested again
+	peer := ps.GetPeer(peer2ID)
+	if peer.isTxRequested(&txs[0].ID, time.Now()) || !ps.isTxRequested(&txs[0].ID, time.Now()) {
+		t.Fatalf("got unexpected in flight request")
+	}
+
+	if ps.isTxRequested(&txs[0].ID, time.Now().Add(txRequestTimeout)) {
+		t.Fatalf("the timeout request is still in flight")
+	}
+}
+
+func TestRetryTxRequests(t *testing.T) {
+	txs := mockRelayTxs(2)
+	peer1 := &recordPeer{basePeer: basePeer{id: peer1ID, serviceFlag: consensus.SFFullNode}}
+	peer2 := &recordPeer{basePeer: basePeer{id: peer2ID, serviceFlag: consensus.SFFullNode}}
+	ps := NewPeerSet(&basePeerSet{})
+	ps.AddPeer(peer1)
+	ps.AddPeer(peer2)
+
+	ps.RequestTxs(peer1ID, []*bc.Hash{&txs[0].ID, &txs[1].ID})
+	ps.RequestTxs(peer2ID, []*bc.Hash{&txs[0].ID, &txs[1].ID})
+	if len(peer2.msgs) != 0 {
+		t.Fatalf("got %d requests to the peer while in flight, want none", len(peer2.msgs))
+	}
+
+	have := func(hash *bc.Hash) bool { return *hash == txs[1].ID }
+	ps.retryTxRequests(have, time.Now())
+	if len(peer2.msgs) != 0 {
+		t.Fatalf("got %d requests to the peer before timeout, want none", len(peer2.msgs))
+	}
+
+	// only the missing tx is requested again from the other announcer
+	ps.retryTxRequests(have, time.Now().Add(txRequestTimeout))
+	if len(peer2.msgs) != 1 {
+		t.Fatalf("got %d requests to the peer after timeout, want 1", len(peer2.msgs))
+	}
+
+	got := peer2.msgs[0].(*msgs.GetTransactionsMessage).GetTxIDs()
+	if len(got) != 1 || *got[0] != txs[0].ID {
+		t.Fatalf("got retried requests %v, want %v", got, txs[0].ID)
+	}
+
+	if hashes := ps.GetPeer(peer2ID).pendingTxHashes(time.Now()); len(hashes) != 0 {
+		t.Fatalf("got %d pending txs after retry, want none", len(hashes))
+	}
+
+	// the received tx clears the pending announcements of the other peers
+	ps.RequestTxs(peer1ID, []*bc.Hash{&txs[1].ID})
+	ps.MarkTxReceived(peer2ID, txs[1].ID)
+	if hashes := ps.GetPeer(peer1ID).pendingTxHashes(time.Now()); len(hashes) != 0 {
+		t.Fatalf("got %d pending txs after received, want none", len(hashes))
+	}
+}
+
+func TestAllowTxInvs(t *testing.T) {
+	peer := newPeer(&basePeer{id: peer1ID, serviceFlag: consensus.SFFullNode})
+	now := time.Now()
+	cases := []struct {
+		num  int
+		time time.Time
+		want bool
+	}{
+		{num: maxTxInvsPerSecond / 2, time: now, want: true},
+		{num: maxTxInvsPerSecond / 2, time: now.Add(500 * time.Millisecond), want: true},
+		{num: 1, time: now.Add(900 * time.Millisecond), want: false},
+		{num: maxTxInvsPerSecond, time: now.Add(time.Second), want: true},
+	}
+
+	for i, c := range cases {
+		if got := peer.AllowTxInvs(c.num, c.time); got != c.want {
+			t.Errorf("case %d: got %v, want %v", i, got, c.want)
+		}
+	}
+}
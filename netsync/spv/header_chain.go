@@ -0,0 +1,745 @@
+package spv
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+
+	"kuskcore/config"
+	"kuskcore/consensus"
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/casper"
+	"kuskcore/protocol/state"
+	"kuskcore/protocol/validation"
+)
+
+var (
+	headerPrefix    = []byte("SPVH:")
+	mainChainPrefix = []byte("SPVM:")
+	blockPrefix     = []byte("SPVB:")
+	justifiedPrefix = []byte("SPVJ:")
+	validatorPrefix = []byte("SPVV:")
+	statusKey       = []byte("SPVStatus")
+
+	errNotFoundHeader     = errors.New("can't find the block header")
+	errNotFoundBlock      = errors.New("can't find the filtered block")
+	errOrphanHeader       = errors.New("the previous header of the block header is unknown")
+	errFinalizedConflict  = errors.New("conflict with the finalized checkpoint")
+	errCheckpointConflict = errors.New("the checkpoint doesn't extend the justified checkpoint")
+	errNotFoundValidators = errors.New("the validators of the checkpoint are unknown")
+	errInvalidValidators  = errors.New("the blocks don't match the epoch of the checkpoint")
+)
+
+func calcHeaderKey(hash *bc.Hash) []byte {
+	return append(headerPrefix, hash.Bytes()...)
+}
+
+func calcMainChainKey(height uint64) []byte {
+	buf := [8]byte{}
+	binary.BigEndian.PutUint64(buf[:], height)
+	return append(mainChainPrefix, buf[:]...)
+}
+
+func calcBlockKey(hash *bc.Hash) []byte {
+	return append(blockPrefix, hash.Bytes()...)
+}
+
+func calcJustifiedKey(hash *bc.Hash) []byte {
+	return append(justifiedPrefix, hash.Bytes()...)
+}
+
+func calcValidatorKey(hash *bc.Hash) []byte {
+	return append(validatorPrefix, hash.Bytes()...)
+}
+
+// headerChainStatus is the persisted status of the header chain
+type headerChainStatus struct {
+	BestHash      bc.Hash `json:"best_hash"`
+	JustifiedHash bc.Hash `json:"justified_hash"`
+	FinalizedHash bc.Hash `json:"finalized_hash"`
+	BlockHeight   uint64  `json:"block_height"`
+}
+
+// HeaderChain keeps the block headers synced by the light node and the blocks
+// filtered by the control programs of the wallet. The main chain is the
+// longest chain containing the justified checkpoint, the headers conflicting
+// with the finalized checkpoint are rejected.
+type HeaderChain struct {
+	db   dbm.DB
+	cond *sync.Cond
+
+	bestHeader      *types.BlockHeader
+	justifiedHeader *types.BlockHeader
+	finalizedHeader *types.BlockHeader
+	// the filtered blocks of the main chain are synced up to the block height
+	blockHeight uint64
+	// the checkpoints of which the votes are needed to verify the headers
+	validatorsToSync map[bc.Hash]bool
+}
+
+// NewHeaderChain loads the header chain from the db, the chain starts from
+// the genesis block if it's not stored
+func NewHeaderChain(db dbm.DB) (*HeaderChain, error) {
+	c := &HeaderChain{db: db, cond: sync.NewCond(&sync.Mutex{}), validatorsToSync: make(map[bc.Hash]bool)}
+	rawStatus := db.Get(statusKey)
+	if rawStatus == nil {
+		return c, c.initGenesis(config.GenesisBlock())
+	}
+
+	status := &headerChainStatus{}
+	if err := json.Unmarshal(rawStatus, status); err != nil {
+		return nil, errors.Wrap(err, "unmarshal header chain status")
+	}
+
+	var err error
+	if c.bestHeader, err = c.getHeader(&status.BestHash); err != nil {
+		return nil, err
+	}
+
+	if c.justifiedHeader, err = c.getHeader(&status.JustifiedHash); err != nil {
+		return nil, err
+	}
+
+	if c.finalizedHeader, err = c.getHeader(&status.FinalizedHash); err != nil {
+		return nil, err
+	}
+
+	c.blockHeight = status.BlockHeight
+	return c, nil
+}
+
+func (c *HeaderChain) initGenesis(genesis *types.Block) error {
+	batch := c.db.NewBatch()
+	if err := c.saveHeader(batch, &genesis.BlockHeader); err != nil {
+		return err
+	}
+
+	if err := c.saveBlock(batch, genesis); err != nil {
+		return err
+	}
+
+	// the genesis checkpoint has no votes, the validators are the federation
+	hash := genesis.Hash()
+	if err := c.saveValidators(batch, &state.Checkpoint{
+		Height:    genesis.Height,
+		Hash:      hash,
+		Timestamp: genesis.Timestamp,
+		Status:    state.Justified,
+	}); err != nil {
+		return err
+	}
+
+	batch.Set(calcMainChainKey(0), hash.Bytes())
+	batch.Set(calcJustifiedKey(&hash), []byte{1})
+	c.bestHeader = &genesis.BlockHeader
+	c.justifiedHeader = &genesis.BlockHeader
+	c.finalizedHeader = &genesis.BlockHeader
+	return c.commit(batch)
+}
+
+// BestBlockHeader returns the tip of the main chain
+func (c *HeaderChain) BestBlockHeader() *types.BlockHeader {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	return c.bestHeader
+}
+
+// BestBlockHeight returns the height of the tip of the main chain
+func (c *HeaderChain) BestBlockHeight() uint64 {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	return c.bestHeader.Height
+}
+
+// BlockHeight returns the height which the filtered blocks are synced to
+func (c *HeaderChain) BlockHeight() uint64 {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	return c.blockHeight
+}
+
+// JustifiedHeader returns the latest justified checkpoint
+func (c *HeaderChain) JustifiedHeader() *types.BlockHeader {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	return c.justifiedHeader
+}
+
+// FinalizedHeader returns the latest finalized checkpoint
+func (c *HeaderChain) FinalizedHeader() *types.BlockHeader {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	return c.finalizedHeader
+}
+
+// BlockExist checks the filtered block of the hash is synced
+func (c *HeaderChain) BlockExist(hash *bc.Hash) bool {
+	return c.db.Get(calcBlockKey(hash)) != nil
+}
+
+// BlockWaiter returns a channel that waits for the filtered block at the given height.
+func (c *HeaderChain) BlockWaiter(height uint64) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	go func() {
+		c.cond.L.Lock()
+		defer c.cond.L.Unlock()
+		for c.blockHeight < height {
+			c.cond.Wait()
+		}
+		ch <- struct{}{}
+	}()
+
+	return ch
+}
+
+// GetBlockByHash returns the filtered block, the transactions of the block
+// are the ones related to the wallet only
+func (c *HeaderChain) GetBlockByHash(hash *bc.Hash) (*types.Block, error) {
+	rawBlock := c.db.Get(calcBlockKey(hash))
+	if rawBlock == nil {
+		return nil, errors.WithDetailf(errNotFoundBlock, "hash %s", hash.String())
+	}
+
+	block := &types.Block{}
+	if err := block.UnmarshalText(rawBlock); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetBlockByHeight returns the filtered block of the main chain
+func (c *HeaderChain) GetBlockByHeight(height uint64) (*types.Block, error) {
+	c.cond.L.Lock()
+	hash := c.mainChainHash(height)
+	c.cond.L.Unlock()
+	if hash == nil {
+		return nil, errors.WithDetailf(errNotFoundBlock, "height %d", height)
+	}
+
+	return c.GetBlockByHash(hash)
+}
+
+// GetHeaderByHash returns the block header of the hash
+func (c *HeaderChain) GetHeaderByHash(hash *bc.Hash) (*types.BlockHeader, error) {
+	return c.getHeader(hash)
+}
+
+// GetHeaderByHeight returns the block header of the main chain
+func (c *HeaderChain) GetHeaderByHeight(height uint64) (*types.BlockHeader, error) {
+	c.cond.L.Lock()
+	hash := c.mainChainHash(height)
+	c.cond.L.Unlock()
+	if hash == nil {
+		return nil, errors.WithDetailf(errNotFoundHeader, "height %d", height)
+	}
+
+	return c.getHeader(hash)
+}
+
+// InMainChain checks the block of the hash is in the main chain
+func (c *HeaderChain) InMainChain(hash bc.Hash) bool {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	return c.inMainChain(hash)
+}
+
+// BlockLocator returns the hashes of the main chain from the tip to the
+// genesis, the step between the hashes doubles after the first ten ones
+func (c *HeaderChain) BlockLocator() []*bc.Hash {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	locator := []*bc.Hash{}
+	step := uint64(1)
+	for height := c.bestHeader.Height; ; height -= step {
+		if hash := c.mainChainHash(height); hash != nil {
+			locator = append(locator, hash)
+		}
+
+		if len(locator) >= 10 {
+			step *= 2
+		}
+
+		if height < step {
+			break
+		}
+	}
+
+	if genesisHash := c.mainChainHash(0); len(locator) == 0 || *locator[len(locator)-1] != *genesisHash {
+		locator = append(locator, genesisHash)
+	}
+	return locator
+}
+
+// BlocksToSync returns the headers of the main chain which the filtered
+// blocks are not synced, at most max headers are returned
+func (c *HeaderChain) BlocksToSync(max int) []*types.BlockHeader {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	headers := []*types.BlockHeader{}
+	for height := c.blockHeight + 1; height <= c.bestHeader.Height && len(headers) < max; height++ {
+		hash := c.mainChainHash(height)
+		if hash == nil || c.BlockExist(hash) {
+			continue
+		}
+
+		header, err := c.getHeader(hash)
+		if err != nil {
+			break
+		}
+
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+// ProcessHeaders saves the headers linked to the known ones, the main chain
+// switches to the longer chain containing the justified checkpoint.
+//
+// The headers must be signed by the validators of their time slots, and the
+// checkpoints are justified by the sup links signed by the majority of the
+// validators. The validators of an epoch are changed by the votes of the
+// previous one, the headers are cut at the epoch which the votes are not
+// synced, the checkpoint is returned by ValidatorsToSync. The sup links are
+// added to the checkpoint headers after they're produced, so the sup links of
+// the known headers are processed again.
+func (c *HeaderChain) ProcessHeaders(headers []*types.BlockHeader) error {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	c.validatorsToSync = make(map[bc.Hash]bool)
+
+	// the checkpoints of the validators signing the children of the headers
+	checkpoints := make(map[bc.Hash]*state.Checkpoint)
+	for _, header := range headers {
+		hash := header.Hash()
+		known := c.db.Get(calcHeaderKey(&hash)) != nil
+		if known && (header.Height%consensus.ActiveNetParams.BlocksOfEpoch != 0 || len(header.SupLinks) == 0) {
+			if err := c.updateBestHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !known {
+			if header.Height <= c.finalizedHeader.Height {
+				return errors.WithDetailf(errFinalizedConflict, "header %d of hash %s, finalized height %d", header.Height, hash.String(), c.finalizedHeader.Height)
+			}
+
+			parent, err := c.getHeader(&header.PreviousBlockHash)
+			if err != nil {
+				return errors.WithDetailf(errOrphanHeader, "header %d of hash %s", header.Height, hash.String())
+			}
+
+			if err := validation.ValidateHeaderLink(header, parent); err != nil {
+				return err
+			}
+		}
+
+		checkpoint, ok := checkpoints[header.PreviousBlockHash]
+		if !ok {
+			var err error
+			checkpoint, err = c.prevCheckpoint(header)
+			if errors.Root(err) == errNotFoundValidators {
+				// the parent is verified, so the header starts the epoch
+				// after the checkpoint of the unknown validators
+				c.validatorsToSync[header.PreviousBlockHash] = true
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if !known {
+			if err := validation.ValidateBlockSignature(header, checkpoint); err != nil {
+				return err
+			}
+
+			batch := c.db.NewBatch()
+			if err := c.saveHeader(batch, header); err != nil {
+				return err
+			}
+			batch.Write()
+		}
+
+		checkpoints[hash] = checkpoint
+		if header.Height%consensus.ActiveNetParams.BlocksOfEpoch == 0 {
+			// the validators signing the children are loaded by the votes
+			delete(checkpoints, hash)
+			if err := c.processSupLinks(header, checkpoint); err != nil {
+				return err
+			}
+		}
+
+		if err := c.updateBestHeader(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidatorsToSync returns the checkpoints of which the votes are needed by the
+// last processed headers
+func (c *HeaderChain) ValidatorsToSync() []*bc.Hash {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	hashes := []*bc.Hash{}
+	for hash := range c.validatorsToSync {
+		hash := hash
+		hashes = append(hashes, &hash)
+	}
+	return hashes
+}
+
+// ProcessValidators saves the validators of the checkpoint changed by the votes
+// of the epoch ending at it. The blocks must be all the blocks of the epoch in
+// height order, and the transactions of them are the vote ones proved by the
+// merkle roots. The blocks are linked to the headers signed by the validators
+// of the previous checkpoint, whose sup links justifying the checkpoint are
+// verified by the same validators, so the votes are from the verified chain.
+func (c *HeaderChain) ProcessValidators(hash *bc.Hash, blocks []*types.Block) error {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if c.db.Get(calcValidatorKey(hash)) != nil {
+		return nil
+	}
+
+	header, err := c.getHeader(hash)
+	if err != nil {
+		return err
+	}
+
+	if header.Height%consensus.ActiveNetParams.BlocksOfEpoch != 0 || uint64(len(blocks)) != consensus.ActiveNetParams.BlocksOfEpoch {
+		return errors.WithDetailf(errInvalidValidators, "checkpoint %d of hash %s, %d blocks", header.Height, hash.String(), len(blocks))
+	}
+
+	parent, err := c.prevCheckpoint(header)
+	if err != nil {
+		return err
+	}
+
+	prevHash := parent.Hash
+	for _, block := range blocks {
+		if block.PreviousBlockHash != prevHash {
+			return errors.WithDetailf(errInvalidValidators, "block %d isn't linked to the epoch", block.Height)
+		}
+		prevHash = block.Hash()
+	}
+
+	if prevHash != *hash {
+		return errors.WithDetailf(errInvalidValidators, "the blocks don't end at checkpoint %s", hash.String())
+	}
+
+	checkpoint := state.NewCheckpoint(parent)
+	checkpoint.Height = header.Height
+	checkpoint.Hash = *hash
+	checkpoint.Timestamp = header.Timestamp
+	checkpoint.Status = state.Justified
+	checkpoint.ApplyVotes(blocks)
+
+	batch := c.db.NewBatch()
+	if err := c.saveValidators(batch, checkpoint); err != nil {
+		return err
+	}
+
+	batch.Write()
+	delete(c.validatorsToSync, *hash)
+	return nil
+}
+
+// CheckCheckpoint checks the justified checkpoint reported by the peer against
+// the synced headers. The checkpoint reported by the peer is never trusted,
+// the checkpoints are justified by the sup links of the headers only. It
+// returns errNotFoundHeader when the header of the checkpoint is not synced
+// yet, and an error when the checkpoint conflicts with the finalized or the
+// justified checkpoint.
+func (c *HeaderChain) CheckCheckpoint(height uint64, hash *bc.Hash) error {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	if height <= c.finalizedHeader.Height {
+		if mainHash := c.mainChainHash(height); mainHash == nil || *mainHash != *hash {
+			return errors.WithDetailf(errFinalizedConflict, "checkpoint %d of hash %s", height, hash.String())
+		}
+		return nil
+	}
+
+	if height <= c.justifiedHeader.Height {
+		return nil
+	}
+
+	header, err := c.getHeader(hash)
+	if err != nil {
+		return err
+	}
+
+	if header.Height != height || !c.isAncestor(c.finalizedHeader, header) {
+		return errors.WithDetailf(errFinalizedConflict, "checkpoint %d of hash %s", height, hash.String())
+	}
+
+	if !c.isAncestor(c.justifiedHeader, header) {
+		return errors.WithDetailf(errCheckpointConflict, "checkpoint %d of hash %s", height, hash.String())
+	}
+	return nil
+}
+
+// SaveBlock saves the block filtered by the control programs of the wallet,
+// the transactions must be verified by the merkle root of the header
+func (c *HeaderChain) SaveBlock(block *types.Block) error {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	hash := block.Hash()
+	if c.db.Get(calcHeaderKey(&hash)) == nil {
+		return errors.WithDetailf(errNotFoundHeader, "hash %s", hash.String())
+	}
+
+	batch := c.db.NewBatch()
+	if err := c.saveBlock(batch, block); err != nil {
+		return err
+	}
+	batch.Write()
+
+	for {
+		hash := c.mainChainHash(c.blockHeight + 1)
+		if hash == nil || !c.BlockExist(hash) {
+			break
+		}
+		c.blockHeight++
+	}
+	return c.commit(c.db.NewBatch())
+}
+
+// setBestHeader switches the main chain to the chain of the header, the
+// filtered blocks are resynced from the fork point
+func (c *HeaderChain) setBestHeader(header *types.BlockHeader) error {
+	batch := c.db.NewBatch()
+	for height := header.Height + 1; height <= c.bestHeader.Height; height++ {
+		batch.Delete(calcMainChainKey(height))
+	}
+
+	fork := header
+	for hash := fork.Hash(); !c.inMainChain(hash); hash = fork.Hash() {
+		batch.Set(calcMainChainKey(fork.Height), hash.Bytes())
+		parent, err := c.getHeader(&fork.PreviousBlockHash)
+		if err != nil {
+			return err
+		}
+		fork = parent
+	}
+
+	if fork.Height < c.blockHeight {
+		c.blockHeight = fork.Height
+	}
+	c.bestHeader = header
+	return c.commit(batch)
+}
+
+// updateBestHeader switches the main chain to the justified checkpoint if it's
+// not in the main chain, and to the header if it's longer and extends the
+// justified checkpoint
+func (c *HeaderChain) updateBestHeader(header *types.BlockHeader) error {
+	if !c.isAncestor(c.justifiedHeader, c.bestHeader) {
+		if err := c.setBestHeader(c.justifiedHeader); err != nil {
+			return err
+		}
+	}
+
+	if header.Height > c.bestHeader.Height && c.isAncestor(c.justifiedHeader, header) {
+		return c.setBestHeader(header)
+	}
+	return nil
+}
+
+// processSupLinks justifies the checkpoint of the header by the sup link from
+// a justified checkpoint signed by more than 2/3 of the validators, the source
+// checkpoint is finalized if it's the checkpoint of the previous epoch
+func (c *HeaderChain) processSupLinks(header *types.BlockHeader, checkpoint *state.Checkpoint) error {
+	hash := header.Hash()
+	validators := checkpoint.EffectiveValidators()
+	batch := c.db.NewBatch()
+	for _, supLink := range header.SupLinks {
+		if err := casper.VerifySupLink(checkpoint, header.Height, hash, supLink); err != nil {
+			return errors.Wrap(err, "verify sup link")
+		}
+
+		// the signatures out of the validators are not verified, so they're
+		// not counted
+		numOfSignatures := 0
+		for _, validator := range validators {
+			if len(supLink.Signatures[validator.Order]) != 0 {
+				numOfSignatures++
+			}
+		}
+
+		if numOfSignatures <= len(validators)*2/3 || c.db.Get(calcJustifiedKey(&supLink.SourceHash)) == nil {
+			continue
+		}
+
+		source, err := c.getHeader(&supLink.SourceHash)
+		if err != nil || source.Height != supLink.SourceHeight || !c.isAncestor(source, header) {
+			continue
+		}
+
+		batch.Set(calcJustifiedKey(&hash), []byte{1})
+		if header.Height > c.justifiedHeader.Height && c.isAncestor(c.finalizedHeader, header) {
+			c.justifiedHeader = header
+		}
+
+		if header.Height == source.Height+consensus.ActiveNetParams.BlocksOfEpoch && source.Height > c.finalizedHeader.Height && c.isAncestor(source, c.justifiedHeader) {
+			c.finalizedHeader = source
+		}
+	}
+	return c.commit(batch)
+}
+
+// prevCheckpoint returns the checkpoint of the validators signing the header,
+// it's the last checkpoint before the header
+func (c *HeaderChain) prevCheckpoint(header *types.BlockHeader) (*state.Checkpoint, error) {
+	for {
+		parent, err := c.getHeader(&header.PreviousBlockHash)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent.Height%consensus.ActiveNetParams.BlocksOfEpoch == 0 {
+			return c.getValidators(&header.PreviousBlockHash)
+		}
+		header = parent
+	}
+}
+
+// isAncestor checks the ancestor is the header itself or the ancestor of it
+func (c *HeaderChain) isAncestor(ancestor, header *types.BlockHeader) bool {
+	for header.Height > ancestor.Height && !c.inMainChain(header.Hash()) {
+		parent, err := c.getHeader(&header.PreviousBlockHash)
+		if err != nil {
+			return false
+		}
+		header = parent
+	}
+
+	if header.Height < ancestor.Height {
+		return false
+	}
+
+	if c.inMainChain(header.Hash()) {
+		return c.inMainChain(ancestor.Hash())
+	}
+	return header.Hash() == ancestor.Hash()
+}
+
+func (c *HeaderChain) inMainChain(hash bc.Hash) bool {
+	header, err := c.getHeader(&hash)
+	if err != nil {
+		return false
+	}
+
+	mainHash := c.mainChainHash(header.Height)
+	return mainHash != nil && *mainHash == hash
+}
+
+func (c *HeaderChain) mainChainHash(height uint64) *bc.Hash {
+	rawHash := c.db.Get(calcMainChainKey(height))
+	if rawHash == nil {
+		return nil
+	}
+
+	var b32 [32]byte
+	copy(b32[:], rawHash)
+	hash := bc.NewHash(b32)
+	return &hash
+}
+
+func (c *HeaderChain) getHeader(hash *bc.Hash) (*types.BlockHeader, error) {
+	rawHeader := c.db.Get(calcHeaderKey(hash))
+	if rawHeader == nil {
+		return nil, errors.WithDetailf(errNotFoundHeader, "hash %s", hash.String())
+	}
+
+	header := &types.BlockHeader{}
+	if err := header.UnmarshalText(rawHeader); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// getValidators returns the checkpoint of which the votes are synced, the
+// validators of the next epoch are elected by the votes
+func (c *HeaderChain) getValidators(hash *bc.Hash) (*state.Checkpoint, error) {
+	rawCheckpoint := c.db.Get(calcValidatorKey(hash))
+	if rawCheckpoint == nil {
+		return nil, errors.WithDetailf(errNotFoundValidators, "hash %s", hash.String())
+	}
+
+	checkpoint := &state.Checkpoint{}
+	if err := json.Unmarshal(rawCheckpoint, checkpoint); err != nil {
+		return nil, errors.Wrap(err, "unmarshal validators")
+	}
+	return checkpoint, nil
+}
+
+func (c *HeaderChain) saveValidators(batch dbm.Batch, checkpoint *state.Checkpoint) error {
+	rawCheckpoint, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	batch.Set(calcValidatorKey(&checkpoint.Hash), rawCheckpoint)
+	return nil
+}
+
+func (c *HeaderChain) saveHeader(batch dbm.Batch, header *types.BlockHeader) error {
+	rawHeader, err := header.MarshalText()
+	if err != nil {
+		return err
+	}
+
+	hash := header.Hash()
+	batch.Set(calcHeaderKey(&hash), rawHeader)
+	return nil
+}
+
+func (c *HeaderChain) saveBlock(batch dbm.Batch, block *types.Block) error {
+	rawBlock, err := block.MarshalText()
+	if err != nil {
+		return err
+	}
+
+	hash := block.Hash()
+	batch.Set(calcBlockKey(&hash), rawBlock)
+	return nil
+}
+
+// commit writes the batch with the status of the chain, the waiters are
+// notified since the main chain or the synced blocks may be changed
+func (c *HeaderChain) commit(batch dbm.Batch) error {
+	rawStatus, err := json.Marshal(&headerChainStatus{
+		BestHash:      c.bestHeader.Hash(),
+		JustifiedHash: c.justifiedHeader.Hash(),
+		FinalizedHash: c.finalizedHeader.Hash(),
+		BlockHeight:   c.blockHeight,
+	})
+	if err != nil {
+		return err
+	}
+
+	batch.Set(statusKey, rawStatus)
+	batch.Write()
+	c.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,513 @@
+package spv
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+
+	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+// mockFederation replaces the federation of the validators with num new keys
+func mockFederation(t *testing.T, num int) []chainkd.XPrv {
+	xpubs := consensus.ActiveNetParams.FederationXpubs
+	t.Cleanup(func() { consensus.ActiveNetParams.FederationXpubs = xpubs })
+
+	xprvs := make([]chainkd.XPrv, num)
+	consensus.ActiveNetParams.FederationXpubs = make([]chainkd.XPub, num)
+	for i := range xprvs {
+		xprv, err := chainkd.NewXPrv(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		xprvs[i] = xprv
+		consensus.ActiveNetParams.FederationXpubs[i] = xprv.XPub()
+	}
+	return xprvs
+}
+
+// mockHeaders builds the headers on the parent signed by the single validator,
+// the fork is added to the timestamps to make different headers of the same
+// height
+func mockHeaders(xprv chainkd.XPrv, parent *types.BlockHeader, num int, fork uint64) []*types.BlockHeader {
+	headers := []*types.BlockHeader{}
+	for i := 0; i < num; i++ {
+		header := &types.BlockHeader{
+			Version:           1,
+			Height:            parent.Height + 1,
+			PreviousBlockHash: parent.Hash(),
+			Timestamp:         parent.Timestamp + consensus.ActiveNetParams.BlockTimeInterval + fork,
+		}
+		hash := header.Hash()
+		header.BlockWitness.Set(xprv.Sign(hash.Bytes()))
+		headers = append(headers, header)
+		parent = header
+	}
+	return headers
+}
+
+// mockSupLink copies the checkpoint header with the sup link from the source
+// signed by the validators
+func mockSupLink(header, source *types.BlockHeader, xprvs ...chainkd.XPrv) *types.BlockHeader {
+	sourceHash, targetHash := source.Hash(), header.Hash()
+	msg := sha3.Sum256(append(sourceHash.Bytes(), targetHash.Bytes()...))
+	supLink := &types.SupLink{SourceHeight: source.Height, SourceHash: sourceHash}
+	for i, xprv := range xprvs {
+		supLink.Signatures[i] = xprv.Sign(msg[:])
+	}
+
+	linked := *header
+	linked.SupLinks = append(types.SupLinks{supLink}, header.SupLinks...)
+	return &linked
+}
+
+// mockBlock builds the block of the transactions on the parent signed by the
+// single validator
+func mockBlock(t *testing.T, xprv chainkd.XPrv, parent *types.BlockHeader, txs ...*types.Tx) *types.Block {
+	block := &types.Block{
+		BlockHeader: types.BlockHeader{
+			Version:           1,
+			Height:            parent.Height + 1,
+			PreviousBlockHash: parent.Hash(),
+			Timestamp:         parent.Timestamp + consensus.ActiveNetParams.BlockTimeInterval,
+		},
+		Transactions: txs,
+	}
+
+	bcTxs := []*bc.Tx{}
+	for _, tx := range txs {
+		bcTxs = append(bcTxs, tx.Tx)
+	}
+
+	merkleRoot, err := types.TxMerkleRoot(bcTxs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block.TransactionsMerkleRoot = merkleRoot
+	hash := block.Hash()
+	block.BlockWitness.Set(xprv.Sign(hash.Bytes()))
+	return block
+}
+
+// processHeaders processes the headers and the votes of the epochs crossed by
+// them, the blocks of the epochs have no votes
+func processHeaders(chain *HeaderChain, headers []*types.BlockHeader) error {
+	for {
+		if err := chain.ProcessHeaders(headers); err != nil {
+			return err
+		}
+
+		hashes := chain.ValidatorsToSync()
+		if len(hashes) == 0 {
+			return nil
+		}
+
+		for _, hash := range hashes {
+			blocks := make([]*types.Block, consensus.ActiveNetParams.BlocksOfEpoch)
+			for i, prevHash := len(blocks)-1, hash; i >= 0; i-- {
+				header, err := chain.GetHeaderByHash(prevHash)
+				if err != nil {
+					return err
+				}
+
+				blocks[i] = &types.Block{BlockHeader: *header}
+				prevHash = &header.PreviousBlockHash
+			}
+
+			if err := chain.ProcessValidators(hash, blocks); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func hashOf(header *types.BlockHeader) *bc.Hash {
+	hash := header.Hash()
+	return &hash
+}
+
+func TestHeaderChainFork(t *testing.T) {
+	xprv := mockFederation(t, 1)[0]
+	chain, err := NewHeaderChain(dbm.NewMemDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := chain.BestBlockHeader()
+	mainHeaders := mockHeaders(xprv, genesis, 10, 0)
+	if err := chain.ProcessHeaders(mainHeaders); err != nil {
+		t.Fatal(err)
+	}
+
+	if best := chain.BestBlockHeader(); best.Hash() != mainHeaders[9].Hash() {
+		t.Fatalf("got best height %d, want the tip of the main headers", best.Height)
+	}
+
+	// the longer fork becomes the main chain
+	forkHeaders := mockHeaders(xprv, mainHeaders[2], 9, 1)
+	if err := processHeaders(chain, forkHeaders); err != nil {
+		t.Fatal(err)
+	}
+
+	if best := chain.BestBlockHeader(); best.Hash() != forkHeaders[8].Hash() {
+		t.Fatalf("got best height %d, want the tip of the fork", best.Height)
+	}
+
+	if chain.InMainChain(mainHeaders[3].Hash()) || !chain.InMainChain(mainHeaders[2].Hash()) {
+		t.Fatal("the main chain is not switched at the fork point")
+	}
+
+	if header, err := chain.GetHeaderByHeight(4); err != nil || header.Hash() != forkHeaders[0].Hash() {
+		t.Fatalf("got header %v, error %v at the fork height", header, err)
+	}
+
+	// the checkpoint justified by the sup link switches the main chain back
+	if err := chain.ProcessHeaders([]*types.BlockHeader{mockSupLink(mainHeaders[9], genesis, xprv)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if best := chain.BestBlockHeader(); best.Hash() != mainHeaders[9].Hash() || chain.JustifiedHeader().Hash() != mainHeaders[9].Hash() {
+		t.Fatalf("got best height %d, want the justified checkpoint", best.Height)
+	}
+
+	// the longer fork without the justified checkpoint is not the main chain
+	if err := chain.ProcessHeaders(mockHeaders(xprv, forkHeaders[8], 5, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if best := chain.BestBlockHeader(); best.Hash() != mainHeaders[9].Hash() {
+		t.Fatalf("got best height %d, want the tip of the justified chain", best.Height)
+	}
+
+	locator := chain.BlockLocator()
+	if len(locator) != 11 || *locator[0] != mainHeaders[9].Hash() || *locator[10] != genesis.Hash() {
+		t.Fatalf("got block locator of %d hashes", len(locator))
+	}
+
+	orphanHeaders := mockHeaders(xprv, mockHeaders(xprv, mainHeaders[9], 1, 2)[0], 1, 0)
+	if err := chain.ProcessHeaders(orphanHeaders); errors.Root(err) != errOrphanHeader {
+		t.Fatalf("got error %v, want %v", err, errOrphanHeader)
+	}
+
+	misordered := &types.BlockHeader{
+		Version:           1,
+		Height:            12,
+		PreviousBlockHash: mainHeaders[9].Hash(),
+		Timestamp:         mainHeaders[9].Timestamp + consensus.ActiveNetParams.BlockTimeInterval,
+	}
+	if err := chain.ProcessHeaders([]*types.BlockHeader{misordered}); err == nil {
+		t.Fatal("the misordered header is accepted")
+	}
+}
+
+func TestHeaderChainVerify(t *testing.T) {
+	xprvs := mockFederation(t, 3)
+	other, err := chainkd.NewXPrv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := NewHeaderChain(dbm.NewMemDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the validators sign the blocks in turn from the start of the epochs
+	genesis := chain.BestBlockHeader()
+	epoch := int(consensus.ActiveNetParams.BlocksOfEpoch)
+	headers := []*types.BlockHeader{}
+	for parent := genesis; len(headers) < 2*epoch; parent = headers[len(headers)-1] {
+		order := len(headers) % epoch % len(xprvs)
+		headers = append(headers, mockHeaders(xprvs[order], parent, 1, 0)...)
+	}
+
+	if err := chain.ProcessHeaders(mockHeaders(other, genesis, 1, 0)); err == nil {
+		t.Fatal("the header signed by the non validator is accepted")
+	}
+
+	if err := chain.ProcessHeaders(mockHeaders(xprvs[1], genesis, 1, 0)); err == nil {
+		t.Fatal("the header signed out of the time slot is accepted")
+	}
+
+	if err := processHeaders(chain, headers); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint := headers[epoch-1]
+	for i, c := range []struct {
+		header        *types.BlockHeader
+		err           bool
+		justifiedHash bc.Hash
+	}{
+		// the signatures of 2 validators are not the majority
+		{header: mockSupLink(checkpoint, genesis, xprvs[:2]...), justifiedHash: genesis.Hash()},
+		// the signature of the non validator
+		{header: mockSupLink(checkpoint, genesis, xprvs[0], other, xprvs[2]), err: true, justifiedHash: genesis.Hash()},
+		// the source isn't justified
+		{header: mockSupLink(headers[2*epoch-1], checkpoint, xprvs...), justifiedHash: genesis.Hash()},
+		{header: mockSupLink(checkpoint, genesis, xprvs...), justifiedHash: checkpoint.Hash()},
+	} {
+		if err := chain.ProcessHeaders([]*types.BlockHeader{c.header}); (err != nil) != c.err {
+			t.Errorf("case %d: got error %v", i, err)
+		}
+
+		if got := chain.JustifiedHeader().Hash(); got != c.justifiedHash {
+			t.Errorf("case %d: got justified hash %s, want %s", i, got.String(), c.justifiedHash.String())
+		}
+	}
+
+	// the checkpoint reported by the peer is only checked
+	if err := chain.CheckCheckpoint(uint64(2*epoch), hashOf(headers[2*epoch-1])); err != nil {
+		t.Fatal(err)
+	}
+
+	if chain.JustifiedHeader().Hash() != checkpoint.Hash() {
+		t.Fatal("the checkpoint reported by the peer is justified")
+	}
+}
+
+func TestHeaderChainValidators(t *testing.T) {
+	federation := mockFederation(t, 1)[0]
+	validator, err := chainkd.NewXPrv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := NewHeaderChain(dbm.NewMemDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the vote in the first epoch elects the validator replacing the federation
+	xpub := validator.XPub()
+	voteNum := consensus.ActiveNetParams.MinValidatorVoteNum
+	voteTx := types.NewTx(types.TxData{
+		Version: 1,
+		Inputs:  []*types.TxInput{types.NewSpendInput(nil, bc.Hash{V0: 1}, *consensus.KUSKAssetID, voteNum, 0, []byte{0x51}, nil)},
+		Outputs: []*types.TxOutput{types.NewVoteOutput(*consensus.KUSKAssetID, voteNum, []byte{0x51}, xpub[:], nil)},
+	})
+	otherTx := types.NewTx(types.TxData{
+		Version: 1,
+		Inputs:  []*types.TxInput{types.NewSpendInput(nil, bc.Hash{V0: 2}, *consensus.KUSKAssetID, voteNum, 0, []byte{0x51}, nil)},
+		Outputs: []*types.TxOutput{types.NewOriginalTxOutput(*consensus.KUSKAssetID, voteNum, []byte{0x51}, nil)},
+	})
+
+	genesis := chain.BestBlockHeader()
+	epoch := int(consensus.ActiveNetParams.BlocksOfEpoch)
+	blocks := []*types.Block{mockBlock(t, federation, genesis, otherTx, voteTx)}
+	for len(blocks) < epoch {
+		blocks = append(blocks, mockBlock(t, federation, &blocks[len(blocks)-1].BlockHeader))
+	}
+
+	checkpoint := &blocks[epoch-1].BlockHeader
+	headers := []*types.BlockHeader{}
+	for _, block := range blocks {
+		headers = append(headers, &block.BlockHeader)
+	}
+
+	// the headers are cut at the epoch of the unknown validators
+	if err := chain.ProcessHeaders(append(headers, mockHeaders(validator, checkpoint, epoch, 0)...)); err != nil {
+		t.Fatal(err)
+	}
+
+	if hashes := chain.ValidatorsToSync(); chain.BestBlockHeight() != uint64(epoch) || len(hashes) != 1 || *hashes[0] != checkpoint.Hash() {
+		t.Fatalf("got best height %d, %d checkpoints of the validators to sync", chain.BestBlockHeight(), len(hashes))
+	}
+
+	// the vote transactions are proved by the merkle blocks
+	voteBlocks := []*types.Block{}
+	for _, block := range blocks {
+		var voteTxs []*types.Tx
+		if len(block.Transactions) != 0 {
+			voteTxs = []*types.Tx{voteTx}
+		}
+
+		voteBlock, err := verifyMerkleBlock(mockMerkleBlock(t, block, voteTxs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		voteBlocks = append(voteBlocks, voteBlock)
+	}
+
+	forkBlocks := append([]*types.Block{mockBlock(t, federation, genesis)}, voteBlocks[1:]...)
+	for i, c := range []struct {
+		hash   *bc.Hash
+		blocks []*types.Block
+		err    error
+	}{
+		{hash: hashOf(checkpoint), blocks: voteBlocks[1:], err: errInvalidValidators},
+		{hash: hashOf(checkpoint), blocks: forkBlocks, err: errInvalidValidators},
+		{hash: hashOf(headers[epoch-2]), blocks: voteBlocks, err: errInvalidValidators},
+		{hash: hashOf(checkpoint), blocks: voteBlocks},
+	} {
+		if err := chain.ProcessValidators(c.hash, c.blocks); errors.Root(err) != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+		}
+	}
+
+	// the federation isn't the validator after the epoch
+	if err := chain.ProcessHeaders(mockHeaders(federation, checkpoint, 1, 0)); err == nil {
+		t.Fatal("the header signed by the federation is accepted")
+	}
+
+	nextHeaders := mockHeaders(validator, checkpoint, epoch, 0)
+	if err := chain.ProcessHeaders(nextHeaders); err != nil {
+		t.Fatal(err)
+	}
+
+	if chain.BestBlockHeight() != uint64(2*epoch) || len(chain.ValidatorsToSync()) != 0 {
+		t.Fatalf("got best height %d, want the headers signed by the elected validator", chain.BestBlockHeight())
+	}
+
+	// the checkpoints are justified by the validators of the epochs
+	if err := chain.ProcessHeaders([]*types.BlockHeader{mockSupLink(checkpoint, genesis, federation)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := chain.ProcessHeaders([]*types.BlockHeader{mockSupLink(nextHeaders[epoch-1], checkpoint, federation)}); err == nil {
+		t.Fatal("the sup link signed by the federation is accepted")
+	}
+
+	if err := chain.ProcessHeaders([]*types.BlockHeader{mockSupLink(nextHeaders[epoch-1], checkpoint, validator)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if chain.FinalizedHeader().Hash() != checkpoint.Hash() || chain.JustifiedHeader().Hash() != nextHeaders[epoch-1].Hash() {
+		t.Fatalf("got finalized height %d, justified height %d", chain.FinalizedHeader().Height, chain.JustifiedHeader().Height)
+	}
+}
+
+func TestHeaderChainFinalize(t *testing.T) {
+	xprv := mockFederation(t, 1)[0]
+	db := dbm.NewMemDB()
+	chain, err := NewHeaderChain(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := chain.BestBlockHeader()
+	epoch := int(consensus.ActiveNetParams.BlocksOfEpoch)
+	headers := mockHeaders(xprv, genesis, 2*epoch+10, 0)
+	if err := processHeaders(chain, headers); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sup links are added to the synced checkpoints
+	if err := chain.ProcessHeaders([]*types.BlockHeader{mockSupLink(headers[epoch-1], genesis, xprv)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if chain.FinalizedHeader().Height != 0 || chain.JustifiedHeader().Height != uint64(epoch) {
+		t.Fatalf("got finalized height %d, justified height %d", chain.FinalizedHeader().Height, chain.JustifiedHeader().Height)
+	}
+
+	if err := chain.ProcessHeaders([]*types.BlockHeader{mockSupLink(headers[2*epoch-1], headers[epoch-1], xprv)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if chain.FinalizedHeader().Height != uint64(epoch) || chain.JustifiedHeader().Height != uint64(2*epoch) {
+		t.Fatalf("got finalized height %d, justified height %d", chain.FinalizedHeader().Height, chain.JustifiedHeader().Height)
+	}
+
+	forkHeaders := mockHeaders(xprv, headers[epoch/2], 2*epoch, 1)
+	for i, c := range []struct {
+		fn  func() error
+		err error
+	}{
+		{fn: func() error { return chain.ProcessHeaders(forkHeaders) }, err: errFinalizedConflict},
+		{fn: func() error { return chain.CheckCheckpoint(uint64(epoch), hashOf(forkHeaders[epoch/2-1])) }, err: errFinalizedConflict},
+		{fn: func() error { return chain.CheckCheckpoint(uint64(3*epoch), hashOf(forkHeaders[0])) }, err: errNotFoundHeader},
+		{fn: func() error { return chain.CheckCheckpoint(uint64(epoch), hashOf(headers[epoch-1])) }, err: nil},
+	} {
+		if err := c.fn(); errors.Root(err) != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+		}
+	}
+
+	// the fork after the justified checkpoint doesn't extend it
+	checkpointFork := mockHeaders(xprv, headers[2*epoch-2], epoch+20, 1)
+	if err := processHeaders(chain, checkpointFork); err != nil {
+		t.Fatal(err)
+	}
+
+	if best := chain.BestBlockHeader(); best.Hash() != headers[2*epoch+9].Hash() {
+		t.Fatalf("got best height %d, want the tip of the justified chain", best.Height)
+	}
+
+	if err := chain.CheckCheckpoint(uint64(3*epoch), hashOf(checkpointFork[epoch])); errors.Root(err) != errCheckpointConflict {
+		t.Fatalf("got error %v, want %v", err, errCheckpointConflict)
+	}
+
+	// the status is reloaded from the db
+	reloaded, err := NewHeaderChain(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded.BestBlockHeight() != chain.BestBlockHeight() || reloaded.FinalizedHeader().Hash() != chain.FinalizedHeader().Hash() || reloaded.JustifiedHeader().Hash() != chain.JustifiedHeader().Hash() {
+		t.Fatal("the reloaded header chain mismatch the saved one")
+	}
+}
+
+func TestHeaderChainBlocks(t *testing.T) {
+	xprv := mockFederation(t, 1)[0]
+	chain, err := NewHeaderChain(dbm.NewMemDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis, err := chain.GetBlockByHeight(0)
+	if err != nil || len(genesis.Transactions) == 0 {
+		t.Fatalf("got genesis block %v, error %v", genesis, err)
+	}
+
+	headers := mockHeaders(xprv, &genesis.BlockHeader, 5, 0)
+	if err := chain.ProcessHeaders(headers); err != nil {
+		t.Fatal(err)
+	}
+
+	if toSync := chain.BlocksToSync(3); len(toSync) != 3 || toSync[0].Height != 1 {
+		t.Fatalf("got %d blocks to sync", len(toSync))
+	}
+
+	for _, i := range []int{1, 0, 2} {
+		if err := chain.SaveBlock(&types.Block{BlockHeader: *headers[i]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if chain.BlockHeight() != 3 {
+		t.Fatalf("got block height %d, want 3", chain.BlockHeight())
+	}
+
+	select {
+	case <-chain.BlockWaiter(3):
+	case <-time.After(time.Second):
+		t.Fatal("the block waiter is not notified")
+	}
+
+	if err := chain.SaveBlock(&types.Block{BlockHeader: *mockHeaders(xprv, headers[4], 1, 0)[0]}); errors.Root(err) != errNotFoundHeader {
+		t.Fatalf("got error %v, want %v", err, errNotFoundHeader)
+	}
+
+	// the synced blocks are reset to the fork point
+	if err := chain.ProcessHeaders(mockHeaders(xprv, headers[1], 5, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if chain.BlockHeight() != 2 || !chain.BlockExist(hashOf(headers[2])) || chain.InMainChain(headers[2].Hash()) {
+		t.Fatalf("got block height %d after the fork", chain.BlockHeight())
+	}
+
+	if toSync := chain.BlocksToSync(10); len(toSync) != 5 || toSync[0].Height != 3 {
+		t.Fatalf("got %d blocks to sync after the fork", len(toSync))
+	}
+}
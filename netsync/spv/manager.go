@@ -0,0 +1,479 @@
+package spv
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"kuskcore/account"
+	"kuskcore/consensus"
+	"kuskcore/errors"
+	msgs "kuskcore/netsync/messages"
+	"kuskcore/netsync/peers"
+	"kuskcore/p2p"
+	"kuskcore/p2p/security"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+const (
+	logModule = "spv"
+
+	syncCycle              = 5 * time.Second
+	blockRequestTimeout    = 30 * time.Second
+	maxNumOfHeadersPerMsg  = 1000
+	maxNumOfBlocksInFlight = 64
+
+	// the full node serving the merkle blocks to the light node
+	servicesOfSyncPeer = consensus.SFFullNode | consensus.SFSPV
+)
+
+var (
+	errInvalidPeer        = errors.New("invalid peer")
+	errInvalidMerkleProof = errors.New("invalid merkle proof of the related transactions")
+	errNoRelayPeer        = errors.New("no full node peer to relay the transaction")
+)
+
+// Switch is the interface for network layer
+type Switch interface {
+	AddReactor(name string, reactor p2p.Reactor) p2p.Reactor
+}
+
+// AccountManager provides the control programs of the wallet, they're loaded
+// to the peers as the filter of the blocks
+type AccountManager interface {
+	ListControlProgram() ([]*account.CtrlProgram, error)
+}
+
+type checkpoint struct {
+	height uint64
+	hash   *bc.Hash
+	// the headers justifying the checkpoint are requested
+	requested bool
+}
+
+type blockRequest struct {
+	peerID string
+	time   time.Time
+}
+
+// Manager syncs the block headers and the blocks filtered by the control
+// programs of the wallet from the full nodes
+type Manager struct {
+	chain    *HeaderChain
+	accounts AccountManager
+	peers    *peers.PeerSet
+
+	mtx         sync.Mutex
+	filter      [][]byte
+	checkpoints map[string]*checkpoint
+	requests    map[bc.Hash]*blockRequest
+
+	syncCh chan struct{}
+	quit   chan struct{}
+}
+
+// NewManager create the light node sync manager.
+func NewManager(sw Switch, chain *HeaderChain, accounts AccountManager, peers *peers.PeerSet) *Manager {
+	manager := &Manager{
+		chain:       chain,
+		accounts:    accounts,
+		peers:       peers,
+		checkpoints: make(map[string]*checkpoint),
+		requests:    make(map[bc.Hash]*blockRequest),
+		syncCh:      make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+	}
+	manager.filter = manager.controlPrograms()
+	sw.AddReactor("PROTOCOL", NewProtocolReactor(manager))
+	return manager
+}
+
+// AddPeer add the network layer peer to logic layer and loads the filter
+func (m *Manager) AddPeer(peer peers.BasePeer) {
+	m.peers.AddPeer(peer)
+	if p := m.peers.GetPeer(peer.ID()); p != nil && !p.LoadFilter(m.currentFilter()) {
+		m.peers.RemovePeer(p.ID())
+	}
+}
+
+// RemovePeer delete peer for peer set
+func (m *Manager) RemovePeer(peerID string) {
+	m.mtx.Lock()
+	delete(m.checkpoints, peerID)
+	m.mtx.Unlock()
+	m.peers.RemovePeer(peerID)
+}
+
+// SendStatus sent the current self status to remote peer
+func (m *Manager) SendStatus(peer peers.BasePeer) error {
+	p := m.peers.GetPeer(peer.ID())
+	if p == nil {
+		return errInvalidPeer
+	}
+
+	if err := p.SendStatus(m.chain.BestBlockHeader(), m.chain.JustifiedHeader()); err != nil {
+		m.peers.RemovePeer(p.ID())
+		return err
+	}
+	return nil
+}
+
+// IsCaughtUp check wheather the headers and the filtered blocks are synced
+func (m *Manager) IsCaughtUp() bool {
+	bestHeight := m.chain.BestBlockHeight()
+	peer := m.peers.BestPeer(servicesOfSyncPeer)
+	return (peer == nil || peer.Height() <= bestHeight) && m.chain.BlockHeight() >= bestHeight
+}
+
+// Start the light node sync loop
+func (m *Manager) Start() error {
+	go m.syncLoop()
+	return nil
+}
+
+// Stop the light node sync loop
+func (m *Manager) Stop() {
+	close(m.quit)
+}
+
+// RelayTx sends the transaction to the full node peers, the light node has no
+// mempool so the transaction is validated by the full nodes
+func (m *Manager) RelayTx(tx *types.Tx) error {
+	relayed := false
+	for _, peer := range m.peers.GetPeersByHeight(0) {
+		if !peer.ServiceFlag().IsEnable(consensus.SFFullNode) {
+			continue
+		}
+
+		if err := peer.SendTransactions([]*types.Tx{tx}); err != nil {
+			log.WithFields(log.Fields{"module": logModule, "peer": peer.Addr(), "err": err}).Warning("fail on relay tx")
+			m.peers.RemovePeer(peer.ID())
+			continue
+		}
+		relayed = true
+	}
+
+	if !relayed {
+		return errNoRelayPeer
+	}
+	return nil
+}
+
+func (m *Manager) handleHeadersMsg(peer *peers.Peer, msg *msgs.HeadersMessage) {
+	headers, err := msg.GetHeaders()
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Debug("fail on handleHeadersMsg GetHeaders")
+		return
+	}
+
+	if err := m.chain.ProcessHeaders(headers); err != nil {
+		m.peers.ProcessIllegal(peer.ID(), security.LevelMsgIllegal, err.Error())
+		return
+	}
+
+	m.processPendingCheckpoints()
+	if hashes := m.chain.ValidatorsToSync(); len(hashes) != 0 {
+		m.requestValidators(peer, hashes)
+	} else if len(headers) >= maxNumOfHeadersPerMsg {
+		m.requestHeaders(peer)
+	}
+	m.requestBlocks()
+}
+
+func (m *Manager) handleValidatorsMsg(peer *peers.Peer, msg *msgs.ValidatorsMessage) {
+	blocks := make([]*types.Block, 0, len(msg.MerkleBlocks))
+	for _, merkleBlock := range msg.MerkleBlocks {
+		block, err := verifyMerkleBlock(merkleBlock)
+		if err != nil {
+			m.peers.ProcessIllegal(peer.ID(), security.LevelMsgIllegal, err.Error())
+			return
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	if err := m.chain.ProcessValidators(msg.GetHash(), blocks); errors.Root(err) == errInvalidValidators {
+		m.peers.ProcessIllegal(peer.ID(), security.LevelMsgIllegal, err.Error())
+		return
+	} else if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Debug("fail on handleValidatorsMsg process validators")
+		return
+	}
+
+	m.requestHeaders(peer)
+}
+
+func (m *Manager) handleMerkleBlockMsg(peer *peers.Peer, msg *msgs.MerkleBlockMessage) {
+	block, err := verifyMerkleBlock(msg)
+	if err != nil {
+		m.peers.ProcessIllegal(peer.ID(), security.LevelMsgIllegal, err.Error())
+		return
+	}
+
+	hash := block.Hash()
+	m.mtx.Lock()
+	request, ok := m.requests[hash]
+	if ok && request.peerID == peer.ID() {
+		delete(m.requests, hash)
+	}
+	m.mtx.Unlock()
+	if !ok || request.peerID != peer.ID() {
+		return
+	}
+
+	if err := m.chain.SaveBlock(block); err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Warning("fail on handleMerkleBlockMsg save block")
+		return
+	}
+
+	m.requestBlocks()
+}
+
+func (m *Manager) handleStatusMsg(peer *peers.Peer, msg *msgs.StatusMessage) {
+	peer.SetBestStatus(msg.BestHeight, msg.GetBestHash())
+	peer.SetJustifiedStatus(msg.JustifiedHeight, msg.GetIrreversibleHash())
+	m.processCheckpoint(peer.ID(), &checkpoint{height: msg.JustifiedHeight, hash: msg.GetIrreversibleHash()})
+	m.triggerSync()
+}
+
+// processCheckpoint processes the justified checkpoint of the peer, it's kept
+// until the header of the checkpoint is synced. The checkpoint is not trusted,
+// the headers from the justified checkpoint to it are requested again since
+// the sup links justifying the checkpoints may be added after they're synced.
+func (m *Manager) processCheckpoint(peerID string, cp *checkpoint) {
+	err := m.chain.CheckCheckpoint(cp.height, cp.hash)
+
+	m.mtx.Lock()
+	prevCp, ok := m.checkpoints[peerID]
+	requested := ok && prevCp.requested && *prevCp.hash == *cp.hash
+	delete(m.checkpoints, peerID)
+	if errors.Root(err) == errNotFoundHeader {
+		m.checkpoints[peerID] = cp
+	}
+
+	justified := m.chain.JustifiedHeader()
+	if err == nil && cp.height > justified.Height {
+		cp.requested = true
+		m.checkpoints[peerID] = cp
+	}
+	m.mtx.Unlock()
+
+	if err != nil && errors.Root(err) != errNotFoundHeader {
+		m.peers.ProcessIllegal(peerID, security.LevelMsgIllegal, err.Error())
+		return
+	}
+
+	if peer := m.peers.GetPeer(peerID); peer != nil && cp.requested && !requested {
+		justifiedHash := justified.Hash()
+		if !peer.GetHeaders([]*bc.Hash{&justifiedHash}, cp.hash, 0) {
+			m.peers.RemovePeer(peerID)
+		}
+	}
+}
+
+func (m *Manager) processPendingCheckpoints() {
+	m.mtx.Lock()
+	checkpoints := make(map[string]*checkpoint, len(m.checkpoints))
+	for peerID, cp := range m.checkpoints {
+		checkpoints[peerID] = cp
+	}
+	m.mtx.Unlock()
+
+	for peerID, cp := range checkpoints {
+		m.processCheckpoint(peerID, cp)
+	}
+}
+
+func (m *Manager) processMsg(basePeer peers.BasePeer, msgType byte, msg msgs.BlockchainMessage) {
+	peer := m.peers.GetPeer(basePeer.ID())
+	if peer == nil {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"module":  logModule,
+		"peer":    basePeer.Addr(),
+		"type":    reflect.TypeOf(msg),
+		"message": msg.String(),
+	}).Debug("receive message from peer")
+	peer.MarkMsgReceived(msg)
+
+	switch msg := msg.(type) {
+	case *msgs.StatusMessage:
+		m.handleStatusMsg(peer, msg)
+
+	case *msgs.HeadersMessage:
+		m.handleHeadersMsg(peer, msg)
+
+	case *msgs.MerkleBlockMessage:
+		m.handleMerkleBlockMsg(peer, msg)
+
+	case *msgs.ValidatorsMessage:
+		m.handleValidatorsMsg(peer, msg)
+
+	case *msgs.TransactionMessage, *msgs.TransactionsMessage:
+		// the unconfirmed transactions are not tracked by the light node
+
+	default:
+		log.WithFields(log.Fields{
+			"module":       logModule,
+			"peer":         basePeer.Addr(),
+			"message_type": reflect.TypeOf(msg),
+		}).Debug("unhandled message type")
+	}
+}
+
+// requestHeaders requests the headers from the main chain of the peer
+func (m *Manager) requestHeaders(peer *peers.Peer) {
+	if !peer.GetHeaders(m.chain.BlockLocator(), peer.BestHash(), 0) {
+		m.peers.RemovePeer(peer.ID())
+	}
+}
+
+// requestValidators requests the votes of the epochs ending at the checkpoints,
+// the validators elected by them sign the headers of the next epochs
+func (m *Manager) requestValidators(peer *peers.Peer, hashes []*bc.Hash) {
+	for _, hash := range hashes {
+		if !peer.GetValidators(hash) {
+			m.peers.RemovePeer(peer.ID())
+			return
+		}
+	}
+}
+
+// requestBlocks requests the filtered blocks of the main chain, the requests
+// are sent to the peers reaching the block height
+func (m *Manager) requestBlocks() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for hash, request := range m.requests {
+		if time.Since(request.time) > blockRequestTimeout {
+			delete(m.requests, hash)
+		}
+	}
+
+	for _, header := range m.chain.BlocksToSync(maxNumOfBlocksInFlight) {
+		hash := header.Hash()
+		if _, ok := m.requests[hash]; ok {
+			continue
+		}
+
+		if len(m.requests) >= maxNumOfBlocksInFlight {
+			return
+		}
+
+		peer := m.peers.BestPeer(servicesOfSyncPeer)
+		if peer == nil || peer.Height() < header.Height {
+			return
+		}
+
+		if !peer.GetMerkleBlock(&hash) {
+			m.peers.RemovePeer(peer.ID())
+			return
+		}
+
+		m.requests[hash] = &blockRequest{peerID: peer.ID(), time: time.Now()}
+	}
+}
+
+func (m *Manager) syncLoop() {
+	ticker := time.NewTicker(syncCycle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.syncCh:
+		case <-ticker.C:
+		case <-m.quit:
+			return
+		}
+
+		m.updateFilter()
+		if peer := m.peers.BestPeer(servicesOfSyncPeer); peer != nil && peer.Height() > m.chain.BestBlockHeight() {
+			m.requestHeaders(peer)
+		}
+		m.requestBlocks()
+	}
+}
+
+func (m *Manager) triggerSync() {
+	select {
+	case m.syncCh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) controlPrograms() [][]byte {
+	cps, err := m.accounts.ListControlProgram()
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err}).Error("fail on list control programs")
+		return nil
+	}
+
+	programs := make([][]byte, 0, len(cps))
+	for _, cp := range cps {
+		programs = append(programs, cp.ControlProgram)
+	}
+	return programs
+}
+
+func (m *Manager) currentFilter() [][]byte {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.filter
+}
+
+// updateFilter reloads the filter of the peers when the control programs of
+// the wallet are created
+func (m *Manager) updateFilter() {
+	filter := m.controlPrograms()
+	m.mtx.Lock()
+	changed := len(filter) != len(m.filter)
+	m.filter = filter
+	m.mtx.Unlock()
+	if !changed {
+		return
+	}
+
+	for _, peer := range m.peers.GetPeersByHeight(0) {
+		if !peer.LoadFilter(filter) {
+			m.peers.RemovePeer(peer.ID())
+		}
+	}
+}
+
+// verifyMerkleBlock decodes the merkle block and checks the related
+// transactions are proved by the merkle root of the block header
+func verifyMerkleBlock(msg *msgs.MerkleBlockMessage) (*types.Block, error) {
+	block := &types.Block{}
+	if err := block.BlockHeader.UnmarshalText(msg.RawBlockHeader); err != nil {
+		return nil, err
+	}
+
+	txHashes := make([]*bc.Hash, 0, len(msg.TxHashes))
+	for _, rawHash := range msg.TxHashes {
+		hash := bc.NewHash(rawHash)
+		txHashes = append(txHashes, &hash)
+	}
+
+	relatedHashes := make([]*bc.Hash, 0, len(msg.RawTxDatas))
+	for _, rawTx := range msg.RawTxDatas {
+		tx := &types.Tx{}
+		if err := tx.UnmarshalText(rawTx); err != nil {
+			return nil, err
+		}
+
+		block.Transactions = append(block.Transactions, tx)
+		relatedHashes = append(relatedHashes, &tx.ID)
+	}
+
+	if !types.ValidateTxMerkleTreeProof(txHashes, msg.Flags, relatedHashes, block.TransactionsMerkleRoot) {
+		return nil, errors.WithDetailf(errInvalidMerkleProof, "block %d", block.Height)
+	}
+	return block, nil
+}
@@ -0,0 +1,86 @@
+package spv
+
+import (
+	"testing"
+
+	"kuskcore/errors"
+	msgs "kuskcore/netsync/messages"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+func mockMerkleBlock(t *testing.T, block *types.Block, relatedTxs []*types.Tx) *msgs.MerkleBlockMessage {
+	msg := msgs.NewMerkleBlockMessage()
+	if err := msg.SetRawBlockHeader(block.BlockHeader); err != nil {
+		t.Fatal(err)
+	}
+
+	txHashes, txFlags := types.GetTxMerkleTreeProof(block.Transactions, relatedTxs)
+	if err := msg.SetTxInfo(txHashes, txFlags, relatedTxs); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestVerifyMerkleBlock(t *testing.T) {
+	block := &types.Block{BlockHeader: types.BlockHeader{Version: 1, Height: 1}}
+	bcTxs := []*bc.Tx{}
+	for i := uint64(0); i < 7; i++ {
+		tx := types.NewTx(types.TxData{
+			Version: 1,
+			Inputs:  []*types.TxInput{types.NewSpendInput(nil, bc.Hash{V0: i + 1}, bc.AssetID{V0: 1}, i+1, i, []byte{byte(i)}, nil)},
+			Outputs: []*types.TxOutput{types.NewOriginalTxOutput(bc.AssetID{V0: 1}, i+1, []byte{byte(i)}, nil)},
+		})
+		block.Transactions = append(block.Transactions, tx)
+		bcTxs = append(bcTxs, tx.Tx)
+	}
+
+	merkleRoot, err := types.TxMerkleRoot(bcTxs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.TransactionsMerkleRoot = merkleRoot
+
+	otherBlock := &types.Block{BlockHeader: block.BlockHeader, Transactions: block.Transactions[:6]}
+	forged := mockMerkleBlock(t, otherBlock, otherBlock.Transactions[4:5])
+	forged.RawBlockHeader = mockMerkleBlock(t, block, nil).RawBlockHeader
+
+	// the related transaction is replaced after the proof is built
+	replaced := mockMerkleBlock(t, block, block.Transactions[1:2])
+	replaced.RawTxDatas = mockMerkleBlock(t, block, block.Transactions[5:6]).RawTxDatas
+
+	cases := []struct {
+		msg     *msgs.MerkleBlockMessage
+		related []*types.Tx
+		err     error
+	}{
+		{msg: mockMerkleBlock(t, block, block.Transactions[2:4]), related: block.Transactions[2:4]},
+		{msg: mockMerkleBlock(t, block, []*types.Tx{block.Transactions[0], block.Transactions[6]}), related: []*types.Tx{block.Transactions[0], block.Transactions[6]}},
+		{msg: mockMerkleBlock(t, block, nil)},
+		{msg: forged, err: errInvalidMerkleProof},
+		{msg: replaced, err: errInvalidMerkleProof},
+	}
+
+	for i, c := range cases {
+		got, err := verifyMerkleBlock(c.msg)
+		if errors.Root(err) != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if got.Hash() != block.Hash() || len(got.Transactions) != len(c.related) {
+			t.Errorf("case %d: got block of %d txs, want %d", i, len(got.Transactions), len(c.related))
+			continue
+		}
+
+		for j, tx := range got.Transactions {
+			if tx.ID != c.related[j].ID {
+				t.Errorf("case %d: got tx %d of %s, want %s", i, j, tx.ID.String(), c.related[j].ID.String())
+			}
+		}
+	}
+}
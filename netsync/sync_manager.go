@@ -2,6 +2,7 @@ package netsync
 
 import (
 	"errors"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -12,8 +13,12 @@ import (
 	"kuskcore/netsync/chainmgr"
 	"kuskcore/netsync/consensusmgr"
 	"kuskcore/netsync/peers"
+	"kuskcore/netsync/spv"
 	"kuskcore/p2p"
+	"kuskcore/p2p/addrbook"
+	"kuskcore/p2p/security"
 	"kuskcore/protocol"
+	"kuskcore/protocol/bc/types"
 )
 
 const (
@@ -21,7 +26,9 @@ const (
 )
 
 var (
-	errVaultModeDialPeer = errors.New("can't dial peer in vault mode")
+	errVaultModeDialPeer   = errors.New("can't dial peer in vault mode")
+	errVaultModeManagePeer = errors.New("can't manage peer in vault mode")
+	errNotLightMode        = errors.New("the transactions are relayed by the light node only")
 )
 
 // ChainMgr is the interface for p2p chain message sync manager.
@@ -31,6 +38,11 @@ type ChainMgr interface {
 	Stop()
 }
 
+// TxRelay is the interface for relaying the transactions of the light node.
+type TxRelay interface {
+	RelayTx(tx *types.Tx) error
+}
+
 // ConsensusMgr is the interface for consensus message sync manager.
 type ConsensusMgr interface {
 	Start() error
@@ -43,6 +55,13 @@ type Switch interface {
 	Stop() error
 	IsListening() bool
 	DialPeerWithAddress(addr *p2p.NetAddress) error
+	ListKnownAddresses() []*addrbook.KnownAddress
+	BanPeer(target string, duration time.Duration, reason string) error
+	UnbanPeer(target string) error
+	ListBannedPeers() []*security.BanEntry
+	AddWhitelistPeer(target string) error
+	RemoveWhitelistPeer(target string) error
+	ListWhitelistPeers() []string
 	Peers() *p2p.PeerSet
 }
 
@@ -52,6 +71,7 @@ type SyncManager struct {
 	sw           Switch
 	chainMgr     ChainMgr
 	consensusMgr ConsensusMgr
+	txRelay      TxRelay
 	peers        *peers.PeerSet
 }
 
@@ -67,7 +87,7 @@ func NewSyncManager(config *config.Config, chain *protocol.Chain, txPool *protoc
 	if err != nil {
 		return nil, err
 	}
-	consensusMgr := consensusmgr.NewManager(sw, chain, peers, dispatcher)
+	consensusMgr := consensusmgr.NewManager(sw, chain, txPool, peers, dispatcher)
 	return &SyncManager{
 		config:       config,
 		sw:           sw,
@@ -77,6 +97,25 @@ func NewSyncManager(config *config.Config, chain *protocol.Chain, txPool *protoc
 	}, nil
 }
 
+// NewLightSyncManager create the sync manager of the light node, it syncs the
+// block headers and the blocks filtered by the wallet instead of the full blocks.
+func NewLightSyncManager(config *config.Config, chain *spv.HeaderChain, accounts spv.AccountManager) (*SyncManager, error) {
+	sw, err := p2p.NewSwitch(config)
+	if err != nil {
+		return nil, err
+	}
+	peers := peers.NewPeerSet(sw)
+	spvMgr := spv.NewManager(sw, chain, accounts, peers)
+
+	return &SyncManager{
+		config:   config,
+		sw:       sw,
+		chainMgr: spvMgr,
+		txRelay:  spvMgr,
+		peers:    peers,
+	}, nil
+}
+
 // Start message sync manager service.
 func (sm *SyncManager) Start() error {
 	if err := sm.sw.Start(); err != nil {
@@ -88,13 +127,18 @@ func (sm *SyncManager) Start() error {
 		return err
 	}
 
+	if sm.consensusMgr == nil {
+		return nil
+	}
 	return sm.consensusMgr.Start()
 }
 
 // Stop message sync manager service.
 func (sm *SyncManager) Stop() {
 	sm.chainMgr.Stop()
-	sm.consensusMgr.Stop()
+	if sm.consensusMgr != nil {
+		sm.consensusMgr.Stop()
+	}
 	if !sm.config.VaultMode {
 		sm.sw.Stop()
 	}
@@ -151,6 +195,62 @@ func (sm *SyncManager) GetPeerInfos() []*peers.PeerInfo {
 	return sm.peers.GetPeerInfos()
 }
 
+// ListKnownAddresses return the addresses of the address book.
+func (sm *SyncManager) ListKnownAddresses() []*addrbook.KnownAddress {
+	if sm.config.VaultMode {
+		return []*addrbook.KnownAddress{}
+	}
+	return sm.sw.ListKnownAddresses()
+}
+
+// BanPeer ban the peer ip or node pubkey for the duration.
+func (sm *SyncManager) BanPeer(target string, duration time.Duration, reason string) error {
+	if sm.config.VaultMode {
+		return errVaultModeManagePeer
+	}
+	return sm.sw.BanPeer(target, duration, reason)
+}
+
+// UnbanPeer lift the ban of the peer ip or node pubkey.
+func (sm *SyncManager) UnbanPeer(target string) error {
+	if sm.config.VaultMode {
+		return errVaultModeManagePeer
+	}
+	return sm.sw.UnbanPeer(target)
+}
+
+// ListBannedPeers return the current bans.
+func (sm *SyncManager) ListBannedPeers() []*security.BanEntry {
+	if sm.config.VaultMode {
+		return []*security.BanEntry{}
+	}
+	return sm.sw.ListBannedPeers()
+}
+
+// AddWhitelistPeer add the peer ip or node pubkey to the whitelist.
+func (sm *SyncManager) AddWhitelistPeer(target string) error {
+	if sm.config.VaultMode {
+		return errVaultModeManagePeer
+	}
+	return sm.sw.AddWhitelistPeer(target)
+}
+
+// RemoveWhitelistPeer remove the peer ip or node pubkey from the whitelist.
+func (sm *SyncManager) RemoveWhitelistPeer(target string) error {
+	if sm.config.VaultMode {
+		return errVaultModeManagePeer
+	}
+	return sm.sw.RemoveWhitelistPeer(target)
+}
+
+// ListWhitelistPeers return the whitelisted peer ips and node pubkeys.
+func (sm *SyncManager) ListWhitelistPeers() []string {
+	if sm.config.VaultMode {
+		return []string{}
+	}
+	return sm.sw.ListWhitelistPeers()
+}
+
 // StopPeer try to stop peer by given ID
 func (sm *SyncManager) StopPeer(peerID string) error {
 	if peer := sm.peers.GetPeer(peerID); peer == nil {
@@ -159,3 +259,11 @@ func (sm *SyncManager) StopPeer(peerID string) error {
 	sm.peers.RemovePeer(peerID)
 	return nil
 }
+
+// RelayTx sends the transaction submitted to the light node to the peers
+func (sm *SyncManager) RelayTx(tx *types.Tx) error {
+	if sm.txRelay == nil {
+		return errNotLightMode
+	}
+	return sm.txRelay.RelayTx(tx)
+}
@@ -29,6 +29,8 @@ import (
 	kuskLog "kuskcore/log"
 	"kuskcore/net/websocket"
 	"kuskcore/netsync"
+	"kuskcore/netsync/spv"
+	"kuskcore/order"
 	"kuskcore/protocol"
 	w "kuskcore/wallet"
 )
@@ -52,6 +54,7 @@ type Node struct {
 	api             *api.API
 	chain           *protocol.Chain
 	traceService    *contract.TraceService
+	orderBook       *order.OrderBook
 	blockProposer   *blockproposer.BlockProposer
 	miningEnable    bool
 }
@@ -66,6 +69,11 @@ func NewNode(config *cfg.Config) *Node {
 	if config.DBBackend != "memdb" && config.DBBackend != "leveldb" {
 		cmn.Exit(cmn.Fmt("Param db_backend [%v] is invalid, use leveldb or memdb", config.DBBackend))
 	}
+
+	if config.LightMode {
+		return newLightNode(config)
+	}
+
 	coreDB := dbm.NewDB("core", config.DBBackend, config.DBDir())
 	store := database.NewStore(coreDB)
 
@@ -80,7 +88,8 @@ func NewNode(config *cfg.Config) *Node {
 		cmn.Exit(cmn.Fmt("Failed to create chain structure: %v", err))
 	}
 
-	traceService := startTraceUpdater(chain, config)
+	traceService := startTraceUpdater(chain, config, dispatcher)
+	orderBook := startOrderUpdater(chain, config)
 
 	var accounts *account.Manager
 	var assets *asset.Registry
@@ -135,6 +144,7 @@ func NewNode(config *cfg.Config) *Node {
 		wallet:          wallet,
 		chain:           chain,
 		traceService:    traceService,
+		orderBook:       orderBook,
 		miningEnable:    config.Mining,
 		notificationMgr: notificationMgr,
 	}
@@ -144,15 +154,79 @@ func NewNode(config *cfg.Config) *Node {
 	return node
 }
 
-func startTraceUpdater(chain *protocol.Chain, cfg *cfg.Config) *contract.TraceService {
+// newLightNode create the light node, it keeps the block headers and the blocks
+// filtered by the wallet instead of the core chain
+func newLightNode(config *cfg.Config) *Node {
+	if config.Wallet.Disable || config.Mining {
+		cmn.Exit("The light node requires the wallet and doesn't support mining")
+	}
+
+	headerDB := dbm.NewDB("header", config.DBBackend, config.DBDir())
+	headerChain, err := spv.NewHeaderChain(headerDB)
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to create header chain: %v", err))
+	}
+
+	tokenDB := dbm.NewDB("accesstoken", config.DBBackend, config.DBDir())
+	accessTokens := accesstoken.NewStore(tokenDB)
+	dispatcher := event.NewDispatcher()
+
+	hsm, err := pseudohsm.New(config.KeysDir())
+	if err != nil {
+		cmn.Exit(cmn.Fmt("initialize HSM failed: %v", err))
+	}
+
+	walletDB := dbm.NewDB("wallet", config.DBBackend, config.DBDir())
+	accounts := account.NewManager(walletDB, headerChain)
+	assets := asset.NewRegistry(walletDB, nil)
+	contracts := contract.NewRegistry(walletDB)
+	wallet, err := w.NewWallet(walletDB, accounts, assets, contracts, hsm, headerChain, dispatcher, config.Wallet.TxIndex)
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to create wallet: %v", err))
+	}
+
+	// trigger rescan wallet
+	if config.Wallet.Rescan {
+		wallet.RescanBlocks()
+	}
+
+	syncManager, err := netsync.NewLightSyncManager(config, headerChain, accounts)
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to create sync manager: %v", err))
+	}
+
+	node := &Node{
+		eventDispatcher: dispatcher,
+		config:          config,
+		syncManager:     syncManager,
+		accessTokens:    accessTokens,
+		wallet:          wallet,
+	}
+
+	node.BaseService = *cmn.NewBaseService(nil, "Node", node)
+	return node
+}
+
+func startTraceUpdater(chain *protocol.Chain, cfg *cfg.Config, dispatcher *event.Dispatcher) *contract.TraceService {
 	db := dbm.NewDB("trace", cfg.DBBackend, cfg.DBDir())
 	store := contract.NewTraceStore(db)
-	tracerService := contract.NewTraceService(contract.NewInfrastructure(chain, store))
+	tracerService := contract.NewTraceService(contract.NewInfrastructure(chain, store, dispatcher))
 	traceUpdater := contract.NewTraceUpdater(tracerService, chain)
 	go traceUpdater.Sync()
 	return tracerService
 }
 
+func startOrderUpdater(chain *protocol.Chain, cfg *cfg.Config) *order.OrderBook {
+	db := dbm.NewDB("order", cfg.DBBackend, cfg.DBDir())
+	orderBook, err := order.NewOrderBook(db, chain)
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to create order book: %v", err))
+	}
+
+	go order.NewUpdater(orderBook, chain).Sync()
+	return orderBook
+}
+
 func initNodeConfig(config *cfg.Config) error {
 	if err := lockDataDirectory(config); err != nil {
 		cmn.Exit("Error: " + err.Error())
@@ -177,6 +251,10 @@ func lockDataDirectory(config *cfg.Config) error {
 }
 
 func initActiveNetParams(config *cfg.Config) {
+	if _, exist := consensus.NetParams[config.ChainID]; !exist && cmn.FileExists(config.GenesisFile()) {
+		initCustomNetParams(config)
+	}
+
 	var exist bool
 	consensus.ActiveNetParams, exist = consensus.NetParams[config.ChainID]
 	if !exist {
@@ -184,6 +262,28 @@ func initActiveNetParams(config *cfg.Config) {
 	}
 }
 
+// initCustomNetParams registers the network defined by the genesis file and
+// checks its genesis block against the pinned one
+func initCustomNetParams(config *cfg.Config) {
+	doc, err := cfg.LoadGenesisDoc(config.GenesisFile())
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to load genesis file: %v", err))
+	}
+
+	if doc.ChainID != config.ChainID {
+		cmn.Exit(cmn.Fmt("chain_id[%v] of the genesis file mismatch the config chain_id[%v]", doc.ChainID, config.ChainID))
+	}
+
+	genesisHash, err := cfg.RegisterGenesisDoc(doc)
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to build genesis block: %v", err))
+	}
+
+	if err := cfg.PinGenesisHash(config.GenesisHashFile(), genesisHash); err != nil {
+		cmn.Exit(cmn.Fmt("Failed to check genesis hash: %v", err))
+	}
+}
+
 func initCommonConfig(config *cfg.Config) {
 	cfg.CommonConfig = config
 }
@@ -199,7 +299,7 @@ func launchWebBrowser(port string) {
 }
 
 func (n *Node) initAndstartAPIServer() {
-	n.api = api.NewAPI(n.syncManager, n.wallet, n.blockProposer, n.chain, n.traceService, n.config, n.accessTokens, n.eventDispatcher, n.notificationMgr)
+	n.api = api.NewAPI(n.syncManager, n.wallet, n.blockProposer, n.chain, n.traceService, n.orderBook, n.config, n.accessTokens, n.eventDispatcher, n.notificationMgr)
 
 	listenAddr := env.String("LISTEN", n.config.ApiAddress)
 	env.Parse()
@@ -222,8 +322,10 @@ func (n *Node) OnStart() error {
 	}
 
 	n.initAndstartAPIServer()
-	if err := n.notificationMgr.Start(); err != nil {
-		return err
+	if n.notificationMgr != nil {
+		if err := n.notificationMgr.Start(); err != nil {
+			return err
+		}
 	}
 
 	if !n.config.Web.Closed {
@@ -238,8 +340,10 @@ func (n *Node) OnStart() error {
 }
 
 func (n *Node) OnStop() {
-	n.notificationMgr.Shutdown()
-	n.notificationMgr.WaitForShutdown()
+	if n.notificationMgr != nil {
+		n.notificationMgr.Shutdown()
+		n.notificationMgr.WaitForShutdown()
+	}
 	n.BaseService.OnStop()
 	if n.miningEnable {
 		n.blockProposer.Stop()
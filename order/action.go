@@ -0,0 +1,126 @@
+package order
+
+import (
+	"context"
+	stdjson "encoding/json"
+
+	"kuskcore/account"
+	"kuskcore/blockchain/txbuilder"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm"
+)
+
+// DecodeFillOrderAction unmarshal JSON-encoded data of fill order action
+func (ob *OrderBook) DecodeFillOrderAction(data []byte) (txbuilder.Action, error) {
+	a := &fillOrderAction{book: ob}
+	return a, stdjson.Unmarshal(data, a)
+}
+
+// fillOrderAction takes the amount of the locked asset from the order, it pays
+// the maker and locks the remainder, the released asset and the requested
+// asset are left to the other actions
+type fillOrderAction struct {
+	book     *OrderBook
+	OutputID *bc.Hash `json:"output_id"`
+	Amount   uint64   `json:"amount"`
+}
+
+func (a *fillOrderAction) ActionType() string {
+	return "fill_order"
+}
+
+func (a *fillOrderAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
+	var missing []string
+	if a.OutputID == nil {
+		missing = append(missing, "output_id")
+	}
+	if a.Amount == 0 {
+		missing = append(missing, "amount")
+	}
+	if len(missing) > 0 {
+		return txbuilder.MissingFieldsError(missing...)
+	}
+
+	order, err := a.book.GetOrder(a.OutputID)
+	if err != nil {
+		return err
+	}
+
+	exchange, err := order.ExchangeAmount(a.Amount)
+	if err != nil {
+		return err
+	}
+
+	position := b.NextOutputPosition()
+	input, err := order.input(nil)
+	if err != nil {
+		return err
+	}
+
+	sigInst := &txbuilder.SigningInstruction{}
+	sigInst.WitnessComponents = append(sigInst.WitnessComponents,
+		txbuilder.DataWitness(vm.Uint64Bytes(exchange)),
+		txbuilder.DataWitness(vm.Uint64Bytes(position)),
+		txbuilder.DataWitness(vm.BoolBytes(false)))
+	if err := b.AddInput(input, sigInst); err != nil {
+		return err
+	}
+
+	payment := types.NewOriginalTxOutput(order.RequestedAssetID, exchange, order.MakerProgram, [][]byte{order.OutputID.Bytes()})
+	if err := b.AddOutput(payment); err != nil {
+		return err
+	}
+
+	if a.Amount == order.Amount {
+		return nil
+	}
+
+	remainder := types.NewOriginalTxOutput(order.AssetID, order.Amount-a.Amount, input.ControlProgram(), order.stateData())
+	return b.AddOutput(remainder)
+}
+
+// DecodeCancelOrderAction unmarshal JSON-encoded data of cancel order action,
+// the order is signed by the local account key of the maker
+func (ob *OrderBook) DecodeCancelOrderAction(accounts *account.Manager) func([]byte) (txbuilder.Action, error) {
+	return func(data []byte) (txbuilder.Action, error) {
+		a := &cancelOrderAction{book: ob, accounts: accounts}
+		return a, stdjson.Unmarshal(data, a)
+	}
+}
+
+type cancelOrderAction struct {
+	book     *OrderBook
+	accounts *account.Manager
+	OutputID *bc.Hash `json:"output_id"`
+}
+
+func (a *cancelOrderAction) ActionType() string {
+	return "cancel_order"
+}
+
+func (a *cancelOrderAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
+	if a.OutputID == nil {
+		return txbuilder.MissingFieldsError("output_id")
+	}
+
+	order, err := a.book.GetOrder(a.OutputID)
+	if err != nil {
+		return err
+	}
+
+	acc, path, err := a.accounts.GetLocalKeyPath(order.makerKey())
+	if err != nil {
+		return err
+	}
+
+	input, err := order.input(nil)
+	if err != nil {
+		return err
+	}
+
+	sigInst := &txbuilder.SigningInstruction{}
+	sigInst.AddRawWitnessKeys(acc.XPubs, path, acc.Quorum)
+	sigInst.WitnessComponents = append(sigInst.WitnessComponents, txbuilder.DataWitness(vm.BoolBytes(true)))
+	return b.AddInput(input, sigInst)
+}
@@ -0,0 +1,229 @@
+package order
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+)
+
+var logModule = "order"
+
+var (
+	orderPrefix    = []byte("Order:")
+	orderIndexKey  = []byte("OrderIndex")
+	chainStatusKey = []byte("OrderChainStatus")
+)
+
+// ChainService is the chain used to build the order book
+type ChainService interface {
+	GetBlockByHash(*bc.Hash) (*types.Block, error)
+	GetBlockByHeight(uint64) (*types.Block, error)
+	BlockWaiter(height uint64) <-chan struct{}
+}
+
+// ChainStatus is the last block applied to the order book
+type ChainStatus struct {
+	BlockHeight uint64  `json:"block_height"`
+	BlockHash   bc.Hash `json:"block_hash"`
+}
+
+// orderKey sorts the orders by the trading pair
+func orderKey(assetID, requestedAssetID *bc.AssetID, outputID *bc.Hash) []byte {
+	return append(pairPrefix(assetID, requestedAssetID), outputID.Bytes()...)
+}
+
+func pairPrefix(assetID, requestedAssetID *bc.AssetID) []byte {
+	key := append([]byte{}, orderPrefix...)
+	key = append(key, assetID.Bytes()...)
+	return append(key, requestedAssetID.Bytes()...)
+}
+
+func orderIDKey(outputID *bc.Hash) []byte {
+	return append(append([]byte{}, orderIndexKey...), outputID.Bytes()...)
+}
+
+// OrderBook indexes the unspent limit orders of the main chain
+type OrderBook struct {
+	db     dbm.DB
+	mtx    sync.RWMutex
+	status *ChainStatus
+}
+
+// NewOrderBook load the order book, the index starts from the genesis block
+func NewOrderBook(db dbm.DB, chain ChainService) (*OrderBook, error) {
+	book := &OrderBook{db: db}
+	if data := db.Get(chainStatusKey); data != nil {
+		book.status = &ChainStatus{}
+		return book, json.Unmarshal(data, book.status)
+	}
+
+	genesis, err := chain.GetBlockByHeight(0)
+	if err != nil {
+		return nil, err
+	}
+
+	book.status = &ChainStatus{BlockHeight: 0, BlockHash: genesis.Hash()}
+	return book, nil
+}
+
+// BestChain return the last block applied to the order book
+func (ob *OrderBook) BestChain() (uint64, bc.Hash) {
+	ob.mtx.RLock()
+	defer ob.mtx.RUnlock()
+
+	return ob.status.BlockHeight, ob.status.BlockHash
+}
+
+// ApplyBlock removes the orders spent by the block and adds the new ones
+func (ob *OrderBook) ApplyBlock(block *types.Block) error {
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	batch := ob.db.NewBatch()
+	for _, tx := range block.Transactions {
+		for _, order := range inputOrders(tx) {
+			deleteOrder(batch, order)
+		}
+
+		for _, order := range outputOrders(tx) {
+			if err := saveOrder(batch, order); err != nil {
+				return err
+			}
+		}
+	}
+	return ob.saveStatus(batch, &ChainStatus{BlockHeight: block.Height, BlockHash: block.Hash()})
+}
+
+// DetachBlock restores the orders spent by the block and removes the new ones
+func (ob *OrderBook) DetachBlock(block *types.Block) error {
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	batch := ob.db.NewBatch()
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		tx := block.Transactions[i]
+		for _, order := range outputOrders(tx) {
+			deleteOrder(batch, order)
+		}
+
+		for _, order := range inputOrders(tx) {
+			if err := saveOrder(batch, order); err != nil {
+				return err
+			}
+		}
+	}
+	return ob.saveStatus(batch, &ChainStatus{BlockHeight: block.Height - 1, BlockHash: block.PreviousBlockHash})
+}
+
+// GetOrder return the unspent order by the output id
+func (ob *OrderBook) GetOrder(outputID *bc.Hash) (*Order, error) {
+	key := ob.db.Get(orderIDKey(outputID))
+	if key == nil {
+		return nil, ErrOrderNotFound
+	}
+
+	data := ob.db.Get(key)
+	if data == nil {
+		return nil, ErrOrderNotFound
+	}
+
+	order := &Order{}
+	return order, json.Unmarshal(data, order)
+}
+
+// ListOrders return the orders selling the asset for the requested asset,
+// the cheapest order is the first
+func (ob *OrderBook) ListOrders(assetID, requestedAssetID *bc.AssetID) ([]*Order, error) {
+	iter := ob.db.IteratorPrefix(pairPrefix(assetID, requestedAssetID))
+	defer iter.Release()
+
+	orders := []*Order{}
+	for iter.Next() {
+		order := &Order{}
+		if err := json.Unmarshal(iter.Value(), order); err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, order)
+	}
+
+	sort.SliceStable(orders, func(i, j int) bool { return cheaper(orders[i], orders[j]) })
+	return orders, nil
+}
+
+func (ob *OrderBook) saveStatus(batch dbm.Batch, status *ChainStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	batch.Set(chainStatusKey, data)
+	batch.Write()
+	ob.status = status
+	return nil
+}
+
+func saveOrder(batch dbm.Batch, order *Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	key := orderKey(&order.AssetID, &order.RequestedAssetID, &order.OutputID)
+	batch.Set(key, data)
+	batch.Set(orderIDKey(&order.OutputID), key)
+	return nil
+}
+
+func deleteOrder(batch dbm.Batch, order *Order) {
+	batch.Delete(orderKey(&order.AssetID, &order.RequestedAssetID, &order.OutputID))
+	batch.Delete(orderIDKey(&order.OutputID))
+}
+
+// Updater keeps the order book following the main chain
+type Updater struct {
+	book  *OrderBook
+	chain ChainService
+}
+
+// NewUpdater create the updater of the order book
+func NewUpdater(book *OrderBook, chain ChainService) *Updater {
+	return &Updater{book: book, chain: chain}
+}
+
+// Sync applies the new blocks and detaches the blocks rolled back
+func (u *Updater) Sync() {
+	for {
+		height, hash := u.book.BestChain()
+		block, _ := u.chain.GetBlockByHeight(height + 1)
+		if block == nil {
+			<-u.chain.BlockWaiter(height + 1)
+			continue
+		}
+
+		if block.PreviousBlockHash != hash {
+			block, err := u.chain.GetBlockByHash(&hash)
+			if err != nil {
+				log.WithFields(log.Fields{"module": logModule, "err": err, "block_hash": hash.String()}).Error("order updater get block")
+				return
+			}
+
+			if err := u.book.DetachBlock(block); err != nil {
+				log.WithFields(log.Fields{"module": logModule, "err": err}).Error("order updater detach block")
+				return
+			}
+			continue
+		}
+
+		if err := u.book.ApplyBlock(block); err != nil {
+			log.WithFields(log.Fields{"module": logModule, "err": err}).Error("order updater apply block")
+			return
+		}
+	}
+}
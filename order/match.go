@@ -0,0 +1,83 @@
+package order
+
+import (
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+)
+
+// Match is a pair of crossed orders, orders[0] sells the asset requested by
+// orders[1]. The spreads are the amounts left to the matcher after both
+// makers are paid, spreads[i] is in the asset locked by orders[i].
+type Match struct {
+	Orders      [2]*Order `json:"orders"`
+	FillAmounts [2]uint64 `json:"fill_amounts"`
+	Exchanges   [2]uint64 `json:"exchanges"`
+	Spreads     [2]uint64 `json:"spreads"`
+}
+
+// MatchOrders fills the order x or the order y completely against the other
+// one, the whole x is tried first
+func MatchOrders(x, y *Order) (*Match, error) {
+	if x.AssetID != y.RequestedAssetID || x.RequestedAssetID != y.AssetID {
+		return nil, errors.WithDetail(ErrOrdersNotCross, "orders are not in the same trading pair")
+	}
+
+	if match, ok := fillFirst(x, y); ok {
+		return match, nil
+	}
+
+	if match, ok := fillFirst(y, x); ok {
+		match.Orders[0], match.Orders[1] = match.Orders[1], match.Orders[0]
+		match.FillAmounts[0], match.FillAmounts[1] = match.FillAmounts[1], match.FillAmounts[0]
+		match.Exchanges[0], match.Exchanges[1] = match.Exchanges[1], match.Exchanges[0]
+		match.Spreads[0], match.Spreads[1] = match.Spreads[1], match.Spreads[0]
+		return match, nil
+	}
+	return nil, ErrOrdersNotCross
+}
+
+// fillFirst fills the whole order x by taking the requested asset of x from
+// the order y, y is paid by the asset released from x
+func fillFirst(x, y *Order) (*Match, bool) {
+	exchangeX, err := x.ExchangeAmount(x.Amount)
+	if err != nil || exchangeX > y.Amount {
+		return nil, false
+	}
+
+	// the amount paid to y must release at least the exchange of x
+	need, ok := mulDiv(exchangeX, y.RatioNumerator, y.RatioDenominator, true)
+	if !ok {
+		return nil, false
+	}
+
+	fillY := y.FillAmount(need)
+	exchangeY, err := y.ExchangeAmount(fillY)
+	if err != nil || fillY < exchangeX || exchangeY > x.Amount {
+		return nil, false
+	}
+
+	return &Match{
+		Orders:      [2]*Order{x, y},
+		FillAmounts: [2]uint64{x.Amount, fillY},
+		Exchanges:   [2]uint64{exchangeX, exchangeY},
+		Spreads:     [2]uint64{x.Amount - exchangeY, fillY - exchangeX},
+	}, true
+}
+
+// BestMatch matches the cheapest orders of both sides of the trading pair
+func (ob *OrderBook) BestMatch(assetID, requestedAssetID *bc.AssetID) (*Match, error) {
+	asks, err := ob.ListOrders(assetID, requestedAssetID)
+	if err != nil {
+		return nil, err
+	}
+
+	bids, err := ob.ListOrders(requestedAssetID, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(asks) == 0 || len(bids) == 0 {
+		return nil, errors.WithDetail(ErrOrdersNotCross, "no orders on one side of the trading pair")
+	}
+	return MatchOrders(asks[0], bids[0])
+}
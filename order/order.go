@@ -0,0 +1,174 @@
+// Package order indexes the limit orders on chain and matches them.
+package order
+
+import (
+	"crypto/ed25519"
+	"math/big"
+
+	"kuskcore/encoding/json"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm/vmutil"
+)
+
+// pre-define errors for supporting kusk errorFormatter
+var (
+	ErrOrderNotFound  = errors.New("order not found")
+	ErrBadFillAmount  = errors.New("invalid order fill amount")
+	ErrOrdersNotCross = errors.New("orders don't cross")
+)
+
+// Order is an unspent limit order output
+type Order struct {
+	OutputID         bc.Hash         `json:"output_id"`
+	SourceID         bc.Hash         `json:"source_id"`
+	SourcePos        uint64          `json:"source_pos"`
+	AssetID          bc.AssetID      `json:"asset_id"`
+	Amount           uint64          `json:"amount"`
+	RequestedAssetID bc.AssetID      `json:"requested_asset_id"`
+	RatioNumerator   uint64          `json:"ratio_numerator"`
+	RatioDenominator uint64          `json:"ratio_denominator"`
+	MakerProgram     json.HexBytes   `json:"maker_program"`
+	MakerPubkey      json.HexBytes   `json:"maker_pubkey"`
+	StateData        []json.HexBytes `json:"state_data"`
+}
+
+func newOrder(outputID, sourceID bc.Hash, sourcePos uint64, assetAmount bc.AssetAmount, program []byte, stateData [][]byte) (*Order, error) {
+	limitOrder, err := vmutil.ParseLimitOrder(program, stateData)
+	if err != nil {
+		return nil, err
+	}
+
+	var requestedAsset [32]byte
+	copy(requestedAsset[:], limitOrder.RequestedAsset)
+	order := &Order{
+		OutputID:         outputID,
+		SourceID:         sourceID,
+		SourcePos:        sourcePos,
+		AssetID:          *assetAmount.AssetId,
+		Amount:           assetAmount.Amount,
+		RequestedAssetID: bc.NewAssetID(requestedAsset),
+		RatioNumerator:   limitOrder.RatioNumerator,
+		RatioDenominator: limitOrder.RatioDenominator,
+		MakerProgram:     limitOrder.MakerProgram,
+		MakerPubkey:      json.HexBytes(limitOrder.MakerKey),
+	}
+	for _, data := range stateData {
+		order.StateData = append(order.StateData, data)
+	}
+	return order, nil
+}
+
+// outputOrders return the orders created by the transaction
+func outputOrders(tx *types.Tx) []*Order {
+	var orders []*Order
+	for i, out := range tx.Outputs {
+		if out.OutputType() != types.OriginalOutputType || !vmutil.IsLimitOrderProgram(out.ControlProgram) {
+			continue
+		}
+
+		resOut, err := tx.OriginalOutput(*tx.OutputID(i))
+		if err != nil {
+			continue
+		}
+
+		if order, err := newOrder(*tx.OutputID(i), *resOut.Source.Ref, resOut.Source.Position, out.AssetAmount, out.ControlProgram, out.StateData); err == nil {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+// inputOrders return the orders spent by the transaction
+func inputOrders(tx *types.Tx) []*Order {
+	var orders []*Order
+	for _, input := range tx.Inputs {
+		spend, ok := input.TypedInput.(*types.SpendInput)
+		if !ok || !vmutil.IsLimitOrderProgram(spend.ControlProgram) {
+			continue
+		}
+
+		outputID, err := input.SpentOutputID()
+		if err != nil {
+			continue
+		}
+
+		if order, err := newOrder(outputID, spend.SourceID, spend.SourcePosition, spend.AssetAmount, spend.ControlProgram, spend.StateData); err == nil {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+// ExchangeAmount return the amount of the requested asset paid to the maker
+// for taking the amount of the locked asset. The amount should be the whole
+// order or an amount can be exactly released by the ratio.
+func (o *Order) ExchangeAmount(amount uint64) (uint64, error) {
+	if amount == 0 || amount > o.Amount {
+		return 0, errors.WithDetailf(ErrBadFillAmount, "amount should be in (0, %d]", o.Amount)
+	}
+
+	exchange, ok := mulDiv(amount, o.RatioNumerator, o.RatioDenominator, true)
+	if !ok {
+		return 0, errors.WithDetail(ErrBadFillAmount, "exchange amount overflow")
+	}
+
+	if actual := o.FillAmount(exchange); actual != amount {
+		return 0, errors.WithDetailf(ErrBadFillAmount, "amount %d can't be released exactly, the nearest amount is %d", amount, actual)
+	}
+	return exchange, nil
+}
+
+// FillAmount return the amount of the locked asset released by paying the
+// exchange amount of the requested asset
+func (o *Order) FillAmount(exchange uint64) uint64 {
+	amount, ok := mulDiv(exchange, o.RatioDenominator, o.RatioNumerator, false)
+	if !ok || amount > o.Amount {
+		return o.Amount
+	}
+	return amount
+}
+
+// input return the spend input of the order output
+func (o *Order) input(arguments [][]byte) (*types.TxInput, error) {
+	program, err := vmutil.LimitOrderProgram()
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewSpendInput(arguments, o.SourceID, o.AssetID, o.Amount, o.SourcePos, program, o.stateData()), nil
+}
+
+func (o *Order) stateData() [][]byte {
+	var stateData [][]byte
+	for _, data := range o.StateData {
+		stateData = append(stateData, data)
+	}
+	return stateData
+}
+
+func (o *Order) makerKey() ed25519.PublicKey {
+	return ed25519.PublicKey(o.MakerPubkey)
+}
+
+// cheaper reports whether the price of order a is lower than the one of order b
+func cheaper(a, b *Order) bool {
+	x := new(big.Int).Mul(new(big.Int).SetUint64(a.RatioNumerator), new(big.Int).SetUint64(b.RatioDenominator))
+	y := new(big.Int).Mul(new(big.Int).SetUint64(b.RatioNumerator), new(big.Int).SetUint64(a.RatioDenominator))
+	return x.Cmp(y) < 0
+}
+
+// mulDiv return a * b / c, rounded up when roundUp is true
+func mulDiv(a, b, c uint64, roundUp bool) (uint64, bool) {
+	product := new(big.Int).Mul(new(big.Int).SetUint64(a), new(big.Int).SetUint64(b))
+	result, mod := new(big.Int).DivMod(product, new(big.Int).SetUint64(c), new(big.Int))
+	if roundUp && mod.Sign() > 0 {
+		result.Add(result, big.NewInt(1))
+	}
+
+	if !result.IsUint64() {
+		return 0, false
+	}
+	return result.Uint64(), true
+}
@@ -0,0 +1,213 @@
+package order
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm/vmutil"
+	"kuskcore/testutil"
+)
+
+var (
+	assetA = bc.NewAssetID([32]byte{1})
+	assetB = bc.NewAssetID([32]byte{2})
+)
+
+func newTestOrder(assetID, requestedAssetID bc.AssetID, amount, numerator, denominator uint64) *Order {
+	return &Order{
+		OutputID:         bc.NewHash([32]byte{byte(amount), byte(numerator), byte(denominator)}),
+		AssetID:          assetID,
+		Amount:           amount,
+		RequestedAssetID: requestedAssetID,
+		RatioNumerator:   numerator,
+		RatioDenominator: denominator,
+	}
+}
+
+func TestExchangeAmount(t *testing.T) {
+	cases := []struct {
+		order    *Order
+		amount   uint64
+		exchange uint64
+		err      error
+	}{
+		{order: newTestOrder(assetA, assetB, 100, 3, 2), amount: 100, exchange: 150},
+		{order: newTestOrder(assetA, assetB, 100, 3, 2), amount: 10, exchange: 15},
+		{order: newTestOrder(assetA, assetB, 100, 1, 3), amount: 100, exchange: 34},
+		{order: newTestOrder(assetA, assetB, 100, 1, 3), amount: 30, exchange: 10},
+		{order: newTestOrder(assetA, assetB, 100, 1, 3), amount: 31, err: ErrBadFillAmount},
+		{order: newTestOrder(assetA, assetB, 100, 3, 2), amount: 0, err: ErrBadFillAmount},
+		{order: newTestOrder(assetA, assetB, 100, 3, 2), amount: 101, err: ErrBadFillAmount},
+	}
+
+	for i, c := range cases {
+		exchange, err := c.order.ExchangeAmount(c.amount)
+		if errors.Root(err) != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+			continue
+		}
+
+		if exchange != c.exchange {
+			t.Errorf("case %d: got exchange %d, want %d", i, exchange, c.exchange)
+		}
+	}
+}
+
+func TestFillAmount(t *testing.T) {
+	order := newTestOrder(assetA, assetB, 100, 1, 3)
+	cases := []struct {
+		exchange uint64
+		amount   uint64
+	}{
+		{exchange: 1, amount: 3},
+		{exchange: 10, amount: 30},
+		{exchange: 34, amount: 100},
+		{exchange: 1000, amount: 100},
+	}
+
+	for i, c := range cases {
+		if amount := order.FillAmount(c.exchange); amount != c.amount {
+			t.Errorf("case %d: got fill amount %d, want %d", i, amount, c.amount)
+		}
+	}
+}
+
+func TestMatchOrders(t *testing.T) {
+	cases := []struct {
+		x, y  *Order
+		match *Match
+		err   error
+	}{
+		{
+			// x sells 100 A at 2 B each, y sells 300 B at 0.4 A each
+			x: newTestOrder(assetA, assetB, 100, 2, 1),
+			y: newTestOrder(assetB, assetA, 300, 2, 5),
+			match: &Match{
+				FillAmounts: [2]uint64{100, 200},
+				Exchanges:   [2]uint64{200, 80},
+				Spreads:     [2]uint64{20, 0},
+			},
+		},
+		{
+			// x sells 100 A at 2 B each, y sells 50 B at 0.4 A each
+			x: newTestOrder(assetA, assetB, 100, 2, 1),
+			y: newTestOrder(assetB, assetA, 50, 2, 5),
+			match: &Match{
+				FillAmounts: [2]uint64{20, 50},
+				Exchanges:   [2]uint64{40, 20},
+				Spreads:     [2]uint64{0, 10},
+			},
+		},
+		{
+			// x sells A at 2 B each, y only pays 0.6 A for each B
+			x:   newTestOrder(assetA, assetB, 100, 2, 1),
+			y:   newTestOrder(assetB, assetA, 300, 3, 5),
+			err: ErrOrdersNotCross,
+		},
+		{
+			x:   newTestOrder(assetA, assetB, 100, 2, 1),
+			y:   newTestOrder(assetA, assetB, 100, 1, 2),
+			err: ErrOrdersNotCross,
+		},
+	}
+
+	for i, c := range cases {
+		match, err := MatchOrders(c.x, c.y)
+		if c.err != nil {
+			if errors.Root(err) != c.err {
+				t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+
+		c.match.Orders = [2]*Order{c.x, c.y}
+		if !testutil.DeepEqual(match, c.match) {
+			t.Errorf("case %d: got match %+v, want %+v", i, match, c.match)
+		}
+	}
+}
+
+func TestOrderBook(t *testing.T) {
+	makerKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := vmutil.LimitOrderProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limitOrder := &vmutil.LimitOrder{
+		RequestedAsset:   assetB.Bytes(),
+		RatioNumerator:   2,
+		RatioDenominator: 1,
+		MakerProgram:     []byte{0x51},
+		MakerKey:         makerKey,
+	}
+	placeTx := types.NewTx(types.TxData{
+		Version: 1,
+		Inputs:  []*types.TxInput{types.NewSpendInput(nil, bc.NewHash([32]byte{9}), assetA, 100, 0, []byte{0x51}, nil)},
+		Outputs: []*types.TxOutput{types.NewOriginalTxOutput(assetA, 100, program, limitOrder.StateData())},
+	})
+	orderID := *placeTx.OutputID(0)
+
+	book := &OrderBook{db: dbm.NewMemDB(), status: &ChainStatus{}}
+	placeBlock := &types.Block{BlockHeader: types.BlockHeader{Height: 1}, Transactions: []*types.Tx{placeTx}}
+	if err := book.ApplyBlock(placeBlock); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := book.GetOrder(&orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order.Amount != 100 || order.RequestedAssetID != assetB || order.RatioNumerator != 2 || order.RatioDenominator != 1 {
+		t.Fatalf("got order %+v", order)
+	}
+
+	input, err := order.input([][]byte{{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelTx := types.NewTx(types.TxData{
+		Version: 1,
+		Inputs:  []*types.TxInput{input},
+		Outputs: []*types.TxOutput{types.NewOriginalTxOutput(assetA, 100, []byte{0x51}, nil)},
+	})
+	cancelBlock := &types.Block{BlockHeader: types.BlockHeader{Height: 2, PreviousBlockHash: placeBlock.Hash()}, Transactions: []*types.Tx{cancelTx}}
+	if err := book.ApplyBlock(cancelBlock); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := book.GetOrder(&orderID); err != ErrOrderNotFound {
+		t.Fatalf("got error %v after the order is canceled, want %v", err, ErrOrderNotFound)
+	}
+
+	if err := book.DetachBlock(cancelBlock); err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := book.ListOrders(&assetA, &assetB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(orders) != 1 || !testutil.DeepEqual(orders[0], order) {
+		t.Fatalf("got orders %+v after the cancel is detached, want %+v", orders, order)
+	}
+
+	if height, hash := book.BestChain(); height != 1 || hash != placeBlock.Hash() {
+		t.Fatalf("got best chain %d %v, want 1 %v", height, hash, placeBlock.Hash())
+	}
+}
@@ -0,0 +1,346 @@
+package addrbook
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	cfg "kuskcore/config"
+	dbm "kuskcore/database/leveldb"
+)
+
+const (
+	logModule = "addrbook"
+
+	maxKnownAddresses     = 4096 // Maximum addresses in the address book
+	maxAddressesPerBucket = 64   // Maximum addresses of the same bucket
+)
+
+var addressKeyPrefix = []byte("addr:")
+
+func calcAddressKey(addr string) []byte {
+	return append(addressKeyPrefix, []byte(addr)...)
+}
+
+// AddrBook is the persistent history of the known addresses, it picks the
+// outbound addresses by the scores from the distinct buckets
+type AddrBook struct {
+	mtx     sync.RWMutex
+	db      dbm.DB
+	table   asnTable
+	addrs   map[string]*KnownAddress
+	buckets map[string]map[string]*KnownAddress
+	closed  bool
+}
+
+// NewAddrBook loads the address book from the db, the addresses are bucketed
+// by the asn table when it's configured
+func NewAddrBook(config *cfg.Config) (*AddrBook, error) {
+	var table asnTable
+	if config.P2P.ASNTable != "" {
+		var err error
+		if table, err = loadASNTable(config.ASNTableFile()); err != nil {
+			return nil, err
+		}
+	}
+
+	return newAddrBook(dbm.NewDB("addrbook", config.DBBackend, config.DBDir()), table), nil
+}
+
+func newAddrBook(db dbm.DB, table asnTable) *AddrBook {
+	ab := &AddrBook{
+		db:      db,
+		table:   table,
+		addrs:   make(map[string]*KnownAddress),
+		buckets: make(map[string]map[string]*KnownAddress),
+	}
+	ab.loadAddresses()
+	return ab
+}
+
+func (ab *AddrBook) loadAddresses() {
+	iter := ab.db.IteratorPrefix(addressKeyPrefix)
+	defer iter.Release()
+
+	for iter.Next() {
+		ka := &KnownAddress{}
+		if err := json.Unmarshal(iter.Value(), ka); err != nil {
+			log.WithFields(log.Fields{"module": logModule, "key": string(iter.Key()), "err": err}).Warn("fail on load known address")
+			continue
+		}
+
+		// the bucket is refreshed since the asn table may be changed
+		ka.Bucket = bucketOf(ab.table, ka.IP)
+		ab.insert(ka)
+	}
+}
+
+func (ab *AddrBook) insert(ka *KnownAddress) {
+	addr := ka.String()
+	ab.addrs[addr] = ka
+	if ab.buckets[ka.Bucket] == nil {
+		ab.buckets[ka.Bucket] = make(map[string]*KnownAddress)
+	}
+	ab.buckets[ka.Bucket][addr] = ka
+}
+
+func (ab *AddrBook) remove(ka *KnownAddress) {
+	addr := ka.String()
+	delete(ab.addrs, addr)
+	delete(ab.buckets[ka.Bucket], addr)
+	if len(ab.buckets[ka.Bucket]) == 0 {
+		delete(ab.buckets, ka.Bucket)
+	}
+
+	if !ab.closed {
+		ab.db.Delete(calcAddressKey(addr))
+	}
+}
+
+func (ab *AddrBook) save(ka *KnownAddress) {
+	if ab.closed {
+		return
+	}
+
+	data, err := json.Marshal(ka)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "address": ka.String(), "err": err}).Error("fail on marshal known address")
+		return
+	}
+	ab.db.Set(calcAddressKey(ka.String()), data)
+}
+
+// worst returns the address of the lowest score
+func worst(addrs map[string]*KnownAddress, now time.Time) *KnownAddress {
+	var worstAddr *KnownAddress
+	for _, ka := range addrs {
+		if worstAddr == nil || ka.isBad(now) || ka.score(now) < worstAddr.score(now) {
+			worstAddr = ka
+			if ka.isBad(now) {
+				break
+			}
+		}
+	}
+	return worstAddr
+}
+
+// evictWorst evicts the worst address, it returns false when the new address
+// is worse than all the addresses
+func (ab *AddrBook) evictWorst(addrs map[string]*KnownAddress, now time.Time) bool {
+	worstAddr := worst(addrs, now)
+	if !worstAddr.isBad(now) && worstAddr.score(now) > (&KnownAddress{}).score(now) {
+		return false
+	}
+
+	ab.remove(worstAddr)
+	return true
+}
+
+// makeRoom makes room for the new address of the bucket, the per bucket limit
+// prevents the addresses of one network from flooding the book
+func (ab *AddrBook) makeRoom(bucket string, now time.Time) bool {
+	if len(ab.buckets[bucket]) >= maxAddressesPerBucket && !ab.evictWorst(ab.buckets[bucket], now) {
+		return false
+	}
+	return len(ab.addrs) < maxKnownAddresses || ab.evictWorst(ab.addrs, now)
+}
+
+// getOrAdd returns the known address, the unknown address is added to the
+// book when there is room
+func (ab *AddrBook) getOrAdd(ip net.IP, port uint16, now time.Time) *KnownAddress {
+	ka := newKnownAddress(ip, port, bucketOf(ab.table, ip), now)
+	if known, ok := ab.addrs[ka.String()]; ok {
+		return known
+	}
+
+	if !ab.makeRoom(ka.Bucket, now) {
+		return nil
+	}
+
+	ab.insert(ka)
+	return ka
+}
+
+// AddAddress adds the discovered address to the book
+func (ab *AddrBook) AddAddress(ip net.IP, port uint16) {
+	ab.mtx.Lock()
+	defer ab.mtx.Unlock()
+
+	if _, ok := ab.addrs[newKnownAddress(ip, port, "", time.Time{}).String()]; ok {
+		return
+	}
+
+	if ka := ab.getOrAdd(ip, port, time.Now()); ka != nil {
+		ab.save(ka)
+	}
+}
+
+// MarkAttempt records the dialing attempt of the address, it's counted as a
+// failure until the connection succeeds
+func (ab *AddrBook) MarkAttempt(ip net.IP, port uint16) {
+	ab.mtx.Lock()
+	defer ab.mtx.Unlock()
+
+	now := time.Now()
+	if ka := ab.getOrAdd(ip, port, now); ka != nil {
+		ka.Attempts++
+		ka.Failures++
+		ka.LastAttempt = now
+		ab.save(ka)
+	}
+}
+
+// MarkGood records the successful connection of the address
+func (ab *AddrBook) MarkGood(ip net.IP, port uint16) {
+	ab.mtx.Lock()
+	defer ab.mtx.Unlock()
+
+	now := time.Now()
+	if ka := ab.getOrAdd(ip, port, now); ka != nil {
+		ka.Successes++
+		ka.Failures = 0
+		ka.LastSuccess = now
+		ka.LastSeen = now
+		ab.save(ka)
+	}
+}
+
+// MarkSeen records the address is alive until now
+func (ab *AddrBook) MarkSeen(ip net.IP, port uint16) {
+	ab.mtx.Lock()
+	defer ab.mtx.Unlock()
+
+	if ka, ok := ab.addrs[newKnownAddress(ip, port, "", time.Time{}).String()]; ok {
+		ka.LastSeen = time.Now()
+		ab.save(ka)
+	}
+}
+
+// MarkBanned records the ban of all the addresses of the ip
+func (ab *AddrBook) MarkBanned(ip string) {
+	ab.mtx.Lock()
+	defer ab.mtx.Unlock()
+
+	now := time.Now()
+	for _, ka := range ab.addrs {
+		if ka.IP.String() == ip {
+			ka.Bans++
+			ka.LastBan = now
+			ab.save(ka)
+		}
+	}
+}
+
+// Bucket returns the bucket of the ip
+func (ab *AddrBook) Bucket(ip net.IP) string {
+	return bucketOf(ab.table, ip)
+}
+
+// pickWeighted picks the index randomly weighted by the scores
+func pickWeighted(scores []float64) int {
+	total := 0.0
+	for _, score := range scores {
+		total += score
+	}
+
+	r := rand.Float64() * total
+	for i, score := range scores {
+		if r -= score; r < 0 {
+			return i
+		}
+	}
+	return len(scores) - 1
+}
+
+// PickOutbound picks at most num dialable addresses from the distinct buckets
+// except the used ones, the addresses of the higher scores are more likely to
+// be picked. The rest addresses including the used buckets are picked when
+// there're not enough distinct buckets.
+func (ab *AddrBook) PickOutbound(num int, usedBuckets map[string]bool) []*KnownAddress {
+	ab.mtx.RLock()
+	defer ab.mtx.RUnlock()
+
+	now := time.Now()
+	candidates, scores := []*KnownAddress{}, []float64{}
+	rests, restScores := []*KnownAddress{}, []float64{}
+	for bucket, addrs := range ab.buckets {
+		bucketAddrs, bucketScores := []*KnownAddress{}, []float64{}
+		for _, ka := range addrs {
+			if ka.isDialable(now) && !ka.isBad(now) {
+				bucketAddrs = append(bucketAddrs, ka)
+				bucketScores = append(bucketScores, ka.score(now))
+			}
+		}
+
+		if len(bucketAddrs) == 0 {
+			continue
+		}
+
+		if usedBuckets[bucket] {
+			rests = append(rests, bucketAddrs...)
+			restScores = append(restScores, bucketScores...)
+			continue
+		}
+
+		i := pickWeighted(bucketScores)
+		candidates = append(candidates, bucketAddrs[i])
+		scores = append(scores, bucketScores[i])
+		rests = append(rests, append(bucketAddrs[:i:i], bucketAddrs[i+1:]...)...)
+		restScores = append(restScores, append(bucketScores[:i:i], bucketScores[i+1:]...)...)
+	}
+
+	picked := pickAddresses(num, candidates, scores)
+	return append(picked, pickAddresses(num-len(picked), rests, restScores)...)
+}
+
+// pickAddresses picks at most num copies of the addresses by the scores
+func pickAddresses(num int, candidates []*KnownAddress, scores []float64) []*KnownAddress {
+	picked := []*KnownAddress{}
+	for len(picked) < num && len(candidates) > 0 {
+		i := pickWeighted(scores)
+		ka := *candidates[i]
+		picked = append(picked, &ka)
+
+		candidates = append(candidates[:i], candidates[i+1:]...)
+		scores = append(scores[:i], scores[i+1:]...)
+	}
+	return picked
+}
+
+// Close closes the db of the address book, the later changes of the addresses
+// aren't persisted
+func (ab *AddrBook) Close() {
+	ab.mtx.Lock()
+	defer ab.mtx.Unlock()
+
+	if !ab.closed {
+		ab.closed = true
+		ab.db.Close()
+	}
+}
+
+// ListKnownAddresses returns the copies of the known addresses sorted by the
+// bucket and the address
+func (ab *AddrBook) ListKnownAddresses() []*KnownAddress {
+	ab.mtx.RLock()
+	defer ab.mtx.RUnlock()
+
+	addrs := make([]*KnownAddress, 0, len(ab.addrs))
+	for _, ka := range ab.addrs {
+		known := *ka
+		addrs = append(addrs, &known)
+	}
+
+	sort.Slice(addrs, func(i, j int) bool {
+		if addrs[i].Bucket != addrs[j].Bucket {
+			return addrs[i].Bucket < addrs[j].Bucket
+		}
+		return addrs[i].String() < addrs[j].String()
+	})
+	return addrs
+}
@@ -0,0 +1,180 @@
+package addrbook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	dbm "kuskcore/database/leveldb"
+)
+
+func TestPickOutbound(t *testing.T) {
+	ab := newAddrBook(dbm.NewMemDB(), nil)
+	for i := 0; i < 200; i++ {
+		ab.AddAddress(net.IPv4(1, 2, byte(i), 1), 46656)
+	}
+
+	for i := 0; i < 5; i++ {
+		ab.AddAddress(net.IPv4(10, byte(i), 0, 1), 46656)
+	}
+
+	if got := len(ab.buckets["1.2.0.0/16"]); got != maxAddressesPerBucket {
+		t.Fatalf("got %d addresses of the flooding bucket, want %d", got, maxAddressesPerBucket)
+	}
+
+	// the failed address is not dialable before the retry delay
+	ab.MarkAttempt(net.IPv4(10, 4, 0, 1), 46656)
+	usedBuckets := map[string]bool{"10.0.0.0/16": true}
+	for i := 0; i < 100; i++ {
+		picked := ab.PickOutbound(8, usedBuckets)
+		if len(picked) != 8 {
+			t.Fatalf("got %d picked addresses, want 8", len(picked))
+		}
+
+		// the distinct unused buckets are picked first, then the rest
+		// addresses fill the shortage
+		buckets, addrs := map[string]bool{}, map[string]bool{}
+		for j, ka := range picked {
+			if addrs[ka.String()] || ka.Bucket == "10.4.0.0/16" || j < 4 && (buckets[ka.Bucket] || usedBuckets[ka.Bucket]) {
+				t.Fatalf("got the picked address %s of the bucket %s", ka.String(), ka.Bucket)
+			}
+			buckets[ka.Bucket] = true
+			addrs[ka.String()] = true
+		}
+	}
+
+	if got := len(ab.PickOutbound(300, usedBuckets)); got != maxAddressesPerBucket+4 {
+		t.Fatalf("got %d picked addresses, want all %d dialable ones", got, maxAddressesPerBucket+4)
+	}
+}
+
+func TestScore(t *testing.T) {
+	now := time.Now()
+	good := &KnownAddress{Attempts: 3, Successes: 3, LastSuccess: now}
+	fresh := &KnownAddress{AddedAt: now}
+	failed := &KnownAddress{Attempts: 2, Failures: 2, LastAttempt: now}
+	banned := &KnownAddress{Attempts: 3, Successes: 3, Bans: 1, LastBan: now}
+
+	if !(good.score(now) > fresh.score(now) && fresh.score(now) > failed.score(now) && good.score(now) > banned.score(now)) {
+		t.Fatalf("got unexpected scores good %v, fresh %v, failed %v, banned %v", good.score(now), fresh.score(now), failed.score(now), banned.score(now))
+	}
+
+	if banned.score(now.Add(banPenaltyDuration)) != good.score(now) {
+		t.Fatalf("the ban penalty doesn't expire")
+	}
+
+	if failed.isDialable(now) || !failed.isDialable(now.Add(4*time.Minute)) {
+		t.Fatalf("got unexpected retry delay of the failed address")
+	}
+
+	bad := &KnownAddress{Attempts: maxFailures, Failures: maxFailures, AddedAt: now}
+	if bad.isBad(now) || !bad.isBad(now.Add(minBadDuration)) {
+		t.Fatalf("got unexpected bad address")
+	}
+}
+
+func TestAddrBookPersistence(t *testing.T) {
+	db := dbm.NewMemDB()
+	ab := newAddrBook(db, nil)
+	ab.AddAddress(net.IPv4(1, 1, 1, 1), 46656)
+	ab.MarkAttempt(net.IPv4(2, 2, 2, 2), 46656)
+	ab.MarkAttempt(net.IPv4(3, 3, 3, 3), 46656)
+	ab.MarkGood(net.IPv4(3, 3, 3, 3), 46656)
+	ab.MarkBanned("3.3.3.3")
+
+	want, err := json.Marshal(ab.ListKnownAddresses())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := json.Marshal(newAddrBook(db, nil).ListKnownAddresses())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got known addresses %s, want %s", got, want)
+	}
+
+	ka := ab.addrs["3.3.3.3:46656"]
+	if ka.Attempts != 1 || ka.Successes != 1 || ka.Failures != 0 || ka.Bans != 1 || ka.LastSeen.IsZero() {
+		t.Fatalf("got unexpected known address %v", ka)
+	}
+}
+
+func TestAddrBookClose(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ab := newAddrBook(dbm.NewDB("addrbook", dbm.GoLevelDBBackendStr, dir), nil)
+	ab.AddAddress(net.IPv4(1, 1, 1, 1), 46656)
+	ab.Close()
+	ab.Close()
+
+	// the changes after closed are kept in memory only
+	ab.MarkGood(net.IPv4(1, 1, 1, 1), 46656)
+	ab.AddAddress(net.IPv4(2, 2, 2, 2), 46656)
+	if got := len(ab.ListKnownAddresses()); got != 2 {
+		t.Fatalf("got %d known addresses, want 2", got)
+	}
+
+	db := dbm.NewDB("addrbook", dbm.GoLevelDBBackendStr, dir)
+	defer db.Close()
+
+	known := newAddrBook(db, nil).ListKnownAddresses()
+	if len(known) != 1 || known[0].String() != "1.1.1.1:46656" || known[0].Successes != 0 {
+		t.Fatalf("got unexpected persisted addresses %v", known)
+	}
+}
+
+func TestASNTable(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "asn.txt")
+	data := "# cidr asn\n1.0.0.0/8 100\n\n1.2.0.0/16 AS200\n2001:db8::/32 300\n"
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := loadASNTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "1.1.1.1", want: "AS100"},
+		{ip: "1.2.3.4", want: "AS200"},
+		{ip: "2001:db8::1", want: "AS300"},
+		{ip: "5.6.7.8", want: "5.6.0.0/16"},
+		{ip: "2001:db9:1::1", want: "2001:db9::/32"},
+	}
+
+	for _, c := range cases {
+		if got := bucketOf(table, net.ParseIP(c.ip)); got != c.want {
+			t.Errorf("ip %s: got bucket %s, want %s", c.ip, got, c.want)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte("1.0.0.0/8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadASNTable(path); err == nil {
+		t.Fatalf("load the invalid asn table")
+	}
+}
@@ -0,0 +1,94 @@
+package addrbook
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kuskcore/errors"
+)
+
+var errInvalidASNTable = errors.New("invalid asn table")
+
+type asnEntry struct {
+	network *net.IPNet
+	asn     uint32
+}
+
+// asnTable maps the ip networks to the autonomous system numbers, the longer
+// prefixes are matched first
+type asnTable []asnEntry
+
+// loadASNTable reads the table file of the "<cidr> <asn>" lines, the empty
+// lines and the lines start with '#' are ignored
+func loadASNTable(path string) (asnTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table := asnTable{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, errors.WithDetailf(errInvalidASNTable, "line %d", line)
+		}
+
+		_, network, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, errors.WithDetailf(errInvalidASNTable, "line %d: %v", line, err)
+		}
+
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(fields[1]), "AS"), 10, 32)
+		if err != nil {
+			return nil, errors.WithDetailf(errInvalidASNTable, "line %d: %v", line, err)
+		}
+
+		table = append(table, asnEntry{network: network, asn: uint32(asn)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(table, func(i, j int) bool {
+		iOnes, _ := table[i].network.Mask.Size()
+		jOnes, _ := table[j].network.Mask.Size()
+		return iOnes > jOnes
+	})
+	return table, nil
+}
+
+func (t asnTable) lookup(ip net.IP) (uint32, bool) {
+	for _, entry := range t {
+		if entry.network.Contains(ip) {
+			return entry.asn, true
+		}
+	}
+	return 0, false
+}
+
+// bucketOf groups the address by the autonomous system when the asn table
+// covers it, otherwise by the /16 of ipv4 or the /32 of ipv6, so the outbound
+// peers picked from distinct buckets are hard to be controlled by one attacker
+func bucketOf(table asnTable, ip net.IP) string {
+	if asn, ok := table.lookup(ip); ok {
+		return fmt.Sprintf("AS%d", asn)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(16, 32)).String() + "/16"
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String() + "/32"
+}
@@ -0,0 +1,77 @@
+package addrbook
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	maxRetryDelay      = time.Hour
+	banPenaltyDuration = 24 * time.Hour
+	maxFailures        = 10                 // Failures in a row to consider the address is bad
+	minBadDuration     = 7 * 24 * time.Hour // Duration without success to consider the address is bad
+)
+
+// KnownAddress is the connecting history of the address in the address book
+type KnownAddress struct {
+	IP          net.IP    `json:"ip"`
+	Port        uint16    `json:"port"`
+	Bucket      string    `json:"bucket"`
+	Attempts    uint32    `json:"attempts"`
+	Successes   uint32    `json:"successes"`
+	Failures    uint32    `json:"failures"` // failures in a row since the last success
+	Bans        uint32    `json:"bans"`
+	AddedAt     time.Time `json:"added_at"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success"`
+	LastSeen    time.Time `json:"last_seen"`
+	LastBan     time.Time `json:"last_ban"`
+}
+
+func newKnownAddress(ip net.IP, port uint16, bucket string, now time.Time) *KnownAddress {
+	return &KnownAddress{IP: ip, Port: port, Bucket: bucket, AddedAt: now}
+}
+
+// String returns the dialing address of the host:port format
+func (ka *KnownAddress) String() string {
+	return net.JoinHostPort(ka.IP.String(), strconv.FormatUint(uint64(ka.Port), 10))
+}
+
+// isBad checks whether the address keeps failing for a long time
+func (ka *KnownAddress) isBad(now time.Time) bool {
+	if ka.Failures < maxFailures {
+		return false
+	}
+
+	lastGood := ka.LastSuccess
+	if lastGood.IsZero() {
+		lastGood = ka.AddedAt
+	}
+	return now.Sub(lastGood) >= minBadDuration
+}
+
+// isDialable checks whether the retry delay of the failed address passed, the
+// delay doubles on each failure
+func (ka *KnownAddress) isDialable(now time.Time) bool {
+	if ka.Failures == 0 {
+		return true
+	}
+
+	delay := maxRetryDelay
+	if ka.Failures < 7 {
+		delay = time.Duration(1<<ka.Failures) * time.Minute
+	}
+	return now.Sub(ka.LastAttempt) >= delay
+}
+
+// score rates the address by the connecting history, the successes raise the
+// score while the failures and the recent bans lower it
+func (ka *KnownAddress) score(now time.Time) float64 {
+	score := (1 + math.Log2(1+float64(ka.Successes))) / float64(1+ka.Failures)
+	if ka.Bans > 0 && now.Sub(ka.LastBan) < banPenaltyDuration {
+		score /= float64(1 + ka.Bans)
+	}
+	return score
+}
@@ -0,0 +1,109 @@
+package connection
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tendermint/tmlibs/flowrate"
+)
+
+const (
+	yieldInterval      = 5 * time.Millisecond
+	maxYieldDuration   = 200 * time.Millisecond
+	strictStallTimeout = time.Second // the strict priority messages without progress are ignored
+	saturatedPercent   = 90          // the send rate percent of the saturated link
+)
+
+// Bandwidth is the traffic limit shared by all the connections, the strict
+// priority channels of any connection preempt the other channels of all the
+// connections sharing the bandwidth when the limit is saturated.
+type Bandwidth struct {
+	sendRate    int64
+	recvRate    int64
+	sendMonitor *flowrate.Monitor
+	recvMonitor *flowrate.Monitor
+
+	mtx   sync.RWMutex
+	conns map[*MConnection]struct{}
+}
+
+// NewBandwidth creates the bandwidth of the global send and receive rates in
+// bytes per second, zero rate means unlimited
+func NewBandwidth(sendRate, recvRate int64) *Bandwidth {
+	return &Bandwidth{
+		sendRate:    sendRate,
+		recvRate:    recvRate,
+		sendMonitor: flowrate.New(0, 0),
+		recvMonitor: flowrate.New(0, 0),
+		conns:       make(map[*MConnection]struct{}),
+	}
+}
+
+func (b *Bandwidth) addConn(c *MConnection) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.conns[c] = struct{}{}
+}
+
+func (b *Bandwidth) removeConn(c *MConnection) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	delete(b.conns, c)
+}
+
+// hasStrictSendPending checks whether any other connection has the strict
+// priority messages waiting to be sent, the stalled connections are ignored
+func (b *Bandwidth) hasStrictSendPending(except *MConnection) bool {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	now := time.Now()
+	for c := range b.conns {
+		if c != except && c.hasStrictSendProgressing(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSendSaturated checks whether the global send rate is limited and the
+// traffic is close to it
+func (b *Bandwidth) isSendSaturated() bool {
+	if b.sendRate <= 0 {
+		return false
+	}
+
+	if !b.canSend() {
+		return true
+	}
+	return b.sendMonitor.Status().CurRate*100 >= b.sendRate*saturatedPercent
+}
+
+// canSend checks whether a packet can be sent without waiting for the global
+// send rate
+func (b *Bandwidth) canSend() bool {
+	return b.sendMonitor.Limit(maxMsgPacketTotalSize, b.sendRate, false) >= maxMsgPacketTotalSize
+}
+
+// yieldSend blocks the low priority sending of the connection while the
+// global send rate is saturated and the other connections have the strict
+// priority messages pending, the wait is bounded so the low priority traffic
+// never starves.
+func (b *Bandwidth) yieldSend(c *MConnection) {
+	for deadline := time.Now().Add(maxYieldDuration); time.Now().Before(deadline); {
+		if !b.isSendSaturated() || !b.hasStrictSendPending(c) {
+			return
+		}
+		time.Sleep(yieldInterval)
+	}
+}
+
+func (b *Bandwidth) limitSend() {
+	b.sendMonitor.Limit(maxMsgPacketTotalSize, b.sendRate, true)
+}
+
+func (b *Bandwidth) limitRecv() {
+	b.recvMonitor.Limit(maxMsgPacketTotalSize, b.recvRate, true)
+}
@@ -0,0 +1,137 @@
+package connection
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// transferBytes sends the messages by the connection pairs for the duration,
+// it returns the bytes received by all the pairs
+func transferBytes(t *testing.T, sendConfig, recvConfig *MConnConfig, duration time.Duration) int64 {
+	chDescs := []*ChannelDescriptor{{ID: 0x01, Priority: 1, SendQueueCapacity: 100}}
+	msg := make([]byte, 1024)
+	received := int64(0)
+	onReceive := func(chID byte, msgBytes []byte) {
+		atomic.AddInt64(&received, int64(len(msgBytes)))
+	}
+
+	quit := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		server, client := net.Pipe()
+		sender := NewMConnectionWithConfig(client, chDescs, nil, nil, sendConfig)
+		receiver := NewMConnectionWithConfig(server, chDescs, onReceive, nil, recvConfig)
+		if err := sender.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := receiver.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		defer sender.Stop()
+		defer receiver.Stop()
+
+		go func() {
+			for {
+				select {
+				case <-quit:
+					return
+				default:
+				}
+
+				if !sender.TrySend(0x01, msg) {
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}()
+	}
+
+	time.Sleep(duration)
+	close(quit)
+	return atomic.LoadInt64(&received)
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	rate := int64(20000)
+	duration := time.Second
+	cases := []struct {
+		desc       string
+		sendConfig *MConnConfig
+		recvConfig *MConnConfig
+	}{
+		{
+			desc:       "global send rate",
+			sendConfig: &MConnConfig{SendRate: 1 << 20, RecvRate: 1 << 20, Bandwidth: NewBandwidth(rate, 0)},
+			recvConfig: &MConnConfig{SendRate: 1 << 20, RecvRate: 1 << 20, Bandwidth: NewBandwidth(0, 0)},
+		},
+		{
+			desc:       "global recv rate",
+			sendConfig: &MConnConfig{SendRate: 1 << 20, RecvRate: 1 << 20, Bandwidth: NewBandwidth(0, 0)},
+			recvConfig: &MConnConfig{SendRate: 1 << 20, RecvRate: 1 << 20, Bandwidth: NewBandwidth(0, rate)},
+		},
+	}
+
+	// each connection is allowed the whole rate, the shared bandwidth caps
+	// the total traffic of the connections
+	for _, c := range cases {
+		got := transferBytes(t, c.sendConfig, c.recvConfig, duration)
+		min, max := rate/4, 2*rate*int64(duration/time.Second)
+		if got < min || got > max {
+			t.Errorf("%s: got %d bytes transferred in %v, want between %d and %d", c.desc, got, duration, min, max)
+		}
+	}
+}
+
+func TestBandwidthYieldSend(t *testing.T) {
+	chDescs := []*ChannelDescriptor{
+		{ID: 0x01, Priority: 10, SendQueueCapacity: 10},
+		{ID: 0x02, Priority: 1, SendQueueCapacity: 10, StrictPriority: true},
+	}
+
+	cases := []struct {
+		desc      string
+		sendRate  int64
+		saturated bool
+		strict    bool
+		stalled   bool
+		wantYield bool
+	}{
+		{desc: "unlimited send rate", sendRate: 0, strict: true},
+		{desc: "unsaturated send rate", sendRate: 1 << 20, strict: true},
+		{desc: "no strict priority messages", sendRate: 1, saturated: true},
+		{desc: "stalled strict priority messages", sendRate: 1, saturated: true, strict: true, stalled: true},
+		{desc: "saturated send rate", sendRate: 1, saturated: true, strict: true, wantYield: true},
+	}
+
+	for _, c := range cases {
+		config := &MConnConfig{SendRate: defaultSendRate, RecvRate: defaultRecvRate, Bandwidth: NewBandwidth(c.sendRate, 0)}
+		mconn := NewMConnectionWithConfig(nil, chDescs, nil, nil, config)
+		other := NewMConnectionWithConfig(nil, chDescs, nil, nil, config)
+		config.Bandwidth.addConn(mconn)
+		config.Bandwidth.addConn(other)
+		if c.saturated {
+			config.Bandwidth.sendMonitor.Update(1 << 20)
+		}
+
+		if c.strict && !other.channelsIdx[0x02].trySendBytes([]byte("vote")) {
+			t.Fatalf("%s: fail on queue the strict priority message", c.desc)
+		}
+
+		if c.stalled {
+			atomic.StoreInt64(&other.channelsIdx[0x02].strictProgress, time.Now().Add(-strictStallTimeout).UnixNano())
+		}
+
+		start := time.Now()
+		config.Bandwidth.yieldSend(mconn)
+		elapsed := time.Since(start)
+		if c.wantYield && (elapsed < maxYieldDuration || elapsed > 2*maxYieldDuration) {
+			t.Errorf("%s: got yield %v, want bounded by %v", c.desc, elapsed, maxYieldDuration)
+		}
+
+		if !c.wantYield && elapsed >= maxYieldDuration/2 {
+			t.Errorf("%s: got yield %v, want none", c.desc, elapsed)
+		}
+	}
+}
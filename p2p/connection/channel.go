@@ -9,10 +9,12 @@ import (
 	cmn "github.com/tendermint/tmlibs/common"
 )
 
-// ChannelDescriptor is the setting of channel
+// ChannelDescriptor is the setting of channel, the pending messages of the
+// strict priority channels are always sent before the other channels
 type ChannelDescriptor struct {
 	ID                  byte
 	Priority            int
+	StrictPriority      bool
 	SendQueueCapacity   int
 	RecvBufferCapacity  int
 	RecvMessageCapacity int
@@ -41,6 +43,10 @@ type channel struct {
 	sending       []byte
 	priority      int
 	recentlySent  int64 // exponential moving average
+	sentBytes     int64 // atomic.
+	recvBytes     int64 // atomic.
+
+	strictProgress int64 // atomic, unix nano of the last progress of the strict priority messages
 }
 
 func newChannel(conn *MConnection, desc *ChannelDescriptor) *channel {
@@ -58,6 +64,28 @@ func newChannel(conn *MConnection, desc *ChannelDescriptor) *channel {
 	}
 }
 
+// Returns true if the messages of the strict priority channel are queued or
+// being sent.
+// Goroutine-safe
+func (ch *channel) isStrictSendPending() bool {
+	return ch.desc.StrictPriority && ch.loadSendQueueSize() > 0
+}
+
+// Returns true if the messages of the strict priority channel are pending and
+// they're queued or sent recently, the stalled ones are ignored.
+// Goroutine-safe
+func (ch *channel) isStrictSendProgressing(now time.Time) bool {
+	progress := time.Unix(0, atomic.LoadInt64(&ch.strictProgress))
+	return ch.isStrictSendPending() && now.Sub(progress) < strictStallTimeout
+}
+
+// Goroutine-safe
+func (ch *channel) markStrictProgress() {
+	if ch.desc.StrictPriority {
+		atomic.StoreInt64(&ch.strictProgress, time.Now().UnixNano())
+	}
+}
+
 // Goroutine-safe
 // Use only as a heuristic.
 func (ch *channel) canSend() bool {
@@ -122,7 +150,9 @@ func (ch *channel) recvMsgPacket(packet msgPacket) ([]byte, error) {
 func (ch *channel) sendBytes(bytes []byte) bool {
 	select {
 	case ch.sendQueue <- bytes:
-		atomic.AddInt32(&ch.sendQueueSize, 1)
+		if atomic.AddInt32(&ch.sendQueueSize, 1) == 1 {
+			ch.markStrictProgress()
+		}
 		return true
 	case <-time.After(defaultSendTimeout):
 		return false
@@ -135,7 +165,9 @@ func (ch *channel) sendBytes(bytes []byte) bool {
 func (ch *channel) trySendBytes(bytes []byte) bool {
 	select {
 	case ch.sendQueue <- bytes:
-		atomic.AddInt32(&ch.sendQueueSize, 1)
+		if atomic.AddInt32(&ch.sendQueueSize, 1) == 1 {
+			ch.markStrictProgress()
+		}
 		return true
 	default:
 		return false
@@ -150,10 +182,23 @@ func (ch *channel) writeMsgPacketTo(w io.Writer) (n int, err error) {
 	wire.WriteBinary(packet, w, &n, &err)
 	if err == nil {
 		ch.recentlySent += int64(n)
+		atomic.AddInt64(&ch.sentBytes, int64(n))
+		ch.markStrictProgress()
 	}
 	return
 }
 
+// status returns the traffic of the channel
+// Goroutine-safe
+func (ch *channel) status() ChannelStatus {
+	return ChannelStatus{
+		ID:            ch.id,
+		SendQueueSize: ch.loadSendQueueSize(),
+		SentBytes:     atomic.LoadInt64(&ch.sentBytes),
+		RecvBytes:     atomic.LoadInt64(&ch.recvBytes),
+	}
+}
+
 // Call this periodically to update stats for throttling purposes.
 // Not goroutine-safe
 func (ch *channel) updateStats() {
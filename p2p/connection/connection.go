@@ -90,6 +90,8 @@ type MConnection struct {
 	onError     errorCbFunc
 	errored     uint32
 	config      *MConnConfig
+	pingSent    int64 // atomic, unix nano of the last ping
+	pingRTT     int64 // atomic, nanoseconds of the last ping round trip
 
 	quit         chan struct{}
 	flushTimer   *cmn.ThrottleTimer // flush writes as necessary but throttled.
@@ -97,20 +99,37 @@ type MConnection struct {
 	chStatsTimer *time.Ticker       // update channel stats periodically
 }
 
-// MConnConfig is a MConnection configuration.
+// MConnConfig is a MConnection configuration, the bandwidth is shared by all
+// the connections of the config.
 type MConnConfig struct {
-	SendRate int64 `mapstructure:"send_rate"`
-	RecvRate int64 `mapstructure:"recv_rate"`
+	SendRate  int64      `mapstructure:"send_rate"`
+	RecvRate  int64      `mapstructure:"recv_rate"`
+	Bandwidth *Bandwidth `mapstructure:"-"`
 }
 
 // DefaultMConnConfig returns the default config.
 func DefaultMConnConfig() *MConnConfig {
 	return &MConnConfig{
-		SendRate: defaultSendRate,
-		RecvRate: defaultRecvRate,
+		SendRate:  defaultSendRate,
+		RecvRate:  defaultRecvRate,
+		Bandwidth: NewBandwidth(0, 0),
 	}
 }
 
+// ChannelStatus is the traffic of the channel
+type ChannelStatus struct {
+	ID            byte  `json:"id"`
+	SendQueueSize int   `json:"send_queue_size"`
+	SentBytes     int64 `json:"sent_bytes"`
+	RecvBytes     int64 `json:"received_bytes"`
+}
+
+// ConnectionStatus is the ping round trip and the traffic of the channels
+type ConnectionStatus struct {
+	PingRTT  time.Duration
+	Channels []ChannelStatus
+}
+
 // NewMConnectionWithConfig wraps net.Conn and creates multiplex connection with a config
 func NewMConnectionWithConfig(conn net.Conn, chDescs []*ChannelDescriptor, onReceive receiveCbFunc, onError errorCbFunc, config *MConnConfig) *MConnection {
 	mconn := &MConnection{
@@ -146,6 +165,9 @@ func (c *MConnection) OnStart() error {
 	c.BaseService.OnStart()
 	c.quit = make(chan struct{})
 	c.flushTimer = cmn.NewThrottleTimer("flush", flushThrottle)
+	if c.config.Bandwidth != nil {
+		c.config.Bandwidth.addConn(c)
+	}
 	go c.sendRoutine()
 	go c.recvRoutine()
 	return nil
@@ -154,6 +176,9 @@ func (c *MConnection) OnStart() error {
 // OnStop implements BaseService
 func (c *MConnection) OnStop() {
 	c.BaseService.OnStop()
+	if c.config.Bandwidth != nil {
+		c.config.Bandwidth.removeConn(c)
+	}
 	c.flushTimer.Stop()
 	c.pingTimer.Stop()
 	c.chStatsTimer.Stop()
@@ -202,6 +227,15 @@ func (c *MConnection) Send(chID byte, msg interface{}) bool {
 	return true
 }
 
+// Status returns the ping round trip and the traffic of the channels
+func (c *MConnection) Status() *ConnectionStatus {
+	status := &ConnectionStatus{PingRTT: time.Duration(atomic.LoadInt64(&c.pingRTT))}
+	for _, channel := range c.channels {
+		status.Channels = append(status.Channels, channel.status())
+	}
+	return status
+}
+
 // TrafficStatus return the in and out traffic status
 func (c *MConnection) TrafficStatus() (*flowrate.Status, *flowrate.Status) {
 	sentStatus := c.sendMonitor.Status()
@@ -257,14 +291,17 @@ func (c *MConnection) recvRoutine() {
 	defer close(c.pong)
 
 	for {
-		// Block until .recvMonitor says we can read.
+		// Block until .recvMonitor and the shared bandwidth say we can read.
 		c.recvMonitor.Limit(maxMsgPacketTotalSize, atomic.LoadInt64(&c.config.RecvRate), true)
+		if c.config.Bandwidth != nil {
+			c.config.Bandwidth.limitRecv()
+		}
 
 		// Read packet type
 		var n int
 		var err error
 		pktType := wire.ReadByte(c.bufReader, &n, &err)
-		c.recvMonitor.Update(int(n))
+		c.updateRecv(int(n))
 		if err != nil {
 			if c.IsRunning() {
 				log.WithFields(log.Fields{"module": logModule, "conn": c, "error": err}).Warn("Connection failed @ recvRoutine (reading byte)")
@@ -285,11 +322,14 @@ func (c *MConnection) recvRoutine() {
 
 		case packetTypePong:
 			log.WithFields(log.Fields{"module": logModule, "conn": c}).Debug("receive Pong")
+			if pingSent := atomic.LoadInt64(&c.pingSent); pingSent > 0 {
+				atomic.StoreInt64(&c.pingRTT, time.Now().UnixNano()-pingSent)
+			}
 
 		case packetTypeMsg:
 			pkt, n, err := msgPacket{}, int(0), error(nil)
 			wire.ReadBinaryPtr(&pkt, c.bufReader, maxMsgPacketTotalSize, &n, &err)
-			c.recvMonitor.Update(int(n))
+			c.updateRecv(int(n))
 			if err != nil {
 				if c.IsRunning() {
 					log.WithFields(log.Fields{"module": logModule, "conn": c, "error": err}).Error("failed on recvRoutine")
@@ -302,6 +342,7 @@ func (c *MConnection) recvRoutine() {
 			if !ok || channel == nil {
 				cmn.PanicQ(cmn.Fmt("Unknown channel %X", pkt.ChannelID))
 			}
+			atomic.AddInt64(&channel.recvBytes, int64(n))
 
 			msgBytes, err := channel.recvMsgPacket(pkt)
 			if err != nil {
@@ -322,19 +363,73 @@ func (c *MConnection) recvRoutine() {
 	}
 }
 
-// Returns true if messages from channels were exhausted.
-func (c *MConnection) sendMsgPacket() bool {
+func (c *MConnection) updateSent(n int) {
+	c.sendMonitor.Update(n)
+	if c.config.Bandwidth != nil {
+		c.config.Bandwidth.sendMonitor.Update(n)
+	}
+}
+
+func (c *MConnection) updateRecv(n int) {
+	c.recvMonitor.Update(n)
+	if c.config.Bandwidth != nil {
+		c.config.Bandwidth.recvMonitor.Update(n)
+	}
+}
+
+// hasStrictSendPending checks whether any strict priority channel has the
+// messages to send.
+// Goroutine-safe
+func (c *MConnection) hasStrictSendPending() bool {
+	for _, channel := range c.channels {
+		if channel.isStrictSendPending() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasStrictSendProgressing checks whether any strict priority channel has the
+// messages to send and makes progress.
+// Goroutine-safe
+func (c *MConnection) hasStrictSendProgressing(now time.Time) bool {
+	for _, channel := range c.channels {
+		if channel.isStrictSendProgressing(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// pickChannel returns the pending channel of the least recently sent ratio,
+// the strict priority channels are picked first.
+func (c *MConnection) pickChannel() *channel {
 	var leastRatio float32 = math.MaxFloat32
 	var leastChannel *channel
 	for _, channel := range c.channels {
 		if !channel.isSendPending() {
 			continue
 		}
+
+		strict := channel.desc.StrictPriority
+		if leastChannel != nil && leastChannel.desc.StrictPriority != strict {
+			if !strict {
+				continue
+			}
+			leastRatio = math.MaxFloat32
+		}
+
 		if ratio := float32(channel.recentlySent) / float32(channel.priority); ratio < leastRatio {
 			leastRatio = ratio
 			leastChannel = channel
 		}
 	}
+	return leastChannel
+}
+
+// Returns true if messages from channels were exhausted.
+func (c *MConnection) sendMsgPacket() bool {
+	leastChannel := c.pickChannel()
 	if leastChannel == nil {
 		return true
 	}
@@ -345,7 +440,7 @@ func (c *MConnection) sendMsgPacket() bool {
 		c.stopForError(err)
 		return true
 	}
-	c.sendMonitor.Update(int(n))
+	c.updateSent(int(n))
 	c.flushTimer.Set()
 	return false
 }
@@ -366,13 +461,14 @@ func (c *MConnection) sendRoutine() {
 			}
 		case <-c.pingTimer.C:
 			log.WithFields(log.Fields{"module": logModule, "conn": c}).Debug("send Ping")
+			atomic.StoreInt64(&c.pingSent, time.Now().UnixNano())
 			wire.WriteByte(packetTypePing, c.bufWriter, &n, &err)
-			c.sendMonitor.Update(int(n))
+			c.updateSent(int(n))
 			c.flush()
 		case <-c.pong:
 			log.WithFields(log.Fields{"module": logModule, "conn": c}).Debug("send Pong")
 			wire.WriteByte(packetTypePong, c.bufWriter, &n, &err)
-			c.sendMonitor.Update(int(n))
+			c.updateSent(int(n))
 			c.flush()
 		case <-c.quit:
 			return
@@ -398,11 +494,32 @@ func (c *MConnection) sendRoutine() {
 
 // Returns true if messages from channels were exhausted.
 func (c *MConnection) sendSomeMsgPackets() bool {
+	// The buffered packets are flushed before blocking on the limits, else
+	// the flush timer may keep firing while we're blocked.
+	if c.bufWriter.Buffered() > 0 {
+		c.flush()
+	}
+
 	// Block until .sendMonitor says we can write.
 	// Once we're ready we send more than we asked for,
 	// but amortized it should even out.
 	c.sendMonitor.Limit(maxMsgPacketTotalSize, atomic.LoadInt64(&c.config.SendRate), true)
+
+	// the low priority traffic backs off while the strict priority messages
+	// of the other connections need the saturated link
+	bandwidth := c.config.Bandwidth
+	if bandwidth != nil && !c.hasStrictSendPending() {
+		bandwidth.yieldSend(c)
+	}
+
 	for i := 0; i < numBatchMsgPackets; i++ {
+		// the shared bandwidth is limited by packet, the overshoot of the
+		// batches isn't evened out among the connections
+		if bandwidth != nil && !bandwidth.canSend() {
+			c.flush()
+			bandwidth.limitSend()
+		}
+
 		if c.sendMsgPacket() {
 			return true
 		}
@@ -117,3 +117,38 @@ func TestMConnectionStopsAndReturnsError(t *testing.T) {
 		t.Fatal("Did not receive error in 500ms")
 	}
 }
+
+func TestMConnectionStrictPriority(t *testing.T) {
+	assert := assert.New(t)
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	config := DefaultMConnConfig()
+	chDescs := []*ChannelDescriptor{
+		{ID: 0x01, Priority: 10, SendQueueCapacity: 10},
+		{ID: 0x02, Priority: 1, SendQueueCapacity: 10, StrictPriority: true},
+	}
+	mconn := NewMConnectionWithConfig(client, chDescs, nil, nil, config)
+	other := NewMConnectionWithConfig(server, chDescs, nil, nil, config)
+	config.Bandwidth.addConn(mconn)
+	config.Bandwidth.addConn(other)
+
+	assert.True(mconn.channelsIdx[0x01].trySendBytes([]byte("block")))
+	assert.Equal(byte(0x01), mconn.pickChannel().id)
+	assert.False(config.Bandwidth.hasStrictSendPending(other))
+
+	assert.True(mconn.channelsIdx[0x02].trySendBytes([]byte("vote")))
+	assert.Equal(byte(0x02), mconn.pickChannel().id)
+	assert.True(config.Bandwidth.hasStrictSendPending(other))
+	assert.False(config.Bandwidth.hasStrictSendPending(mconn))
+
+	mconn.channelsIdx[0x02].nextMsgPacket()
+	assert.Equal(byte(0x01), mconn.pickChannel().id)
+	assert.False(config.Bandwidth.hasStrictSendPending(other))
+
+	config.Bandwidth.removeConn(mconn)
+	assert.True(mconn.channelsIdx[0x02].trySendBytes([]byte("vote")))
+	assert.False(config.Bandwidth.hasStrictSendPending(other))
+}
@@ -12,7 +12,32 @@ import (
 	"kuskcore/version"
 )
 
-const maxNodeInfoSize = 10240 // 10Kb
+const (
+	maxNodeInfoSize = 10240 // 10Kb
+
+	// ProtocolVersion is the peer protocol version of the node
+	ProtocolVersion = uint32(2)
+	// MinProtocolVersion is the default lowest peer protocol version to
+	// connect, the nodes before the protocol versioning are the version 1. It's
+	// raised by the p2p.min_protocol_version config.
+	MinProtocolVersion    = uint32(1)
+	legacyProtocolVersion = uint32(1)
+
+	// CompactBlockVersion is the lowest peer protocol version relaying the
+	// proposed blocks by the compact blocks
+	CompactBlockVersion = uint32(2)
+	// TxInventoryVersion is the lowest peer protocol version relaying the
+	// transactions by the announcements
+	TxInventoryVersion = uint32(2)
+)
+
+// the fields of the other application specific data, the service flags stay
+// the first field for the nodes before the protocol versioning
+const (
+	otherServicesField = iota
+	otherAliasField
+	otherProtocolField
+)
 
 // NodeInfo peer node info
 type NodeInfo struct {
@@ -23,22 +48,40 @@ type NodeInfo struct {
 	ListenAddr string `json:"listen_addr"`
 	Version    string `json:"version"` // major.minor.revision
 	// other application specific data
-	// field 0: node service flags. field 1: node alias.
+	// field 0: node service flags. field 1: node alias. field 2: protocol version.
 	Other []string `json:"other"`
+
+	minProtocolVersion uint32 // lowest peer protocol version to connect, it's not sent to the peers
 }
 
 func NewNodeInfo(config *cfg.Config, pubkey ed25519.PublicKey, listenAddr string) *NodeInfo {
-	other := []string{strconv.FormatUint(uint64(consensus.DefaultServices), 10)}
-	if config.NodeAlias != "" {
-		other = append(other, config.NodeAlias)
+	services := consensus.DefaultServices
+	if config.LightMode {
+		services = consensus.LightServices
+	}
+
+	other := make([]string, otherProtocolField+1)
+	other[otherServicesField] = strconv.FormatUint(uint64(services), 10)
+	other[otherAliasField] = config.NodeAlias
+	other[otherProtocolField] = strconv.FormatUint(uint64(ProtocolVersion), 10)
+
+	// the peers of our own protocol version are always allowed
+	minProtocolVersion := MinProtocolVersion
+	if version := config.P2P.MinProtocolVersion; version > minProtocolVersion {
+		minProtocolVersion = version
 	}
+	if minProtocolVersion > ProtocolVersion {
+		minProtocolVersion = ProtocolVersion
+	}
+
 	return &NodeInfo{
-		PubKey:     pubkey,
-		Moniker:    config.Moniker,
-		Network:    config.ChainID,
-		ListenAddr: listenAddr,
-		Version:    version.Version,
-		Other:      other,
+		PubKey:             pubkey,
+		Moniker:            config.Moniker,
+		Network:            config.ChainID,
+		ListenAddr:         listenAddr,
+		Version:            version.Version,
+		Other:              other,
+		minProtocolVersion: minProtocolVersion,
 	}
 }
 
@@ -56,9 +99,47 @@ func (info *NodeInfo) CompatibleWith(other *NodeInfo) error {
 	if info.Network != other.Network {
 		return fmt.Errorf("Peer is on a different network. Peer network: %v, node network: %v", other.Network, info.Network)
 	}
+
+	minProtocolVersion := info.minProtocolVersion
+	if minProtocolVersion < MinProtocolVersion {
+		minProtocolVersion = MinProtocolVersion
+	}
+
+	if protocolVersion := other.ProtocolVersion(); protocolVersion < minProtocolVersion {
+		return fmt.Errorf("Peer protocol version is too old. Peer protocol version: %v, min protocol version: %v", protocolVersion, minProtocolVersion)
+	}
 	return nil
 }
 
+// Services returns the service flags of the node, the node not declaring the
+// services is regarded as the full node
+func (info *NodeInfo) Services() consensus.ServiceFlag {
+	if len(info.Other) <= otherServicesField {
+		return consensus.SFFullNode
+	}
+
+	services, err := strconv.ParseUint(info.Other[otherServicesField], 10, 64)
+	if err != nil {
+		return consensus.SFFullNode
+	}
+	return consensus.ServiceFlag(services)
+}
+
+// ProtocolVersion returns the peer protocol version of the node, the nodes
+// before the protocol versioning are the version 1. The malformed version is
+// the version 0 which is never compatible.
+func (info *NodeInfo) ProtocolVersion() uint32 {
+	if len(info.Other) <= otherProtocolField {
+		return legacyProtocolVersion
+	}
+
+	protocolVersion, err := strconv.ParseUint(info.Other[otherProtocolField], 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(protocolVersion)
+}
+
 func (info NodeInfo) DoFilter(ip string, pubKey string) error {
 	if hex.EncodeToString(info.PubKey) == pubKey {
 		return ErrConnectSelf
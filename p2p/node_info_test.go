@@ -0,0 +1,79 @@
+//go:build !network
+// +build !network
+
+package p2p
+
+import (
+	"strconv"
+	"testing"
+
+	cfg "kuskcore/config"
+	"kuskcore/consensus"
+	"kuskcore/version"
+)
+
+func TestNodeInfoServices(t *testing.T) {
+	config := cfg.DefaultConfig()
+	info := NewNodeInfo(config, make([]byte, 32), "127.0.0.1:46656")
+	if info.Services() != consensus.DefaultServices || info.ProtocolVersion() != ProtocolVersion {
+		t.Fatalf("got services %v and protocol version %d", info.Services(), info.ProtocolVersion())
+	}
+
+	config.LightMode = true
+	config.NodeAlias = "light"
+	info = NewNodeInfo(config, make([]byte, 32), "127.0.0.1:46656")
+	if info.Services() != consensus.LightServices || info.ProtocolVersion() != ProtocolVersion {
+		t.Fatalf("got services %v and protocol version %d of the light node", info.Services(), info.ProtocolVersion())
+	}
+
+	cases := []struct {
+		other           []string
+		services        consensus.ServiceFlag
+		protocolVersion uint32
+	}{
+		{other: nil, services: consensus.SFFullNode, protocolVersion: legacyProtocolVersion},
+		{other: []string{"7"}, services: consensus.SFFullNode | consensus.SFFastSync | consensus.SFSPV, protocolVersion: legacyProtocolVersion},
+		{other: []string{"0", "alias"}, services: consensus.LightServices, protocolVersion: legacyProtocolVersion},
+		{other: []string{"1", "", "3"}, services: consensus.SFFullNode, protocolVersion: 3},
+		{other: []string{"x", "", "x"}, services: consensus.SFFullNode, protocolVersion: 0},
+	}
+
+	for i, c := range cases {
+		info := &NodeInfo{Other: c.other}
+		if info.Services() != c.services || info.ProtocolVersion() != c.protocolVersion {
+			t.Errorf("case %d: got services %v and protocol version %d, want %v and %d", i, info.Services(), info.ProtocolVersion(), c.services, c.protocolVersion)
+		}
+	}
+}
+
+func TestCompatibleWithProtocolVersion(t *testing.T) {
+	info := NewNodeInfo(cfg.DefaultConfig(), make([]byte, 32), "127.0.0.1:46656")
+	legacy := &NodeInfo{Network: info.Network, Version: version.Version, Other: []string{"7"}}
+	if err := info.CompatibleWith(legacy); err != nil {
+		t.Fatalf("the node before the protocol versioning is incompatible: %v", err)
+	}
+
+	tooOld := &NodeInfo{Network: info.Network, Version: version.Version, Other: []string{"7", "", "0"}}
+	if err := info.CompatibleWith(tooOld); err == nil {
+		t.Fatal("the node of the too old protocol version is compatible")
+	}
+
+	malformed := &NodeInfo{Network: info.Network, Version: version.Version, Other: []string{"7", "", "v2"}}
+	if err := info.CompatibleWith(malformed); err == nil {
+		t.Fatal("the node of the malformed protocol version is compatible")
+	}
+
+	// the legacy nodes are rejected by the raised minimum, the minimum never
+	// exceeds our own protocol version
+	config := cfg.DefaultConfig()
+	config.P2P.MinProtocolVersion = ProtocolVersion + 1
+	info = NewNodeInfo(config, make([]byte, 32), "127.0.0.1:46656")
+	if err := info.CompatibleWith(legacy); err == nil {
+		t.Fatal("the legacy node is compatible with the raised min protocol version")
+	}
+
+	current := &NodeInfo{Network: info.Network, Version: version.Version, Other: []string{"7", "", strconv.FormatUint(uint64(ProtocolVersion), 10)}}
+	if err := info.CompatibleWith(current); err != nil {
+		t.Fatalf("the node of our protocol version is incompatible: %v", err)
+	}
+}
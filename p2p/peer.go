@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net"
 	"reflect"
-	"strconv"
 	"time"
 
 	"github.com/btcsuite/go-socks/socks"
@@ -47,16 +46,32 @@ func DefaultPeerConfig(config *cfg.P2PConfig) *PeerConfig {
 		ProxyAddress:     config.ProxyAddress,
 		ProxyUsername:    config.ProxyUsername,
 		ProxyPassword:    config.ProxyPassword,
-		MConfig:          connection.DefaultMConnConfig(),
+		MConfig: &connection.MConnConfig{
+			SendRate:  config.SendRate,
+			RecvRate:  config.RecvRate,
+			Bandwidth: connection.NewBandwidth(config.MaxSendRate, config.MaxRecvRate),
+		},
 	}
 }
 
+// Connection is the multiplex connection carrying the channel messages of the
+// peer, MConnection is the implementation used over the network
+type Connection interface {
+	Start() error
+	Stop() error
+	CanSend(chID byte) bool
+	Send(chID byte, msg interface{}) bool
+	TrySend(chID byte, msg interface{}) bool
+	Status() *connection.ConnectionStatus
+	TrafficStatus() (*flowrate.Status, *flowrate.Status)
+}
+
 // Peer represent a kusk network node
 type Peer struct {
 	cmn.BaseService
 	*NodeInfo
 	*peerConn
-	mconn *connection.MConnection // multiplex connection
+	mconn Connection // multiplex connection
 	Key   string
 	isLAN bool
 }
@@ -104,8 +119,8 @@ func newOutboundPeerConn(addr *NetAddress, ourNodePrivKey chainkd.XPrv, config *
 	return pc, nil
 }
 
-func newInboundPeerConn(conn net.Conn, ourNodePrivKey chainkd.XPrv, config *cfg.P2PConfig) (*peerConn, error) {
-	return newPeerConn(conn, false, ourNodePrivKey, DefaultPeerConfig(config))
+func newInboundPeerConn(conn net.Conn, ourNodePrivKey chainkd.XPrv, config *PeerConfig) (*peerConn, error) {
+	return newPeerConn(conn, false, ourNodePrivKey, config)
 }
 
 func newPeerConn(rawConn net.Conn, outbound bool, ourNodePrivKey chainkd.XPrv, config *PeerConfig) (*peerConn, error) {
@@ -223,15 +238,16 @@ func (p *Peer) Send(chID byte, msg interface{}) bool {
 
 // ServiceFlag return the ServiceFlag of this peer
 func (p *Peer) ServiceFlag() consensus.ServiceFlag {
-	services := consensus.SFFullNode
-	if len(p.Other) == 0 {
-		return services
-	}
+	return p.Services()
+}
 
-	if serviceFlag, err := strconv.ParseUint(p.Other[0], 10, 64); err == nil {
-		services = consensus.ServiceFlag(serviceFlag)
+// ProtocolVersion returns the peer protocol version negotiated with the peer,
+// it's the lower one of the two nodes
+func (p *Peer) ProtocolVersion() uint32 {
+	if version := p.NodeInfo.ProtocolVersion(); version < ProtocolVersion {
+		return version
 	}
-	return services
+	return ProtocolVersion
 }
 
 // String representation.
@@ -247,6 +263,11 @@ func (p *Peer) TrafficStatus() (*flowrate.Status, *flowrate.Status) {
 	return p.mconn.TrafficStatus()
 }
 
+// ConnectionStatus return the ping round trip and the traffic of the channels
+func (p *Peer) ConnectionStatus() *connection.ConnectionStatus {
+	return p.mconn.Status()
+}
+
 // TrySend msg to the channel identified by chID byte. Immediately returns
 // false if the send queue is full.
 func (p *Peer) TrySend(chID byte, msg interface{}) bool {
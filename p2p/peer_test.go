@@ -126,7 +126,7 @@ func (rp *remotePeer) accept(l net.Listener) {
 			fmt.Println("Failed to accept conn:", err)
 		}
 
-		pc, err := newInboundPeerConn(conn, rp.PrivKey, rp.Config.P2P)
+		pc, err := newInboundPeerConn(conn, rp.PrivKey, DefaultPeerConfig(rp.Config.P2P))
 		if err != nil {
 			fmt.Println("Failed to create a peer:", err)
 		}
@@ -3,6 +3,7 @@ package security
 import (
 	"encoding/json"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,10 +18,19 @@ const (
 
 var (
 	ErrConnectBannedPeer = errors.New("connect banned peer")
+	errPeerNotBanned     = errors.New("peer is not banned")
 )
 
+// BanEntry is the ban of the peer ip or node pubkey
+type BanEntry struct {
+	Target string    `json:"target"`
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
 type Blacklist struct {
-	peers map[string]time.Time
+	peers map[string]*BanEntry
 	db    dbm.DB
 
 	mtx sync.Mutex
@@ -28,24 +38,65 @@ type Blacklist struct {
 
 func NewBlacklist(config *cfg.Config) *Blacklist {
 	return &Blacklist{
-		peers: make(map[string]time.Time),
+		peers: make(map[string]*BanEntry),
 		db:    dbm.NewDB("blacklist", config.DBBackend, config.DBDir()),
 	}
 }
 
-// AddPeer add peer to blacklist
-func (bl *Blacklist) AddPeer(ip string) error {
+// Ban add the peer ip or node pubkey to blacklist for the duration
+func (bl *Blacklist) Ban(target string, duration time.Duration, reason string) error {
 	bl.mtx.Lock()
 	defer bl.mtx.Unlock()
 
 	// delete expired banned peers
-	for peer, banEnd := range bl.peers {
-		if time.Now().Before(banEnd) {
+	now := time.Now()
+	for peer, entry := range bl.peers {
+		if !now.Before(entry.Until) {
 			delete(bl.peers, peer)
 		}
 	}
+
+	if duration <= 0 {
+		duration = defaultBanDuration
+	}
+
 	// add banned peer
-	bl.peers[ip] = time.Now().Add(defaultBanDuration)
+	bl.peers[target] = &BanEntry{Target: target, Reason: reason, Since: now, Until: now.Add(duration)}
+	return bl.save()
+}
+
+// Unban remove the peer ip or node pubkey from blacklist
+func (bl *Blacklist) Unban(target string) error {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	if _, ok := bl.peers[target]; !ok {
+		return errPeerNotBanned
+	}
+	return bl.delPeer(target)
+}
+
+// ListBans return the current bans sorted by the end time
+func (bl *Blacklist) ListBans() []*BanEntry {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	now := time.Now()
+	entries := []*BanEntry{}
+	for _, entry := range bl.peers {
+		if now.Before(entry.Until) {
+			banEntry := *entry
+			entries = append(entries, &banEntry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Until.Before(entries[j].Until)
+	})
+	return entries
+}
+
+func (bl *Blacklist) save() error {
 	dataJSON, err := json.Marshal(bl.peers)
 	if err != nil {
 		return err
@@ -55,31 +106,41 @@ func (bl *Blacklist) AddPeer(ip string) error {
 	return nil
 }
 
-func (bl *Blacklist) delPeer(ip string) error {
-	delete(bl.peers, ip)
-	dataJson, err := json.Marshal(bl.peers)
-	if err != nil {
-		return err
+func (bl *Blacklist) delPeer(target string) error {
+	delete(bl.peers, target)
+	return bl.save()
+}
+
+func (bl *Blacklist) isBanned(target string) (bool, error) {
+	entry, ok := bl.peers[target]
+	if !ok {
+		return false, nil
 	}
 
-	bl.db.Set([]byte(blacklistKey), dataJson)
-	return nil
+	if time.Now().Before(entry.Until) {
+		return true, nil
+	}
+	return false, bl.delPeer(target)
 }
 
 func (bl *Blacklist) DoFilter(ip string, pubKey string) error {
 	bl.mtx.Lock()
 	defer bl.mtx.Unlock()
 
-	if banEnd, ok := bl.peers[ip]; ok {
-		if time.Now().Before(banEnd) {
-			return ErrConnectBannedPeer
+	for _, target := range []string{ip, pubKey} {
+		if target == "" {
+			continue
 		}
 
-		if err := bl.delPeer(ip); err != nil {
+		banned, err := bl.isBanned(target)
+		if err != nil {
 			return err
 		}
-	}
 
+		if banned {
+			return ErrConnectBannedPeer
+		}
+	}
 	return nil
 }
 
@@ -88,11 +149,24 @@ func (bl *Blacklist) LoadPeers() error {
 	bl.mtx.Lock()
 	defer bl.mtx.Unlock()
 
-	if dataJSON := bl.db.Get([]byte(blacklistKey)); dataJSON != nil {
-		if err := json.Unmarshal(dataJSON, &bl.peers); err != nil {
-			return err
-		}
+	dataJSON := bl.db.Get([]byte(blacklistKey))
+	if dataJSON == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(dataJSON, &bl.peers); err == nil {
+		return nil
 	}
 
+	// the blacklist saved by the early version only has the ban end time
+	banEnds := make(map[string]time.Time)
+	if err := json.Unmarshal(dataJSON, &banEnds); err != nil {
+		return err
+	}
+
+	bl.peers = make(map[string]*BanEntry)
+	for ip, banEnd := range banEnds {
+		bl.peers[ip] = &BanEntry{Target: ip, Since: banEnd.Add(-defaultBanDuration), Until: banEnd}
+	}
 	return nil
 }
@@ -36,6 +36,17 @@ func (ps *PeersBanScore) DelPeer(ip string) {
 	delete(ps.peers, ip)
 }
 
+// Score returns the current ban score of the peer
+func (ps *PeersBanScore) Score(ip string) uint32 {
+	ps.mtx.Lock()
+	defer ps.mtx.Unlock()
+
+	if banScore, ok := ps.peers[ip]; ok {
+		return banScore.Int()
+	}
+	return 0
+}
+
 func (ps *PeersBanScore) Increase(ip string, level byte, reason string) bool {
 	ps.mtx.Lock()
 	defer ps.mtx.Unlock()
@@ -1,6 +1,12 @@
 package security
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"net"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	cfg "kuskcore/config"
@@ -8,9 +14,12 @@ import (
 
 const logModule = "p2pSecurity"
 
+var errInvalidPeerTarget = errors.New("invalid peer ip or node pubkey")
+
 type Security struct {
 	filter        *PeerFilter
 	blacklist     *Blacklist
+	whitelist     *Whitelist
 	peersBanScore *PeersBanScore
 }
 
@@ -18,10 +27,24 @@ func NewSecurity(config *cfg.Config) *Security {
 	return &Security{
 		filter:        NewPeerFilter(),
 		blacklist:     NewBlacklist(config),
+		whitelist:     NewWhitelist(config),
 		peersBanScore: NewPeersScore(),
 	}
 }
 
+// normalizeTarget returns the canonical form of the peer ip or the hex node
+// pubkey, so the same peer always maps to the same ban or whitelist entry
+func normalizeTarget(target string) (string, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		return ip.String(), nil
+	}
+
+	if pubKey, err := hex.DecodeString(target); err == nil && len(pubKey) == ed25519.PublicKeySize {
+		return hex.EncodeToString(pubKey), nil
+	}
+	return "", errInvalidPeerTarget
+}
+
 func (s *Security) DoFilter(ip string, pubKey string) error {
 	return s.filter.doFilter(ip, pubKey)
 }
@@ -31,7 +54,7 @@ func (s *Security) IsBanned(ip string, level byte, reason string) bool {
 		return false
 	}
 
-	if err := s.blacklist.AddPeer(ip); err != nil {
+	if err := s.blacklist.Ban(ip, defaultBanDuration, reason); err != nil {
 		log.WithFields(log.Fields{"module": logModule, "err": err}).Error("fail on add ban peer")
 	}
 	//clear peer score
@@ -39,6 +62,67 @@ func (s *Security) IsBanned(ip string, level byte, reason string) bool {
 	return true
 }
 
+// BanScore returns the current ban score of the peer ip
+func (s *Security) BanScore(ip string) uint32 {
+	return s.peersBanScore.Score(ip)
+}
+
+// Ban bans the peer ip or node pubkey for the duration
+func (s *Security) Ban(target string, duration time.Duration, reason string) (string, error) {
+	target, err := normalizeTarget(target)
+	if err != nil {
+		return "", err
+	}
+	return target, s.blacklist.Ban(target, duration, reason)
+}
+
+// Unban lifts the ban of the peer ip or node pubkey and clears the ban score
+func (s *Security) Unban(target string) error {
+	target, err := normalizeTarget(target)
+	if err != nil {
+		return err
+	}
+
+	s.peersBanScore.DelPeer(target)
+	return s.blacklist.Unban(target)
+}
+
+// ListBans returns the current bans
+func (s *Security) ListBans() []*BanEntry {
+	return s.blacklist.ListBans()
+}
+
+// AddWhitelist exempts the peer ip or node pubkey from the ban scoring and the
+// connection limits
+func (s *Security) AddWhitelist(target string) (string, error) {
+	target, err := normalizeTarget(target)
+	if err != nil {
+		return "", err
+	}
+
+	s.peersBanScore.DelPeer(target)
+	return target, s.whitelist.AddPeer(target)
+}
+
+// RemoveWhitelist removes the peer ip or node pubkey from the whitelist
+func (s *Security) RemoveWhitelist(target string) error {
+	target, err := normalizeTarget(target)
+	if err != nil {
+		return err
+	}
+	return s.whitelist.DelPeer(target)
+}
+
+// IsWhitelisted checks whether the peer ip or node pubkey is whitelisted
+func (s *Security) IsWhitelisted(ip string, pubKey string) bool {
+	return s.whitelist.Has(ip, pubKey)
+}
+
+// ListWhitelist returns the whitelisted peer ips and node pubkeys
+func (s *Security) ListWhitelist() []string {
+	return s.whitelist.ListPeers()
+}
+
 func (s *Security) RegisterFilter(filter Filter) {
 	s.filter.register(filter)
 }
@@ -48,6 +132,10 @@ func (s *Security) Start() error {
 		return err
 	}
 
+	if err := s.whitelist.LoadPeers(); err != nil {
+		return err
+	}
+
 	s.filter.register(s.blacklist)
 	return nil
 }
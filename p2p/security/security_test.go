@@ -0,0 +1,131 @@
+package security
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	cfg "kuskcore/config"
+)
+
+func newTestSecurity(t *testing.T) (*Security, *cfg.Config, func()) {
+	dir, err := ioutil.TempDir(".", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := cfg.DefaultConfig().SetRoot(dir)
+	sec := NewSecurity(config)
+	if err := sec.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return sec, config, func() { os.RemoveAll(dir) }
+}
+
+func TestBanAndUnban(t *testing.T) {
+	sec, _, cleanup := newTestSecurity(t)
+	defer cleanup()
+
+	pubKey := strings.Repeat("ab", 32)
+	if _, err := sec.Ban("1.2.3.4", time.Minute, "spam"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sec.Ban(strings.ToUpper(pubKey), 0, "invalid block"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sec.Ban("1.2.3", time.Minute, ""); err != errInvalidPeerTarget {
+		t.Fatalf("got error %v, want %v", err, errInvalidPeerTarget)
+	}
+
+	if err := sec.DoFilter("1.2.3.4", ""); err != ErrConnectBannedPeer {
+		t.Fatalf("got error %v of the banned ip, want %v", err, ErrConnectBannedPeer)
+	}
+
+	if err := sec.DoFilter("5.6.7.8", pubKey); err != ErrConnectBannedPeer {
+		t.Fatalf("got error %v of the banned pubkey, want %v", err, ErrConnectBannedPeer)
+	}
+
+	bans := sec.ListBans()
+	if len(bans) != 2 || bans[0].Target != "1.2.3.4" || bans[0].Reason != "spam" || bans[1].Target != pubKey || bans[1].Until.Sub(bans[1].Since) != defaultBanDuration {
+		t.Fatalf("got unexpected bans %v", bans)
+	}
+
+	if err := sec.Unban("1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sec.Unban("1.2.3.4"); err != errPeerNotBanned {
+		t.Fatalf("got error %v, want %v", err, errPeerNotBanned)
+	}
+
+	if err := sec.DoFilter("1.2.3.4", ""); err != nil {
+		t.Fatalf("got error %v of the unbanned ip", err)
+	}
+}
+
+func TestWhitelist(t *testing.T) {
+	sec, config, cleanup := newTestSecurity(t)
+	defer cleanup()
+
+	pubKey := strings.Repeat("cd", 32)
+	for _, target := range []string{"1.2.3.4", pubKey} {
+		if _, err := sec.AddWhitelist(target); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !sec.IsWhitelisted("1.2.3.4", "") || !sec.IsWhitelisted("5.6.7.8", pubKey) || sec.IsWhitelisted("5.6.7.8", "") {
+		t.Fatalf("got unexpected whitelist %v", sec.ListWhitelist())
+	}
+
+	if err := sec.RemoveWhitelist("1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sec.RemoveWhitelist("1.2.3.4"); err != errPeerNotWhitelisted {
+		t.Fatalf("got error %v, want %v", err, errPeerNotWhitelisted)
+	}
+
+	sec.whitelist.db.Close()
+	whitelist := NewWhitelist(config)
+	defer whitelist.db.Close()
+
+	if err := whitelist.LoadPeers(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := whitelist.ListPeers(); len(got) != 1 || got[0] != pubKey {
+		t.Fatalf("got whitelist %v, want [%s]", got, pubKey)
+	}
+}
+
+func TestLoadLegacyBlacklist(t *testing.T) {
+	sec, config, cleanup := newTestSecurity(t)
+	defer cleanup()
+
+	banEnd := time.Now().Add(time.Minute).Round(time.Second)
+	dataJSON, err := json.Marshal(map[string]time.Time{"1.2.3.4": banEnd, "5.6.7.8": time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sec.blacklist.db.Set([]byte(blacklistKey), dataJSON)
+	sec.blacklist.db.Close()
+
+	blacklist := NewBlacklist(config)
+	defer blacklist.db.Close()
+
+	if err := blacklist.LoadPeers(); err != nil {
+		t.Fatal(err)
+	}
+
+	bans := blacklist.ListBans()
+	if len(bans) != 1 || bans[0].Target != "1.2.3.4" || !bans[0].Until.Equal(banEnd) {
+		t.Fatalf("got unexpected bans %v", bans)
+	}
+}
@@ -0,0 +1,109 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	cfg "kuskcore/config"
+	dbm "kuskcore/database/leveldb"
+)
+
+const whitelistKey = "WhitelistPeers"
+
+var errPeerNotWhitelisted = errors.New("peer is not whitelisted")
+
+// Whitelist is the peer ips and node pubkeys exempt from the ban scoring and
+// the connection limits
+type Whitelist struct {
+	peers map[string]bool
+	db    dbm.DB
+
+	mtx sync.RWMutex
+}
+
+func NewWhitelist(config *cfg.Config) *Whitelist {
+	return &Whitelist{
+		peers: make(map[string]bool),
+		db:    dbm.NewDB("whitelist", config.DBBackend, config.DBDir()),
+	}
+}
+
+// AddPeer add the peer ip or node pubkey to whitelist
+func (wl *Whitelist) AddPeer(target string) error {
+	wl.mtx.Lock()
+	defer wl.mtx.Unlock()
+
+	wl.peers[target] = true
+	return wl.save()
+}
+
+// DelPeer remove the peer ip or node pubkey from whitelist
+func (wl *Whitelist) DelPeer(target string) error {
+	wl.mtx.Lock()
+	defer wl.mtx.Unlock()
+
+	if !wl.peers[target] {
+		return errPeerNotWhitelisted
+	}
+
+	delete(wl.peers, target)
+	return wl.save()
+}
+
+// Has check whether the peer ip or node pubkey is whitelisted
+func (wl *Whitelist) Has(ip string, pubKey string) bool {
+	wl.mtx.RLock()
+	defer wl.mtx.RUnlock()
+
+	return wl.peers[ip] || (pubKey != "" && wl.peers[pubKey])
+}
+
+// ListPeers return the whitelisted peer ips and node pubkeys
+func (wl *Whitelist) ListPeers() []string {
+	wl.mtx.RLock()
+	defer wl.mtx.RUnlock()
+
+	return wl.sortedPeers()
+}
+
+func (wl *Whitelist) sortedPeers() []string {
+	peers := make([]string, 0, len(wl.peers))
+	for peer := range wl.peers {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+func (wl *Whitelist) save() error {
+	dataJSON, err := json.Marshal(wl.sortedPeers())
+	if err != nil {
+		return err
+	}
+
+	wl.db.Set([]byte(whitelistKey), dataJSON)
+	return nil
+}
+
+// LoadPeers load whitelisted peers from db
+func (wl *Whitelist) LoadPeers() error {
+	wl.mtx.Lock()
+	defer wl.mtx.Unlock()
+
+	dataJSON := wl.db.Get([]byte(whitelistKey))
+	if dataJSON == nil {
+		return nil
+	}
+
+	peers := []string{}
+	if err := json.Unmarshal(dataJSON, &peers); err != nil {
+		return err
+	}
+
+	for _, peer := range peers {
+		wl.peers[peer] = true
+	}
+	return nil
+}
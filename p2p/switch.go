@@ -15,6 +15,7 @@ import (
 	"kuskcore/crypto/ed25519/chainkd"
 	"kuskcore/errors"
 	"kuskcore/event"
+	"kuskcore/p2p/addrbook"
 	"kuskcore/p2p/connection"
 	"kuskcore/p2p/discover/dht"
 	"kuskcore/p2p/discover/mdns"
@@ -28,6 +29,7 @@ const (
 
 	minNumOutboundPeers = 4
 	maxNumLANPeers      = 15
+	numDiscoverNodes    = 32 // Number of random nodes read from the discover to fill the address book
 )
 
 // pre-define errors for connecting fail
@@ -35,6 +37,7 @@ var (
 	ErrDuplicatePeer  = errors.New("Duplicate peer")
 	ErrConnectSelf    = errors.New("Connect self")
 	ErrConnectSpvPeer = errors.New("Outbound connect spv peer")
+	ErrTooManyPeers   = errors.New("Too many peers")
 )
 
 type discv interface {
@@ -49,6 +52,14 @@ type lanDiscv interface {
 type Security interface {
 	DoFilter(ip string, pubKey string) error
 	IsBanned(ip string, level byte, reason string) bool
+	BanScore(ip string) uint32
+	Ban(target string, duration time.Duration, reason string) (string, error)
+	Unban(target string) error
+	ListBans() []*security.BanEntry
+	AddWhitelist(target string) (string, error)
+	RemoveWhitelist(target string) error
+	IsWhitelisted(ip string, pubKey string) bool
+	ListWhitelist() []string
 	RegisterFilter(filter security.Filter)
 	Start() error
 }
@@ -73,6 +84,7 @@ type Switch struct {
 	discv        discv
 	lanDiscv     lanDiscv
 	security     Security
+	addrBook     *addrbook.AddrBook
 }
 
 // NewSwitch create a new Switch and set discover.
@@ -101,6 +113,11 @@ func NewSwitch(config *cfg.Config) (*Switch, error) {
 
 // newSwitch creates a new Switch with the given config.
 func newSwitch(config *cfg.Config, discv discv, lanDiscv lanDiscv, l Listener, priv chainkd.XPrv, listenAddr string) (*Switch, error) {
+	addrBook, err := addrbook.NewAddrBook(config)
+	if err != nil {
+		return nil, err
+	}
+
 	sw := &Switch{
 		Config:       config,
 		peerConfig:   DefaultPeerConfig(config.P2P),
@@ -114,6 +131,7 @@ func newSwitch(config *cfg.Config, discv discv, lanDiscv lanDiscv, l Listener, p
 		lanDiscv:     lanDiscv,
 		nodeInfo:     NewNodeInfo(config, priv.XPub().PublicKey(), listenAddr),
 		security:     security.NewSecurity(config),
+		addrBook:     addrBook,
 	}
 
 	sw.AddListener(l)
@@ -163,6 +181,7 @@ func (sw *Switch) OnStop() {
 	for _, reactor := range sw.reactors {
 		reactor.Stop()
 	}
+	sw.addrBook.Close()
 }
 
 // AddPeer performs the P2P handshake with a peer
@@ -188,7 +207,7 @@ func (sw *Switch) AddPeer(pc *peerConn, isLAN bool) error {
 		return err
 	}
 
-	if pc.outbound && !peer.ServiceFlag().IsEnable(consensus.SFFullNode) {
+	if pc.outbound && !peer.ServiceFlag().IsAnyEnable(consensus.RelayServices) {
 		return ErrConnectSpvPeer
 	}
 
@@ -235,6 +254,10 @@ func (sw *Switch) DialPeerWithAddress(addr *NetAddress) error {
 		return err
 	}
 
+	if !addr.isLAN {
+		sw.addrBook.MarkAttempt(addr.IP, addr.Port)
+	}
+
 	pc, err := newOutboundPeerConn(addr, sw.nodePrivKey, sw.peerConfig)
 	if err != nil {
 		log.WithFields(log.Fields{"module": logModule, "address": addr, " err": err}).Warn("DialPeer fail on newOutboundPeerConn")
@@ -246,12 +269,90 @@ func (sw *Switch) DialPeerWithAddress(addr *NetAddress) error {
 		pc.CloseConn()
 		return err
 	}
+
+	if !addr.isLAN {
+		sw.addrBook.MarkGood(addr.IP, addr.Port)
+	}
 	log.WithFields(log.Fields{"module": logModule, "address": addr, "peer num": sw.peers.Size()}).Debug("DialPeer added peer")
 	return nil
 }
 
+// IsBanned increases the ban score of the peer ip, the whitelisted peers are
+// exempt from the ban scoring
 func (sw *Switch) IsBanned(ip string, level byte, reason string) bool {
-	return sw.security.IsBanned(ip, level, reason)
+	if sw.isWhitelisted(ip) || !sw.security.IsBanned(ip, level, reason) {
+		return false
+	}
+
+	sw.addrBook.MarkBanned(ip)
+	return true
+}
+
+// BanScore returns the current ban score of the peer ip
+func (sw *Switch) BanScore(ip string) uint32 {
+	return sw.security.BanScore(ip)
+}
+
+// BanPeer bans the peer ip or node pubkey for the duration, the connected
+// peers of the target are disconnected
+func (sw *Switch) BanPeer(target string, duration time.Duration, reason string) error {
+	target, err := sw.security.Ban(target, duration, reason)
+	if err != nil {
+		return err
+	}
+
+	if net.ParseIP(target) != nil {
+		sw.addrBook.MarkBanned(target)
+	}
+
+	for _, peer := range sw.peers.List() {
+		if peer.RemoteAddrHost() == target || hex.EncodeToString(peer.PubKey()) == target {
+			sw.stopAndRemovePeer(peer, reason)
+		}
+	}
+	return nil
+}
+
+// UnbanPeer lifts the ban of the peer ip or node pubkey
+func (sw *Switch) UnbanPeer(target string) error {
+	return sw.security.Unban(target)
+}
+
+// ListBannedPeers returns the current bans
+func (sw *Switch) ListBannedPeers() []*security.BanEntry {
+	return sw.security.ListBans()
+}
+
+// AddWhitelistPeer exempts the peer ip or node pubkey from the ban scoring and
+// the inbound connection limit
+func (sw *Switch) AddWhitelistPeer(target string) error {
+	_, err := sw.security.AddWhitelist(target)
+	return err
+}
+
+// RemoveWhitelistPeer removes the peer ip or node pubkey from the whitelist
+func (sw *Switch) RemoveWhitelistPeer(target string) error {
+	return sw.security.RemoveWhitelist(target)
+}
+
+// ListWhitelistPeers returns the whitelisted peer ips and node pubkeys
+func (sw *Switch) ListWhitelistPeers() []string {
+	return sw.security.ListWhitelist()
+}
+
+// isWhitelisted checks whether the ip or the node pubkey of the connected peer
+// of the ip is whitelisted
+func (sw *Switch) isWhitelisted(ip string) bool {
+	if sw.security.IsWhitelisted(ip, "") {
+		return true
+	}
+
+	for _, peer := range sw.peers.List() {
+		if peer.RemoteAddrHost() == ip && sw.security.IsWhitelisted(ip, hex.EncodeToString(peer.PubKey())) {
+			return true
+		}
+	}
+	return false
 }
 
 // IsDialing prevent duplicate dialing
@@ -288,6 +389,11 @@ func (sw *Switch) NumPeers() (lan, outbound, inbound, dialing int) {
 	return
 }
 
+// ListKnownAddresses returns the addresses of the address book
+func (sw *Switch) ListKnownAddresses() []*addrbook.KnownAddress {
+	return sw.addrBook.ListKnownAddresses()
+}
+
 // NodeInfo returns the switch's NodeInfo.
 // NOTE: Not goroutine safe.
 func (sw *Switch) NodeInfo() *NodeInfo {
@@ -312,8 +418,17 @@ func (sw *Switch) StopPeerGracefully(peerID string) {
 	}
 }
 
+// checkInboundLimit checks the inbound peer against MaxNumPeers, the
+// whitelisted peer ips and node pubkeys are exempted
+func (sw *Switch) checkInboundLimit(ip string, pubKey string) error {
+	if sw.peers.Size() >= sw.Config.P2P.MaxNumPeers && !sw.security.IsWhitelisted(ip, pubKey) {
+		return ErrTooManyPeers
+	}
+	return nil
+}
+
 func (sw *Switch) addPeerWithConnection(conn net.Conn) error {
-	peerConn, err := newInboundPeerConn(conn, sw.nodePrivKey, sw.Config.P2P)
+	peerConn, err := newInboundPeerConn(conn, sw.nodePrivKey, sw.peerConfig)
 	if err != nil {
 		if err := conn.Close(); err != nil {
 			log.WithFields(log.Fields{"module": logModule, "remote peer:": conn.RemoteAddr().String(), " err:": err}).Warn("closes connection err")
@@ -321,7 +436,15 @@ func (sw *Switch) addPeerWithConnection(conn net.Conn) error {
 		return err
 	}
 
-	if err = sw.AddPeer(peerConn, false); err != nil {
+	// the limit is checked once the node pubkey is known by the handshake of
+	// the secret connection
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	pubKey := hex.EncodeToString(peerConn.conn.(*connection.SecretConnection).RemotePubKey())
+	if err = sw.checkInboundLimit(host, pubKey); err == nil {
+		err = sw.AddPeer(peerConn, false)
+	}
+
+	if err != nil {
 		if err := conn.Close(); err != nil {
 			log.WithFields(log.Fields{"module": logModule, "remote peer:": conn.RemoteAddr().String(), " err:": err}).Warn("closes connection err")
 		}
@@ -383,17 +506,15 @@ func (sw *Switch) listenerRoutine(l Listener) {
 			break
 		}
 
-		// disconnect if we alrady have MaxNumPeers
-		if sw.peers.Size() >= sw.Config.P2P.MaxNumPeers {
-			if err := inConn.Close(); err != nil {
-				log.WithFields(log.Fields{"module": logModule, "remote peer:": inConn.RemoteAddr().String(), " err:": err}).Warn("closes connection err")
-			}
+		// New inbound connection! it's disconnected if we already have
+		// MaxNumPeers, except the whitelisted peers
+		err := sw.addPeerWithConnection(inConn)
+		if err == ErrTooManyPeers {
 			log.Info("Ignoring inbound connection: already have enough peers.")
 			continue
 		}
 
-		// New inbound connection!
-		if err := sw.addPeerWithConnection(inConn); err != nil {
+		if err != nil {
 			log.Info("Ignoring inbound connection: error while adding peer.", " address:", inConn.RemoteAddr().String(), " error:", err)
 			continue
 		}
@@ -446,6 +567,8 @@ func (sw *Switch) ensureKeepConnectPeers() {
 	sw.dialPeers(addresses)
 }
 
+// ensureOutboundPeers fills the address book by the discovered nodes and dials
+// the addresses picked from the buckets unused by the outbound peers
 func (sw *Switch) ensureOutboundPeers() {
 	lanPeers, numOutPeers, _, numDialing := sw.NumPeers()
 	numToDial := minNumOutboundPeers - (numOutPeers + numDialing)
@@ -454,12 +577,28 @@ func (sw *Switch) ensureOutboundPeers() {
 		return
 	}
 
-	nodes := make([]*dht.Node, numToDial)
+	nodes := make([]*dht.Node, numDiscoverNodes)
 	n := sw.discv.ReadRandomNodes(nodes)
-	addresses := make([]*NetAddress, 0)
 	for i := 0; i < n; i++ {
-		address := NewNetAddressIPPort(nodes[i].IP, nodes[i].TCP)
-		addresses = append(addresses, address)
+		sw.addrBook.AddAddress(nodes[i].IP, nodes[i].TCP)
+	}
+
+	usedBuckets := make(map[string]bool)
+	for _, peer := range sw.peers.List() {
+		if peer.outbound && !peer.isLAN {
+			usedBuckets[sw.addrBook.Bucket(net.ParseIP(peer.RemoteAddrHost()))] = true
+		}
+	}
+
+	for _, value := range sw.dialing.Values() {
+		if address, ok := value.(*NetAddress); ok && !address.isLAN {
+			usedBuckets[sw.addrBook.Bucket(address.IP)] = true
+		}
+	}
+
+	addresses := make([]*NetAddress, 0)
+	for _, knownAddress := range sw.addrBook.PickOutbound(numToDial, usedBuckets) {
+		addresses = append(addresses, NewNetAddressIPPort(knownAddress.IP, knownAddress.Port))
 	}
 	sw.dialPeers(addresses)
 }
@@ -502,6 +641,9 @@ func (sw *Switch) stopAndRemovePeer(peer *Peer, reason interface{}) {
 		reactor.RemovePeer(peer, reason)
 	}
 	peer.Stop()
+	if tcpAddr, ok := peer.Addr().(*net.TCPAddr); ok && peer.outbound && !peer.isLAN {
+		sw.addrBook.MarkSeen(tcpAddr.IP, uint16(tcpAddr.Port))
+	}
 
 	sentStatus, receivedStatus := peer.TrafficStatus()
 	log.WithFields(log.Fields{
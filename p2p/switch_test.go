@@ -380,3 +380,44 @@ func TestStopPeer(t *testing.T) {
 		t.Fatal("TestStopPeer peer size error,want 0, got:", spew.Sdump(s1.peers.lookup))
 	}
 }
+
+func TestCheckInboundLimit(t *testing.T) {
+	dirPath, err := ioutil.TempDir(".", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	config := cfg.DefaultConfig().SetRoot(dirPath)
+	config.P2P.MaxNumPeers = 0
+	sec := security.NewSecurity(config)
+	if err := sec.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	sw := &Switch{Config: config, peers: NewPeerSet(), security: sec}
+	pubKey := "ab00000000000000000000000000000000000000000000000000000000000000"
+	if _, err := sec.AddWhitelist("1.1.1.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sec.AddWhitelist(pubKey); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ip     string
+		pubKey string
+		want   error
+	}{
+		{ip: "1.1.1.1", pubKey: "cd00000000000000000000000000000000000000000000000000000000000000", want: nil},
+		{ip: "2.2.2.2", pubKey: pubKey, want: nil},
+		{ip: "2.2.2.2", pubKey: "cd00000000000000000000000000000000000000000000000000000000000000", want: ErrTooManyPeers},
+	}
+
+	for i, c := range cases {
+		if err := sw.checkInboundLimit(c.ip, c.pubKey); err != c.want {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"encoding/hex"
 	"net"
 
 	log "github.com/sirupsen/logrus"
@@ -30,6 +31,22 @@ func CreateRandomPeer(outbound bool) *Peer {
 	return p
 }
 
+// NewPeerWithConnection creates the peer over the given raw connection and
+// multiplex connection, it's used by the in-process networks of the tests
+func NewPeerWithConnection(nodeInfo *NodeInfo, rawConn net.Conn, conn Connection, outbound bool) *Peer {
+	p := &Peer{
+		peerConn: &peerConn{
+			outbound: outbound,
+			conn:     rawConn,
+		},
+		NodeInfo: nodeInfo,
+		mconn:    conn,
+		Key:      hex.EncodeToString(nodeInfo.PubKey),
+	}
+	p.BaseService = *cmn.NewBaseService(nil, "Peer", p)
+	return p
+}
+
 func CreateRoutableAddr() (addr string, netAddr *NetAddress) {
 	for {
 		var err error
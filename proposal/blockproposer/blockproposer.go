@@ -8,11 +8,11 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"kuskcore/account"
-	"kuskcore/config"
 	"kuskcore/consensus"
 	"kuskcore/event"
 	"kuskcore/proposal"
 	"kuskcore/protocol"
+	"kuskcore/protocol/bc/types"
 )
 
 const (
@@ -41,8 +41,6 @@ type BlockProposer struct {
 //
 // It must be run as a goroutine.
 func (b *BlockProposer) generateBlocks() {
-	xpub := config.CommonConfig.PrivateKey().XPub()
-	xpubStr := hex.EncodeToString(xpub[:])
 	ticker := time.NewTicker(time.Duration(consensus.ActiveNetParams.BlockTimeInterval) * time.Millisecond / 4)
 	defer ticker.Stop()
 
@@ -53,54 +51,65 @@ func (b *BlockProposer) generateBlocks() {
 		case <-ticker.C:
 		}
 
-		bestBlockHeader := b.chain.BestBlockHeader()
-		bestBlockHash := bestBlockHeader.Hash()
+		b.ProposeBlock(uint64(time.Now().UnixNano() / 1e6))
+	}
+}
 
-		now := uint64(time.Now().UnixNano() / 1e6)
-		base := bestBlockHeader.Timestamp
-		if now > bestBlockHeader.Timestamp+consensus.ActiveNetParams.BlockTimeInterval {
-			base = now - consensus.ActiveNetParams.BlockTimeInterval
-		}
-		minTimeToNextBlock := consensus.ActiveNetParams.BlockTimeInterval - base%consensus.ActiveNetParams.BlockTimeInterval
-		nextBlockTime := base + minTimeToNextBlock
-		if (nextBlockTime - now) < consensus.ActiveNetParams.BlockTimeInterval/10 {
-			nextBlockTime += consensus.ActiveNetParams.BlockTimeInterval
-		}
+// ProposeBlock proposes the next block at the time now in milliseconds if the
+// node is the validator of the next block, it returns the processed block or
+// nil when nothing is proposed. It lets the simulations drive the proposer by
+// the virtual clock.
+func (b *BlockProposer) ProposeBlock(now uint64) *types.Block {
+	xpub := b.chain.PrivateKey().XPub()
+	xpubStr := hex.EncodeToString(xpub[:])
 
-		if nextBlockTime > now {
-			continue
-		}
+	bestBlockHeader := b.chain.BestBlockHeader()
+	bestBlockHash := bestBlockHeader.Hash()
 
-		validator, err := b.chain.GetValidator(&bestBlockHash, nextBlockTime)
-		if err != nil {
-			log.WithFields(log.Fields{"module": logModule, "error": err, "pubKey": xpubStr}).Error("fail on check is next blocker")
-			continue
-		}
+	base := bestBlockHeader.Timestamp
+	if now > bestBlockHeader.Timestamp+consensus.ActiveNetParams.BlockTimeInterval {
+		base = now - consensus.ActiveNetParams.BlockTimeInterval
+	}
+	minTimeToNextBlock := consensus.ActiveNetParams.BlockTimeInterval - base%consensus.ActiveNetParams.BlockTimeInterval
+	nextBlockTime := base + minTimeToNextBlock
+	if (nextBlockTime - now) < consensus.ActiveNetParams.BlockTimeInterval/10 {
+		nextBlockTime += consensus.ActiveNetParams.BlockTimeInterval
+	}
 
-		if xpubStr != validator.PubKey {
-			continue
-		}
+	if nextBlockTime > now {
+		return nil
+	}
 
-		warnDuration := time.Duration(consensus.ActiveNetParams.BlockTimeInterval*warnTimeNum/warnTimeDenom) * time.Millisecond
-		criticalDuration := time.Duration(consensus.ActiveNetParams.BlockTimeInterval*criticalTimeNum/criticalTimeDenom) * time.Millisecond
-		block, err := proposal.NewBlockTemplate(b.chain, validator, b.accountManager, nextBlockTime, warnDuration, criticalDuration)
-		if err != nil {
-			log.WithFields(log.Fields{"module": logModule, "error": err}).Error("failed on create NewBlockTemplate")
-			continue
-		}
+	validator, err := b.chain.GetValidator(&bestBlockHash, nextBlockTime)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "error": err, "pubKey": xpubStr}).Error("fail on check is next blocker")
+		return nil
+	}
 
-		isOrphan, err := b.chain.ProcessBlock(block)
-		if err != nil {
-			log.WithFields(log.Fields{"module": logModule, "height": block.BlockHeader.Height, "error": err}).Error("proposer fail on ProcessBlock")
-			continue
-		}
+	if xpubStr != validator.PubKey {
+		return nil
+	}
 
-		log.WithFields(log.Fields{"module": logModule, "height": block.BlockHeader.Height, "isOrphan": isOrphan, "tx": len(block.Transactions)}).Info("proposer processed block")
-		// Broadcast the block and announce chain insertion event
-		if err = b.eventDispatcher.Post(event.NewProposedBlockEvent{Block: *block}); err != nil {
-			log.WithFields(log.Fields{"module": logModule, "height": block.BlockHeader.Height, "error": err}).Error("proposer fail on post block")
-		}
+	warnDuration := time.Duration(consensus.ActiveNetParams.BlockTimeInterval*warnTimeNum/warnTimeDenom) * time.Millisecond
+	criticalDuration := time.Duration(consensus.ActiveNetParams.BlockTimeInterval*criticalTimeNum/criticalTimeDenom) * time.Millisecond
+	block, err := proposal.NewBlockTemplate(b.chain, validator, b.accountManager, nextBlockTime, warnDuration, criticalDuration)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "error": err}).Error("failed on create NewBlockTemplate")
+		return nil
+	}
+
+	isOrphan, err := b.chain.ProcessBlock(block)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "height": block.BlockHeader.Height, "error": err}).Error("proposer fail on ProcessBlock")
+		return nil
+	}
+
+	log.WithFields(log.Fields{"module": logModule, "height": block.BlockHeader.Height, "isOrphan": isOrphan, "tx": len(block.Transactions)}).Info("proposer processed block")
+	// Broadcast the block and announce chain insertion event
+	if err = b.eventDispatcher.Post(event.NewProposedBlockEvent{Block: *block}); err != nil {
+		log.WithFields(log.Fields{"module": logModule, "height": block.BlockHeader.Height, "error": err}).Error("proposer fail on post block")
 	}
+	return block
 }
 
 // Start begins the block propose process as well as the speed monitor used to
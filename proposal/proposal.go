@@ -70,13 +70,19 @@ func newBlockBuilder(chain *protocol.Chain, validator *state.Validator, accountM
 		utxoView:          state.NewUtxoViewpoint(),
 		warnTimeoutCh:     time.After(warnDuration),
 		criticalTimeoutCh: time.After(criticalDuration),
-		gasLeft:           int64(consensus.MaxBlockGas),
+		gasLeft:           int64(consensus.ActiveNetParams.ForkParams(block.Height).MaxBlockGas),
 		timeoutStatus:     timeoutOk,
 	}
 	return builder
 }
 
 func (b *blockBuilder) build() (*types.Block, error) {
+	version, err := b.chain.BlockVersion(&b.block.PreviousBlockHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail on get block version")
+	}
+
+	b.block.Version = version
 	b.block.Transactions = []*types.Tx{nil}
 	if err := b.applyTransactionFromPool(); err != nil {
 		return nil, err
@@ -101,7 +107,7 @@ func (b *blockBuilder) applyCoinbaseTransaction() error {
 		return errors.Wrap(err, "fail on create coinbase tx")
 	}
 
-	gasState, err := validation.ValidateTx(coinbaseTx.Tx, &bc.Block{BlockHeader: &bc.BlockHeader{Height: b.block.Height}, Transactions: []*bc.Tx{coinbaseTx.Tx}}, b.chain.ProgramConverter)
+	gasState, err := validation.ValidateTx(coinbaseTx.Tx, &bc.Block{BlockHeader: &bc.BlockHeader{Height: b.block.Height}, Transactions: []*bc.Tx{coinbaseTx.Tx}}, b.chain.ProgramConverter, nil)
 	if err != nil {
 		return err
 	}
@@ -235,13 +241,13 @@ type validateTxResult struct {
 
 func (b *blockBuilder) preValidateTxs(txs []*protocol.TxDesc, chain *protocol.Chain, view *state.UtxoViewpoint, gasLeft int64) ([]*validateTxResult, int64) {
 	var results []*validateTxResult
-	bcBlock := &bc.Block{BlockHeader: &bc.BlockHeader{Height: chain.BestBlockHeight() + 1}}
+	bcBlock := &bc.Block{BlockHeader: &bc.BlockHeader{Height: b.block.Height, Timestamp: b.block.Timestamp}}
 	bcTxs := make([]*bc.Tx, len(txs))
 	for i, tx := range txs {
 		bcTxs[i] = tx.Tx.Tx
 	}
 
-	validateResults := validation.ValidateTxs(bcTxs, bcBlock, b.chain.ProgramConverter)
+	validateResults := validation.ValidateTxs(bcTxs, bcBlock, b.chain.ProgramConverter, b.chain.OutputHeight(bcBlock.Height))
 	for i := 0; i < len(validateResults) && gasLeft > 0; i++ {
 		tx := txs[i].Tx
 		gasStatus := validateResults[i].GetGasState()
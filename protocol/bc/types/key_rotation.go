@@ -0,0 +1,68 @@
+package types
+
+import (
+	"bytes"
+
+	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
+	"kuskcore/crypto/sha3pool"
+	"kuskcore/errors"
+)
+
+// KeyRotationFlag is the first state data item of a vote output which rotates
+// the validator key voted by the output
+var KeyRotationFlag = []byte("keyRotation")
+
+var (
+	// ErrKeyRotationFormat is returned when the state data is flagged as a key rotation but malformed
+	ErrKeyRotationFormat = errors.New("invalid key rotation format")
+	// ErrKeyRotationSignature is returned when the key rotation is not signed by the old key
+	ErrKeyRotationSignature = errors.New("invalid key rotation signature")
+)
+
+// KeyRotation moves all the votes of the validator OldPubKey to NewPubKey at the
+// next epoch boundary. It is carried by a vote output, the output's vote is the
+// old pubkey and the state data is [KeyRotationFlag, NewPubKey, Signature]
+type KeyRotation struct {
+	OldPubKey []byte
+	NewPubKey []byte
+	Signature []byte
+}
+
+// KeyRotationMsg is the message must be signed by the old key, the name of the
+// network separates the rotations of the different chains
+func KeyRotationMsg(oldPubKey, newPubKey []byte) []byte {
+	msg := make([]byte, 32)
+	sha3pool.Sum256(msg, bytes.Join([][]byte{KeyRotationFlag, []byte(consensus.ActiveNetParams.Name), oldPubKey, newPubKey}, nil))
+	return msg
+}
+
+// NewKeyRotationStateData returns the state data of a vote output to rotate the key
+func NewKeyRotationStateData(newPubKey, signature []byte) [][]byte {
+	return [][]byte{KeyRotationFlag, newPubKey, signature}
+}
+
+// IsKeyRotation return whether the state data of the vote output is flagged as a key rotation
+func IsKeyRotation(stateData [][]byte) bool {
+	return len(stateData) != 0 && bytes.Equal(stateData[0], KeyRotationFlag)
+}
+
+// ParseKeyRotation parses and verifies the key rotation carried by a vote output
+func ParseKeyRotation(vote []byte, stateData [][]byte) (*KeyRotation, error) {
+	if !IsKeyRotation(stateData) || len(stateData) != 3 {
+		return nil, ErrKeyRotationFormat
+	}
+
+	rotation := &KeyRotation{OldPubKey: vote, NewPubKey: stateData[1], Signature: stateData[2]}
+	if len(rotation.OldPubKey) != 64 || len(rotation.NewPubKey) != 64 || bytes.Equal(rotation.OldPubKey, rotation.NewPubKey) {
+		return nil, ErrKeyRotationFormat
+	}
+
+	var oldXPub chainkd.XPub
+	copy(oldXPub[:], rotation.OldPubKey)
+	if !oldXPub.Verify(KeyRotationMsg(rotation.OldPubKey, rotation.NewPubKey), rotation.Signature) {
+		return nil, ErrKeyRotationSignature
+	}
+
+	return rotation, nil
+}
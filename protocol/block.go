@@ -1,8 +1,12 @@
 package protocol
 
 import (
+	"sync"
+
 	log "github.com/sirupsen/logrus"
 
+	"kuskcore/crypto/sha3pool"
+	"kuskcore/database/storage"
 	"kuskcore/errors"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
@@ -16,6 +20,8 @@ var (
 	// ErrBadStateRoot is returned when the computed assets merkle root
 	// disagrees with the one declared in a block header.
 	ErrBadStateRoot = errors.New("invalid state merkle root")
+
+	errUnconfirmedOutput = errors.New("output is not confirmed")
 )
 
 // BlockExist check is a block in chain or orphan
@@ -57,6 +63,16 @@ func (c *Chain) GetHeaderByHeight(height uint64) (*types.BlockHeader, error) {
 	return c.store.GetBlockHeader(hash)
 }
 
+// GetBlockFilter return the compact filter of the block by given hash
+func (c *Chain) GetBlockFilter(hash *bc.Hash) ([]byte, error) {
+	return c.store.GetBlockFilter(hash)
+}
+
+// GetBlockFilterHeader return the filter header of the block by given hash
+func (c *Chain) GetBlockFilterHeader(hash *bc.Hash) (*bc.Hash, error) {
+	return c.store.GetBlockFilterHeader(hash)
+}
+
 func (c *Chain) calcReorganizeChain(beginAttach *types.BlockHeader, beginDetach *types.BlockHeader) ([]*types.BlockHeader, []*types.BlockHeader, error) {
 	var err error
 	var attachBlockHeaders []*types.BlockHeader
@@ -192,7 +208,7 @@ func (c *Chain) saveBlock(block *types.Block) error {
 		return err
 	}
 
-	if err := validation.ValidateBlock(block, parent, checkpoint, c.ProgramConverter); err != nil {
+	if err := c.validateBlock(block, parent, checkpoint); err != nil {
 		return errors.Sub(ErrBadBlock, err)
 	}
 
@@ -209,6 +225,192 @@ func (c *Chain) saveBlock(block *types.Block) error {
 	return nil
 }
 
+// prevalidatedBlock is the results of the transactions validated ahead of the
+// block, with the heights of the spent outputs the results depend on
+type prevalidatedBlock struct {
+	results       []*validation.ValidateTxResult
+	mtx           sync.Mutex
+	outputHeights map[bc.Hash]uint64
+}
+
+// PrevalidateBlock validates the transactions of the block going to be
+// processed, it's safe to call in parallel ahead of ProcessBlock so the block
+// is applied without validating the transactions again
+func (c *Chain) PrevalidateBlock(block *types.Block) {
+	key, err := blockContentHash(block)
+	if err != nil {
+		return
+	}
+
+	prevalidated := &prevalidatedBlock{outputHeights: map[bc.Hash]uint64{}}
+	results, ok := validation.PrevalidateBlock(block, c.ProgramConverter, func(outputID bc.Hash) (uint64, error) {
+		height, err := c.confirmedOutputHeight(outputID)
+		if err != nil {
+			return 0, err
+		}
+
+		prevalidated.mtx.Lock()
+		prevalidated.outputHeights[outputID] = height
+		prevalidated.mtx.Unlock()
+		return height, nil
+	})
+	if !ok {
+		return
+	}
+
+	prevalidated.results = results
+	c.txResultsCache.Add(key, prevalidated)
+}
+
+// confirmedOutputHeight looks up the height of the output in the utxo set only,
+// the transactions spending the outputs of the blocks not applied yet fail the
+// prevalidation and are validated with the block
+func (c *Chain) confirmedOutputHeight(outputID bc.Hash) (uint64, error) {
+	entry, err := c.store.GetUtxo(&outputID)
+	if err != nil {
+		return 0, err
+	}
+
+	if entry == nil || entry.Spent {
+		return 0, errUnconfirmedOutput
+	}
+	return entry.BlockHeight, nil
+}
+
+// prevalidatedTxResults returns the results of the prevalidated transactions of
+// the block. They're only used when the block extends the best block and the
+// spent outputs are still at the heights the transactions were validated with.
+func (c *Chain) prevalidatedTxResults(block *types.Block, view *state.UtxoViewpoint) ([]*validation.ValidateTxResult, bool) {
+	key, err := blockContentHash(block)
+	if err != nil {
+		return nil, false
+	}
+
+	value, ok := c.txResultsCache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	c.txResultsCache.Remove(key)
+	if block.PreviousBlockHash != c.bestBlockHeader.Hash() {
+		return nil, false
+	}
+
+	prevalidated := value.(*prevalidatedBlock)
+	outputHeight := viewOutputHeight(view)
+	for outputID, height := range prevalidated.outputHeights {
+		if viewHeight, err := outputHeight(outputID); err != nil || viewHeight != height {
+			return nil, false
+		}
+	}
+	return prevalidated.results, true
+}
+
+func (c *Chain) validateBlock(block *types.Block, parent *types.BlockHeader, checkpoint *state.Checkpoint) error {
+	view, err := c.blockUtxoView(block)
+	if err != nil {
+		return err
+	}
+
+	if results, ok := c.prevalidatedTxResults(block, view); ok {
+		return validation.ValidateBlockWithTxResults(block, parent, checkpoint, results)
+	}
+
+	return validation.ValidateBlock(block, parent, checkpoint, c.ProgramConverter, viewOutputHeight(view))
+}
+
+// blockContentHash hashes the full serialized block, unlike the block hash it
+// covers the witnesses of the transactions which the results depend on
+func blockContentHash(block *types.Block) (bc.Hash, error) {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+
+	if _, err := block.WriteTo(h); err != nil {
+		return bc.Hash{}, err
+	}
+
+	var b32 [32]byte
+	h.Read(b32[:])
+	return bc.NewHash(b32), nil
+}
+
+// blockUtxoView returns the utxo view holding the outputs spent by the block,
+// the view is at the parent of the block which may be on a side chain. The
+// outputs created by the block itself are at the height of the block.
+func (c *Chain) blockUtxoView(block *types.Block) (*state.UtxoViewpoint, error) {
+	view, err := c.forkUtxoView(&block.PreviousBlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	bcBlock := types.MapBlock(block)
+	if err := c.store.GetTransactionsUtxo(view, bcBlock.Transactions); err != nil {
+		return nil, err
+	}
+
+	for _, tx := range bcBlock.Transactions {
+		for _, id := range tx.ResultIds {
+			view.Entries[*id] = storage.NewUtxoEntry(storage.NormalUTXOType, block.Height, false)
+		}
+	}
+	return view, nil
+}
+
+// forkUtxoView returns the utxo view at the block of the given hash, the main
+// chain blocks after the fork point are detached from the utxo set and the side
+// chain blocks are attached
+func (c *Chain) forkUtxoView(hash *bc.Hash) (*state.UtxoViewpoint, error) {
+	view := state.NewUtxoViewpoint()
+	if *hash == c.bestBlockHeader.Hash() {
+		return view, nil
+	}
+
+	header, err := c.store.GetBlockHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	attachHeaders, detachHeaders, err := c.calcReorganizeChain(header, c.bestBlockHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, detachHeader := range detachHeaders {
+		detachHash := detachHeader.Hash()
+		b, err := c.store.GetBlock(&detachHash)
+		if err != nil {
+			return nil, err
+		}
+
+		detachBlock := types.MapBlock(b)
+		if err := c.store.GetTransactionsUtxo(view, detachBlock.Transactions); err != nil {
+			return nil, err
+		}
+
+		if err := view.DetachBlock(detachBlock); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, attachHeader := range attachHeaders {
+		attachHash := attachHeader.Hash()
+		b, err := c.store.GetBlock(&attachHash)
+		if err != nil {
+			return nil, err
+		}
+
+		attachBlock := types.MapBlock(b)
+		if err := c.store.GetTransactionsUtxo(view, attachBlock.Transactions); err != nil {
+			return nil, err
+		}
+
+		if err := view.ApplyBlock(attachBlock); err != nil {
+			return nil, err
+		}
+	}
+	return view, nil
+}
+
 func (c *Chain) saveSubBlock(block *types.Block) {
 	blockHash := block.Hash()
 	prevOrphans, ok := c.orphanManage.GetPrevOrphans(&blockHash)
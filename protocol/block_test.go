@@ -0,0 +1,235 @@
+package protocol
+
+import (
+	"testing"
+
+	"kuskcore/common"
+	"kuskcore/consensus"
+	"kuskcore/database/storage"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/state"
+	"kuskcore/protocol/vm"
+)
+
+type mockForkStore struct {
+	mockStore
+	blocks map[bc.Hash]*types.Block
+	utxos  map[bc.Hash]*storage.UtxoEntry
+}
+
+func (s *mockForkStore) GetBlockHeader(hash *bc.Hash) (*types.BlockHeader, error) {
+	block, err := s.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &block.BlockHeader, nil
+}
+
+func (s *mockForkStore) GetBlock(hash *bc.Hash) (*types.Block, error) {
+	block, ok := s.blocks[*hash]
+	if !ok {
+		return nil, errors.New("can't find block")
+	}
+	return block, nil
+}
+
+func (s *mockForkStore) GetUtxo(hash *bc.Hash) (*storage.UtxoEntry, error) {
+	entry, ok := s.utxos[*hash]
+	if !ok {
+		return nil, errors.New("can't find utxo")
+	}
+	return entry, nil
+}
+
+func (s *mockForkStore) GetTransactionsUtxo(view *state.UtxoViewpoint, txs []*bc.Tx) error {
+	for _, tx := range txs {
+		for _, prevout := range tx.SpentOutputIDs {
+			if entry, ok := s.utxos[prevout]; ok && !view.HasUtxo(&prevout) {
+				utxo := *entry
+				view.Entries[prevout] = &utxo
+			}
+		}
+	}
+	return nil
+}
+
+func TestBlockUtxoView(t *testing.T) {
+	tx := types.NewTx(types.TxData{
+		Inputs:  []*types.TxInput{types.NewSpendInput(nil, bc.NewHash([32]byte{1}), *consensus.KUSKAssetID, 10, 0, []byte{0x51}, nil)},
+		Outputs: []*types.TxOutput{types.NewOriginalTxOutput(*consensus.KUSKAssetID, 10, []byte{0x51}, nil)},
+	})
+	outputID := *tx.ResultIds[0]
+	spendTx := types.NewTx(types.TxData{
+		Inputs:  []*types.TxInput{types.NewSpendInput(nil, *tx.Entries[outputID].(*bc.OriginalOutput).Source.Ref, *consensus.KUSKAssetID, 10, 0, []byte{0x51}, nil)},
+		Outputs: []*types.TxOutput{types.NewOriginalTxOutput(*consensus.KUSKAssetID, 10, []byte{0x52}, nil)},
+	})
+	missingID := bc.NewHash([32]byte{2})
+
+	newBlock := func(parent *types.Block, timestamp uint64, txs ...*types.Tx) *types.Block {
+		block := &types.Block{BlockHeader: types.BlockHeader{Timestamp: timestamp}, Transactions: txs}
+		if parent != nil {
+			block.Height = parent.Height + 1
+			block.PreviousBlockHash = parent.Hash()
+		}
+		return block
+	}
+
+	// the transaction is confirmed at height 1 on the main chain, and at
+	// height 2 on the side chain
+	genesis := newBlock(nil, 0)
+	mainBlock := newBlock(genesis, 1, tx)
+	sideBlock1 := newBlock(genesis, 2)
+	sideBlock2 := newBlock(sideBlock1, 3, tx)
+
+	store := &mockForkStore{
+		blocks: map[bc.Hash]*types.Block{},
+		utxos:  map[bc.Hash]*storage.UtxoEntry{outputID: storage.NewUtxoEntry(storage.NormalUTXOType, 1, false)},
+	}
+	for _, block := range []*types.Block{genesis, mainBlock, sideBlock1, sideBlock2} {
+		store.blocks[block.Hash()] = block
+	}
+	chain := &Chain{store: store, bestBlockHeader: &mainBlock.BlockHeader}
+
+	cases := []struct {
+		block      *types.Block
+		wantHeight uint64
+	}{
+		{block: newBlock(mainBlock, 4, spendTx), wantHeight: 1},
+		{block: newBlock(sideBlock2, 5, spendTx), wantHeight: 2},
+		{block: newBlock(sideBlock1, 6, tx, spendTx), wantHeight: 2},
+	}
+
+	for i, c := range cases {
+		view, err := chain.blockUtxoView(c.block)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+
+		outputHeight := viewOutputHeight(view)
+		if height, err := outputHeight(outputID); err != nil || height != c.wantHeight {
+			t.Errorf("case %d: got output height %d, %v, want %d", i, height, err, c.wantHeight)
+		}
+
+		if _, err := outputHeight(missingID); errors.Root(err) != ErrMissingUtxo {
+			t.Errorf("case %d: got error %v with missing output, want %v", i, err, ErrMissingUtxo)
+		}
+	}
+}
+
+func TestPrevalidatedTxResults(t *testing.T) {
+	defer func(params consensus.Params) { consensus.ActiveNetParams = params }(consensus.ActiveNetParams)
+	consensus.ActiveNetParams.Forks = []consensus.Fork{{Name: "timeOpcodes", Features: []string{consensus.FeatureTimeOpcodes}}}
+
+	program, err := vm.Assemble("OUTPUTHEIGHT NUMEQUAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the transaction checks the height of the spent output equals the argument,
+	// the argument is in the witness which the tx id leaves out
+	newTx := func(height byte) *types.Tx {
+		return types.NewTx(types.TxData{
+			Version:        1,
+			SerializedSize: 1,
+			Inputs: []*types.TxInput{
+				types.NewSpendInput(nil, bc.NewHash([32]byte{1}), *consensus.KUSKAssetID, 100000000, 0, []byte{0x51}, nil),
+				types.NewSpendInput([][]byte{{height}}, bc.NewHash([32]byte{2}), *consensus.KUSKAssetID, 1, 0, program, nil),
+			},
+			Outputs: []*types.TxOutput{types.NewOriginalTxOutput(*consensus.KUSKAssetID, 1, []byte{0x6a}, nil)},
+		})
+	}
+	outputID := newTx(5).SpentOutputIDs[1]
+
+	genesis := &types.Block{}
+	bestBlock := &types.Block{BlockHeader: types.BlockHeader{Height: 1, Timestamp: 1, PreviousBlockHash: genesis.Hash()}}
+	newBlock := func(parent *types.Block, height byte) *types.Block {
+		return &types.Block{
+			BlockHeader:  types.BlockHeader{Height: parent.Height + 1, Timestamp: 2, PreviousBlockHash: parent.Hash()},
+			Transactions: []*types.Tx{newTx(height)},
+		}
+	}
+	if newBlock(bestBlock, 5).Hash() != newBlock(bestBlock, 6).Hash() {
+		t.Fatal("the witness changes the block hash")
+	}
+
+	cases := []struct {
+		desc           string
+		prevalidate    *types.Block
+		prevalidateAt  uint64
+		validate       *types.Block
+		validateAt     uint64
+		confirmedLater bool
+		wantOk         bool
+		wantLeft       int
+	}{
+		{
+			desc:          "the prevalidated block extends the best block",
+			prevalidate:   newBlock(bestBlock, 5),
+			prevalidateAt: 5,
+			validate:      newBlock(bestBlock, 5),
+			validateAt:    5,
+			wantOk:        true,
+		},
+		{
+			desc:          "the block differs from the prevalidated one in the witness",
+			prevalidate:   newBlock(bestBlock, 5),
+			prevalidateAt: 5,
+			validate:      newBlock(bestBlock, 6),
+			validateAt:    5,
+			wantLeft:      1,
+		},
+		{
+			desc:          "the prevalidated block is on a side chain",
+			prevalidate:   newBlock(genesis, 5),
+			prevalidateAt: 5,
+			validate:      newBlock(genesis, 5),
+			validateAt:    5,
+		},
+		{
+			desc:          "the spent output moves to another height after the prevalidation",
+			prevalidate:   newBlock(bestBlock, 5),
+			prevalidateAt: 5,
+			validate:      newBlock(bestBlock, 5),
+			validateAt:    6,
+		},
+		{
+			desc:           "the spent output is not confirmed on the prevalidation",
+			prevalidate:    newBlock(bestBlock, 5),
+			validate:       newBlock(bestBlock, 5),
+			validateAt:     5,
+			confirmedLater: true,
+		},
+	}
+
+	for i, c := range cases {
+		store := &mockForkStore{
+			blocks: map[bc.Hash]*types.Block{genesis.Hash(): genesis, bestBlock.Hash(): bestBlock},
+			utxos:  map[bc.Hash]*storage.UtxoEntry{},
+		}
+		if !c.confirmedLater {
+			store.utxos[outputID] = storage.NewUtxoEntry(storage.NormalUTXOType, c.prevalidateAt, false)
+		}
+		chain := &Chain{store: store, bestBlockHeader: &bestBlock.BlockHeader, txResultsCache: common.NewCache(maxNumOfPrevalidatedBlocks)}
+
+		chain.PrevalidateBlock(c.prevalidate)
+		if cached := chain.txResultsCache.Len() == 1; cached == c.confirmedLater {
+			t.Fatalf("case %d (%s): got the block prevalidated %v, want %v", i, c.desc, cached, !c.confirmedLater)
+		}
+
+		store.utxos[outputID] = storage.NewUtxoEntry(storage.NormalUTXOType, c.validateAt, false)
+		view, err := chain.blockUtxoView(c.validate)
+		if err != nil {
+			t.Fatalf("case %d (%s): %v", i, c.desc, err)
+		}
+
+		if results, ok := chain.prevalidatedTxResults(c.validate, view); ok != c.wantOk || (ok && len(results) != len(c.validate.Transactions)) {
+			t.Errorf("case %d (%s): got %d results, %v, want %v", i, c.desc, len(results), ok, c.wantOk)
+		}
+
+		if chain.txResultsCache.Len() != c.wantLeft {
+			t.Errorf("case %d (%s): got %d prevalidated blocks left, want %d", i, c.desc, chain.txResultsCache.Len(), c.wantLeft)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
-	"kuskcore/config"
 	"kuskcore/consensus"
 	"kuskcore/errors"
 	"kuskcore/protocol/bc"
@@ -131,7 +130,7 @@ func (c *Casper) myVerification(target *state.Checkpoint) *verification {
 		return nil
 	}
 
-	prvKey := config.CommonConfig.PrivateKey()
+	prvKey := c.privateKey()
 	v, err := convertVerification(source, target, &ValidCasperSignMsg{PubKey: prvKey.XPub().String()})
 	if err != nil {
 		return nil
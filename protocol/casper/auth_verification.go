@@ -23,6 +23,11 @@ func (c *Casper) AuthVerification(msg *ValidCasperSignMsg) error {
 		return nil
 	}
 
+	// the last finalized checkpoint has no parent in the tree, the late verification can't change it
+	if targetNode.Parent == nil {
+		return nil
+	}
+
 	source, err := c.store.GetCheckpoint(&msg.SourceHash)
 	if err != nil {
 		return err
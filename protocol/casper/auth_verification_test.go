@@ -98,6 +98,17 @@ func TestRollback(t *testing.T) {
 	}
 }
 
+func TestAuthVerificationToRoot(t *testing.T) {
+	casper := NewCasper(&mockStore2{}, event.NewDispatcher(), checkpoints)
+	if err := casper.AuthVerification(&ValidCasperSignMsg{
+		SourceHash: checkpoints[0].Hash,
+		TargetHash: checkpoints[0].Hash,
+		PubKey:     pubKey,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 type mockStore2 struct{}
 
 func (s *mockStore2) GetCheckpointsByHeight(u uint64) ([]*state.Checkpoint, error) { return nil, nil }
@@ -112,6 +123,8 @@ func (s *mockStore2) GetTransactionsUtxo(*state.UtxoViewpoint, []*bc.Tx) error {
 func (s *mockStore2) GetUtxo(*bc.Hash) (*storage.UtxoEntry, error)             { return nil, nil }
 func (s *mockStore2) GetMainChainHash(uint64) (*bc.Hash, error)                { return nil, nil }
 func (s *mockStore2) GetContract([32]byte) ([]byte, error)                     { return nil, nil }
+func (s *mockStore2) GetBlockFilter(*bc.Hash) ([]byte, error)                  { return nil, nil }
+func (s *mockStore2) GetBlockFilterHeader(*bc.Hash) (*bc.Hash, error)          { return nil, nil }
 func (s *mockStore2) SaveBlock(*types.Block) error                             { return nil }
 func (s *mockStore2) SaveBlockHeader(*types.BlockHeader) error                 { return nil }
 func (s *mockStore2) SaveChainStatus(*types.BlockHeader, []*types.BlockHeader, *state.UtxoViewpoint, *state.ContractViewpoint, uint64, *bc.Hash) error {
@@ -6,7 +6,9 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"kuskcore/common"
+	"kuskcore/config"
 	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
 	"kuskcore/errors"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/state"
@@ -47,6 +49,9 @@ type Casper struct {
 
 	rollbackCh chan *RollbackMsg
 	newEpochCh chan bc.Hash
+
+	// prvKey signs the verifications of the node, nil means the key of the config
+	prvKey *chainkd.XPrv
 }
 
 // NewCasper create a new instance of Casper
@@ -71,6 +76,21 @@ func NewCasper(store state.Store, queue msgQueue, checkpoints []*state.Checkpoin
 	return casper
 }
 
+// SetPrivateKey set the key signing the verifications instead of the key of the config
+func (c *Casper) SetPrivateKey(xprv *chainkd.XPrv) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prvKey = xprv
+}
+
+func (c *Casper) privateKey() *chainkd.XPrv {
+	if c.prvKey != nil {
+		return c.prvKey
+	}
+	return config.CommonConfig.PrivateKey()
+}
+
 // LastFinalized return the block height and block hash which is finalized at last
 func (c *Casper) LastFinalized() (uint64, bc.Hash) {
 	c.mu.RLock()
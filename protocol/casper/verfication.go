@@ -139,3 +139,28 @@ func (v *verification) encodeMessage() ([]byte, error) {
 	msg := sha3.Sum256(buff.Bytes())
 	return msg[:], nil
 }
+
+// VerifySupLink verifies the signatures of the sup link to the target checkpoint
+// block, the signers are the validators of the parent checkpoint of the target
+func VerifySupLink(parent *state.Checkpoint, targetHeight uint64, targetHash bc.Hash, supLink *types.SupLink) error {
+	for _, validator := range parent.EffectiveValidators() {
+		signature := supLink.Signatures[validator.Order]
+		if len(signature) == 0 {
+			continue
+		}
+
+		v := &verification{
+			SourceHash:   supLink.SourceHash,
+			TargetHash:   targetHash,
+			SourceHeight: supLink.SourceHeight,
+			TargetHeight: targetHeight,
+			Signature:    signature,
+			PubKey:       validator.PubKey,
+			order:        validator.Order,
+		}
+		if err := v.valid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
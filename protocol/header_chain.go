@@ -0,0 +1,134 @@
+package protocol
+
+import (
+	"runtime"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"kuskcore/consensus"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/casper"
+	"kuskcore/protocol/state"
+	"kuskcore/protocol/validation"
+)
+
+// ErrUnknownHeaderParent is returned when the header chain doesn't extend a local block
+var ErrUnknownHeaderParent = errors.New("the parent of the header chain is unknown")
+
+// VerifyHeaders verifies the header chain which extends a local block, the
+// bodies of the blocks are not needed. The headers must be linked, signed by
+// the validators of their time slots, and the sup links of the checkpoints must
+// be signed by the validators of the epochs.
+//
+// The validators of the epochs after the local checkpoints depend on the votes
+// in the block bodies, they're assumed unchanged from the last known epoch. The
+// header failing on the assumed validators isn't an error, the header chain is
+// cut there and the rest is left for the next sync.
+//
+// It returns the number of the verified headers from the start of the chain.
+func (c *Chain) VerifyHeaders(headers []*types.BlockHeader) (int, error) {
+	if len(headers) == 0 {
+		return 0, nil
+	}
+
+	prevHash := &headers[0].PreviousBlockHash
+	parent, err := c.store.GetBlockHeader(prevHash)
+	if err != nil {
+		return 0, errors.Sub(ErrUnknownHeaderParent, err)
+	}
+
+	checkpoint, err := c.PrevCheckpointByPrevHash(prevHash)
+	if err != nil {
+		return 0, err
+	}
+
+	checkpoints := make([]*state.Checkpoint, len(headers))
+	knownNum := len(headers)
+	for i, header := range headers {
+		if err := validation.ValidateHeaderLink(header, parent); err != nil {
+			return i, err
+		}
+
+		checkpoints[i] = checkpoint
+		if header.Height%consensus.ActiveNetParams.BlocksOfEpoch == 0 {
+			hash := header.Hash()
+			if checkpoint, err = c.store.GetCheckpoint(&hash); err != nil {
+				checkpoint = assumedCheckpoint(checkpoints[i], header)
+				if knownNum == len(headers) {
+					knownNum = i + 1
+				}
+			}
+		}
+		parent = header
+	}
+
+	errs := verifyHeaders(headers, checkpoints)
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if i < knownNum {
+			return i, err
+		}
+
+		log.WithFields(log.Fields{"module": logModule, "height": headers[i].Height, "err": err}).Debug("cut the header chain at the assumed validators")
+		return i, nil
+	}
+	return len(headers), nil
+}
+
+// assumedCheckpoint is the checkpoint of the header ending the epoch, the votes
+// of the epoch are unknown so the votes of the parent are kept
+func assumedCheckpoint(parent *state.Checkpoint, header *types.BlockHeader) *state.Checkpoint {
+	checkpoint := state.NewCheckpoint(parent)
+	checkpoint.Height = header.Height
+	checkpoint.Hash = header.Hash()
+	checkpoint.Timestamp = header.Timestamp
+	checkpoint.Status = state.Unjustified
+	return checkpoint
+}
+
+// verifyHeaders verifies the signatures of the headers in parallel, the header
+// of index i is verified by the validators of checkpoints[i]
+func verifyHeaders(headers []*types.BlockHeader, checkpoints []*state.Checkpoint) []error {
+	errs := make([]error, len(headers))
+	workCh := make(chan int, len(headers))
+	for i := range headers {
+		workCh <- i
+	}
+	close(workCh)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU() && i < len(headers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range workCh {
+				errs[i] = verifyHeader(headers[i], checkpoints[i])
+			}
+		}()
+	}
+
+	wg.Wait()
+	return errs
+}
+
+func verifyHeader(header *types.BlockHeader, checkpoint *state.Checkpoint) error {
+	if err := validation.ValidateBlockSignature(header, checkpoint); err != nil {
+		return err
+	}
+
+	if header.Height%consensus.ActiveNetParams.BlocksOfEpoch != 0 {
+		return nil
+	}
+
+	for _, supLink := range header.SupLinks {
+		if err := casper.VerifySupLink(checkpoint, header.Height, header.Hash(), supLink); err != nil {
+			return errors.Wrap(err, "verify sup link")
+		}
+	}
+	return nil
+}
@@ -5,7 +5,10 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"kuskcore/common"
 	"kuskcore/config"
+	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
 	"kuskcore/event"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
@@ -14,7 +17,8 @@ import (
 )
 
 const (
-	maxProcessBlockChSize = 1024
+	maxProcessBlockChSize      = 1024
+	maxNumOfPrevalidatedBlocks = 1024
 )
 
 // Chain provides functions for working with the Kusk block chain.
@@ -25,9 +29,12 @@ type Chain struct {
 	casper          *casper.Casper
 	processBlockCh  chan *processBlockMsg
 	eventDispatcher *event.Dispatcher
+	txResultsCache  *common.Cache // block content hash -> the prevalidated transactions of the block
 
 	cond            sync.Cond
 	bestBlockHeader *types.BlockHeader // the last block on current main chain
+
+	prvKey *chainkd.XPrv // the key signing the blocks, nil means the key of the config
 }
 
 // NewChain returns a new Chain using store as the underlying storage.
@@ -42,6 +49,7 @@ func NewChainWithOrphanManage(store state.Store, txPool *TxPool, manage *OrphanM
 		txPool:          txPool,
 		store:           store,
 		processBlockCh:  make(chan *processBlockMsg, maxProcessBlockChSize),
+		txResultsCache:  common.NewCache(maxNumOfPrevalidatedBlocks),
 	}
 	c.cond.L = new(sync.Mutex)
 
@@ -170,6 +178,36 @@ func (c *Chain) GetValidator(prevHash *bc.Hash, timeStamp uint64) (*state.Valida
 	return parentCheckpoint.GetValidator(timeStamp), nil
 }
 
+// BlockVersion return the version of the next block after the specified blockHash,
+// it signals the deployments in started state
+func (c *Chain) BlockVersion(prevHash *bc.Hash) (uint64, error) {
+	prevHeader, err := c.store.GetBlockHeader(prevHash)
+	if err != nil {
+		return 0, err
+	}
+
+	parentCheckpoint, err := c.casper.ParentCheckpointByPrevHash(prevHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return parentCheckpoint.BlockVersion(prevHeader.Height + 1), nil
+}
+
+// DeploymentStates return the state of deployments for the next block after the specified blockHash
+func (c *Chain) DeploymentStates(prevHash *bc.Hash) (map[string]state.DeploymentState, error) {
+	parentCheckpoint, err := c.casper.ParentCheckpointByPrevHash(prevHash)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]state.DeploymentState)
+	for _, deployment := range consensus.ActiveNetParams.Deployments {
+		states[deployment.Name] = parentCheckpoint.DeploymentState(deployment.Name)
+	}
+	return states, nil
+}
+
 // BestBlockHeader returns the chain tail block
 func (c *Chain) BestBlockHeader() *types.BlockHeader {
 	c.cond.L.Lock()
@@ -192,8 +230,22 @@ func (c *Chain) InMainChain(hash bc.Hash) bool {
 	return *blockHash == hash
 }
 
+// SetPrivateKey set the key signing the blocks and the verifications of the chain
+func (c *Chain) SetPrivateKey(xprv *chainkd.XPrv) {
+	c.prvKey = xprv
+	c.casper.SetPrivateKey(xprv)
+}
+
+// PrivateKey return the key signing the blocks of the chain
+func (c *Chain) PrivateKey() *chainkd.XPrv {
+	if c.prvKey != nil {
+		return c.prvKey
+	}
+	return config.CommonConfig.PrivateKey()
+}
+
 func (c *Chain) SignBlockHeader(blockHeader *types.BlockHeader) {
-	xprv := config.CommonConfig.PrivateKey()
+	xprv := c.PrivateKey()
 	signature := xprv.Sign(blockHeader.Hash().Bytes())
 	blockHeader.Set(signature)
 }
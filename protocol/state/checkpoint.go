@@ -45,6 +45,12 @@ type Checkpoint struct {
 	Rewards map[string]uint64 // controlProgram -> num of reward
 	Votes   map[string]uint64 // pubKey -> num of vote
 
+	KeyRotations        map[string]string // rotated pubKey -> pubKey now holding its votes
+	PendingKeyRotations []*KeyRotation    // applied in order when the epoch ends
+
+	Deployments map[string]DeploymentState // deployment name -> state
+	Signals     map[string]uint64          // pubKey -> version bits signalled in the epoch
+
 	// only save in the memory, not be persisted
 	Parent   *Checkpoint      `json:"-"`
 	SupLinks []*types.SupLink `json:"-"`
@@ -53,14 +59,16 @@ type Checkpoint struct {
 // NewCheckpoint create a new checkpoint instance
 func NewCheckpoint(parent *Checkpoint) *Checkpoint {
 	checkpoint := &Checkpoint{
-		Height:     parent.Height,
-		Hash:       parent.Hash,
-		Timestamp:  parent.Timestamp,
-		ParentHash: parent.Hash,
-		Parent:     parent,
-		Status:     Growing,
-		Rewards:    make(map[string]uint64),
-		Votes:      make(map[string]uint64),
+		Height:       parent.Height,
+		Hash:         parent.Hash,
+		Timestamp:    parent.Timestamp,
+		ParentHash:   parent.Hash,
+		Parent:       parent,
+		Status:       Growing,
+		Rewards:      make(map[string]uint64),
+		Votes:        make(map[string]uint64),
+		KeyRotations: make(map[string]string),
+		Deployments:  make(map[string]DeploymentState),
 	}
 
 	for pubKey, num := range parent.Votes {
@@ -68,9 +76,23 @@ func NewCheckpoint(parent *Checkpoint) *Checkpoint {
 			checkpoint.Votes[pubKey] = num
 		}
 	}
+
+	for oldPubKey, newPubKey := range parent.KeyRotations {
+		checkpoint.KeyRotations[oldPubKey] = newPubKey
+	}
+
+	for name, state := range parent.Deployments {
+		checkpoint.Deployments[name] = state
+	}
 	return checkpoint
 }
 
+// KeyRotation represent a validator key rotation waiting for the end of the epoch
+type KeyRotation struct {
+	OldPubKey string
+	NewPubKey string
+}
+
 // AddVerification add a valid verification to checkpoint's supLink
 func (c *Checkpoint) AddVerification(sourceHash bc.Hash, sourceHeight uint64, validatorOrder int, signature []byte) *types.SupLink {
 	for _, supLink := range c.SupLinks {
@@ -120,15 +142,17 @@ func (c *Checkpoint) Increase(block *types.Block) error {
 		return errIncreaseCheckpoint
 	}
 
-	if block.Height%consensus.ActiveNetParams.BlocksOfEpoch == 0 {
-		c.Status = Unjustified
-	}
-
 	c.Hash = block.Hash()
 	c.Height = block.Height
 	c.Timestamp = block.Timestamp
 	c.applyVotes(block)
 	c.applyValidatorReward(block)
+	c.applySignal(block)
+	if block.Height%consensus.ActiveNetParams.BlocksOfEpoch == 0 {
+		c.Status = Unjustified
+		c.applyKeyRotations()
+		c.applyDeployments()
+	}
 	return nil
 }
 
@@ -182,11 +206,21 @@ func (c *Checkpoint) AllValidators() []*Validator {
 	return validators
 }
 
+// ApplyVotes applies the votes of the blocks ending the epoch of the checkpoint,
+// it's used by the light node which only has the vote transactions of the
+// blocks
+func (c *Checkpoint) ApplyVotes(blocks []*types.Block) {
+	for _, block := range blocks {
+		c.applyVotes(block)
+	}
+	c.applyKeyRotations()
+}
+
 func (c *Checkpoint) applyVotes(block *types.Block) {
 	for _, tx := range block.Transactions {
 		for _, input := range tx.Inputs {
 			if vetoInput, ok := input.TypedInput.(*types.VetoInput); ok {
-				pubKey := hex.EncodeToString(vetoInput.Vote)
+				pubKey := c.votePubKey(hex.EncodeToString(vetoInput.Vote))
 				if c.Votes[pubKey] > vetoInput.Amount {
 					c.Votes[pubKey] -= vetoInput.Amount
 				} else {
@@ -196,13 +230,66 @@ func (c *Checkpoint) applyVotes(block *types.Block) {
 		}
 
 		for _, output := range tx.Outputs {
-			if voteOutput, ok := output.TypedOutput.(*types.VoteOutput); ok {
-				c.Votes[hex.EncodeToString(voteOutput.Vote)] += output.Amount
+			voteOutput, ok := output.TypedOutput.(*types.VoteOutput)
+			if !ok {
+				continue
+			}
+
+			c.Votes[c.votePubKey(hex.EncodeToString(voteOutput.Vote))] += output.Amount
+			if !types.IsKeyRotation(output.StateData) || !consensus.ActiveNetParams.IsFeatureActive(consensus.FeatureKeyRotation, block.Height) {
+				continue
+			}
+
+			if rotation, err := types.ParseKeyRotation(voteOutput.Vote, output.StateData); err == nil {
+				c.PendingKeyRotations = append(c.PendingKeyRotations, &KeyRotation{
+					OldPubKey: hex.EncodeToString(rotation.OldPubKey),
+					NewPubKey: hex.EncodeToString(rotation.NewPubKey),
+				})
 			}
 		}
 	}
 }
 
+// votePubKey return the pubKey which currently holds the votes for the voted pubKey
+func (c *Checkpoint) votePubKey(pubKey string) string {
+	if newPubKey, ok := c.KeyRotations[pubKey]; ok {
+		return newPubKey
+	}
+	return pubKey
+}
+
+// applyKeyRotations move the votes of the rotated validators to the new keys,
+// a rotated key can't be rotated again or be the target of a rotation since
+// it may be the compromised one
+func (c *Checkpoint) applyKeyRotations() {
+	if c.KeyRotations == nil {
+		c.KeyRotations = make(map[string]string)
+	}
+
+	for _, rotation := range c.PendingKeyRotations {
+		if _, ok := c.KeyRotations[rotation.OldPubKey]; ok {
+			continue
+		}
+
+		if _, ok := c.KeyRotations[rotation.NewPubKey]; ok {
+			continue
+		}
+
+		for pubKey, newPubKey := range c.KeyRotations {
+			if newPubKey == rotation.OldPubKey {
+				c.KeyRotations[pubKey] = rotation.NewPubKey
+			}
+		}
+
+		c.KeyRotations[rotation.OldPubKey] = rotation.NewPubKey
+		if num, ok := c.Votes[rotation.OldPubKey]; ok {
+			c.Votes[rotation.NewPubKey] += num
+			delete(c.Votes, rotation.OldPubKey)
+		}
+	}
+	c.PendingKeyRotations = nil
+}
+
 func getValidatorOrder(startTimestamp, blockTimestamp, numOfValidators uint64) uint64 {
 	// One round of product block time for all consensus nodes
 	roundBlockTime := numOfValidators * consensus.ActiveNetParams.BlockTimeInterval
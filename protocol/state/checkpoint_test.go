@@ -0,0 +1,137 @@
+package state
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/testutil"
+)
+
+func mockKeyRotationTx(oldXPrv chainkd.XPrv, newXPub chainkd.XPub, amount uint64) *types.Tx {
+	oldPubKey := oldXPrv.XPub()
+	signature := oldXPrv.Sign(types.KeyRotationMsg(oldPubKey[:], newXPub[:]))
+	return types.NewTx(types.TxData{
+		Outputs: []*types.TxOutput{
+			types.NewVoteOutput(*consensus.KUSKAssetID, amount, []byte{0x51}, oldPubKey[:], types.NewKeyRotationStateData(newXPub[:], signature)),
+		},
+	})
+}
+
+func mockVoteTx(xpub chainkd.XPub, voteAmount, vetoAmount uint64) *types.Tx {
+	txData := types.TxData{}
+	if voteAmount != 0 {
+		txData.Outputs = append(txData.Outputs, types.NewVoteOutput(*consensus.KUSKAssetID, voteAmount, []byte{0x51}, xpub[:], nil))
+	}
+	if vetoAmount != 0 {
+		txData.Inputs = append(txData.Inputs, types.NewVetoInput(nil, bc.Hash{V0: 1}, *consensus.KUSKAssetID, vetoAmount, 0, []byte{0x51}, xpub[:], nil))
+	}
+	return types.NewTx(txData)
+}
+
+func scheduleKeyRotation(height uint64) func() {
+	params := consensus.ActiveNetParams
+	consensus.ActiveNetParams.Forks = []consensus.Fork{{Name: "keyRotation", Height: height, Features: []string{consensus.FeatureKeyRotation}}}
+	return func() { consensus.ActiveNetParams = params }
+}
+
+func TestKeyRotationBeforeFork(t *testing.T) {
+	defer scheduleKeyRotation(10)()
+
+	xprv, _, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, newXPub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Checkpoint{Votes: map[string]uint64{}}
+	c.applyVotes(&types.Block{BlockHeader: types.BlockHeader{Height: 9}, Transactions: []*types.Tx{mockKeyRotationTx(xprv, newXPub, 10)}})
+	if len(c.PendingKeyRotations) != 0 {
+		t.Fatalf("got %d pending key rotations before the fork", len(c.PendingKeyRotations))
+	}
+
+	c.applyVotes(&types.Block{BlockHeader: types.BlockHeader{Height: 10}, Transactions: []*types.Tx{mockKeyRotationTx(xprv, newXPub, 10)}})
+	if len(c.PendingKeyRotations) != 1 {
+		t.Fatalf("got %d pending key rotations after the fork, want 1", len(c.PendingKeyRotations))
+	}
+}
+
+func TestApplyKeyRotations(t *testing.T) {
+	defer scheduleKeyRotation(0)()
+
+	var xprvs []chainkd.XPrv
+	var xpubs []chainkd.XPub
+	for i := 0; i < 4; i++ {
+		xprv, xpub, err := chainkd.NewXKeys(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		xprvs, xpubs = append(xprvs, xprv), append(xpubs, xpub)
+	}
+
+	pubKey := func(i int) string { return hex.EncodeToString(xpubs[i][:]) }
+	c := &Checkpoint{Votes: map[string]uint64{pubKey(0): 100, pubKey(1): 200}}
+
+	// a rotation signed by other key is ignored
+	forged := mockKeyRotationTx(xprvs[1], xpubs[2], 10)
+	forged.Outputs[0].TypedOutput.(*types.VoteOutput).Vote = xpubs[0][:]
+	c.applyVotes(&types.Block{Transactions: []*types.Tx{forged}})
+	if len(c.PendingKeyRotations) != 0 {
+		t.Fatalf("got %d pending key rotations from a forged signature", len(c.PendingKeyRotations))
+	}
+
+	c.applyVotes(&types.Block{Transactions: []*types.Tx{
+		mockKeyRotationTx(xprvs[0], xpubs[2], 10),
+		mockVoteTx(xpubs[0], 5, 0),
+	}})
+
+	// votes stay on the old key until the epoch ends
+	wantVotes := map[string]uint64{pubKey(0): 125, pubKey(1): 200}
+	if !testutil.DeepEqual(c.Votes, wantVotes) {
+		t.Fatalf("got votes %v before the epoch ends, want %v", c.Votes, wantVotes)
+	}
+
+	c.applyKeyRotations()
+	wantVotes = map[string]uint64{pubKey(1): 200, pubKey(2): 125}
+	if !testutil.DeepEqual(c.Votes, wantVotes) {
+		t.Fatalf("got votes %v after the epoch ends, want %v", c.Votes, wantVotes)
+	}
+
+	// the votes and vetoes for the rotated key go to the new key
+	c.applyVotes(&types.Block{Transactions: []*types.Tx{mockVoteTx(xpubs[0], 50, 0), mockVoteTx(xpubs[0], 0, 25)}})
+	wantVotes = map[string]uint64{pubKey(1): 200, pubKey(2): 150}
+	if !testutil.DeepEqual(c.Votes, wantVotes) {
+		t.Fatalf("got votes %v after voting the rotated key, want %v", c.Votes, wantVotes)
+	}
+
+	// the rotated key can't rotate again or be rotated to
+	c.applyVotes(&types.Block{Transactions: []*types.Tx{
+		mockKeyRotationTx(xprvs[0], xpubs[3], 10),
+		mockKeyRotationTx(xprvs[1], xpubs[0], 10),
+		mockKeyRotationTx(xprvs[2], xpubs[3], 10),
+	}})
+	c.applyKeyRotations()
+
+	wantVotes = map[string]uint64{pubKey(1): 210, pubKey(3): 170}
+	if !testutil.DeepEqual(c.Votes, wantVotes) {
+		t.Errorf("got votes %v after the second epoch, want %v", c.Votes, wantVotes)
+	}
+
+	wantRotations := map[string]string{pubKey(0): pubKey(3), pubKey(2): pubKey(3)}
+	if !testutil.DeepEqual(c.KeyRotations, wantRotations) {
+		t.Errorf("got key rotations %v, want %v", c.KeyRotations, wantRotations)
+	}
+
+	child := NewCheckpoint(c)
+	if !testutil.DeepEqual(child.KeyRotations, wantRotations) {
+		t.Errorf("got child key rotations %v, want %v", child.KeyRotations, wantRotations)
+	}
+}
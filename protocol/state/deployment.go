@@ -0,0 +1,126 @@
+package state
+
+import (
+	"kuskcore/consensus"
+	"kuskcore/protocol/bc/types"
+)
+
+// DeploymentState represent the state of a deployment at the end of an epoch
+type DeploymentState uint8
+
+const (
+	// DeploymentDefined means the start epoch of the deployment has not been reached
+	DeploymentDefined DeploymentState = iota
+
+	// DeploymentStarted means the validators are signalling the deployment
+	DeploymentStarted
+
+	// DeploymentLockedIn means enough validators have signalled the deployment, it will be active in the next epoch
+	DeploymentLockedIn
+
+	// DeploymentActive means the deployment has taken effect
+	DeploymentActive
+
+	// DeploymentFailed means the deployment is not locked in before timeout
+	DeploymentFailed
+)
+
+func (s DeploymentState) String() string {
+	switch s {
+	case DeploymentDefined:
+		return "defined"
+	case DeploymentStarted:
+		return "started"
+	case DeploymentLockedIn:
+		return "locked_in"
+	case DeploymentActive:
+		return "active"
+	case DeploymentFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// DeploymentState return the state of the deployment for the blocks of the next epoch
+func (c *Checkpoint) DeploymentState(name string) DeploymentState {
+	return c.Deployments[name]
+}
+
+// BlockVersion return the version of the block at the height, it signals all
+// the started deployments once the version bits are enabled by a fork
+func (c *Checkpoint) BlockVersion(height uint64) uint64 {
+	if !consensus.ActiveNetParams.IsFeatureActive(consensus.FeatureVersionBits, height) {
+		return 1
+	}
+
+	version := consensus.VersionBitsTopBits
+	for _, deployment := range consensus.ActiveNetParams.Deployments {
+		if c.DeploymentState(deployment.Name) == DeploymentStarted {
+			version |= deployment.Mask()
+		}
+	}
+
+	if version == consensus.VersionBitsTopBits {
+		return 1
+	}
+	return version
+}
+
+// applySignal record the deployments signalled by the validator produced the block
+func (c *Checkpoint) applySignal(block *types.Block) {
+	if c.Parent == nil || !consensus.IsVersionBits(block.Version) {
+		return
+	}
+
+	validator := c.Parent.GetValidator(block.Timestamp)
+	if validator == nil {
+		return
+	}
+
+	if c.Signals == nil {
+		c.Signals = make(map[string]uint64)
+	}
+	c.Signals[validator.PubKey] |= block.Version &^ consensus.VersionBitsTopMask
+}
+
+// applyDeployments update the state of deployments when the epoch ends
+func (c *Checkpoint) applyDeployments() {
+	if c.Deployments == nil {
+		c.Deployments = make(map[string]DeploymentState)
+	}
+
+	epoch := c.Height / consensus.ActiveNetParams.BlocksOfEpoch
+	for _, deployment := range consensus.ActiveNetParams.Deployments {
+		switch c.Deployments[deployment.Name] {
+		case DeploymentDefined:
+			if epoch >= deployment.StartEpoch {
+				c.Deployments[deployment.Name] = DeploymentStarted
+			}
+		case DeploymentStarted:
+			if c.isLockedIn(&deployment) {
+				c.Deployments[deployment.Name] = DeploymentLockedIn
+			} else if deployment.TimeoutEpoch != 0 && epoch >= deployment.TimeoutEpoch {
+				c.Deployments[deployment.Name] = DeploymentFailed
+			}
+		case DeploymentLockedIn:
+			c.Deployments[deployment.Name] = DeploymentActive
+		}
+	}
+	c.Signals = nil
+}
+
+func (c *Checkpoint) isLockedIn(deployment *consensus.Deployment) bool {
+	if c.Parent == nil {
+		return false
+	}
+
+	validators := c.Parent.EffectiveValidators()
+	signalled := uint64(0)
+	for pubKey := range validators {
+		if c.Signals[pubKey]&deployment.Mask() != 0 {
+			signalled++
+		}
+	}
+	return len(validators) != 0 && signalled*100 >= deployment.Threshold*uint64(len(validators))
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"testing"
+
+	"kuskcore/consensus"
+	"kuskcore/protocol/bc/types"
+)
+
+func TestApplyDeployments(t *testing.T) {
+	defer func(params consensus.Params) { consensus.ActiveNetParams = params }(consensus.ActiveNetParams)
+
+	consensus.ActiveNetParams.Forks = []consensus.Fork{{Name: "versionBits", Height: 1, Features: []string{consensus.FeatureVersionBits}}}
+	consensus.ActiveNetParams.Deployments = []consensus.Deployment{
+		{Name: "lockin", Bit: 1, StartEpoch: 1, Threshold: 66},
+		{Name: "timeout", Bit: 2, StartEpoch: 1, TimeoutEpoch: 2, Threshold: 66},
+	}
+
+	blocksOfEpoch := consensus.ActiveNetParams.BlocksOfEpoch
+	interval := consensus.ActiveNetParams.BlockTimeInterval
+	minVoteNum := consensus.ActiveNetParams.MinValidatorVoteNum
+	parent := &Checkpoint{
+		Status: Justified,
+		Votes:  map[string]uint64{"a": minVoteNum * 3, "b": minVoteNum * 2, "c": minVoteNum},
+	}
+
+	// the validators of order 0, 1, 2 produce the blocks with the timestamps
+	blockOf := func(order uint64, version uint64) *types.Block {
+		return &types.Block{BlockHeader: types.BlockHeader{Version: version, Timestamp: interval * (order + 1)}}
+	}
+
+	c := NewCheckpoint(parent)
+	c.Height = blocksOfEpoch
+	if version := c.BlockVersion(c.Height + 1); version != 1 {
+		t.Fatalf("got block version %x before deployments start, want 1", version)
+	}
+
+	c.applyDeployments()
+	if state := c.DeploymentState("lockin"); state != DeploymentStarted {
+		t.Fatalf("got state %s at the start epoch, want started", state)
+	}
+
+	wantVersion := consensus.VersionBitsTopBits | 1<<1 | 1<<2
+	if version := c.BlockVersion(c.Height + 1); version != wantVersion {
+		t.Fatalf("got block version %x, want %x", version, wantVersion)
+	}
+
+	if version := c.BlockVersion(0); version != 1 {
+		t.Fatalf("got block version %x before the version bits fork, want 1", version)
+	}
+
+	// one of three validators is not enough
+	c.applySignal(blockOf(0, consensus.VersionBitsTopBits|1<<1))
+	c.applySignal(blockOf(1, 1))
+	c.Height = blocksOfEpoch * 2
+	c.applyDeployments()
+	if state := c.DeploymentState("lockin"); state != DeploymentStarted {
+		t.Fatalf("got state %s with 1/3 signalled, want started", state)
+	}
+
+	if state := c.DeploymentState("timeout"); state != DeploymentFailed {
+		t.Fatalf("got state %s after timeout, want failed", state)
+	}
+
+	c.applySignal(blockOf(0, consensus.VersionBitsTopBits|1<<1))
+	c.applySignal(blockOf(2, consensus.VersionBitsTopBits|1<<1))
+	c.Height = blocksOfEpoch * 3
+	c.applyDeployments()
+	if state := c.DeploymentState("lockin"); state != DeploymentLockedIn {
+		t.Fatalf("got state %s with 2/3 signalled, want locked_in", state)
+	}
+
+	c.Height = blocksOfEpoch * 4
+	c.applyDeployments()
+	if state := c.DeploymentState("lockin"); state != DeploymentActive {
+		t.Fatalf("got state %s after locked in, want active", state)
+	}
+
+	if version := c.BlockVersion(c.Height + 1); version != 1 {
+		t.Fatalf("got block version %x after all deployments finished, want 1", version)
+	}
+}
@@ -17,6 +17,8 @@ type Store interface {
 	GetUtxo(*bc.Hash) (*storage.UtxoEntry, error)
 	GetMainChainHash(uint64) (*bc.Hash, error)
 	GetContract(hash [32]byte) ([]byte, error)
+	GetBlockFilter(*bc.Hash) ([]byte, error)
+	GetBlockFilterHeader(*bc.Hash) (*bc.Hash, error)
 
 	GetCheckpoint(*bc.Hash) (*Checkpoint, error)
 	CheckpointsFromNode(height uint64, hash *bc.Hash) ([]*Checkpoint, error)
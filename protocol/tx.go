@@ -4,6 +4,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"kuskcore/consensus/bcrp"
+	"kuskcore/database/storage"
 	"kuskcore/errors"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
@@ -11,8 +12,12 @@ import (
 	"kuskcore/protocol/validation"
 )
 
-// ErrBadTx is returned for transactions failing validation
-var ErrBadTx = errors.New("invalid transaction")
+var (
+	// ErrBadTx is returned for transactions failing validation
+	ErrBadTx = errors.New("invalid transaction")
+	// ErrMissingUtxo is returned when the spent output can't be found in the utxo set, the tx pool or the parents
+	ErrMissingUtxo = errors.New("spent output is not found or has been spent")
+)
 
 // GetTransactionsUtxo return all the utxos that related to the txs' inputs
 func (c *Chain) GetTransactionsUtxo(view *state.UtxoViewpoint, txs []*bc.Tx) error {
@@ -33,7 +38,7 @@ func (c *Chain) ValidateTx(tx *types.Tx) (bool, error) {
 	}
 
 	bh := c.BestBlockHeader()
-	gasStatus, err := validation.ValidateTx(tx.Tx, types.MapBlock(&types.Block{BlockHeader: *bh}), c.ProgramConverter)
+	gasStatus, err := validation.ValidateTx(tx.Tx, types.MapBlock(&types.Block{BlockHeader: *bh}), c.ProgramConverter, c.OutputHeight(bh.Height))
 	if err != nil {
 		log.WithFields(log.Fields{"module": logModule, "tx_id": tx.Tx.ID.String(), "error": err}).Info("transaction status fail")
 		c.txPool.AddErrCache(&tx.ID, err)
@@ -52,3 +57,113 @@ func (c *Chain) ProgramConverter(prog []byte) ([]byte, error) {
 
 	return c.store.GetContract(hash)
 }
+
+// OutputHeight returns the lookup of the height of the block which created
+// the output spent by the transaction going to the tx pool. The outputs created
+// by the transactions in the pool are unconfirmed, the height of the validating
+// block is used for them.
+func (c *Chain) OutputHeight(height uint64) validation.OutputHeightFunc {
+	return func(outputID bc.Hash) (uint64, error) {
+		if c.txPool.IsUtxoInPool(&outputID) {
+			return height, nil
+		}
+
+		entry, err := c.store.GetUtxo(&outputID)
+		if err != nil {
+			return 0, err
+		}
+
+		if entry.Spent {
+			return 0, ErrMissingUtxo
+		}
+		return entry.BlockHeight, nil
+	}
+}
+
+// viewOutputHeight returns the lookup of the height of the block which created
+// the output in the utxo view
+func viewOutputHeight(view *state.UtxoViewpoint) validation.OutputHeightFunc {
+	return func(outputID bc.Hash) (uint64, error) {
+		entry, ok := view.Entries[outputID]
+		if !ok {
+			return 0, errors.WithDetailf(ErrMissingUtxo, "output %x", outputID.Bytes())
+		}
+		return entry.BlockHeight, nil
+	}
+}
+
+// TraceTx validates the given transaction against the best block without
+// touching the tx pool, the instructions executed by the VM are sent to the tracer
+func (c *Chain) TraceTx(tx *types.Tx, tracer validation.TracerFunc) (*validation.GasState, error) {
+	bh := c.BestBlockHeader()
+	return validation.TraceTx(tx.Tx, types.MapBlock(&types.Block{BlockHeader: *bh}), c.ProgramConverter, c.OutputHeight(bh.Height), tracer)
+}
+
+// DryRunInput executes the program of the transaction input against the best
+// block, it returns the gas consumed by the program
+func (c *Chain) DryRunInput(tx *types.Tx, index int) (int64, error) {
+	bh := c.BestBlockHeader()
+	return validation.DryRunInput(tx.Tx, types.MapBlock(&types.Block{BlockHeader: *bh}), c.ProgramConverter, c.OutputHeight(bh.Height), index)
+}
+
+// SimulateTx validates the given transaction against the best block and the
+// utxo set without touching the tx pool. The parents are unconfirmed
+// transactions which create the outputs spent by the transaction, they are
+// validated in order before the transaction.
+func (c *Chain) SimulateTx(tx *types.Tx, parents []*types.Tx) (*validation.GasState, *validation.TxSimulation, error) {
+	bh := c.BestBlockHeader()
+	block := types.MapBlock(&types.Block{BlockHeader: *bh})
+	view := state.NewUtxoViewpoint()
+	for i, parent := range parents {
+		if err := c.applySimulatedTx(view, block, parent, nil); err != nil {
+			return nil, nil, errors.Wrapf(err, "applying parent %d", i)
+		}
+
+		if _, err := validation.ValidateTx(parent.Tx, block, c.ProgramConverter, viewOutputHeight(view)); err != nil {
+			return nil, nil, errors.Wrapf(err, "validating parent %d", i)
+		}
+	}
+
+	simulation := validation.NewTxSimulation()
+	if err := c.applySimulatedTx(view, block, tx, simulation); err != nil {
+		return nil, simulation, err
+	}
+
+	gasState, err := validation.SimulateTx(tx.Tx, block, c.ProgramConverter, viewOutputHeight(view), simulation)
+	return gasState, simulation, err
+}
+
+// applySimulatedTx checks the outputs spent by the transaction are available,
+// then applies the transaction to the view
+func (c *Chain) applySimulatedTx(view *state.UtxoViewpoint, block *bc.Block, tx *types.Tx, simulation *validation.TxSimulation) error {
+	if err := c.store.GetTransactionsUtxo(view, []*bc.Tx{tx.Tx}); err != nil {
+		return err
+	}
+
+	for i, input := range tx.Inputs {
+		if input.InputType() != types.SpendInputType && input.InputType() != types.VetoInputType {
+			continue
+		}
+
+		outputID, err := input.SpentOutputID()
+		if err != nil {
+			return err
+		}
+
+		if !view.HasUtxo(&outputID) && c.txPool.IsUtxoInPool(&outputID) {
+			view.Entries[outputID] = storage.NewUtxoEntry(storage.NormalUTXOType, block.Height, false)
+		}
+
+		if !view.CanSpend(&outputID) {
+			if simulation != nil {
+				simulation.FailedEntry = &tx.Tx.InputIDs[i]
+			}
+			return errors.WithDetailf(ErrMissingUtxo, "input %d spends output %x", i, outputID.Bytes())
+		}
+	}
+
+	if err := view.ApplyTransaction(block, tx.Tx); err != nil {
+		return errors.Sub(ErrBadTx, err)
+	}
+	return nil
+}
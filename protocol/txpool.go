@@ -187,6 +187,15 @@ func (tp *TxPool) IsTransactionInErrCache(txHash *bc.Hash) bool {
 	return ok
 }
 
+// IsUtxoInPool check whether the output is created by a transaction in the pool
+func (tp *TxPool) IsUtxoInPool(outputID *bc.Hash) bool {
+	tp.mtx.RLock()
+	defer tp.mtx.RUnlock()
+
+	_, ok := tp.utxo[*outputID]
+	return ok
+}
+
 // HaveTransaction IsTransactionInErrCache check is  transaction in errCache or pool
 func (tp *TxPool) HaveTransaction(txHash *bc.Hash) bool {
 	return tp.IsTransactionInPool(txHash) || tp.IsTransactionInErrCache(txHash)
@@ -9,6 +9,7 @@ import (
 	"kuskcore/consensus"
 	"kuskcore/crypto/ed25519/chainkd"
 	"kuskcore/errors"
+	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 	"kuskcore/protocol/state"
 )
@@ -20,6 +21,7 @@ var (
 	errBadBits               = errors.New("block bits is invalid")
 	errMismatchedBlock       = errors.New("mismatched block")
 	errMismatchedMerkleRoot  = errors.New("mismatched merkle root")
+	errMismatchedTxResults   = errors.New("mismatched transaction results")
 	errMisorderedBlockHeight = errors.New("misordered block height")
 	errOverBlockLimit        = errors.New("block's gas is over the limit")
 	errVersionRegression     = errors.New("version regression")
@@ -83,7 +85,18 @@ func checkoutRewardCoinbase(tx *types.Tx, checkpoint *state.Checkpoint) error {
 
 // ValidateBlockHeader check the block's header
 func ValidateBlockHeader(b, parent *types.BlockHeader, checkpoint *state.Checkpoint) error {
-	if b.Version != 1 {
+	if err := ValidateHeaderLink(b, parent); err != nil {
+		return err
+	}
+
+	return verifyBlockSignature(b, checkpoint)
+}
+
+// ValidateHeaderLink checks the block header is linked to the parent, the
+// signature isn't verified since the light node may not know the validators
+// of the epoch yet
+func ValidateHeaderLink(b, parent *types.BlockHeader) error {
+	if b.Version != 1 && !(consensus.IsVersionBits(b.Version) && consensus.ActiveNetParams.IsFeatureActive(consensus.FeatureVersionBits, b.Height)) {
 		return errors.WithDetailf(errVersionRegression, "previous block verson %d, current block version %d", parent.Version, b.Version)
 	}
 
@@ -95,10 +108,12 @@ func ValidateBlockHeader(b, parent *types.BlockHeader, checkpoint *state.Checkpo
 		return errors.WithDetailf(errMismatchedBlock, "previous block ID %x, current block wants %x", parentHash.Bytes(), b.PreviousBlockHash)
 	}
 
-	if err := checkBlockTime(b, parent); err != nil {
-		return err
-	}
+	return checkBlockTime(b, parent)
+}
 
+// ValidateBlockSignature verifies the block header is signed by the validator
+// of its time slot in the checkpoint
+func ValidateBlockSignature(b *types.BlockHeader, checkpoint *state.Checkpoint) error {
 	return verifyBlockSignature(b, checkpoint)
 }
 
@@ -119,7 +134,41 @@ func verifyBlockSignature(blockHeader *types.BlockHeader, checkpoint *state.Chec
 }
 
 // ValidateBlock validates a block and the transactions within.
-func ValidateBlock(b *types.Block, parent *types.BlockHeader, checkpoint *state.Checkpoint, converter ProgramConverterFunc) error {
+func ValidateBlock(b *types.Block, parent *types.BlockHeader, checkpoint *state.Checkpoint, converter ProgramConverterFunc, outputHeight OutputHeightFunc) error {
+	return validateBlock(b, parent, checkpoint, func(bcBlock *bc.Block) []*ValidateTxResult {
+		return ValidateTxs(bcBlock.Transactions, bcBlock, converter, outputHeight)
+	})
+}
+
+// ValidateBlockWithTxResults validates a block like ValidateBlock, but the
+// transactions are not validated again, the results of validating them ahead
+// of the block are used instead
+func ValidateBlockWithTxResults(b *types.Block, parent *types.BlockHeader, checkpoint *state.Checkpoint, results []*ValidateTxResult) error {
+	if len(results) != len(b.Transactions) {
+		return errors.WithDetailf(errMismatchedTxResults, "%d results of %d transactions", len(results), len(b.Transactions))
+	}
+
+	return validateBlock(b, parent, checkpoint, func(*bc.Block) []*ValidateTxResult {
+		return results
+	})
+}
+
+// PrevalidateBlock validates the transactions of the block without the
+// block's parent, the results are for ValidateBlockWithTxResults when the
+// block is applied. It's false if any of the transactions fails, the failure
+// may come from the state not reached yet so it's left to ValidateBlock.
+func PrevalidateBlock(b *types.Block, converter ProgramConverterFunc, outputHeight OutputHeightFunc) ([]*ValidateTxResult, bool) {
+	bcBlock := types.MapBlock(b)
+	results := ValidateTxs(bcBlock.Transactions, bcBlock, converter, outputHeight)
+	for _, result := range results {
+		if result.err != nil {
+			return nil, false
+		}
+	}
+	return results, true
+}
+
+func validateBlock(b *types.Block, parent *types.BlockHeader, checkpoint *state.Checkpoint, validateTxs func(*bc.Block) []*ValidateTxResult) error {
 	startTime := time.Now()
 	if err := ValidateBlockHeader(&b.BlockHeader, parent, checkpoint); err != nil {
 		return err
@@ -127,13 +176,14 @@ func ValidateBlock(b *types.Block, parent *types.BlockHeader, checkpoint *state.
 
 	bcBlock := types.MapBlock(b)
 	blockGasSum := uint64(0)
-	validateResults := ValidateTxs(bcBlock.Transactions, bcBlock, converter)
+	maxBlockGas := consensus.ActiveNetParams.ForkParams(b.Height).MaxBlockGas
+	validateResults := validateTxs(bcBlock)
 	for i, validateResult := range validateResults {
 		if validateResult.err != nil {
 			return errors.Wrapf(validateResult.err, "validate of transaction %d of %d", i, len(b.Transactions))
 		}
 
-		if blockGasSum += uint64(validateResult.gasStatus.GasUsed); blockGasSum > consensus.MaxBlockGas {
+		if blockGasSum += uint64(validateResult.gasStatus.GasUsed); blockGasSum > maxBlockGas {
 			return errOverBlockLimit
 		}
 	}
@@ -142,12 +192,8 @@ func ValidateBlock(b *types.Block, parent *types.BlockHeader, checkpoint *state.
 		return err
 	}
 
-	txMerkleRoot, err := types.TxMerkleRoot(bcBlock.Transactions)
-	if err != nil {
-		return errors.Wrap(err, "computing transaction id merkle root")
-	}
-	if txMerkleRoot != b.TransactionsMerkleRoot {
-		return errors.WithDetailf(errMismatchedMerkleRoot, "transaction id merkle root")
+	if err := validateTxMerkleRoot(bcBlock.Transactions, b.TransactionsMerkleRoot); err != nil {
+		return err
 	}
 
 	log.WithFields(log.Fields{
@@ -158,3 +204,22 @@ func ValidateBlock(b *types.Block, parent *types.BlockHeader, checkpoint *state.
 	}).Debug("finish validate block")
 	return nil
 }
+
+// ValidateBlockBody checks the transactions of the block match the merkle root
+// of its header, the body of a verified header is checked before the block is
+// fully validated
+func ValidateBlockBody(b *types.Block) error {
+	return validateTxMerkleRoot(types.MapBlock(b).Transactions, b.TransactionsMerkleRoot)
+}
+
+func validateTxMerkleRoot(txs []*bc.Tx, merkleRoot bc.Hash) error {
+	txMerkleRoot, err := types.TxMerkleRoot(txs)
+	if err != nil {
+		return errors.Wrap(err, "computing transaction id merkle root")
+	}
+
+	if txMerkleRoot != merkleRoot {
+		return errors.WithDetailf(errMismatchedMerkleRoot, "transaction id merkle root")
+	}
+	return nil
+}
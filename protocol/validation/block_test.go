@@ -7,9 +7,12 @@ import (
 	"time"
 
 	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
+	"kuskcore/errors"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 	"kuskcore/protocol/state"
+	"kuskcore/protocol/vm"
 )
 
 func TestCheckBlockTime(t *testing.T) {
@@ -256,3 +259,234 @@ func TestValidateBlockHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateVersionBitsHeader(t *testing.T) {
+	defer func(params consensus.Params) { consensus.ActiveNetParams = params }(consensus.ActiveNetParams)
+
+	parent := &types.BlockHeader{Version: 1, Height: 9, Timestamp: 1520000000}
+	header := &types.BlockHeader{
+		Version:           consensus.VersionBitsTopBits | 1<<2,
+		Height:            10,
+		Timestamp:         parent.Timestamp + consensus.ActiveNetParams.BlockTimeInterval,
+		PreviousBlockHash: parent.Hash(),
+	}
+
+	cases := []struct {
+		desc        string
+		forks       []consensus.Fork
+		deployments []consensus.Deployment
+		err         error
+	}{
+		{
+			desc: "no fork enables the version bits",
+			err:  errVersionRegression,
+		},
+		{
+			desc:        "the deployments are defined without the fork",
+			deployments: []consensus.Deployment{{Name: "test", Bit: 2, Threshold: 66}},
+			err:         errVersionRegression,
+		},
+		{
+			desc:  "the fork enabling the version bits is not activated yet",
+			forks: []consensus.Fork{{Name: "versionBits", Height: 11, Features: []string{consensus.FeatureVersionBits}}},
+			err:   errVersionRegression,
+		},
+		{
+			desc:  "the fork enabling the version bits is activated",
+			forks: []consensus.Fork{{Name: "versionBits", Height: 10, Features: []string{consensus.FeatureVersionBits}}},
+		},
+	}
+
+	for i, c := range cases {
+		consensus.ActiveNetParams.Forks = c.forks
+		consensus.ActiveNetParams.Deployments = c.deployments
+		if err := ValidateHeaderLink(header, parent); rootErr(err) != c.err {
+			t.Errorf("case %d (%s) got error %v, want %v", i, c.desc, err, c.err)
+		}
+	}
+}
+
+func TestValidateBlockBody(t *testing.T) {
+	tx := types.NewTx(types.TxData{
+		Version: 1,
+		Outputs: []*types.TxOutput{
+			types.NewOriginalTxOutput(*consensus.KUSKAssetID, 0, []byte{0x51}, nil),
+		},
+	})
+	merkleRoot, err := types.TxMerkleRoot([]*bc.Tx{tx.Tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		desc  string
+		block *types.Block
+		err   error
+	}{
+		{
+			desc: "transactions match the merkle root",
+			block: &types.Block{
+				BlockHeader:  types.BlockHeader{BlockCommitment: types.BlockCommitment{TransactionsMerkleRoot: merkleRoot}},
+				Transactions: []*types.Tx{tx},
+			},
+		},
+		{
+			desc: "transactions mismatch the merkle root",
+			block: &types.Block{
+				BlockHeader: types.BlockHeader{BlockCommitment: types.BlockCommitment{TransactionsMerkleRoot: merkleRoot}},
+			},
+			err: errMismatchedMerkleRoot,
+		},
+	}
+
+	for i, c := range cases {
+		if err := ValidateBlockBody(c.block); rootErr(err) != c.err {
+			t.Errorf("case %d (%s) got error %s, want %s", i, c.desc, err, c.err)
+		}
+	}
+}
+
+func scheduleTimeOpcodes() func() {
+	params := consensus.ActiveNetParams
+	consensus.ActiveNetParams.Forks = []consensus.Fork{{Name: "timeOpcodes", Features: []string{consensus.FeatureTimeOpcodes}}}
+	return func() { consensus.ActiveNetParams = params }
+}
+
+// mockOutputHeightTx spends an output which checks its height equals the argument
+func mockOutputHeightTx(t *testing.T, height byte) *types.Tx {
+	program, err := vm.Assemble("OUTPUTHEIGHT NUMEQUAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return types.NewTx(types.TxData{
+		Version:        1,
+		SerializedSize: 1,
+		Inputs: []*types.TxInput{
+			mockGasTxInput(),
+			types.NewSpendInput([][]byte{{height}}, *newHash(9), *consensus.KUSKAssetID, 1, 0, program, nil),
+		},
+		Outputs: []*types.TxOutput{
+			types.NewOriginalTxOutput(*consensus.KUSKAssetID, 1, []byte{0x6a}, nil),
+		},
+	})
+}
+
+func TestPrevalidateBlock(t *testing.T) {
+	defer scheduleTimeOpcodes()()
+
+	converter := func(prog []byte) ([]byte, error) { return nil, nil }
+	block := &types.Block{
+		BlockHeader:  types.BlockHeader{Height: 2},
+		Transactions: []*types.Tx{mockOutputHeightTx(t, 5)},
+	}
+
+	cases := []struct {
+		desc         string
+		outputHeight OutputHeightFunc
+		wantOk       bool
+	}{
+		{
+			desc:         "the spent output is at the checked height",
+			outputHeight: func(bc.Hash) (uint64, error) { return 5, nil },
+			wantOk:       true,
+		},
+		{
+			desc:         "the spent output is at another height",
+			outputHeight: func(bc.Hash) (uint64, error) { return 4, nil },
+		},
+		{
+			desc:         "the spent output is not confirmed",
+			outputHeight: func(bc.Hash) (uint64, error) { return 0, errors.New("output is not confirmed") },
+		},
+	}
+
+	for i, c := range cases {
+		results, ok := PrevalidateBlock(block, converter, c.outputHeight)
+		if ok != c.wantOk {
+			t.Errorf("case %d (%s) got ok %v, want %v", i, c.desc, ok, c.wantOk)
+			continue
+		}
+
+		if ok && (len(results) != len(block.Transactions) || results[0].err != nil) {
+			t.Errorf("case %d (%s) got unexpected results %v", i, c.desc, results)
+		}
+	}
+}
+
+func TestValidateBlockWithTxResults(t *testing.T) {
+	defer scheduleTimeOpcodes()()
+
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint := &state.Checkpoint{
+		Status: state.Justified,
+		Votes:  map[string]uint64{xpub.String(): consensus.ActiveNetParams.MinValidatorVoteNum},
+	}
+	now := uint64(time.Now().UnixNano() / 1e6)
+	parent := &types.BlockHeader{Version: 1, Height: 1, Timestamp: now - consensus.ActiveNetParams.BlockTimeInterval}
+	coinbase := types.NewTx(types.TxData{
+		Version:        1,
+		SerializedSize: 1,
+		Inputs:         []*types.TxInput{types.NewCoinbaseInput(nil)},
+		Outputs:        []*types.TxOutput{types.NewOriginalTxOutput(*consensus.KUSKAssetID, 0, []byte{0x51}, nil)},
+	})
+	block := &types.Block{
+		BlockHeader: types.BlockHeader{
+			Version:           1,
+			Height:            2,
+			Timestamp:         now,
+			PreviousBlockHash: parent.Hash(),
+		},
+		Transactions: []*types.Tx{coinbase, mockOutputHeightTx(t, 5)},
+	}
+
+	bcBlock := types.MapBlock(block)
+	if block.TransactionsMerkleRoot, err = types.TxMerkleRoot(bcBlock.Transactions); err != nil {
+		t.Fatal(err)
+	}
+	block.BlockWitness = xprv.Sign(block.Hash().Bytes())
+
+	converter := func(prog []byte) ([]byte, error) { return nil, nil }
+	outputHeight := func(height uint64) OutputHeightFunc {
+		return func(bc.Hash) (uint64, error) { return height, nil }
+	}
+	validResults, ok := PrevalidateBlock(block, converter, outputHeight(5))
+	if !ok {
+		t.Fatal("fail to prevalidate the block")
+	}
+
+	cases := []struct {
+		desc    string
+		results []*ValidateTxResult
+		err     error
+	}{
+		{
+			desc:    "the results of the prevalidated transactions",
+			results: validResults,
+		},
+		{
+			desc:    "the results miss a transaction",
+			results: validResults[:1],
+			err:     errMismatchedTxResults,
+		},
+		{
+			desc:    "the results of the failed transactions",
+			results: ValidateTxs(bcBlock.Transactions, bcBlock, converter, outputHeight(4)),
+			err:     vm.ErrFalseVMResult,
+		},
+	}
+
+	for i, c := range cases {
+		if err := ValidateBlockWithTxResults(block, parent, checkpoint, c.results); rootErr(err) != c.err {
+			t.Errorf("case %d (%s) got error %v, want %v", i, c.desc, err, c.err)
+		}
+	}
+
+	if err := ValidateBlock(block, parent, checkpoint, converter, outputHeight(5)); err != nil {
+		t.Errorf("got error %v validating the block, want nil", err)
+	}
+}
@@ -839,7 +839,7 @@ func TestValidateUglyTx(t *testing.T) {
 		_, err := validation.ValidateTx(bcTx, &bc.Block{
 			BlockHeader:  &bc.BlockHeader{Height: 1},
 			Transactions: []*bc.Tx{bcTx},
-		}, converter)
+		}, converter, nil)
 		if !c.err && err != nil {
 			t.Errorf("case #%d (%s) expect no error, got error %s", i, c.desc, err)
 		}
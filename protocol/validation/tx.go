@@ -10,6 +10,7 @@ import (
 	"kuskcore/errors"
 	"kuskcore/math/checked"
 	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
 	"kuskcore/protocol/vm"
 )
 
@@ -48,7 +49,7 @@ type GasState struct {
 	StorageGas int64
 }
 
-func (g *GasState) setGas(KUSKValue int64, txSize int64) error {
+func (g *GasState) setGas(KUSKValue int64, txSize int64, forkParams consensus.ForkParams) error {
 	if KUSKValue < 0 {
 		return errors.Wrap(ErrGasCalculate, "input KUSK is negative")
 	}
@@ -56,15 +57,15 @@ func (g *GasState) setGas(KUSKValue int64, txSize int64) error {
 	g.KUSKValue = uint64(KUSKValue)
 
 	var ok bool
-	if g.GasLeft, ok = checked.DivInt64(KUSKValue, consensus.VMGasRate); !ok {
+	if g.GasLeft, ok = checked.DivInt64(KUSKValue, forkParams.VMGasRate); !ok {
 		return errors.Wrap(ErrGasCalculate, "setGas calc gas amount")
 	}
 
-	if g.GasLeft > consensus.MaxGasAmount {
-		g.GasLeft = consensus.MaxGasAmount
+	if g.GasLeft > forkParams.MaxGasAmount {
+		g.GasLeft = forkParams.MaxGasAmount
 	}
 
-	if g.StorageGas, ok = checked.MulInt64(txSize, consensus.StorageGasRate); !ok {
+	if g.StorageGas, ok = checked.MulInt64(txSize, forkParams.StorageGasRate); !ok {
 		return errors.Wrap(ErrGasCalculate, "setGas calc tx storage gas")
 	}
 	return nil
@@ -104,17 +105,49 @@ func (g *GasState) updateUsage(gasLeft int64) error {
 // ProgramConverterFunc represent a func convert control program
 type ProgramConverterFunc func(prog []byte) ([]byte, error)
 
+// OutputHeightFunc returns the height of the block which confirmed the output
+type OutputHeightFunc func(outputID bc.Hash) (uint64, error)
+
 // validationState contains the context that must propagate through
 // the transaction graph when validating entries.
 type validationState struct {
-	block     *bc.Block
-	tx        *bc.Tx
-	gasStatus *GasState
-	entryID   bc.Hash              // The ID of the nearest enclosing entry
-	sourcePos uint64               // The source position, for validate ValueSources
-	destPos   uint64               // The destination position, for validate ValueDestinations
-	cache     map[bc.Hash]error    // Memoized per-entry validation results
-	converter ProgramConverterFunc // Program converter function
+	block        *bc.Block
+	tx           *bc.Tx
+	gasStatus    *GasState
+	entryID      bc.Hash              // The ID of the nearest enclosing entry
+	sourcePos    uint64               // The source position, for validate ValueSources
+	destPos      uint64               // The destination position, for validate ValueDestinations
+	cache        map[bc.Hash]error    // Memoized per-entry validation results
+	converter    ProgramConverterFunc // Program converter function
+	outputHeight OutputHeightFunc     // Look up the height of the spent output, may be nil
+	tracer       TracerFunc           // Receive the instructions executed by the VM, may be nil
+	simulation   *TxSimulation        // Record the execution details of the inputs, may be nil
+}
+
+// TracerFunc receives the instructions executed by the VM for the entry
+type TracerFunc func(entryID bc.Hash, step *vm.TraceStep)
+
+// TxSimulation records the execution details of the inputs when simulating a transaction
+type TxSimulation struct {
+	GasUsed     map[bc.Hash]int64 // Gas consumed by the program of each input
+	FailedEntry *bc.Hash          // The innermost input failing the validation, nil if none
+}
+
+// NewTxSimulation returns an empty TxSimulation
+func NewTxSimulation() *TxSimulation {
+	return &TxSimulation{GasUsed: make(map[bc.Hash]int64)}
+}
+
+func (vs *validationState) updateUsage(entryID bc.Hash, gasLeft int64) error {
+	gasUsed := vs.gasStatus.GasLeft - gasLeft
+	if err := vs.gasStatus.updateUsage(gasLeft); err != nil {
+		return err
+	}
+
+	if vs.simulation != nil {
+		vs.simulation.GasUsed[entryID] = gasUsed
+	}
+	return nil
 }
 
 func checkValid(vs *validationState, e bc.Entry) (err error) {
@@ -126,6 +159,14 @@ func checkValid(vs *validationState, e bc.Entry) (err error) {
 
 	defer func() {
 		vs.cache[entryID] = err
+		if err == nil || vs.simulation == nil || vs.simulation.FailedEntry != nil {
+			return
+		}
+
+		switch e.(type) {
+		case *bc.Spend, *bc.Issuance, *bc.VetoInput:
+			vs.simulation.FailedEntry = &entryID
+		}
 	}()
 
 	switch e := e.(type) {
@@ -173,7 +214,8 @@ func checkValid(vs *validationState, e bc.Entry) (err error) {
 
 		for assetID, amount := range parity {
 			if assetID == *consensus.KUSKAssetID {
-				if err = vs.gasStatus.setGas(amount, int64(vs.tx.SerializedSize)); err != nil {
+				forkParams := consensus.ActiveNetParams.ForkParams(vs.block.BlockHeader.GetHeight())
+				if err = vs.gasStatus.setGas(amount, int64(vs.tx.SerializedSize), forkParams); err != nil {
 					return err
 				}
 			} else if amount != 0 {
@@ -232,6 +274,12 @@ func checkValid(vs *validationState, e bc.Entry) (err error) {
 		if *e.Source.Value.AssetId != *consensus.KUSKAssetID {
 			return ErrVoteOutputAseet
 		}
+
+		if types.IsKeyRotation(e.StateData) && consensus.ActiveNetParams.IsFeatureActive(consensus.FeatureKeyRotation, vs.block.BlockHeader.GetHeight()) {
+			if _, err := types.ParseKeyRotation(e.Vote, e.StateData); err != nil {
+				return errors.Wrap(err, "checking key rotation")
+			}
+		}
 	case *bc.Issuance:
 		computedAssetID := e.WitnessAssetDefinition.ComputeAssetID()
 		if computedAssetID != *e.Value.AssetId {
@@ -242,7 +290,7 @@ func checkValid(vs *validationState, e bc.Entry) (err error) {
 		if err != nil {
 			return errors.Wrap(err, "checking issuance program")
 		}
-		if err = vs.gasStatus.updateUsage(gasLeft); err != nil {
+		if err = vs.updateUsage(entryID, gasLeft); err != nil {
 			return err
 		}
 
@@ -265,7 +313,7 @@ func checkValid(vs *validationState, e bc.Entry) (err error) {
 		if err != nil {
 			return errors.Wrap(err, "checking control program")
 		}
-		if err = vs.gasStatus.updateUsage(gasLeft); err != nil {
+		if err = vs.updateUsage(entryID, gasLeft); err != nil {
 			return err
 		}
 
@@ -307,7 +355,7 @@ func checkValid(vs *validationState, e bc.Entry) (err error) {
 		if err != nil {
 			return errors.Wrap(err, "checking control program")
 		}
-		if err = vs.gasStatus.updateUsage(gasLeft); err != nil {
+		if err = vs.updateUsage(entryID, gasLeft); err != nil {
 			return err
 		}
 
@@ -525,8 +573,74 @@ func checkTimeRange(tx *bc.Tx, block *bc.Block) error {
 }
 
 // ValidateTx validates a transaction.
-func ValidateTx(tx *bc.Tx, block *bc.Block, converter ProgramConverterFunc) (*GasState, error) {
-	if block.Version == 1 && tx.Version != 1 {
+func ValidateTx(tx *bc.Tx, block *bc.Block, converter ProgramConverterFunc, outputHeight OutputHeightFunc) (*GasState, error) {
+	return validateTx(tx, block, converter, outputHeight, nil, nil)
+}
+
+// TraceTx validates a transaction like ValidateTx, the instructions executed
+// for each entry are sent to the tracer
+func TraceTx(tx *bc.Tx, block *bc.Block, converter ProgramConverterFunc, outputHeight OutputHeightFunc, tracer TracerFunc) (*GasState, error) {
+	return validateTx(tx, block, converter, outputHeight, tracer, nil)
+}
+
+// SimulateTx validates a transaction like ValidateTx, the gas used by each
+// input and the failing input are recorded to the simulation
+func SimulateTx(tx *bc.Tx, block *bc.Block, converter ProgramConverterFunc, outputHeight OutputHeightFunc, simulation *TxSimulation) (*GasState, error) {
+	return validateTx(tx, block, converter, outputHeight, nil, simulation)
+}
+
+// DryRunInput executes the program of the input without validating the rest
+// of the transaction, it returns the gas consumed by the program
+func DryRunInput(tx *bc.Tx, block *bc.Block, converter ProgramConverterFunc, outputHeight OutputHeightFunc, index int) (int64, error) {
+	if index < 0 || index >= len(tx.InputIDs) {
+		return 0, errors.WithDetailf(ErrPosition, "input %d of %d inputs", index, len(tx.InputIDs))
+	}
+
+	var (
+		prog      *bc.Program
+		stateData [][]byte
+		args      [][]byte
+	)
+	entry := tx.Entries[tx.InputIDs[index]]
+	switch e := entry.(type) {
+	case *bc.Issuance:
+		prog, stateData, args = e.WitnessAssetDefinition.IssuanceProgram, [][]byte{}, e.WitnessArguments
+
+	case *bc.Spend:
+		spentOutput, err := tx.OriginalOutput(*e.SpentOutputId)
+		if err != nil {
+			return 0, err
+		}
+		prog, stateData, args = spentOutput.ControlProgram, spentOutput.StateData, e.WitnessArguments
+
+	case *bc.VetoInput:
+		voteOutput, err := tx.VoteOutput(*e.SpentOutputId)
+		if err != nil {
+			return 0, err
+		}
+		prog, stateData, args = voteOutput.ControlProgram, voteOutput.StateData, e.WitnessArguments
+
+	default:
+		return 0, errors.WithDetailf(ErrMissingField, "input %d has no program", index)
+	}
+
+	gasLimit := consensus.ActiveNetParams.ForkParams(block.BlockHeader.GetHeight()).MaxGasAmount
+	vs := &validationState{
+		block:        block,
+		tx:           tx,
+		entryID:      tx.InputIDs[index],
+		gasStatus:    &GasState{GasLeft: gasLimit},
+		cache:        make(map[bc.Hash]error),
+		converter:    converter,
+		outputHeight: outputHeight,
+	}
+
+	gasLeft, err := vm.Verify(NewTxVMContext(vs, entry, prog, stateData, args), gasLimit)
+	return gasLimit - gasLeft, err
+}
+
+func validateTx(tx *bc.Tx, block *bc.Block, converter ProgramConverterFunc, outputHeight OutputHeightFunc, tracer TracerFunc, simulation *TxSimulation) (*GasState, error) {
+	if (block.Version == 1 || consensus.IsVersionBits(block.Version)) && tx.Version != 1 {
 		return nil, errors.WithDetailf(ErrTxVersion, "block version %d, transaction version %d", block.Version, tx.Version)
 	}
 
@@ -543,12 +657,15 @@ func ValidateTx(tx *bc.Tx, block *bc.Block, converter ProgramConverterFunc) (*Ga
 	}
 
 	vs := &validationState{
-		block:     block,
-		tx:        tx,
-		entryID:   tx.ID,
-		gasStatus: &GasState{},
-		cache:     make(map[bc.Hash]error),
-		converter: converter,
+		block:        block,
+		tx:           tx,
+		entryID:      tx.ID,
+		gasStatus:    &GasState{},
+		cache:        make(map[bc.Hash]error),
+		converter:    converter,
+		outputHeight: outputHeight,
+		tracer:       tracer,
+		simulation:   simulation,
 	}
 
 	if err := checkValid(vs, tx.TxHeader); err != nil {
@@ -581,16 +698,16 @@ func (r *ValidateTxResult) GetError() error {
 	return r.err
 }
 
-func validateTxWorker(workCh chan *validateTxWork, resultCh chan *ValidateTxResult, wg *sync.WaitGroup, converter ProgramConverterFunc) {
+func validateTxWorker(workCh chan *validateTxWork, resultCh chan *ValidateTxResult, wg *sync.WaitGroup, converter ProgramConverterFunc, outputHeight OutputHeightFunc) {
 	for work := range workCh {
-		gasStatus, err := ValidateTx(work.tx, work.block, converter)
+		gasStatus, err := ValidateTx(work.tx, work.block, converter, outputHeight)
 		resultCh <- &ValidateTxResult{i: work.i, gasStatus: gasStatus, err: err}
 	}
 	wg.Done()
 }
 
 // ValidateTxs validates txs in async mode
-func ValidateTxs(txs []*bc.Tx, block *bc.Block, converter ProgramConverterFunc) []*ValidateTxResult {
+func ValidateTxs(txs []*bc.Tx, block *bc.Block, converter ProgramConverterFunc, outputHeight OutputHeightFunc) []*ValidateTxResult {
 	txSize := len(txs)
 	validateWorkerNum := runtime.NumCPU()
 	//init the goroutine validate worker
@@ -599,7 +716,7 @@ func ValidateTxs(txs []*bc.Tx, block *bc.Block, converter ProgramConverterFunc)
 	resultCh := make(chan *ValidateTxResult, txSize)
 	for i := 0; i <= validateWorkerNum && i < txSize; i++ {
 		wg.Add(1)
-		go validateTxWorker(workCh, resultCh, &wg, converter)
+		go validateTxWorker(workCh, resultCh, &wg, converter, outputHeight)
 	}
 
 	//sent the works
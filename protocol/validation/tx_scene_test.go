@@ -235,7 +235,7 @@ func TestValidateTx(t *testing.T) {
 	}
 
 	for i, c := range cases {
-		if _, err := ValidateTx(types.MapTx(c.txData), mockBlock(), converter); rootErr(err) != c.err {
+		if _, err := ValidateTx(types.MapTx(c.txData), mockBlock(), converter, nil); rootErr(err) != c.err {
 			t.Errorf("case #%d (%s) got error %s, want %s; validationState is:\n", i, c.desc, err, c.err)
 		}
 	}
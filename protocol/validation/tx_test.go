@@ -39,7 +39,7 @@ func TestGasStatus(t *testing.T) {
 				KUSKValue: 10000,
 			},
 			f: func(input *GasState) error {
-				return input.setGas(10000, 0)
+				return input.setGas(10000, 0, consensus.ActiveNetParams.ForkParams(0))
 			},
 			err: nil,
 		},
@@ -55,7 +55,7 @@ func TestGasStatus(t *testing.T) {
 				KUSKValue: 0,
 			},
 			f: func(input *GasState) error {
-				return input.setGas(-10000, 0)
+				return input.setGas(-10000, 0, consensus.ActiveNetParams.ForkParams(0))
 			},
 			err: ErrGasCalculate,
 		},
@@ -71,7 +71,7 @@ func TestGasStatus(t *testing.T) {
 				KUSKValue: 80000000000,
 			},
 			f: func(input *GasState) error {
-				return input.setGas(80000000000, 0)
+				return input.setGas(80000000000, 0, consensus.ActiveNetParams.ForkParams(0))
 			},
 			err: nil,
 		},
@@ -87,7 +87,7 @@ func TestGasStatus(t *testing.T) {
 				KUSKValue: math.MaxInt64,
 			},
 			f: func(input *GasState) error {
-				return input.setGas(math.MaxInt64, 0)
+				return input.setGas(math.MaxInt64, 0, consensus.ActiveNetParams.ForkParams(0))
 			},
 			err: nil,
 		},
@@ -298,7 +298,7 @@ func TestOverflow(t *testing.T) {
 
 	for i, c := range cases {
 		tx := newTx(c.inputs, c.outputs)
-		if _, err := ValidateTx(tx, mockBlock(), converter); rootErr(err) != c.err {
+		if _, err := ValidateTx(tx, mockBlock(), converter, nil); rootErr(err) != c.err {
 			t.Fatalf("case %d test failed, want %s, have %s", i, c.err, rootErr(err))
 		}
 	}
@@ -805,7 +805,7 @@ func TestCoinbase(t *testing.T) {
 	}
 
 	for i, c := range cases {
-		_, err := ValidateTx(c.block.Transactions[c.txIndex], c.block, converter)
+		_, err := ValidateTx(c.block.Transactions[c.txIndex], c.block, converter, nil)
 		if rootErr(err) != c.err {
 			t.Errorf("#%d got error %s, want %s", i, err, c.err)
 		}
@@ -928,7 +928,7 @@ func TestDoubleSpend(t *testing.T) {
 	}
 
 	for i, c := range cases {
-		_, err := ValidateTx(tx.Tx, c.block, converter)
+		_, err := ValidateTx(tx.Tx, c.block, converter, nil)
 		if rootErr(err) != c.err {
 			t.Errorf("#%d got error %s, want %s", i, err, c.err)
 		}
@@ -980,7 +980,7 @@ func TestTimeRange(t *testing.T) {
 
 	for i, c := range cases {
 		tx.TimeRange = c.timeRange
-		if _, err := ValidateTx(tx, block, converter); (err != nil) != c.err {
+		if _, err := ValidateTx(tx, block, converter, nil); (err != nil) != c.err {
 			t.Errorf("#%d got error %t, want %t", i, !c.err, c.err)
 		}
 	}
@@ -1023,10 +1023,20 @@ func TestValidateTxVersion(t *testing.T) {
 			},
 			err: ErrTxVersion,
 		},
+		{
+			desc: "tx version greater than 1 in the block signalling deployments",
+			block: &bc.Block{
+				BlockHeader: &bc.BlockHeader{Version: consensus.VersionBitsTopBits | 1},
+				Transactions: []*bc.Tx{
+					{TxHeader: &bc.TxHeader{Version: 2}},
+				},
+			},
+			err: ErrTxVersion,
+		},
 	}
 
 	for i, c := range cases {
-		if _, err := ValidateTx(c.block.Transactions[0], c.block, converter); rootErr(err) != c.err {
+		if _, err := ValidateTx(c.block.Transactions[0], c.block, converter, nil); rootErr(err) != c.err {
 			t.Errorf("case #%d (%s) got error %t, want %t", i, c.desc, err, c.err)
 		}
 	}
@@ -1207,3 +1217,124 @@ func getMuxID(tx *bc.Tx) *bc.Hash {
 	}
 	return nil
 }
+
+func TestTraceTx(t *testing.T) {
+	converter := func(prog []byte) ([]byte, error) { return nil, nil }
+	tx := types.MapTx(&types.TxData{
+		SerializedSize: 1,
+		Inputs: []*types.TxInput{
+			mockGasTxInput(),
+			types.NewSpendInput([][]byte{{2}}, *newHash(9), *consensus.KUSKAssetID, 1, 0, []byte{byte(vm.OP_3), byte(vm.OP_NUMEQUAL)}, nil),
+		},
+		Outputs: []*types.TxOutput{
+			types.NewOriginalTxOutput(*consensus.KUSKAssetID, 1, []byte{0x6a}, nil),
+		},
+	})
+
+	steps := map[bc.Hash][]*vm.TraceStep{}
+	_, err := TraceTx(tx, mockBlock(), converter, nil, func(entryID bc.Hash, step *vm.TraceStep) {
+		steps[entryID] = append(steps[entryID], step)
+	})
+	if rootErr(err) != vm.ErrFalseVMResult {
+		t.Fatalf("got error %v, want %v", err, vm.ErrFalseVMResult)
+	}
+
+	if got := steps[tx.InputIDs[0]]; len(got) != 1 || got[0].Op != vm.OP_TRUE {
+		t.Errorf("got %d steps for the gas input, want 1", len(got))
+	}
+
+	got := steps[tx.InputIDs[1]]
+	if len(got) != 2 || got[1].Op != vm.OP_NUMEQUAL || !testutil.DeepEqual(got[1].DataStack, [][]byte{{}}) {
+		t.Errorf("got unexpected steps for the contract input %v", got)
+	}
+}
+
+func TestSimulateTx(t *testing.T) {
+	converter := func(prog []byte) ([]byte, error) { return nil, nil }
+	cases := []struct {
+		program    []byte
+		wantErr    error
+		wantFailed bool
+	}{
+		{
+			program: []byte{byte(vm.OP_2), byte(vm.OP_NUMEQUAL)},
+		},
+		{
+			program:    []byte{byte(vm.OP_3), byte(vm.OP_NUMEQUAL)},
+			wantErr:    vm.ErrFalseVMResult,
+			wantFailed: true,
+		},
+	}
+
+	for i, c := range cases {
+		tx := types.MapTx(&types.TxData{
+			SerializedSize: 1,
+			Inputs: []*types.TxInput{
+				mockGasTxInput(),
+				types.NewSpendInput([][]byte{{2}}, *newHash(9), *consensus.KUSKAssetID, 1, 0, c.program, nil),
+			},
+			Outputs: []*types.TxOutput{
+				types.NewOriginalTxOutput(*consensus.KUSKAssetID, 1, []byte{0x6a}, nil),
+			},
+		})
+
+		simulation := NewTxSimulation()
+		if _, err := SimulateTx(tx, mockBlock(), converter, nil, simulation); rootErr(err) != c.wantErr {
+			t.Fatalf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+
+		if gasUsed := simulation.GasUsed[tx.InputIDs[0]]; gasUsed <= 0 {
+			t.Errorf("case %d: got gas used %d for the gas input, want positive", i, gasUsed)
+		}
+
+		if c.wantFailed != (simulation.FailedEntry != nil) || (c.wantFailed && *simulation.FailedEntry != tx.InputIDs[1]) {
+			t.Errorf("case %d: got failed entry %v, want the contract input failed %v", i, simulation.FailedEntry, c.wantFailed)
+		}
+
+		if gasUsed := simulation.GasUsed[tx.InputIDs[1]]; !c.wantFailed && gasUsed <= 0 {
+			t.Errorf("case %d: got gas used %d for the contract input, want positive", i, gasUsed)
+		}
+	}
+}
+
+func TestDryRunInput(t *testing.T) {
+	converter := func(prog []byte) ([]byte, error) { return nil, nil }
+	cases := []struct {
+		program []byte
+		wantErr error
+	}{
+		{
+			program: []byte{byte(vm.OP_2), byte(vm.OP_NUMEQUAL)},
+		},
+		{
+			program: []byte{byte(vm.OP_3), byte(vm.OP_NUMEQUAL)},
+			wantErr: vm.ErrFalseVMResult,
+		},
+	}
+
+	for i, c := range cases {
+		// the transaction is unbalanced, only the program of the input is executed
+		tx := types.MapTx(&types.TxData{
+			SerializedSize: 1,
+			Inputs: []*types.TxInput{
+				types.NewSpendInput([][]byte{{2}}, *newHash(9), *consensus.KUSKAssetID, 1, 0, c.program, nil),
+			},
+			Outputs: []*types.TxOutput{
+				types.NewOriginalTxOutput(*consensus.KUSKAssetID, 2, []byte{0x6a}, nil),
+			},
+		})
+
+		gasUsed, err := DryRunInput(tx, mockBlock(), converter, nil, 0)
+		if rootErr(err) != c.wantErr {
+			t.Fatalf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+
+		if gasUsed <= 0 {
+			t.Errorf("case %d: got gas used %d, want positive", i, gasUsed)
+		}
+	}
+
+	if _, err := DryRunInput(types.MapTx(&types.TxData{}), mockBlock(), converter, nil, 0); rootErr(err) != ErrPosition {
+		t.Errorf("got error %v for the missing input, want %v", err, ErrPosition)
+	}
+}
@@ -3,6 +3,7 @@ package validation
 import (
 	"bytes"
 
+	"kuskcore/consensus"
 	"kuskcore/consensus/bcrp"
 	"kuskcore/consensus/segwit"
 	"kuskcore/crypto/sha3pool"
@@ -14,15 +15,17 @@ import (
 // NewTxVMContext generates the vm.Context for BVM
 func NewTxVMContext(vs *validationState, entry bc.Entry, prog *bc.Program, stateData [][]byte, args [][]byte) *vm.Context {
 	var (
-		tx          = vs.tx
-		blockHeight = vs.block.BlockHeader.GetHeight()
-		numResults  = uint64(len(tx.ResultIds))
-		entryID     = bc.EntryID(entry) // TODO(bobg): pass this in, don't recompute it
+		tx             = vs.tx
+		blockHeight    = vs.block.BlockHeader.GetHeight()
+		blockTimestamp = vs.block.BlockHeader.GetTimestamp()
+		numResults     = uint64(len(tx.ResultIds))
+		entryID        = bc.EntryID(entry) // TODO(bobg): pass this in, don't recompute it
 
 		assetID       *[]byte
 		amount        *uint64
 		destPos       *uint64
 		spentOutputID *[]byte
+		outputHeight  func() (uint64, error)
 	)
 
 	switch e := entry.(type) {
@@ -40,6 +43,12 @@ func NewTxVMContext(vs *validationState, entry bc.Entry, prog *bc.Program, state
 		destPos = &e.WitnessDestination.Position
 		s := e.SpentOutputId.Bytes()
 		spentOutputID = &s
+		if vs.outputHeight != nil {
+			outputID := *e.SpentOutputId
+			outputHeight = func() (uint64, error) {
+				return vs.outputHeight(outputID)
+			}
+		}
 	}
 
 	var txSigHash *[]byte
@@ -72,18 +81,29 @@ func NewTxVMContext(vs *validationState, entry bc.Entry, prog *bc.Program, state
 
 		EntryID: entryID.Bytes(),
 
-		TxVersion:   &tx.Version,
-		BlockHeight: &blockHeight,
-
-		TxSigHash:     txSigHashFn,
-		NumResults:    &numResults,
-		AssetID:       assetID,
-		Amount:        amount,
-		DestPos:       destPos,
-		SpentOutputID: spentOutputID,
-		CheckOutput:   ec.checkOutput,
+		TxVersion:      &tx.Version,
+		BlockHeight:    &blockHeight,
+		BlockTimestamp: &blockTimestamp,
+
+		IsFeatureActive: func(feature string) bool {
+			return consensus.ActiveNetParams.IsFeatureActive(feature, blockHeight)
+		},
+
+		TxSigHash:         txSigHashFn,
+		NumResults:        &numResults,
+		AssetID:           assetID,
+		Amount:            amount,
+		DestPos:           destPos,
+		SpentOutputID:     spentOutputID,
+		SpentOutputHeight: outputHeight,
+		CheckOutput:       ec.checkOutput,
 	}
 
+	if vs.tracer != nil {
+		result.Tracer = func(step *vm.TraceStep) {
+			vs.tracer(entryID, step)
+		}
+	}
 	return result
 }
 
@@ -19,8 +19,9 @@ type Context struct {
 
 	// TxVersion must be present when verifying transaction components
 	// (such as spends and issuances).
-	TxVersion   *uint64
-	BlockHeight *uint64
+	TxVersion      *uint64
+	BlockHeight    *uint64
+	BlockTimestamp *uint64
 
 	// Fields below this point are required by particular opcodes when
 	// verifying transaction components.
@@ -31,6 +32,27 @@ type Context struct {
 	DestPos       *uint64
 	SpentOutputID *[]byte
 
+	// SpentOutputHeight returns the height of the block which confirmed
+	// the spent output, it's only present when verifying a spend.
+	SpentOutputHeight func() (uint64, error)
+
+	// IsFeatureActive reports whether a fork feature is active for the
+	// block being verified, a nil func means no feature is active.
+	IsFeatureActive func(feature string) bool
+
 	TxSigHash   func() []byte
 	CheckOutput func(index uint64, amount uint64, assetID []byte, vmVersion uint64, code []byte, state [][]byte, expansion bool) (bool, error)
+
+	// Tracer - if non-nil - receives every instruction executed for
+	// this context, including the ones of the predicates it spawns.
+	Tracer func(step *TraceStep)
+}
+
+func (c *Context) isOpEnabled(op Op) bool {
+	feature, ok := opFeatures[op]
+	if !ok {
+		return true
+	}
+
+	return c.IsFeatureActive != nil && c.IsFeatureActive(feature)
 }
@@ -122,3 +122,32 @@ func opBlockHeight(vm *virtualMachine) error {
 
 	return vm.pushBigInt(uint256.NewInt(*vm.context.BlockHeight), true)
 }
+
+func opBlockTime(vm *virtualMachine) error {
+	if err := vm.applyCost(1); err != nil {
+		return err
+	}
+
+	if vm.context.BlockTimestamp == nil {
+		return ErrContext
+	}
+
+	return vm.pushBigInt(uint256.NewInt(*vm.context.BlockTimestamp), true)
+}
+
+func opOutputHeight(vm *virtualMachine) error {
+	if err := vm.applyCost(1); err != nil {
+		return err
+	}
+
+	if vm.context.SpentOutputHeight == nil {
+		return ErrContext
+	}
+
+	height, err := vm.context.SpentOutputHeight()
+	if err != nil {
+		return err
+	}
+
+	return vm.pushBigInt(uint256.NewInt(height), true)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 
+	"kuskcore/consensus"
 	"kuskcore/errors"
 	"kuskcore/testutil"
 )
@@ -269,3 +270,50 @@ func TestIntrospectionOps(t *testing.T) {
 }
 
 func uint64ptr(n uint64) *uint64 { return &n }
+
+func TestTimeOpcodes(t *testing.T) {
+	blockTimestamp := uint64(1600000000000)
+	isActive := func(feature string) bool { return feature == consensus.FeatureTimeOpcodes }
+	outputHeight := func() (uint64, error) { return 100, nil }
+
+	cases := []struct {
+		prog    string
+		context *Context
+		wantErr error
+	}{
+		{
+			prog:    "BLOCKTIME 1600000000000 NUMEQUAL",
+			context: &Context{BlockTimestamp: &blockTimestamp, IsFeatureActive: isActive},
+		},
+		{
+			prog:    "OUTPUTHEIGHT 100 NUMEQUAL",
+			context: &Context{SpentOutputHeight: outputHeight, IsFeatureActive: isActive},
+		},
+		{
+			prog:    "OUTPUTHEIGHT",
+			context: &Context{IsFeatureActive: isActive},
+			wantErr: ErrContext,
+		},
+		{
+			// the opcodes are NOPs before the feature is activated
+			prog:    "1 BLOCKTIME OUTPUTHEIGHT",
+			context: &Context{BlockTimestamp: &blockTimestamp, SpentOutputHeight: outputHeight},
+		},
+	}
+
+	for i, c := range cases {
+		prog, err := Assemble(c.prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vm := &virtualMachine{runLimit: 50000, program: prog, context: c.context}
+		if err = vm.run(); err == nil && vm.falseResult() {
+			err = ErrFalseVMResult
+		}
+
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+	}
+}
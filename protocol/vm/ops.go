@@ -6,6 +6,7 @@ import (
 	"math"
 	"reflect"
 
+	"kuskcore/consensus"
 	"kuskcore/errors"
 	"kuskcore/math/checked"
 )
@@ -199,14 +200,16 @@ const (
 	OP_CHECKMULTISIG Op = 0xad
 	OP_TXSIGHASH     Op = 0xae
 
-	OP_CHECKOUTPUT Op = 0xc1
-	OP_ASSET       Op = 0xc2
-	OP_AMOUNT      Op = 0xc3
-	OP_PROGRAM     Op = 0xc4
-	OP_INDEX       Op = 0xc9
-	OP_ENTRYID     Op = 0xca
-	OP_OUTPUTID    Op = 0xcb
-	OP_BLOCKHEIGHT Op = 0xcd
+	OP_CHECKOUTPUT  Op = 0xc1
+	OP_ASSET        Op = 0xc2
+	OP_AMOUNT       Op = 0xc3
+	OP_PROGRAM      Op = 0xc4
+	OP_INDEX        Op = 0xc9
+	OP_ENTRYID      Op = 0xca
+	OP_OUTPUTID     Op = 0xcb
+	OP_BLOCKHEIGHT  Op = 0xcd
+	OP_BLOCKTIME    Op = 0xce
+	OP_OUTPUTHEIGHT Op = 0xcf
 )
 
 type opInfo struct {
@@ -301,14 +304,16 @@ var (
 		OP_CHECKMULTISIG: {OP_CHECKMULTISIG, "CHECKMULTISIG", opCheckMultiSig},
 		OP_TXSIGHASH:     {OP_TXSIGHASH, "TXSIGHASH", opTxSigHash},
 
-		OP_CHECKOUTPUT: {OP_CHECKOUTPUT, "CHECKOUTPUT", opCheckOutput},
-		OP_ASSET:       {OP_ASSET, "ASSET", opAsset},
-		OP_AMOUNT:      {OP_AMOUNT, "AMOUNT", opAmount},
-		OP_PROGRAM:     {OP_PROGRAM, "PROGRAM", opProgram},
-		OP_INDEX:       {OP_INDEX, "INDEX", opIndex},
-		OP_ENTRYID:     {OP_ENTRYID, "ENTRYID", opEntryID},
-		OP_OUTPUTID:    {OP_OUTPUTID, "OUTPUTID", opOutputID},
-		OP_BLOCKHEIGHT: {OP_BLOCKHEIGHT, "BLOCKHEIGHT", opBlockHeight},
+		OP_CHECKOUTPUT:  {OP_CHECKOUTPUT, "CHECKOUTPUT", opCheckOutput},
+		OP_ASSET:        {OP_ASSET, "ASSET", opAsset},
+		OP_AMOUNT:       {OP_AMOUNT, "AMOUNT", opAmount},
+		OP_PROGRAM:      {OP_PROGRAM, "PROGRAM", opProgram},
+		OP_INDEX:        {OP_INDEX, "INDEX", opIndex},
+		OP_ENTRYID:      {OP_ENTRYID, "ENTRYID", opEntryID},
+		OP_OUTPUTID:     {OP_OUTPUTID, "OUTPUTID", opOutputID},
+		OP_BLOCKHEIGHT:  {OP_BLOCKHEIGHT, "BLOCKHEIGHT", opBlockHeight},
+		OP_BLOCKTIME:    {OP_BLOCKTIME, "BLOCKTIME", opBlockTime},
+		OP_OUTPUTHEIGHT: {OP_OUTPUTHEIGHT, "OUTPUTHEIGHT", opOutputHeight},
 	}
 
 	opsByName map[string]opInfo
@@ -452,6 +457,13 @@ func ParseProgram(prog []byte) ([]Instruction, error) {
 
 var isExpansion [256]bool
 
+// opFeatures holds the opcodes introduced by a scheduled fork, such an opcode
+// is handled as an expansion opcode until its feature is active
+var opFeatures = map[Op]string{
+	OP_BLOCKTIME:    consensus.FeatureTimeOpcodes,
+	OP_OUTPUTHEIGHT: consensus.FeatureTimeOpcodes,
+}
+
 func init() {
 	for i := 1; i <= 75; i++ {
 		ops[i] = opInfo{Op(i), fmt.Sprintf("DATA_%d", i), opPushdata}
@@ -34,9 +34,23 @@ type virtualMachine struct {
 }
 
 // TraceOut - if non-nil - will receive trace output during
-// execution.
+// execution. It is shared by all the running VMs, use Context.Tracer
+// to trace a single verification.
 var TraceOut io.Writer
 
+// TraceStep is an instruction executed by the VM, the stacks and the run
+// limit are the ones after the execution, Err is set if the instruction failed
+type TraceStep struct {
+	Depth     int
+	PC        uint32
+	Op        Op
+	Data      []byte
+	DataStack [][]byte
+	AltStack  [][]byte
+	RunLimit  int64
+	Err       error
+}
+
 // Verify program by running VM
 func Verify(context *Context, gasLimit int64) (gasLeft int64, err error) {
 	defer func() {
@@ -94,13 +108,16 @@ func (vm *virtualMachine) run() error {
 	return nil
 }
 
-func (vm *virtualMachine) step() error {
+func (vm *virtualMachine) step() (err error) {
 	inst, err := ParseOp(vm.program, vm.pc)
 	if err != nil {
 		return err
 	}
 
 	vm.nextPC = vm.pc + inst.Len
+	if vm.context != nil && vm.context.Tracer != nil {
+		defer func(pc uint32) { vm.trace(pc, inst, err) }(vm.pc)
+	}
 
 	if TraceOut != nil {
 		opname := inst.Op.String()
@@ -111,7 +128,7 @@ func (vm *virtualMachine) step() error {
 		fmt.Fprint(TraceOut, "\n")
 	}
 
-	if isExpansion[inst.Op] {
+	if isExpansion[inst.Op] || !vm.context.isOpEnabled(inst.Op) {
 		if vm.expansionReserved {
 			return ErrDisallowedOpcode
 		}
@@ -140,6 +157,27 @@ func (vm *virtualMachine) step() error {
 	return nil
 }
 
+func (vm *virtualMachine) trace(pc uint32, inst Instruction, err error) {
+	copyStack := func(stack [][]byte) [][]byte {
+		result := make([][]byte, 0, len(stack))
+		for _, item := range stack {
+			result = append(result, append([]byte{}, item...))
+		}
+		return result
+	}
+
+	vm.context.Tracer(&TraceStep{
+		Depth:     vm.depth,
+		PC:        pc,
+		Op:        inst.Op,
+		Data:      inst.Data,
+		DataStack: copyStack(vm.dataStack),
+		AltStack:  copyStack(vm.altStack),
+		RunLimit:  vm.runLimit,
+		Err:       err,
+	})
+}
+
 func (vm *virtualMachine) pushDataStack(data []byte, deferred bool) error {
 	cost := 8 + int64(len(data))
 	if deferred {
@@ -427,3 +427,70 @@ func TestVerifyBlockHeaderQuickCheck(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestContextTracer(t *testing.T) {
+	cases := []struct {
+		prog      string
+		wantOps   []Op
+		wantDepth []int
+		wantStack [][]byte
+		wantErr   error
+	}{
+		{
+			prog:      "2 3 ADD",
+			wantOps:   []Op{OP_2, OP_3, OP_ADD},
+			wantDepth: []int{0, 0, 0},
+			wantStack: [][]byte{{5}},
+		},
+		{
+			prog:      "1 VERIFY 0 VERIFY 1",
+			wantOps:   []Op{OP_1, OP_VERIFY, OP_0, OP_VERIFY},
+			wantDepth: []int{0, 0, 0, 0},
+			wantStack: [][]byte{},
+			wantErr:   ErrVerifyFailed,
+		},
+		{
+			prog:      "0 0x51 0 CHECKPREDICATE",
+			wantOps:   []Op{OP_0, OP_DATA_1, OP_0, OP_1, OP_CHECKPREDICATE},
+			wantDepth: []int{0, 0, 0, 1, 0},
+			wantStack: [][]byte{{1}},
+		},
+	}
+
+	for i, c := range cases {
+		prog, err := Assemble(c.prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var steps []*TraceStep
+		txVersion := uint64(1)
+		context := &Context{
+			VMVersion: 1,
+			Code:      prog,
+			TxVersion: &txVersion,
+			Tracer:    func(step *TraceStep) { steps = append(steps, step) },
+		}
+
+		if _, err := Verify(context, 10000); errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+			continue
+		}
+
+		if len(steps) != len(c.wantOps) {
+			t.Errorf("case %d: got %d steps, want %d", i, len(steps), len(c.wantOps))
+			continue
+		}
+
+		for j, step := range steps {
+			if step.Op != c.wantOps[j] || step.Depth != c.wantDepth[j] {
+				t.Errorf("case %d step %d: got op %s depth %d, want op %s depth %d", i, j, step.Op, step.Depth, c.wantOps[j], c.wantDepth[j])
+			}
+		}
+
+		last := steps[len(steps)-1]
+		if !testutil.DeepEqual(last.DataStack, c.wantStack) || last.Err != c.wantErr {
+			t.Errorf("case %d: got last step stack %x error %v, want stack %x error %v", i, last.DataStack, last.Err, c.wantStack, c.wantErr)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package vmutil
 
 import (
+	"bytes"
 	"crypto/ed25519"
 
 	"kuskcore/consensus/bcrp"
@@ -8,12 +9,44 @@ import (
 	"kuskcore/protocol/vm"
 )
 
+// HTLCPreimageSize is the size of the htlc preimage, the claim with a preimage
+// of another size is rejected so the secret can be revealed on any chain
+const HTLCPreimageSize = 32
+
 // pre-define errors
 var (
 	ErrBadValue       = errors.New("bad value")
 	ErrMultisigFormat = errors.New("bad multisig program format")
+	ErrHTLCFormat     = errors.New("bad htlc program format")
+	ErrOrderFormat    = errors.New("bad limit order format")
+	ErrChannelFormat  = errors.New("bad payment channel program format")
 )
 
+// LimitOrder is the params of a limit order, they're kept in the state data
+// of the order output
+type LimitOrder struct {
+	RequestedAsset   []byte
+	RatioNumerator   uint64
+	RatioDenominator uint64
+	MakerProgram     []byte
+	MakerKey         ed25519.PublicKey
+}
+
+// PaymentChannel is the params of an unidirectional payment channel
+type PaymentChannel struct {
+	Sender   ed25519.PublicKey
+	Receiver ed25519.PublicKey
+	Timeout  uint64
+}
+
+// HTLCContract is the params of a hash time-locked contract
+type HTLCContract struct {
+	SecretHash []byte
+	Recipient  ed25519.PublicKey
+	Sender     ed25519.PublicKey
+	LockHeight uint64
+}
+
 // IsUnspendable checks if a contorl program is absolute failed
 func IsUnspendable(prog []byte) bool {
 	return len(prog) > 0 && prog[0] == byte(vm.OP_FAIL)
@@ -139,6 +172,275 @@ func P2SPMultiSigProgramWithHeight(pubkeys []ed25519.PublicKey, nrequired int, b
 	return builder.Build()
 }
 
+// HTLCProgram generates the script for hash time-locked contract, the output
+// can be claimed by the recipient with the 32 bytes sha256 preimage of the
+// secret hash, or be refunded to the sender once the block height reaches the lock height.
+//
+// The witness arguments of claim are [recipient sig, preimage, 1], and the
+// ones of refund are [sender sig, 0].
+func HTLCProgram(secretHash []byte, recipient, sender ed25519.PublicKey, lockHeight uint64) ([]byte, error) {
+	if len(secretHash) != 32 {
+		return nil, errors.WithDetail(ErrBadValue, "secret hash should be 32 bytes")
+	}
+
+	if len(recipient) != ed25519.PublicKeySize || len(sender) != ed25519.PublicKeySize {
+		return nil, errors.WithDetail(ErrBadValue, "invalid recipient or sender pubkey")
+	}
+
+	builder := NewBuilder()
+	claim, end := builder.NewJumpTarget(), builder.NewJumpTarget()
+	builder.AddJumpIf(claim)         // stack is now [... SIG]
+	builder.AddOp(vm.OP_BLOCKHEIGHT) // stack is now [... SIG BLOCKHEIGHT]
+	builder.AddUint64(lockHeight)    // stack is now [... SIG BLOCKHEIGHT LOCKHEIGHT]
+	builder.AddOp(vm.OP_GREATERTHANOREQUAL)
+	builder.AddOp(vm.OP_VERIFY)
+	builder.AddOp(vm.OP_TXSIGHASH)
+	builder.AddData(sender) // stack is now [... SIG HASH SENDER]
+	builder.AddOp(vm.OP_CHECKSIG)
+	builder.AddJump(end)
+	builder.SetJumpTarget(claim) // stack is now [... SIG PREIMAGE]
+	builder.AddOp(vm.OP_SIZE)    // stack is now [... SIG PREIMAGE SIZE]
+	builder.AddUint64(HTLCPreimageSize)
+	builder.AddOp(vm.OP_EQUALVERIFY)
+	builder.AddOp(vm.OP_SHA256)
+	builder.AddData(secretHash)
+	builder.AddOp(vm.OP_EQUALVERIFY)
+	builder.AddOp(vm.OP_TXSIGHASH)
+	builder.AddData(recipient) // stack is now [... SIG HASH RECIPIENT]
+	builder.AddOp(vm.OP_CHECKSIG)
+	builder.SetJumpTarget(end)
+	return builder.Build()
+}
+
+// ParseHTLCProgram parses the params of the program generated by HTLCProgram
+func ParseHTLCProgram(program []byte) (*HTLCContract, error) {
+	insts, err := vm.ParseProgram(program)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(insts) != 18 || !insts[2].IsPushdata() || !insts[6].IsPushdata() || !insts[13].IsPushdata() || !insts[16].IsPushdata() {
+		return nil, ErrHTLCFormat
+	}
+
+	lockHeight, err := vm.AsBigInt(insts[2].Data)
+	if err != nil || !lockHeight.IsUint64() {
+		return nil, ErrHTLCFormat
+	}
+
+	contract := &HTLCContract{
+		SecretHash: insts[13].Data,
+		Recipient:  ed25519.PublicKey(insts[16].Data),
+		Sender:     ed25519.PublicKey(insts[6].Data),
+		LockHeight: lockHeight.Uint64(),
+	}
+
+	// rebuild the program to make sure the ops and the jumps are exactly the template
+	expected, err := HTLCProgram(contract.SecretHash, contract.Recipient, contract.Sender, contract.LockHeight)
+	if err != nil || !bytes.Equal(expected, program) {
+		return nil, ErrHTLCFormat
+	}
+	return contract, nil
+}
+
+// IsHTLCProgram checks if the program is generated by HTLCProgram
+func IsHTLCProgram(program []byte) bool {
+	_, err := ParseHTLCProgram(program)
+	return err == nil
+}
+
+// LimitOrderProgram generates the script for limit order, all the orders share
+// the program and keep their params in the state data. The order sells the
+// locked asset for the requested asset at the ratio of numerator/denominator.
+//
+// The fill clause takes [exchange amount, position, 0], it pays the exchange
+// amount of the requested asset to the maker program at the position, with
+// the spent output id as the state data, and releases
+// min(exchange amount * denominator / numerator, amount) of the locked asset.
+// The remainder is locked by the order again at the next position.
+//
+// The cancel clause takes [maker sig, 1].
+func LimitOrderProgram() ([]byte, error) {
+	builder := NewBuilder()
+	cancel, full, end := builder.NewJumpTarget(), builder.NewJumpTarget(), builder.NewJumpTarget()
+	builder.AddJumpIf(cancel) // stack is now [... EXCHANGE POSITION]
+
+	// stack is now [... EXCHANGE POSITION KEY PROGRAM DENOMINATOR NUMERATOR ASSET]
+	for i := 0; i < 5; i++ {
+		builder.AddOp(vm.OP_FROMALTSTACK)
+	}
+
+	builder.AddUint64(6).AddOp(vm.OP_PICK)
+	builder.AddUint64(3).AddOp(vm.OP_PICK)
+	builder.AddOp(vm.OP_MUL)
+	builder.AddUint64(2).AddOp(vm.OP_PICK)
+	builder.AddOp(vm.OP_DIV)
+	builder.AddOp(vm.OP_AMOUNT)
+	builder.AddOp(vm.OP_MIN) // stack is now [... ASSET ACTUAL]
+	builder.AddOp(vm.OP_DUP)
+	builder.AddUint64(0)
+	builder.AddOp(vm.OP_GREATERTHAN)
+	builder.AddOp(vm.OP_VERIFY)
+
+	// pay the maker with the spent output id as the state data
+	builder.AddOp(vm.OP_OUTPUTID).AddOp(vm.OP_TOALTSTACK)
+	builder.AddUint64(6).AddOp(vm.OP_PICK)
+	builder.AddUint64(8).AddOp(vm.OP_PICK)
+	builder.AddUint64(3).AddOp(vm.OP_PICK)
+	builder.AddUint64(1)
+	builder.AddUint64(8).AddOp(vm.OP_PICK) // stack is now [... ACTUAL POSITION EXCHANGE ASSET 1 PROGRAM]
+	builder.AddOp(vm.OP_CHECKOUTPUT).AddOp(vm.OP_VERIFY)
+	builder.AddOp(vm.OP_FROMALTSTACK).AddOp(vm.OP_DROP)
+
+	builder.AddOp(vm.OP_AMOUNT).AddOp(vm.OP_SWAP).AddOp(vm.OP_SUB) // stack is now [... ASSET REMAINDER]
+	builder.AddOp(vm.OP_DUP).AddOp(vm.OP_NOT)
+	builder.AddJumpIf(full)
+
+	// lock the remainder with the same state data
+	for i := 1; i <= 5; i++ {
+		builder.AddUint64(uint64(i)).AddOp(vm.OP_PICK).AddOp(vm.OP_TOALTSTACK)
+	}
+	builder.AddUint64(6).AddOp(vm.OP_PICK).AddOp(vm.OP_1ADD)
+	builder.AddOp(vm.OP_SWAP) // stack is now [... POSITION+1 REMAINDER]
+	builder.AddOp(vm.OP_ASSET)
+	builder.AddUint64(1)
+	builder.AddOp(vm.OP_PROGRAM)
+	builder.AddOp(vm.OP_CHECKOUTPUT)
+	builder.AddJump(end)
+
+	builder.SetJumpTarget(full)
+	builder.AddOp(vm.OP_TRUE)
+	builder.AddJump(end)
+
+	builder.SetJumpTarget(cancel) // stack is now [... SIG]
+	builder.AddOp(vm.OP_TXSIGHASH)
+	builder.AddOp(vm.OP_FROMALTSTACK)
+	builder.AddOp(vm.OP_CHECKSIG)
+	builder.SetJumpTarget(end)
+	return builder.Build()
+}
+
+// IsLimitOrderProgram checks if the program is generated by LimitOrderProgram
+func IsLimitOrderProgram(program []byte) bool {
+	expected, err := LimitOrderProgram()
+	return err == nil && bytes.Equal(expected, program)
+}
+
+// StateData return the state data of the limit order output
+func (o *LimitOrder) StateData() [][]byte {
+	return [][]byte{o.RequestedAsset, vm.Uint64Bytes(o.RatioNumerator), vm.Uint64Bytes(o.RatioDenominator), o.MakerProgram, o.MakerKey}
+}
+
+// Validate checks the params of the limit order
+func (o *LimitOrder) Validate() error {
+	if len(o.RequestedAsset) != 32 {
+		return errors.WithDetail(ErrOrderFormat, "requested asset should be 32 bytes")
+	}
+
+	if o.RatioNumerator == 0 || o.RatioDenominator == 0 {
+		return errors.WithDetail(ErrOrderFormat, "ratio should be positive")
+	}
+
+	if len(o.MakerProgram) == 0 || len(o.MakerKey) != ed25519.PublicKeySize {
+		return errors.WithDetail(ErrOrderFormat, "invalid maker program or key")
+	}
+	return nil
+}
+
+// ParseLimitOrder parses the limit order from the program and the state data of an output
+func ParseLimitOrder(program []byte, stateData [][]byte) (*LimitOrder, error) {
+	if !IsLimitOrderProgram(program) || len(stateData) != 5 {
+		return nil, ErrOrderFormat
+	}
+
+	numerator, err := vm.AsBigInt(stateData[1])
+	if err != nil || !numerator.IsUint64() {
+		return nil, ErrOrderFormat
+	}
+
+	denominator, err := vm.AsBigInt(stateData[2])
+	if err != nil || !denominator.IsUint64() {
+		return nil, ErrOrderFormat
+	}
+
+	order := &LimitOrder{
+		RequestedAsset:   stateData[0],
+		RatioNumerator:   numerator.Uint64(),
+		RatioDenominator: denominator.Uint64(),
+		MakerProgram:     stateData[3],
+		MakerKey:         ed25519.PublicKey(stateData[4]),
+	}
+	return order, order.Validate()
+}
+
+// PaymentChannelProgram generates the script for unidirectional payment channel,
+// the output is closed by the 2-of-2 multisig of the sender and the receiver,
+// or be refunded to the sender once the block height reaches the timeout.
+//
+// The witness arguments of close are [sender sig, receiver sig, 0], and the
+// ones of refund are [sender sig, 1].
+func PaymentChannelProgram(sender, receiver ed25519.PublicKey, timeout uint64) ([]byte, error) {
+	if len(sender) != ed25519.PublicKeySize || len(receiver) != ed25519.PublicKeySize {
+		return nil, errors.WithDetail(ErrBadValue, "invalid sender or receiver pubkey")
+	}
+
+	builder := NewBuilder()
+	refund, end := builder.NewJumpTarget(), builder.NewJumpTarget()
+	builder.AddJumpIf(refund) // stack is now [... SIG SIG]
+	if err := builder.addP2SPMultiSig([]ed25519.PublicKey{sender, receiver}, 2); err != nil {
+		return nil, err
+	}
+
+	builder.AddJump(end)
+	builder.SetJumpTarget(refund)    // stack is now [... SIG]
+	builder.AddOp(vm.OP_BLOCKHEIGHT) // stack is now [... SIG BLOCKHEIGHT]
+	builder.AddUint64(timeout)       // stack is now [... SIG BLOCKHEIGHT TIMEOUT]
+	builder.AddOp(vm.OP_GREATERTHANOREQUAL)
+	builder.AddOp(vm.OP_VERIFY)
+	if err := builder.addP2SPMultiSig([]ed25519.PublicKey{sender}, 1); err != nil {
+		return nil, err
+	}
+
+	builder.SetJumpTarget(end)
+	return builder.Build()
+}
+
+// ParsePaymentChannelProgram parses the params of the program generated by PaymentChannelProgram
+func ParsePaymentChannelProgram(program []byte) (*PaymentChannel, error) {
+	insts, err := vm.ParseProgram(program)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(insts) != 17 || !insts[2].IsPushdata() || !insts[3].IsPushdata() || !insts[9].IsPushdata() {
+		return nil, ErrChannelFormat
+	}
+
+	timeout, err := vm.AsBigInt(insts[9].Data)
+	if err != nil || !timeout.IsUint64() {
+		return nil, ErrChannelFormat
+	}
+
+	channel := &PaymentChannel{
+		Sender:   ed25519.PublicKey(insts[2].Data),
+		Receiver: ed25519.PublicKey(insts[3].Data),
+		Timeout:  timeout.Uint64(),
+	}
+
+	// rebuild the program to make sure the ops and the jumps are exactly the template
+	expected, err := PaymentChannelProgram(channel.Sender, channel.Receiver, channel.Timeout)
+	if err != nil || !bytes.Equal(expected, program) {
+		return nil, ErrChannelFormat
+	}
+	return channel, nil
+}
+
+// IsPaymentChannelProgram checks if the program is generated by PaymentChannelProgram
+func IsPaymentChannelProgram(program []byte) bool {
+	_, err := ParsePaymentChannelProgram(program)
+	return err == nil
+}
+
 func checkMultiSigParams(nrequired, npubkeys int64) error {
 	if nrequired < 0 {
 		return errors.WithDetail(ErrBadValue, "negative quorum")
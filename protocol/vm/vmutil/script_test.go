@@ -1,12 +1,15 @@
 package vmutil
 
 import (
+	"bytes"
 	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/hex"
 	"reflect"
 	"testing"
 
 	"kuskcore/errors"
+	"kuskcore/protocol/vm"
 )
 
 // TestIsUnspendable ensures the IsUnspendable function returns the expected
@@ -292,3 +295,230 @@ func TestCallContractProgram(t *testing.T) {
 		}
 	}
 }
+
+func TestHTLCProgram(t *testing.T) {
+	recipient := ed25519.NewKeyFromSeed(make([]byte, 32))
+	sender := ed25519.NewKeyFromSeed(append(make([]byte, 31), 1))
+	preimage := []byte("atomic swap secret of 32 bytes..")
+	secretHash := sha256.Sum256(preimage)
+	longPreimage := append(preimage, 0)
+	longSecretHash := sha256.Sum256(longPreimage)
+
+	program, err := HTLCProgram(secretHash[:], recipient.Public().(ed25519.PublicKey), sender.Public().(ed25519.PublicKey), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contract, err := ParseHTLCProgram(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &HTLCContract{SecretHash: secretHash[:], Recipient: recipient.Public().(ed25519.PublicKey), Sender: sender.Public().(ed25519.PublicKey), LockHeight: 100}
+	if !reflect.DeepEqual(contract, want) {
+		t.Errorf("got htlc contract %v, want %v", contract, want)
+	}
+
+	if _, err := HTLCProgram(secretHash[:4], recipient.Public().(ed25519.PublicKey), sender.Public().(ed25519.PublicKey), 100); errors.Root(err) != ErrBadValue {
+		t.Errorf("got error %v with short secret hash, want %v", err, ErrBadValue)
+	}
+
+	if IsHTLCProgram(append(program, byte(vm.OP_TRUE))) {
+		t.Error("program with trailing op is recognized as htlc")
+	}
+
+	// the preimage of another size is rejected even it matches the secret hash
+	longProgram, err := HTLCProgram(longSecretHash[:], recipient.Public().(ed25519.PublicKey), sender.Public().(ed25519.PublicKey), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigHash := sha256.Sum256([]byte("tx"))
+	cases := []struct {
+		program []byte
+		height  uint64
+		args    [][]byte
+		wantErr bool
+	}{
+		{height: 1, args: [][]byte{ed25519.Sign(recipient, sigHash[:]), preimage, {1}}},
+		{program: longProgram, height: 1, args: [][]byte{ed25519.Sign(recipient, sigHash[:]), longPreimage, {1}}, wantErr: true},
+		{height: 1, args: [][]byte{ed25519.Sign(recipient, sigHash[:]), []byte("wrong"), {1}}, wantErr: true},
+		{height: 1, args: [][]byte{ed25519.Sign(sender, sigHash[:]), preimage, {1}}, wantErr: true},
+		{height: 99, args: [][]byte{ed25519.Sign(sender, sigHash[:]), {}}, wantErr: true},
+		{height: 100, args: [][]byte{ed25519.Sign(sender, sigHash[:]), {}}},
+		{height: 100, args: [][]byte{ed25519.Sign(recipient, sigHash[:]), {}}, wantErr: true},
+	}
+
+	for i, c := range cases {
+		if c.program == nil {
+			c.program = program
+		}
+
+		height := c.height
+		context := &vm.Context{
+			VMVersion:   1,
+			Code:        c.program,
+			Arguments:   c.args,
+			BlockHeight: &height,
+			TxSigHash:   func() []byte { return sigHash[:] },
+		}
+
+		if _, err := vm.Verify(context, 100000); (err != nil) != c.wantErr {
+			t.Errorf("case %d: got error %v, want error %v", i, err, c.wantErr)
+		}
+	}
+}
+
+func TestPaymentChannelProgram(t *testing.T) {
+	sender := ed25519.NewKeyFromSeed(make([]byte, 32))
+	receiver := ed25519.NewKeyFromSeed(append(make([]byte, 31), 1))
+	program, err := PaymentChannelProgram(sender.Public().(ed25519.PublicKey), receiver.Public().(ed25519.PublicKey), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	channel, err := ParsePaymentChannelProgram(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &PaymentChannel{Sender: sender.Public().(ed25519.PublicKey), Receiver: receiver.Public().(ed25519.PublicKey), Timeout: 100}
+	if !reflect.DeepEqual(channel, want) {
+		t.Errorf("got payment channel %v, want %v", channel, want)
+	}
+
+	if IsPaymentChannelProgram(append(program, byte(vm.OP_TRUE))) {
+		t.Error("program with trailing op is recognized as payment channel")
+	}
+
+	sigHash := sha256.Sum256([]byte("tx"))
+	senderSig, receiverSig := ed25519.Sign(sender, sigHash[:]), ed25519.Sign(receiver, sigHash[:])
+	cases := []struct {
+		desc    string
+		height  uint64
+		args    [][]byte
+		wantErr bool
+	}{
+		{desc: "close by both", height: 1, args: [][]byte{senderSig, receiverSig, {}}},
+		{desc: "close with the latest state after timeout", height: 120, args: [][]byte{senderSig, receiverSig, {}}},
+		{desc: "close without sender sig", height: 1, args: [][]byte{receiverSig, receiverSig, {}}, wantErr: true},
+		{desc: "close without receiver sig", height: 1, args: [][]byte{senderSig, {}}, wantErr: true},
+		{desc: "close with swapped sigs", height: 1, args: [][]byte{receiverSig, senderSig, {}}, wantErr: true},
+		{desc: "refund before timeout", height: 99, args: [][]byte{senderSig, {1}}, wantErr: true},
+		{desc: "refund at timeout", height: 100, args: [][]byte{senderSig, {1}}},
+		{desc: "refund by receiver", height: 100, args: [][]byte{receiverSig, {1}}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		height := c.height
+		context := &vm.Context{
+			VMVersion:   1,
+			Code:        program,
+			Arguments:   c.args,
+			BlockHeight: &height,
+			TxSigHash:   func() []byte { return sigHash[:] },
+		}
+
+		if _, err := vm.Verify(context, 100000); (err != nil) != c.wantErr {
+			t.Errorf("%s: got error %v, want error %v", c.desc, err, c.wantErr)
+		}
+	}
+}
+
+func TestLimitOrderProgram(t *testing.T) {
+	maker := ed25519.NewKeyFromSeed(make([]byte, 32))
+	order := &LimitOrder{
+		RequestedAsset:   bytes.Repeat([]byte{0xbb}, 32),
+		RatioNumerator:   3,
+		RatioDenominator: 2,
+		MakerProgram:     []byte{0x00, 0x14, 0x01},
+		MakerKey:         maker.Public().(ed25519.PublicKey),
+	}
+
+	program, err := LimitOrderProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseLimitOrder(program, order.StateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, order) {
+		t.Errorf("got limit order %v, want %v", parsed, order)
+	}
+
+	type output struct {
+		index, amount uint64
+		assetID, code []byte
+		state         [][]byte
+	}
+
+	offeredAsset, outputID := bytes.Repeat([]byte{0xaa}, 32), bytes.Repeat([]byte{0xcc}, 32)
+	sigHash := sha256.Sum256([]byte("tx"))
+	cases := []struct {
+		desc    string
+		args    [][]byte
+		outputs []output
+		wantErr bool
+	}{
+		{
+			desc:    "partial fill",
+			args:    [][]byte{vm.Uint64Bytes(30), vm.Uint64Bytes(1), {}},
+			outputs: []output{{1, 30, order.RequestedAsset, order.MakerProgram, [][]byte{outputID}}, {2, 80, offeredAsset, program, order.StateData()}},
+		},
+		{
+			desc:    "partial fill without remainder",
+			args:    [][]byte{vm.Uint64Bytes(30), vm.Uint64Bytes(1), {}},
+			outputs: []output{{1, 30, order.RequestedAsset, order.MakerProgram, [][]byte{outputID}}},
+			wantErr: true,
+		},
+		{
+			desc:    "full fill",
+			args:    [][]byte{vm.Uint64Bytes(150), vm.Uint64Bytes(0), {}},
+			outputs: []output{{0, 150, order.RequestedAsset, order.MakerProgram, [][]byte{outputID}}},
+		},
+		{
+			desc:    "underpaid",
+			args:    [][]byte{vm.Uint64Bytes(30), vm.Uint64Bytes(0), {}},
+			outputs: []output{{0, 29, order.RequestedAsset, order.MakerProgram, [][]byte{outputID}}, {1, 80, offeredAsset, program, order.StateData()}},
+			wantErr: true,
+		},
+		{
+			desc: "cancel",
+			args: [][]byte{ed25519.Sign(maker, sigHash[:]), {1}},
+		},
+		{
+			desc:    "cancel with wrong key",
+			args:    [][]byte{ed25519.Sign(ed25519.NewKeyFromSeed(append(make([]byte, 31), 1)), sigHash[:]), {1}},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		amount, outputs := uint64(100), c.outputs
+		context := &vm.Context{
+			VMVersion:     1,
+			Code:          program,
+			StateData:     order.StateData(),
+			Arguments:     c.args,
+			AssetID:       &offeredAsset,
+			Amount:        &amount,
+			SpentOutputID: &outputID,
+			TxSigHash:     func() []byte { return sigHash[:] },
+			CheckOutput: func(index uint64, amount uint64, assetID []byte, vmVersion uint64, code []byte, state [][]byte, expansion bool) (bool, error) {
+				for _, out := range outputs {
+					if out.index == index && out.amount == amount && bytes.Equal(out.assetID, assetID) && bytes.Equal(out.code, code) && reflect.DeepEqual(out.state, state) {
+						return true, nil
+					}
+				}
+				return false, nil
+			},
+		}
+
+		if _, err := vm.Verify(context, 100000); (err != nil) != c.wantErr {
+			t.Errorf("%s: got error %v, want error %v", c.desc, err, c.wantErr)
+		}
+	}
+}
@@ -55,6 +55,6 @@ func validateContract(chain *protocol.Chain, contract []byte, arguments [][]byte
 		return err
 	}
 
-	_, err = validation.ValidateTx(tx.Tx, types.MapBlock(block), chain.ProgramConverter)
+	_, err = validation.ValidateTx(tx.Tx, types.MapBlock(block), chain.ProgramConverter, chain.OutputHeight(block.Height))
 	return err
 }
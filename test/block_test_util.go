@@ -28,7 +28,7 @@ func NewBlock(chain *protocol.Chain, txs []*types.Tx, controlProgram []byte) (*t
 
 	bcBlock := &bc.Block{BlockHeader: &bc.BlockHeader{Height: preBlockHeader.Height + 1}}
 	for _, tx := range txs {
-		gasStatus, err := validation.ValidateTx(tx.Tx, bcBlock, chain.ProgramConverter)
+		gasStatus, err := validation.ValidateTx(tx.Tx, bcBlock, chain.ProgramConverter, chain.OutputHeight(bcBlock.Height))
 		if err != nil {
 			continue
 		}
@@ -63,7 +63,7 @@ func TestP2PKH(t *testing.T) {
 
 	tx.SerializedSize = 1
 	converter := func(prog []byte) ([]byte, error) { return nil, nil }
-	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter); err != nil {
+	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter, nil); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -128,7 +128,7 @@ func TestBip0032P2PKH(t *testing.T) {
 
 	tx.SerializedSize = 1
 	converter := func(prog []byte) ([]byte, error) { return nil, nil }
-	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter); err != nil {
+	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter, nil); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -190,7 +190,7 @@ func TestP2SH(t *testing.T) {
 
 	tx.SerializedSize = 1
 	converter := func(prog []byte) ([]byte, error) { return nil, nil }
-	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter); err != nil {
+	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter, nil); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -264,7 +264,7 @@ func TestBip0032P2SH(t *testing.T) {
 
 	tx.SerializedSize = 1
 	converter := func(prog []byte) ([]byte, error) { return nil, nil }
-	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter); err != nil {
+	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter, nil); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -336,7 +336,7 @@ func TestMutilNodeSign(t *testing.T) {
 
 	tx.SerializedSize = 1
 	converter := func(prog []byte) ([]byte, error) { return nil, nil }
-	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter); err != nil {
+	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter, nil); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -421,7 +421,7 @@ func TestBip0032MutilNodeSign(t *testing.T) {
 
 	tx.SerializedSize = 1
 	converter := func(prog []byte) ([]byte, error) { return nil, nil }
-	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter); err != nil {
+	if _, err = validation.ValidateTx(types.MapTx(tx), test.MockBlock(), converter, nil); err != nil {
 		t.Fatal(err)
 	}
 }
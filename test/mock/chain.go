@@ -2,6 +2,8 @@ package mock
 
 import (
 	"errors"
+
+	"kuskcore/blockchain/gcs"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 )
@@ -75,6 +77,42 @@ func (c *Chain) GetHeaderByHeight(height uint64) (*types.BlockHeader, error) {
 	return &block.BlockHeader, nil
 }
 
+func (c *Chain) GetBlockFilter(hash *bc.Hash) ([]byte, error) {
+	block, ok := c.blockMap[*hash]
+	if !ok {
+		return nil, errors.New("can't find block")
+	}
+
+	filter, err := gcs.BuildBlockFilter(block)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Bytes(), nil
+}
+
+func (c *Chain) GetBlockFilterHeader(hash *bc.Hash) (*bc.Hash, error) {
+	block, ok := c.blockMap[*hash]
+	if !ok {
+		return nil, errors.New("can't find block")
+	}
+
+	prevHeader := &bc.Hash{}
+	if block.Height > 0 {
+		var err error
+		if prevHeader, err = c.GetBlockFilterHeader(&block.PreviousBlockHash); err != nil {
+			return nil, err
+		}
+	}
+
+	filter, err := gcs.BuildBlockFilter(block)
+	if err != nil {
+		return nil, err
+	}
+
+	header := gcs.FilterHeader(filter, prevHeader)
+	return &header, nil
+}
+
 func (c *Chain) InMainChain(hash bc.Hash) bool {
 	block, ok := c.blockMap[hash]
 	if !ok {
@@ -83,6 +121,9 @@ func (c *Chain) InMainChain(hash bc.Hash) bool {
 	return c.heightMap[block.Height] == block
 }
 
+func (c *Chain) PrevalidateBlock(block *types.Block) {
+}
+
 func (c *Chain) ProcessBlock(block *types.Block) (bool, error) {
 	if c.bestBlockHeader.Hash() == block.PreviousBlockHash {
 		c.heightMap[block.Height] = block
@@ -126,3 +167,12 @@ func (c *Chain) SetBlockByHeight(height uint64, block *types.Block) {
 func (c *Chain) ValidateTx(*types.Tx) (bool, error) {
 	return false, nil
 }
+
+func (c *Chain) VerifyHeaders(headers []*types.BlockHeader) (int, error) {
+	for i := 1; i < len(headers); i++ {
+		if headers[i].PreviousBlockHash != headers[i-1].Hash() {
+			return i, errors.New("headers are not linked")
+		}
+	}
+	return len(headers), nil
+}
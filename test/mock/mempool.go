@@ -2,6 +2,7 @@ package mock
 
 import (
 	"kuskcore/protocol"
+	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
 )
 
@@ -19,10 +20,24 @@ func (m *Mempool) AddTx(tx *types.Tx) {
 	m.txs = append(m.txs, &protocol.TxDesc{Tx: tx})
 }
 
+func (m *Mempool) GetTransaction(txHash *bc.Hash) (*protocol.TxDesc, error) {
+	for _, txDesc := range m.txs {
+		if txDesc.Tx.ID == *txHash {
+			return txDesc, nil
+		}
+	}
+	return nil, protocol.ErrTransactionNotExist
+}
+
 func (m *Mempool) GetTransactions() []*protocol.TxDesc {
 	return m.txs
 }
 
+func (m *Mempool) HaveTransaction(txHash *bc.Hash) bool {
+	_, err := m.GetTransaction(txHash)
+	return err == nil
+}
+
 func (m *Mempool) IsDust(tx *types.Tx) bool {
 	return false
 }
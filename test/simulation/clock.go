@@ -0,0 +1,44 @@
+package simulation
+
+import (
+	"sync"
+)
+
+// Clock is the virtual clock of the simulation in milliseconds, it only moves
+// when the simulation sets or advances it
+type Clock struct {
+	mtx sync.RWMutex
+	now uint64
+}
+
+// NewClock creates the clock starting at the given time
+func NewClock(now uint64) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current virtual time
+func (c *Clock) Now() uint64 {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.now
+}
+
+// Set moves the clock to the given time, the clock never goes backward
+func (c *Clock) Set(now uint64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if now > c.now {
+		c.now = now
+	}
+}
+
+// Advance moves the clock forward by the given duration
+func (c *Clock) Advance(d uint64) uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.now += d
+	return c.now
+}
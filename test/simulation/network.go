@@ -0,0 +1,447 @@
+package simulation
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/tmlibs/flowrate"
+
+	"kuskcore/p2p"
+	"kuskcore/p2p/connection"
+)
+
+const (
+	pumpInterval  = time.Millisecond
+	inboxCapacity = 1024
+)
+
+// message is the serialized channel message in flight between two nodes
+type message struct {
+	deliverAt uint64
+	seq       uint64
+	from, to  int
+	chID      byte
+	bytes     []byte
+}
+
+// messageQueue orders the messages by the delivery time, the messages of the
+// same delivery time keep the sending order
+type messageQueue []*message
+
+func (q messageQueue) Len() int { return len(q) }
+
+func (q messageQueue) Less(i, j int) bool {
+	if q[i].deliverAt != q[j].deliverAt {
+		return q[i].deliverAt < q[j].deliverAt
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q messageQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *messageQueue) Push(x interface{}) { *q = append(*q, x.(*message)) }
+
+func (q *messageQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	msg := old[n-1]
+	*q = old[:n-1]
+	return msg
+}
+
+type linkKey struct {
+	from, to int
+}
+
+// link is the one way connection between two nodes, the messages of a link
+// are handled in order by its own routine like the receiving routine of the
+// multiplex connection
+type link struct {
+	to    *Node
+	src   *p2p.Peer // the peer of the sending node at the receiving node
+	conn  *simConn  // the connection of the src peer
+	up    bool
+	inbox chan *message
+	quit  chan struct{}
+}
+
+// Network is the in-memory transport between the nodes of the simulation, the
+// latency is in the virtual time so the delayed messages wait for the clock
+type Network struct {
+	clock *Clock
+
+	mtx          sync.Mutex
+	rand         *rand.Rand
+	seq          uint64
+	queue        messageQueue
+	links        map[linkKey]*link
+	latency      uint64
+	linkLatency  map[linkKey]uint64
+	dropRate     float64
+	groups       map[int]int
+	pending      int
+	droppedCount uint64
+
+	quit chan struct{}
+}
+
+// NewNetwork creates the network driven by the clock, the seed decides the
+// dropped messages
+func NewNetwork(clock *Clock, seed int64) *Network {
+	n := &Network{
+		clock:       clock,
+		rand:        rand.New(rand.NewSource(seed)),
+		links:       make(map[linkKey]*link),
+		linkLatency: make(map[linkKey]uint64),
+		quit:        make(chan struct{}),
+	}
+	go n.pumpRoutine()
+	return n
+}
+
+// SetLatency sets the latency in milliseconds of all the links
+func (n *Network) SetLatency(latency uint64) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.latency = latency
+	n.linkLatency = make(map[linkKey]uint64)
+}
+
+// SetLinkLatency sets the latency in milliseconds between the two nodes
+func (n *Network) SetLinkLatency(a, b int, latency uint64) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.linkLatency[linkKey{from: a, to: b}] = latency
+	n.linkLatency[linkKey{from: b, to: a}] = latency
+}
+
+// SetDropRate sets the probability of losing a message
+func (n *Network) SetDropRate(rate float64) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.dropRate = rate
+}
+
+// Partition splits the nodes into the groups, the messages between the groups
+// are lost and the nodes not in any group are isolated
+func (n *Network) Partition(groups ...[]int) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.groups = make(map[int]int)
+	for i, group := range groups {
+		for _, index := range group {
+			n.groups[index] = i
+		}
+	}
+}
+
+// Heal removes the partition of the network
+func (n *Network) Heal() {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.groups = nil
+}
+
+// Dropped returns the number of the lost messages
+func (n *Network) Dropped() uint64 {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return n.droppedCount
+}
+
+// Stop stops delivering the messages
+func (n *Network) Stop() {
+	close(n.quit)
+}
+
+// reachable must be called with the lock held
+func (n *Network) reachable(from, to int) bool {
+	if n.groups == nil {
+		return true
+	}
+
+	fromGroup, ok := n.groups[from]
+	if !ok {
+		return false
+	}
+
+	toGroup, ok := n.groups[to]
+	return ok && fromGroup == toGroup
+}
+
+func (n *Network) send(from, to int, chID byte, bytes []byte) bool {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	key := linkKey{from: from, to: to}
+	if _, ok := n.links[key]; !ok {
+		return false
+	}
+
+	if !n.reachable(from, to) || (n.dropRate > 0 && n.rand.Float64() < n.dropRate) {
+		n.droppedCount++
+		return true
+	}
+
+	latency, ok := n.linkLatency[key]
+	if !ok {
+		latency = n.latency
+	}
+
+	n.seq++
+	heap.Push(&n.queue, &message{
+		deliverAt: n.clock.Now() + latency,
+		seq:       n.seq,
+		from:      from,
+		to:        to,
+		chID:      chID,
+		bytes:     bytes,
+	})
+	return true
+}
+
+func (n *Network) pumpRoutine() {
+	ticker := time.NewTicker(pumpInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.deliverDue()
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// deliverDue hands the due messages to the links in order, the messages of the
+// links not up yet or with the full inbox wait for the next round
+func (n *Network) deliverDue() {
+	now := n.clock.Now()
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	blocked := make(map[linkKey]bool)
+	var retry []*message
+	for n.queue.Len() > 0 && n.queue[0].deliverAt <= now {
+		msg := heap.Pop(&n.queue).(*message)
+		key := linkKey{from: msg.from, to: msg.to}
+		l, ok := n.links[key]
+		if !ok {
+			continue
+		}
+
+		if !n.reachable(msg.from, msg.to) {
+			n.droppedCount++
+			continue
+		}
+
+		if !l.up || blocked[key] {
+			retry = append(retry, msg)
+			continue
+		}
+
+		select {
+		case l.inbox <- msg:
+			n.pending++
+		default:
+			blocked[key] = true
+			retry = append(retry, msg)
+		}
+	}
+
+	for _, msg := range retry {
+		heap.Push(&n.queue, msg)
+	}
+}
+
+func (n *Network) receiveRoutine(l *link) {
+	for {
+		select {
+		case msg := <-l.inbox:
+			l.conn.recvMonitor.Update(len(msg.bytes))
+			l.to.receive(msg.chID, l.src, msg.bytes)
+			n.handled(1)
+		case <-l.quit:
+			n.handled(len(l.inbox))
+			return
+		}
+	}
+}
+
+func (n *Network) handled(num int) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.pending -= num
+}
+
+// idle reports whether no message is due or being handled
+func (n *Network) idle() bool {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if n.pending > 0 {
+		return false
+	}
+
+	now := n.clock.Now()
+	for _, msg := range n.queue {
+		if msg.deliverAt <= now && n.reachable(msg.from, msg.to) {
+			if l, ok := n.links[linkKey{from: msg.from, to: msg.to}]; ok && l.up {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (n *Network) connected(a, b int) bool {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	_, ok := n.links[linkKey{from: a, to: b}]
+	return ok
+}
+
+// connect links the two nodes with the peers at both sides, the messages are
+// held until the reactors of both sides added the peers
+func (n *Network) connect(a, b *Node) error {
+	if a.index == b.index || n.connected(a.index, b.index) {
+		return nil
+	}
+
+	connAtA, connAtB := newSimConn(n, a.index, b.index), newSimConn(n, b.index, a.index)
+	peerAtA := p2p.NewPeerWithConnection(b.nodeInfo(), newAddrConn(b.index), connAtA, true)
+	peerAtB := p2p.NewPeerWithConnection(a.nodeInfo(), newAddrConn(a.index), connAtB, false)
+	for _, peer := range []*p2p.Peer{peerAtA, peerAtB} {
+		if err := peer.Start(); err != nil {
+			return err
+		}
+	}
+
+	aToB := &link{to: b, src: peerAtB, conn: connAtB, inbox: make(chan *message, inboxCapacity), quit: make(chan struct{})}
+	bToA := &link{to: a, src: peerAtA, conn: connAtA, inbox: make(chan *message, inboxCapacity), quit: make(chan struct{})}
+	n.mtx.Lock()
+	n.links[linkKey{from: a.index, to: b.index}] = aToB
+	n.links[linkKey{from: b.index, to: a.index}] = bToA
+	n.mtx.Unlock()
+	go n.receiveRoutine(aToB)
+	go n.receiveRoutine(bToA)
+
+	if err := a.sw.addPeer(peerAtA); err != nil {
+		return err
+	}
+
+	if err := b.sw.addPeer(peerAtB); err != nil {
+		return err
+	}
+
+	n.mtx.Lock()
+	aToB.up, bToA.up = true, true
+	n.mtx.Unlock()
+	return nil
+}
+
+// disconnect closes the links between the two nodes and removes the peers
+func (n *Network) disconnect(a, b *Node) {
+	n.mtx.Lock()
+	aToB, ok := n.links[linkKey{from: a.index, to: b.index}]
+	bToA := n.links[linkKey{from: b.index, to: a.index}]
+	delete(n.links, linkKey{from: a.index, to: b.index})
+	delete(n.links, linkKey{from: b.index, to: a.index})
+	n.mtx.Unlock()
+	if !ok {
+		return
+	}
+
+	close(aToB.quit)
+	close(bToA.quit)
+	a.sw.removePeer(bToA.src)
+	b.sw.removePeer(aToB.src)
+}
+
+// simConn is the multiplex connection of the peer over the network
+type simConn struct {
+	network  *Network
+	from, to int
+
+	sendMonitor *flowrate.Monitor
+	recvMonitor *flowrate.Monitor
+}
+
+func newSimConn(network *Network, from, to int) *simConn {
+	return &simConn{
+		network:     network,
+		from:        from,
+		to:          to,
+		sendMonitor: flowrate.New(0, 0),
+		recvMonitor: flowrate.New(0, 0),
+	}
+}
+
+func (c *simConn) Start() error { return nil }
+
+func (c *simConn) Stop() error { return nil }
+
+func (c *simConn) CanSend(chID byte) bool { return true }
+
+func (c *simConn) Send(chID byte, msg interface{}) bool {
+	return c.TrySend(chID, msg)
+}
+
+func (c *simConn) TrySend(chID byte, msg interface{}) bool {
+	bytes := wire.BinaryBytes(msg)
+	if !c.network.send(c.from, c.to, chID, bytes) {
+		return false
+	}
+
+	c.sendMonitor.Update(len(bytes))
+	return true
+}
+
+func (c *simConn) Status() *connection.ConnectionStatus {
+	return &connection.ConnectionStatus{}
+}
+
+func (c *simConn) TrafficStatus() (*flowrate.Status, *flowrate.Status) {
+	sentStatus := c.sendMonitor.Status()
+	receivedStatus := c.recvMonitor.Status()
+	return &sentStatus, &receivedStatus
+}
+
+func (c *simConn) String() string {
+	return fmt.Sprintf("SimConn{%d->%d}", c.from, c.to)
+}
+
+// addrConn is the raw connection of the peer only providing the addresses
+type addrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func newAddrConn(index int) *addrConn {
+	return &addrConn{addr: nodeAddr(index)}
+}
+
+func (c *addrConn) LocalAddr() net.Addr { return c.addr }
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *addrConn) Close() error { return nil }
+
+func nodeAddr(index int) *net.TCPAddr {
+	return &net.TCPAddr{IP: net.IPv4(10, 0, byte(index>>8), byte(index)), Port: 46656}
+}
@@ -0,0 +1,161 @@
+package simulation
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	cfg "kuskcore/config"
+	"kuskcore/crypto/ed25519/chainkd"
+	"kuskcore/database"
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/event"
+	"kuskcore/netsync/chainmgr"
+	"kuskcore/netsync/consensusmgr"
+	"kuskcore/netsync/peers"
+	"kuskcore/p2p"
+	"kuskcore/proposal/blockproposer"
+	"kuskcore/protocol"
+	"kuskcore/protocol/bc/types"
+)
+
+// Node is the node-like instance of the simulation, it runs the chain, the
+// mempool, casper and the network synchronization like the full node but its
+// proposer is driven by the virtual clock of the simulation
+type Node struct {
+	sim   *Simulation
+	index int
+	xprv  chainkd.XPrv
+
+	Chain  *protocol.Chain
+	TxPool *protocol.TxPool
+
+	dispatcher   *event.Dispatcher
+	sw           *simSwitch
+	chainMgr     *chainmgr.Manager
+	consensusMgr *consensusmgr.Manager
+	proposer     *blockproposer.BlockProposer
+	online       bool
+}
+
+func newNode(sim *Simulation, index int, xprv chainkd.XPrv) (*Node, error) {
+	dir := filepath.Join(sim.dir, fmt.Sprintf("node%d", index))
+	store := database.NewStore(dbm.NewDB("core", "leveldb", dir))
+	dispatcher := event.NewDispatcher()
+	txPool := protocol.NewTxPool(store, dispatcher)
+	chain, err := protocol.NewChain(store, txPool, dispatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	chain.SetPrivateKey(&xprv)
+	node := &Node{
+		sim:        sim,
+		index:      index,
+		xprv:       xprv,
+		Chain:      chain,
+		TxPool:     txPool,
+		dispatcher: dispatcher,
+		proposer:   blockproposer.NewBlockProposer(chain, nil, dispatcher),
+	}
+
+	node.sw = newSwitch(node)
+	peerSet := peers.NewPeerSet(node.sw)
+	if node.chainMgr, err = chainmgr.NewManager(cfg.DefaultConfig(), node.sw, chain, txPool, dispatcher, peerSet, dbm.NewDB("fastsync", "leveldb", dir)); err != nil {
+		return nil, err
+	}
+
+	node.consensusMgr = consensusmgr.NewManager(node.sw, chain, txPool, peerSet, dispatcher)
+	return node, nil
+}
+
+func (n *Node) start() error {
+	if err := n.sw.Start(); err != nil {
+		return err
+	}
+
+	if err := n.chainMgr.Start(); err != nil {
+		return err
+	}
+
+	n.online = true
+	return n.consensusMgr.Start()
+}
+
+func (n *Node) stop() {
+	n.consensusMgr.Stop()
+	n.chainMgr.Stop()
+	n.sw.Stop()
+	n.dispatcher.Stop()
+}
+
+// Index returns the index of the node in the simulation
+func (n *Node) Index() int {
+	return n.index
+}
+
+// Key returns the hex public key identifying the node on the network
+func (n *Node) Key() string {
+	return hex.EncodeToString(n.xprv.XPub().PublicKey())
+}
+
+// XPub returns the validator public key of the node
+func (n *Node) XPub() chainkd.XPub {
+	return n.xprv.XPub()
+}
+
+// Online reports whether the node is connected to the network
+func (n *Node) Online() bool {
+	return n.online
+}
+
+// BestBlockHeight returns the height of the main chain of the node
+func (n *Node) BestBlockHeight() uint64 {
+	return n.Chain.BestBlockHeight()
+}
+
+// LastJustifiedHeight returns the height of the last justified checkpoint
+func (n *Node) LastJustifiedHeight() uint64 {
+	header, err := n.Chain.LastJustifiedHeader()
+	if err != nil {
+		return 0
+	}
+	return header.Height
+}
+
+// LastFinalizedHeight returns the height of the last finalized checkpoint
+func (n *Node) LastFinalizedHeight() uint64 {
+	return n.Chain.FinalizedHeight()
+}
+
+// propose lets the proposer of the node propose the block at the time
+func (n *Node) propose(now uint64) *types.Block {
+	return n.proposer.ProposeBlock(now)
+}
+
+func (n *Node) nodeInfo() *p2p.NodeInfo {
+	config := cfg.DefaultConfig()
+	config.Moniker = n.String()
+	config.ChainID = n.sim.chainID
+	info := p2p.NewNodeInfo(config, n.xprv.XPub().PublicKey(), nodeAddr(n.index).String())
+	info.RemoteAddr = nodeAddr(n.index).String()
+	return info
+}
+
+// receive hands the message from the peer to the reactor of the channel
+func (n *Node) receive(chID byte, src *p2p.Peer, msgBytes []byte) {
+	reactor := n.sw.reactor(chID)
+	if reactor == nil {
+		log.WithFields(log.Fields{"module": logModule, "node": n.index, "chID": chID}).Error("receive message of unknown channel")
+		return
+	}
+
+	reactor.Receive(chID, src, msgBytes)
+}
+
+// String representation.
+func (n *Node) String() string {
+	return fmt.Sprintf("node%d", n.index)
+}
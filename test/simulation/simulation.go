@@ -0,0 +1,290 @@
+// Package simulation runs the network of the in-process nodes over the in-memory
+// transport, the block proposals follow the virtual clock so the consensus
+// scenarios like forks, reorganizations and validator downtime can be tested
+// without the real network and the real block time.
+package simulation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"kuskcore/config"
+	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
+	"kuskcore/crypto/sha3pool"
+	"kuskcore/protocol/bc/types"
+)
+
+const (
+	logModule = "simulation"
+
+	startupDuration   = 100 * time.Millisecond
+	idleDuration      = 100 * time.Millisecond
+	pollInterval      = 5 * time.Millisecond
+	maxSettleDuration = 10 * time.Second
+)
+
+// Config is the config of the simulation
+type Config struct {
+	// Nodes is the number of the nodes, all of them are validators
+	Nodes int
+	// Seed decides the keys of the nodes and the dropped messages
+	Seed int64
+	// BlocksOfEpoch is the block num in one epoch
+	BlocksOfEpoch uint64
+	// BlockTimeInterval is the block time in milliseconds
+	BlockTimeInterval uint64
+	// Latency is the initial latency of the links in milliseconds
+	Latency uint64
+}
+
+// DefaultConfig returns the config of 4 validators with the short epoch
+func DefaultConfig() *Config {
+	return &Config{
+		Nodes:             4,
+		Seed:              1,
+		BlocksOfEpoch:     5,
+		BlockTimeInterval: 6000,
+	}
+}
+
+// Simulation is the network of the nodes driven by the virtual clock
+type Simulation struct {
+	Clock *Clock
+	Nodes []*Node
+
+	network   *Network
+	dir       string
+	chainID   string
+	netParams consensus.Params
+}
+
+// New creates the simulation and starts the nodes, the nodes are connected to
+// each other and the active net params are replaced until the simulation is
+// closed, so the simulations can't run in parallel.
+func New(cfg *Config) (*Simulation, error) {
+	if cfg.Nodes <= 0 {
+		return nil, fmt.Errorf("invalid number of nodes %d", cfg.Nodes)
+	}
+
+	keys := make([]chainkd.XPrv, cfg.Nodes)
+	xpubs := make([]chainkd.XPub, cfg.Nodes)
+	for i := range keys {
+		var seed [32]byte
+		sha3pool.Sum256(seed[:], []byte(fmt.Sprintf("simulation %d node %d", cfg.Seed, i)))
+		keys[i] = chainkd.RootXPrv(seed[:])
+		xpubs[i] = keys[i].XPub()
+	}
+
+	dir, err := ioutil.TempDir("", "simulation")
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &Simulation{
+		dir:       dir,
+		chainID:   "simulation",
+		netParams: consensus.ActiveNetParams,
+	}
+	params := consensus.TestNetParams
+	params.BlocksOfEpoch = cfg.BlocksOfEpoch
+	params.BlockTimeInterval = cfg.BlockTimeInterval
+	params.FederationXpubs = xpubs
+	consensus.ActiveNetParams = params
+
+	sim.Clock = NewClock(config.GenesisBlock().Timestamp + params.BlockTimeInterval)
+	sim.network = NewNetwork(sim.Clock, cfg.Seed)
+	sim.network.SetLatency(cfg.Latency)
+	for i, xprv := range keys {
+		node, err := newNode(sim, i, xprv)
+		if err != nil {
+			sim.Close()
+			return nil, err
+		}
+
+		sim.Nodes = append(sim.Nodes, node)
+	}
+
+	for _, node := range sim.Nodes {
+		if err := node.start(); err != nil {
+			sim.Close()
+			return nil, err
+		}
+	}
+
+	// let the managers subscribe the events before any block is proposed
+	time.Sleep(startupDuration)
+	if err := sim.connectAll(); err != nil {
+		sim.Close()
+		return nil, err
+	}
+
+	sim.settle()
+	return sim, nil
+}
+
+// Close stops the nodes, removes their data and restores the active net params
+func (s *Simulation) Close() {
+	s.network.Stop()
+	for _, node := range s.Nodes {
+		node.stop()
+	}
+	consensus.ActiveNetParams = s.netParams
+	os.RemoveAll(s.dir)
+}
+
+// Step moves the clock to the middle of the next block time slot, lets the
+// validator of the slot propose and waits the network to settle. It returns
+// the proposed block or nil when the validator of the slot is offline.
+func (s *Simulation) Step() *types.Block {
+	interval := consensus.ActiveNetParams.BlockTimeInterval
+	now := (s.Clock.Now()/interval+1)*interval + interval/2
+	s.Clock.Set(now)
+
+	var block *types.Block
+	for _, node := range s.Nodes {
+		if !node.online {
+			continue
+		}
+
+		if b := node.propose(now); b != nil {
+			block = b
+		}
+	}
+
+	s.settle()
+	return block
+}
+
+// Run runs the simulation for the number of the block time slots
+func (s *Simulation) Run(slots int) {
+	for i := 0; i < slots; i++ {
+		s.Step()
+	}
+}
+
+// Partition splits the network into the groups of the node indexes
+func (s *Simulation) Partition(groups ...[]int) {
+	s.network.Partition(groups...)
+}
+
+// Heal removes the partition of the network
+func (s *Simulation) Heal() {
+	s.network.Heal()
+}
+
+// SetLatency sets the latency in milliseconds of all the links
+func (s *Simulation) SetLatency(latency uint64) {
+	s.network.SetLatency(latency)
+}
+
+// SetLinkLatency sets the latency in milliseconds between the two nodes
+func (s *Simulation) SetLinkLatency(a, b int, latency uint64) {
+	s.network.SetLinkLatency(a, b, latency)
+}
+
+// SetDropRate sets the probability of losing a message
+func (s *Simulation) SetDropRate(rate float64) {
+	s.network.SetDropRate(rate)
+}
+
+// Dropped returns the number of the lost messages
+func (s *Simulation) Dropped() uint64 {
+	return s.network.Dropped()
+}
+
+// StopNode disconnects the node from the network and stops its proposing, the
+// node keeps its chain like a restart with the same data
+func (s *Simulation) StopNode(index int) {
+	node := s.Nodes[index]
+	node.online = false
+	for _, other := range s.Nodes {
+		s.network.disconnect(node, other)
+	}
+}
+
+// StartNode reconnects the node to the other online nodes
+func (s *Simulation) StartNode(index int) error {
+	node := s.Nodes[index]
+	node.online = true
+	for _, other := range s.Nodes {
+		if other.online {
+			if err := s.network.connect(node, other); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.settle()
+	return nil
+}
+
+// WaitFor waits until the condition is met or the real time timeout, it's used
+// for the processes on the real time like the regular block sync
+func (s *Simulation) WaitFor(cond func() bool, timeout time.Duration) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); {
+		if cond() {
+			return true
+		}
+		time.Sleep(pollInterval)
+	}
+	return cond()
+}
+
+// Synced reports whether all the online nodes have the same best block
+func (s *Simulation) Synced() bool {
+	var hash string
+	for _, node := range s.Nodes {
+		if !node.online {
+			continue
+		}
+
+		nodeHash := node.Chain.BestBlockHash().String()
+		if hash == "" {
+			hash = nodeHash
+		} else if hash != nodeHash {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Simulation) connectAll() error {
+	for i, a := range s.Nodes {
+		for _, b := range s.Nodes[i+1:] {
+			if err := s.network.connect(a, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Simulation) nodeByKey(key string) *Node {
+	for _, node := range s.Nodes {
+		if node.Key() == key {
+			return node
+		}
+	}
+	return nil
+}
+
+// settle waits until no message is due or being handled for a while, the
+// handling of the messages goes on in the routines of the nodes
+func (s *Simulation) settle() {
+	var idleSince time.Time
+	for deadline := time.Now().Add(maxSettleDuration); time.Now().Before(deadline); time.Sleep(pollInterval) {
+		if !s.network.idle() {
+			idleSince = time.Time{}
+			continue
+		}
+
+		if idleSince.IsZero() {
+			idleSince = time.Now()
+		} else if time.Since(idleSince) >= idleDuration {
+			return
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package simulation
+
+import (
+	"testing"
+	"time"
+
+	"kuskcore/consensus"
+	"kuskcore/protocol/bc/types"
+)
+
+const syncTimeout = 20 * time.Second
+
+func newTestSimulation(t *testing.T) *Simulation {
+	sim, err := New(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}
+
+func checkSynced(t *testing.T, sim *Simulation) {
+	if !sim.WaitFor(sim.Synced, syncTimeout) {
+		for _, node := range sim.Nodes {
+			t.Logf("%s height %d hash %s", node, node.BestBlockHeight(), node.Chain.BestBlockHash())
+		}
+		t.Fatal("the nodes are not synced")
+	}
+}
+
+func TestCheckpointProgression(t *testing.T) {
+	sim := newTestSimulation(t)
+	defer sim.Close()
+
+	sim.Run(20)
+	checkSynced(t, sim)
+	for _, node := range sim.Nodes {
+		if got := node.BestBlockHeight(); got != 20 {
+			t.Errorf("%s best height got %d, want %d", node, got, 20)
+		}
+
+		if got := node.LastJustifiedHeight(); got < 10 {
+			t.Errorf("%s last justified height got %d, want at least %d", node, got, 10)
+		}
+
+		if got := node.LastFinalizedHeight(); got < 5 {
+			t.Errorf("%s last finalized height got %d, want at least %d", node, got, 5)
+		}
+	}
+}
+
+func TestPartitionReorgAcrossCheckpoint(t *testing.T) {
+	sim := newTestSimulation(t)
+	defer sim.Close()
+
+	sim.Run(6)
+	checkSynced(t, sim)
+
+	sim.Partition([]int{0, 1, 2}, []int{3})
+	sim.Run(20)
+	majority, minority := sim.Nodes[0], sim.Nodes[3]
+	if minority.BestBlockHeight() < 10 || majority.BestBlockHeight() <= minority.BestBlockHeight() {
+		t.Fatalf("majority height %d, minority height %d", majority.BestBlockHeight(), minority.BestBlockHeight())
+	}
+
+	forkCheckpoint, err := minority.Chain.GetHeaderByHeight(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if majority.Chain.InMainChain(forkCheckpoint.Hash()) {
+		t.Fatal("the minority checkpoint is on the majority chain")
+	}
+
+	if got := minority.LastJustifiedHeight(); got >= 10 {
+		t.Errorf("minority last justified height got %d, want less than %d", got, 10)
+	}
+
+	if got := majority.LastJustifiedHeight(); got < 15 {
+		t.Errorf("majority last justified height got %d, want at least %d", got, 15)
+	}
+
+	// the minority learns the majority chain from the next majority blocks
+	sim.Heal()
+	sim.Run(4)
+	checkSynced(t, sim)
+	if minority.Chain.InMainChain(forkCheckpoint.Hash()) {
+		t.Error("the minority doesn't reorganize to the justified chain")
+	}
+
+	if got, want := minority.LastJustifiedHeight(), majority.LastJustifiedHeight(); got != want {
+		t.Errorf("minority last justified height got %d, want %d", got, want)
+	}
+}
+
+func TestValidatorDowntime(t *testing.T) {
+	sim := newTestSimulation(t)
+	defer sim.Close()
+
+	sim.Run(4)
+	checkSynced(t, sim)
+
+	sim.StopNode(3)
+	height := sim.Nodes[0].BestBlockHeight()
+	for i := 0; i < 16; i++ {
+		if block := sim.Step(); block != nil {
+			height++
+		}
+	}
+
+	if got := sim.Nodes[0].BestBlockHeight(); got != height || got >= 20 {
+		t.Fatalf("best height got %d, want %d with the slots of the offline validator skipped", got, height)
+	}
+
+	if got := sim.Nodes[0].LastJustifiedHeight(); got < 10 {
+		t.Errorf("last justified height got %d, want at least %d", got, 10)
+	}
+
+	if err := sim.StartNode(3); err != nil {
+		t.Fatal(err)
+	}
+
+	checkSynced(t, sim)
+	sim.Run(4)
+	checkSynced(t, sim)
+	if got, want := sim.Nodes[3].BestBlockHeight(), height+4; got != want {
+		t.Errorf("best height got %d, want %d", got, want)
+	}
+}
+
+func TestMessageDrop(t *testing.T) {
+	sim := newTestSimulation(t)
+	defer sim.Close()
+
+	sim.SetDropRate(0.1)
+	sim.Run(20)
+	if sim.Dropped() == 0 {
+		t.Fatal("no message is dropped")
+	}
+
+	sim.SetDropRate(0)
+	sim.Step()
+	checkSynced(t, sim)
+	if got := sim.Nodes[0].BestBlockHeight(); got < 15 {
+		t.Errorf("best height got %d, want at least %d", got, 15)
+	}
+}
+
+func TestVerifyHeaderChain(t *testing.T) {
+	sim := newTestSimulation(t)
+	defer sim.Close()
+
+	sim.Run(4)
+	checkSynced(t, sim)
+
+	// the offline node knows the checkpoint of the first epoch only
+	sim.StopNode(3)
+	sim.Run(12)
+	node, peer := sim.Nodes[3], sim.Nodes[0]
+	var headers []*types.BlockHeader
+	for height := node.BestBlockHeight() + 1; height <= peer.BestBlockHeight(); height++ {
+		header, err := peer.Chain.GetHeaderByHeight(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		copied := *header
+		headers = append(headers, &copied)
+	}
+
+	// the headers after the first checkpoint header are signed by the assumed validators
+	knownNum := 0
+	for knownNum < len(headers) && headers[knownNum].Height%consensus.ActiveNetParams.BlocksOfEpoch != 0 {
+		knownNum++
+	}
+
+	if knownNum++; len(headers) <= knownNum+1 {
+		t.Fatalf("got %d headers after height %d", len(headers), node.BestBlockHeight())
+	}
+
+	checkpoint := headers[knownNum-1]
+	if len(checkpoint.SupLinks) == 0 {
+		t.Fatalf("checkpoint at height %d has no sup link", checkpoint.Height)
+	}
+
+	cases := []struct {
+		desc    string
+		tamper  func([]*types.BlockHeader)
+		wantNum int
+		wantErr bool
+	}{
+		{
+			desc:    "valid header chain",
+			tamper:  func([]*types.BlockHeader) {},
+			wantNum: len(headers),
+		},
+		{
+			desc: "bad signature by the known validators",
+			tamper: func(headers []*types.BlockHeader) {
+				headers[0].BlockWitness = headers[1].BlockWitness
+			},
+			wantNum: 0,
+			wantErr: true,
+		},
+		{
+			desc: "bad sup link signature by the known validators",
+			tamper: func(headers []*types.BlockHeader) {
+				supLink := *headers[knownNum-1].SupLinks[0]
+				for i, signature := range supLink.Signatures {
+					if len(signature) != 0 {
+						supLink.Signatures[i] = append([]byte{}, headers[knownNum].BlockWitness...)
+					}
+				}
+				headers[knownNum-1].SupLinks = types.SupLinks{&supLink}
+			},
+			wantNum: knownNum - 1,
+			wantErr: true,
+		},
+		{
+			desc: "bad signature by the assumed validators",
+			tamper: func(headers []*types.BlockHeader) {
+				headers[knownNum+1].BlockWitness = headers[knownNum].BlockWitness
+			},
+			wantNum: knownNum + 1,
+		},
+	}
+
+	for _, c := range cases {
+		tampered := make([]*types.BlockHeader, len(headers))
+		for i, header := range headers {
+			copied := *header
+			tampered[i] = &copied
+		}
+
+		c.tamper(tampered)
+		num, err := node.Chain.VerifyHeaders(tampered)
+		if num != c.wantNum || (err != nil) != c.wantErr {
+			t.Errorf("%s: got %d verified headers and err %v, want %d verified headers and err %v", c.desc, num, err, c.wantNum, c.wantErr)
+		}
+	}
+}
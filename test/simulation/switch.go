@@ -0,0 +1,135 @@
+package simulation
+
+import (
+	"sync"
+
+	cmn "github.com/tendermint/tmlibs/common"
+
+	"kuskcore/p2p"
+)
+
+// simSwitch is the connection level peer manager of a node over the network,
+// it never bans the peers since the simulated nodes are all honest
+type simSwitch struct {
+	node *Node
+
+	mtx          sync.RWMutex
+	reactors     map[string]p2p.Reactor
+	reactorsByCh map[byte]p2p.Reactor
+	peers        *p2p.PeerSet
+}
+
+func newSwitch(node *Node) *simSwitch {
+	return &simSwitch{
+		node:         node,
+		reactors:     make(map[string]p2p.Reactor),
+		reactorsByCh: make(map[byte]p2p.Reactor),
+		peers:        p2p.NewPeerSet(),
+	}
+}
+
+// AddReactor registers the reactor by the channels it handles
+func (sw *simSwitch) AddReactor(name string, reactor p2p.Reactor) p2p.Reactor {
+	sw.mtx.Lock()
+	defer sw.mtx.Unlock()
+
+	for _, chDesc := range reactor.GetChannels() {
+		if sw.reactorsByCh[chDesc.ID] != nil {
+			cmn.PanicSanity(cmn.Fmt("Channel %X has multiple reactors %v & %v", chDesc.ID, sw.reactorsByCh[chDesc.ID], reactor))
+		}
+		sw.reactorsByCh[chDesc.ID] = reactor
+	}
+	sw.reactors[name] = reactor
+	return reactor
+}
+
+// Start starts the reactors
+func (sw *simSwitch) Start() error {
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	for _, reactor := range sw.reactors {
+		if err := reactor.Start(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops the reactors
+func (sw *simSwitch) Stop() error {
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	for _, reactor := range sw.reactors {
+		reactor.Stop()
+	}
+	return nil
+}
+
+func (sw *simSwitch) IsListening() bool {
+	return false
+}
+
+// DialPeerWithAddress does nothing, the connections are made by the simulation
+func (sw *simSwitch) DialPeerWithAddress(addr *p2p.NetAddress) error {
+	return nil
+}
+
+func (sw *simSwitch) Peers() *p2p.PeerSet {
+	return sw.peers
+}
+
+// StopPeerGracefully disconnects the peer from the node
+func (sw *simSwitch) StopPeerGracefully(peerID string) {
+	peer := sw.peers.Get(peerID)
+	if peer == nil {
+		return
+	}
+
+	if other := sw.node.sim.nodeByKey(peerID); other != nil {
+		sw.node.sim.network.disconnect(sw.node, other)
+	}
+}
+
+func (sw *simSwitch) IsBanned(ip string, level byte, reason string) bool {
+	return false
+}
+
+func (sw *simSwitch) BanScore(ip string) uint32 {
+	return 0
+}
+
+func (sw *simSwitch) addPeer(peer *p2p.Peer) error {
+	if err := sw.peers.Add(peer); err != nil {
+		return err
+	}
+
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	for _, reactor := range sw.reactors {
+		if err := reactor.AddPeer(peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sw *simSwitch) removePeer(peer *p2p.Peer) {
+	sw.mtx.RLock()
+	for _, reactor := range sw.reactors {
+		reactor.RemovePeer(peer, nil)
+	}
+	sw.mtx.RUnlock()
+
+	sw.peers.Remove(peer)
+	peer.Stop()
+}
+
+func (sw *simSwitch) reactor(chID byte) p2p.Reactor {
+	sw.mtx.RLock()
+	defer sw.mtx.RUnlock()
+
+	return sw.reactorsByCh[chID]
+}
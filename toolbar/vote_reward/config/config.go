@@ -7,11 +7,24 @@ import (
 	"kuskcore/toolbar/common"
 )
 
+const (
+	// MySQLBackend stores the vote utxos in an external MySQL server
+	MySQLBackend = "mysql"
+	// LevelDBBackend stores the vote utxos in an embedded leveldb under LevelDBConfig.Dir
+	LevelDBBackend = "leveldb"
+)
+
 type Config struct {
-	NodeIP      string             `json:"node_ip"`
-	ChainID     string             `json:"chain_id"`
-	MySQLConfig common.MySQLConfig `json:"mysql"`
-	RewardConf  *RewardConfig      `json:"reward_config"`
+	NodeIP        string             `json:"node_ip"`
+	ChainID       string             `json:"chain_id"`
+	DBBackend     string             `json:"db_backend"`
+	MySQLConfig   common.MySQLConfig `json:"mysql"`
+	LevelDBConfig LevelDBConfig      `json:"leveldb"`
+	RewardConf    *RewardConfig      `json:"reward_config"`
+}
+
+type LevelDBConfig struct {
+	Dir string `json:"dir"`
 }
 
 type RewardConfig struct {
@@ -29,5 +42,13 @@ func LoadConfigFile(configFile string, config *Config) error {
 	}
 	defer file.Close()
 
-	return json.NewDecoder(file).Decode(config)
+	if err := json.NewDecoder(file).Decode(config); err != nil {
+		return err
+	}
+
+	// keep the config files written before db_backend was introduced working
+	if config.DBBackend == "" {
+		config.DBBackend = MySQLBackend
+	}
+	return nil
 }
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
+	"kuskcore/toolbar/vote_reward/database/orm"
+)
+
+const (
+	levelDBName = "vote_reward"
+
+	// the leveldb layout has not changed since it was introduced, bump it
+	// together with a new entry in levelDBMigrations
+	levelDBSchemaVersion = 1
+)
+
+var (
+	schemaVersionKey = []byte("schemaVersion")
+	chainStatusKey   = []byte("chainStatus")
+	// utxoPrefix + xpub + ":" + output id => orm.Utxo
+	utxoPrefix = "UTXO:"
+	// utxoIndexPrefix + output id => key of the utxo
+	utxoIndexPrefix = "UID:"
+)
+
+// levelDBMigrations[i] upgrades the layout from version i+1 to version i+2
+var levelDBMigrations = []func(db dbm.DB) error{}
+
+func calcUtxoKey(xpub, outputID string) []byte {
+	return []byte(utxoPrefix + xpub + ":" + outputID)
+}
+
+func calcUtxoIndexKey(outputID string) []byte {
+	return []byte(utxoIndexPrefix + outputID)
+}
+
+// NewLevelDB opens the embedded database under dir, the directory is created if missing
+func NewLevelDB(dir string) (dbm.DB, error) {
+	return dbm.NewGoLevelDB(levelDBName, dir)
+}
+
+// LevelDBStore is the embedded Store, it needs no external database server
+type LevelDBStore struct {
+	db dbm.DB
+}
+
+func NewLevelDBStore(db dbm.DB) *LevelDBStore {
+	return &LevelDBStore{db: db}
+}
+
+func (l *LevelDBStore) Migrate() error {
+	rawVersion := l.db.Get(schemaVersionKey)
+	if rawVersion == nil {
+		// a fresh database is created with the latest layout
+		l.db.SetSync(schemaVersionKey, encodeSchemaVersion(levelDBSchemaVersion))
+		return nil
+	}
+
+	version := binary.BigEndian.Uint64(rawVersion)
+	if version > levelDBSchemaVersion {
+		return errors.WithDetailf(ErrInconsistentDB, "db schema version %d is newer than %d", version, levelDBSchemaVersion)
+	}
+
+	for ; version < levelDBSchemaVersion; version++ {
+		if err := levelDBMigrations[version-1](l.db); err != nil {
+			return errors.Wrapf(err, "migrate to version %d", version+1)
+		}
+
+		l.db.SetSync(schemaVersionKey, encodeSchemaVersion(version+1))
+	}
+	return nil
+}
+
+func encodeSchemaVersion(version uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, version)
+	return buf
+}
+
+func (l *LevelDBStore) GetChainStatus() (*orm.ChainStatus, error) {
+	data := l.db.Get(chainStatusKey)
+	if data == nil {
+		return nil, ErrNotFoundChainStatus
+	}
+
+	chainStatus := &orm.ChainStatus{}
+	if err := json.Unmarshal(data, chainStatus); err != nil {
+		return nil, err
+	}
+
+	return chainStatus, nil
+}
+
+func (l *LevelDBStore) InitChainStatus(chainStatus *orm.ChainStatus) error {
+	data, err := json.Marshal(chainStatus)
+	if err != nil {
+		return err
+	}
+
+	l.db.SetSync(chainStatusKey, data)
+	return nil
+}
+
+func (l *LevelDBStore) AttachBlock(prevStatus, chainStatus *orm.ChainStatus, votes []*orm.Utxo, vetoOutputIDs []string) error {
+	currentStatus, err := l.GetChainStatus()
+	if err != nil {
+		return err
+	}
+
+	if *currentStatus != *prevStatus {
+		return ErrInconsistentDB
+	}
+
+	// vetoes may spend a vote created earlier in the same block
+	utxos := make(map[string]*orm.Utxo)
+	for _, utxo := range votes {
+		utxos[utxo.OutputID] = utxo
+	}
+
+	for _, outputID := range vetoOutputIDs {
+		utxo, ok := utxos[outputID]
+		if !ok {
+			if utxo, err = l.getUtxo(outputID); err != nil {
+				return err
+			}
+
+			utxos[outputID] = utxo
+		}
+
+		if utxo.VetoHeight != 0 {
+			return ErrInconsistentDB
+		}
+
+		utxo.VetoHeight = chainStatus.BlockHeight
+	}
+
+	batch := l.db.NewBatch()
+	for outputID, utxo := range utxos {
+		data, err := json.Marshal(utxo)
+		if err != nil {
+			return err
+		}
+
+		utxoKey := calcUtxoKey(utxo.Xpub, outputID)
+		batch.Set(utxoKey, data)
+		batch.Set(calcUtxoIndexKey(outputID), utxoKey)
+	}
+
+	data, err := json.Marshal(chainStatus)
+	if err != nil {
+		return err
+	}
+
+	batch.Set(chainStatusKey, data)
+	batch.Write()
+	return nil
+}
+
+func (l *LevelDBStore) getUtxo(outputID string) (*orm.Utxo, error) {
+	utxoKey := l.db.Get(calcUtxoIndexKey(outputID))
+	if utxoKey == nil {
+		return nil, ErrInconsistentDB
+	}
+
+	data := l.db.Get(utxoKey)
+	if data == nil {
+		return nil, ErrInconsistentDB
+	}
+
+	utxo := &orm.Utxo{}
+	if err := json.Unmarshal(data, utxo); err != nil {
+		return nil, err
+	}
+
+	return utxo, nil
+}
+
+func (l *LevelDBStore) GetVoteResults(xpub string, voteHeight, vetoHeight uint64) ([]*orm.VoteResult, error) {
+	voteNums := make(map[string]uint64)
+	iter := l.db.IteratorPrefix([]byte(utxoPrefix + xpub + ":"))
+	defer iter.Release()
+
+	for iter.Next() {
+		utxo := &orm.Utxo{}
+		if err := json.Unmarshal(iter.Value(), utxo); err != nil {
+			return nil, err
+		}
+
+		if utxo.VoteHeight > voteHeight || (utxo.VetoHeight != 0 && utxo.VetoHeight < vetoHeight) {
+			continue
+		}
+
+		voteNums[utxo.VoteAddress] += utxo.VoteNum
+	}
+
+	var voteResults []*orm.VoteResult
+	for voteAddress, voteNum := range voteNums {
+		voteResults = append(voteResults, &orm.VoteResult{VoteAddress: voteAddress, VoteNum: voteNum})
+	}
+
+	sort.Slice(voteResults, func(i, j int) bool {
+		return voteResults[i].VoteAddress < voteResults[j].VoteAddress
+	})
+	return voteResults, nil
+}
+
+func (l *LevelDBStore) Close() error {
+	l.db.Close()
+	return nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"kuskcore/errors"
+	"kuskcore/toolbar/vote_reward/database/orm"
+)
+
+// mysqlMigrations must only ever be appended to, the index plus one is the
+// schema version recorded in the schema_versions table.
+var mysqlMigrations = []string{
+	"CREATE TABLE IF NOT EXISTS `chain_statuses` (" +
+		"`block_height` int(11) NOT NULL," +
+		"`block_hash` varchar(64) NOT NULL" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+	"CREATE TABLE IF NOT EXISTS `utxos` (" +
+		"`id` int(11) NOT NULL AUTO_INCREMENT," +
+		"`output_id` varchar(64) NOT NULL," +
+		"`xpub` varchar(128) NOT NULL," +
+		"`vote_address` varchar(62) NOT NULL," +
+		"`vote_num` bigint(21) NOT NULL," +
+		"`vote_height` int(11) NOT NULL," +
+		"`veto_height` int(11) NOT NULL," +
+		"PRIMARY KEY (`id`)," +
+		"UNIQUE KEY `output_id` (`output_id`)," +
+		"KEY `xpub` (`xpub`)," +
+		"KEY `vote_height` (`vote_height`)," +
+		"KEY `veto_height` (`veto_height`)" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8",
+}
+
+type schemaVersion struct {
+	Version int
+}
+
+// MySQLStore is the Store backed by a MySQL server through gorm
+type MySQLStore struct {
+	db *gorm.DB
+}
+
+func NewMySQLStore(db *gorm.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (m *MySQLStore) Migrate() error {
+	if err := m.db.Exec("CREATE TABLE IF NOT EXISTS `schema_versions` (`version` int(11) NOT NULL) ENGINE=InnoDB DEFAULT CHARSET=utf8").Error; err != nil {
+		return err
+	}
+
+	version := &schemaVersion{}
+	if err := m.db.First(version).Error; err == gorm.ErrRecordNotFound {
+		if err := m.db.Create(version).Error; err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	for ; version.Version < len(mysqlMigrations); version.Version++ {
+		dbTX := m.db.Begin()
+		if err := dbTX.Exec(mysqlMigrations[version.Version]).Error; err != nil {
+			dbTX.Rollback()
+			return errors.Wrapf(err, "migrate to version %d", version.Version+1)
+		}
+
+		if err := dbTX.Model(&schemaVersion{}).Update("version", version.Version+1).Error; err != nil {
+			dbTX.Rollback()
+			return err
+		}
+
+		if err := dbTX.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MySQLStore) GetChainStatus() (*orm.ChainStatus, error) {
+	chainStatus := &orm.ChainStatus{}
+	if err := m.db.First(chainStatus).Error; err == gorm.ErrRecordNotFound {
+		return nil, ErrNotFoundChainStatus
+	} else if err != nil {
+		return nil, err
+	}
+
+	return chainStatus, nil
+}
+
+func (m *MySQLStore) InitChainStatus(chainStatus *orm.ChainStatus) error {
+	return m.db.Save(chainStatus).Error
+}
+
+func (m *MySQLStore) AttachBlock(prevStatus, chainStatus *orm.ChainStatus, votes []*orm.Utxo, vetoOutputIDs []string) error {
+	dbTX := m.db.Begin()
+	if err := attachBlock(dbTX, prevStatus, chainStatus, votes, vetoOutputIDs); err != nil {
+		dbTX.Rollback()
+		return err
+	}
+
+	return dbTX.Commit().Error
+}
+
+func attachBlock(db *gorm.DB, prevStatus, chainStatus *orm.ChainStatus, votes []*orm.Utxo, vetoOutputIDs []string) error {
+	for _, utxo := range votes {
+		if err := db.Save(utxo).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, outputID := range vetoOutputIDs {
+		result := db.Model(&orm.Utxo{}).Where(&orm.Utxo{OutputID: outputID}).Update("veto_height", chainStatus.BlockHeight)
+		if err := result.Error; err != nil {
+			return err
+		} else if result.RowsAffected != 1 {
+			return ErrInconsistentDB
+		}
+	}
+
+	result := db.Model(&orm.ChainStatus{}).Where(prevStatus).Updates(chainStatus)
+	if err := result.Error; err != nil {
+		return err
+	} else if result.RowsAffected != 1 {
+		return ErrInconsistentDB
+	}
+	return nil
+}
+
+func (m *MySQLStore) GetVoteResults(xpub string, voteHeight, vetoHeight uint64) ([]*orm.VoteResult, error) {
+	var voteResults []*orm.VoteResult
+	query := m.db.Table("utxos").Select("vote_address, sum(vote_num) as vote_num")
+	query = query.Where("(veto_height >= ? or veto_height = 0) and vote_height <= ? and xpub = ?", vetoHeight, voteHeight, xpub)
+	query = query.Group("vote_address")
+	if err := query.Scan(&voteResults).Error; err != nil {
+		return nil, err
+	}
+
+	return voteResults, nil
+}
+
+func (m *MySQLStore) Close() error {
+	return m.db.Close()
+}
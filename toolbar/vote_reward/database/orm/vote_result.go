@@ -0,0 +1,6 @@
+package orm
+
+type VoteResult struct {
+	VoteAddress string
+	VoteNum     uint64
+}
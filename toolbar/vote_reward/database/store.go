@@ -0,0 +1,64 @@
+package database
+
+import (
+	"kuskcore/errors"
+	"kuskcore/toolbar/common"
+	"kuskcore/toolbar/vote_reward/config"
+	"kuskcore/toolbar/vote_reward/database/orm"
+)
+
+var (
+	ErrNotFoundChainStatus = errors.New("chain status not found")
+	ErrInconsistentDB      = errors.New("inconsistent db status")
+	errUnknownBackend      = errors.New("unknown db backend")
+)
+
+// Store is the persistence layer used by the vote reward tool, the MySQL and
+// the embedded leveldb implementation are interchangeable.
+type Store interface {
+	// Migrate brings the schema up to the latest version.
+	Migrate() error
+
+	// GetChainStatus returns ErrNotFoundChainStatus before the first block is saved.
+	GetChainStatus() (*orm.ChainStatus, error)
+	InitChainStatus(chainStatus *orm.ChainStatus) error
+
+	// AttachBlock atomically saves the vote utxos created by a block, marks the
+	// vetoed ones and moves the chain status from prevStatus to chainStatus.
+	AttachBlock(prevStatus, chainStatus *orm.ChainStatus, votes []*orm.Utxo, vetoOutputIDs []string) error
+
+	// GetVoteResults sums the vote num of the xpub per vote address, counting
+	// the utxos voted at or before voteHeight and not vetoed before vetoHeight.
+	GetVoteResults(xpub string, voteHeight, vetoHeight uint64) ([]*orm.VoteResult, error)
+
+	Close() error
+}
+
+// NewStore opens and migrates the store selected by cfg.DBBackend.
+func NewStore(cfg *config.Config) (Store, error) {
+	var store Store
+	switch cfg.DBBackend {
+	case config.MySQLBackend:
+		db, err := common.NewMySQLDB(cfg.MySQLConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		store = NewMySQLStore(db)
+	case config.LevelDBBackend:
+		db, err := NewLevelDB(cfg.LevelDBConfig.Dir)
+		if err != nil {
+			return nil, err
+		}
+
+		store = NewLevelDBStore(db)
+	default:
+		return nil, errors.WithDetailf(errUnknownBackend, "db backend: %s", cfg.DBBackend)
+	}
+
+	if err := store.Migrate(); err != nil {
+		store.Close()
+		return nil, errors.Wrap(err, "migrate db")
+	}
+	return store, nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"testing"
+
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/testutil"
+	"kuskcore/toolbar/vote_reward/database/orm"
+)
+
+func TestLevelDBStoreMigrate(t *testing.T) {
+	db := dbm.NewMemDB()
+	store := NewLevelDBStore(db)
+	if err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Migrate(); err != nil {
+		t.Fatal("migrate a migrated db", err)
+	}
+
+	db.Set(schemaVersionKey, encodeSchemaVersion(levelDBSchemaVersion+1))
+	if err := store.Migrate(); err == nil {
+		t.Fatal("migrate a db newer than the tool should fail")
+	}
+}
+
+func TestLevelDBStoreAttachBlock(t *testing.T) {
+	store := NewLevelDBStore(dbm.NewMemDB())
+	if _, err := store.GetChainStatus(); err != ErrNotFoundChainStatus {
+		t.Fatalf("got err %v before init chain status, want %v", err, ErrNotFoundChainStatus)
+	}
+
+	genesis := &orm.ChainStatus{BlockHeight: 0, BlockHash: "genesis"}
+	if err := store.InitChainStatus(genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		desc          string
+		prevStatus    *orm.ChainStatus
+		chainStatus   *orm.ChainStatus
+		votes         []*orm.Utxo
+		vetoOutputIDs []string
+		wantErr       error
+	}{
+		{
+			desc:        "vote in block 1",
+			prevStatus:  genesis,
+			chainStatus: &orm.ChainStatus{BlockHeight: 1, BlockHash: "b1"},
+			votes: []*orm.Utxo{
+				{OutputID: "o1", Xpub: "x1", VoteAddress: "a1", VoteNum: 100, VoteHeight: 1},
+				{OutputID: "o2", Xpub: "x1", VoteAddress: "a2", VoteNum: 200, VoteHeight: 1},
+				{OutputID: "o3", Xpub: "x2", VoteAddress: "a1", VoteNum: 300, VoteHeight: 1},
+			},
+		},
+		{
+			desc:        "stale prev status",
+			prevStatus:  genesis,
+			chainStatus: &orm.ChainStatus{BlockHeight: 2, BlockHash: "b2"},
+			wantErr:     ErrInconsistentDB,
+		},
+		{
+			desc:          "veto unknown output",
+			prevStatus:    &orm.ChainStatus{BlockHeight: 1, BlockHash: "b1"},
+			chainStatus:   &orm.ChainStatus{BlockHeight: 2, BlockHash: "b2"},
+			vetoOutputIDs: []string{"o4"},
+			wantErr:       ErrInconsistentDB,
+		},
+		{
+			desc:        "veto and vote in block 2",
+			prevStatus:  &orm.ChainStatus{BlockHeight: 1, BlockHash: "b1"},
+			chainStatus: &orm.ChainStatus{BlockHeight: 2, BlockHash: "b2"},
+			votes: []*orm.Utxo{
+				{OutputID: "o4", Xpub: "x1", VoteAddress: "a1", VoteNum: 50, VoteHeight: 2},
+			},
+			vetoOutputIDs: []string{"o2"},
+		},
+		{
+			desc:          "veto twice",
+			prevStatus:    &orm.ChainStatus{BlockHeight: 2, BlockHash: "b2"},
+			chainStatus:   &orm.ChainStatus{BlockHeight: 3, BlockHash: "b3"},
+			vetoOutputIDs: []string{"o2"},
+			wantErr:       ErrInconsistentDB,
+		},
+	}
+
+	for _, c := range cases {
+		if err := store.AttachBlock(c.prevStatus, c.chainStatus, c.votes, c.vetoOutputIDs); err != c.wantErr {
+			t.Fatalf("case %s: got err %v, want %v", c.desc, err, c.wantErr)
+		}
+	}
+
+	chainStatus, err := store.GetChainStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (&orm.ChainStatus{BlockHeight: 2, BlockHash: "b2"}); !testutil.DeepEqual(chainStatus, want) {
+		t.Errorf("got chain status %v, want %v", chainStatus, want)
+	}
+
+	voteCases := []struct {
+		xpub       string
+		voteHeight uint64
+		vetoHeight uint64
+		want       []*orm.VoteResult
+	}{
+		{
+			xpub:       "x1",
+			voteHeight: 0,
+			vetoHeight: 1,
+		},
+		{
+			xpub:       "x1",
+			voteHeight: 1,
+			vetoHeight: 2,
+			want:       []*orm.VoteResult{{VoteAddress: "a1", VoteNum: 100}, {VoteAddress: "a2", VoteNum: 200}},
+		},
+		{
+			xpub:       "x1",
+			voteHeight: 2,
+			vetoHeight: 3,
+			want:       []*orm.VoteResult{{VoteAddress: "a1", VoteNum: 150}},
+		},
+		{
+			xpub:       "x2",
+			voteHeight: 2,
+			vetoHeight: 3,
+			want:       []*orm.VoteResult{{VoteAddress: "a1", VoteNum: 300}},
+		},
+	}
+
+	for i, c := range voteCases {
+		got, err := store.GetVoteResults(c.xpub, c.voteHeight, c.vetoHeight)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !testutil.DeepEqual(got, c.want) {
+			t.Errorf("case %d: got vote results %v, want %v", i, got, c.want)
+		}
+	}
+}
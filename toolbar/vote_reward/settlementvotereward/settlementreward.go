@@ -5,13 +5,14 @@ import (
 	"encoding/json"
 	"math/big"
 
-	"github.com/jinzhu/gorm"
-
 	"kuskcore/consensus"
 	"kuskcore/errors"
+	"kuskcore/protocol/bc/types"
 	"kuskcore/toolbar/apinode"
 	"kuskcore/toolbar/common"
 	"kuskcore/toolbar/vote_reward/config"
+	"kuskcore/toolbar/vote_reward/database"
+	"kuskcore/toolbar/vote_reward/database/orm"
 )
 
 var (
@@ -19,15 +20,16 @@ var (
 	errNotRewardTx    = errors.New("No reward transaction")
 )
 
-type voteResult struct {
-	VoteAddress string
-	VoteNum     uint64
+// chainNode is the part of apinode.Node used by the settlement
+type chainNode interface {
+	GetBlockByHeight(height uint64) (*types.Block, error)
+	BatchSendKUSK(accountID, password string, outputs map[string]uint64, memo []byte) error
 }
 
 type SettlementReward struct {
 	rewardCfg   *config.RewardConfig
-	node        *apinode.Node
-	db          *gorm.DB
+	node        chainNode
+	store       database.Store
 	rewards     map[string]uint64
 	startHeight uint64
 	endHeight   uint64
@@ -40,9 +42,9 @@ type memo struct {
 	RewardRatio uint64 `json:"reward_ratio"`
 }
 
-func NewSettlementReward(db *gorm.DB, cfg *config.Config, startHeight, endHeight uint64) *SettlementReward {
+func NewSettlementReward(store database.Store, cfg *config.Config, startHeight, endHeight uint64) *SettlementReward {
 	return &SettlementReward{
-		db:          db,
+		store:       store,
 		rewardCfg:   cfg.RewardConf,
 		node:        apinode.NewNode(cfg.NodeIP),
 		rewards:     make(map[string]uint64),
@@ -51,15 +53,8 @@ func NewSettlementReward(db *gorm.DB, cfg *config.Config, startHeight, endHeight
 	}
 }
 
-func (s *SettlementReward) getVoteResultFromDB(height uint64) (voteResults []*voteResult, err error) {
-	query := s.db.Table("utxos").Select("vote_address, sum(vote_num) as vote_num")
-	query = query.Where("(veto_height >= ? or veto_height = 0) and vote_height <= ? and xpub = ?", height-consensus.ActiveNetParams.BlocksOfEpoch+1, height-consensus.ActiveNetParams.BlocksOfEpoch, s.rewardCfg.XPub)
-	query = query.Group("vote_address")
-	if err := query.Scan(&voteResults).Error; err != nil {
-		return nil, err
-	}
-
-	return voteResults, nil
+func (s *SettlementReward) getVoteResultFromDB(height uint64) ([]*orm.VoteResult, error) {
+	return s.store.GetVoteResults(s.rewardCfg.XPub, height-consensus.ActiveNetParams.BlocksOfEpoch, height-consensus.ActiveNetParams.BlocksOfEpoch+1)
 }
 
 func (s *SettlementReward) Settlement() error {
@@ -126,7 +121,7 @@ func (s *SettlementReward) getCoinbaseReward(height uint64) (uint64, error) {
 	return 0, errNotFoundReward
 }
 
-func (s *SettlementReward) calcVoterRewards(voteResults []*voteResult, totalReward uint64) {
+func (s *SettlementReward) calcVoterRewards(voteResults []*orm.VoteResult, totalReward uint64) {
 	totalVoteNum := uint64(0)
 	for _, voteResult := range voteResults {
 		totalVoteNum += voteResult.VoteNum
@@ -0,0 +1,158 @@
+package settlementvotereward
+
+import (
+	"testing"
+
+	"kuskcore/consensus"
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm/vmutil"
+	"kuskcore/testutil"
+	"kuskcore/toolbar/common"
+	"kuskcore/toolbar/vote_reward/config"
+	"kuskcore/toolbar/vote_reward/database"
+	"kuskcore/toolbar/vote_reward/database/orm"
+)
+
+type mockNode struct {
+	blocks  map[uint64]*types.Block
+	outputs map[string]uint64
+	memo    []byte
+}
+
+func (m *mockNode) GetBlockByHeight(height uint64) (*types.Block, error) {
+	block, ok := m.blocks[height]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+
+	return block, nil
+}
+
+func (m *mockNode) BatchSendKUSK(accountID, password string, outputs map[string]uint64, memo []byte) error {
+	m.outputs, m.memo = outputs, memo
+	return nil
+}
+
+func mockCoinbaseBlock(height uint64, controlProgram []byte, amount uint64) *types.Block {
+	return &types.Block{
+		BlockHeader: types.BlockHeader{Height: height},
+		Transactions: []*types.Tx{
+			types.NewTx(types.TxData{
+				Inputs:  []*types.TxInput{types.NewCoinbaseInput(nil)},
+				Outputs: []*types.TxOutput{types.NewOriginalTxOutput(*consensus.KUSKAssetID, amount, controlProgram, nil)},
+			}),
+		},
+	}
+}
+
+func TestSettlement(t *testing.T) {
+	epoch := consensus.ActiveNetParams.BlocksOfEpoch
+	miningProgram, err := vmutil.P2WPKHProgram(make([]byte, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherProgram, err := vmutil.P2WPKHProgram([]byte("00000000000000000001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		RewardConf: &config.RewardConfig{
+			XPub:          "x1",
+			MiningAddress: common.GetAddressFromControlProgram(miningProgram),
+			RewardRatio:   50,
+		},
+	}
+
+	store := database.NewLevelDBStore(dbm.NewMemDB())
+	if err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := &orm.ChainStatus{BlockHeight: 0, BlockHash: "genesis"}
+	if err := store.InitChainStatus(genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	votes := []*orm.Utxo{
+		{OutputID: "o1", Xpub: "x1", VoteAddress: "a1", VoteNum: 100, VoteHeight: 1},
+		{OutputID: "o2", Xpub: "x1", VoteAddress: "a2", VoteNum: 300, VoteHeight: 1},
+		{OutputID: "o3", Xpub: "x2", VoteAddress: "a3", VoteNum: 400, VoteHeight: 1},
+	}
+	if err := store.AttachBlock(genesis, &orm.ChainStatus{BlockHeight: 1, BlockHash: "b1"}, votes, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// a2 vetoes in the second epoch, so it only shares the reward of the second epoch
+	if err := store.AttachBlock(&orm.ChainStatus{BlockHeight: 1, BlockHash: "b1"}, &orm.ChainStatus{BlockHeight: epoch + 2, BlockHash: "b2"}, nil, []string{"o2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		desc        string
+		blocks      map[uint64]*types.Block
+		startHeight uint64
+		endHeight   uint64
+		wantRewards map[string]uint64
+		wantErr     error
+	}{
+		{
+			desc: "votes start counting one epoch after voting",
+			blocks: map[uint64]*types.Block{
+				epoch + 1: mockCoinbaseBlock(epoch+1, miningProgram, 1000),
+			},
+			startHeight: 0,
+			endHeight:   epoch,
+			wantErr:     errNotRewardTx,
+		},
+		{
+			desc: "reward of two epochs",
+			blocks: map[uint64]*types.Block{
+				2*epoch + 1: mockCoinbaseBlock(2*epoch+1, miningProgram, 1000),
+				3*epoch + 1: mockCoinbaseBlock(3*epoch+1, miningProgram, 1000),
+			},
+			startHeight: epoch,
+			endHeight:   3 * epoch,
+			wantRewards: map[string]uint64{"a1": 625, "a2": 375},
+		},
+		{
+			desc: "coinbase not paid to the mining address",
+			blocks: map[uint64]*types.Block{
+				2*epoch + 1: mockCoinbaseBlock(2*epoch+1, otherProgram, 1000),
+			},
+			startHeight: epoch,
+			endHeight:   2 * epoch,
+			wantErr:     errNotRewardTx,
+		},
+	}
+
+	for _, c := range cases {
+		node := &mockNode{blocks: c.blocks}
+		s := NewSettlementReward(store, cfg, c.startHeight, c.endHeight)
+		s.node = node
+		if err := s.Settlement(); errors.Root(err) != c.wantErr {
+			t.Fatalf("case %s: got err %v, want %v", c.desc, err, c.wantErr)
+		}
+
+		if !testutil.DeepEqual(node.outputs, c.wantRewards) {
+			t.Errorf("case %s: got rewards %v, want %v", c.desc, node.outputs, c.wantRewards)
+		}
+	}
+}
+
+func TestCalcVoterRewards(t *testing.T) {
+	s := &SettlementReward{rewards: make(map[string]uint64)}
+	s.calcVoterRewards([]*orm.VoteResult{
+		{VoteAddress: "a1", VoteNum: 100},
+		{VoteAddress: "a2", VoteNum: 300},
+		{VoteAddress: "a3", VoteNum: 1},
+	}, 1000)
+
+	want := map[string]uint64{"a1": 249, "a2": 748, "a3": 2}
+	if !testutil.DeepEqual(s.rewards, want) {
+		t.Errorf("got rewards %v, want %v", s.rewards, want)
+	}
+}
@@ -3,27 +3,24 @@ package synchron
 import (
 	"encoding/hex"
 
-	"github.com/jinzhu/gorm"
-
 	"kuskcore/errors"
 	"kuskcore/protocol/bc/types"
 	"kuskcore/toolbar/apinode"
 	"kuskcore/toolbar/common"
 	"kuskcore/toolbar/vote_reward/config"
+	"kuskcore/toolbar/vote_reward/database"
 	"kuskcore/toolbar/vote_reward/database/orm"
 )
 
-var ErrInconsistentDB = errors.New("inconsistent db status")
-
 type ChainKeeper struct {
-	db           *gorm.DB
+	store        database.Store
 	node         *apinode.Node
 	targetHeight uint64
 }
 
-func NewChainKeeper(db *gorm.DB, cfg *config.Config, targetHeight uint64) (*ChainKeeper, error) {
+func NewChainKeeper(store database.Store, cfg *config.Config, targetHeight uint64) (*ChainKeeper, error) {
 	keeper := &ChainKeeper{
-		db:           db,
+		store:        store,
 		node:         apinode.NewNode(cfg.NodeIP),
 		targetHeight: targetHeight,
 	}
@@ -37,10 +34,9 @@ func NewChainKeeper(db *gorm.DB, cfg *config.Config, targetHeight uint64) (*Chai
 		return nil, errors.New("reward end height is more than finalized height")
 	}
 
-	chainStatus := &orm.ChainStatus{}
-	if err := db.First(chainStatus).Error; err == nil {
+	if _, err := store.GetChainStatus(); err == nil {
 		return keeper, nil
-	} else if err != gorm.ErrRecordNotFound {
+	} else if err != database.ErrNotFoundChainStatus {
 		return nil, errors.Wrap(err, "fail on get chainStatus")
 	}
 
@@ -52,8 +48,8 @@ func NewChainKeeper(db *gorm.DB, cfg *config.Config, targetHeight uint64) (*Chai
 
 func (c *ChainKeeper) SyncBlock() error {
 	for {
-		chainStatus := &orm.ChainStatus{}
-		if err := c.db.First(chainStatus).Error; err != nil {
+		chainStatus, err := c.store.GetChainStatus()
+		if err != nil {
 			return errors.Wrap(err, "fail on syncBlock query chainStatus")
 		}
 
@@ -61,29 +57,25 @@ func (c *ChainKeeper) SyncBlock() error {
 			break
 		}
 
-		dbTX := c.db.Begin()
-		if err := c.syncChainStatus(dbTX, chainStatus); err != nil {
-			dbTX.Rollback()
-			return err
-		}
-
-		if err := dbTX.Commit().Error; err != nil {
+		if err := c.syncChainStatus(chainStatus); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (c *ChainKeeper) syncChainStatus(db *gorm.DB, chainStatus *orm.ChainStatus) error {
+func (c *ChainKeeper) syncChainStatus(chainStatus *orm.ChainStatus) error {
 	nextBlock, err := c.node.GetBlockByHeight(chainStatus.BlockHeight + 1)
 	if err != nil {
 		return err
 	}
 
-	return c.AttachBlock(db, chainStatus, nextBlock)
+	return c.AttachBlock(chainStatus, nextBlock)
 }
 
-func (c *ChainKeeper) AttachBlock(db *gorm.DB, chainStatus *orm.ChainStatus, block *types.Block) error {
+func (c *ChainKeeper) AttachBlock(chainStatus *orm.ChainStatus, block *types.Block) error {
+	var votes []*orm.Utxo
+	var vetoOutputIDs []string
 	for _, tx := range block.Transactions {
 		for _, input := range tx.Inputs {
 			if input.TypedInput.InputType() != types.VetoInputType {
@@ -95,12 +87,7 @@ func (c *ChainKeeper) AttachBlock(db *gorm.DB, chainStatus *orm.ChainStatus, blo
 				return err
 			}
 
-			result := db.Model(&orm.Utxo{}).Where(&orm.Utxo{OutputID: outputID.String()}).Update("veto_height", block.Height)
-			if err := result.Error; err != nil {
-				return err
-			} else if result.RowsAffected != 1 {
-				return ErrInconsistentDB
-			}
+			vetoOutputIDs = append(vetoOutputIDs, outputID.String())
 		}
 
 		for i, output := range tx.Outputs {
@@ -109,21 +96,21 @@ func (c *ChainKeeper) AttachBlock(db *gorm.DB, chainStatus *orm.ChainStatus, blo
 				continue
 			}
 
-			utxo := &orm.Utxo{
+			votes = append(votes, &orm.Utxo{
 				Xpub:        hex.EncodeToString(voteOutput.Vote),
 				VoteAddress: common.GetAddressFromControlProgram(output.ControlProgram),
 				VoteHeight:  block.Height,
 				VoteNum:     output.Amount,
 				OutputID:    tx.OutputID(i).String(),
-			}
-
-			if err := db.Save(utxo).Error; err != nil {
-				return err
-			}
+			})
 		}
 	}
 
-	return c.updateChainStatus(db, chainStatus, block)
+	blockHash := block.Hash()
+	return c.store.AttachBlock(chainStatus, &orm.ChainStatus{
+		BlockHeight: block.Height,
+		BlockHash:   blockHash.String(),
+	}, votes, vetoOutputIDs)
 }
 
 func (c *ChainKeeper) initBlockState() error {
@@ -137,19 +124,5 @@ func (c *ChainKeeper) initBlockState() error {
 		BlockHeight: block.Height,
 		BlockHash:   blockHash.String(),
 	}
-	return c.db.Save(chainStatus).Error
-}
-
-func (c *ChainKeeper) updateChainStatus(db *gorm.DB, chainStatus *orm.ChainStatus, block *types.Block) error {
-	blockHash := block.Hash()
-	result := db.Model(&orm.ChainStatus{}).Where(chainStatus).Updates(&orm.ChainStatus{
-		BlockHeight: block.Height,
-		BlockHash:   blockHash.String(),
-	})
-	if err := result.Error; err != nil {
-		return err
-	} else if result.RowsAffected != 1 {
-		return ErrInconsistentDB
-	}
-	return nil
+	return c.store.InitChainStatus(chainStatus)
 }
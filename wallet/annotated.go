@@ -13,6 +13,7 @@ import (
 	"kuskcore/blockchain/signers"
 	"kuskcore/common"
 	"kuskcore/consensus"
+	"kuskcore/consensus/bcrp"
 	"kuskcore/consensus/segwit"
 	"kuskcore/crypto/sha3pool"
 	dbm "kuskcore/database/leveldb"
@@ -193,6 +194,9 @@ func (w *Wallet) BuildAnnotatedInput(tx *types.Tx, i uint32) *query.AnnotatedInp
 		for _, arg := range arguments {
 			in.WitnessArguments = append(in.WitnessArguments, arg)
 		}
+		if spendInput, ok := orig.TypedInput.(*types.SpendInput); ok {
+			in.Contract = w.buildAnnotatedContractCall(in.ControlProgram, spendInput.StateData, arguments)
+		}
 	case *bc.Issuance:
 		in.Type = "issue"
 		in.IssuanceProgram = orig.ControlProgram()
@@ -225,6 +229,36 @@ func (w *Wallet) BuildAnnotatedInput(tx *types.Tx, i uint32) *query.AnnotatedInp
 	return in
 }
 
+// buildAnnotatedContractCall decodes the state data and the arguments by the
+// abi of the registered contract, returns nil if the program doesn't call one
+func (w *Wallet) buildAnnotatedContractCall(prog []byte, stateData, arguments [][]byte) *query.AnnotatedContractCall {
+	if w.ContractReg == nil || !bcrp.IsCallContractScript(prog) {
+		return nil
+	}
+
+	hash, err := bcrp.ParseContractHash(prog)
+	if err != nil {
+		return nil
+	}
+
+	c, err := w.ContractReg.GetContract(hash[:])
+	if err != nil || c.ABI == nil {
+		return nil
+	}
+
+	call := &query.AnnotatedContractCall{ID: c.Hash, Alias: c.Alias}
+	if call.StateData, err = c.ABI.DecodeStateData(stateData); err != nil {
+		log.WithFields(log.Fields{"module": logModule, "contract": c.Alias, "err": err}).Warn("fail to decode contract state data")
+		return nil
+	}
+
+	if call.Clause, call.Arguments, err = c.ABI.DecodeArguments(arguments); err != nil {
+		log.WithFields(log.Fields{"module": logModule, "contract": c.Alias, "err": err}).Warn("fail to decode contract arguments")
+		return nil
+	}
+	return call
+}
+
 func (w *Wallet) getAddressFromControlProgram(prog []byte) string {
 	if segwit.IsP2WPKHScript(prog) {
 		if pubHash, err := segwit.GetHashFromStandardProg(prog); err == nil {
@@ -0,0 +1,559 @@
+package wallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+
+	"kuskcore/blockchain/txbuilder"
+	dbm "kuskcore/database/leveldb"
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm"
+	"kuskcore/protocol/vm/vmutil"
+)
+
+const (
+	// PaymentChannelPrefix is the prefix of the payment channels of the local keys
+	PaymentChannelPrefix = "PaymentChannel:"
+
+	// the roles of the local key in the payment channel
+	ChannelSender   = "sender"
+	ChannelReceiver = "receiver"
+
+	// the status of the payment channel
+	ChannelOpen     = "open"
+	ChannelClosed   = "closed"
+	ChannelRefunded = "refunded"
+	ChannelOrphaned = "orphaned"
+)
+
+// pre-define errors for supporting kusk errorFormatter
+var (
+	ErrChannelNotFound = errors.New("payment channel not found")
+	ErrChannelRole     = errors.New("payment channel operation isn't allowed for the local role")
+	ErrChannelStatus   = errors.New("payment channel isn't open")
+	ErrChannelUpdate   = errors.New("invalid payment channel update")
+	ErrChannelTimeout  = errors.New("payment channel can't be refunded before the timeout")
+)
+
+// PaymentChannel is an unidirectional payment channel funded on chain, the
+// receiver holds the latest balance signed by the sender and closes the
+// channel with it before the sender can refund the whole capacity
+type PaymentChannel struct {
+	ID              bc.Hash            `json:"id"`
+	AccountID       string             `json:"account_id"`
+	Role            string             `json:"role"`
+	Status          string             `json:"status"`
+	AssetID         bc.AssetID         `json:"asset_id"`
+	Capacity        uint64             `json:"capacity"`
+	SourceID        bc.Hash            `json:"source_id"`
+	SourcePos       uint64             `json:"source_pos"`
+	ControlProgram  chainjson.HexBytes `json:"control_program"`
+	Sender          chainjson.HexBytes `json:"sender_pubkey"`
+	Receiver        chainjson.HexBytes `json:"receiver_pubkey"`
+	SenderProgram   chainjson.HexBytes `json:"sender_program"`
+	ReceiverProgram chainjson.HexBytes `json:"receiver_program"`
+	Timeout         uint64             `json:"timeout"`
+	Refundable      bool               `json:"refundable"`
+	Paid            uint64             `json:"paid"`
+	Fee             uint64             `json:"fee"`
+	SenderSig       chainjson.HexBytes `json:"sender_signature,omitempty"`
+	ReceiverSig     chainjson.HexBytes `json:"receiver_signature,omitempty"`
+	CloseTxID       *bc.Hash           `json:"close_tx_id,omitempty"`
+}
+
+// ChannelUpdate is the off-chain balance update exchanged by the parties,
+// the signatures are on the close transaction of the balance
+type ChannelUpdate struct {
+	ChannelID   bc.Hash            `json:"channel_id"`
+	Paid        uint64             `json:"paid"`
+	Fee         uint64             `json:"fee"`
+	SenderSig   chainjson.HexBytes `json:"sender_signature"`
+	ReceiverSig chainjson.HexBytes `json:"receiver_signature,omitempty"`
+}
+
+func calcPaymentChannelKey(id *bc.Hash) []byte {
+	return []byte(PaymentChannelPrefix + id.String())
+}
+
+func (c *PaymentChannel) update() *ChannelUpdate {
+	return &ChannelUpdate{ChannelID: c.ID, Paid: c.Paid, Fee: c.Fee, SenderSig: c.SenderSig, ReceiverSig: c.ReceiverSig}
+}
+
+func (c *PaymentChannel) input() *types.TxInput {
+	stateData := [][]byte{c.SenderProgram, c.ReceiverProgram}
+	return types.NewSpendInput(nil, c.SourceID, c.AssetID, c.Capacity, c.SourcePos, c.ControlProgram, stateData)
+}
+
+// closeTx return the close transaction of the balance, the receiver is paid
+// and the rest of the capacity except the fee goes back to the sender. The
+// close transaction has no other input, so the fee must be paid by the channel.
+func (c *PaymentChannel) closeTx(paid, fee uint64) (*types.Tx, error) {
+	if fee == 0 {
+		return nil, errors.WithDetail(ErrChannelUpdate, "the close transaction needs a fee")
+	}
+
+	if paid == 0 || paid > c.Capacity || fee > c.Capacity-paid {
+		return nil, errors.WithDetailf(ErrChannelUpdate, "balance %d and fee %d exceed the capacity %d", paid, fee, c.Capacity)
+	}
+
+	outputs := []*types.TxOutput{types.NewOriginalTxOutput(c.AssetID, paid, c.ReceiverProgram, nil)}
+	if change := c.Capacity - paid - fee; change > 0 {
+		outputs = append(outputs, types.NewOriginalTxOutput(c.AssetID, change, c.SenderProgram, nil))
+	}
+	return types.NewTx(types.TxData{Version: 1, Inputs: []*types.TxInput{c.input()}, Outputs: outputs}), nil
+}
+
+// newPaymentChannel return the channel of the output if one of the keys is local
+func (w *Wallet) newPaymentChannel(tx *types.Tx, index int) (*PaymentChannel, error) {
+	out := tx.Outputs[index]
+	contract, err := vmutil.ParsePaymentChannelProgram(out.ControlProgram)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(out.StateData) != 2 {
+		return nil, vmutil.ErrChannelFormat
+	}
+
+	resOut, err := tx.OriginalOutput(*tx.OutputID(index))
+	if err != nil {
+		return nil, err
+	}
+
+	channel := &PaymentChannel{
+		ID:              *tx.OutputID(index),
+		Status:          ChannelOpen,
+		AssetID:         *out.AssetId,
+		Capacity:        out.Amount,
+		SourceID:        *resOut.Source.Ref,
+		SourcePos:       resOut.Source.Position,
+		ControlProgram:  out.ControlProgram,
+		Sender:          chainjson.HexBytes(contract.Sender),
+		Receiver:        chainjson.HexBytes(contract.Receiver),
+		SenderProgram:   out.StateData[0],
+		ReceiverProgram: out.StateData[1],
+		Timeout:         contract.Timeout,
+	}
+	if cp, err := w.AccountMgr.GetLocalCtrlProgramByPubkey(contract.Sender); err == nil {
+		channel.Role, channel.AccountID = ChannelSender, cp.AccountID
+	} else if cp, err := w.AccountMgr.GetLocalCtrlProgramByPubkey(contract.Receiver); err == nil {
+		channel.Role, channel.AccountID = ChannelReceiver, cp.AccountID
+	} else {
+		return nil, ErrChannelNotFound
+	}
+	return channel, nil
+}
+
+// GetPaymentChannel return the payment channel by the id of the funding output
+func (w *Wallet) GetPaymentChannel(id *bc.Hash) (*PaymentChannel, error) {
+	data := w.DB.Get(calcPaymentChannelKey(id))
+	if data == nil {
+		return nil, ErrChannelNotFound
+	}
+
+	channel := &PaymentChannel{}
+	return channel, json.Unmarshal(data, channel)
+}
+
+// ListPaymentChannels return the payment channels of the account, the
+// receiver should close the channel before it's refundable
+func (w *Wallet) ListPaymentChannels(accountID string) ([]*PaymentChannel, error) {
+	iter := w.DB.IteratorPrefix([]byte(PaymentChannelPrefix))
+	defer iter.Release()
+
+	bestHeight := w.chain.BestBlockHeight()
+	channels := []*PaymentChannel{}
+	for iter.Next() {
+		channel := &PaymentChannel{}
+		if err := json.Unmarshal(iter.Value(), channel); err != nil {
+			return nil, err
+		}
+
+		if accountID != "" && channel.AccountID != accountID {
+			continue
+		}
+
+		channel.Refundable = bestHeight+1 >= channel.Timeout
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+// SignChannelPayment signs the new balance of the receiver by the sender, the
+// update is sent to the receiver off chain
+func (w *Wallet) SignChannelPayment(id *bc.Hash, paid, fee uint64, password string) (*ChannelUpdate, error) {
+	w.rw.Lock()
+	defer w.rw.Unlock()
+
+	channel, err := w.openChannel(id, ChannelSender)
+	if err != nil {
+		return nil, err
+	}
+
+	if paid < channel.Paid {
+		return nil, errors.WithDetailf(ErrChannelUpdate, "balance %d is lower than the signed balance %d", paid, channel.Paid)
+	}
+
+	tx, err := channel.closeTx(paid, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := w.signChannelTx(ed25519.PublicKey(channel.Sender), tx, password)
+	if err != nil {
+		return nil, err
+	}
+
+	channel.Paid, channel.Fee, channel.SenderSig, channel.ReceiverSig = paid, fee, sig, nil
+	return channel.update(), w.savePaymentChannel(channel)
+}
+
+// SignChannelClose signs the latest balance by the receiver, the update lets
+// the sender close the channel cooperatively
+func (w *Wallet) SignChannelClose(id *bc.Hash, password string) (*ChannelUpdate, error) {
+	w.rw.Lock()
+	defer w.rw.Unlock()
+
+	channel, err := w.openChannel(id, ChannelReceiver)
+	if err != nil {
+		return nil, err
+	}
+
+	if channel.SenderSig == nil {
+		return nil, errors.WithDetail(ErrChannelUpdate, "no balance is signed by the sender")
+	}
+
+	tx, err := channel.closeTx(channel.Paid, channel.Fee)
+	if err != nil {
+		return nil, err
+	}
+
+	if channel.ReceiverSig, err = w.signChannelTx(ed25519.PublicKey(channel.Receiver), tx, password); err != nil {
+		return nil, err
+	}
+	return channel.update(), w.savePaymentChannel(channel)
+}
+
+// AcceptChannelUpdate verifies and saves the update of the other party. The
+// receiver only accepts a balance not lower than the accepted one, and the
+// sender only accepts the receiver signature on the latest balance.
+func (w *Wallet) AcceptChannelUpdate(update *ChannelUpdate) (*PaymentChannel, error) {
+	w.rw.Lock()
+	defer w.rw.Unlock()
+
+	channel, err := w.GetPaymentChannel(&update.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if channel.Status != ChannelOpen {
+		return nil, ErrChannelStatus
+	}
+
+	tx, err := channel.closeTx(update.Paid, update.Fee)
+	if err != nil {
+		return nil, err
+	}
+
+	sigHash := tx.SigHash(0)
+	switch channel.Role {
+	case ChannelReceiver:
+		if update.Paid < channel.Paid {
+			return nil, errors.WithDetailf(ErrChannelUpdate, "balance %d is lower than the accepted balance %d", update.Paid, channel.Paid)
+		}
+
+		if !ed25519.Verify(ed25519.PublicKey(channel.Sender), sigHash.Bytes(), update.SenderSig) {
+			return nil, errors.WithDetail(ErrChannelUpdate, "invalid sender signature")
+		}
+
+		channel.Paid, channel.Fee, channel.SenderSig, channel.ReceiverSig = update.Paid, update.Fee, update.SenderSig, nil
+
+	case ChannelSender:
+		if update.Paid != channel.Paid || update.Fee != channel.Fee {
+			return nil, errors.WithDetail(ErrChannelUpdate, "the update isn't the latest balance")
+		}
+
+		if !ed25519.Verify(ed25519.PublicKey(channel.Receiver), sigHash.Bytes(), update.ReceiverSig) {
+			return nil, errors.WithDetail(ErrChannelUpdate, "invalid receiver signature")
+		}
+
+		channel.ReceiverSig = update.ReceiverSig
+	}
+	return channel, w.savePaymentChannel(channel)
+}
+
+func (w *Wallet) openChannel(id *bc.Hash, role string) (*PaymentChannel, error) {
+	channel, err := w.GetPaymentChannel(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if channel.Role != role {
+		return nil, errors.WithDetailf(ErrChannelRole, "the local key is the %s of the channel", channel.Role)
+	}
+
+	if channel.Status != ChannelOpen {
+		return nil, ErrChannelStatus
+	}
+	return channel, nil
+}
+
+func (w *Wallet) signChannelTx(pubkey ed25519.PublicKey, tx *types.Tx, password string) (chainjson.HexBytes, error) {
+	acc, path, err := w.AccountMgr.GetLocalKeyPath(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	sigHash := tx.SigHash(0)
+	return w.Hsm.XSign(acc.XPubs[0], path, sigHash.Bytes(), password)
+}
+
+func (w *Wallet) savePaymentChannel(channel *PaymentChannel) error {
+	data, err := json.Marshal(channel)
+	if err != nil {
+		return err
+	}
+
+	w.DB.Set(calcPaymentChannelKey(&channel.ID), data)
+	return nil
+}
+
+// attachChannels saves the channels funded to the local keys and marks the
+// ones spent by the block
+func (w *Wallet) attachChannels(batch dbm.Batch, b *types.Block) {
+	channels := make(map[bc.Hash]*PaymentChannel)
+	for _, tx := range b.Transactions {
+		for _, input := range tx.Inputs {
+			spend, ok := input.TypedInput.(*types.SpendInput)
+			if !ok || !vmutil.IsPaymentChannelProgram(spend.ControlProgram) {
+				continue
+			}
+
+			outputID, err := input.SpentOutputID()
+			if err != nil {
+				continue
+			}
+
+			channel := w.blockChannel(channels, &outputID)
+			if channel == nil {
+				continue
+			}
+
+			channel.Status = ChannelClosed
+			if len(spend.Arguments) > 0 && vm.AsBool(spend.Arguments[len(spend.Arguments)-1]) {
+				channel.Status = ChannelRefunded
+			}
+			channel.CloseTxID = &tx.ID
+		}
+
+		for i, out := range tx.Outputs {
+			if out.OutputType() != types.OriginalOutputType || !vmutil.IsPaymentChannelProgram(out.ControlProgram) {
+				continue
+			}
+
+			if channel := w.blockChannel(channels, tx.OutputID(i)); channel != nil {
+				channel.Status = ChannelOpen
+				continue
+			}
+
+			if channel, err := w.newPaymentChannel(tx, i); err == nil {
+				channels[channel.ID] = channel
+			}
+		}
+	}
+	saveBlockChannels(batch, channels)
+}
+
+// detachChannels reopens the channels spent by the block and orphans the
+// ones funded by the block, the signed balances are kept for the reorg
+func (w *Wallet) detachChannels(batch dbm.Batch, b *types.Block) {
+	channels := make(map[bc.Hash]*PaymentChannel)
+	for i := len(b.Transactions) - 1; i >= 0; i-- {
+		tx := b.Transactions[i]
+		for j, out := range tx.Outputs {
+			if out.OutputType() != types.OriginalOutputType || !vmutil.IsPaymentChannelProgram(out.ControlProgram) {
+				continue
+			}
+
+			if channel := w.blockChannel(channels, tx.OutputID(j)); channel != nil {
+				channel.Status = ChannelOrphaned
+			}
+		}
+
+		for _, input := range tx.Inputs {
+			spend, ok := input.TypedInput.(*types.SpendInput)
+			if !ok || !vmutil.IsPaymentChannelProgram(spend.ControlProgram) {
+				continue
+			}
+
+			outputID, err := input.SpentOutputID()
+			if err != nil {
+				continue
+			}
+
+			if channel := w.blockChannel(channels, &outputID); channel != nil {
+				channel.Status, channel.CloseTxID = ChannelOpen, nil
+			}
+		}
+	}
+	saveBlockChannels(batch, channels)
+}
+
+// blockChannel return the channel changed by the block or the saved one
+func (w *Wallet) blockChannel(channels map[bc.Hash]*PaymentChannel, id *bc.Hash) *PaymentChannel {
+	if channel, ok := channels[*id]; ok {
+		return channel
+	}
+
+	channel, err := w.GetPaymentChannel(id)
+	if err != nil {
+		return nil
+	}
+
+	channels[*id] = channel
+	return channel
+}
+
+func saveBlockChannels(batch dbm.Batch, channels map[bc.Hash]*PaymentChannel) {
+	for _, channel := range channels {
+		data, err := json.Marshal(channel)
+		if err != nil {
+			log.WithFields(log.Fields{"module": logModule, "err": err}).Error("marshal payment channel")
+			continue
+		}
+
+		batch.Set(calcPaymentChannelKey(&channel.ID), data)
+	}
+}
+
+// DecodeCloseChannelAction unmarshal JSON-encoded data of close channel action
+func (w *Wallet) DecodeCloseChannelAction(data []byte) (txbuilder.Action, error) {
+	a := &channelAction{wallet: w, close: true}
+	return a, json.Unmarshal(data, a)
+}
+
+// DecodeRefundChannelAction unmarshal JSON-encoded data of refund channel action
+func (w *Wallet) DecodeRefundChannelAction(data []byte) (txbuilder.Action, error) {
+	a := &channelAction{wallet: w}
+	return a, json.Unmarshal(data, a)
+}
+
+// channelAction spends the payment channel, the close pays the latest balance
+// and the refund returns the capacity to the sender after the timeout. The
+// close transaction is signed off chain, so it can't have any other action.
+type channelAction struct {
+	wallet    *Wallet
+	close     bool
+	ChannelID *bc.Hash `json:"channel_id"`
+	Fee       uint64   `json:"fee"`
+}
+
+func (a *channelAction) ActionType() string {
+	if a.close {
+		return "close_channel"
+	}
+	return "refund_channel"
+}
+
+func (a *channelAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
+	if a.ChannelID == nil {
+		return txbuilder.MissingFieldsError("channel_id")
+	}
+
+	channel, err := a.wallet.GetPaymentChannel(a.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	if channel.Status != ChannelOpen {
+		return ErrChannelStatus
+	}
+
+	if a.close {
+		return a.buildClose(channel, b)
+	}
+	return a.buildRefund(channel, b)
+}
+
+func (a *channelAction) buildClose(channel *PaymentChannel, b *txbuilder.TemplateBuilder) error {
+	if channel.SenderSig == nil {
+		return errors.WithDetail(ErrChannelUpdate, "no balance is signed by the sender")
+	}
+
+	tx, err := channel.closeTx(channel.Paid, channel.Fee)
+	if err != nil {
+		return err
+	}
+
+	sigInst := &txbuilder.SigningInstruction{}
+	sigInst.WitnessComponents = append(sigInst.WitnessComponents, txbuilder.DataWitness(channel.SenderSig))
+	switch {
+	case channel.ReceiverSig != nil:
+		sigInst.WitnessComponents = append(sigInst.WitnessComponents, txbuilder.DataWitness(channel.ReceiverSig))
+
+	case channel.Role == ChannelReceiver:
+		acc, path, err := a.wallet.AccountMgr.GetLocalKeyPath(ed25519.PublicKey(channel.Receiver))
+		if err != nil {
+			return err
+		}
+		sigInst.AddRawWitnessKeys(acc.XPubs, path, acc.Quorum)
+
+	default:
+		return errors.WithDetail(ErrChannelRole, "the sender needs the receiver signature to close the channel")
+	}
+
+	sigInst.WitnessComponents = append(sigInst.WitnessComponents, txbuilder.DataWitness(vm.BoolBytes(false)))
+	if err := b.AddInput(tx.Inputs[0], sigInst); err != nil {
+		return err
+	}
+
+	for _, out := range tx.Outputs {
+		if err := b.AddOutput(out); err != nil {
+			return err
+		}
+	}
+
+	// the signatures are on the close transaction, they're invalidated by any
+	// other input, output or time range of the built transaction
+	b.OnBuild(func() error {
+		if b.Base() != nil || b.TimeRange() != 0 || b.InputCount() != 1 || len(b.Outputs()) != len(tx.Outputs) {
+			return errors.WithDetail(ErrChannelUpdate, "the close transaction can't have other actions or time range")
+		}
+		return nil
+	})
+	return nil
+}
+
+func (a *channelAction) buildRefund(channel *PaymentChannel, b *txbuilder.TemplateBuilder) error {
+	if channel.Role != ChannelSender {
+		return errors.WithDetail(ErrChannelRole, "only the sender can refund the channel")
+	}
+
+	if height := a.wallet.chain.BestBlockHeight() + 1; height < channel.Timeout {
+		return errors.WithDetailf(ErrChannelTimeout, "timeout %d, next block height %d", channel.Timeout, height)
+	}
+
+	if a.Fee == 0 || a.Fee >= channel.Capacity {
+		return errors.WithDetailf(ErrChannelUpdate, "fee %d should be positive and below the capacity %d", a.Fee, channel.Capacity)
+	}
+
+	acc, path, err := a.wallet.AccountMgr.GetLocalKeyPath(ed25519.PublicKey(channel.Sender))
+	if err != nil {
+		return err
+	}
+
+	sigInst := &txbuilder.SigningInstruction{}
+	sigInst.AddRawWitnessKeys(acc.XPubs, path, acc.Quorum)
+	sigInst.WitnessComponents = append(sigInst.WitnessComponents, txbuilder.DataWitness(vm.BoolBytes(true)))
+	if err := b.AddInput(channel.input(), sigInst); err != nil {
+		return err
+	}
+
+	return b.AddOutput(types.NewOriginalTxOutput(channel.AssetID, channel.Capacity-a.Fee, channel.SenderProgram, nil))
+}
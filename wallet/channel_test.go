@@ -0,0 +1,354 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"kuskcore/account"
+	"kuskcore/blockchain/pseudohsm"
+	"kuskcore/blockchain/signers"
+	"kuskcore/blockchain/txbuilder"
+	"kuskcore/consensus"
+	"kuskcore/crypto/ed25519/chainkd"
+	dbm "kuskcore/database/leveldb"
+	"kuskcore/errors"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm"
+	"kuskcore/protocol/vm/vmutil"
+)
+
+type channelParty struct {
+	wallet  *Wallet
+	program []byte
+	pubkey  ed25519.PublicKey
+}
+
+func newChannelParty(t *testing.T, hsm *pseudohsm.HSM, alias string) *channelParty {
+	xpub, _, err := hsm.XCreate(alias, "password", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := dbm.NewMemDB()
+	manager := account.NewManager(db, nil)
+	acc, err := manager.Create([]chainkd.XPub{xpub.XPub}, 1, alias, signers.BIP0044)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := manager.CreateAddress(acc.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := signers.Path(acc.Signer, signers.AccountKeySpace, cp.Change, cp.KeyIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &channelParty{
+		wallet:  &Wallet{DB: db, AccountMgr: manager, Hsm: hsm},
+		program: cp.ControlProgram,
+		pubkey:  xpub.XPub.Derive(path).PublicKey(),
+	}
+}
+
+func attachChannelBlock(parties []*channelParty, block *types.Block, detach bool) {
+	for _, p := range parties {
+		batch := p.wallet.DB.NewBatch()
+		if detach {
+			p.wallet.detachChannels(batch, block)
+		} else {
+			p.wallet.attachChannels(batch, block)
+		}
+		batch.Write()
+	}
+}
+
+type mockChannelChain struct {
+	Chain
+	bestHeight uint64
+}
+
+func (c *mockChannelChain) BestBlockHeight() uint64 {
+	return c.bestHeight
+}
+
+func fundChannel(t *testing.T, parties []*channelParty, timeout uint64) (*types.Block, *bc.Hash) {
+	sender, receiver := parties[0], parties[1]
+	program, err := vmutil.PaymentChannelProgram(sender.pubkey, receiver.pubkey, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fundTx := types.NewTx(types.TxData{
+		Version: 1,
+		Inputs:  []*types.TxInput{types.NewSpendInput(nil, bc.NewHash([32]byte{1}), *consensus.KUSKAssetID, 1100, 0, sender.program, nil)},
+		Outputs: []*types.TxOutput{types.NewOriginalTxOutput(*consensus.KUSKAssetID, 1000, program, [][]byte{sender.program, receiver.program})},
+	})
+	fundBlock := &types.Block{BlockHeader: types.BlockHeader{Height: 1}, Transactions: []*types.Tx{fundTx}}
+	attachChannelBlock(parties, fundBlock, false)
+	return fundBlock, fundTx.OutputID(0)
+}
+
+// buildChannelClose builds and signs the close transaction by the wallet, the
+// witness is verified against the channel program
+func buildChannelClose(t *testing.T, w *Wallet, channel *PaymentChannel) *txbuilder.Template {
+	action, err := w.DecodeCloseChannelAction([]byte(`{"channel_id": "` + channel.ID.String() + `"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buildChannelTx(t, w, channel, action, 1)
+}
+
+// buildChannelTx builds and signs the transaction of the channel action, the
+// witness is verified against the channel program at the block height
+func buildChannelTx(t *testing.T, w *Wallet, channel *PaymentChannel, action txbuilder.Action, height uint64) *txbuilder.Template {
+	tpl, err := txbuilder.Build(context.Background(), nil, []txbuilder.Action{action}, time.Now().Add(time.Minute), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signFn := func(_ context.Context, xpub chainkd.XPub, path [][]byte, data [32]byte, password string) ([]byte, error) {
+		return w.Hsm.XSign(xpub, path, data[:], password)
+	}
+	if err := txbuilder.Sign(context.Background(), tpl, "password", signFn); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vm.Verify(&vm.Context{
+		VMVersion:   1,
+		Code:        channel.ControlProgram,
+		Arguments:   tpl.Transaction.Inputs[0].Arguments(),
+		BlockHeight: &height,
+		TxSigHash: func() []byte {
+			hash := tpl.Hash(0)
+			return hash.Bytes()
+		},
+	}, 100000); err != nil {
+		t.Fatalf("verify %s transaction: %v", action.ActionType(), err)
+	}
+	return tpl
+}
+
+func TestPaymentChannel(t *testing.T) {
+	dirPath, err := ioutil.TempDir(".", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	hsm, err := pseudohsm.New(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := newChannelParty(t, hsm, "sender")
+	receiver := newChannelParty(t, hsm, "receiver")
+	parties := []*channelParty{sender, receiver}
+	fundBlock, channelID := fundChannel(t, parties, 100)
+	for _, c := range []struct {
+		party *channelParty
+		role  string
+	}{{sender, ChannelSender}, {receiver, ChannelReceiver}} {
+		channel, err := c.party.wallet.GetPaymentChannel(channelID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if channel.Role != c.role || channel.Status != ChannelOpen || channel.Capacity != 1000 {
+			t.Fatalf("got channel %+v, want the %s of the open channel", channel, c.role)
+		}
+	}
+
+	staleUpdate, err := sender.wallet.SignChannelPayment(channelID, 100, 10, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := receiver.wallet.AcceptChannelUpdate(staleUpdate); err != nil {
+		t.Fatal(err)
+	}
+
+	update, err := sender.wallet.SignChannelPayment(channelID, 300, 10, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := receiver.wallet.AcceptChannelUpdate(update); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := *update
+	forged.Paid = 400
+	for i, c := range []struct {
+		err error
+		fn  func() error
+	}{
+		{ErrChannelUpdate, func() error { _, err := receiver.wallet.AcceptChannelUpdate(staleUpdate); return err }},
+		{ErrChannelUpdate, func() error { _, err := receiver.wallet.AcceptChannelUpdate(&forged); return err }},
+		{ErrChannelUpdate, func() error { _, err := sender.wallet.SignChannelPayment(channelID, 200, 10, "password"); return err }},
+		{ErrChannelUpdate, func() error { _, err := sender.wallet.SignChannelPayment(channelID, 995, 10, "password"); return err }},
+		{ErrChannelUpdate, func() error { _, err := sender.wallet.SignChannelPayment(channelID, 500, 0, "password"); return err }},
+		{ErrChannelRole, func() error { _, err := receiver.wallet.SignChannelPayment(channelID, 500, 10, "password"); return err }},
+		{ErrChannelRole, func() error { _, err := sender.wallet.SignChannelClose(channelID, "password"); return err }},
+	} {
+		if err := c.fn(); errors.Root(err) != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+		}
+	}
+
+	// the receiver can close the channel without the sender
+	channel, err := receiver.wallet.GetPaymentChannel(channelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closeAction, err := receiver.wallet.DecodeCloseChannelAction([]byte(`{"channel_id": "` + channelID.String() + `"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputAction, err := txbuilder.DecodeControlProgramAction([]byte(`{"asset_id": "` + consensus.KUSKAssetID.String() + `", "amount": 10, "control_program": "51"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the signed close transaction can't be changed by the request
+	for i, c := range []struct {
+		actions   []txbuilder.Action
+		timeRange uint64
+	}{
+		{actions: []txbuilder.Action{closeAction, outputAction}},
+		{actions: []txbuilder.Action{closeAction}, timeRange: 100},
+	} {
+		if _, err := txbuilder.Build(context.Background(), nil, c.actions, time.Now().Add(time.Minute), c.timeRange); errors.Root(err) != ErrChannelUpdate {
+			t.Errorf("case %d: got error %v building the changed close transaction, want %v", i, err, ErrChannelUpdate)
+		}
+	}
+
+	tpl := buildChannelClose(t, receiver.wallet, channel)
+	if outs := tpl.Transaction.Outputs; len(outs) != 2 || outs[0].Amount != 300 || outs[1].Amount != 690 {
+		t.Fatalf("got close outputs %+v, want 300 to the receiver and 690 to the sender", outs)
+	}
+
+	// the sender closes the channel cooperatively with the receiver signature
+	closeUpdate, err := receiver.wallet.SignChannelClose(channelID, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if channel, err = sender.wallet.AcceptChannelUpdate(closeUpdate); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl = buildChannelClose(t, sender.wallet, channel)
+	closeBlock := &types.Block{BlockHeader: types.BlockHeader{Height: 2, PreviousBlockHash: fundBlock.Hash()}, Transactions: []*types.Tx{tpl.Transaction}}
+	attachChannelBlock(parties, closeBlock, false)
+	for _, p := range parties {
+		channel, err := p.wallet.GetPaymentChannel(channelID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if channel.Status != ChannelClosed || channel.CloseTxID == nil || *channel.CloseTxID != tpl.Transaction.ID {
+			t.Fatalf("got channel %+v after the close is attached", channel)
+		}
+	}
+
+	attachChannelBlock(parties, closeBlock, true)
+	for _, p := range parties {
+		channel, err := p.wallet.GetPaymentChannel(channelID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if channel.Status != ChannelOpen || channel.CloseTxID != nil || channel.Paid != 300 {
+			t.Fatalf("got channel %+v after the close is detached", channel)
+		}
+	}
+
+	attachChannelBlock(parties, fundBlock, true)
+	if channel, err = receiver.wallet.GetPaymentChannel(channelID); err != nil || channel.Status != ChannelOrphaned {
+		t.Fatalf("got channel %+v, error %v after the funding is detached", channel, err)
+	}
+}
+
+func TestPaymentChannelRefund(t *testing.T) {
+	dirPath, err := ioutil.TempDir(".", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	hsm, err := pseudohsm.New(dirPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := newChannelParty(t, hsm, "sender")
+	receiver := newChannelParty(t, hsm, "receiver")
+	parties := []*channelParty{sender, receiver}
+	_, channelID := fundChannel(t, parties, 100)
+
+	chain := &mockChannelChain{bestHeight: 98}
+	sender.wallet.chain, receiver.wallet.chain = chain, chain
+	decodeRefund := func(w *Wallet, fee uint64) txbuilder.Action {
+		action, err := w.DecodeRefundChannelAction([]byte(fmt.Sprintf(`{"channel_id": "%s", "fee": %d}`, channelID.String(), fee)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return action
+	}
+
+	for i, c := range []struct {
+		action txbuilder.Action
+		err    error
+	}{
+		{decodeRefund(sender.wallet, 10), ErrChannelTimeout},
+		{decodeRefund(receiver.wallet, 10), ErrChannelRole},
+	} {
+		if err := c.action.Build(context.Background(), txbuilder.NewBuilder(time.Now().Add(time.Minute))); errors.Root(err) != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+		}
+	}
+
+	chain.bestHeight = 99
+	channels, err := sender.wallet.ListPaymentChannels("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(channels) != 1 || !channels[0].Refundable {
+		t.Fatalf("got channels %+v, want the refundable channel", channels)
+	}
+
+	if err := decodeRefund(sender.wallet, 0).Build(context.Background(), txbuilder.NewBuilder(time.Now().Add(time.Minute))); errors.Root(err) != ErrChannelUpdate {
+		t.Errorf("got error %v refunding without fee, want %v", err, ErrChannelUpdate)
+	}
+
+	tpl := buildChannelTx(t, sender.wallet, channels[0], decodeRefund(sender.wallet, 10), 100)
+	if outs := tpl.Transaction.Outputs; len(outs) != 1 || outs[0].Amount != 990 || !bytes.Equal(outs[0].ControlProgram, sender.program) {
+		t.Fatalf("got refund outputs %+v, want 990 to the sender", outs)
+	}
+
+	refundBlock := &types.Block{BlockHeader: types.BlockHeader{Height: 100}, Transactions: []*types.Tx{tpl.Transaction}}
+	attachChannelBlock(parties, refundBlock, false)
+	for _, p := range parties {
+		channel, err := p.wallet.GetPaymentChannel(channelID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if channel.Status != ChannelRefunded || channel.CloseTxID == nil || *channel.CloseTxID != tpl.Transaction.ID {
+			t.Fatalf("got channel %+v after the refund is attached", channel)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"kuskcore/account"
+	dbm "kuskcore/database/leveldb"
+	chainjson "kuskcore/encoding/json"
+	"kuskcore/protocol/bc"
+	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm"
+	"kuskcore/protocol/vm/vmutil"
+)
+
+const (
+	// HTLCSecretPrefix is the prefix of the known htlc preimages
+	HTLCSecretPrefix = "HTLCSecret:"
+
+	htlcSecretSize = vmutil.HTLCPreimageSize
+)
+
+// HTLC is a pending hash time-locked contract output of the local keys
+type HTLC struct {
+	OutputID       bc.Hash            `json:"output_id"`
+	AccountID      string             `json:"account_id"`
+	AccountAlias   string             `json:"account_alias"`
+	AssetID        bc.AssetID         `json:"asset_id"`
+	Amount         uint64             `json:"amount"`
+	Role           string             `json:"role"`
+	SecretHash     chainjson.HexBytes `json:"secret_hash"`
+	Preimage       chainjson.HexBytes `json:"preimage,omitempty"`
+	Recipient      chainjson.HexBytes `json:"recipient_pubkey"`
+	Sender         chainjson.HexBytes `json:"sender_pubkey"`
+	LockHeight     uint64             `json:"lock_height"`
+	Refundable     bool               `json:"refundable"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+}
+
+func calcHTLCSecretKey(secretHash []byte) []byte {
+	return []byte(HTLCSecretPrefix + hex.EncodeToString(secretHash))
+}
+
+// NewHTLCSecret generates a random preimage and saves it, the sha256 hash of
+// the preimage is used as the secret hash of the htlc
+func (w *Wallet) NewHTLCSecret() ([]byte, []byte, error) {
+	preimage := make([]byte, htlcSecretSize)
+	if _, err := rand.Read(preimage); err != nil {
+		return nil, nil, err
+	}
+
+	secretHash := sha256.Sum256(preimage)
+	w.DB.Set(calcHTLCSecretKey(secretHash[:]), preimage)
+	return preimage, secretHash[:], nil
+}
+
+// GetHTLCPreimage return the known preimage of the secret hash
+func (w *Wallet) GetHTLCPreimage(secretHash []byte) []byte {
+	return w.DB.Get(calcHTLCSecretKey(secretHash))
+}
+
+// ListHTLCs return the pending htlc outputs of the account, the preimages are
+// attached once they're generated locally or revealed by the claims on chain
+func (w *Wallet) ListHTLCs(accountID string) []*HTLC {
+	htlcs := []*HTLC{}
+	bestHeight := w.chain.BestBlockHeight()
+	for _, utxo := range w.GetAccountUtxos(accountID, "", false, true, false) {
+		contract, err := vmutil.ParseHTLCProgram(utxo.ControlProgram)
+		if err != nil {
+			continue
+		}
+
+		role := "sender"
+		if cp, err := w.AccountMgr.GetLocalCtrlProgramByPubkey(contract.Recipient); err == nil && cp.AccountID == utxo.AccountID {
+			role = "recipient"
+		}
+
+		htlcs = append(htlcs, &HTLC{
+			OutputID:       utxo.OutputID,
+			AccountID:      utxo.AccountID,
+			AccountAlias:   w.AccountMgr.GetAliasByID(utxo.AccountID),
+			AssetID:        utxo.AssetID,
+			Amount:         utxo.Amount,
+			Role:           role,
+			SecretHash:     contract.SecretHash,
+			Preimage:       w.GetHTLCPreimage(contract.SecretHash),
+			Recipient:      chainjson.HexBytes(contract.Recipient),
+			Sender:         chainjson.HexBytes(contract.Sender),
+			LockHeight:     contract.LockHeight,
+			Refundable:     bestHeight+1 >= contract.LockHeight,
+			ControlProgram: utxo.ControlProgram,
+		})
+	}
+	return htlcs
+}
+
+// htlcCtrlProgram return the local control program of the recipient or the
+// sender of the htlc, the recipient one is preferred. The parties of the htlc
+// are single keys, so only the single signature accounts are matched.
+func (w *Wallet) htlcCtrlProgram(contract *vmutil.HTLCContract) *account.CtrlProgram {
+	for _, pubkey := range [][]byte{contract.Recipient, contract.Sender} {
+		if cp, err := w.AccountMgr.GetLocalCtrlProgramByPubkey(pubkey); err == nil {
+			return cp
+		}
+	}
+	return nil
+}
+
+// saveHTLCSecrets saves the preimages revealed by the claims of the local htlcs
+func (w *Wallet) saveHTLCSecrets(batch dbm.Batch, b *types.Block) {
+	for _, tx := range b.Transactions {
+		for _, input := range tx.Inputs {
+			spend, ok := input.TypedInput.(*types.SpendInput)
+			if !ok || len(spend.Arguments) != 3 || !vm.AsBool(spend.Arguments[2]) {
+				continue
+			}
+
+			contract, err := vmutil.ParseHTLCProgram(spend.ControlProgram)
+			if err != nil || w.htlcCtrlProgram(contract) == nil {
+				continue
+			}
+
+			if hash := sha256.Sum256(spend.Arguments[1]); bytes.Equal(hash[:], contract.SecretHash) {
+				batch.Set(calcHTLCSecretKey(contract.SecretHash), spend.Arguments[1])
+			}
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"kuskcore/errors"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm/vmutil"
 )
 
 const (
@@ -320,6 +321,11 @@ func (w *Wallet) indexBalances(accountUTXOs []*account.UTXO) ([]AccountBalance,
 	balances := []AccountBalance{}
 
 	for _, accountUTXO := range accountUTXOs {
+		// the htlc outputs are listed by ListHTLCs, they're not spendable balance
+		if vmutil.IsHTLCProgram(accountUTXO.ControlProgram) {
+			continue
+		}
+
 		assetID := accountUTXO.AssetID.String()
 		if _, ok := accBalance[accountUTXO.AccountID]; ok {
 			if _, ok := accBalance[accountUTXO.AccountID][assetID]; ok {
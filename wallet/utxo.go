@@ -13,6 +13,7 @@ import (
 	"kuskcore/errors"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm/vmutil"
 )
 
 // GetAccountUtxos return all account unspent outputs
@@ -103,6 +104,18 @@ func (w *Wallet) filterAccountUtxo(utxos []*account.UTXO) []*account.UTXO {
 
 	result := make([]*account.UTXO, 0, len(utxos))
 	for s := range outsByScript {
+		if contract, err := vmutil.ParseHTLCProgram([]byte(s)); err == nil {
+			if cp := w.htlcCtrlProgram(contract); cp != nil {
+				for _, utxo := range outsByScript[s] {
+					utxo.AccountID = cp.AccountID
+					utxo.ControlProgramIndex = cp.KeyIndex
+					utxo.Change = cp.Change
+					result = append(result, utxo)
+				}
+			}
+			continue
+		}
+
 		if !segwit.IsP2WScript([]byte(s)) {
 			continue
 		}
@@ -13,6 +13,7 @@ import (
 	dbm "kuskcore/database/leveldb"
 	"kuskcore/protocol/bc"
 	"kuskcore/protocol/bc/types"
+	"kuskcore/protocol/vm/vmutil"
 	"kuskcore/testutil"
 )
 
@@ -639,3 +640,20 @@ func TestTxOutToUtxos(t *testing.T) {
 		}
 	}
 }
+
+func TestIndexBalancesSkipHTLC(t *testing.T) {
+	htlcProgram, err := vmutil.HTLCProgram(make([]byte, 32), make([]byte, 32), make([]byte, 32), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Wallet{}
+	balances, err := w.indexBalances([]*account.UTXO{{OutputID: bc.Hash{V0: 1}, AccountID: "testAccount", Amount: 1, ControlProgram: htlcProgram}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(balances) != 0 {
+		t.Errorf("got balances %v of the htlc output, want none", balances)
+	}
+}
@@ -41,6 +41,18 @@ type StatusInfo struct {
 	BestHash   bc.Hash
 }
 
+// Chain is the chain followed by the wallet, it's the core chain of the full
+// node or the header chain of the light node which only keeps the filtered
+// transactions of the blocks
+type Chain interface {
+	BestBlockHeight() uint64
+	BlockExist(*bc.Hash) bool
+	BlockWaiter(height uint64) <-chan struct{}
+	GetBlockByHash(*bc.Hash) (*types.Block, error)
+	GetBlockByHeight(uint64) (*types.Block, error)
+	InMainChain(bc.Hash) bool
+}
+
 // Wallet is related to storing account unspent outputs
 type Wallet struct {
 	DB              dbm.DB
@@ -51,7 +63,7 @@ type Wallet struct {
 	AssetReg        *asset.Registry
 	ContractReg     *contract.Registry
 	Hsm             *pseudohsm.HSM
-	chain           *protocol.Chain
+	chain           Chain
 	RecoveryMgr     *recoveryManager
 	eventDispatcher *event.Dispatcher
 	txMsgSub        *event.Subscription
@@ -60,7 +72,7 @@ type Wallet struct {
 }
 
 // NewWallet return a new wallet instance
-func NewWallet(walletDB dbm.DB, account *account.Manager, asset *asset.Registry, contract *contract.Registry, hsm *pseudohsm.HSM, chain *protocol.Chain, dispatcher *event.Dispatcher, txIndexFlag bool) (*Wallet, error) {
+func NewWallet(walletDB dbm.DB, account *account.Manager, asset *asset.Registry, contract *contract.Registry, hsm *pseudohsm.HSM, chain Chain, dispatcher *event.Dispatcher, txIndexFlag bool) (*Wallet, error) {
 	w := &Wallet{
 		DB:              walletDB,
 		AccountMgr:      account,
@@ -192,6 +204,8 @@ func (w *Wallet) AttachBlock(block *types.Block) error {
 	}
 
 	w.attachUtxos(storeBatch, block)
+	w.saveHTLCSecrets(storeBatch, block)
+	w.attachChannels(storeBatch, block)
 	w.status.WorkHeight = block.Height
 	w.status.WorkHash = block.Hash()
 	if w.status.WorkHeight >= w.status.BestHeight {
@@ -208,6 +222,7 @@ func (w *Wallet) DetachBlock(block *types.Block) error {
 
 	storeBatch := w.DB.NewBatch()
 	w.detachUtxos(storeBatch, block)
+	w.detachChannels(storeBatch, block)
 	w.deleteTransactions(storeBatch, w.status.BestHeight)
 
 	w.status.BestHeight = block.Height - 1
@@ -347,6 +362,11 @@ func (w *Wallet) walletBlockWaiter() {
 	}
 }
 
+// BestBlockHeight return the height of the best block of the followed chain
+func (w *Wallet) BestBlockHeight() uint64 {
+	return w.chain.BestBlockHeight()
+}
+
 // GetWalletStatusInfo return current wallet StatusInfo
 func (w *Wallet) GetWalletStatusInfo() StatusInfo {
 	w.rw.RLock()